@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"sensor-edge/config"
+	"sensor-edge/protocols/bacnet"
+	"sensor-edge/types"
+)
+
+// discoverBacnetDevices 广播 Who-Is，把未在 devices.yaml 中配置的设备注册到 devMap，
+// 设备的协议参数取 protocols.yaml 中名为 protocol_name 的 bacnet 实例（未配置时取第一个）。
+// 发现服务保持运行，之后设备主动广播的 I-Am 也会进入注册表
+func discoverBacnetDevices(conf types.BacnetDiscoveryConfig, protoConf map[string][]map[string]interface{}, devMap map[string]types.DeviceConfigWithMeta) {
+	low, high, err := bacnet.ParseInstanceRange(conf.InstanceRange)
+	if err != nil {
+		fmt.Printf("[BACNET] 发现配置错误: %v\n", err)
+		return
+	}
+	protocolName, err := bacnetProtocolName(conf.ProtocolName, protoConf)
+	if err != nil {
+		fmt.Printf("[BACNET] 发现配置错误: %v\n", err)
+		return
+	}
+	wait := time.Duration(conf.Wait) * time.Second
+	if wait <= 0 {
+		wait = 3 * time.Second
+	}
+	disc, err := bacnet.NewDiscovery(conf.Listen, conf.Broadcast, 3*time.Second)
+	if err != nil {
+		fmt.Printf("[BACNET] 发现服务启动失败: %v\n", err)
+		return
+	}
+	devices, err := disc.Discover(low, high, wait)
+	if err != nil {
+		fmt.Printf("[BACNET] Who-Is 发送失败: %v\n", err)
+		return
+	}
+	var sets []types.DevicePointSetV2
+	for _, dev := range devices {
+		id := strconv.FormatUint(uint64(dev.DeviceID), 10)
+		if _, exists := devMap[id]; exists {
+			continue
+		}
+		name := dev.ObjectName
+		if name == "" {
+			name = dev.ModelName
+		}
+		fmt.Printf("[BACNET] 发现新设备: id=%s, address=%s:%d, name=%s, vendor=%s, model=%s, firmware=%s\n",
+			id, dev.IP, dev.Port, dev.ObjectName, dev.VendorName, dev.ModelName, dev.FirmwareRevision)
+		devMap[id] = types.DeviceConfigWithMeta{
+			DeviceMeta: types.DeviceMeta{
+				ID:           id,
+				Name:         name,
+				Protocol:     "bacnet",
+				ProtocolName: protocolName,
+				IP:           dev.IP,
+			},
+			Config: map[string]interface{}{
+				"object_device": int(dev.DeviceID),
+				"ip":            dev.IP,
+				"port":          dev.Port,
+			},
+		}
+		if conf.PointsFile != "" {
+			if set, err := generateBacnetPoints(id, protocolName, devMap[id].Config); err != nil {
+				fmt.Printf("[BACNET] 设备 %s 生成点位失败: %v\n", id, err)
+			} else {
+				sets = append(sets, set)
+			}
+		}
+	}
+	if len(sets) > 0 {
+		if err := bacnet.WritePointsToFile(conf.PointsFile, sets); err != nil {
+			fmt.Printf("[BACNET] 写入点位配置失败: %v\n", err)
+		} else {
+			fmt.Printf("[BACNET] 已为 %d 台新设备生成点位配置: %s\n", len(sets), conf.PointsFile)
+		}
+	}
+}
+
+// generateBacnetPoints 读取设备的 objectList 及对象名称、单位、描述，生成点位配置
+func generateBacnetPoints(id, protocolName string, devConfig map[string]interface{}) (types.DevicePointSetV2, error) {
+	client := &bacnet.BacnetClient{}
+	if err := client.Init(devConfig); err != nil {
+		return types.DevicePointSetV2{}, err
+	}
+	defer client.Close()
+	return client.GeneratePointSet(id, protocolName)
+}
+
+// bacnetProtocolName 发现的设备使用的 bacnet 参数实例名，必须在 protocols.yaml 中存在，否则设备不会被采集
+func bacnetProtocolName(name string, protoConf map[string][]map[string]interface{}) (string, error) {
+	entries := protoConf["bacnet"]
+	if name == "" {
+		if len(entries) == 0 {
+			return "", fmt.Errorf("protocols.yaml 中没有 bacnet 参数实例")
+		}
+		name, _ = entries[0]["name"].(string)
+		return name, nil
+	}
+	for _, p := range entries {
+		if n, _ := p["name"].(string); n == name {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("protocols.yaml 中没有名为 %s 的 bacnet 参数实例", name)
+}
+
+// checkBacnetPorts 发现服务和启用的 bacnet_server 北向各自独占一个 UDP 端口，监听地址冲突时返回错误
+func checkBacnetPorts(conf types.BacnetDiscoveryConfig, uplinks []config.UplinkConfig) error {
+	if !conf.Enable {
+		return nil
+	}
+	for _, u := range uplinks {
+		if u.Type == "bacnet_server" && u.Enable && sameUDPPort(conf.Listen, u.Listen) {
+			return fmt.Errorf("bacnet_discovery.listen %q 与北向 %s 的 listen %q 冲突", conf.Listen, u.Name, u.Listen)
+		}
+	}
+	return nil
+}
+
+// sameUDPPort 两个监听地址（为空时 :47808）端口相同，且主机相同或任一为通配地址
+func sameUDPPort(a, b string) bool {
+	split := func(addr string) (string, string) {
+		if addr == "" {
+			addr = fmt.Sprintf(":%d", bacnet.DefaultPort)
+		}
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return addr, ""
+		}
+		if host == "0.0.0.0" {
+			host = ""
+		}
+		return host, port
+	}
+	hostA, portA := split(a)
+	hostB, portB := split(b)
+	return portA == portB && (hostA == "" || hostB == "" || hostA == hostB)
+}
@@ -0,0 +1,22 @@
+package config
+
+import (
+	"os"
+
+	"sensor-edge/types"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadSnmpTrapRules 加载 SNMP Trap 接收配置和映射规则
+func LoadSnmpTrapRules(file string) (*types.SnmpTrapRules, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var rules types.SnmpTrapRules
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
@@ -21,6 +21,8 @@ type UplinkConfig struct {
 	Brokers  []string          `yaml:"brokers"`
 	Server   string            `yaml:"server"`
 	Subject  string            `yaml:"subject"`
+	Listen   string            `yaml:"listen"`  // 北向服务监听地址，如 modbus_server 的 ":502"、bacnet_server 的 ":47808"、opcua_server 的 ":4840"
+	Mapping  string            `yaml:"mapping"` // 北向服务点位映射文件
 }
 
 // LoadUplinkConfigs loads the uplink configurations from the specified file.
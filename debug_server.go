@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"sensor-edge/protocols"
+)
+
+// readPlanGroup 单个功能分组的读请求计划
+type readPlanGroup struct {
+	Function string `json:"function"`
+	protocols.ReadPlan
+}
+
+// readPlanReport 设备每轮采集的读请求计划
+type readPlanReport struct {
+	DeviceID   string          `json:"device_id"`
+	RoundTrips int             `json:"round_trips"`
+	Groups     []readPlanGroup `json:"groups"`
+}
+
+// priorityArrayReport 可命令点位各优先级上的命令值
+type priorityArrayReport struct {
+	DeviceID       string        `json:"device_id"`
+	Point          string        `json:"point"`
+	ActivePriority int           `json:"active_priority"` // 生效的优先级，0 表示都已释放（relinquishDefault 生效）
+	PriorityArray  []interface{} `json:"priority_array"`  // 下标 0 为优先级 1，未占用为 null
+}
+
+// startDebugServer 启动调试 HTTP 服务：
+//
+//	GET  /debug/read_plan?device_id=xxx  返回设备每轮采集的读请求计划和往返次数
+//	GET  /debug/priority_array?device_id=xxx&point=yyy  返回可命令点位 16 个优先级上的命令值，查看由谁占用控制
+//	POST /debug/relinquish?device_id=xxx&point=yyy&priority=8  释放点位在该优先级上的命令，priority 省略时用点位配置的优先级
+func startDebugServer(addr string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/read_plan", handleReadPlan)
+	mux.HandleFunc("/debug/priority_array", handlePriorityArray)
+	mux.HandleFunc("/debug/relinquish", handleRelinquish)
+	go func() {
+		fmt.Printf("[DEBUG] 调试服务监听 %s\n", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			fmt.Printf("[DEBUG] 调试服务退出: %v\n", err)
+		}
+	}()
+}
+
+func handleReadPlan(w http.ResponseWriter, r *http.Request) {
+	deviceID := r.URL.Query().Get("device_id")
+	dev, ok := lookupRunningDevice(deviceID)
+	if !ok {
+		http.Error(w, fmt.Sprintf("device %s not found", deviceID), http.StatusNotFound)
+		return
+	}
+	planner, ok := dev.client.(protocols.ReadPlanner)
+	if !ok {
+		http.Error(w, fmt.Sprintf("device %s does not support read plan", deviceID), http.StatusNotImplemented)
+		return
+	}
+	report := readPlanReport{DeviceID: deviceID}
+	for _, funcGroup := range dev.set.Functions {
+		plan, err := planner.ReadPlan(deviceID, funcGroup.Function, extractPointAddresses(funcGroup.Points))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		report.RoundTrips += len(plan.Requests)
+		report.Groups = append(report.Groups, readPlanGroup{Function: funcGroup.Function, ReadPlan: plan})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// priorityCommander 查找设备和点位地址，设备的客户端需实现 protocols.PriorityCommander
+func priorityCommander(w http.ResponseWriter, r *http.Request) (protocols.PriorityCommander, string, bool) {
+	deviceID, point := r.URL.Query().Get("device_id"), r.URL.Query().Get("point")
+	dev, ok := lookupRunningDevice(deviceID)
+	if !ok {
+		http.Error(w, fmt.Sprintf("device %s not found", deviceID), http.StatusNotFound)
+		return nil, "", false
+	}
+	commander, ok := dev.client.(protocols.PriorityCommander)
+	if !ok {
+		http.Error(w, fmt.Sprintf("device %s does not support command priorities", deviceID), http.StatusNotImplemented)
+		return nil, "", false
+	}
+	for _, funcGroup := range dev.set.Functions {
+		for _, p := range funcGroup.Points {
+			if p.Name == point {
+				return commander, p.Address, true
+			}
+		}
+	}
+	http.Error(w, fmt.Sprintf("point %s not found on device %s", point, deviceID), http.StatusNotFound)
+	return nil, "", false
+}
+
+func handlePriorityArray(w http.ResponseWriter, r *http.Request) {
+	commander, addr, ok := priorityCommander(w, r)
+	if !ok {
+		return
+	}
+	slots, err := commander.ReadPriorityArray(addr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	report := priorityArrayReport{DeviceID: r.URL.Query().Get("device_id"), Point: r.URL.Query().Get("point"), PriorityArray: slots}
+	for i, v := range slots {
+		if v != nil {
+			report.ActivePriority = i + 1
+			break
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func handleRelinquish(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	priority := 0
+	if s := r.URL.Query().Get("priority"); s != "" {
+		var err error
+		if priority, err = strconv.Atoi(s); err != nil || priority < 1 || priority > 16 {
+			http.Error(w, fmt.Sprintf("invalid priority %s", s), http.StatusBadRequest)
+			return
+		}
+	}
+	commander, addr, ok := priorityCommander(w, r)
+	if !ok {
+		return
+	}
+	if err := commander.Relinquish(addr, priority); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
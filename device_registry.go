@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"sensor-edge/protocols"
+	"sensor-edge/protocols/modbus"
+	"sensor-edge/types"
+)
+
+// runningDevice 已启动采集的设备，供调试接口和北向写入查找点位与客户端
+type runningDevice struct {
+	set     types.DevicePointSetV2
+	devConf types.DeviceConfigWithMeta
+	client  protocols.Protocol
+}
+
+var (
+	runningDevices   = make(map[string]runningDevice)
+	runningDevicesMu sync.RWMutex
+)
+
+// registerRunningDevice 记录设备的点位分组、配置和客户端
+func registerRunningDevice(set types.DevicePointSetV2, devConf types.DeviceConfigWithMeta, client protocols.Protocol) {
+	runningDevicesMu.Lock()
+	defer runningDevicesMu.Unlock()
+	runningDevices[set.DeviceID] = runningDevice{set: set, devConf: devConf, client: client}
+}
+
+func lookupRunningDevice(deviceID string) (runningDevice, bool) {
+	runningDevicesMu.RLock()
+	defer runningDevicesMu.RUnlock()
+	dev, ok := runningDevices[deviceID]
+	return dev, ok
+}
+
+// writeDevicePoint 北向写入：按点位名找到源设备的点位地址，通过该设备的客户端写入。
+// 写入值原样下发，不做 Transform 的逆运算
+func writeDevicePoint(deviceID, point string, value interface{}) error {
+	dev, ok := lookupRunningDevice(deviceID)
+	if !ok {
+		return fmt.Errorf("device %s not running", deviceID)
+	}
+	for _, funcGroup := range dev.set.Functions {
+		for _, p := range funcGroup.Points {
+			if p.Name != point {
+				continue
+			}
+			// 共享连接的 Modbus 从站写入前切换从站号
+			if m, ok := dev.client.(*modbus.ModbusTCP); ok {
+				switch vv := dev.devConf.Config["slave_id"].(type) {
+				case int:
+					m.SetSlave(byte(vv))
+				case float64:
+					m.SetSlave(byte(vv))
+				}
+			}
+			return dev.client.Write(p.Address, value)
+		}
+	}
+	return fmt.Errorf("point %s not found on device %s", point, deviceID)
+}
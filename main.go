@@ -4,14 +4,12 @@ import (
 	"encoding/binary"
 	"fmt"
 	"math"
-	"net"
 	"os"
 	"os/signal"
 	"reflect"
 	"sensor-edge/config"
 	"sensor-edge/edgecompute"
 	"sensor-edge/protocols"
-	"sensor-edge/protocols/bacnet"
 	"sensor-edge/protocols/modbus"
 	"sensor-edge/schema"
 	"sensor-edge/types"
@@ -27,6 +25,12 @@ import (
 	"gopkg.in/yaml.v3"
 
 	_ "sensor-edge/protocols/bacnet"
+	_ "sensor-edge/protocols/httpclient"
+	_ "sensor-edge/protocols/iec104"
+	_ "sensor-edge/protocols/mqttsouth"
+	_ "sensor-edge/protocols/s7"
+	_ "sensor-edge/protocols/slmp"
+	_ "sensor-edge/protocols/tcpclient"
 )
 
 // 客户端池Key
@@ -34,6 +38,11 @@ type ClientKey struct {
 	Protocol string
 	IP       string
 	Port     int
+	Serial   string // 串口名，串口类协议按 串口+从站 区分实例，总线由驱动内部共享
+	SlaveID  int
+	Broker   string // MQTT 等经 broker 接入的协议按 broker 区分实例，设备由驱动内部按主题区分
+	URL      string // HTTP、OPC UA 等按 URL 接入、可以没有 ip/port 的协议按 URL 区分实例
+	Device   string // 认证、请求模板等都是设备级配置的协议（HTTP）每台设备独立实例
 }
 
 var clientCache = make(map[ClientKey]protocols.Protocol)
@@ -53,6 +62,8 @@ func toPointConfig(points []types.PointMapping) []protocols.PointConfig {
 			Type:      p.Type,
 			Unit:      p.Unit,
 			Transform: p.Transform,
+			Format:    p.Format,
+			Options:   p.Options,
 		})
 	}
 	return out
@@ -113,7 +124,7 @@ func readWithRetry(client protocols.Protocol, devID string, function string, add
 }
 
 // 获取或创建协议客户端（池化）
-func getOrCreateClient(protocol string, config map[string]interface{}) (protocols.Protocol, error) {
+func getOrCreateClient(deviceID, protocol string, config map[string]interface{}) (protocols.Protocol, error) {
 	ip, _ := config["ip"].(string)
 	port := 0
 	switch v := config["port"].(type) {
@@ -123,6 +134,24 @@ func getOrCreateClient(protocol string, config map[string]interface{}) (protocol
 		port = int(v)
 	}
 	key := ClientKey{Protocol: protocol, IP: ip, Port: port}
+	key.Broker, _ = config["broker"].(string)
+	key.URL, _ = config["endpoint"].(string)
+	base, _ := config["base_url"].(string)
+	if u, _ := config["url"].(string); base+u != "" {
+		key.URL = base + u
+	}
+	if protocol == "http" {
+		key.Device = deviceID
+	}
+	if serial, ok := config["port"].(string); ok {
+		key.Serial = serial
+		switch v := config["slave_id"].(type) {
+		case int:
+			key.SlaveID = v
+		case float64:
+			key.SlaveID = int(v)
+		}
+	}
 	if client, exists := clientCache[key]; exists {
 		return client, nil
 	}
@@ -138,6 +167,147 @@ func getOrCreateClient(protocol string, config map[string]interface{}) (protocol
 	return client, nil
 }
 
+// convertPointValue 按点位的 Format、Transform、Type 把驱动返回的原始值转换为上报值
+func convertPointValue(deviceID string, p types.PointMapping, val interface{}) interface{} {
+	// 自动兼容驱动返回 [uint16,uint16] 的 float/double 点位
+	if arr, ok := val.([]uint16); ok && len(arr) == 2 && strings.HasPrefix(strings.ToUpper(p.Format), "FLOAT") {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint16(b[0:2], arr[0])
+		binary.BigEndian.PutUint16(b[2:4], arr[1])
+		val = b
+	}
+	if arr, ok := val.([]uint16); ok && len(arr) == 4 && strings.HasPrefix(strings.ToUpper(p.Format), "DOUBLE") {
+		b := make([]byte, 8)
+		binary.BigEndian.PutUint16(b[0:2], arr[0])
+		binary.BigEndian.PutUint16(b[2:4], arr[1])
+		binary.BigEndian.PutUint16(b[4:6], arr[2])
+		binary.BigEndian.PutUint16(b[6:8], arr[3])
+		val = b
+	}
+	// 其他多寄存器格式（如 Long）同样按大端拼接为字节后交给 Format 解析
+	if arr, ok := val.([]uint16); ok && len(arr) > 1 && p.Format != "" {
+		b := make([]byte, 2*len(arr))
+		for i, r := range arr {
+			binary.BigEndian.PutUint16(b[2*i:], r)
+		}
+		val = b
+	}
+	// 兼容驱动直接返回 uint32 且 format 为 float 的情况
+	if u32, ok := val.(uint32); ok && strings.HasPrefix(strings.ToUpper(p.Format), "FLOAT") {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, u32)
+		val = b
+	}
+	// 新增：兼容 format 为 float 且只收到单个 uint16 的情况，自动补齐为4字节 float32
+	if strings.HasPrefix(strings.ToUpper(p.Format), "FLOAT") {
+		switch vv := val.(type) {
+		case uint16:
+			b := make([]byte, 4)
+			binary.BigEndian.PutUint16(b[0:2], vv)
+			val = b
+		case []uint16:
+			if len(vv) == 1 {
+				b := make([]byte, 4)
+				binary.BigEndian.PutUint16(b[0:2], vv[0])
+				val = b
+			}
+		}
+	}
+	// 使用 Format 字段进行格式化
+	if p.Format != "" {
+		val2, err := utils.ParseAndCastFormat(p.Format, val)
+		if err == nil {
+			val = val2
+		}
+	}
+	// 使用 Transform 表达式
+	if p.Transform != "" {
+		val, err := parseTransform(p.Transform, val)
+		if err != nil {
+
+			fmt.Printf("[WARN] 设备 %s 点位 %s 转换失败: %v\n", deviceID, p.Name, err)
+		} else {
+			// 如果转换结果是字符串，尝试转换为 float
+			if strVal, ok := val.(string); ok {
+				if f, err := strconv.ParseFloat(strVal, 64); err == nil {
+					val = f
+				}
+			}
+		}
+
+	}
+	// 根据 Type 进行类型转换
+	if strings.ToLower(p.Type) == "float" {
+		switch vv := val.(type) {
+		case float32, float64:
+			val = math.Round(reflect.ValueOf(vv).Convert(reflect.TypeOf(float64(0))).Float()*100) / 100
+		case int, int32, int64, uint16, uint32, uint64:
+			valf := reflect.ValueOf(vv).Convert(reflect.TypeOf(float64(0))).Float()
+			val = math.Round(valf*100) / 100
+		case string:
+			if f, err := strconv.ParseFloat(vv, 64); err == nil {
+				val = math.Round(f*100) / 100
+			}
+		}
+	}
+	if strings.ToLower(p.Type) == "int" {
+		switch vv := val.(type) {
+		case float32, float64:
+			val = int(math.Round(reflect.ValueOf(vv).Convert(reflect.TypeOf(float64(0))).Float()))
+		case string:
+			if f, err := strconv.ParseFloat(vv, 64); err == nil {
+				val = int(math.Round(f))
+			}
+		}
+	}
+	return val
+}
+
+// walkRowName 表格列点位（如 SNMP 的 walk: true）每行返回 <地址>.<索引>，上报为 <点位名>.<索引>
+func walkRowName(p types.PointMapping, pointID string) (string, bool) {
+	if walk, _ := p.Options["walk"].(bool); !walk || !strings.HasPrefix(pointID, p.Address+".") {
+		return "", false
+	}
+	return p.Name + strings.TrimPrefix(pointID, p.Address), true
+}
+
+// pushPointValues 处理驱动主动上报的点位变化：转换、推进边缘规则并立即上报，报文只包含变化的点位。
+// 丢弃 bad 质量的值，uncertain（如 IEC 104 的 NT/SB/BL/OV、OPC UA 的 Uncertain 状态）照常上报
+func pushPointValues(set types.DevicePointSetV2, values []protocols.PointValue, re *edgecompute.RuleEngine, uplinkMgr *uplink.UplinkManager) {
+	pointValues := make(map[string]interface{})
+	for _, v := range values {
+		if protocols.IsBadQuality(v.Quality) {
+			continue
+		}
+		for _, funcGroup := range set.Functions {
+			for _, p := range funcGroup.Points {
+				name := p.Name
+				if v.PointID != p.Address && v.PointID != p.Name {
+					row, ok := walkRowName(p, v.PointID)
+					if !ok {
+						continue
+					}
+					name = row
+				}
+				pointValues[name] = convertPointValue(set.DeviceID, p, v.Value)
+				fmt.Printf("[%s] %s = %v (push)\n", set.DeviceID, v.PointID, pointValues[name])
+			}
+		}
+	}
+	if len(pointValues) == 0 {
+		return
+	}
+	var alarms []schema.AlarmInfo
+	if re != nil {
+		re.ApplyRules(set.DeviceID, pointValues)
+		alarms = append(alarms, re.LastAlarms...)
+	}
+	payload := uplink.EncodeDataReport(set.DeviceID, pointValues, alarms, nil)
+	if err := uplinkMgr.SendToAll(payload); err != nil {
+		fmt.Printf("[Error] 设备 %s 变化数据上报失败: %v\n", set.DeviceID, err)
+	}
+}
+
 // parseTransform 支持复杂表达式和内置函数
 func parseTransform(expr string, value interface{}) (interface{}, error) {
 	parameters := make(map[string]interface{})
@@ -184,28 +354,6 @@ func parseTransform(expr string, value interface{}) (interface{}, error) {
 }
 
 func main() {
-	// 启动 BACnet I-Am 自动发现监听
-	go func() {
-		err := bacnet.ListenIAM(47808, func(info *bacnet.DeviceInfo) {
-			bacnet.RegisterDevice(info)
-		})
-		if err != nil {
-			fmt.Printf("[BACNET] ListenIAM error: %v\n", err)
-		}
-	}()
-
-	go func() {
-		conn, _ := net.ListenUDP("udp4", &net.UDPAddr{Port: 47808}) // BACnet 默认端口
-		buf := make([]byte, 1500)
-		for {
-			n, addr, _ := conn.ReadFromUDP(buf)
-			info, err := bacnet.ParseIAm(buf[:n], addr.IP)
-			if err == nil {
-				bacnet.RegisterAndStartPolling(info)
-			}
-		}
-	}()
-
 	// 1. 通信协议接入与注册（已在各协议包init中自动完成）
 
 	// 2. 读取全局配置、协议参数、设备清单
@@ -247,6 +395,11 @@ func main() {
 		}
 	}
 
+	// 调试接口（configs/config.yaml 中配置 debug_addr 时启用）
+	if cfg, err := config.LoadConfig("configs/config.yaml"); err == nil && cfg.DebugAddr != "" {
+		startDebugServer(cfg.DebugAddr)
+	}
+
 	// 5. 加载新版分组式边缘规则
 	devRules, _ := config.LoadDeviceEdgeRules("configs/edge_rules.yaml")
 	aggRules := config.ExtractAggregateRules(devRules)
@@ -256,35 +409,26 @@ func main() {
 
 	// 6. 加载上行通道配置
 	uplinkCfgs, _ := config.LoadUplinkConfigs("configs/uplinks.yaml")
+	// BACnet 发现服务与 bacnet_server 北向不能监听同一 UDP 端口
+	if cfg, err := config.LoadConfig("configs/config.yaml"); err == nil {
+		if err := checkBacnetPorts(cfg.BacnetDiscovery, uplinkCfgs); err != nil {
+			panic(err)
+		}
+	}
 	uplinkMgr := uplink.NewUplinkManagerFromConfig(uplinkCfgs)
+	// 北向从站（如 modbus_server）收到的写入转发到源设备
+	uplinkMgr.SetPointWriter(writeDevicePoint)
 
 	fmt.Println("[System] Device collection, edge rule engine & uplink started...")
 
-	// BACnet自动发现并注册设备
-	bacnetClient := &bacnet.BacnetClient{}
-	devices, err := bacnetClient.DiscoverDevicesReal(3 * time.Second)
-	if err == nil && len(devices) > 0 {
-		for _, dev := range devices {
-			if _, exists := devMap[dev.DeviceID]; !exists {
-				fmt.Printf("[BACNET] 发现新设备: id=%s, address=%s, vendor=%s, model=%s\n", dev.DeviceID, dev.Address, dev.Vendor, dev.Model)
-				// 自动注册到 devMap，DeviceMeta 结构体赋值
-				devMap[dev.DeviceID] = types.DeviceConfigWithMeta{
-					DeviceMeta: types.DeviceMeta{
-						ID:           dev.DeviceID,
-						Name:         dev.Model,
-						Protocol:     "bacnet",
-						ProtocolName: "auto_discovered",
-						IP:           dev.Address,
-					},
-					Config: map[string]interface{}{
-						"object_device": dev.DeviceID,
-						"ip":            dev.Address,
-					},
-				}
-				// 可选：自动启动采集 goroutine
-				// go StartPolling(devMap[dev.DeviceID])
-			}
-		}
+	// BACnet 自动发现：单个发现服务广播 Who-Is 并收集 I-Am，发现的设备注册到 devMap
+	if cfg, err := config.LoadConfig("configs/config.yaml"); err == nil && cfg.BacnetDiscovery.Enable {
+		discoverBacnetDevices(cfg.BacnetDiscovery, protoConf, devMap)
+	}
+
+	// SNMP Trap/Inform 接收：设备告警即时上报，不等下一次轮询
+	if cfg, err := config.LoadConfig("configs/config.yaml"); err == nil && cfg.SnmpTrap.Enable {
+		startSnmpTrapReceiver(cfg.SnmpTrap, re, uplinkMgr)
 	}
 
 	// 7. 采集主流程：每个设备独立采集周期并发采集
@@ -322,7 +466,7 @@ func main() {
 				devConf.Config[k] = v
 			}
 		}
-		client, err := getOrCreateClient(protocol, devConf.Config)
+		client, err := getOrCreateClient(set.DeviceID, protocol, devConf.Config)
 		if err != nil {
 			fmt.Printf("[ERROR] 设备 %s 协议初始化失败: %v\n", set.DeviceID, err)
 			continue
@@ -339,6 +483,29 @@ func main() {
 			}
 			m.SetSlave(slaveId)
 		}
+		// 共享客户端的设备各自注入设备级配置（如从站的块读取规划参数）
+		if dc, ok := client.(protocols.DeviceConfigurable); ok {
+			if err := dc.ConfigureDevice(set.DeviceID, devConf.Config); err != nil {
+				fmt.Printf("[ERROR] 设备 %s 配置无效: %v\n", set.DeviceID, err)
+				continue
+			}
+		}
+		// 注入点位配置（格式、类型等），供驱动按 Format 编解码
+		if pc, ok := client.(protocols.PointConfigurable); ok {
+			var allPoints []types.PointMapping
+			for _, funcGroup := range set.Functions {
+				allPoints = append(allPoints, funcGroup.Points...)
+			}
+			pc.SetPointConfigs(set.DeviceID, toPointConfig(allPoints))
+		}
+		registerRunningDevice(set, devConf, client)
+		// 支持主动上报的驱动（如 BACnet COV），两次轮询之间的变化立即处理并上报
+		if ps, ok := client.(protocols.PointSubscriber); ok {
+			set := set
+			ps.SetValueHandler(set.DeviceID, func(values []protocols.PointValue) {
+				pushPointValues(set, values, re, uplinkMgr)
+			})
+		}
 		interval := 5 * time.Second
 		if v, ok := devConf.Config["interval"]; ok {
 			switch vv := v.(type) {
@@ -387,95 +554,18 @@ func main() {
 					for _, v := range values {
 						for _, p := range funcGroup.Points {
 							if v.PointID == p.Address || v.PointID == p.Name {
-								val := v.Value
-								// 自动兼容驱动返回 [uint16,uint16] 的 float/double 点位
-								if arr, ok := val.([]uint16); ok && len(arr) == 2 && strings.HasPrefix(strings.ToUpper(p.Format), "FLOAT") {
-									b := make([]byte, 4)
-									binary.BigEndian.PutUint16(b[0:2], arr[0])
-									binary.BigEndian.PutUint16(b[2:4], arr[1])
-									val = b
-								}
-								if arr, ok := val.([]uint16); ok && len(arr) == 4 && strings.HasPrefix(strings.ToUpper(p.Format), "DOUBLE") {
-									b := make([]byte, 8)
-									binary.BigEndian.PutUint16(b[0:2], arr[0])
-									binary.BigEndian.PutUint16(b[2:4], arr[1])
-									binary.BigEndian.PutUint16(b[4:6], arr[2])
-									binary.BigEndian.PutUint16(b[6:8], arr[3])
-									val = b
-								}
-								// 兼容驱动直接返回 uint32 且 format 为 float 的情况
-								if u32, ok := val.(uint32); ok && strings.HasPrefix(strings.ToUpper(p.Format), "FLOAT") {
-									b := make([]byte, 4)
-									binary.BigEndian.PutUint32(b, u32)
-									val = b
-								}
-								// 新增：兼容 format 为 float 且只收到单个 uint16 的情况，自动补齐为4字节 float32
-								if strings.HasPrefix(strings.ToUpper(p.Format), "FLOAT") {
-									switch vv := val.(type) {
-									case uint16:
-										b := make([]byte, 4)
-										binary.BigEndian.PutUint16(b[0:2], vv)
-										val = b
-									case []uint16:
-										if len(vv) == 1 {
-											b := make([]byte, 4)
-											binary.BigEndian.PutUint16(b[0:2], vv[0])
-											val = b
-										}
-									}
-								}
-								// 使用 Format 字段进行格式化
-								if p.Format != "" {
-									val2, err := utils.ParseAndCastFormat(p.Format, val)
-									if err == nil {
-										val = val2
-									}
-								}
-								// 使用 Transform 表达式
-								if p.Transform != "" {
-									val, err := parseTransform(p.Transform, val)
-									if err != nil {
-
-										fmt.Printf("[WARN] 设备 %s 点位 %s 转换失败: %v\n", set.DeviceID, p.Name, err)
-									} else {
-										// 如果转换结果是字符串，尝试转换为 float
-										if strVal, ok := val.(string); ok {
-											if f, err := strconv.ParseFloat(strVal, 64); err == nil {
-												val = f
-											}
-										}
-									}
-
-								}
-								// 根据 Type 进行类型转换
-								if strings.ToLower(p.Type) == "float" {
-									switch vv := val.(type) {
-									case float32, float64:
-										val = math.Round(reflect.ValueOf(vv).Convert(reflect.TypeOf(float64(0))).Float()*100) / 100
-									case int, int32, int64, uint16, uint32, uint64:
-										valf := reflect.ValueOf(vv).Convert(reflect.TypeOf(float64(0))).Float()
-										val = math.Round(valf*100) / 100
-									case string:
-										if f, err := strconv.ParseFloat(vv, 64); err == nil {
-											val = math.Round(f*100) / 100
-										}
-									}
-								}
-								if strings.ToLower(p.Type) == "int" {
-									switch vv := val.(type) {
-									case float32, float64:
-										val = int(math.Round(reflect.ValueOf(vv).Convert(reflect.TypeOf(float64(0))).Float()))
-									case string:
-										if f, err := strconv.ParseFloat(vv, 64); err == nil {
-											val = int(math.Round(f))
-										}
-									}
-								}
+								val := convertPointValue(set.DeviceID, p, v.Value)
 								allPointValues[p.Name] = val
 								// 日志输出也用最终val，保证与上报一致
 								fmt.Printf("[%s] %s = %v\n", set.DeviceID, v.PointID, val)
 								break
 							}
+							if row, ok := walkRowName(p, v.PointID); ok {
+								val := convertPointValue(set.DeviceID, p, v.Value)
+								allPointValues[row] = val
+								fmt.Printf("[%s] %s = %v\n", set.DeviceID, row, val)
+								break
+							}
 						}
 					}
 					// 先推进边缘规则引擎，聚合窗口和报警状态
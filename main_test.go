@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"sensor-edge/protocols"
+	"sensor-edge/types"
+)
+
+// TestHTTPClientPerDevice 只配置完整 url 的 HTTP 设备各自独立实例，相同 url 的设备也不共用（认证、TLS 可能不同）
+func TestHTTPClientPerDevice(t *testing.T) {
+	t.Cleanup(func() { clientCache = make(map[ClientKey]protocols.Protocol) })
+	a, err := getOrCreateClient("dev_a", "http", map[string]interface{}{"url": "https://a.example.com/status"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := getOrCreateClient("dev_b", "http", map[string]interface{}{"url": "https://b.example.com/status"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := getOrCreateClient("dev_c", "http", map[string]interface{}{
+		"url": "https://a.example.com/status", "auth": map[string]interface{}{"type": "bearer", "token": "t"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a == b || a == c {
+		t.Error("devices with different url or auth share one client")
+	}
+	again, _ := getOrCreateClient("dev_a", "http", map[string]interface{}{"url": "https://a.example.com/status"})
+	if again != a {
+		t.Error("same device should reuse its client")
+	}
+}
+
+// fakeCommander 记录释放请求的可命令客户端
+type fakeCommander struct {
+	protocols.Protocol
+	slots      []interface{}
+	relinquish string
+}
+
+func (f *fakeCommander) ReadPriorityArray(point string) ([]interface{}, error) {
+	return f.slots, nil
+}
+
+func (f *fakeCommander) Relinquish(point string, priority int) error {
+	f.relinquish = fmt.Sprint(point, "@", priority)
+	return nil
+}
+
+func TestDebugPriorityArray(t *testing.T) {
+	client := &fakeCommander{slots: make([]interface{}, 16)}
+	client.slots[7], client.slots[15] = 21.5, 18.0
+	registerRunningDevice(types.DevicePointSetV2{DeviceID: "ahu", Functions: []types.FunctionPointGroup{
+		{Function: "analogValue", Points: []types.PointMapping{{Name: "setpoint", Address: "analogValue:1"}}},
+	}}, types.DeviceConfigWithMeta{}, client)
+	t.Cleanup(func() { delete(runningDevices, "ahu") })
+
+	w := httptest.NewRecorder()
+	handlePriorityArray(w, httptest.NewRequest(http.MethodGet, "/debug/priority_array?device_id=ahu&point=setpoint", nil))
+	var report priorityArrayReport
+	if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.ActivePriority != 8 || report.PriorityArray[7] != 21.5 || report.PriorityArray[0] != nil {
+		t.Errorf("priority array = %+v", report)
+	}
+
+	w = httptest.NewRecorder()
+	handleRelinquish(w, httptest.NewRequest(http.MethodPost, "/debug/relinquish?device_id=ahu&point=setpoint&priority=8", nil))
+	if w.Code != http.StatusNoContent || client.relinquish != "analogValue:1@8" {
+		t.Errorf("relinquish: %d %s", w.Code, client.relinquish)
+	}
+	for _, url := range []string{"?device_id=ahu&point=missing", "?device_id=ahu&point=setpoint&priority=17", "?device_id=none&point=setpoint"} {
+		w = httptest.NewRecorder()
+		handleRelinquish(w, httptest.NewRequest(http.MethodPost, "/debug/relinquish"+url, nil))
+		if w.Code < 400 {
+			t.Errorf("%s: expect error, got %d", url, w.Code)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package bacnet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// BVLC（Annex J）
+const (
+	bvlcType              = 0x81
+	bvlcForwardedNPDU     = 0x04
+	bvlcOriginalUnicast   = 0x0A
+	bvlcOriginalBroadcast = 0x0B
+)
+
+// APDU 类型
+const (
+	pduConfirmedRequest   = 0x00
+	pduUnconfirmedRequest = 0x10
+	pduSimpleAck          = 0x20
+	pduComplexAck         = 0x30
+	pduSegmentAck         = 0x40
+	pduError              = 0x50
+	pduReject             = 0x60
+	pduAbort              = 0x70
+)
+
+// 确认服务
+const (
+	serviceConfirmedCOVNotification = 1
+	serviceSubscribeCOV             = 5
+	serviceReadProperty             = 12
+	serviceReadPropertyMultiple     = 14
+	serviceWriteProperty            = 15
+)
+
+// 非确认服务
+const (
+	serviceIAm                        = 0
+	serviceUnconfirmedCOVNotification = 2
+	serviceWhoIs                      = 8
+)
+
+// maxAPDU 本端可接收的最大 APDU 长度（BACnet/IP 上限）
+const maxAPDU = 1476
+
+// arrayAll 不指定数组下标
+const arrayAll = ^uint32(0)
+
+// encodeFrame 组装 BVLC + NPDU + APDU，expectReply 对应 NPDU 控制字的 data-expecting-reply 位
+func encodeFrame(apdu []byte, broadcast, expectReply bool) []byte {
+	function := byte(bvlcOriginalUnicast)
+	if broadcast {
+		function = bvlcOriginalBroadcast
+	}
+	control := byte(0x00)
+	if expectReply {
+		control = 0x04
+	}
+	frame := make([]byte, 0, 6+len(apdu))
+	frame = append(frame, bvlcType, function, 0, 0, 0x01, control)
+	frame = append(frame, apdu...)
+	binary.BigEndian.PutUint16(frame[2:], uint16(len(frame)))
+	return frame
+}
+
+// decodeFrame 拆出 APDU；Forwarded-NPDU 时 origin 为 BBMD 转发前的原始地址。
+// 网络层报文（如 Who-Is-Router-To-Network）返回空 APDU
+func decodeFrame(data []byte) (apdu []byte, origin *net.UDPAddr, err error) {
+	if len(data) < 4 || data[0] != bvlcType {
+		return nil, nil, fmt.Errorf("bacnet: not a BACnet/IP frame")
+	}
+	if l := int(binary.BigEndian.Uint16(data[2:])); l != len(data) {
+		return nil, nil, fmt.Errorf("bacnet: BVLC length %d mismatch %d", l, len(data))
+	}
+	npdu := data[4:]
+	switch data[1] {
+	case bvlcOriginalUnicast, bvlcOriginalBroadcast:
+	case bvlcForwardedNPDU:
+		if len(npdu) < 6 {
+			return nil, nil, errShortData
+		}
+		origin = &net.UDPAddr{IP: net.IP(append([]byte(nil), npdu[:4]...)), Port: int(binary.BigEndian.Uint16(npdu[4:]))}
+		npdu = npdu[6:]
+	default:
+		return nil, nil, nil
+	}
+	if len(npdu) < 2 || npdu[0] != 0x01 {
+		return nil, nil, fmt.Errorf("bacnet: unsupported NPDU")
+	}
+	control := npdu[1]
+	pos := 2
+	skipAddress := func() error { // NET(2) + LEN(1) + ADR
+		if len(npdu) < pos+3 {
+			return errShortData
+		}
+		pos += 3 + int(npdu[pos+2])
+		return nil
+	}
+	if control&0x20 != 0 { // DNET
+		if err := skipAddress(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if control&0x08 != 0 { // SNET
+		if err := skipAddress(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if control&0x20 != 0 { // hop count
+		pos++
+	}
+	if len(npdu) < pos {
+		return nil, nil, errShortData
+	}
+	if control&0x80 != 0 {
+		return nil, origin, nil
+	}
+	return npdu[pos:], origin, nil
+}
+
+// apdu 解码后的 APDU 头，data 为服务参数（Error PDU 为错误类别/代码）
+type apdu struct {
+	pduType     byte
+	segmented   bool
+	moreFollows bool
+	invokeID    byte
+	service     byte
+	reason      byte // Reject/Abort 原因
+	server      bool // Abort 是否由服务端发起
+	maxResponse int  // 确认请求中对端可接收的最大 APDU
+	data        []byte
+}
+
+// maxAPDUSizes 确认请求中 max-APDU-length-accepted 编码对应的字节数
+var maxAPDUSizes = []int{50, 128, 206, 480, 1024, 1476}
+
+func decodeAPDU(b []byte) (apdu, error) {
+	if len(b) < 2 {
+		return apdu{}, errShortData
+	}
+	a := apdu{pduType: b[0] & 0xF0}
+	need := func(n int) error {
+		if len(b) < n {
+			return errShortData
+		}
+		return nil
+	}
+	switch a.pduType {
+	case pduConfirmedRequest:
+		a.segmented = b[0]&0x08 != 0
+		a.moreFollows = b[0]&0x04 != 0
+		pos := 3
+		if a.segmented {
+			pos += 2
+		}
+		if err := need(pos + 1); err != nil {
+			return a, err
+		}
+		a.maxResponse = maxAPDU
+		if i := int(b[1] & 0x0F); i < len(maxAPDUSizes) {
+			a.maxResponse = maxAPDUSizes[i]
+		}
+		a.invokeID = b[2]
+		a.service = b[pos]
+		a.data = b[pos+1:]
+	case pduUnconfirmedRequest:
+		a.service = b[1]
+		a.data = b[2:]
+	case pduSimpleAck, pduError:
+		if err := need(3); err != nil {
+			return a, err
+		}
+		a.invokeID, a.service, a.data = b[1], b[2], b[3:]
+	case pduComplexAck:
+		a.segmented = b[0]&0x08 != 0
+		a.moreFollows = b[0]&0x04 != 0
+		pos := 2
+		if a.segmented {
+			pos += 2
+		}
+		if err := need(pos + 1); err != nil {
+			return a, err
+		}
+		a.invokeID = b[1]
+		a.service = b[pos]
+		a.data = b[pos+1:]
+	case pduReject, pduAbort:
+		if err := need(3); err != nil {
+			return a, err
+		}
+		a.server = b[0]&0x01 != 0
+		a.invokeID, a.reason = b[1], b[2]
+	case pduSegmentAck:
+		a.server = b[0]&0x01 != 0
+		a.invokeID = b[1]
+	default:
+		return a, fmt.Errorf("bacnet: unknown PDU type 0x%02x", b[0])
+	}
+	return a, nil
+}
+
+// encodeConfirmedRequest 确认请求头：不接受分段应答，可接收 1476 字节的 APDU
+func encodeConfirmedRequest(invokeID, service byte, params []byte) []byte {
+	return append([]byte{pduConfirmedRequest, 0x05, invokeID, service}, params...)
+}
+
+func encodeUnconfirmedRequest(service byte, params []byte) []byte {
+	return append([]byte{pduUnconfirmedRequest, service}, params...)
+}
+
+func encodeSimpleAck(invokeID, service byte) []byte {
+	return []byte{pduSimpleAck, invokeID, service}
+}
+
+func encodeComplexAck(invokeID, service byte, params []byte) []byte {
+	return append([]byte{pduComplexAck, invokeID, service}, params...)
+}
+
+func encodeErrorPDU(invokeID, service byte, class, code uint32) []byte {
+	b := []byte{pduError, invokeID, service}
+	b = appendApplication(b, TypeEnumerated, unsignedBytes(uint64(class)))
+	return appendApplication(b, TypeEnumerated, unsignedBytes(uint64(code)))
+}
+
+func encodeReject(invokeID, reason byte) []byte {
+	return []byte{pduReject, invokeID, reason}
+}
+
+func encodeAbort(invokeID, reason byte, server bool) []byte {
+	t := byte(pduAbort)
+	if server {
+		t |= 0x01
+	}
+	return []byte{t, invokeID, reason}
+}
+
+// decodeErrorPDU 解析 Error PDU 中的错误类别和代码，部分服务会额外包一层上下文标签
+func decodeErrorPDU(data []byte) *Error {
+	if t, n, err := decodeTag(data); err == nil && t.opening {
+		data = data[n:]
+	}
+	e := &Error{}
+	if pv, n, err := decodeApplicationValue(data); err == nil {
+		e.Class = uint32(toUint(pv.Value))
+		if pv, _, err := decodeApplicationValue(data[n:]); err == nil {
+			e.Code = uint32(toUint(pv.Value))
+		}
+	}
+	return e
+}
+
+// propertyRef 读写请求中的对象属性引用
+type propertyRef struct {
+	Object   ObjectID
+	Property uint32
+	Index    uint32 // arrayAll 表示不指定下标
+}
+
+func appendPropertyRef(buf []byte, ref propertyRef) []byte {
+	buf = appendContextObjectID(buf, 0, ref.Object)
+	buf = appendContextUnsigned(buf, 1, uint64(ref.Property))
+	if ref.Index != arrayAll {
+		buf = appendContextUnsigned(buf, 2, uint64(ref.Index))
+	}
+	return buf
+}
+
+// decodePropertyRef 解析 [0]对象 [1]属性 [2]下标（可选），返回消耗的字节数
+func decodePropertyRef(data []byte) (propertyRef, int, error) {
+	ref := propertyRef{Index: arrayAll}
+	t, n, err := decodeTag(data)
+	if err != nil || !t.isContext(0) || len(data) < n+t.length {
+		return ref, 0, fmt.Errorf("bacnet: missing object identifier")
+	}
+	if ref.Object, err = decodeObjectID(data[n : n+t.length]); err != nil {
+		return ref, 0, err
+	}
+	pos := n + t.length
+	t, n, err = decodeTag(data[pos:])
+	if err != nil || !t.isContext(1) || len(data) < pos+n+t.length {
+		return ref, 0, fmt.Errorf("bacnet: missing property identifier")
+	}
+	ref.Property = uint32(decodeUnsigned(data[pos+n : pos+n+t.length]))
+	pos += n + t.length
+	if t, n, err := decodeTag(data[pos:]); err == nil && t.isContext(2) && len(data) >= pos+n+t.length {
+		ref.Index = uint32(decodeUnsigned(data[pos+n : pos+n+t.length]))
+		pos += n + t.length
+	}
+	return ref, pos, nil
+}
+
+func encodeReadProperty(ref propertyRef) []byte {
+	return appendPropertyRef(nil, ref)
+}
+
+// decodeReadPropertyAck 解析 ReadProperty-ACK，返回属性值列表（标量属性只有一个值）
+func decodeReadPropertyAck(data []byte) (propertyRef, []PropertyValue, error) {
+	ref, pos, err := decodePropertyRef(data)
+	if err != nil {
+		return ref, nil, err
+	}
+	content, _, err := splitEnclosed(data[pos:], 3)
+	if err != nil {
+		return ref, nil, err
+	}
+	values, err := decodeValues(content)
+	return ref, values, err
+}
+
+// encodeWriteProperty value 为已编码的应用标签值，priority 为 0 时不携带优先级
+func encodeWriteProperty(ref propertyRef, value []byte, priority int) []byte {
+	buf := appendPropertyRef(nil, ref)
+	buf = encodeOpeningTag(buf, 3)
+	buf = append(buf, value...)
+	buf = encodeClosingTag(buf, 3)
+	if priority > 0 {
+		buf = appendContextUnsigned(buf, 4, uint64(priority))
+	}
+	return buf
+}
+
+func toUint(v interface{}) uint64 {
+	switch n := v.(type) {
+	case uint64:
+		return n
+	case int:
+		return uint64(n)
+	}
+	return 0
+}
@@ -3,29 +3,42 @@ package bacnet
 import (
 	"errors"
 	"fmt"
+	"log"
 	"net"
-	"reflect"
 	"sensor-edge/protocols"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
-
-	"github.com/Knetic/govaluate"
 )
 
-// BacnetClient 是 BACnet 协议的客户端实现（模拟器/占位实现）
+// BacnetClient 是 BACnet/IP 协议的客户端实现
 type BacnetClient struct {
-	deviceID      string
-	connected     bool
-	lock          sync.Mutex
-	points        map[string]BacnetPoint // 支持点位物模型
-	idToName      map[string]string      // id -> name
-	addressToName map[string]string      // address -> name
+	deviceID       string
+	connected      bool
+	lock           sync.Mutex
+	peer           *net.UDPAddr           // 设备地址
+	tr             *transport             // BACnet/IP 收发
+	timeout        time.Duration          // 单次请求超时
+	retries        int                    // 超时重发次数
+	maxAPDU        int                    // 设备可接收的最大 APDU，0 表示尚未获取
+	rpmUnsupported bool                   // 设备不支持 ReadPropertyMultiple，逐点读取
+	points         map[string]BacnetPoint // 支持点位物模型
+	idToName       map[string]string      // id -> name
+	addressToName  map[string]string      // address -> name
+	// COV 订阅，covMu 保护以下字段（通知在收包协程中处理，不持有 lock）
+	covMu         sync.Mutex
+	covSubs       map[string]*covSubscription // 点位名 -> 订阅
+	covLifetime   time.Duration               // 订阅生命周期
+	covConfirmed  bool                        // 请求确认型通知
+	nextProcessID uint32
+	covStop       chan struct{}
+	covWake       chan struct{}
+	covPush       chan []protocols.PointValue // 待回调的 COV 通知值，由 pushLoop 消费
+	valueHandlers map[string]func([]protocols.PointValue)
 	// 扩展能力
-	covSubs       map[string]bool // COV订阅状态
-	discovered    bool            // 设备发现标志
-	priorityWrite map[string]int  // 点位优先级写入
-	retryCount    map[string]int  // 点位错误重试计数
-	offline       bool            // 设备离线标志
+	retryCount map[string]int // 点位错误重试计数
+	offline    bool           // 设备离线标志
 }
 
 type BacnetPoint struct {
@@ -39,14 +52,15 @@ type BacnetPoint struct {
 	Type              string            // 点位数据类型
 	Format            string            // 点位格式
 	Unit              string            // 点位单位
-	Value             interface{}       // 点位当前值
 	Property          string            // 属性名，如 presentValue
 	PropertyValueType PropertyValueType // 属性值类型，参考 type.go
 	Writable          bool              // 是否可写
 	Transform         string            // 变换表达式
+	COV               bool              // 订阅 COV，变化时主动上报
+	Priority          int               // 写入优先级 1-16，0 表示不带优先级
 }
 
-// 初始化链接信息
+// 初始化链接信息，ip 必须配置，port 默认 47808
 func (c *BacnetClient) Init(config map[string]interface{}) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
@@ -56,347 +70,482 @@ func (c *BacnetClient) Init(config map[string]interface{}) error {
 	} else {
 		c.deviceID = fmt.Sprintf("%v", config["device_id"])
 	}
-	c.connected = true
 	c.points = make(map[string]BacnetPoint)
 	c.idToName = make(map[string]string)
 	c.addressToName = make(map[string]string)
 	if pts, ok := config["points"].([]interface{}); ok {
 		for _, p := range pts {
 			if m, ok := p.(map[string]interface{}); ok {
-				pt := BacnetPoint{}
-				if v, ok := m["id"]; ok {
-					pt.ID = fmt.Sprintf("%v", v)
-				} else if c.deviceID != "" && m["name"] != nil {
-					pt.ID = fmt.Sprintf("%s.%s", c.deviceID, m["name"])
-				}
-				if v, ok := m["object_type"]; ok {
-					pt.ObjectType = fmt.Sprintf("%v", v)
-				}
-				if v, ok := m["instance"]; ok {
-					if i, ok2 := v.(int); ok2 {
-						pt.Instance = i
-					} else if f, ok2 := v.(float64); ok2 {
-						pt.Instance = int(f)
-					} else {
-						pt.Instance = 0
-					}
-				}
-				if v, ok := m["description"]; ok {
-					pt.Description = fmt.Sprintf("%v", v)
-				}
-				if v, ok := m["access"]; ok {
-					pt.Access = fmt.Sprintf("%v", v)
-				}
-				if v, ok := m["name"]; ok {
-					pt.Name = fmt.Sprintf("%v", v)
-				}
-				if v, ok := m["address"]; ok {
-					pt.Address = fmt.Sprintf("%v", v)
-				}
-				if v, ok := m["type"]; ok {
-					pt.Type = fmt.Sprintf("%v", v)
-				}
-				if v, ok := m["format"]; ok {
-					pt.Format = fmt.Sprintf("%v", v)
-				}
-				if v, ok := m["unit"]; ok {
-					pt.Unit = fmt.Sprintf("%v", v)
-				}
-				if v, ok := m["init_value"]; ok {
-					pt.Value = v
-				}
-				if v, ok := m["property"]; ok {
-					pt.Property = fmt.Sprintf("%v", v)
-				}
-				if v, ok := m["transform"]; ok {
-					pt.Transform = fmt.Sprintf("%v", v)
-				}
-				pt.Writable = false
-				if w, ok := m["writable"].(bool); ok {
-					pt.Writable = w
-				}
-				if pvt, ok := m["property_value_type"].(string); ok {
-					switch pvt {
-					case "REAL":
-						pt.PropertyValueType = TypeReal
-					case "INTEGER":
-						pt.PropertyValueType = TypeSignedInt
-					case "BOOLEAN":
-						pt.PropertyValueType = TypeBoolean
-					case "ENUMERATED":
-						pt.PropertyValueType = TypeEnumerated
-					case "CHARACTERSTRING":
-						pt.PropertyValueType = TypeCharacterString
-					case "OCTETSTRING":
-						pt.PropertyValueType = TypeOctetString
-					case "BITSTRING":
-						pt.PropertyValueType = TypeBitString
-					case "OBJECTID":
-						pt.PropertyValueType = TypeObjectID
-					default:
-						pt.PropertyValueType = TypeNull
-					}
-				}
-				// 自动从 address 解析 object_type/instance
-				if pt.ObjectType == "" || pt.Instance == 0 {
-					if segs := parseAddressFields(pt.Address); segs != nil {
-						if pt.ObjectType == "" {
-							if s, ok := segs[0].(string); ok {
-								pt.ObjectType = s
-							}
-						}
-						if pt.Instance == 0 {
-							if i, ok := segs[1].(int); ok {
-								pt.Instance = i
-							} else if f, ok := segs[1].(float64); ok {
-								pt.Instance = int(f)
-							}
-						}
-					}
-				}
-				c.points[pt.Name] = pt
-				if pt.ID != "" {
-					c.idToName[pt.ID] = pt.Name
-				}
-				if pt.Address != "" {
-					c.addressToName[pt.Address] = pt.Name
-				}
+				c.addPoint(c.parsePoint(m))
 			}
 		}
 	}
+	ip, _ := config["ip"].(string)
+	if ip == "" {
+		return errors.New("bacnet: ip is required")
+	}
+	port := configInt(config["port"], 47808)
+	peer, err := net.ResolveUDPAddr("udp4", net.JoinHostPort(ip, strconv.Itoa(port)))
+	if err != nil {
+		return fmt.Errorf("bacnet: invalid address %s:%d: %v", ip, port, err)
+	}
+	c.peer = peer
+	c.timeout = time.Duration(configInt(config["timeout"], 3000)) * time.Millisecond
+	c.retries = configInt(config["retries"], 2)
+	c.maxAPDU = configInt(config["max_apdu"], 0)
+	c.covLifetime = time.Duration(configInt(config["cov_lifetime"], 300)) * time.Second
+	c.covConfirmed, _ = config["cov_confirmed"].(bool)
+	if err := c.open(); err != nil {
+		return err
+	}
+	c.syncCOV()
+	c.connected = true
+	return nil
+}
+
+// open 创建本地 UDP 端点（随机端口），设备应答和 COV 通知发到该端口
+func (c *BacnetClient) open() error {
+	tr, err := newTransport(":0", c.timeout, c.retries)
+	if err != nil {
+		return fmt.Errorf("bacnet: open udp failed: %v", err)
+	}
+	tr.setHandler(func(src *net.UDPAddr, a apdu) { c.handleRequest(tr, src, a) })
+	c.covMu.Lock()
+	c.tr = tr
+	c.covMu.Unlock()
 	return nil
 }
 
-// parseAddressFields 解析 address 字段，返回 [object_type, instance]，如 analogInput:0
+// parsePoint 解析一个点位配置（points.yaml 或 Init 配置中的 points 项）
+func (c *BacnetClient) parsePoint(m map[string]interface{}) BacnetPoint {
+	pt := BacnetPoint{}
+	if v, ok := m["id"]; ok {
+		pt.ID = fmt.Sprintf("%v", v)
+	} else if c.deviceID != "" && m["name"] != nil {
+		pt.ID = fmt.Sprintf("%s.%s", c.deviceID, m["name"])
+	}
+	if v, ok := m["object_type"]; ok {
+		pt.ObjectType = fmt.Sprintf("%v", v)
+	}
+	if v, ok := m["instance"]; ok {
+		pt.Instance = configInt(v, 0)
+	}
+	if v, ok := m["description"]; ok {
+		pt.Description = fmt.Sprintf("%v", v)
+	}
+	if v, ok := m["access"]; ok {
+		pt.Access = fmt.Sprintf("%v", v)
+	}
+	if v, ok := m["name"]; ok {
+		pt.Name = fmt.Sprintf("%v", v)
+	}
+	if v, ok := m["address"]; ok {
+		pt.Address = fmt.Sprintf("%v", v)
+	}
+	if v, ok := m["type"]; ok {
+		pt.Type = fmt.Sprintf("%v", v)
+	}
+	if v, ok := m["format"]; ok {
+		pt.Format = fmt.Sprintf("%v", v)
+	}
+	if v, ok := m["unit"]; ok {
+		pt.Unit = fmt.Sprintf("%v", v)
+	}
+	if v, ok := m["property"]; ok {
+		pt.Property = fmt.Sprintf("%v", v)
+	}
+	if v, ok := m["transform"]; ok {
+		pt.Transform = fmt.Sprintf("%v", v)
+	}
+	pt.Writable = false
+	if w, ok := m["writable"].(bool); ok {
+		pt.Writable = w
+	}
+	pt.COV, _ = m["cov"].(bool)
+	if v, ok := m["priority"]; ok {
+		pt.Priority = configInt(v, 0)
+	}
+	if pvt, ok := m["property_value_type"].(string); ok {
+		switch pvt {
+		case "REAL":
+			pt.PropertyValueType = TypeReal
+		case "DOUBLE":
+			pt.PropertyValueType = TypeDouble
+		case "INTEGER":
+			pt.PropertyValueType = TypeSignedInt
+		case "UNSIGNED":
+			pt.PropertyValueType = TypeUnsignedInt
+		case "BOOLEAN":
+			pt.PropertyValueType = TypeBoolean
+		case "ENUMERATED":
+			pt.PropertyValueType = TypeEnumerated
+		case "CHARACTERSTRING":
+			pt.PropertyValueType = TypeCharacterString
+		case "OCTETSTRING":
+			pt.PropertyValueType = TypeOctetString
+		case "BITSTRING":
+			pt.PropertyValueType = TypeBitString
+		case "DATE":
+			pt.PropertyValueType = TypeDate
+		case "TIME":
+			pt.PropertyValueType = TypeTime
+		case "OBJECTID":
+			pt.PropertyValueType = TypeObjectID
+		default:
+			pt.PropertyValueType = TypeNull
+		}
+	}
+	// 自动从 address 解析 object_type/instance/property
+	if segs := parseAddressFields(pt.Address); segs != nil {
+		if pt.ObjectType == "" {
+			pt.ObjectType = segs[0].(string)
+		}
+		if pt.Instance == 0 {
+			pt.Instance = segs[1].(int)
+		}
+		if pt.Property == "" && len(segs) > 2 {
+			pt.Property = segs[2].(string)
+		}
+	}
+	return pt
+}
+
+func (c *BacnetClient) addPoint(pt BacnetPoint) {
+	c.points[pt.Name] = pt
+	if pt.ID != "" {
+		c.idToName[pt.ID] = pt.Name
+	}
+	if pt.Address != "" {
+		c.addressToName[pt.Address] = pt.Name
+	}
+}
+
+// SetPointConfigs 注入 points.yaml 中的点位，BACnet 专有字段（object_type、instance、property、
+// property_value_type、writable 等）来自 PointConfig.Options
+func (c *BacnetClient) SetPointConfigs(deviceID string, points []protocols.PointConfig) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	for _, p := range points {
+		m := make(map[string]interface{}, len(p.Options)+6)
+		for k, v := range p.Options {
+			m[k] = v
+		}
+		m["name"] = p.PointID
+		m["address"] = p.Address
+		m["type"] = p.Type
+		m["unit"] = p.Unit
+		m["format"] = p.Format
+		m["transform"] = p.Transform
+		c.addPoint(c.parsePoint(m))
+	}
+	c.syncCOV()
+}
+
+// parseAddressFields 解析 address 字段，返回 [object_type, instance] 或 [object_type, instance, property]，
+// 如 analogInput:0、analogValue:1:presentValue
 func parseAddressFields(addr string) []interface{} {
-	var res []interface{}
-	if addr == "" {
+	segs := strings.Split(addr, ":")
+	if len(segs) < 2 || len(segs) > 3 || segs[0] == "" {
 		return nil
 	}
-	var objType string
-	var inst int
-	_, err := fmt.Sscanf(addr, "%[^:]:%d", &objType, &inst)
-	if err == nil {
-		res = append(res, objType)
-		res = append(res, inst)
-		return res
+	inst, err := strconv.Atoi(strings.TrimSpace(segs[1]))
+	if err != nil || inst < 0 {
+		return nil
+	}
+	res := []interface{}{strings.TrimSpace(segs[0]), inst}
+	if len(segs) == 3 {
+		res = append(res, strings.TrimSpace(segs[2]))
+	}
+	return res
+}
+
+// resolvePoint 按点位名、地址或 ID 查找点位
+func (c *BacnetClient) resolvePoint(key string) (BacnetPoint, bool) {
+	if pt, ok := c.points[key]; ok {
+		return pt, true
+	}
+	if name, ok := c.addressToName[key]; ok {
+		return c.points[name], true
+	}
+	if name, ok := c.idToName[key]; ok {
+		return c.points[name], true
+	}
+	return BacnetPoint{}, false
+}
+
+// propertyRef 点位对应的对象属性
+func (pt BacnetPoint) propertyRef() (propertyRef, error) {
+	objType, err := ParseObjectType(pt.ObjectType)
+	if err != nil {
+		return propertyRef{}, err
+	}
+	prop, err := ParseProperty(pt.Property)
+	if err != nil {
+		return propertyRef{}, err
+	}
+	return propertyRef{Object: ObjectID{Type: objType, Instance: uint32(pt.Instance)}, Property: prop, Index: arrayAll}, nil
+}
+
+// valueType 写入时使用的应用标签类型：未配置 property_value_type 时按对象类型推断 presentValue 的类型
+func (pt BacnetPoint) valueType(ref propertyRef) PropertyValueType {
+	if pt.PropertyValueType != TypeNull || ref.Property != PropPresentValue {
+		return pt.PropertyValueType
+	}
+	switch ref.Object.Type {
+	case AnalogInput, AnalogOutput, AnalogValue:
+		return TypeReal
+	case LargeAnalogValue:
+		return TypeDouble
+	case BinaryInput, BinaryOutput, BinaryValue:
+		return TypeEnumerated
+	case MultiStateInput, MultiStateOutput, MultiStateValue, PositiveIntegerValue, Accumulator:
+		return TypeUnsignedInt
+	case IntegerValue:
+		return TypeSignedInt
+	case CharacterstringValue:
+		return TypeCharacterString
+	}
+	return TypeNull
+}
+
+// readProperties 读取一组点位：设备支持时按 max-APDU 分组发送 ReadPropertyMultiple，否则逐点 ReadProperty。
+// 对端返回错误的点位质量为 bad:<错误类别>:<错误代码>；超时说明设备不可达，整批返回错误由上层重试/重连
+func (c *BacnetClient) readProperties(keys []string) ([]protocols.PointValue, error) {
+	now := time.Now().Unix()
+	result := make([]protocols.PointValue, len(keys))
+	var items []readItem
+	for i, key := range keys {
+		result[i] = protocols.PointValue{PointID: key, Quality: "bad", Timestamp: now}
+		pt, ok := c.resolvePoint(key)
+		if !ok {
+			continue
+		}
+		result[i].PointID = pt.Name
+		if v, ts, ok := c.covValue(pt.Name); ok {
+			result[i].Value, result[i].Quality, result[i].Timestamp = v, "good", ts.Unix()
+			continue
+		}
+		ref, err := pt.propertyRef()
+		if err != nil {
+			log.Printf("[BACnet] point %s: %v", pt.Name, err)
+			continue
+		}
+		items = append(items, readItem{index: i, point: pt, ref: ref})
+	}
+	if err := c.readItems(items, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// readItem 待读取的点位，index 为其在结果中的位置
+type readItem struct {
+	index int
+	point BacnetPoint
+	ref   propertyRef
+}
+
+// readSingle ReadProperty 读取一个点位，仅超时返回错误
+func (c *BacnetClient) readSingle(item readItem, result []protocols.PointValue) error {
+	_, values, err := c.readProperty(item.ref)
+	if err != nil {
+		if errors.Is(err, ErrTimeout) {
+			return err
+		}
+		log.Printf("[BACnet] read %s %s of %s failed: %v", item.ref.Object, PropertyName(item.ref.Property), c.deviceID, err)
+		result[item.index].Quality = errorQuality(err)
+		return nil
 	}
+	setResult(&result[item.index], item.point, values)
 	return nil
 }
 
+func setResult(pv *protocols.PointValue, pt BacnetPoint, values []PropertyValue) {
+	pv.Value = pointValue(pt, values)
+	pv.Quality = "good"
+	pv.Timestamp = time.Now().Unix()
+}
+
+// errorQuality 读取失败的质量码，BACnet 错误带上类别和代码（如 bad:object:unknown-object）
+func errorQuality(err error) string {
+	var be *Error
+	if errors.As(err, &be) {
+		return "bad:" + be.ClassName() + ":" + be.CodeName()
+	}
+	return "bad"
+}
+
+func (c *BacnetClient) readProperty(ref propertyRef) (propertyRef, []PropertyValue, error) {
+	data, err := c.tr.request(c.peer, serviceReadProperty, encodeReadProperty(ref))
+	if err != nil {
+		return ref, nil, err
+	}
+	return decodeReadPropertyAck(data)
+}
+
+// pointValue 取属性值：标量属性取唯一的值，数组/列表属性返回 []interface{}；bool 点位的枚举值转为 bool
+func pointValue(pt BacnetPoint, values []PropertyValue) interface{} {
+	if len(values) != 1 {
+		list := make([]interface{}, len(values))
+		for i, v := range values {
+			list[i] = v.Value
+		}
+		return list
+	}
+	v := values[0].Value
+	if strings.EqualFold(pt.Type, "bool") {
+		switch n := v.(type) {
+		case int:
+			return n != 0
+		case uint64:
+			return n != 0
+		}
+	}
+	return v
+}
+
 func (c *BacnetClient) Read(deviceID string) ([]protocols.PointValue, error) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	if !c.connected {
 		return nil, errors.New("bacnet: not connected")
 	}
-	var result []protocols.PointValue
-	for name, pt := range c.points {
-		val := pt.Value
-		if val == nil {
-			// 默认值
-			if pt.Type == "bool" {
-				val = false
-			} else {
-				val = 42.0
-			}
-		}
-		result = append(result, protocols.PointValue{
-			PointID:   name,
-			Value:     val,
-			Quality:   "good",
-			Timestamp: time.Now().Unix(),
-		})
+	names := make([]string, 0, len(c.points))
+	for name := range c.points {
+		names = append(names, name)
 	}
-	return result, nil
+	return c.readProperties(names)
 }
 
-// 采集时生成 BACnetReadRequest（此处仅打印，便于后续对接真实协议栈）
+// ReadBatch 读取一组点位的属性值，不在驱动内执行 transform，由采集主流程统一处理
 func (c *BacnetClient) ReadBatch(deviceID string, function string, points []string) ([]protocols.PointValue, error) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	if !c.connected {
 		return nil, errors.New("bacnet: not connected")
 	}
-	result := make([]protocols.PointValue, 0, len(points))
-	for _, key := range points {
-		pt, ok := c.points[key]
-		if !ok {
-			if name2, ok2 := c.addressToName[key]; ok2 {
-				pt = c.points[name2]
-			} else if name3, ok3 := c.idToName[key]; ok3 {
-				pt = c.points[name3]
-			} else {
-				result = append(result, protocols.PointValue{
-					PointID:   key,
-					Value:     nil,
-					Quality:   "bad",
-					Timestamp: time.Now().Unix(),
-				})
-				continue
-			}
-		}
-		// 采集请求结构体
-		req := BACnetReadRequest{
-			DeviceID:   c.deviceID,
-			ObjectType: pt.ObjectType,
-			Instance:   pt.Instance,
-			Property:   pt.Property,
-		}
-		fmt.Printf("[BACnet] ReadRequest: %+v\n", req)
-		val := pt.Value
-		if val == nil {
-			if pt.Type == "bool" {
-				val = false
-			} else {
-				val = 42.0
-			}
-		}
-		if pt.Transform != "" {
-			if v2, err := parseTransform(pt.Transform, val); err == nil {
-				val = v2
-			}
-		}
-		result = append(result, protocols.PointValue{
-			PointID:   pt.Name,
-			Value:     val,
-			Quality:   "good",
-			Timestamp: time.Now().Unix(),
-		})
-	}
-	return result, nil
+	return c.readProperties(points)
 }
 
+// Write 按点位配置的 priority 写入；value 为 nil 时释放该优先级
 func (c *BacnetClient) Write(point string, value interface{}) error {
+	return c.WritePriority(point, value, 0)
+}
+
+// Relinquish 释放点位在 priority 上的命令（写入 NULL），之后由更低优先级或 relinquishDefault 接管。
+// priority 为 0 时使用点位配置的 priority
+func (c *BacnetClient) Relinquish(point string, priority int) error {
+	return c.WritePriority(point, nil, priority)
+}
+
+// WritePriority 以指定优先级（1-16）写入可命令对象的 presentValue，priority 为 0 时使用点位配置的 priority
+func (c *BacnetClient) WritePriority(point string, value interface{}, priority int) error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	if !c.connected {
 		return errors.New("bacnet: not connected")
 	}
-	pt, ok := c.points[point]
+	pt, ok := c.resolvePoint(point)
 	if !ok {
-		if name2, ok2 := c.addressToName[point]; ok2 {
-			pt = c.points[name2]
-			point = name2
-		} else if name3, ok3 := c.idToName[point]; ok3 {
-			pt = c.points[name3]
-			point = name3
-		} else {
-			return fmt.Errorf("bacnet: point %s not found", point)
-		}
+		return fmt.Errorf("bacnet: point %s not found", point)
 	}
+	point = pt.Name
 	if !pt.Writable {
 		return fmt.Errorf("bacnet: point %s is not writable", point)
 	}
-	typeCheck := func(expect, actual reflect.Kind) error {
-		if actual != expect {
-			return fmt.Errorf("bacnet: point %s expect %s value, got %s", point, expect, actual)
-		}
-		return nil
+	if priority == 0 {
+		priority = pt.Priority
 	}
-	switch pt.PropertyValueType {
-	case TypeReal, TypeDouble:
-		if err := typeCheck(reflect.Float64, reflect.TypeOf(value).Kind()); err != nil {
-			return err
-		}
-	case TypeSignedInt, TypeEnumerated:
-		if err := typeCheck(reflect.Int, reflect.TypeOf(value).Kind()); err != nil {
-			return err
-		}
-	case TypeUnsignedInt:
-		if err := typeCheck(reflect.Uint64, reflect.TypeOf(value).Kind()); err != nil {
-			return err
-		}
-	case TypeBoolean:
-		if err := typeCheck(reflect.Bool, reflect.TypeOf(value).Kind()); err != nil {
-			return err
-		}
-	case TypeCharacterString, TypeDate, TypeTime:
-		if err := typeCheck(reflect.String, reflect.TypeOf(value).Kind()); err != nil {
-			return err
-		}
-	case TypeOctetString:
-		if reflect.TypeOf(value).Kind() != reflect.Slice || reflect.TypeOf(value).Elem().Kind() != reflect.Uint8 {
-			return fmt.Errorf("bacnet: point %s expect []byte value", point)
-		}
-	case TypeBitString:
-		if reflect.TypeOf(value).Kind() != reflect.Slice || reflect.TypeOf(value).Elem().Kind() != reflect.Bool {
-			return fmt.Errorf("bacnet: point %s expect []bool value", point)
-		}
-	case TypeObjectID:
-		if reflect.TypeOf(value) != reflect.TypeOf(ObjectID{}) {
-			return fmt.Errorf("bacnet: point %s expect ObjectID value", point)
-		}
+	if priority < 0 || priority > 16 {
+		return fmt.Errorf("bacnet: point %s: invalid priority %d", point, priority)
+	}
+	return c.writeProperty(pt, value, priority)
+}
+
+// writeProperty 编码并发送 WriteProperty，value 为 nil 时写入 NULL（释放优先级）。
+// 数值按属性值类型转换（如北向写入的 uint16、int64、float32 写到 REAL/ENUMERATED），类型不符时由编码报错
+func (c *BacnetClient) writeProperty(pt BacnetPoint, value interface{}, priority int) error {
+	ref, err := pt.propertyRef()
+	if err != nil {
+		return err
+	}
+	vt := pt.valueType(ref)
+	if value == nil {
+		vt = TypeNull
+	} else if vt == TypeNull {
+		return fmt.Errorf("bacnet: point %s: property_value_type required for %s", pt.Name, PropertyName(ref.Property))
+	}
+	encoded, err := encodeValue(vt, value)
+	if err != nil {
+		return fmt.Errorf("bacnet: point %s: %v", pt.Name, err)
+	}
+	if _, err := c.tr.request(c.peer, serviceWriteProperty, encodeWriteProperty(ref, encoded, priority)); err != nil {
+		return fmt.Errorf("bacnet: write %s %s failed: %w", ref.Object, PropertyName(ref.Property), err)
 	}
-	pt.Value = value
-	c.points[point] = pt
 	return nil
 }
 
+// ReadPriorityArray 读取点位对象的 priorityArray，返回 16 个优先级上的命令值（下标 0 为优先级 1），
+// 未被占用的优先级为 nil
+func (c *BacnetClient) ReadPriorityArray(point string) ([]interface{}, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	pt, ok := c.resolvePoint(point)
+	if !ok {
+		return nil, fmt.Errorf("bacnet: point %s not found", point)
+	}
+	if !c.connected {
+		return nil, errors.New("bacnet: not connected")
+	}
+	ref, err := pt.propertyRef()
+	if err != nil {
+		return nil, err
+	}
+	ref.Property = PropPriorityArray
+	_, values, err := c.readProperty(ref)
+	if err != nil {
+		return nil, fmt.Errorf("bacnet: read %s priorityArray failed: %w", ref.Object, err)
+	}
+	if len(values) != 16 {
+		return nil, fmt.Errorf("bacnet: %s priorityArray has %d entries", ref.Object, len(values))
+	}
+	slots := make([]interface{}, len(values))
+	for i, v := range values {
+		if v.Type != TypeNull {
+			slots[i] = pointValue(pt, values[i:i+1])
+		}
+	}
+	return slots, nil
+}
+
 func (c *BacnetClient) Close() error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
 	c.connected = false
+	if c.tr != nil {
+		c.stopCOV()
+		return c.tr.close()
+	}
 	return nil
 }
 
 func (c *BacnetClient) Reconnect() error {
 	c.lock.Lock()
 	defer c.lock.Unlock()
+	if c.peer == nil {
+		return errors.New("bacnet: not initialized")
+	}
 	time.Sleep(100 * time.Millisecond)
-	c.connected = true
-	return nil
-}
-
-// BACnet 发现到的设备信息结构体
-type BACnetDeviceInfo struct {
-	DeviceID string
-	Address  string
-	Vendor   string
-	Model    string
-}
-
-// 设备发现与 I-Am 解析（模拟/占位实现）
-func (c *BacnetClient) DiscoverDevices() ([]BACnetDeviceInfo, error) {
-	fmt.Println("[BACnet] DiscoverDevices: Who-Is → I-Am (模拟)")
-	// 模拟返回2台设备
-	devices := []BACnetDeviceInfo{
-		{DeviceID: "2228316", Address: "192.168.1.10", Vendor: "SimVendor", Model: "SimModelA"},
-		{DeviceID: "2228317", Address: "192.168.1.11", Vendor: "SimVendor", Model: "SimModelB"},
-	}
-	c.discovered = true
-	return devices, nil
-}
-
-// 真实网络发现 Who-Is/I-Am（占位实现）
-func (c *BacnetClient) DiscoverDevicesReal(timeout time.Duration) ([]BACnetDeviceInfo, error) {
-	var devices []BACnetDeviceInfo
-	conn, err := net.ListenPacket("udp4", ":0")
-	if err != nil {
-		return nil, err
+	if c.tr != nil {
+		c.tr.close()
 	}
-	defer conn.Close()
-	whoIs := []byte{0x81, 0x0b, 0x00, 0x0c, 0x01, 0x20, 0xff, 0xff, 0xff, 0xff, 0x00, 0x00}
-	broadcastAddr, _ := net.ResolveUDPAddr("udp4", "255.255.255.255:47808")
-	conn.WriteTo(whoIs, broadcastAddr)
-	conn.SetDeadline(time.Now().Add(timeout))
-	buf := make([]byte, 1024)
-	for {
-		n, addr, err := conn.ReadFrom(buf)
-		if err != nil {
-			break
-		}
-		// 简单判断I-Am响应（实际应解析BACnet NPDU/APDU）
-		if n > 10 && buf[1] == 0x0a {
-			devices = append(devices, BACnetDeviceInfo{
-				DeviceID: "解析DeviceID", // TODO: 解析真实DeviceID
-				Address:  addr.String(),
-				Vendor:   "Unknown",
-				Model:    "Unknown",
-			})
-		}
+	if err := c.open(); err != nil {
+		return err
 	}
-	return devices, nil
+	c.syncCOV()
+	c.resetCOV()
+	c.connected = true
+	return nil
 }
 
 // 导出点位物模型
@@ -410,18 +559,19 @@ func (c *BacnetClient) GetPointModel() map[string]BacnetPoint {
 	return model
 }
 
-// 支持简单表达式的 transform 解析
-func parseTransform(expr string, value interface{}) (interface{}, error) {
-	if expr == "" {
-		return value, nil
-	}
-	parameters := make(map[string]interface{})
-	parameters["value"] = value
-	expression, err := govaluate.NewEvaluableExpression(expr)
-	if err != nil {
-		return value, err
+// configInt 读取配置中的整数（YAML 解析为 int，JSON 为 float64）
+func configInt(v interface{}, def int) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case float64:
+		return int(n)
+	case string:
+		if i, err := strconv.Atoi(n); err == nil {
+			return i
+		}
 	}
-	return expression.Evaluate(parameters)
+	return def
 }
 
 func init() {
@@ -429,11 +579,3 @@ func init() {
 		return &BacnetClient{}
 	})
 }
-
-// BACnetReadRequest 结构体（模拟/占位）
-type BACnetReadRequest struct {
-	DeviceID   string
-	ObjectType string
-	Instance   int
-	Property   string
-}
@@ -1,19 +1,48 @@
 package bacnet
 
 import (
+	"net"
 	"testing"
+	"time"
 )
 
+// TestDiscoverDevices 应答 Who-Is 的设备和主动广播 I-Am 的设备进入同一个注册表，读取属性后回调 OnDevice
 func TestDiscoverDevices(t *testing.T) {
-	client := &BacnetClient{}
-	devices, err := client.DiscoverDevices()
+	simA, simB := newSimDevice(t), newSimDevice(t)
+	simA.instance = 2228316
+	simA.set(ObjectID{Type: BacnetDevice, Instance: 2228316}, PropModelName, TypeCharacterString, "SimModelA")
+	simB.set(ObjectID{Type: BacnetDevice, Instance: 2228317}, PropModelName, TypeCharacterString, "SimModelB")
+
+	disc, err := NewDiscovery("127.0.0.1:0", simA.conn.LocalAddr().String(), 200*time.Millisecond)
 	if err != nil {
-		t.Fatalf("DiscoverDevices failed: %v", err)
+		t.Fatalf("NewDiscovery failed: %v", err)
+	}
+	defer disc.Close()
+	found := make(chan DeviceInfo, 2)
+	disc.OnDevice(func(dev DeviceInfo) { found <- dev })
+
+	devices, err := disc.Discover(-1, -1, 200*time.Millisecond)
+	if err != nil || len(devices) != 1 {
+		t.Fatalf("Discover: devices=%v err=%v", devices, err)
 	}
+	// simB 未收到 Who-Is，主动广播 I-Am
+	iam := encodeIAm(DeviceInfo{DeviceID: 2228317, MaxAPDU: maxAPDU, Segmentation: 3, VendorID: 260})
+	simB.conn.WriteToUDP(encodeFrame(encodeUnconfirmedRequest(serviceIAm, iam), false, false), disc.tr.conn.LocalAddr().(*net.UDPAddr))
+	for i := 0; i < 2; i++ {
+		select {
+		case <-found:
+		case <-time.After(2 * time.Second):
+			t.Fatalf("OnDevice called %d times, want 2", i)
+		}
+	}
+	devices = disc.Devices()
 	if len(devices) != 2 {
-		t.Errorf("expect 2 devices, got %d", len(devices))
+		t.Fatalf("expect 2 devices, got %d", len(devices))
 	}
-	if devices[0].DeviceID != "2228316" || devices[1].DeviceID != "2228317" {
+	if devices[0].DeviceID != 2228316 || devices[1].DeviceID != 2228317 {
 		t.Errorf("device id mismatch: %+v", devices)
 	}
+	if devices[0].ModelName != "SimModelA" || devices[1].ModelName != "SimModelB" {
+		t.Errorf("model name mismatch: %+v", devices)
+	}
 }
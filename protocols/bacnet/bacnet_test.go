@@ -1,156 +1,130 @@
 package bacnet
 
 import (
+	"reflect"
 	"testing"
 )
 
-func getTestConfig() map[string]interface{} {
-	return map[string]interface{}{
-		"device_id": "test_bacnet_1",
-		"points": []interface{}{
-			map[string]interface{}{
-				"name":                "temp",
-				"address":             "1",
-				"type":                "float",
-				"format":              "Float AB CD",
-				"unit":                "℃",
-				"init_value":          25.5,
-				"property_value_type": "REAL",
-				"writable":            true,
-			},
-			map[string]interface{}{
-				"name":                "alarm",
-				"address":             "2",
-				"type":                "bool",
-				"format":              "BOOL",
-				"unit":                "",
-				"init_value":          false,
-				"property_value_type": "BOOLEAN",
-				"writable":            true,
-			},
-			map[string]interface{}{
-				"name":                "count",
-				"address":             "3",
-				"type":                "int",
-				"format":              "INT",
-				"unit":                "",
-				"init_value":          10,
-				"property_value_type": "INTEGER",
-				"writable":            true,
-			},
-			map[string]interface{}{
-				"name":                "enum",
-				"address":             "4",
-				"type":                "int",
-				"format":              "INT",
-				"unit":                "",
-				"init_value":          1,
-				"property_value_type": "ENUMERATED",
-				"writable":            true,
-			},
-			map[string]interface{}{
-				"name":                "str",
-				"address":             "5",
-				"type":                "string",
-				"format":              "CHAR",
-				"unit":                "",
-				"init_value":          "abc",
-				"property_value_type": "CHARACTERSTRING",
-				"writable":            true,
-			},
-			map[string]interface{}{
-				"name":                "octet",
-				"address":             "6",
-				"type":                "bytes",
-				"format":              "OCTET",
-				"unit":                "",
-				"init_value":          []byte{1, 2, 3},
-				"property_value_type": "OCTETSTRING",
-				"writable":            true,
-			},
-			map[string]interface{}{
-				"name":                "bitstr",
-				"address":             "7",
-				"type":                "bits",
-				"format":              "BITSTR",
-				"unit":                "",
-				"init_value":          []bool{true, false},
-				"property_value_type": "BITSTRING",
-				"writable":            true,
-			},
-			map[string]interface{}{
-				"name":                "objid",
-				"address":             "8",
-				"type":                "objectid",
-				"format":              "OBJID",
-				"unit":                "",
-				"init_value":          ObjectID{Type: AnalogInput, Instance: 1},
-				"property_value_type": "OBJECTID",
-				"writable":            true,
-			},
-		},
-	}
+// testPoints 覆盖各种属性值类型的点位，模拟器中对应对象的 presentValue 初值为 value
+var testPoints = []struct {
+	name, address, typ, pvt string
+	vt                      PropertyValueType
+	value                   interface{}
+}{
+	{"temp", "analogValue:1", "float", "REAL", TypeReal, 25.5},
+	{"alarm", "binaryValue:2", "bool", "BOOLEAN", TypeBoolean, false},
+	{"count", "integerValue:3", "int", "INTEGER", TypeSignedInt, 10},
+	{"enum", "multiStateValue:4", "int", "ENUMERATED", TypeEnumerated, 1},
+	{"str", "characterstringValue:5", "string", "CHARACTERSTRING", TypeCharacterString, "abc"},
+	{"octet", "octetstringValue:6", "bytes", "OCTETSTRING", TypeOctetString, []byte{1, 2, 3}},
+	{"bitstr", "bitstringValue:7", "bits", "BITSTRING", TypeBitString, []bool{true, false}},
+	{"objid", "analogValue:8", "objectid", "OBJECTID", TypeObjectID, ObjectID{Type: AnalogInput, Instance: 1}},
 }
 
-func TestInitAndGetPointModel(t *testing.T) {
+// newTestClient 在 UDP 模拟设备上初始化带 testPoints 的客户端
+func newTestClient(t *testing.T) *BacnetClient {
+	sim := newSimDevice(t)
+	conf := sim.config()
+	var points []interface{}
+	for _, p := range testPoints {
+		segs := parseAddressFields(p.address)
+		objType, err := ParseObjectType(segs[0].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sim.set(ObjectID{Type: objType, Instance: uint32(segs[1].(int))}, PropPresentValue, p.vt, p.value)
+		points = append(points, map[string]interface{}{
+			"name": p.name, "address": p.address, "type": p.typ, "property_value_type": p.pvt, "writable": true,
+		})
+	}
+	conf["points"] = points
 	c := &BacnetClient{}
-	err := c.Init(getTestConfig())
-	if err != nil {
+	if err := c.Init(conf); err != nil {
 		t.Fatalf("Init failed: %v", err)
 	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+// readBack 读取全部点位，按点位名返回值，质量不为 good 时报错
+func readBack(t *testing.T, c *BacnetClient) map[string]interface{} {
+	t.Helper()
+	vals, err := c.Read("sim")
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	got := make(map[string]interface{}, len(vals))
+	for _, v := range vals {
+		if v.Quality != "good" {
+			t.Errorf("%s quality %s", v.PointID, v.Quality)
+		}
+		got[v.PointID] = v.Value
+	}
+	return got
+}
+
+func TestInitAndGetPointModel(t *testing.T) {
+	c := newTestClient(t)
 	model := c.GetPointModel()
 	if len(model) != 8 {
 		t.Errorf("point model size error: %d", len(model))
 	}
+	if err := (&BacnetClient{}).Init(map[string]interface{}{"device_id": "no_ip"}); err == nil {
+		t.Error("Init without ip should fail")
+	}
 }
 
 func TestRead(t *testing.T) {
-	c := &BacnetClient{}
-	_ = c.Init(getTestConfig())
-	vals, err := c.Read("test_bacnet_1")
-	if err != nil {
-		t.Fatalf("Read failed: %v", err)
+	c := newTestClient(t)
+	got := readBack(t, c)
+	if len(got) != 8 {
+		t.Errorf("Read point count error: %d", len(got))
 	}
-	if len(vals) != 8 {
-		t.Errorf("Read point count error: %d", len(vals))
+	for _, p := range testPoints {
+		if !reflect.DeepEqual(got[p.name], p.value) {
+			t.Errorf("%s = %#v, want %#v", p.name, got[p.name], p.value)
+		}
 	}
 }
 
 func TestReadBatch(t *testing.T) {
-	c := &BacnetClient{}
-	_ = c.Init(getTestConfig())
-	vals, err := c.ReadBatch("test_bacnet_1", "analogInput", []string{"temp", "alarm"})
+	c := newTestClient(t)
+	vals, err := c.ReadBatch("sim", "analogInput", []string{"temp", "alarm"})
 	if err != nil {
 		t.Fatalf("ReadBatch failed: %v", err)
 	}
-	if len(vals) != 2 {
-		t.Errorf("ReadBatch point count error: %d", len(vals))
+	if len(vals) != 2 || vals[0].Value != 25.5 || vals[1].Value != false {
+		t.Errorf("ReadBatch = %+v", vals)
 	}
 }
 
 func TestWriteAndReadBack(t *testing.T) {
-	c := &BacnetClient{}
-	_ = c.Init(getTestConfig())
-	_ = c.Write("temp", 30.5)
-	_ = c.Write("alarm", true)
-	_ = c.Write("count", 99)
-	_ = c.Write("enum", 2)
-	_ = c.Write("str", "hello")
-	_ = c.Write("octet", []byte{9, 8, 7})
-	_ = c.Write("bitstr", []bool{false, true})
-	_ = c.Write("objid", ObjectID{Type: AnalogInput, Instance: 2})
-	vals, err := c.Read("test_bacnet_1")
-	if err != nil {
-		t.Fatalf("Read failed: %v", err)
+	c := newTestClient(t)
+	writes := map[string]interface{}{
+		"temp":   30.5,
+		"alarm":  true,
+		"count":  99,
+		"enum":   2,
+		"str":    "hello",
+		"octet":  []byte{9, 8, 7},
+		"bitstr": []bool{false, true},
+		"objid":  ObjectID{Type: AnalogInput, Instance: 2},
 	}
-	if len(vals) != 8 {
-		t.Errorf("Read back count error: %d", len(vals))
+	for name, v := range writes {
+		if err := c.Write(name, v); err != nil {
+			t.Errorf("Write %s failed: %v", name, err)
+		}
+	}
+	got := readBack(t, c)
+	for name, v := range writes {
+		if !reflect.DeepEqual(got[name], v) {
+			t.Errorf("read back %s = %#v, want %#v", name, got[name], v)
+		}
 	}
 }
 
 func TestWriteTypeError(t *testing.T) {
-	c := &BacnetClient{}
-	_ = c.Init(getTestConfig())
+	c := newTestClient(t)
 	if err := c.Write("temp", true); err == nil {
 		t.Error("Write type error not detected (float)")
 	}
@@ -162,30 +136,57 @@ func TestWriteTypeError(t *testing.T) {
 	}
 }
 
+// TestWriteNumericConversion 北向写入的数值类型（modbus_server 的 uint16、OPC UA 的 int64/float32）按属性值类型转换
+func TestWriteNumericConversion(t *testing.T) {
+	c := newTestClient(t)
+	writes := []struct {
+		point string
+		value interface{}
+		want  interface{}
+	}{
+		{"temp", uint16(40), 40.0},
+		{"temp", float32(12.5), 12.5},
+		{"count", int64(-7), -7},
+		{"enum", uint16(3), 3},
+		{"enum", 2.0, 2},
+	}
+	for _, w := range writes {
+		if err := c.Write(w.point, w.value); err != nil {
+			t.Errorf("Write %s %T failed: %v", w.point, w.value, err)
+			continue
+		}
+		if got := readBack(t, c)[w.point]; got != w.want {
+			t.Errorf("%s after writing %T = %#v, want %#v", w.point, w.value, got, w.want)
+		}
+	}
+	if err := c.Write("enum", 2.5); err == nil {
+		t.Error("Write ENUMERATED with fraction should fail")
+	}
+}
+
 func TestWriteNotFound(t *testing.T) {
-	c := &BacnetClient{}
-	_ = c.Init(getTestConfig())
+	c := newTestClient(t)
 	if err := c.Write("not_exist", 1); err == nil {
 		t.Error("Write not found error not detected")
 	}
 }
 
 func TestCloseAndReconnect(t *testing.T) {
-	c := &BacnetClient{}
-	_ = c.Init(getTestConfig())
+	c := newTestClient(t)
 	_ = c.Close()
-	if _, err := c.Read("test_bacnet_1"); err == nil {
+	if _, err := c.Read("sim"); err == nil {
 		t.Error("Read after close should fail")
 	}
-	_ = c.Reconnect()
-	if _, err := c.Read("test_bacnet_1"); err != nil {
+	if err := c.Reconnect(); err != nil {
+		t.Fatalf("Reconnect failed: %v", err)
+	}
+	if _, err := c.Read("sim"); err != nil {
 		t.Error("Read after reconnect should succeed")
 	}
 }
 
 func TestWriteAllTypes(t *testing.T) {
-	c := &BacnetClient{}
-	_ = c.Init(getTestConfig())
+	c := newTestClient(t)
 	// REAL
 	if err := c.Write("temp", 12.34); err != nil {
 		t.Errorf("Write REAL failed: %v", err)
@@ -221,10 +222,9 @@ func TestWriteAllTypes(t *testing.T) {
 }
 
 func TestWriteTypeErrorAllTypes(t *testing.T) {
-	c := &BacnetClient{}
-	_ = c.Init(getTestConfig())
+	c := newTestClient(t)
 	// REAL
-	if err := c.Write("temp", int(123)); err == nil {
+	if err := c.Write("temp", "123"); err == nil {
 		t.Error("Write REAL type error not detected")
 	}
 	// BOOLEAN
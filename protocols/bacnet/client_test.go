@@ -0,0 +1,839 @@
+package bacnet
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"sensor-edge/config"
+	"sensor-edge/protocols"
+	"sensor-edge/types"
+)
+
+// simDevice 本地 UDP BACnet 设备模拟器，对象属性值以编码后的应用标签保存
+type simDevice struct {
+	t    *testing.T
+	conn *net.UDPConn
+
+	mu       sync.Mutex
+	values   map[propertyRef][]byte
+	drop     int               // 丢弃接下来的 N 个请求，用于验证超时重发
+	abort    map[ObjectID]bool // 读这些对象时以 segmentation-not-supported 中止
+	noRPM    bool              // 以 unrecognized-service 拒绝 ReadPropertyMultiple
+	requests []apdu
+
+	covReject map[ObjectID]bool // 拒绝这些对象的 COV 订阅
+	covSubs   map[ObjectID]simSubscription
+
+	commandable map[ObjectID][16][]byte // 可命令对象的优先级数组，nil 表示 NULL
+
+	instance    uint32 // 非 0 时以该设备实例号应答 Who-Is
+	maxResponse int    // 非 0 时 ReadProperty 应答超过该长度以 segmentation-not-supported 中止
+}
+
+func newSimDevice(t *testing.T) *simDevice {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	d := &simDevice{t: t, conn: conn, values: make(map[propertyRef][]byte), abort: make(map[ObjectID]bool),
+		covReject: make(map[ObjectID]bool), covSubs: make(map[ObjectID]simSubscription),
+		commandable: make(map[ObjectID][16][]byte)}
+	go d.serve()
+	t.Cleanup(func() { conn.Close() })
+	return d
+}
+
+func (d *simDevice) set(obj ObjectID, prop uint32, t PropertyValueType, v interface{}) {
+	b, err := encodeValue(t, v)
+	if err != nil {
+		d.t.Fatalf("encode %v: %v", v, err)
+	}
+	d.mu.Lock()
+	d.values[propertyRef{Object: obj, Property: prop, Index: arrayAll}] = b
+	d.mu.Unlock()
+}
+
+func (d *simDevice) get(obj ObjectID, prop uint32) []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.values[propertyRef{Object: obj, Property: prop, Index: arrayAll}]
+}
+
+func (d *simDevice) config() map[string]interface{} {
+	addr := d.conn.LocalAddr().(*net.UDPAddr)
+	return map[string]interface{}{"device_id": "sim", "ip": "127.0.0.1", "port": addr.Port, "timeout": 200, "retries": 2}
+}
+
+func (d *simDevice) serve() {
+	buf := make([]byte, 2048)
+	for {
+		n, src, err := d.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		data, _, err := decodeFrame(buf[:n])
+		if err != nil || len(data) == 0 {
+			continue
+		}
+		a, err := decodeAPDU(data)
+		if err == nil && a.pduType == pduUnconfirmedRequest && a.service == serviceWhoIs {
+			d.whoIs(a, src)
+			continue
+		}
+		if err != nil || a.pduType != pduConfirmedRequest {
+			continue
+		}
+		d.mu.Lock()
+		d.requests = append(d.requests, a)
+		if d.drop > 0 {
+			d.drop--
+			d.mu.Unlock()
+			continue
+		}
+		d.mu.Unlock()
+		if a.service == serviceSubscribeCOV {
+			d.subscribe(a, src)
+			continue
+		}
+		if resp := d.handle(a); resp != nil {
+			d.conn.WriteToUDP(encodeFrame(resp, false, false), src)
+		}
+	}
+}
+
+func (d *simDevice) whoIs(a apdu, src *net.UDPAddr) {
+	d.mu.Lock()
+	id := d.instance
+	d.mu.Unlock()
+	low, high, err := decodeWhoIs(a.data)
+	if id == 0 || err != nil || (low >= 0 && (int(id) < low || int(id) > high)) {
+		return
+	}
+	iam := encodeIAm(DeviceInfo{DeviceID: id, MaxAPDU: maxAPDU, Segmentation: 3, VendorID: 260})
+	d.conn.WriteToUDP(encodeFrame(encodeUnconfirmedRequest(serviceIAm, iam), false, false), src)
+}
+
+func (d *simDevice) handle(a apdu) []byte {
+	if a.service == serviceReadPropertyMultiple {
+		return d.readMultiple(a)
+	}
+	ref, pos, err := decodePropertyRef(a.data)
+	if err != nil {
+		return encodeReject(a.invokeID, 5)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	key := propertyRef{Object: ref.Object, Property: ref.Property, Index: arrayAll}
+	switch a.service {
+	case serviceReadProperty:
+		if d.abort[ref.Object] {
+			return encodeAbort(a.invokeID, abortSegmentationNotSupported, true)
+		}
+		v, ok := d.lookup(ref)
+		if !ok {
+			return encodeErrorPDU(a.invokeID, a.service, 1, 31) // object / unknown-object
+		}
+		ack := appendPropertyRef(nil, ref)
+		ack = encodeOpeningTag(ack, 3)
+		ack = append(ack, v...)
+		ack = encodeClosingTag(ack, 3)
+		if d.maxResponse > 0 && len(ack)+3 > d.maxResponse {
+			return encodeAbort(a.invokeID, abortSegmentationNotSupported, true)
+		}
+		return encodeComplexAck(a.invokeID, a.service, ack)
+	case serviceWriteProperty:
+		if _, ok := d.values[key]; !ok {
+			return encodeErrorPDU(a.invokeID, a.service, 1, 31)
+		}
+		content, n, err := splitEnclosed(a.data[pos:], 3)
+		if err != nil {
+			return encodeReject(a.invokeID, 5)
+		}
+		slots, ok := d.commandable[ref.Object]
+		if !ok || ref.Property != PropPresentValue {
+			d.values[key] = append([]byte(nil), content...)
+			return encodeSimpleAck(a.invokeID, a.service)
+		}
+		priority := 16
+		if fields, err := decodeContextFields(a.data[pos+n:]); err == nil && fields[4] != nil {
+			priority = int(decodeUnsigned(fields[4]))
+		}
+		slots[priority-1] = nil
+		if !bytes.Equal(content, []byte{0x00}) {
+			slots[priority-1] = append([]byte(nil), content...)
+		}
+		d.commandable[ref.Object] = slots
+		d.command(ref.Object)
+		return encodeSimpleAck(a.invokeID, a.service)
+	}
+	return encodeReject(a.invokeID, 9)
+}
+
+// command 按优先级数组更新 presentValue（无命令时取 relinquishDefault）和 priorityArray（调用方持有锁）
+func (d *simDevice) command(obj ObjectID) {
+	slots := d.commandable[obj]
+	pv := d.values[propertyRef{Object: obj, Property: PropRelinquishDefault, Index: arrayAll}]
+	var array []byte
+	for i := len(slots) - 1; i >= 0; i-- {
+		if slots[i] != nil {
+			pv = slots[i]
+		}
+	}
+	for _, v := range slots {
+		if v == nil {
+			v = []byte{0x00}
+		}
+		array = append(array, v...)
+	}
+	d.values[propertyRef{Object: obj, Property: PropPresentValue, Index: arrayAll}] = pv
+	d.values[propertyRef{Object: obj, Property: PropPriorityArray, Index: arrayAll}] = array
+}
+
+// lookup 取属性值（调用方持有锁），带数组下标时取对应元素，下标 0 为数组长度
+func (d *simDevice) lookup(ref propertyRef) ([]byte, bool) {
+	v, ok := d.values[propertyRef{Object: ref.Object, Property: ref.Property, Index: arrayAll}]
+	if !ok || ref.Index == arrayAll {
+		return v, ok
+	}
+	var elems [][]byte
+	for pos := 0; pos < len(v); {
+		_, n, err := decodeApplicationValue(v[pos:])
+		if err != nil {
+			return nil, false
+		}
+		elems = append(elems, v[pos:pos+n])
+		pos += n
+	}
+	if ref.Index == 0 {
+		return appendApplication(nil, TypeUnsignedInt, unsignedBytes(uint64(len(elems)))), true
+	}
+	if int(ref.Index) > len(elems) {
+		return nil, false
+	}
+	return elems[ref.Index-1], true
+}
+
+func (d *simDevice) readMultiple(a apdu) []byte {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.noRPM {
+		return encodeReject(a.invokeID, 9)
+	}
+	refs, err := decodeReadPropertyMultiple(a.data)
+	if err != nil {
+		return encodeReject(a.invokeID, 5)
+	}
+	results := make([]propertyResult, len(refs))
+	for i, ref := range refs {
+		if d.abort[ref.Object] {
+			return encodeAbort(a.invokeID, abortSegmentationNotSupported, true)
+		}
+		results[i].Ref = ref
+		v, ok := d.lookup(ref)
+		if !ok {
+			results[i].Err = &Error{Class: 1, Code: 31}
+			continue
+		}
+		results[i].Values, _ = decodeValues(v)
+	}
+	ack, err := encodeReadPropertyMultipleAck(results)
+	if err != nil {
+		return encodeAbort(a.invokeID, 0, true)
+	}
+	if len(ack)+3 > a.maxResponse {
+		return encodeAbort(a.invokeID, abortSegmentationNotSupported, true)
+	}
+	return encodeComplexAck(a.invokeID, a.service, ack)
+}
+
+type simSubscription struct {
+	addr      *net.UDPAddr
+	processID uint32
+	confirmed bool
+	lifetime  uint32
+}
+
+// subscribe 接受订阅后按标准立即发送一次当前值的通知
+func (d *simDevice) subscribe(a apdu, src *net.UDPAddr) {
+	pid, obj, confirmed, lifetime, cancel, err := decodeSubscribeCOV(a.data)
+	d.mu.Lock()
+	switch {
+	case err != nil:
+		d.mu.Unlock()
+		d.conn.WriteToUDP(encodeFrame(encodeReject(a.invokeID, 5), false, false), src)
+		return
+	case d.covReject[obj]:
+		delete(d.covSubs, obj)
+		d.mu.Unlock()
+		d.conn.WriteToUDP(encodeFrame(encodeErrorPDU(a.invokeID, a.service, 5, 43), false, false), src)
+		return
+	case cancel:
+		delete(d.covSubs, obj)
+	default:
+		d.covSubs[obj] = simSubscription{addr: src, processID: pid, confirmed: confirmed, lifetime: lifetime}
+	}
+	d.mu.Unlock()
+	d.conn.WriteToUDP(encodeFrame(encodeSimpleAck(a.invokeID, a.service), false, false), src)
+	if !cancel {
+		d.notify(obj)
+	}
+}
+
+// notify 向订阅者发送对象当前 presentValue 的 COV 通知
+func (d *simDevice) notify(obj ObjectID) {
+	d.mu.Lock()
+	sub, ok := d.covSubs[obj]
+	values, _ := decodeValues(d.values[propertyRef{Object: obj, Property: PropPresentValue, Index: arrayAll}])
+	d.mu.Unlock()
+	if !ok {
+		return
+	}
+	params, err := encodeCOVNotification(covNotification{
+		ProcessID: sub.processID, Device: ObjectID{Type: BacnetDevice, Instance: 1001}, Object: obj, TimeRemaining: sub.lifetime,
+		Values: []propertyResult{{Ref: propertyRef{Object: obj, Property: PropPresentValue, Index: arrayAll}, Values: values}},
+	})
+	if err != nil {
+		d.t.Errorf("encode notification: %v", err)
+		return
+	}
+	apdu := encodeUnconfirmedRequest(serviceUnconfirmedCOVNotification, params)
+	if sub.confirmed {
+		apdu = encodeConfirmedRequest(7, serviceConfirmedCOVNotification, params)
+	}
+	d.conn.WriteToUDP(encodeFrame(apdu, false, sub.confirmed), sub.addr)
+}
+
+func (d *simDevice) count(service byte) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	n := 0
+	for _, a := range d.requests {
+		if a.service == service {
+			n++
+		}
+	}
+	return n
+}
+
+func TestEncodeReadPropertyRequest(t *testing.T) {
+	apdu := encodeConfirmedRequest(1, serviceReadProperty, encodeReadProperty(propertyRef{
+		Object: ObjectID{Type: AnalogInput, Instance: 0}, Property: PropPresentValue, Index: arrayAll}))
+	frame := encodeFrame(apdu, false, true)
+	want, _ := hex.DecodeString("810a001101040005010c0c000000001955")
+	if !bytes.Equal(frame, want) {
+		t.Errorf("frame = % x, want % x", frame, want)
+	}
+}
+
+func TestValueRoundTrip(t *testing.T) {
+	cases := []struct {
+		t    PropertyValueType
+		in   interface{}
+		want interface{}
+	}{
+		{TypeReal, 21.1, 21.1},
+		{TypeDouble, -1.5, -1.5},
+		{TypeUnsignedInt, uint64(70000), uint64(70000)},
+		{TypeSignedInt, -300, -300},
+		{TypeEnumerated, true, 1},
+		{TypeBoolean, true, true},
+		{TypeCharacterString, "温度", "温度"},
+		{TypeDate, "2024-02-29", "2024-02-29"},
+		{TypeTime, "13:05:09.50", "13:05:09.50"},
+		{TypeObjectID, ObjectID{Type: AnalogValue, Instance: 4194303}, ObjectID{Type: AnalogValue, Instance: 4194303}},
+	}
+	for _, c := range cases {
+		b, err := encodeValue(c.t, c.in)
+		if err != nil {
+			t.Errorf("encode %v: %v", c.in, err)
+			continue
+		}
+		pv, n, err := decodeApplicationValue(b)
+		if err != nil || n != len(b) || pv.Type != c.t || pv.Value != c.want {
+			t.Errorf("%v: decoded %#v (type %d, %d/%d bytes, %v)", c.in, pv.Value, pv.Type, n, len(b), err)
+		}
+	}
+	b, _ := encodeValue(TypeBitString, []bool{true, false, true, true, false, false, false, false, true})
+	pv, _, err := decodeApplicationValue(b)
+	if bits, ok := pv.Value.([]bool); err != nil || !ok || len(bits) != 9 || !bits[0] || bits[1] || !bits[8] {
+		t.Errorf("bitstring decoded %v %v", pv.Value, err)
+	}
+}
+
+func TestReadWriteProperty(t *testing.T) {
+	sim := newSimDevice(t)
+	ai0 := ObjectID{Type: AnalogInput, Instance: 0}
+	av1 := ObjectID{Type: AnalogValue, Instance: 1}
+	bv2 := ObjectID{Type: BinaryValue, Instance: 2}
+	sim.set(ai0, PropPresentValue, TypeReal, 21.5)
+	sim.set(av1, PropPresentValue, TypeReal, 18.0)
+	sim.set(bv2, PropPresentValue, TypeEnumerated, 1)
+	sim.set(ai0, PropObjectName, TypeCharacterString, "Room Temp")
+	sim.mu.Lock()
+	sim.abort[ObjectID{Type: TrendLogMultiple, Instance: 1}] = true
+	sim.mu.Unlock()
+
+	c := &BacnetClient{}
+	if err := c.Init(sim.config()); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	defer c.Close()
+	c.SetPointConfigs("sim", []protocols.PointConfig{
+		{PointID: "temp", Address: "analogInput:0", Type: "float"},
+		{PointID: "name", Address: "analogInput:0:objectName", Type: "string"},
+		{PointID: "setpoint", Address: "analogValue:1", Type: "float", Options: map[string]interface{}{"writable": true}},
+		{PointID: "fan", Address: "binaryValue:2", Type: "bool", Options: map[string]interface{}{"writable": true}},
+		{PointID: "missing", Address: "analogInput:9", Type: "float"},
+		{PointID: "log", Address: "trendLogMultiple:1:logBuffer", Options: map[string]interface{}{"property": "131"}},
+	})
+
+	values, err := c.ReadBatch("sim", "", []string{"temp", "name", "fan", "missing", "log"})
+	if err != nil {
+		t.Fatalf("ReadBatch failed: %v", err)
+	}
+	want := []struct {
+		value   interface{}
+		quality string
+	}{{21.5, "good"}, {"Room Temp", "good"}, {true, "good"}, {nil, "bad:object:unknown-object"}, {nil, "bad"}}
+	for i, w := range want {
+		if values[i].Value != w.value || values[i].Quality != w.quality {
+			t.Errorf("%s = %v (%s), want %v (%s)", values[i].PointID, values[i].Value, values[i].Quality, w.value, w.quality)
+		}
+	}
+
+	if err := c.Write("setpoint", 22.5); err != nil {
+		t.Fatalf("Write setpoint failed: %v", err)
+	}
+	if pv, _, _ := decodeApplicationValue(sim.get(av1, PropPresentValue)); pv.Value != 22.5 {
+		t.Errorf("setpoint on device = %v, want 22.5", pv.Value)
+	}
+	if err := c.Write("binaryValue:2", false); err != nil {
+		t.Fatalf("Write fan failed: %v", err)
+	}
+	if pv, _, _ := decodeApplicationValue(sim.get(bv2, PropPresentValue)); pv.Type != TypeEnumerated || pv.Value != 0 {
+		t.Errorf("fan on device = %v, want inactive", pv.Value)
+	}
+	if err := c.Write("temp", 1.0); err == nil {
+		t.Error("write to read-only point should fail")
+	}
+
+	// 丢弃首个请求，应以同一 invoke ID 重发后成功
+	sim.mu.Lock()
+	sim.drop = 1
+	sim.requests = nil
+	sim.mu.Unlock()
+	if values, err := c.ReadBatch("sim", "", []string{"temp"}); err != nil || values[0].Value != 21.5 {
+		t.Fatalf("read after drop: %v %v", values, err)
+	}
+	sim.mu.Lock()
+	if len(sim.requests) != 2 || sim.requests[0].invokeID != sim.requests[1].invokeID {
+		t.Errorf("expect one retransmission with the same invoke ID, got %+v", sim.requests)
+	}
+	sim.mu.Unlock()
+}
+
+func TestTransportErrors(t *testing.T) {
+	sim := newSimDevice(t)
+	c := &BacnetClient{}
+	if err := c.Init(sim.config()); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	defer c.Close()
+	sim.mu.Lock()
+	sim.abort[ObjectID{Type: AnalogInput, Instance: 1}] = true
+	sim.mu.Unlock()
+
+	_, _, err := c.readProperty(propertyRef{Object: ObjectID{Type: AnalogInput, Instance: 1}, Property: PropPresentValue, Index: arrayAll})
+	if !errors.Is(err, ErrSegmentationNotSupported) {
+		t.Errorf("expect segmentation not supported, got %v", err)
+	}
+	_, _, err = c.readProperty(propertyRef{Object: ObjectID{Type: AnalogInput, Instance: 2}, Property: PropPresentValue, Index: arrayAll})
+	var be *Error
+	if !errors.As(err, &be) || be.ClassName() != "object" || be.CodeName() != "unknown-object" {
+		t.Errorf("expect unknown-object error, got %v", err)
+	}
+
+	sim.mu.Lock()
+	sim.drop = 3
+	sim.mu.Unlock()
+	start := time.Now()
+	_, _, err = c.readProperty(propertyRef{Object: ObjectID{Type: AnalogInput, Instance: 2}, Property: PropPresentValue, Index: arrayAll})
+	if !errors.Is(err, ErrTimeout) {
+		t.Errorf("expect timeout, got %v", err)
+	}
+	if d := time.Since(start); d < 500*time.Millisecond {
+		t.Errorf("timed out after %v, expect 3 attempts of 200ms", d)
+	}
+}
+
+func TestReadPropertyMultiple(t *testing.T) {
+	sim := newSimDevice(t)
+	sim.set(ObjectID{Type: BacnetDevice, Instance: 1001}, PropMaxAPDULengthAccepted, TypeUnsignedInt, uint64(206))
+	var configs []protocols.PointConfig
+	var keys []string
+	for i := 0; i < 40; i++ {
+		obj := ObjectID{Type: AnalogInput, Instance: uint32(i)}
+		sim.set(obj, PropPresentValue, TypeReal, float64(i)+0.5)
+		name := fmt.Sprintf("ai%d", i)
+		configs = append(configs, protocols.PointConfig{PointID: name, Address: fmt.Sprintf("analogInput:%d", i), Type: "float"})
+		keys = append(keys, name)
+	}
+	configs = append(configs, protocols.PointConfig{PointID: "ghost", Address: "analogInput:99", Type: "float"})
+	keys = append(keys, "ghost")
+
+	cfg := sim.config()
+	cfg["object_device"] = 1001
+	c := &BacnetClient{}
+	if err := c.Init(cfg); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	defer c.Close()
+	c.SetPointConfigs("sim", configs)
+	values, err := c.ReadBatch("sim", "", keys)
+	if err != nil {
+		t.Fatalf("ReadBatch failed: %v", err)
+	}
+	for i := 0; i < 40; i++ {
+		if values[i].Value != float64(i)+0.5 || values[i].Quality != "good" {
+			t.Errorf("%s = %v (%s)", values[i].PointID, values[i].Value, values[i].Quality)
+		}
+	}
+	if q := values[40].Quality; q != "bad:object:unknown-object" {
+		t.Errorf("ghost quality = %s", q)
+	}
+	// 206 字节的 APDU 放不下 41 个属性，需要多次 RPM 但远少于逐点读取
+	rpm, rp := sim.count(serviceReadPropertyMultiple), sim.count(serviceReadProperty)
+	if rpm < 2 || rpm > 6 || rp != 1 {
+		t.Errorf("expect a few RPM requests and one RP for max APDU, got %d RPM and %d RP", rpm, rp)
+	}
+
+	// 设备不支持 RPM 时回退逐点读取
+	sim.mu.Lock()
+	sim.noRPM = true
+	sim.requests = nil
+	sim.mu.Unlock()
+	values, err = c.ReadBatch("sim", "", keys[:3])
+	if err != nil || values[2].Value != 2.5 {
+		t.Fatalf("ReadBatch after fallback: %v %v", values, err)
+	}
+	if rpm, rp := sim.count(serviceReadPropertyMultiple), sim.count(serviceReadProperty); rpm != 1 || rp != 3 {
+		t.Errorf("expect one rejected RPM then 3 RP, got %d RPM and %d RP", rpm, rp)
+	}
+	if !c.rpmUnsupported {
+		t.Error("RPM should be marked unsupported")
+	}
+}
+
+func TestCOVSubscription(t *testing.T) {
+	for _, confirmed := range []bool{false, true} {
+		sim := newSimDevice(t)
+		ai0 := ObjectID{Type: AnalogInput, Instance: 0}
+		ai1 := ObjectID{Type: AnalogInput, Instance: 1}
+		sim.set(ai0, PropPresentValue, TypeReal, 20.0)
+		sim.set(ai1, PropPresentValue, TypeReal, 30.0)
+		sim.mu.Lock()
+		sim.covReject[ai1] = true
+		sim.mu.Unlock()
+
+		cfg := sim.config()
+		cfg["cov_lifetime"] = 1
+		cfg["cov_confirmed"] = confirmed
+		c := &BacnetClient{}
+		if err := c.Init(cfg); err != nil {
+			t.Fatalf("Init failed: %v", err)
+		}
+		pushed := make(chan protocols.PointValue, 10)
+		c.SetValueHandler("sim", func(values []protocols.PointValue) {
+			for _, v := range values {
+				pushed <- v
+			}
+		})
+		c.SetPointConfigs("sim", []protocols.PointConfig{
+			{PointID: "temp", Address: "analogInput:0", Type: "float", Options: map[string]interface{}{"cov": true}},
+			{PointID: "rejected", Address: "analogInput:1", Type: "float", Options: map[string]interface{}{"cov": true}},
+		})
+		expectPush := func(want float64) {
+			t.Helper()
+			select {
+			case v := <-pushed:
+				if v.PointID != "temp" || v.Value != want {
+					t.Errorf("confirmed=%v: pushed %s = %v, want temp = %v", confirmed, v.PointID, v.Value, want)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatalf("confirmed=%v: no COV notification for %v", confirmed, want)
+			}
+		}
+		expectPush(20.0)
+
+		sim.set(ai0, PropPresentValue, TypeReal, 21.5)
+		sim.notify(ai0)
+		expectPush(21.5)
+
+		// 订阅有效的点位用通知值，被拒绝的点位继续轮询
+		sim.mu.Lock()
+		sim.requests = nil
+		sim.mu.Unlock()
+		values, err := c.ReadBatch("sim", "", []string{"temp", "rejected"})
+		if err != nil || values[0].Value != 21.5 || values[1].Value != 30.0 {
+			t.Fatalf("ReadBatch: %v %v", values, err)
+		}
+		if n := sim.count(serviceReadProperty); n != 1 {
+			t.Errorf("confirmed=%v: expect only the rejected point polled, got %d reads", confirmed, n)
+		}
+
+		// 续订被拒绝后订阅到期，回到轮询
+		sim.mu.Lock()
+		sim.covReject[ai0] = true
+		sim.mu.Unlock()
+		sim.set(ai0, PropPresentValue, TypeReal, 25.0)
+		time.Sleep(1500 * time.Millisecond)
+		values, err = c.ReadBatch("sim", "", []string{"temp"})
+		if err != nil || values[0].Value != 25.0 {
+			t.Errorf("confirmed=%v: after expiry got %v %v, want polled 25", confirmed, values, err)
+		}
+		c.Close()
+	}
+}
+
+// TestCOVSlowHandler 回调阻塞时收包协程照常处理其他请求的应答
+func TestCOVSlowHandler(t *testing.T) {
+	sim := newSimDevice(t)
+	ai0 := ObjectID{Type: AnalogInput, Instance: 0}
+	ai1 := ObjectID{Type: AnalogInput, Instance: 1}
+	sim.set(ai0, PropPresentValue, TypeReal, 20.0)
+	sim.set(ai1, PropPresentValue, TypeReal, 30.0)
+	c := &BacnetClient{}
+	if err := c.Init(sim.config()); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	defer c.Close()
+	called, release := make(chan struct{}, 1), make(chan struct{})
+	defer close(release)
+	c.SetValueHandler("sim", func(values []protocols.PointValue) {
+		select {
+		case called <- struct{}{}:
+		default:
+		}
+		<-release
+	})
+	c.SetPointConfigs("sim", []protocols.PointConfig{
+		{PointID: "temp", Address: "analogInput:0", Type: "float", Options: map[string]interface{}{"cov": true}},
+		{PointID: "other", Address: "analogInput:1", Type: "float"},
+	})
+	select {
+	case <-called:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no COV notification")
+	}
+	sim.notify(ai0)
+	values, err := c.ReadBatch("sim", "", []string{"other"})
+	if err != nil || values[0].Value != 30.0 {
+		t.Errorf("read while handler blocked: %v %v", values, err)
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	sim := newSimDevice(t)
+	sim.instance = 1001
+	device := ObjectID{Type: BacnetDevice, Instance: 1001}
+	sim.set(device, PropObjectName, TypeCharacterString, "AHU-1")
+	sim.set(device, PropModelName, TypeCharacterString, "SimModel")
+	sim.set(device, PropVendorName, TypeCharacterString, "SimVendor")
+	sim.set(device, PropFirmwareRevision, TypeCharacterString, "1.2.3")
+
+	// Who-Is 直接发往模拟器（测试环境不走广播）
+	disc, err := NewDiscovery("127.0.0.1:0", sim.conn.LocalAddr().String(), 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewDiscovery failed: %v", err)
+	}
+	defer disc.Close()
+
+	devices, err := disc.Discover(2000, 3000, 200*time.Millisecond)
+	if err != nil || len(devices) != 0 {
+		t.Fatalf("out-of-range Who-Is: devices=%v err=%v", devices, err)
+	}
+	// 不支持 RPM 时逐个读取设备属性
+	sim.mu.Lock()
+	sim.noRPM = true
+	sim.mu.Unlock()
+	devices, err = disc.Discover(1000, 1001, 200*time.Millisecond)
+	if err != nil || len(devices) != 1 {
+		t.Fatalf("Discover: devices=%v err=%v", devices, err)
+	}
+	dev := devices[0]
+	if dev.DeviceID != 1001 || dev.VendorID != 260 || dev.MaxAPDU != maxAPDU || dev.Segmentation != 3 || dev.IP != "127.0.0.1" {
+		t.Errorf("unexpected I-Am fields: %+v", dev)
+	}
+	if dev.ObjectName != "AHU-1" || dev.ModelName != "SimModel" || dev.VendorName != "SimVendor" || dev.FirmwareRevision != "1.2.3" {
+		t.Errorf("unexpected device properties: %+v", dev)
+	}
+	// 重复的 I-Am 只刷新 LastSeen，注册表中仍只有一台设备
+	devices, _ = disc.Discover(-1, -1, 100*time.Millisecond)
+	if len(devices) != 1 {
+		t.Errorf("expected 1 device after repeated I-Am, got %d", len(devices))
+	}
+	if _, ok := disc.Device(1001); !ok {
+		t.Errorf("device 1001 not in registry")
+	}
+
+	for _, c := range []struct {
+		in        string
+		low, high int
+		ok        bool
+	}{{"", -1, -1, true}, {"1000-2000", 1000, 2000, true}, {"5", 5, 5, true}, {"9-1", 0, 0, false}, {"x", 0, 0, false}} {
+		low, high, err := ParseInstanceRange(c.in)
+		if (err == nil) != c.ok || (c.ok && (low != c.low || high != c.high)) {
+			t.Errorf("ParseInstanceRange(%q) = %d,%d,%v", c.in, low, high, err)
+		}
+	}
+}
+
+func TestGeneratePointSet(t *testing.T) {
+	sim := newSimDevice(t)
+	device := ObjectID{Type: BacnetDevice, Instance: 1001}
+	objects := []ObjectID{device}
+	for i := uint32(0); i < 30; i++ {
+		obj := ObjectID{Type: AnalogInput, Instance: i}
+		objects = append(objects, obj)
+		sim.set(obj, PropObjectName, TypeCharacterString, fmt.Sprintf("Zone Temp %d", i))
+		sim.set(obj, PropUnits, TypeEnumerated, 62)
+	}
+	sim.set(ObjectID{Type: AnalogInput, Instance: 0}, PropDescription, TypeCharacterString, "room temperature")
+	bv := ObjectID{Type: BinaryValue, Instance: 1}
+	objects = append(objects, bv, ObjectID{Type: NotificationClass, Instance: 1})
+	sim.set(bv, PropObjectName, TypeCharacterString, "Fan Enable")
+	var list []byte
+	for _, obj := range objects {
+		list = appendApplication(list, TypeObjectID, encodeObjectID(obj))
+	}
+	sim.mu.Lock()
+	sim.values[propertyRef{Object: device, Property: PropObjectList, Index: arrayAll}] = list
+	sim.maxResponse = 100 // 整个 objectList 超出应答长度，需按下标读取
+	sim.mu.Unlock()
+
+	conf := sim.config()
+	conf["object_device"] = 1001
+	conf["max_apdu"] = 480
+	c := &BacnetClient{}
+	if err := c.Init(conf); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	defer c.Close()
+	set, err := c.GeneratePointSet("ahu1", "bacnet_ahu1")
+	if err != nil {
+		t.Fatalf("GeneratePointSet failed: %v", err)
+	}
+	if len(set.Functions) != 2 || set.Functions[0].Function != "analogInput" || len(set.Functions[0].Points) != 30 ||
+		set.Functions[1].Function != "binaryValue" || len(set.Functions[1].Points) != 1 {
+		t.Fatalf("unexpected groups: %+v", set.Functions)
+	}
+	ai0 := set.Functions[0].Points[0]
+	if ai0.Name != "Zone_Temp_0" || ai0.Address != "analogInput:0" || ai0.Unit != "℃" || ai0.Type != "float" ||
+		ai0.Options["description"] != "room temperature" || ai0.Options["property_value_type"] != "REAL" {
+		t.Errorf("unexpected ai0: %+v", ai0)
+	}
+	fan := set.Functions[1].Points[0]
+	if fan.Name != "Fan_Enable" || fan.Type != "bool" || fan.Unit != "" || fan.Options["writable"] != true {
+		t.Errorf("unexpected binaryValue point: %+v", fan)
+	}
+
+	// 生成的文件可由 points.yaml 的加载方式直接读取
+	path := filepath.Join(t.TempDir(), "points.yaml")
+	if err := WritePointsToFile(path, []types.DevicePointSetV2{set}); err != nil {
+		t.Fatalf("WritePointsToFile failed: %v", err)
+	}
+	loaded, err := config.LoadPointMappingsV2(path)
+	if err != nil || len(loaded) != 1 || loaded[0].DeviceID != "ahu1" || len(loaded[0].Functions[0].Points) != 30 {
+		t.Fatalf("LoadPointMappingsV2: %+v, %v", loaded, err)
+	}
+	p := loaded[0].Functions[0].Points[0]
+	if p.Unit != "℃" || p.Options["object_type"] != "analogInput" || p.Options["instance"] != 0 {
+		t.Errorf("unexpected loaded point: %+v", p)
+	}
+}
+
+func TestWritePriority(t *testing.T) {
+	sim := newSimDevice(t)
+	ao := ObjectID{Type: AnalogOutput, Instance: 1}
+	bo := ObjectID{Type: BinaryOutput, Instance: 2}
+	sim.set(ao, PropRelinquishDefault, TypeReal, 20.0)
+	sim.set(bo, PropRelinquishDefault, TypeEnumerated, 0)
+	sim.mu.Lock()
+	sim.commandable[ao] = [16][]byte{}
+	sim.commandable[bo] = [16][]byte{}
+	sim.command(ao)
+	sim.command(bo)
+	sim.mu.Unlock()
+
+	conf := sim.config()
+	conf["points"] = []interface{}{
+		map[string]interface{}{"name": "valve", "address": "analogOutput:1", "writable": true, "priority": 8},
+		map[string]interface{}{"name": "pump", "address": "binaryOutput:2", "type": "bool", "writable": true},
+	}
+	c := &BacnetClient{}
+	if err := c.Init(conf); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	defer c.Close()
+
+	var _ protocols.PriorityCommander = c // 调试接口据此查看和释放优先级
+
+	// 点位配置的优先级 8，单次调用的优先级 5 覆盖之
+	if err := c.Write("valve", 55.0); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := c.WritePriority("valve", 80.0, 5); err != nil {
+		t.Fatalf("WritePriority failed: %v", err)
+	}
+	slots, err := c.ReadPriorityArray("valve")
+	if err != nil {
+		t.Fatalf("ReadPriorityArray failed: %v", err)
+	}
+	for i, v := range slots {
+		want := interface{}(nil)
+		switch i + 1 {
+		case 5:
+			want = 80.0
+		case 8:
+			want = 55.0
+		}
+		if v != want {
+			t.Errorf("priority %d = %v, want %v", i+1, v, want)
+		}
+	}
+	if vals, _ := c.ReadBatch("sim", "", []string{"valve"}); vals[0].Value != 80.0 {
+		t.Errorf("presentValue = %v, want 80", vals[0].Value)
+	}
+	// 释放优先级 5 后由优先级 8 接管，全部释放后回到 relinquishDefault
+	if err := c.Relinquish("valve", 5); err != nil {
+		t.Fatalf("Relinquish failed: %v", err)
+	}
+	if vals, _ := c.ReadBatch("sim", "", []string{"valve"}); vals[0].Value != 55.0 {
+		t.Errorf("presentValue after relinquish 5 = %v, want 55", vals[0].Value)
+	}
+	if err := c.Write("valve", nil); err != nil {
+		t.Fatalf("relinquish via Write failed: %v", err)
+	}
+	if vals, _ := c.ReadBatch("sim", "", []string{"valve"}); vals[0].Value != 20.0 {
+		t.Errorf("presentValue after relinquish 8 = %v, want relinquishDefault 20", vals[0].Value)
+	}
+
+	// 开关量输出：不带优先级的写入落在优先级 16，priorityArray 中的值按点位类型转为 bool
+	if err := c.Write("pump", true); err != nil {
+		t.Fatalf("Write pump failed: %v", err)
+	}
+	slots, err = c.ReadPriorityArray("pump")
+	if err != nil || slots[15] != true || slots[7] != nil {
+		t.Errorf("pump priorityArray = %v, %v", slots, err)
+	}
+	if err := c.WritePriority("pump", true, 17); err == nil {
+		t.Errorf("expected error for priority 17")
+	}
+}
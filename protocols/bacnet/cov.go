@@ -0,0 +1,415 @@
+package bacnet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"sensor-edge/protocols"
+)
+
+// covSubscription 一个 cov: true 点位的 COV 订阅。订阅有效且收到过通知时，轮询直接使用通知的值；
+// 订阅被拒绝或过期后自动回到轮询读取
+type covSubscription struct {
+	point     string
+	object    ObjectID
+	processID uint32
+	pointType string // 点位 type，bool 点位的枚举值转为 bool
+
+	active   bool      // 设备接受了订阅
+	expires  time.Time // 订阅到期时间，到期前续订
+	retryAt  time.Time // 订阅失败后的下次尝试时间
+	value    interface{}
+	hasValue bool
+	updated  time.Time
+}
+
+// valid 订阅有效且已有通知值
+func (s *covSubscription) valid(now time.Time) bool {
+	return s.active && s.hasValue && now.Before(s.expires)
+}
+
+// covNotification COV 通知（确认与非确认格式相同）
+type covNotification struct {
+	ProcessID     uint32
+	Device        ObjectID
+	Object        ObjectID
+	TimeRemaining uint32
+	Values        []propertyResult
+}
+
+// encodeSubscribeCOV lifetime 为 0 时编码为取消订阅（不携带 issueConfirmed 和 lifetime）
+func encodeSubscribeCOV(processID uint32, object ObjectID, confirmed bool, lifetime uint32) []byte {
+	buf := appendContextUnsigned(nil, 0, uint64(processID))
+	buf = appendContextObjectID(buf, 1, object)
+	if lifetime == 0 {
+		return buf
+	}
+	flag := byte(0)
+	if confirmed {
+		flag = 1
+	}
+	buf = appendContext(buf, 2, []byte{flag})
+	return appendContextUnsigned(buf, 3, uint64(lifetime))
+}
+
+// decodeSubscribeCOV 解析 SubscribeCOV 请求（服务端使用），cancel 表示取消订阅
+func decodeSubscribeCOV(data []byte) (processID uint32, object ObjectID, confirmed bool, lifetime uint32, cancel bool, err error) {
+	fields, err := decodeContextFields(data)
+	if err != nil {
+		return
+	}
+	pid, ok0 := fields[0]
+	obj, ok1 := fields[1]
+	if !ok0 || !ok1 {
+		err = fmt.Errorf("bacnet: missing parameters in SubscribeCOV")
+		return
+	}
+	processID = uint32(decodeUnsigned(pid))
+	if object, err = decodeObjectID(obj); err != nil {
+		return
+	}
+	flag, ok2 := fields[2]
+	life, ok3 := fields[3]
+	if !ok2 && !ok3 {
+		cancel = true
+		return
+	}
+	confirmed = len(flag) == 1 && flag[0] != 0
+	lifetime = uint32(decodeUnsigned(life))
+	return
+}
+
+// decodeContextFields 解析一串原始上下文标签，返回 标签号->内容
+func decodeContextFields(data []byte) (map[byte][]byte, error) {
+	fields := make(map[byte][]byte)
+	for len(data) > 0 {
+		t, n, err := decodeTag(data)
+		if err != nil {
+			return nil, err
+		}
+		if !t.context || t.opening || t.closing || len(data) < n+t.length {
+			return nil, fmt.Errorf("bacnet: unexpected tag %d", t.number)
+		}
+		fields[t.number] = data[n : n+t.length]
+		data = data[n+t.length:]
+	}
+	return fields, nil
+}
+
+// encodeCOVNotification 编码 COV 通知参数（服务端使用）
+func encodeCOVNotification(n covNotification) ([]byte, error) {
+	buf := appendContextUnsigned(nil, 0, uint64(n.ProcessID))
+	buf = appendContextObjectID(buf, 1, n.Device)
+	buf = appendContextObjectID(buf, 2, n.Object)
+	buf = appendContextUnsigned(buf, 3, uint64(n.TimeRemaining))
+	buf = encodeOpeningTag(buf, 4)
+	for _, r := range n.Values {
+		buf = appendContextUnsigned(buf, 0, uint64(r.Ref.Property))
+		if r.Ref.Index != arrayAll {
+			buf = appendContextUnsigned(buf, 1, uint64(r.Ref.Index))
+		}
+		buf = encodeOpeningTag(buf, 2)
+		for _, v := range r.Values {
+			b, err := encodeValue(v.Type, v.Value)
+			if err != nil {
+				return nil, err
+			}
+			buf = append(buf, b...)
+		}
+		buf = encodeClosingTag(buf, 2)
+	}
+	return encodeClosingTag(buf, 4), nil
+}
+
+// decodeCOVNotification [0]进程号 [1]发起设备 [2]被监视对象 [3]剩余时间 [4]{ [0]属性 [1]下标? [2]{值} [3]优先级? ... }
+func decodeCOVNotification(data []byte) (covNotification, error) {
+	var n covNotification
+	for i := byte(0); i < 4; i++ {
+		t, hl, err := decodeTag(data)
+		if err != nil || !t.isContext(i) || len(data) < hl+t.length {
+			return n, fmt.Errorf("bacnet: invalid COV notification")
+		}
+		content := data[hl : hl+t.length]
+		switch i {
+		case 0:
+			n.ProcessID = uint32(decodeUnsigned(content))
+		case 1:
+			n.Device, err = decodeObjectID(content)
+		case 2:
+			n.Object, err = decodeObjectID(content)
+		case 3:
+			n.TimeRemaining = uint32(decodeUnsigned(content))
+		}
+		if err != nil {
+			return n, err
+		}
+		data = data[hl+t.length:]
+	}
+	list, _, err := splitEnclosed(data, 4)
+	if err != nil {
+		return n, err
+	}
+	for len(list) > 0 {
+		r := propertyResult{Ref: propertyRef{Object: n.Object, Index: arrayAll}}
+		t, hl, err := decodeTag(list)
+		if err != nil || !t.isContext(0) || len(list) < hl+t.length {
+			return n, fmt.Errorf("bacnet: invalid COV value list")
+		}
+		r.Ref.Property = uint32(decodeUnsigned(list[hl : hl+t.length]))
+		list = list[hl+t.length:]
+		if t, hl, err := decodeTag(list); err == nil && t.isContext(1) && len(list) >= hl+t.length {
+			r.Ref.Index = uint32(decodeUnsigned(list[hl : hl+t.length]))
+			list = list[hl+t.length:]
+		}
+		content, l, err := splitEnclosed(list, 2)
+		if err != nil {
+			return n, err
+		}
+		if r.Values, err = decodeValues(content); err != nil {
+			return n, err
+		}
+		list = list[l:]
+		if t, hl, err := decodeTag(list); err == nil && t.isContext(3) && len(list) >= hl+t.length {
+			list = list[hl+t.length:]
+		}
+		n.Values = append(n.Values, r)
+	}
+	return n, nil
+}
+
+// SetValueHandler 注册 COV 通知的回调（实现 protocols.PointSubscriber）。
+// 共享客户端的多台设备各自注册，回调只会收到点位名，由采集主流程按设备点位过滤
+func (c *BacnetClient) SetValueHandler(deviceID string, handler func(values []protocols.PointValue)) {
+	c.covMu.Lock()
+	defer c.covMu.Unlock()
+	if c.valueHandlers == nil {
+		c.valueHandlers = make(map[string]func([]protocols.PointValue))
+	}
+	c.valueHandlers[deviceID] = handler
+}
+
+// syncCOV 为 cov: true 的 presentValue 点位建立订阅记录并启动续订循环，调用方持有 c.lock
+func (c *BacnetClient) syncCOV() {
+	if c.tr == nil {
+		return
+	}
+	c.covMu.Lock()
+	defer c.covMu.Unlock()
+	if c.covSubs == nil {
+		c.covSubs = make(map[string]*covSubscription)
+	}
+	for name, pt := range c.points {
+		if !pt.COV {
+			continue
+		}
+		ref, err := pt.propertyRef()
+		if err != nil || ref.Property != PropPresentValue {
+			log.Printf("[BACnet] point %s: COV only supported on presentValue", name)
+			continue
+		}
+		if sub, ok := c.covSubs[name]; ok && sub.object == ref.Object {
+			continue
+		}
+		c.nextProcessID++
+		c.covSubs[name] = &covSubscription{
+			point:     name,
+			object:    ref.Object,
+			processID: c.nextProcessID,
+			pointType: pt.Type,
+		}
+	}
+	if len(c.covSubs) > 0 && c.covStop == nil {
+		c.covStop = make(chan struct{})
+		c.covWake = make(chan struct{}, 1)
+		c.covPush = make(chan []protocols.PointValue, covPushQueue)
+		go c.covLoop(c.covStop, c.covWake)
+		go c.pushLoop(c.covStop, c.covPush)
+	}
+}
+
+// covLoop 订阅并在到期前续订；订阅失败的点位在半个生命周期后重试
+func (c *BacnetClient) covLoop(stop, wake chan struct{}) {
+	tick := c.covLifetime / 4
+	if tick < 100*time.Millisecond {
+		tick = 100 * time.Millisecond
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		c.renewCOV(stop)
+		select {
+		case <-stop:
+			return
+		case <-wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *BacnetClient) renewCOV(stop chan struct{}) {
+	now := time.Now()
+	c.covMu.Lock()
+	var due []*covSubscription
+	for _, sub := range c.covSubs {
+		if sub.active && sub.expires.Sub(now) > c.covLifetime/2 {
+			continue
+		}
+		if !sub.active && now.Before(sub.retryAt) {
+			continue
+		}
+		due = append(due, sub)
+	}
+	tr, peer := c.tr, c.peer
+	c.covMu.Unlock()
+
+	lifetime := uint32((c.covLifetime + time.Second - 1) / time.Second)
+	for _, sub := range due {
+		_, err := tr.request(peer, serviceSubscribeCOV, encodeSubscribeCOV(sub.processID, sub.object, c.covConfirmed, lifetime))
+		select {
+		case <-stop:
+			return
+		default:
+		}
+		c.covMu.Lock()
+		switch {
+		case err == nil:
+			sub.active = true
+			sub.expires = time.Now().Add(c.covLifetime)
+		case errors.Is(err, ErrTimeout) && sub.active:
+			// 设备暂时无应答，保留到期时间，到期后轮询接管
+			log.Printf("[BACnet] renew COV of %s on %s failed: %v", sub.object, c.deviceID, err)
+		default:
+			// 设备拒绝订阅，立即回到轮询
+			log.Printf("[BACnet] subscribe COV of %s on %s failed: %v, keep polling", sub.object, c.deviceID, err)
+			sub.active = false
+			sub.retryAt = now.Add(c.covLifetime / 2)
+		}
+		c.covMu.Unlock()
+	}
+}
+
+// handleRequest 处理设备发来的请求：COV 通知，其余确认请求回复 Reject
+func (c *BacnetClient) handleRequest(tr *transport, src *net.UDPAddr, a apdu) {
+	confirmed := a.pduType == pduConfirmedRequest
+	if (confirmed && a.service != serviceConfirmedCOVNotification) ||
+		(!confirmed && a.service != serviceUnconfirmedCOVNotification) {
+		if confirmed {
+			tr.send(src, encodeReject(a.invokeID, 9), false)
+		}
+		return
+	}
+	n, err := decodeCOVNotification(a.data)
+	if err != nil {
+		if confirmed {
+			tr.send(src, encodeReject(a.invokeID, 4), false)
+		}
+		return
+	}
+	if confirmed {
+		tr.send(src, encodeSimpleAck(a.invokeID, a.service), false)
+	}
+	c.onNotification(n)
+}
+
+// onNotification 在收包协程中更新订阅值，回调交给 pushLoop，不阻塞同一端口上其他请求的应答
+func (c *BacnetClient) onNotification(n covNotification) {
+	var values []protocols.PointValue
+	c.covMu.Lock()
+	defer c.covMu.Unlock()
+	for _, sub := range c.covSubs {
+		if sub.processID != n.ProcessID || sub.object != n.Object {
+			continue
+		}
+		if n.TimeRemaining == 0 {
+			// 订阅已被设备结束，回到轮询并尽快重新订阅
+			sub.active = false
+			continue
+		}
+		sub.expires = time.Now().Add(time.Duration(n.TimeRemaining) * time.Second)
+		for _, r := range n.Values {
+			if r.Ref.Property != PropPresentValue {
+				continue
+			}
+			sub.value = pointValue(BacnetPoint{Type: sub.pointType}, r.Values)
+			sub.hasValue = true
+			sub.updated = time.Now()
+			values = append(values, protocols.PointValue{PointID: sub.point, Value: sub.value, Quality: "good", Timestamp: sub.updated.Unix()})
+		}
+	}
+	if len(values) == 0 || c.covPush == nil {
+		return
+	}
+	select {
+	case c.covPush <- values:
+	default:
+		// 回调积压，丢弃本次推送；值已缓存，下一次轮询照常上报
+		log.Printf("[BACnet] COV push queue of %s full, drop %d values", c.deviceID, len(values))
+	}
+}
+
+// covPushQueue 等待回调的 COV 通知数
+const covPushQueue = 64
+
+// pushLoop 依次把 COV 通知的值交给回调，慢的北向上报只阻塞本协程
+func (c *BacnetClient) pushLoop(stop chan struct{}, push chan []protocols.PointValue) {
+	for {
+		select {
+		case <-stop:
+			return
+		case values := <-push:
+			c.covMu.Lock()
+			handlers := make([]func([]protocols.PointValue), 0, len(c.valueHandlers))
+			for _, h := range c.valueHandlers {
+				handlers = append(handlers, h)
+			}
+			c.covMu.Unlock()
+			for _, h := range handlers {
+				h(values)
+			}
+		}
+	}
+}
+
+// covValue 订阅有效时返回通知的值，供轮询跳过网络读取
+func (c *BacnetClient) covValue(point string) (interface{}, time.Time, bool) {
+	c.covMu.Lock()
+	defer c.covMu.Unlock()
+	sub, ok := c.covSubs[point]
+	if !ok || !sub.valid(time.Now()) {
+		return nil, time.Time{}, false
+	}
+	return sub.value, sub.updated, true
+}
+
+// resetCOV 重连后本地端口变化，已有订阅作废，立即重新订阅
+func (c *BacnetClient) resetCOV() {
+	c.covMu.Lock()
+	for _, sub := range c.covSubs {
+		sub.active = false
+		sub.retryAt = time.Time{}
+	}
+	wake := c.covWake
+	c.covMu.Unlock()
+	if wake != nil {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// stopCOV 停止续订，设备上的订阅在生命周期结束后自动失效
+func (c *BacnetClient) stopCOV() {
+	c.covMu.Lock()
+	defer c.covMu.Unlock()
+	if c.covStop != nil {
+		close(c.covStop)
+		c.covStop = nil
+	}
+	for _, sub := range c.covSubs {
+		sub.active = false
+	}
+}
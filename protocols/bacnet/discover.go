@@ -1,271 +1,280 @@
 package bacnet
 
 import (
-	"encoding/binary"
 	"fmt"
-	"net"
+	"log"
 	"os"
-	"sync"
-	"time"
+	"strconv"
+	"strings"
+
+	"sensor-edge/protocols"
+	"sensor-edge/types"
 
 	"gopkg.in/yaml.v3"
 )
 
-type DeviceInfo struct {
-	DeviceID  uint32
-	VendorID  uint16
-	ModelName string
-	IP        string
-}
-
-type Device struct {
-	ID        string
-	IP        string
-	DeviceID  uint32
-	VendorID  uint16
-	Online    bool
-	FailCount int
-	Points    []string // 可扩展为 config.PointItem
+// ObjectInfo 设备中一个对象的名称、描述和工程单位
+type ObjectInfo struct {
+	Object      ObjectID
+	Name        string
+	Description string
+	Units       string // 可读单位，无 units 属性的对象为空
 }
 
-var devMap = map[string]*Device{}
-var mu sync.RWMutex
-
-// ObjectIdentifier BACnet对象标识
-// 可根据实际协议栈结构调整
-// 这里只做示例
-//
-//	type ObjectIdentifier struct {
-//		ObjectType string
-//		Instance   uint32
-//	}
-type ObjectIdentifier struct {
-	ObjectType string
-	Instance   uint32
+// pointObjectTypes 可生成采集点位的对象类型：点位类型和 presentValue 的值类型
+var pointObjectTypes = map[ObjectType]struct {
+	pointType string
+	valueType string
+}{
+	AnalogInput:          {"float", "REAL"},
+	AnalogOutput:         {"float", "REAL"},
+	AnalogValue:          {"float", "REAL"},
+	LargeAnalogValue:     {"float64", "DOUBLE"},
+	BinaryInput:          {"bool", "ENUMERATED"},
+	BinaryOutput:         {"bool", "ENUMERATED"},
+	BinaryValue:          {"bool", "ENUMERATED"},
+	MultiStateInput:      {"int", "UNSIGNED"},
+	MultiStateOutput:     {"int", "UNSIGNED"},
+	MultiStateValue:      {"int", "UNSIGNED"},
+	Accumulator:          {"int", "UNSIGNED"},
+	PulseConverter:       {"float", "REAL"},
+	IntegerValue:         {"int", "INTEGER"},
+	PositiveIntegerValue: {"int", "UNSIGNED"},
+	CharacterstringValue: {"string", "CHARACTERSTRING"},
 }
 
-type PointModel struct {
-	Name       string `yaml:"name"`
-	Address    string `yaml:"address"`
-	ObjectType string `yaml:"object_type"`
-	Instance   uint32 `yaml:"instance"`
-	Property   string `yaml:"property"`
-	Type       string `yaml:"type"`
-	Unit       string `yaml:"unit,omitempty"`
-	Writable   bool   `yaml:"writable"`
-}
-
-// ListenIAM 监听 UDP 47808 接收 I-Am 报文并自动注册设备
-func ListenIAM(port int, handler func(info *DeviceInfo)) error {
-	addr := net.UDPAddr{Port: port, IP: net.IPv4zero}
-	conn, err := net.ListenUDP("udp", &addr)
-	if err != nil {
-		return err
+// hasUnits 带 units 属性的对象类型
+func hasUnits(t ObjectType) bool {
+	switch t {
+	case AnalogInput, AnalogOutput, AnalogValue, LargeAnalogValue, Accumulator, PulseConverter,
+		IntegerValue, PositiveIntegerValue:
+		return true
 	}
-	defer conn.Close()
+	return false
+}
 
-	buf := make([]byte, 2048)
-	for {
-		n, remote, err := conn.ReadFromUDP(buf)
-		if err != nil {
-			continue
-		}
-		info, err := parseIAM(buf[:n])
-		if err == nil {
-			info.IP = remote.IP.String()
-			handler(info)
-		}
+// deviceObject 本设备的设备对象，未配置数字实例号时使用通配实例
+func (c *BacnetClient) deviceObject() ObjectID {
+	instance := uint32(wildcardDevice)
+	if n, err := strconv.ParseUint(c.deviceID, 10, 22); err == nil {
+		instance = uint32(n)
 	}
+	return ObjectID{Type: BacnetDevice, Instance: instance}
 }
 
-// parseIAM 解析 I-Am 报文，提取 DeviceID/VendorID
-func parseIAM(data []byte) (*DeviceInfo, error) {
-	if len(data) < 14 {
-		return nil, fmt.Errorf("invalid length")
+// ReadObjectList 读取设备对象的 objectList。设备无法一次返回整个数组（不支持分段、应答过长）时，
+// 先读下标 0 得到数组长度，再按下标读取各元素（设备支持时用 ReadPropertyMultiple 分组）
+func (c *BacnetClient) ReadObjectList() ([]ObjectID, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.tr == nil {
+		return nil, fmt.Errorf("bacnet: device %s is not a BACnet/IP device", c.deviceID)
 	}
-	// 简单判断：APDU type 0x10，service 0x00（I-Am）
-	if data[1] != 0x10 && data[4] != 0xC4 {
-		return nil, fmt.Errorf("not I-Am")
+	ref := propertyRef{Object: c.deviceObject(), Property: PropObjectList, Index: arrayAll}
+	_, values, err := c.readProperty(ref)
+	if err == nil {
+		return objectIDs(values)
 	}
-	apdu := data[6:]
-	if apdu[0] != 0x10 || apdu[1] != 0x00 {
-		return nil, fmt.Errorf("not unconfirmed I-Am")
+	if !responseTooLong(err) {
+		return nil, err
 	}
-	deviceID := binary.BigEndian.Uint32(apdu[2:6])
-	vendorID := binary.BigEndian.Uint16(apdu[6:8])
-	return &DeviceInfo{
-		DeviceID:  deviceID,
-		VendorID:  vendorID,
-		ModelName: "Unknown",
-	}, nil
-}
-
-// ParseIAm 解析 I-Am 报文，提取 DeviceInfo
-func ParseIAm(buf []byte, srcIP net.IP) (*DeviceInfo, error) {
-	// 这里只做简单模拟，实际应解析NPDU/APDU结构
-	if len(buf) < 14 {
-		return nil, fmt.Errorf("I-Am too short")
+	log.Printf("[BACnet] objectList of %s too long (%v), read by index", c.deviceID, err)
+	ref.Index = 0
+	_, values, err = c.readProperty(ref)
+	if err != nil {
+		return nil, err
 	}
-	// 假设 buf[1] == 0x10 表示 UnconfirmedRequest，buf[4] == 0xC4 表示 I-Am
-	if buf[1] != 0x10 || buf[4] != 0xC4 {
-		return nil, fmt.Errorf("not I-Am")
+	if len(values) != 1 {
+		return nil, fmt.Errorf("bacnet: invalid objectList length")
 	}
-	// 模拟解析 DeviceID、VendorID
-	deviceID := uint32(buf[8])<<24 | uint32(buf[9])<<16 | uint32(buf[10])<<8 | uint32(buf[11])
-	vendorID := uint16(buf[12])<<8 | uint16(buf[13])
-	return &DeviceInfo{
-		IP:        srcIP.String(),
-		DeviceID:  deviceID,
-		VendorID:  vendorID,
-		ModelName: "Unknown",
-	}, nil
-}
-
-// RegisterDevice 自动注册新设备并启动采集
-func RegisterDevice(info *DeviceInfo) {
-	mu.Lock()
-	defer mu.Unlock()
-	devKey := fmt.Sprintf("bacnet_%d", info.DeviceID)
-	if _, exists := devMap[devKey]; exists {
-		return
+	count := int(toUint(values[0].Value))
+	items := make([]readItem, count)
+	for i := range items {
+		items[i] = readItem{index: i, ref: propertyRef{Object: ref.Object, Property: PropObjectList, Index: uint32(i + 1)}}
 	}
-	dev := &Device{
-		ID:       devKey,
-		IP:       info.IP,
-		DeviceID: info.DeviceID,
-		VendorID: info.VendorID,
-		Online:   true,
-	}
-	devMap[devKey] = dev
-	fmt.Printf("[DISCOVER] New device %s at %s (vendor %d)\n", dev.ID, dev.IP, dev.VendorID)
-	go startPolling(dev)
-}
-
-// RegisterAndStartPolling 自动注册设备并启动点位模板生成和采集任务
-func RegisterAndStartPolling(info *DeviceInfo) {
-	key := fmt.Sprintf("bacnet_%d", info.DeviceID)
-	mu.Lock()
-	if _, ok := devMap[key]; ok {
-		mu.Unlock()
-		return
+	result := badResults(count)
+	if err := c.readItems(items, result); err != nil {
+		return nil, err
 	}
-	dev := &Device{
-		ID:       key,
-		IP:       info.IP,
-		DeviceID: info.DeviceID,
-		VendorID: info.VendorID,
-		Online:   true,
+	list := make([]ObjectID, 0, count)
+	for i, r := range result {
+		id, ok := r.Value.(ObjectID)
+		if r.Quality != "good" || !ok {
+			return nil, fmt.Errorf("bacnet: read objectList[%d] failed: %s", i+1, r.Quality)
+		}
+		list = append(list, id)
 	}
-	devMap[key] = dev
-	mu.Unlock()
-	fmt.Printf("[REGISTER] Device %s (%s)\n", key, dev.IP)
-	// 自动生成点位模板
-	go AutoGeneratePoints(dev)
-	// 启动采集任务（可根据实际点位结构完善）
-	go startPolling(dev)
+	return list, nil
 }
 
-// startPolling 启动简单采集任务
-func startPolling(dev *Device) {
-	ticker := time.NewTicker(5 * time.Second)
-	defer ticker.Stop()
-	for {
-		select {
-		case <-ticker.C:
-			if err := pollOnce(dev); err != nil {
-				dev.FailCount++
-				if dev.FailCount >= 3 {
-					dev.Online = false
-					fmt.Printf("[OFFLINE] %s\n", dev.ID)
-					return
-				}
-			} else {
-				dev.FailCount = 0
-				dev.Online = true
-			}
+func objectIDs(values []PropertyValue) ([]ObjectID, error) {
+	list := make([]ObjectID, 0, len(values))
+	for _, v := range values {
+		id, ok := v.Value.(ObjectID)
+		if !ok {
+			return nil, fmt.Errorf("bacnet: unexpected %v in objectList", v.Type)
 		}
+		list = append(list, id)
 	}
+	return list, nil
 }
 
-func pollOnce(dev *Device) error {
-	// TODO: 替换为真实读点
-	fmt.Printf("[POLL] Device %s (%s) is alive\n", dev.ID, dev.IP)
+// readItems 读取一组属性，结果写入 result 中对应位置（质量码与点位读取相同）
+func (c *BacnetClient) readItems(items []readItem, result []protocols.PointValue) error {
+	if len(items) > 1 && !c.rpmUnsupported {
+		return c.readMultiple(items, result)
+	}
+	for _, item := range items {
+		if err := c.readSingle(item, result); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-// ReadObjectList 读取objectList属性，返回对象列表（本地模拟/占位实现，无 go-bacnet 依赖）
-func ReadObjectList(dev *Device) ([]ObjectIdentifier, error) {
-	// 这里直接返回模拟数据，或后续对接你自己的 BACnetClient 实现
-	return []ObjectIdentifier{
-		{"analogInput", 0},
-		{"analogInput", 1},
-		{"binaryInput", 2},
-	}, nil
+func badResults(n int) []protocols.PointValue {
+	result := make([]protocols.PointValue, n)
+	for i := range result {
+		result[i].Quality = "bad"
+	}
+	return result
 }
 
-// AutoGeneratePoints 自动生成点位模板并写入yaml
-func AutoGeneratePoints(dev *Device) {
-	objectList, err := ReadObjectList(dev)
+// ReadObjects 读取 objectList 中可作为点位的对象的 objectName、description 和 units。
+// 设备没有的可选属性（如 description）留空
+func (c *BacnetClient) ReadObjects() ([]ObjectInfo, error) {
+	list, err := c.ReadObjectList()
 	if err != nil {
-		fmt.Printf("[POINT-GEN] Read objectList failed: %v\n", err)
-		return
+		return nil, err
 	}
-	points := make([]PointModel, 0)
-	for _, obj := range objectList {
-		name := fmt.Sprintf("%s_%d", obj.ObjectType, obj.Instance)
-		address := fmt.Sprintf("%s:%d", obj.ObjectType, obj.Instance)
-		prop := "presentValue"
-		pType := GuessValueType(obj.ObjectType)
-		points = append(points, PointModel{
-			Name:       name,
-			Address:    address,
-			ObjectType: obj.ObjectType,
-			Instance:   obj.Instance,
-			Property:   prop,
-			Type:       pType,
-			Writable:   IsWritable(obj.ObjectType),
-		})
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	var objects []ObjectInfo
+	var items []readItem
+	for _, obj := range list {
+		if _, ok := pointObjectTypes[obj.Type]; !ok {
+			continue
+		}
+		props := []uint32{PropObjectName, PropDescription}
+		if hasUnits(obj.Type) {
+			props = append(props, PropUnits)
+		}
+		for _, p := range props {
+			items = append(items, readItem{index: len(items), ref: propertyRef{Object: obj, Property: p, Index: arrayAll}})
+		}
+		objects = append(objects, ObjectInfo{Object: obj})
 	}
-	err = WritePointsToFile(dev.ID, points)
-	if err != nil {
-		fmt.Printf("[POINT-GEN] Write failed: %v\n", err)
+	result := badResults(len(items))
+	if err := c.readItems(items, result); err != nil {
+		return nil, err
+	}
+	byObject := make(map[ObjectID]*ObjectInfo, len(objects))
+	for i := range objects {
+		byObject[objects[i].Object] = &objects[i]
+	}
+	for _, item := range items {
+		r := result[item.index]
+		if r.Quality != "good" {
+			continue
+		}
+		info := byObject[item.ref.Object]
+		switch item.ref.Property {
+		case PropObjectName:
+			info.Name = fmt.Sprint(r.Value)
+		case PropDescription:
+			info.Description = fmt.Sprint(r.Value)
+		case PropUnits:
+			info.Units = UnitName(uint32(toUint(r.Value)))
+		}
 	}
+	return objects, nil
 }
 
-// WritePointsToFile 写入点位模板到yaml
-func WritePointsToFile(devID string, points []PointModel) error {
-	filePath := fmt.Sprintf("bacnet_points_%s.yaml", devID)
-	data := map[string]interface{}{
-		"device_id": devID,
-		"protocol":  "bacnet",
-		"functions": map[string][]PointModel{},
+// GeneratePointSet 按设备的对象生成点位配置：按对象类型分组，点位名取 objectName，
+// 结构与 points.yaml 一致，可直接由 config.LoadPointMappingsV2 加载
+func (c *BacnetClient) GeneratePointSet(deviceID, protocolName string) (types.DevicePointSetV2, error) {
+	set := types.DevicePointSetV2{DeviceID: deviceID, Protocol: "bacnet", ProtocolName: protocolName}
+	objects, err := c.ReadObjects()
+	if err != nil {
+		return set, err
 	}
-	funcMap := data["functions"].(map[string][]PointModel)
-	for _, pt := range points {
-		fn := pt.ObjectType
-		funcMap[fn] = append(funcMap[fn], pt)
+	groups := make(map[string]int)
+	used := make(map[string]bool)
+	for _, obj := range objects {
+		typeName := obj.Object.Type.String()
+		kind := pointObjectTypes[obj.Object.Type]
+		name := pointName(obj.Name)
+		if name == "" || used[name] {
+			name = fmt.Sprintf("%s_%d", typeName, obj.Object.Instance)
+		}
+		used[name] = true
+		options := map[string]interface{}{
+			"object_type":         typeName,
+			"instance":            int(obj.Object.Instance),
+			"property":            "presentValue",
+			"property_value_type": kind.valueType,
+			"writable":            IsWritable(typeName),
+		}
+		if obj.Description != "" {
+			options["description"] = obj.Description
+		}
+		if deviceID != "" {
+			options["id"] = deviceID + "." + name
+		}
+		idx, ok := groups[typeName]
+		if !ok {
+			idx = len(set.Functions)
+			groups[typeName] = idx
+			set.Functions = append(set.Functions, types.FunctionPointGroup{Function: typeName})
+		}
+		set.Functions[idx].Points = append(set.Functions[idx].Points, types.PointMapping{
+			Address: obj.Object.String(),
+			Name:    name,
+			Type:    kind.pointType,
+			Unit:    obj.Units,
+			Options: options,
+		})
 	}
-	out, err := yaml.Marshal(data)
+	return set, nil
+}
+
+// pointName 由 objectName 得到点位名：空白和分隔符替换为下划线
+func pointName(objectName string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '.', ':', '/', '\\', '-':
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(objectName))
+}
+
+// WritePointsToFile 把生成的点位配置写入 yaml 文件（points.yaml 格式）
+func WritePointsToFile(path string, sets []types.DevicePointSetV2) error {
+	out, err := yaml.Marshal(sets)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(filePath, out, 0644)
+	return os.WriteFile(path, out, 0644)
 }
 
 func GuessValueType(objType string) string {
-	switch objType {
-	case "analogInput", "analogValue":
-		return "float"
-	case "binaryInput", "binaryValue":
-		return "bool"
-	default:
+	t, err := ParseObjectType(objType)
+	if err != nil {
 		return "float"
 	}
+	if kind, ok := pointObjectTypes[t]; ok {
+		return kind.pointType
+	}
+	return "float"
 }
 
+// IsWritable 输出和值对象的 presentValue 可写（输出对象可命令，写入带优先级）
 func IsWritable(objType string) bool {
 	switch objType {
-	case "analogValue", "binaryValue", "multiStateValue":
+	case "analogOutput", "binaryOutput", "multiStateOutput",
+		"analogValue", "binaryValue", "multiStateValue",
+		"largeAnalogValue", "integerValue", "positiveIntegerValue", "characterstringValue":
 		return true
 	default:
 		return false
@@ -0,0 +1,272 @@
+package bacnet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPort BACnet/IP 默认 UDP 端口
+const DefaultPort = 47808
+
+// DeviceInfo Who-Is/I-Am 发现到的设备，名称等信息来自设备对象的属性
+type DeviceInfo struct {
+	DeviceID         uint32
+	IP               string
+	Port             int
+	MaxAPDU          int
+	Segmentation     int // 0 both, 1 transmit, 2 receive, 3 no-segmentation
+	VendorID         uint16
+	ObjectName       string
+	ModelName        string
+	VendorName       string
+	FirmwareRevision string
+	LastSeen         time.Time
+}
+
+// Discovery 设备发现服务：一个 UDP 端口上广播 Who-Is、接收 I-Am（含设备主动广播的 I-Am），
+// 发现的设备保存在同一个注册表中，新设备自动读取名称、型号、厂商和固件版本
+type Discovery struct {
+	tr        *transport
+	broadcast *net.UDPAddr
+
+	mu        sync.RWMutex
+	devices   map[uint32]*DeviceInfo
+	onDevice  func(DeviceInfo)
+	enriching sync.WaitGroup
+}
+
+// NewDiscovery listen 为本地地址（默认 :47808，设备的 I-Am 通常广播到该端口），
+// broadcast 为 Who-Is 的目的地址（默认 255.255.255.255:47808）
+func NewDiscovery(listen, broadcast string, timeout time.Duration) (*Discovery, error) {
+	if listen == "" {
+		listen = fmt.Sprintf(":%d", DefaultPort)
+	}
+	if broadcast == "" {
+		broadcast = fmt.Sprintf("255.255.255.255:%d", DefaultPort)
+	}
+	baddr, err := net.ResolveUDPAddr("udp4", broadcast)
+	if err != nil {
+		return nil, fmt.Errorf("bacnet: invalid broadcast address %s: %v", broadcast, err)
+	}
+	tr, err := newTransport(listen, timeout, 1)
+	if err != nil {
+		return nil, fmt.Errorf("bacnet: listen %s failed: %v", listen, err)
+	}
+	d := &Discovery{tr: tr, broadcast: baddr, devices: make(map[uint32]*DeviceInfo)}
+	tr.setHandler(d.handle)
+	return d, nil
+}
+
+// OnDevice 设置新设备（读取完属性后）的回调
+func (d *Discovery) OnDevice(fn func(DeviceInfo)) {
+	d.mu.Lock()
+	d.onDevice = fn
+	d.mu.Unlock()
+}
+
+// WhoIs 广播 Who-Is，low/high 小于 0 时不限实例号范围
+func (d *Discovery) WhoIs(low, high int) error {
+	var params []byte
+	if low >= 0 && high >= 0 {
+		params = appendContextUnsigned(params, 0, uint64(low))
+		params = appendContextUnsigned(params, 1, uint64(high))
+	}
+	return d.tr.send(d.broadcast, encodeUnconfirmedRequest(serviceWhoIs, params), true)
+}
+
+// Discover 广播 Who-Is 并等待 wait，返回注册表中的全部设备（含新设备的属性）
+func (d *Discovery) Discover(low, high int, wait time.Duration) ([]DeviceInfo, error) {
+	if err := d.WhoIs(low, high); err != nil {
+		return nil, err
+	}
+	time.Sleep(wait)
+	d.enriching.Wait()
+	return d.Devices(), nil
+}
+
+// Devices 注册表中的设备，按实例号排序
+func (d *Discovery) Devices() []DeviceInfo {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	out := make([]DeviceInfo, 0, len(d.devices))
+	for _, dev := range d.devices {
+		out = append(out, *dev)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].DeviceID < out[j].DeviceID })
+	return out
+}
+
+// Device 按实例号查询设备
+func (d *Discovery) Device(id uint32) (DeviceInfo, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	dev, ok := d.devices[id]
+	if !ok {
+		return DeviceInfo{}, false
+	}
+	return *dev, true
+}
+
+func (d *Discovery) Close() error {
+	return d.tr.close()
+}
+
+func (d *Discovery) handle(src *net.UDPAddr, a apdu) {
+	if a.pduType != pduUnconfirmedRequest || a.service != serviceIAm {
+		if a.pduType == pduConfirmedRequest {
+			d.tr.send(src, encodeReject(a.invokeID, 9), false)
+		}
+		return
+	}
+	info, err := decodeIAm(a.data)
+	if err != nil {
+		log.Printf("[BACnet] invalid I-Am from %s: %v", src, err)
+		return
+	}
+	info.IP, info.Port, info.LastSeen = src.IP.String(), src.Port, time.Now()
+	d.mu.Lock()
+	old, ok := d.devices[info.DeviceID]
+	if ok && old.IP == info.IP && old.Port == info.Port {
+		old.LastSeen = info.LastSeen
+		d.mu.Unlock()
+		return
+	}
+	d.devices[info.DeviceID] = &info
+	d.enriching.Add(1)
+	d.mu.Unlock()
+	log.Printf("[BACnet] discovered device %d at %s:%d (vendor %d)", info.DeviceID, info.IP, info.Port, info.VendorID)
+	go d.enrich(info.DeviceID, &net.UDPAddr{IP: src.IP, Port: src.Port})
+}
+
+// enrich 读取设备对象的 objectName/modelName/vendorName/firmwareRevision，
+// 优先一次 ReadPropertyMultiple，不支持时逐个 ReadProperty
+func (d *Discovery) enrich(id uint32, peer *net.UDPAddr) {
+	defer d.enriching.Done()
+	device := ObjectID{Type: BacnetDevice, Instance: id}
+	props := []uint32{PropObjectName, PropModelName, PropVendorName, PropFirmwareRevision}
+	values := make(map[uint32]string)
+	refs := make([]propertyRef, len(props))
+	for i, p := range props {
+		refs[i] = propertyRef{Object: device, Property: p, Index: arrayAll}
+	}
+	data, err := d.tr.request(peer, serviceReadPropertyMultiple, encodeReadPropertyMultiple(refs))
+	var results []propertyResult
+	if err == nil {
+		results, err = decodeReadPropertyMultipleAck(data)
+	}
+	if err == nil {
+		for _, r := range results {
+			if r.Err == nil && len(r.Values) == 1 {
+				values[r.Ref.Property] = fmt.Sprint(r.Values[0].Value)
+			}
+		}
+	} else if !errors.Is(err, ErrTimeout) {
+		for _, ref := range refs {
+			data, err := d.tr.request(peer, serviceReadProperty, encodeReadProperty(ref))
+			if err != nil {
+				if errors.Is(err, ErrTimeout) {
+					break
+				}
+				continue
+			}
+			if _, vs, err := decodeReadPropertyAck(data); err == nil && len(vs) == 1 {
+				values[ref.Property] = fmt.Sprint(vs[0].Value)
+			}
+		}
+	}
+	if len(values) == 0 {
+		log.Printf("[BACnet] read properties of device %d failed: %v", id, err)
+	}
+	d.mu.Lock()
+	dev, ok := d.devices[id]
+	if ok {
+		dev.ObjectName = values[PropObjectName]
+		dev.ModelName = values[PropModelName]
+		dev.VendorName = values[PropVendorName]
+		dev.FirmwareRevision = values[PropFirmwareRevision]
+	}
+	var info DeviceInfo
+	if ok {
+		info = *dev
+	}
+	fn := d.onDevice
+	d.mu.Unlock()
+	if ok && fn != nil {
+		fn(info)
+	}
+}
+
+// encodeIAm I-Am 参数：设备对象标识、max-APDU、分段能力、厂商 ID（均为应用标签）
+func encodeIAm(info DeviceInfo) []byte {
+	buf := appendApplication(nil, TypeObjectID, encodeObjectID(ObjectID{Type: BacnetDevice, Instance: info.DeviceID}))
+	buf = appendApplication(buf, TypeUnsignedInt, unsignedBytes(uint64(info.MaxAPDU)))
+	buf = appendApplication(buf, TypeEnumerated, unsignedBytes(uint64(info.Segmentation)))
+	return appendApplication(buf, TypeUnsignedInt, unsignedBytes(uint64(info.VendorID)))
+}
+
+func decodeIAm(data []byte) (DeviceInfo, error) {
+	values, err := decodeValues(data)
+	if err != nil {
+		return DeviceInfo{}, err
+	}
+	if len(values) != 4 || values[0].Type != TypeObjectID || values[1].Type != TypeUnsignedInt ||
+		values[2].Type != TypeEnumerated || values[3].Type != TypeUnsignedInt {
+		return DeviceInfo{}, fmt.Errorf("bacnet: malformed I-Am")
+	}
+	id := values[0].Value.(ObjectID)
+	if id.Type != BacnetDevice {
+		return DeviceInfo{}, fmt.Errorf("bacnet: I-Am for non-device object %s", id)
+	}
+	return DeviceInfo{
+		DeviceID:     id.Instance,
+		MaxAPDU:      int(toUint(values[1].Value)),
+		Segmentation: int(toUint(values[2].Value)),
+		VendorID:     uint16(toUint(values[3].Value)),
+	}, nil
+}
+
+// decodeWhoIs 解析 Who-Is 的实例号范围，无范围时返回 -1,-1
+func decodeWhoIs(data []byte) (low, high int, err error) {
+	if len(data) == 0 {
+		return -1, -1, nil
+	}
+	fields, err := decodeContextFields(data)
+	if err != nil {
+		return 0, 0, err
+	}
+	l, ok0 := fields[0]
+	h, ok1 := fields[1]
+	if !ok0 || !ok1 {
+		return 0, 0, fmt.Errorf("bacnet: malformed Who-Is range")
+	}
+	return int(decodeUnsigned(l)), int(decodeUnsigned(h)), nil
+}
+
+// ParseInstanceRange 解析 "1000-2000" 形式的实例号范围，空串表示不限（返回 -1,-1）
+func ParseInstanceRange(s string) (low, high int, err error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return -1, -1, nil
+	}
+	parts := strings.SplitN(s, "-", 2)
+	if low, err = strconv.Atoi(strings.TrimSpace(parts[0])); err != nil {
+		return 0, 0, fmt.Errorf("bacnet: invalid instance range %q", s)
+	}
+	high = low
+	if len(parts) == 2 {
+		if high, err = strconv.Atoi(strings.TrimSpace(parts[1])); err != nil {
+			return 0, 0, fmt.Errorf("bacnet: invalid instance range %q", s)
+		}
+	}
+	if low < 0 || high < low || high > wildcardDevice {
+		return 0, 0, fmt.Errorf("bacnet: invalid instance range %q", s)
+	}
+	return low, high, nil
+}
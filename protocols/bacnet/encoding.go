@@ -0,0 +1,479 @@
+package bacnet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// BACnet 标签编码（ASHRAE 135 第20.2节）：标签头为 标签号(4位) | 类别(1位，1=上下文标签) | 长度/值/类型(3位)，
+// 应用标签号与 PropertyValueType 的取值一一对应
+
+var errShortData = errors.New("bacnet: data too short")
+
+// tag 解码后的标签头
+type tag struct {
+	number  byte
+	context bool
+	opening bool
+	closing bool
+	length  int // 数据长度；应用标签 Boolean 时为值本身
+}
+
+// isContext 是否为指定编号的上下文标签（不含开闭标签）
+func (t tag) isContext(n byte) bool {
+	return t.context && !t.opening && !t.closing && t.number == n
+}
+
+func (t tag) isOpening(n byte) bool { return t.opening && t.number == n }
+func (t tag) isClosing(n byte) bool { return t.closing && t.number == n }
+
+func encodeTag(buf []byte, number byte, context bool, length int) []byte {
+	var first byte
+	if context {
+		first = 0x08
+	}
+	var ext []byte
+	if number <= 14 {
+		first |= number << 4
+	} else {
+		first |= 0xF0
+		ext = append(ext, number)
+	}
+	switch {
+	case length <= 4:
+		first |= byte(length)
+	case length <= 253:
+		first |= 5
+		ext = append(ext, byte(length))
+	case length <= 65535:
+		first |= 5
+		ext = append(ext, 254, byte(length>>8), byte(length))
+	default:
+		first |= 5
+		ext = append(ext, 255, byte(length>>24), byte(length>>16), byte(length>>8), byte(length))
+	}
+	buf = append(buf, first)
+	return append(buf, ext...)
+}
+
+// 开闭标签只用于编号 0~14 的上下文标签
+func encodeOpeningTag(buf []byte, number byte) []byte {
+	return append(buf, number<<4|0x0E)
+}
+
+func encodeClosingTag(buf []byte, number byte) []byte {
+	return append(buf, number<<4|0x0F)
+}
+
+// decodeTag 解析标签头，返回标签和标签头长度
+func decodeTag(data []byte) (tag, int, error) {
+	if len(data) < 1 {
+		return tag{}, 0, errShortData
+	}
+	t := tag{number: data[0] >> 4, context: data[0]&0x08 != 0}
+	n := 1
+	if t.number == 0x0F {
+		if len(data) < 2 {
+			return t, 0, errShortData
+		}
+		t.number = data[1]
+		n++
+	}
+	lvt := data[0] & 0x07
+	switch {
+	case t.context && lvt == 6:
+		t.opening = true
+	case t.context && lvt == 7:
+		t.closing = true
+	case lvt == 5:
+		if len(data) < n+1 {
+			return t, 0, errShortData
+		}
+		switch data[n] {
+		case 254:
+			if len(data) < n+3 {
+				return t, 0, errShortData
+			}
+			t.length = int(binary.BigEndian.Uint16(data[n+1:]))
+			n += 3
+		case 255:
+			if len(data) < n+5 {
+				return t, 0, errShortData
+			}
+			t.length = int(binary.BigEndian.Uint32(data[n+1:]))
+			n += 5
+		default:
+			t.length = int(data[n])
+			n++
+		}
+	default:
+		t.length = int(lvt)
+	}
+	return t, n, nil
+}
+
+// contentLength 标签内容占用的字节数（Boolean 应用标签的值在标签头中）
+func (t tag) contentLength() int {
+	if t.opening || t.closing || (!t.context && t.number == byte(TypeBoolean)) {
+		return 0
+	}
+	return t.length
+}
+
+// unsignedBytes 无符号数的最短大端编码
+func unsignedBytes(v uint64) []byte {
+	n := 1
+	for n < 8 && v >= 1<<(8*uint(n)) {
+		n++
+	}
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return b
+}
+
+// signedBytes 有符号数的最短大端补码编码
+func signedBytes(v int64) []byte {
+	n := 1
+	for n < 8 && (v < -(1<<(8*uint(n)-1)) || v >= 1<<(8*uint(n)-1)) {
+		n++
+	}
+	b := make([]byte, n)
+	u := uint64(v)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte(u)
+		u >>= 8
+	}
+	return b
+}
+
+func decodeUnsigned(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func decodeSigned(b []byte) int64 {
+	if len(b) == 0 {
+		return 0
+	}
+	v := int64(int8(b[0]))
+	for _, c := range b[1:] {
+		v = v<<8 | int64(c)
+	}
+	return v
+}
+
+func encodeObjectID(id ObjectID) []byte {
+	return binary.BigEndian.AppendUint32(nil, uint32(id.Type)<<22|id.Instance&0x3FFFFF)
+}
+
+func decodeObjectID(b []byte) (ObjectID, error) {
+	if len(b) != 4 {
+		return ObjectID{}, fmt.Errorf("bacnet: invalid object identifier length %d", len(b))
+	}
+	v := binary.BigEndian.Uint32(b)
+	return ObjectID{Type: ObjectType(v >> 22), Instance: v & 0x3FFFFF}, nil
+}
+
+func appendContext(buf []byte, number byte, content []byte) []byte {
+	return append(encodeTag(buf, number, true, len(content)), content...)
+}
+
+func appendContextUnsigned(buf []byte, number byte, v uint64) []byte {
+	return appendContext(buf, number, unsignedBytes(v))
+}
+
+func appendContextObjectID(buf []byte, number byte, id ObjectID) []byte {
+	return appendContext(buf, number, encodeObjectID(id))
+}
+
+func appendApplication(buf []byte, t PropertyValueType, content []byte) []byte {
+	return append(encodeTag(buf, byte(t), false, len(content)), content...)
+}
+
+// encodeValue 按应用标签编码属性值。数值类型接受任意 Go 数值；
+// Enumerated 额外接受 bool（开关量 presentValue：active=1/inactive=0）
+func encodeValue(t PropertyValueType, value interface{}) ([]byte, error) {
+	switch t {
+	case TypeNull:
+		return []byte{0x00}, nil
+	case TypeBoolean:
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("bacnet: BOOLEAN expects bool, got %T", value)
+		}
+		if b {
+			return []byte{0x11}, nil
+		}
+		return []byte{0x10}, nil
+	case TypeUnsignedInt, TypeEnumerated:
+		if b, ok := value.(bool); ok && t == TypeEnumerated {
+			value = 0
+			if b {
+				value = 1
+			}
+		}
+		f, ok := toFloat(value)
+		if !ok || f < 0 || f != math.Trunc(f) || f > math.MaxUint32 {
+			return nil, fmt.Errorf("bacnet: invalid unsigned value %v", value)
+		}
+		return appendApplication(nil, t, unsignedBytes(uint64(f))), nil
+	case TypeSignedInt:
+		f, ok := toFloat(value)
+		if !ok || f != math.Trunc(f) || f < math.MinInt32 || f > math.MaxInt32 {
+			return nil, fmt.Errorf("bacnet: invalid signed value %v", value)
+		}
+		return appendApplication(nil, t, signedBytes(int64(f))), nil
+	case TypeReal:
+		f, ok := toFloat(value)
+		if !ok {
+			return nil, fmt.Errorf("bacnet: REAL expects number, got %T", value)
+		}
+		return appendApplication(nil, t, binary.BigEndian.AppendUint32(nil, math.Float32bits(float32(f)))), nil
+	case TypeDouble:
+		f, ok := toFloat(value)
+		if !ok {
+			return nil, fmt.Errorf("bacnet: DOUBLE expects number, got %T", value)
+		}
+		return appendApplication(nil, t, binary.BigEndian.AppendUint64(nil, math.Float64bits(f))), nil
+	case TypeOctetString:
+		b, ok := value.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("bacnet: OCTETSTRING expects []byte, got %T", value)
+		}
+		return appendApplication(nil, t, b), nil
+	case TypeCharacterString:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("bacnet: CHARACTERSTRING expects string, got %T", value)
+		}
+		return appendApplication(nil, t, append([]byte{0x00}, s...)), nil // 字符集 0 = UTF-8
+	case TypeBitString:
+		bits, ok := value.([]bool)
+		if !ok {
+			return nil, fmt.Errorf("bacnet: BITSTRING expects []bool, got %T", value)
+		}
+		content := make([]byte, 1+(len(bits)+7)/8)
+		content[0] = byte((8 - len(bits)%8) % 8) // 末字节未使用的位数
+		for i, b := range bits {
+			if b {
+				content[1+i/8] |= 0x80 >> (uint(i) % 8)
+			}
+		}
+		return appendApplication(nil, t, content), nil
+	case TypeDate:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("bacnet: DATE expects string, got %T", value)
+		}
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return nil, fmt.Errorf("bacnet: invalid date %q", s)
+		}
+		wd := byte(d.Weekday())
+		if wd == 0 {
+			wd = 7
+		}
+		return appendApplication(nil, t, []byte{byte(d.Year() - 1900), byte(d.Month()), byte(d.Day()), wd}), nil
+	case TypeTime:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("bacnet: TIME expects string, got %T", value)
+		}
+		var h, m, sec, hs int
+		if n, _ := fmt.Sscanf(strings.Replace(s, ".", ":", 1), "%d:%d:%d:%d", &h, &m, &sec, &hs); n < 2 {
+			return nil, fmt.Errorf("bacnet: invalid time %q", s)
+		}
+		return appendApplication(nil, t, []byte{byte(h), byte(m), byte(sec), byte(hs)}), nil
+	case TypeObjectID:
+		id, ok := value.(ObjectID)
+		if !ok {
+			return nil, fmt.Errorf("bacnet: OBJECTID expects ObjectID, got %T", value)
+		}
+		return appendApplication(nil, t, encodeObjectID(id)), nil
+	}
+	return nil, fmt.Errorf("bacnet: unsupported value type %d", t)
+}
+
+// decodeApplicationValue 解码一个应用标签值，返回值和消耗的字节数。Go 类型约定：
+// Unsigned→uint64、Signed/Enumerated→int、Real/Double→float64、CharacterString/Date/Time→string、
+// OctetString→[]byte、BitString→[]bool、ObjectIdentifier→ObjectID
+func decodeApplicationValue(data []byte) (PropertyValue, int, error) {
+	t, n, err := decodeTag(data)
+	if err != nil {
+		return PropertyValue{}, 0, err
+	}
+	if t.context {
+		return PropertyValue{}, 0, fmt.Errorf("bacnet: unexpected context tag %d", t.number)
+	}
+	l := t.contentLength()
+	if len(data) < n+l {
+		return PropertyValue{}, 0, errShortData
+	}
+	b := data[n : n+l]
+	pv := PropertyValue{Type: PropertyValueType(t.number)}
+	switch pv.Type {
+	case TypeNull:
+	case TypeBoolean:
+		pv.Value = t.length != 0
+	case TypeUnsignedInt:
+		pv.Value = decodeUnsigned(b)
+	case TypeSignedInt:
+		pv.Value = int(decodeSigned(b))
+	case TypeEnumerated:
+		pv.Value = int(decodeUnsigned(b))
+	case TypeReal:
+		if l != 4 {
+			return pv, 0, fmt.Errorf("bacnet: invalid REAL length %d", l)
+		}
+		pv.Value = float32ToFloat64(math.Float32frombits(binary.BigEndian.Uint32(b)))
+	case TypeDouble:
+		if l != 8 {
+			return pv, 0, fmt.Errorf("bacnet: invalid DOUBLE length %d", l)
+		}
+		pv.Value = math.Float64frombits(binary.BigEndian.Uint64(b))
+	case TypeOctetString:
+		pv.Value = append([]byte(nil), b...)
+	case TypeCharacterString:
+		pv.Value = decodeCharacterString(b)
+	case TypeBitString:
+		if l == 0 {
+			pv.Value = []bool{}
+			break
+		}
+		count := 8*(l-1) - int(b[0]&0x07)
+		bits := make([]bool, 0, count)
+		for i := 0; i < count; i++ {
+			bits = append(bits, b[1+i/8]&(0x80>>(uint(i)%8)) != 0)
+		}
+		pv.Value = bits
+	case TypeDate:
+		if l != 4 {
+			return pv, 0, fmt.Errorf("bacnet: invalid DATE length %d", l)
+		}
+		year := "*"
+		if b[0] != 0xFF {
+			year = strconv.Itoa(1900 + int(b[0]))
+		}
+		pv.Value = fmt.Sprintf("%s-%s-%s", year, wildcard(b[1], 2), wildcard(b[2], 2))
+	case TypeTime:
+		if l != 4 {
+			return pv, 0, fmt.Errorf("bacnet: invalid TIME length %d", l)
+		}
+		pv.Value = fmt.Sprintf("%s:%s:%s.%s", wildcard(b[0], 2), wildcard(b[1], 2), wildcard(b[2], 2), wildcard(b[3], 2))
+	case TypeObjectID:
+		id, err := decodeObjectID(b)
+		if err != nil {
+			return pv, 0, err
+		}
+		pv.Value = id
+	default:
+		return pv, 0, fmt.Errorf("bacnet: unsupported application tag %d", t.number)
+	}
+	return pv, n + l, nil
+}
+
+// decodeValues 解码开闭标签之间的全部应用标签值（数组、列表属性会有多个值）
+func decodeValues(data []byte) ([]PropertyValue, error) {
+	var values []PropertyValue
+	for len(data) > 0 {
+		pv, n, err := decodeApplicationValue(data)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, pv)
+		data = data[n:]
+	}
+	return values, nil
+}
+
+// splitEnclosed 取出编号为 number 的开闭标签之间的内容（可嵌套），返回内容和包含开闭标签的总长度
+func splitEnclosed(data []byte, number byte) ([]byte, int, error) {
+	t, n, err := decodeTag(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !t.isOpening(number) {
+		return nil, 0, fmt.Errorf("bacnet: expect opening tag %d", number)
+	}
+	depth := 0
+	for pos := n; pos < len(data); {
+		t, hl, err := decodeTag(data[pos:])
+		if err != nil {
+			return nil, 0, err
+		}
+		switch {
+		case t.opening:
+			depth++
+		case t.closing:
+			if depth == 0 {
+				if t.number != number {
+					return nil, 0, fmt.Errorf("bacnet: unbalanced closing tag %d", t.number)
+				}
+				return data[n:pos], pos + hl, nil
+			}
+			depth--
+		}
+		pos += hl + t.contentLength()
+	}
+	return nil, 0, fmt.Errorf("bacnet: missing closing tag %d", number)
+}
+
+// decodeCharacterString 按字符集解码：0 UTF-8，5 ISO-8859-1，其他字符集尽量按 UTF-8 处理
+func decodeCharacterString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	charset, s := b[0], b[1:]
+	if charset == 5 || (charset != 0 && !utf8.Valid(s)) {
+		r := make([]rune, len(s))
+		for i, c := range s {
+			r[i] = rune(c)
+		}
+		return string(r)
+	}
+	return string(s)
+}
+
+func wildcard(v byte, width int) string {
+	if v == 0xFF {
+		return "*"
+	}
+	return fmt.Sprintf("%0*d", width, v)
+}
+
+// float32ToFloat64 按 float32 的最短十进制表示转换，避免 21.1 变成 21.100000381
+func float32ToFloat64(f float32) float64 {
+	v, err := strconv.ParseFloat(strconv.FormatFloat(float64(f), 'g', -1, 32), 64)
+	if err != nil {
+		return float64(f)
+	}
+	return v
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32:
+		return float32ToFloat64(float32(rv.Float())), true
+	case reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,106 @@
+package bacnet
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var (
+	// ErrTimeout 重试次数用尽仍未收到应答
+	ErrTimeout = errors.New("bacnet: request timed out")
+	// ErrSegmentationNotSupported 应答需要分段传输，本实现不支持分段
+	ErrSegmentationNotSupported = errors.New("bacnet: segmentation not supported")
+)
+
+// Error 对端返回的 BACnet-Error-PDU
+type Error struct {
+	Class uint32
+	Code  uint32
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("bacnet: error class=%s code=%s", e.ClassName(), e.CodeName())
+}
+
+var errorClassNames = []string{"device", "object", "property", "resources", "security", "services", "vt", "communication"}
+
+var errorCodeNames = map[uint32]string{
+	0:  "other",
+	2:  "configuration-in-progress",
+	3:  "device-busy",
+	7:  "inconsistent-parameters",
+	9:  "invalid-data-type",
+	16: "missing-required-parameter",
+	20: "no-space-to-write-property",
+	25: "operational-problem",
+	27: "read-access-denied",
+	29: "service-request-denied",
+	30: "timeout",
+	31: "unknown-object",
+	32: "unknown-property",
+	36: "unsupported-object-type",
+	37: "value-out-of-range",
+	40: "write-access-denied",
+	42: "invalid-array-index",
+	43: "cov-subscription-failed",
+	44: "not-cov-property",
+	45: "optional-functionality-not-supported",
+	47: "datatype-not-supported",
+	50: "property-is-not-an-array",
+}
+
+func (e *Error) ClassName() string {
+	if int(e.Class) < len(errorClassNames) {
+		return errorClassNames[e.Class]
+	}
+	return strconv.FormatUint(uint64(e.Class), 10)
+}
+
+func (e *Error) CodeName() string {
+	if name, ok := errorCodeNames[e.Code]; ok {
+		return name
+	}
+	return strconv.FormatUint(uint64(e.Code), 10)
+}
+
+// RejectError 对端拒绝请求（BACnet-Reject-PDU），通常是请求格式或服务不被支持
+type RejectError struct {
+	Reason byte
+}
+
+var rejectReasonNames = []string{"other", "buffer-overflow", "inconsistent-parameters", "invalid-parameter-data-type",
+	"invalid-tag", "missing-required-parameter", "parameter-out-of-range", "too-many-arguments",
+	"undefined-enumeration", "unrecognized-service"}
+
+func (e *RejectError) Error() string {
+	reason := strconv.Itoa(int(e.Reason))
+	if int(e.Reason) < len(rejectReasonNames) {
+		reason = rejectReasonNames[e.Reason]
+	}
+	return "bacnet: request rejected: " + reason
+}
+
+// AbortError 事务被中止（BACnet-Abort-PDU），Server 表示由对端发起
+type AbortError struct {
+	Reason byte
+	Server bool
+}
+
+const abortSegmentationNotSupported = 4
+
+var abortReasonNames = []string{"other", "buffer-overflow", "invalid-apdu-in-this-state",
+	"preempted-by-higher-priority-task", "segmentation-not-supported"}
+
+func (e *AbortError) Error() string {
+	reason := strconv.Itoa(int(e.Reason))
+	if int(e.Reason) < len(abortReasonNames) {
+		reason = abortReasonNames[e.Reason]
+	}
+	return "bacnet: transaction aborted: " + reason
+}
+
+// Is 使 errors.Is(err, ErrSegmentationNotSupported) 对原因为 segmentation-not-supported 的中止成立
+func (e *AbortError) Is(target error) bool {
+	return target == ErrSegmentationNotSupported && e.Reason == abortSegmentationNotSupported
+}
@@ -0,0 +1,235 @@
+package bacnet
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// objectTypeNames 对象类型的标准名称（驼峰写法，与 points.yaml 的 object_type 一致）
+var objectTypeNames = map[ObjectType]string{
+	AnalogInput:           "analogInput",
+	AnalogOutput:          "analogOutput",
+	AnalogValue:           "analogValue",
+	BinaryInput:           "binaryInput",
+	BinaryOutput:          "binaryOutput",
+	BinaryValue:           "binaryValue",
+	Calendar:              "calendar",
+	Command:               "command",
+	BacnetDevice:          "device",
+	EventEnrollment:       "eventEnrollment",
+	File:                  "file",
+	Group:                 "group",
+	Loop:                  "loop",
+	MultiStateInput:       "multiStateInput",
+	MultiStateOutput:      "multiStateOutput",
+	NotificationClass:     "notificationClass",
+	Program:               "program",
+	Schedule:              "schedule",
+	Averaging:             "averaging",
+	MultiStateValue:       "multiStateValue",
+	Trendlog:              "trendLog",
+	LifeSafetyPoint:       "lifeSafetyPoint",
+	LifeSafetyZone:        "lifeSafetyZone",
+	Accumulator:           "accumulator",
+	PulseConverter:        "pulseConverter",
+	EventLog:              "eventLog",
+	GlobalGroup:           "globalGroup",
+	TrendLogMultiple:      "trendLogMultiple",
+	LoadControl:           "loadControl",
+	StructuredView:        "structuredView",
+	AccessDoor:            "accessDoor",
+	Timer:                 "timer",
+	AccessCredential:      "accessCredential",
+	AccessPoint:           "accessPoint",
+	AccessRights:          "accessRights",
+	AccessUser:            "accessUser",
+	AccessZone:            "accessZone",
+	CredentialDataInput:   "credentialDataInput",
+	NetworkSecurity:       "networkSecurity",
+	BitstringValue:        "bitstringValue",
+	CharacterstringValue:  "characterstringValue",
+	DatePatternValue:      "datePatternValue",
+	DateValue:             "dateValue",
+	DatetimePatternValue:  "datetimePatternValue",
+	DatetimeValue:         "datetimeValue",
+	IntegerValue:          "integerValue",
+	LargeAnalogValue:      "largeAnalogValue",
+	OctetstringValue:      "octetstringValue",
+	PositiveIntegerValue:  "positiveIntegerValue",
+	TimePatternValue:      "timePatternValue",
+	TimeValue:             "timeValue",
+	NotificationForwarder: "notificationForwarder",
+	AlertEnrollment:       "alertEnrollment",
+	Channel:               "channel",
+	LightingOutput:        "lightingOutput",
+	BinaryLightingOutput:  "binaryLightingOutput",
+	NetworkPort:           "networkPort",
+}
+
+func (t ObjectType) String() string {
+	if name, ok := objectTypeNames[t]; ok {
+		return name
+	}
+	return strconv.Itoa(int(t))
+}
+
+func (id ObjectID) String() string {
+	return fmt.Sprintf("%s:%d", id.Type, id.Instance)
+}
+
+// 常用属性标识符
+const (
+	PropAckedTransitions          uint32 = 0
+	PropActiveText                uint32 = 4
+	PropAll                       uint32 = 8
+	PropAPDUTimeout               uint32 = 11
+	PropApplicationSoftwareVer    uint32 = 12
+	PropCOVIncrement              uint32 = 22
+	PropDescription               uint32 = 28
+	PropDeviceAddressBinding      uint32 = 30
+	PropEventState                uint32 = 36
+	PropFirmwareRevision          uint32 = 44
+	PropInactiveText              uint32 = 46
+	PropLocation                  uint32 = 58
+	PropMaxAPDULengthAccepted     uint32 = 62
+	PropMaxPresValue              uint32 = 65
+	PropMinPresValue              uint32 = 69
+	PropModelName                 uint32 = 70
+	PropNumberOfAPDURetries       uint32 = 73
+	PropNumberOfStates            uint32 = 74
+	PropObjectIdentifier          uint32 = 75
+	PropObjectList                uint32 = 76
+	PropObjectName                uint32 = 77
+	PropObjectType                uint32 = 79
+	PropOptional                  uint32 = 80
+	PropOutOfService              uint32 = 81
+	PropPolarity                  uint32 = 84
+	PropPresentValue              uint32 = 85
+	PropPriorityArray             uint32 = 87
+	PropProtocolObjectTypes       uint32 = 96
+	PropProtocolServicesSupported uint32 = 97
+	PropProtocolVersion           uint32 = 98
+	PropReliability               uint32 = 103
+	PropRelinquishDefault         uint32 = 104
+	PropRequired                  uint32 = 105
+	PropResolution                uint32 = 106
+	PropSegmentationSupported     uint32 = 107
+	PropStateText                 uint32 = 110
+	PropStatusFlags               uint32 = 111
+	PropSystemStatus              uint32 = 112
+	PropUnits                     uint32 = 117
+	PropVendorIdentifier          uint32 = 120
+	PropVendorName                uint32 = 121
+	PropProtocolRevision          uint32 = 139
+	PropDatabaseRevision          uint32 = 155
+)
+
+var propertyNames = map[uint32]string{
+	PropAckedTransitions:          "ackedTransitions",
+	PropActiveText:                "activeText",
+	PropAll:                       "all",
+	PropAPDUTimeout:               "apduTimeout",
+	PropApplicationSoftwareVer:    "applicationSoftwareVersion",
+	PropCOVIncrement:              "covIncrement",
+	PropDescription:               "description",
+	PropDeviceAddressBinding:      "deviceAddressBinding",
+	PropEventState:                "eventState",
+	PropFirmwareRevision:          "firmwareRevision",
+	PropInactiveText:              "inactiveText",
+	PropLocation:                  "location",
+	PropMaxAPDULengthAccepted:     "maxApduLengthAccepted",
+	PropMaxPresValue:              "maxPresValue",
+	PropMinPresValue:              "minPresValue",
+	PropModelName:                 "modelName",
+	PropNumberOfAPDURetries:       "numberOfApduRetries",
+	PropNumberOfStates:            "numberOfStates",
+	PropObjectIdentifier:          "objectIdentifier",
+	PropObjectList:                "objectList",
+	PropObjectName:                "objectName",
+	PropObjectType:                "objectType",
+	PropOptional:                  "optional",
+	PropOutOfService:              "outOfService",
+	PropPolarity:                  "polarity",
+	PropPresentValue:              "presentValue",
+	PropPriorityArray:             "priorityArray",
+	PropProtocolObjectTypes:       "protocolObjectTypesSupported",
+	PropProtocolServicesSupported: "protocolServicesSupported",
+	PropProtocolVersion:           "protocolVersion",
+	PropReliability:               "reliability",
+	PropRelinquishDefault:         "relinquishDefault",
+	PropRequired:                  "required",
+	PropResolution:                "resolution",
+	PropSegmentationSupported:     "segmentationSupported",
+	PropStateText:                 "stateText",
+	PropStatusFlags:               "statusFlags",
+	PropSystemStatus:              "systemStatus",
+	PropUnits:                     "units",
+	PropVendorIdentifier:          "vendorIdentifier",
+	PropVendorName:                "vendorName",
+	PropProtocolRevision:          "protocolRevision",
+	PropDatabaseRevision:          "databaseRevision",
+}
+
+// normalizeName 名称比较时忽略大小写、连字符和下划线（analog-input、analog_input、analogInput 等价）
+func normalizeName(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.ReplaceAll(s, "-", "")
+	return strings.ReplaceAll(s, "_", "")
+}
+
+// ParseObjectType 解析对象类型名称或数字
+func ParseObjectType(s string) (ObjectType, error) {
+	if n, err := strconv.ParseUint(strings.TrimSpace(s), 10, 16); err == nil && n <= uint64(Proprietarymax) {
+		return ObjectType(n), nil
+	}
+	key := normalizeName(s)
+	for t, name := range objectTypeNames {
+		if normalizeName(name) == key {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("bacnet: unknown object type %q", s)
+}
+
+// ParseObjectID 解析 "analogValue:1" 形式的对象标识
+func ParseObjectID(s string) (ObjectID, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 2 {
+		return ObjectID{}, fmt.Errorf("bacnet: invalid object %q, expect type:instance", s)
+	}
+	t, err := ParseObjectType(parts[0])
+	if err != nil {
+		return ObjectID{}, err
+	}
+	n, err := strconv.ParseUint(strings.TrimSpace(parts[1]), 10, 32)
+	if err != nil || n >= wildcardDevice {
+		return ObjectID{}, fmt.Errorf("bacnet: invalid object instance %q", parts[1])
+	}
+	return ObjectID{Type: t, Instance: uint32(n)}, nil
+}
+
+// ParseProperty 解析属性名称或数字，空串为 presentValue
+func ParseProperty(s string) (uint32, error) {
+	if strings.TrimSpace(s) == "" {
+		return PropPresentValue, nil
+	}
+	if n, err := strconv.ParseUint(strings.TrimSpace(s), 10, 22); err == nil {
+		return uint32(n), nil
+	}
+	key := normalizeName(s)
+	for id, name := range propertyNames {
+		if normalizeName(name) == key {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("bacnet: unknown property %q", s)
+}
+
+// PropertyName 属性标识符的名称，未收录的返回数字
+func PropertyName(id uint32) string {
+	if name, ok := propertyNames[id]; ok {
+		return name
+	}
+	return strconv.FormatUint(uint64(id), 10)
+}
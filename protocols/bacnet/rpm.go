@@ -0,0 +1,351 @@
+package bacnet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"sensor-edge/protocols"
+)
+
+// wildcardDevice 设备对象的通配实例号，ReadProperty 时表示“应答方自己”
+const wildcardDevice = 4194303
+
+// defaultDeviceAPDU 无法获取设备 max-APDU 时按 BACnet 最常见的 MS/TP 路由器上限分组
+const defaultDeviceAPDU = 480
+
+// propertyResult ReadPropertyMultiple-ACK 中一个属性的结果，Err 非空表示该属性读取失败
+type propertyResult struct {
+	Ref    propertyRef
+	Values []PropertyValue
+	Err    *Error
+}
+
+// encodeReadPropertyMultiple 相邻的同一对象的属性合并到一个 ReadAccessSpecification
+func encodeReadPropertyMultiple(refs []propertyRef) []byte {
+	var buf []byte
+	for i, ref := range refs {
+		if i == 0 || refs[i-1].Object != ref.Object {
+			if i > 0 {
+				buf = encodeClosingTag(buf, 1)
+			}
+			buf = appendContextObjectID(buf, 0, ref.Object)
+			buf = encodeOpeningTag(buf, 1)
+		}
+		buf = appendContextUnsigned(buf, 0, uint64(ref.Property))
+		if ref.Index != arrayAll {
+			buf = appendContextUnsigned(buf, 1, uint64(ref.Index))
+		}
+	}
+	if len(refs) > 0 {
+		buf = encodeClosingTag(buf, 1)
+	}
+	return buf
+}
+
+// decodeReadPropertyMultiple 解析 ReadPropertyMultiple 请求（服务端使用）
+func decodeReadPropertyMultiple(data []byte) ([]propertyRef, error) {
+	var refs []propertyRef
+	for len(data) > 0 {
+		t, n, err := decodeTag(data)
+		if err != nil || !t.isContext(0) || len(data) < n+t.length {
+			return nil, fmt.Errorf("bacnet: missing object identifier in RPM request")
+		}
+		obj, err := decodeObjectID(data[n : n+t.length])
+		if err != nil {
+			return nil, err
+		}
+		list, l, err := splitEnclosed(data[n+t.length:], 1)
+		if err != nil {
+			return nil, err
+		}
+		data = data[n+t.length+l:]
+		for len(list) > 0 {
+			ref := propertyRef{Object: obj, Index: arrayAll}
+			t, n, err := decodeTag(list)
+			if err != nil || !t.isContext(0) || len(list) < n+t.length {
+				return nil, fmt.Errorf("bacnet: missing property identifier in RPM request")
+			}
+			ref.Property = uint32(decodeUnsigned(list[n : n+t.length]))
+			list = list[n+t.length:]
+			if t, n, err := decodeTag(list); err == nil && t.isContext(1) && len(list) >= n+t.length {
+				ref.Index = uint32(decodeUnsigned(list[n : n+t.length]))
+				list = list[n+t.length:]
+			}
+			refs = append(refs, ref)
+		}
+	}
+	return refs, nil
+}
+
+// encodeReadPropertyMultipleAck 编码 ReadPropertyMultiple-ACK（服务端使用）
+func encodeReadPropertyMultipleAck(results []propertyResult) ([]byte, error) {
+	var buf []byte
+	for i, r := range results {
+		if i == 0 || results[i-1].Ref.Object != r.Ref.Object {
+			if i > 0 {
+				buf = encodeClosingTag(buf, 1)
+			}
+			buf = appendContextObjectID(buf, 0, r.Ref.Object)
+			buf = encodeOpeningTag(buf, 1)
+		}
+		buf = appendContextUnsigned(buf, 2, uint64(r.Ref.Property))
+		if r.Ref.Index != arrayAll {
+			buf = appendContextUnsigned(buf, 3, uint64(r.Ref.Index))
+		}
+		if r.Err != nil {
+			buf = encodeOpeningTag(buf, 5)
+			buf = appendApplication(buf, TypeEnumerated, unsignedBytes(uint64(r.Err.Class)))
+			buf = appendApplication(buf, TypeEnumerated, unsignedBytes(uint64(r.Err.Code)))
+			buf = encodeClosingTag(buf, 5)
+			continue
+		}
+		buf = encodeOpeningTag(buf, 4)
+		for _, v := range r.Values {
+			b, err := encodeValue(v.Type, v.Value)
+			if err != nil {
+				return nil, err
+			}
+			buf = append(buf, b...)
+		}
+		buf = encodeClosingTag(buf, 4)
+	}
+	if len(results) > 0 {
+		buf = encodeClosingTag(buf, 1)
+	}
+	return buf, nil
+}
+
+// decodeReadPropertyMultipleAck 解析 ReadPropertyMultiple-ACK：
+// [0]对象 [1]{ [2]属性 [3]下标? ([4]{值} | [5]{错误类别 错误代码}) ... }
+func decodeReadPropertyMultipleAck(data []byte) ([]propertyResult, error) {
+	var results []propertyResult
+	for len(data) > 0 {
+		t, n, err := decodeTag(data)
+		if err != nil || !t.isContext(0) || len(data) < n+t.length {
+			return nil, fmt.Errorf("bacnet: missing object identifier in RPM ack")
+		}
+		obj, err := decodeObjectID(data[n : n+t.length])
+		if err != nil {
+			return nil, err
+		}
+		list, l, err := splitEnclosed(data[n+t.length:], 1)
+		if err != nil {
+			return nil, err
+		}
+		data = data[n+t.length+l:]
+		for len(list) > 0 {
+			r := propertyResult{Ref: propertyRef{Object: obj, Index: arrayAll}}
+			t, n, err := decodeTag(list)
+			if err != nil || !t.isContext(2) || len(list) < n+t.length {
+				return nil, fmt.Errorf("bacnet: missing property identifier in RPM ack")
+			}
+			r.Ref.Property = uint32(decodeUnsigned(list[n : n+t.length]))
+			list = list[n+t.length:]
+			if t, n, err := decodeTag(list); err == nil && t.isContext(3) && len(list) >= n+t.length {
+				r.Ref.Index = uint32(decodeUnsigned(list[n : n+t.length]))
+				list = list[n+t.length:]
+			}
+			t, _, err = decodeTag(list)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case t.isOpening(4):
+				content, l, err := splitEnclosed(list, 4)
+				if err != nil {
+					return nil, err
+				}
+				if r.Values, err = decodeValues(content); err != nil {
+					return nil, err
+				}
+				list = list[l:]
+			case t.isOpening(5):
+				content, l, err := splitEnclosed(list, 5)
+				if err != nil {
+					return nil, err
+				}
+				r.Err = decodeErrorPDU(content)
+				list = list[l:]
+			default:
+				return nil, fmt.Errorf("bacnet: missing property result in RPM ack")
+			}
+			results = append(results, r)
+		}
+	}
+	return results, nil
+}
+
+// 应答长度估算：对象标识 5 字节 + 列表开闭标签 2 字节；每个属性的标识、下标和值开闭标签约 8 字节
+const (
+	rpmObjectOverhead   = 7
+	rpmPropertyOverhead = 8
+	rpmHeaderOverhead   = 8 // APDU 头及余量
+)
+
+// estimateValueSize 属性值编码后的大致长度，用于控制应答不超过 max-APDU
+func estimateValueSize(pt BacnetPoint, ref propertyRef) int {
+	switch pt.valueType(ref) {
+	case TypeReal, TypeUnsignedInt, TypeSignedInt, TypeEnumerated, TypeDate, TypeTime, TypeObjectID, TypeBoolean:
+		return 5
+	case TypeDouble:
+		return 10
+	}
+	switch ref.Property {
+	case PropStatusFlags:
+		return 3
+	case PropObjectName, PropDescription:
+		return 64
+	}
+	return 32
+}
+
+// planBatches 按估算的应答长度把点位分组，同一对象的属性排在一起以共享对象标识
+func planBatches(items []readItem, limit int) [][]readItem {
+	order := make([]ObjectID, 0, len(items))
+	byObject := make(map[ObjectID][]readItem)
+	for _, item := range items {
+		if _, ok := byObject[item.ref.Object]; !ok {
+			order = append(order, item.ref.Object)
+		}
+		byObject[item.ref.Object] = append(byObject[item.ref.Object], item)
+	}
+	var batches [][]readItem
+	var batch []readItem
+	size := rpmHeaderOverhead
+	for _, obj := range order {
+		objectStarted := false
+		for _, item := range byObject[obj] {
+			add := rpmPropertyOverhead + estimateValueSize(item.point, item.ref)
+			if !objectStarted {
+				add += rpmObjectOverhead
+			}
+			if len(batch) > 0 && size+add > limit {
+				batches = append(batches, batch)
+				batch, size = nil, rpmHeaderOverhead
+				if objectStarted {
+					add += rpmObjectOverhead
+				}
+			}
+			batch = append(batch, item)
+			size += add
+			objectStarted = true
+		}
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// readMultiple 分组发送 ReadPropertyMultiple。设备回复服务不支持时记住并改为逐点读取；
+// 应答过长被中止时拆半重试；整个请求被拒绝（如其中某个对象不存在）时该组逐点读取
+func (c *BacnetClient) readMultiple(items []readItem, result []protocols.PointValue) error {
+	for _, batch := range planBatches(items, c.deviceMaxAPDU()) {
+		if err := c.readBatchRPM(batch, result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *BacnetClient) readBatchRPM(batch []readItem, result []protocols.PointValue) error {
+	if len(batch) == 1 || c.rpmUnsupported {
+		for _, item := range batch {
+			if err := c.readSingle(item, result); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	refs := make([]propertyRef, len(batch))
+	for i, item := range batch {
+		refs[i] = item.ref
+	}
+	data, err := c.tr.request(c.peer, serviceReadPropertyMultiple, encodeReadPropertyMultiple(refs))
+	var results []propertyResult
+	if err == nil {
+		results, err = decodeReadPropertyMultipleAck(data)
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrTimeout):
+		return err
+	case serviceUnsupported(err):
+		log.Printf("[BACnet] device %s does not support ReadPropertyMultiple, fall back to ReadProperty", c.deviceID)
+		c.rpmUnsupported = true
+		return c.readBatchRPM(batch, result)
+	case responseTooLong(err):
+		half := len(batch) / 2
+		if err := c.readBatchRPM(batch[:half], result); err != nil {
+			return err
+		}
+		return c.readBatchRPM(batch[half:], result)
+	default:
+		log.Printf("[BACnet] ReadPropertyMultiple of %s failed: %v, read one by one", c.deviceID, err)
+		for _, item := range batch {
+			if err := c.readSingle(item, result); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	byRef := make(map[propertyRef]propertyResult, len(results))
+	for _, r := range results {
+		byRef[r.Ref] = r
+	}
+	for _, item := range batch {
+		r, ok := byRef[item.ref]
+		switch {
+		case !ok:
+			result[item.index].Quality = "bad"
+		case r.Err != nil:
+			result[item.index].Quality = errorQuality(r.Err)
+		default:
+			setResult(&result[item.index], item.point, r.Values)
+		}
+	}
+	return nil
+}
+
+// serviceUnsupported 设备不认识该服务：Reject unrecognized-service 或 services 类错误
+func serviceUnsupported(err error) bool {
+	var re *RejectError
+	if errors.As(err, &re) {
+		return re.Reason == 9
+	}
+	var be *Error
+	return errors.As(err, &be) && be.Class == 5 && (be.Code == 29 || be.Code == 45)
+}
+
+// responseTooLong 应答超出设备发送能力：需要分段或缓冲区溢出
+func responseTooLong(err error) bool {
+	var ae *AbortError
+	if errors.As(err, &ae) {
+		return ae.Reason == abortSegmentationNotSupported || ae.Reason == 1
+	}
+	return errors.Is(err, ErrSegmentationNotSupported)
+}
+
+// deviceMaxAPDU 设备的 max-APDU：优先用配置的 max_apdu，否则读设备对象的 maxApduLengthAccepted
+func (c *BacnetClient) deviceMaxAPDU() int {
+	if c.maxAPDU > 0 {
+		return c.maxAPDU
+	}
+	_, values, err := c.readProperty(propertyRef{Object: c.deviceObject(), Property: PropMaxAPDULengthAccepted, Index: arrayAll})
+	if errors.Is(err, ErrTimeout) {
+		return defaultDeviceAPDU // 设备暂不可达，下次再获取
+	}
+	c.maxAPDU = defaultDeviceAPDU
+	if err == nil && len(values) == 1 {
+		if n := int(toUint(values[0].Value)); n >= 50 {
+			c.maxAPDU = n
+		}
+	} else if err != nil {
+		log.Printf("[BACnet] read max APDU of %s failed: %v, use %d", c.deviceID, err, defaultDeviceAPDU)
+	}
+	if c.maxAPDU > maxAPDU {
+		c.maxAPDU = maxAPDU
+	}
+	return c.maxAPDU
+}
@@ -0,0 +1,616 @@
+package bacnet
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"sync"
+	"time"
+)
+
+// ServerDevice 网关作为 BACnet/IP 设备对外呈现的设备对象
+type ServerDevice struct {
+	Instance         uint32
+	Name             string
+	Description      string
+	VendorID         uint16
+	VendorName       string
+	ModelName        string
+	FirmwareRevision string
+}
+
+// ServerObject 服务端的一个虚拟对象，仅支持 analogValue/binaryValue/multiStateValue
+type ServerObject struct {
+	Object       ObjectID
+	Name         string
+	Description  string
+	Units        uint32   // analogValue 的工程单位枚举
+	COVIncrement float64  // analogValue 的 COV 阈值，0 表示任何变化都通知
+	ActiveText   string   // binaryValue 的状态文本，可为空
+	InactiveText string   // binaryValue 的状态文本，可为空
+	States       int      // multiStateValue 的状态数，配置了 StateText 时取其长度
+	StateText    []string // multiStateValue 的状态文本，可为空
+}
+
+// serverObject 对象的当前值：未收到采集值或采集失败时 fault 置位（reliability 为 communication-failure）
+type serverObject struct {
+	ServerObject
+	value         interface{} // analogValue: float64；binaryValue: bool；multiStateValue: uint64
+	fault         bool
+	notified      interface{} // 上次 COV 通知的值
+	notifiedFault bool        // 上次 COV 通知时的 fault
+}
+
+// serverSubscription 客户端的 COV 订阅，expires 为零值表示不过期
+type serverSubscription struct {
+	addr      *net.UDPAddr
+	processID uint32
+	object    ObjectID
+	confirmed bool
+	expires   time.Time
+}
+
+// reliability 取值
+const (
+	reliabilityNoFault              = 0
+	reliabilityCommunicationFailure = 12
+)
+
+// 服务端支持的服务（protocolServicesSupported 的位号与服务选择号相同，Who-Is/I-Am 另计）
+const (
+	servicesSupportedBits   = 41
+	serviceBitIAm           = 26
+	serviceBitWhoIs         = 34
+	objectTypesSupportedLen = 56
+	protocolRevision        = 14
+)
+
+// Server BACnet/IP 服务端：应答 Who-Is、ReadProperty、ReadPropertyMultiple 和 SubscribeCOV，
+// 对外呈现一个设备对象及若干由采集点位驱动的虚拟对象。不支持分段，应答超出客户端可接收长度时中止
+type Server struct {
+	tr     *transport
+	device ServerDevice
+
+	mu      sync.Mutex
+	order   []ObjectID
+	objects map[ObjectID]*serverObject
+	subs    []*serverSubscription
+}
+
+// NewServer 监听 listen（默认 :47808）并开始服务
+func NewServer(listen string, device ServerDevice, objects []ServerObject) (*Server, error) {
+	if device.Instance >= wildcardDevice {
+		return nil, fmt.Errorf("bacnet: invalid device instance %d", device.Instance)
+	}
+	if device.Name == "" {
+		device.Name = fmt.Sprintf("device_%d", device.Instance)
+	}
+	s := &Server{device: device, objects: make(map[ObjectID]*serverObject)}
+	for _, o := range objects {
+		switch o.Object.Type {
+		case AnalogValue, BinaryValue:
+		case MultiStateValue:
+			if len(o.StateText) > 0 {
+				o.States = len(o.StateText)
+			}
+			if o.States < 1 {
+				return nil, fmt.Errorf("bacnet: %s requires states or state_text", o.Object)
+			}
+		default:
+			return nil, fmt.Errorf("bacnet: unsupported server object type %s", o.Object.Type)
+		}
+		if _, dup := s.objects[o.Object]; dup {
+			return nil, fmt.Errorf("bacnet: duplicate object %s", o.Object)
+		}
+		if o.Name == "" {
+			o.Name = pointName(o.Object.String())
+		}
+		obj := &serverObject{ServerObject: o, fault: true}
+		switch o.Object.Type {
+		case AnalogValue:
+			obj.value = 0.0
+		case BinaryValue:
+			obj.value = false
+		case MultiStateValue:
+			obj.value = uint64(1)
+		}
+		s.objects[o.Object] = obj
+		s.order = append(s.order, o.Object)
+	}
+	if listen == "" {
+		listen = fmt.Sprintf(":%d", DefaultPort)
+	}
+	tr, err := newTransport(listen, 3*time.Second, 1)
+	if err != nil {
+		return nil, fmt.Errorf("bacnet: listen %s failed: %v", listen, err)
+	}
+	s.tr = tr
+	tr.setHandler(s.handle)
+	log.Printf("[BACnet-SERVER] device %d listening on %s with %d objects", device.Instance, tr.conn.LocalAddr(), len(objects))
+	return s, nil
+}
+
+// Addr 实际监听地址
+func (s *Server) Addr() *net.UDPAddr {
+	return s.tr.conn.LocalAddr().(*net.UDPAddr)
+}
+
+func (s *Server) Close() error {
+	return s.tr.close()
+}
+
+// Update 更新对象的 presentValue，value 为 nil 表示采集失败（保留上次的值并置 fault）。
+// 值的变化满足 COV 条件时向订阅者发送通知
+func (s *Server) Update(object ObjectID, value interface{}) error {
+	s.mu.Lock()
+	obj, ok := s.objects[object]
+	if !ok {
+		s.mu.Unlock()
+		return fmt.Errorf("bacnet: unknown object %s", object)
+	}
+	if value == nil {
+		obj.fault = true
+	} else {
+		v, err := obj.convert(value)
+		if err != nil {
+			s.mu.Unlock()
+			return fmt.Errorf("bacnet: %s: %v", object, err)
+		}
+		obj.value, obj.fault = v, false
+	}
+	var notify []*serverSubscription
+	if obj.covChanged() {
+		notify = s.subscribers(object)
+		obj.notified, obj.notifiedFault = obj.value, obj.fault
+	}
+	values := obj.covValues()
+	s.mu.Unlock()
+	for _, sub := range notify {
+		s.notify(sub, values)
+	}
+	return nil
+}
+
+// convert 采集值转为对象 presentValue 的类型
+func (o *serverObject) convert(value interface{}) (interface{}, error) {
+	if b, ok := value.(bool); ok {
+		switch o.Object.Type {
+		case BinaryValue:
+			return b, nil
+		case AnalogValue:
+			if b {
+				return 1.0, nil
+			}
+			return 0.0, nil
+		}
+	}
+	f, ok := toFloat(value)
+	if !ok {
+		return nil, fmt.Errorf("unsupported value type %T", value)
+	}
+	switch o.Object.Type {
+	case AnalogValue:
+		return f, nil
+	case BinaryValue:
+		return f != 0, nil
+	}
+	n := math.Round(f)
+	if n < 1 || n > float64(o.States) {
+		return nil, fmt.Errorf("state %v out of range 1-%d", value, o.States)
+	}
+	return uint64(n), nil
+}
+
+// covChanged 是否需要发送 COV 通知：状态标志变化，或 presentValue 变化（analogValue 按 covIncrement）
+func (o *serverObject) covChanged() bool {
+	if o.notified == nil || o.fault != o.notifiedFault {
+		return true
+	}
+	if o.Object.Type == AnalogValue {
+		diff := math.Abs(o.value.(float64) - o.notified.(float64))
+		if o.COVIncrement > 0 {
+			return diff >= o.COVIncrement
+		}
+		return diff != 0
+	}
+	return o.value != o.notified
+}
+
+func (o *serverObject) statusFlags() []bool {
+	return []bool{false, o.fault, false, false} // in-alarm, fault, overridden, out-of-service
+}
+
+func (o *serverObject) presentValue() PropertyValue {
+	switch o.Object.Type {
+	case AnalogValue:
+		return PropertyValue{Type: TypeReal, Value: o.value}
+	case BinaryValue:
+		return PropertyValue{Type: TypeEnumerated, Value: o.value}
+	}
+	return PropertyValue{Type: TypeUnsignedInt, Value: o.value}
+}
+
+func (o *serverObject) covValues() []propertyResult {
+	return []propertyResult{
+		{Ref: propertyRef{Object: o.Object, Property: PropPresentValue, Index: arrayAll}, Values: []PropertyValue{o.presentValue()}},
+		{Ref: propertyRef{Object: o.Object, Property: PropStatusFlags, Index: arrayAll}, Values: []PropertyValue{{Type: TypeBitString, Value: o.statusFlags()}}},
+	}
+}
+
+// subscribers 对象的有效订阅，顺带清理过期订阅（调用方持有锁）
+func (s *Server) subscribers(object ObjectID) []*serverSubscription {
+	now := time.Now()
+	var out []*serverSubscription
+	kept := s.subs[:0]
+	for _, sub := range s.subs {
+		if !sub.expires.IsZero() && now.After(sub.expires) {
+			continue
+		}
+		kept = append(kept, sub)
+		if sub.object == object {
+			out = append(out, sub)
+		}
+	}
+	s.subs = kept
+	return out
+}
+
+// notify 发送 COV 通知，确认型通知在独立协程中等待应答
+func (s *Server) notify(sub *serverSubscription, values []propertyResult) {
+	remaining := uint32(0)
+	if !sub.expires.IsZero() {
+		remaining = uint32(time.Until(sub.expires).Seconds())
+	}
+	params, err := encodeCOVNotification(covNotification{
+		ProcessID:     sub.processID,
+		Device:        ObjectID{Type: BacnetDevice, Instance: s.device.Instance},
+		Object:        sub.object,
+		TimeRemaining: remaining,
+		Values:        values,
+	})
+	if err != nil {
+		log.Printf("[BACnet-SERVER] encode COV notification of %s failed: %v", sub.object, err)
+		return
+	}
+	if !sub.confirmed {
+		s.tr.send(sub.addr, encodeUnconfirmedRequest(serviceUnconfirmedCOVNotification, params), false)
+		return
+	}
+	go func() {
+		if _, err := s.tr.request(sub.addr, serviceConfirmedCOVNotification, params); err != nil {
+			log.Printf("[BACnet-SERVER] COV notification of %s to %s failed: %v", sub.object, sub.addr, err)
+		}
+	}()
+}
+
+func (s *Server) handle(src *net.UDPAddr, a apdu) {
+	if a.pduType == pduUnconfirmedRequest {
+		if a.service == serviceWhoIs {
+			s.whoIs(src, a)
+		}
+		return
+	}
+	if a.segmented {
+		s.tr.send(src, encodeAbort(a.invokeID, abortSegmentationNotSupported, true), false)
+		return
+	}
+	var resp []byte
+	switch a.service {
+	case serviceReadProperty:
+		resp = s.readProperty(a)
+	case serviceReadPropertyMultiple:
+		resp = s.readPropertyMultiple(a)
+	case serviceSubscribeCOV:
+		resp = s.subscribeCOV(src, a)
+	case serviceWriteProperty:
+		resp = encodeErrorPDU(a.invokeID, a.service, 2, 40) // property / write-access-denied
+	default:
+		resp = encodeReject(a.invokeID, 9) // unrecognized-service
+	}
+	if resp == nil {
+		return
+	}
+	limit := a.maxResponse
+	if limit == 0 || limit > maxAPDU {
+		limit = maxAPDU
+	}
+	if len(resp) > limit {
+		resp = encodeAbort(a.invokeID, abortSegmentationNotSupported, true)
+	}
+	s.tr.send(src, resp, false)
+}
+
+func (s *Server) whoIs(src *net.UDPAddr, a apdu) {
+	low, high, err := decodeWhoIs(a.data)
+	id := int(s.device.Instance)
+	if err != nil || (low >= 0 && (id < low || id > high)) {
+		return
+	}
+	iam := encodeIAm(DeviceInfo{DeviceID: s.device.Instance, MaxAPDU: maxAPDU, Segmentation: 3, VendorID: s.device.VendorID})
+	s.tr.send(src, encodeUnconfirmedRequest(serviceIAm, iam), false)
+}
+
+func (s *Server) readProperty(a apdu) []byte {
+	ref, _, err := decodePropertyRef(a.data)
+	if err != nil {
+		return encodeReject(a.invokeID, 5) // invalid-parameter-data-type
+	}
+	s.mu.Lock()
+	ref.Object = s.resolve(ref.Object)
+	values, perr := s.property(ref)
+	s.mu.Unlock()
+	if perr != nil {
+		return encodeErrorPDU(a.invokeID, a.service, perr.Class, perr.Code)
+	}
+	ack := appendPropertyRef(nil, ref)
+	ack = encodeOpeningTag(ack, 3)
+	for _, v := range values {
+		b, err := encodeValue(v.Type, v.Value)
+		if err != nil {
+			return encodeErrorPDU(a.invokeID, a.service, 0, 0)
+		}
+		ack = append(ack, b...)
+	}
+	ack = encodeClosingTag(ack, 3)
+	return encodeComplexAck(a.invokeID, a.service, ack)
+}
+
+func (s *Server) readPropertyMultiple(a apdu) []byte {
+	refs, err := decodeReadPropertyMultiple(a.data)
+	if err != nil || len(refs) == 0 {
+		return encodeReject(a.invokeID, 5)
+	}
+	s.mu.Lock()
+	var results []propertyResult
+	for _, ref := range refs {
+		ref.Object = s.resolve(ref.Object)
+		if ref.Property == PropAll || ref.Property == PropRequired || ref.Property == PropOptional {
+			props, ok := s.propertyList(ref.Object)
+			if !ok {
+				results = append(results, propertyResult{Ref: ref, Err: &Error{Class: 1, Code: 31}})
+				continue
+			}
+			for _, p := range props {
+				if ref.Property == PropRequired && optionalProperty(p) || ref.Property == PropOptional && !optionalProperty(p) {
+					continue
+				}
+				r := propertyRef{Object: ref.Object, Property: p, Index: arrayAll}
+				values, perr := s.property(r)
+				results = append(results, propertyResult{Ref: r, Values: values, Err: perr})
+			}
+			continue
+		}
+		values, perr := s.property(ref)
+		results = append(results, propertyResult{Ref: ref, Values: values, Err: perr})
+	}
+	s.mu.Unlock()
+	ack, err := encodeReadPropertyMultipleAck(results)
+	if err != nil {
+		return encodeErrorPDU(a.invokeID, a.service, 0, 0)
+	}
+	return encodeComplexAck(a.invokeID, a.service, ack)
+}
+
+func (s *Server) subscribeCOV(src *net.UDPAddr, a apdu) []byte {
+	pid, object, confirmed, lifetime, cancel, err := decodeSubscribeCOV(a.data)
+	if err != nil {
+		return encodeReject(a.invokeID, 5)
+	}
+	s.mu.Lock()
+	obj, ok := s.objects[object]
+	if !ok {
+		s.mu.Unlock()
+		return encodeErrorPDU(a.invokeID, a.service, 1, 31) // object / unknown-object
+	}
+	kept := s.subs[:0]
+	for _, sub := range s.subs {
+		if sub.object != object || sub.processID != pid || sub.addr.String() != src.String() {
+			kept = append(kept, sub)
+		}
+	}
+	s.subs = kept
+	if cancel {
+		s.mu.Unlock()
+		return encodeSimpleAck(a.invokeID, a.service)
+	}
+	sub := &serverSubscription{addr: src, processID: pid, object: object, confirmed: confirmed}
+	if lifetime > 0 {
+		sub.expires = time.Now().Add(time.Duration(lifetime) * time.Second)
+	}
+	s.subs = append(s.subs, sub)
+	values := obj.covValues()
+	s.mu.Unlock()
+	// 先应答订阅，再发送初始通知（返回 nil，应答已发出）
+	s.tr.send(src, encodeSimpleAck(a.invokeID, a.service), false)
+	s.notify(sub, values)
+	return nil
+}
+
+// resolve 通配实例号的设备对象指本设备
+func (s *Server) resolve(object ObjectID) ObjectID {
+	if object.Type == BacnetDevice && object.Instance == wildcardDevice {
+		return ObjectID{Type: BacnetDevice, Instance: s.device.Instance}
+	}
+	return object
+}
+
+// optionalProperty 标准中的可选属性（用于 ReadPropertyMultiple 的 required/optional）
+func optionalProperty(p uint32) bool {
+	switch p {
+	case PropDescription, PropReliability, PropCOVIncrement, PropActiveText, PropInactiveText, PropStateText:
+		return true
+	}
+	return false
+}
+
+// propertyList 对象支持的属性（调用方持有锁）
+func (s *Server) propertyList(object ObjectID) ([]uint32, bool) {
+	props := []uint32{PropObjectIdentifier, PropObjectName, PropObjectType}
+	if object == (ObjectID{Type: BacnetDevice, Instance: s.device.Instance}) {
+		props = append(props, PropSystemStatus, PropVendorName, PropVendorIdentifier, PropModelName,
+			PropFirmwareRevision, PropApplicationSoftwareVer, PropProtocolVersion, PropProtocolRevision,
+			PropProtocolServicesSupported, PropProtocolObjectTypes, PropObjectList, PropMaxAPDULengthAccepted,
+			PropSegmentationSupported, PropAPDUTimeout, PropNumberOfAPDURetries, PropDeviceAddressBinding,
+			PropDatabaseRevision)
+		if s.device.Description != "" {
+			props = append(props, PropDescription)
+		}
+		return props, true
+	}
+	obj, ok := s.objects[object]
+	if !ok {
+		return nil, false
+	}
+	props = append(props, PropPresentValue, PropStatusFlags, PropEventState, PropOutOfService, PropReliability)
+	if obj.Description != "" {
+		props = append(props, PropDescription)
+	}
+	switch object.Type {
+	case AnalogValue:
+		props = append(props, PropUnits, PropCOVIncrement)
+	case BinaryValue:
+		if obj.ActiveText != "" || obj.InactiveText != "" {
+			props = append(props, PropActiveText, PropInactiveText)
+		}
+	case MultiStateValue:
+		props = append(props, PropNumberOfStates)
+		if len(obj.StateText) > 0 {
+			props = append(props, PropStateText)
+		}
+	}
+	return props, true
+}
+
+// property 读取属性值，数组属性按 ref.Index 取长度或元素（调用方持有锁）
+func (s *Server) property(ref propertyRef) ([]PropertyValue, *Error) {
+	props, ok := s.propertyList(ref.Object)
+	if !ok {
+		return nil, &Error{Class: 1, Code: 31} // object / unknown-object
+	}
+	supported := false
+	for _, p := range props {
+		supported = supported || p == ref.Property
+	}
+	if !supported {
+		return nil, &Error{Class: 2, Code: 32} // property / unknown-property
+	}
+	values, array := s.propertyValues(ref.Object, ref.Property)
+	if ref.Index == arrayAll {
+		return values, nil
+	}
+	if !array {
+		return nil, &Error{Class: 2, Code: 50} // property / property-is-not-an-array
+	}
+	if ref.Index == 0 {
+		return []PropertyValue{{Type: TypeUnsignedInt, Value: uint64(len(values))}}, nil
+	}
+	if int(ref.Index) > len(values) {
+		return nil, &Error{Class: 2, Code: 42} // property / invalid-array-index
+	}
+	return values[ref.Index-1 : ref.Index], nil
+}
+
+// propertyValues 属性的全部值及是否为数组属性
+func (s *Server) propertyValues(object ObjectID, prop uint32) ([]PropertyValue, bool) {
+	one := func(t PropertyValueType, v interface{}) []PropertyValue {
+		return []PropertyValue{{Type: t, Value: v}}
+	}
+	switch prop {
+	case PropObjectIdentifier:
+		return one(TypeObjectID, object), false
+	case PropObjectType:
+		return one(TypeEnumerated, uint64(object.Type)), false
+	}
+	if object.Type == BacnetDevice {
+		d := s.device
+		switch prop {
+		case PropObjectName:
+			return one(TypeCharacterString, d.Name), false
+		case PropDescription:
+			return one(TypeCharacterString, d.Description), false
+		case PropSystemStatus:
+			return one(TypeEnumerated, uint64(0)), false // operational
+		case PropVendorName:
+			return one(TypeCharacterString, d.VendorName), false
+		case PropVendorIdentifier:
+			return one(TypeUnsignedInt, uint64(d.VendorID)), false
+		case PropModelName:
+			return one(TypeCharacterString, d.ModelName), false
+		case PropFirmwareRevision, PropApplicationSoftwareVer:
+			return one(TypeCharacterString, d.FirmwareRevision), false
+		case PropProtocolVersion:
+			return one(TypeUnsignedInt, uint64(1)), false
+		case PropProtocolRevision:
+			return one(TypeUnsignedInt, uint64(protocolRevision)), false
+		case PropProtocolServicesSupported:
+			bits := make([]bool, servicesSupportedBits)
+			for _, b := range []int{serviceSubscribeCOV, serviceReadProperty, serviceReadPropertyMultiple, serviceBitIAm, serviceBitWhoIs} {
+				bits[b] = true
+			}
+			return one(TypeBitString, bits), false
+		case PropProtocolObjectTypes:
+			bits := make([]bool, objectTypesSupportedLen)
+			for _, t := range []ObjectType{AnalogValue, BinaryValue, BacnetDevice, MultiStateValue} {
+				bits[t] = true
+			}
+			return one(TypeBitString, bits), false
+		case PropObjectList:
+			list := []PropertyValue{{Type: TypeObjectID, Value: object}}
+			for _, id := range s.order {
+				list = append(list, PropertyValue{Type: TypeObjectID, Value: id})
+			}
+			return list, true
+		case PropMaxAPDULengthAccepted:
+			return one(TypeUnsignedInt, uint64(maxAPDU)), false
+		case PropSegmentationSupported:
+			return one(TypeEnumerated, uint64(3)), false // no-segmentation
+		case PropAPDUTimeout:
+			return one(TypeUnsignedInt, uint64(3000)), false
+		case PropNumberOfAPDURetries:
+			return one(TypeUnsignedInt, uint64(1)), false
+		case PropDeviceAddressBinding:
+			return nil, false
+		case PropDatabaseRevision:
+			return one(TypeUnsignedInt, uint64(0)), false
+		}
+		return nil, false
+	}
+	obj := s.objects[object]
+	switch prop {
+	case PropObjectName:
+		return one(TypeCharacterString, obj.Name), false
+	case PropDescription:
+		return one(TypeCharacterString, obj.Description), false
+	case PropPresentValue:
+		return []PropertyValue{obj.presentValue()}, false
+	case PropStatusFlags:
+		return one(TypeBitString, obj.statusFlags()), false
+	case PropEventState:
+		return one(TypeEnumerated, uint64(0)), false // normal
+	case PropOutOfService:
+		return one(TypeBoolean, false), false
+	case PropReliability:
+		if obj.fault {
+			return one(TypeEnumerated, uint64(reliabilityCommunicationFailure)), false
+		}
+		return one(TypeEnumerated, uint64(reliabilityNoFault)), false
+	case PropUnits:
+		return one(TypeEnumerated, uint64(obj.Units)), false
+	case PropCOVIncrement:
+		return one(TypeReal, obj.COVIncrement), false
+	case PropActiveText:
+		return one(TypeCharacterString, obj.ActiveText), false
+	case PropInactiveText:
+		return one(TypeCharacterString, obj.InactiveText), false
+	case PropNumberOfStates:
+		return one(TypeUnsignedInt, uint64(obj.States)), false
+	case PropStateText:
+		list := make([]PropertyValue, len(obj.StateText))
+		for i, text := range obj.StateText {
+			list[i] = PropertyValue{Type: TypeCharacterString, Value: text}
+		}
+		return list, true
+	}
+	return nil, false
+}
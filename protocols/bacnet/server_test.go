@@ -0,0 +1,131 @@
+package bacnet
+
+import (
+	"testing"
+	"time"
+
+	"sensor-edge/protocols"
+)
+
+func TestServer(t *testing.T) {
+	srv, err := NewServer("127.0.0.1:0", ServerDevice{Instance: 260001, Name: "gateway", VendorID: 999, VendorName: "edge", ModelName: "gw"},
+		[]ServerObject{
+			{Object: ObjectID{Type: AnalogValue, Instance: 1}, Name: "supply_temp", Units: 62, COVIncrement: 0.5},
+			{Object: ObjectID{Type: BinaryValue, Instance: 1}, Name: "fan", ActiveText: "on", InactiveText: "off"},
+			{Object: ObjectID{Type: MultiStateValue, Instance: 1}, Name: "mode", StateText: []string{"off", "low", "high"}},
+		})
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	defer srv.Close()
+	av := ObjectID{Type: AnalogValue, Instance: 1}
+	srv.Update(av, 21.5)
+	srv.Update(ObjectID{Type: BinaryValue, Instance: 1}, true)
+	srv.Update(ObjectID{Type: MultiStateValue, Instance: 1}, 3.0)
+	if err := srv.Update(ObjectID{Type: MultiStateValue, Instance: 1}, 4); err == nil {
+		t.Errorf("expected error for state out of range")
+	}
+
+	// Who-Is 发现本设备并读取设备属性
+	disc, err := NewDiscovery("127.0.0.1:0", srv.Addr().String(), 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("NewDiscovery failed: %v", err)
+	}
+	defer disc.Close()
+	devices, err := disc.Discover(260000, 260010, 200*time.Millisecond)
+	if err != nil || len(devices) != 1 || devices[0].DeviceID != 260001 || devices[0].VendorID != 999 ||
+		devices[0].ObjectName != "gateway" || devices[0].ModelName != "gw" {
+		t.Fatalf("Discover: %+v, %v", devices, err)
+	}
+
+	conf := map[string]interface{}{"object_device": 260001, "ip": "127.0.0.1", "port": srv.Addr().Port, "timeout": 200,
+		"cov_lifetime": 60,
+		"points": []interface{}{
+			map[string]interface{}{"name": "temp", "address": "analogValue:1", "cov": true},
+			map[string]interface{}{"name": "fan", "address": "binaryValue:1", "type": "bool"},
+			map[string]interface{}{"name": "mode", "address": "multiStateValue:1"},
+			map[string]interface{}{"name": "units", "address": "analogValue:1:units"},
+			map[string]interface{}{"name": "missing", "address": "analogValue:9"},
+			map[string]interface{}{"name": "reliability", "address": "analogValue:1:reliability"},
+		}}
+	c := &BacnetClient{}
+	if err := c.Init(conf); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	defer c.Close()
+	vals, err := c.ReadBatch("", "", []string{"temp", "fan", "mode", "units", "missing"})
+	if err != nil {
+		t.Fatalf("ReadBatch failed: %v", err)
+	}
+	want := []interface{}{21.5, true, uint64(3), 62, nil}
+	for i, v := range vals {
+		if v.Value != want[i] {
+			t.Errorf("%s = %v (%s), want %v", v.PointID, v.Value, v.Quality, want[i])
+		}
+	}
+	if vals[4].Quality != "bad:object:unknown-object" {
+		t.Errorf("missing quality = %s", vals[4].Quality)
+	}
+	if c.Write("temp", 1.0) == nil {
+		t.Errorf("expected write to be rejected")
+	}
+
+	// objectList 包含设备对象和三个虚拟对象
+	objects, err := c.ReadObjectList()
+	if err != nil || len(objects) != 4 {
+		t.Fatalf("ReadObjectList: %v, %v", objects, err)
+	}
+
+	// COV：变化小于 covIncrement 不通知，超过时推送
+	got := make(chan interface{}, 10)
+	c.SetValueHandler("", func(values []protocols.PointValue) {
+		for _, v := range values {
+			got <- v.Value
+		}
+	})
+	deadline := time.After(2 * time.Second)
+	for {
+		c.covMu.Lock()
+		active := c.covSubs["temp"] != nil && c.covSubs["temp"].active
+		c.covMu.Unlock()
+		if active {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("COV subscription not active")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+	drain(got)
+	srv.Update(av, 21.7)
+	srv.Update(av, 22.5)
+	select {
+	case v := <-got:
+		if v != 22.5 {
+			t.Errorf("COV value = %v, want 22.5", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no COV notification")
+	}
+	// 采集失败时置 fault 并通知
+	srv.Update(av, nil)
+	select {
+	case <-got:
+	case <-time.After(time.Second):
+		t.Fatalf("no COV notification on fault")
+	}
+	if vals, _ := c.ReadBatch("", "", []string{"reliability"}); vals[0].Value != 12 {
+		t.Errorf("reliability = %v, want communication-failure (12)", vals[0].Value)
+	}
+}
+
+func drain(ch chan interface{}) {
+	for {
+		select {
+		case <-ch:
+		default:
+			return
+		}
+	}
+}
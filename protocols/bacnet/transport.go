@@ -0,0 +1,200 @@
+package bacnet
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// transport BACnet/IP 的 UDP 收发：为确认请求分配 invoke ID、匹配应答、超时重发。
+// 非应答类报文（对端发来的请求）交给 handler
+type transport struct {
+	conn    *net.UDPConn
+	timeout time.Duration
+	retries int
+
+	mu      sync.Mutex
+	nextID  map[string]byte
+	pending map[string]chan apdu
+	handler func(src *net.UDPAddr, a apdu)
+	done    chan struct{}
+}
+
+func newTransport(local string, timeout time.Duration, retries int) (*transport, error) {
+	addr, err := net.ResolveUDPAddr("udp4", local)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp4", addr)
+	if err != nil {
+		return nil, err
+	}
+	t := &transport{
+		conn:    conn,
+		timeout: timeout,
+		retries: retries,
+		nextID:  make(map[string]byte),
+		pending: make(map[string]chan apdu),
+		done:    make(chan struct{}),
+	}
+	go t.readLoop()
+	return t, nil
+}
+
+func pendingKey(peer *net.UDPAddr, invokeID byte) string {
+	return fmt.Sprintf("%s#%d", peer, invokeID)
+}
+
+// setHandler 设置对端请求（COV 通知、I-Am 等）的处理函数
+func (t *transport) setHandler(h func(src *net.UDPAddr, a apdu)) {
+	t.mu.Lock()
+	t.handler = h
+	t.mu.Unlock()
+}
+
+// allocate 为对端分配一个未占用的 invoke ID
+func (t *transport) allocate(peer *net.UDPAddr) (byte, chan apdu, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := peer.String()
+	for i := 0; i < 256; i++ {
+		id := t.nextID[p]
+		t.nextID[p] = id + 1
+		key := pendingKey(peer, id)
+		if _, busy := t.pending[key]; !busy {
+			ch := make(chan apdu, 1)
+			t.pending[key] = ch
+			return id, ch, nil
+		}
+	}
+	return 0, nil, fmt.Errorf("bacnet: no free invoke ID for %s", peer)
+}
+
+func (t *transport) release(peer *net.UDPAddr, invokeID byte) {
+	t.mu.Lock()
+	delete(t.pending, pendingKey(peer, invokeID))
+	t.mu.Unlock()
+}
+
+// request 发送确认请求并等待应答，超时后以同一 invoke ID 重发。
+// 返回 SimpleAck/ComplexAck 的服务参数；对端的 Error/Reject/Abort 转为对应错误
+func (t *transport) request(peer *net.UDPAddr, service byte, params []byte) ([]byte, error) {
+	id, ch, err := t.allocate(peer)
+	if err != nil {
+		return nil, err
+	}
+	defer t.release(peer, id)
+	frame := encodeFrame(encodeConfirmedRequest(id, service, params), false, true)
+	timer := time.NewTimer(t.timeout)
+	defer timer.Stop()
+	for attempt := 0; attempt <= t.retries; attempt++ {
+		if _, err := t.conn.WriteToUDP(frame, peer); err != nil {
+			return nil, err
+		}
+		if attempt > 0 {
+			timer.Reset(t.timeout)
+		}
+		select {
+		case a := <-ch:
+			return t.response(peer, service, a)
+		case <-timer.C:
+		case <-t.done:
+			return nil, errors.New("bacnet: transport closed")
+		}
+	}
+	return nil, fmt.Errorf("%w: %s service %d", ErrTimeout, peer, service)
+}
+
+func (t *transport) response(peer *net.UDPAddr, service byte, a apdu) ([]byte, error) {
+	switch a.pduType {
+	case pduSimpleAck, pduComplexAck:
+		if a.service != service {
+			return nil, fmt.Errorf("bacnet: unexpected service %d in ack", a.service)
+		}
+		if a.segmented {
+			// 请求中已声明不接受分段，仍收到分段应答时中止该事务
+			t.send(peer, encodeAbort(a.invokeID, abortSegmentationNotSupported, false), false)
+			return nil, ErrSegmentationNotSupported
+		}
+		return a.data, nil
+	case pduError:
+		return nil, decodeErrorPDU(a.data)
+	case pduReject:
+		return nil, &RejectError{Reason: a.reason}
+	case pduAbort:
+		return nil, &AbortError{Reason: a.reason, Server: a.server}
+	}
+	return nil, fmt.Errorf("bacnet: unexpected PDU type 0x%02x", a.pduType)
+}
+
+// send 发送一个 APDU（不等待应答）
+func (t *transport) send(peer *net.UDPAddr, apdu []byte, broadcast bool) error {
+	_, err := t.conn.WriteToUDP(encodeFrame(apdu, broadcast, false), peer)
+	return err
+}
+
+func (t *transport) readLoop() {
+	buf := make([]byte, 2048)
+	for {
+		n, src, err := t.conn.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-t.done:
+				return
+			default:
+			}
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			log.Printf("[BACnet] read failed: %v", err)
+			return
+		}
+		data, origin, err := decodeFrame(buf[:n])
+		if err != nil || len(data) == 0 {
+			continue
+		}
+		a, err := decodeAPDU(data)
+		if err != nil {
+			continue
+		}
+		// 服务参数引用接收缓冲区，交出前复制
+		a.data = append([]byte(nil), a.data...)
+		if origin != nil {
+			src = origin
+		}
+		switch a.pduType {
+		case pduConfirmedRequest, pduUnconfirmedRequest:
+			t.mu.Lock()
+			h := t.handler
+			t.mu.Unlock()
+			if h != nil {
+				h(src, a)
+			} else if a.pduType == pduConfirmedRequest {
+				t.send(src, encodeReject(a.invokeID, 9), false) // unrecognized-service
+			}
+		default:
+			t.mu.Lock()
+			ch, ok := t.pending[pendingKey(src, a.invokeID)]
+			t.mu.Unlock()
+			if ok {
+				select {
+				case ch <- a:
+				default: // 重发导致的重复应答
+				}
+			}
+		}
+	}
+}
+
+func (t *transport) close() error {
+	select {
+	case <-t.done:
+		return nil
+	default:
+	}
+	close(t.done)
+	return t.conn.Close()
+}
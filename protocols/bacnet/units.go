@@ -0,0 +1,221 @@
+package bacnet
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// engineeringUnits BACnetEngineeringUnits 枚举到可读单位（常用单位用符号，与 points.yaml 的 unit 写法一致）
+var engineeringUnits = map[uint32]string{
+	0:   "m²",
+	1:   "ft²",
+	2:   "mA",
+	3:   "A",
+	4:   "Ω",
+	5:   "V",
+	6:   "kV",
+	7:   "MV",
+	8:   "VA",
+	9:   "kVA",
+	10:  "MVA",
+	11:  "var",
+	12:  "kvar",
+	13:  "Mvar",
+	14:  "°",
+	15:  "PF",
+	16:  "J",
+	17:  "kJ",
+	18:  "Wh",
+	19:  "kWh",
+	20:  "BTU",
+	21:  "thm",
+	22:  "ton·h",
+	23:  "J/kg",
+	24:  "BTU/lb",
+	25:  "cycles/h",
+	26:  "cycles/min",
+	27:  "Hz",
+	28:  "g/kg",
+	29:  "%RH",
+	30:  "mm",
+	31:  "m",
+	32:  "in",
+	33:  "ft",
+	34:  "W/ft²",
+	35:  "W/m²",
+	36:  "lm",
+	37:  "lx",
+	38:  "fc",
+	39:  "kg",
+	40:  "lb",
+	41:  "t",
+	42:  "kg/s",
+	43:  "kg/min",
+	44:  "kg/h",
+	45:  "lb/min",
+	46:  "lb/h",
+	47:  "W",
+	48:  "kW",
+	49:  "MW",
+	50:  "BTU/h",
+	51:  "hp",
+	52:  "TR",
+	53:  "Pa",
+	54:  "kPa",
+	55:  "bar",
+	56:  "psi",
+	57:  "cmH2O",
+	58:  "inH2O",
+	59:  "mmHg",
+	60:  "cmHg",
+	61:  "inHg",
+	62:  "℃",
+	63:  "K",
+	64:  "℉",
+	65:  "℃·d",
+	66:  "℉·d",
+	67:  "a",
+	68:  "mo",
+	69:  "wk",
+	70:  "d",
+	71:  "h",
+	72:  "min",
+	73:  "s",
+	74:  "m/s",
+	75:  "km/h",
+	76:  "ft/s",
+	77:  "ft/min",
+	78:  "mph",
+	79:  "ft³",
+	80:  "m³",
+	81:  "imp gal",
+	82:  "L",
+	83:  "gal",
+	84:  "cfm",
+	85:  "m³/s",
+	86:  "imp gal/min",
+	87:  "L/s",
+	88:  "L/min",
+	89:  "gpm",
+	90:  "°",
+	91:  "℃/h",
+	92:  "℃/min",
+	93:  "℉/h",
+	94:  "℉/min",
+	95:  "",
+	96:  "ppm",
+	97:  "ppb",
+	98:  "%",
+	99:  "%/s",
+	100: "/min",
+	101: "/s",
+	102: "psi/℉",
+	103: "rad",
+	104: "rpm",
+	116: "in²",
+	117: "cm²",
+	118: "BTU/lb",
+	119: "cm",
+	120: "lb/s",
+	121: "Δ℉",
+	122: "ΔK",
+	123: "kΩ",
+	124: "MΩ",
+	125: "mV",
+	126: "kJ/kg",
+	127: "MJ",
+	128: "J/K",
+	129: "J/(kg·K)",
+	130: "kHz",
+	131: "MHz",
+	132: "/h",
+	133: "mW",
+	134: "hPa",
+	135: "mbar",
+	136: "m³/h",
+	137: "L/h",
+	138: "kWh/m²",
+	139: "kWh/ft²",
+	140: "MJ/m²",
+	141: "MJ/ft²",
+	142: "W/(m²·K)",
+	143: "ft³/s",
+	144: "%obs/ft",
+	145: "%obs/m",
+	146: "mΩ",
+	147: "MWh",
+	148: "kBTU",
+	149: "MBTU",
+	150: "kJ/kg",
+	151: "MJ/kg",
+	152: "kJ/K",
+	153: "MJ/K",
+	154: "N",
+	155: "g/s",
+	156: "g/min",
+	157: "t/h",
+	158: "kBTU/h",
+	159: "cs",
+	160: "ms",
+	161: "N·m",
+	162: "mm/s",
+	163: "mm/min",
+	164: "m/min",
+	165: "m/h",
+	166: "m³/min",
+	167: "m/s²",
+	168: "A/m",
+	169: "A/m²",
+	170: "A·m²",
+	171: "F",
+	172: "H",
+	173: "Ω·m",
+	174: "S",
+	175: "S/m",
+	176: "T",
+	177: "V/K",
+	178: "V/m",
+	179: "Wb",
+	180: "cd",
+	181: "cd/m²",
+	182: "K/h",
+	183: "K/min",
+	184: "J·s",
+	185: "rad/s",
+	186: "m²/N",
+	187: "kg/m³",
+	188: "N·s",
+	189: "N/m",
+	190: "W/(m·K)",
+}
+
+// UnitName 工程单位枚举对应的单位字符串，未收录的单位返回 units-<枚举值>
+func UnitName(units uint32) string {
+	if name, ok := engineeringUnits[units]; ok {
+		return name
+	}
+	return fmt.Sprintf("units-%d", units)
+}
+
+// ParseUnits 由单位字符串（如 ℃、kW）或枚举值得到工程单位枚举，空串为 no-units
+func ParseUnits(s string) (uint32, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 95, nil
+	}
+	if n, err := strconv.ParseUint(s, 10, 16); err == nil {
+		return uint32(n), nil
+	}
+	var found uint32
+	ok := false
+	for units, name := range engineeringUnits {
+		if name == s && (!ok || units < found) {
+			found, ok = units, true
+		}
+	}
+	if !ok {
+		return 0, fmt.Errorf("bacnet: unknown units %q", s)
+	}
+	return found, nil
+}
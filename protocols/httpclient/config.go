@@ -0,0 +1,253 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"sensor-edge/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// deviceConfig 设备级配置：默认请求（url/method/headers/query/body）之外，
+// requests 中的具名请求模板由点位分组的 function 选择
+type deviceConfig struct {
+	requestConfig `yaml:",inline"`
+	BaseURL       string                   `yaml:"base_url"`
+	Timeout       int                      `yaml:"timeout"` // 单次请求超时时间(毫秒)
+	Auth          authConfig               `yaml:"auth"`
+	TLS           tlsConfig                `yaml:"tls"`
+	Requests      map[string]requestConfig `yaml:"requests"`
+}
+
+// requestConfig 请求模板，url/query/headers/body 为 text/template 模板，
+// 可用 {{.DeviceID}}、{{.Point}}、{{.Value}}（写入）和 {{json .Value}}
+type requestConfig struct {
+	Method     string            `yaml:"method"`
+	URL        string            `yaml:"url"` // 完整 URL 或相对 base_url 的路径
+	Headers    map[string]string `yaml:"headers"`
+	Query      map[string]string `yaml:"query"`
+	Body       string            `yaml:"body"`
+	Pagination *pagination       `yaml:"pagination"`
+}
+
+// authConfig 认证：basic（username/password）、bearer（token）、
+// api_key（key 为请求头或查询参数名，in: header | query）
+type authConfig struct {
+	Type     string `yaml:"type"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	Token    string `yaml:"token"`
+	Key      string `yaml:"key"`
+	Value    string `yaml:"value"`
+	In       string `yaml:"in"`
+}
+
+type tlsConfig struct {
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"` // 客户端证书（双向认证）
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+}
+
+// pagination 分页：
+//   - page/offset：param 为页码或偏移参数，每页 size 条（size_param 不为空时随请求发送），
+//     items 指向的数组为空或不足一页时结束
+//   - cursor：next 指向下一页游标，作为 param 参数发送，为空时结束
+//   - link：next 指向下一页 URL，未配置时取 Link 响应头的 rel="next"
+type pagination struct {
+	Type      string `yaml:"type"`
+	Param     string `yaml:"param"`
+	Start     int    `yaml:"start"`
+	SizeParam string `yaml:"size_param"`
+	Size      int    `yaml:"size"`
+	Items     string `yaml:"items"`
+	Next      string `yaml:"next"`
+	MaxPages  int    `yaml:"max_pages"` // 默认 10
+}
+
+// decodeConfig 把 YAML 解析出的 map 转换为结构体
+func decodeConfig(config map[string]interface{}, out interface{}) error {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, out)
+}
+
+// compiledRequest 解析好模板的请求
+type compiledRequest struct {
+	method     string
+	url        *template.Template
+	headers    map[string]*template.Template
+	query      map[string]*template.Template
+	body       *template.Template
+	pagination *pagination
+}
+
+var templateFuncs = template.FuncMap{
+	"json": jsonString,
+}
+
+func compileRequest(name string, rc requestConfig, defaultMethod string) (*compiledRequest, error) {
+	if rc.URL == "" {
+		return nil, fmt.Errorf("http request %s: url is required", name)
+	}
+	cr := &compiledRequest{
+		method:  strings.ToUpper(rc.Method),
+		headers: make(map[string]*template.Template),
+		query:   make(map[string]*template.Template),
+	}
+	if cr.method == "" {
+		cr.method = defaultMethod
+	}
+	var err error
+	parse := func(field, text string) *template.Template {
+		if err != nil {
+			return nil
+		}
+		var t *template.Template
+		t, err = template.New(name + "." + field).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+		return t
+	}
+	cr.url = parse("url", rc.URL)
+	cr.body = parse("body", rc.Body)
+	for k, v := range rc.Headers {
+		cr.headers[k] = parse("headers."+k, v)
+	}
+	for k, v := range rc.Query {
+		cr.query[k] = parse("query."+k, v)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("http request %s: %v", name, err)
+	}
+	if p := rc.Pagination; p != nil {
+		switch p.Type {
+		case "page", "offset":
+			if p.Param == "" || p.Items == "" {
+				return nil, fmt.Errorf("http request %s: %s pagination requires param and items", name, p.Type)
+			}
+			if p.Type == "offset" && p.Size <= 0 {
+				return nil, fmt.Errorf("http request %s: offset pagination requires size", name)
+			}
+		case "cursor":
+			if p.Param == "" || p.Next == "" {
+				return nil, fmt.Errorf("http request %s: cursor pagination requires param and next", name)
+			}
+		case "link":
+		default:
+			return nil, fmt.Errorf("http request %s: unsupported pagination type %q", name, p.Type)
+		}
+		for _, expr := range []string{p.Items, p.Next} {
+			if expr != "" {
+				if _, err := utils.CompileJSONPath(expr); err != nil {
+					return nil, fmt.Errorf("http request %s: %v", name, err)
+				}
+			}
+		}
+		if p.MaxPages <= 0 {
+			p.MaxPages = 10
+		}
+		cr.pagination = p
+	}
+	return cr, nil
+}
+
+// newHTTPClient 按超时和 TLS 配置创建 http.Client
+func newHTTPClient(conf deviceConfig) (*http.Client, error) {
+	timeout := 5 * time.Second
+	if conf.Timeout > 0 {
+		timeout = time.Duration(conf.Timeout) * time.Millisecond
+	}
+	tlsConf := &tls.Config{
+		InsecureSkipVerify: conf.TLS.InsecureSkipVerify,
+		ServerName:         conf.TLS.ServerName,
+	}
+	if conf.TLS.CAFile != "" {
+		pem, err := os.ReadFile(conf.TLS.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("http: read ca_file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("http: no certificate found in %s", conf.TLS.CAFile)
+		}
+		tlsConf.RootCAs = pool
+	}
+	if conf.TLS.CertFile != "" || conf.TLS.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.TLS.CertFile, conf.TLS.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("http: load client certificate: %v", err)
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConf
+	return &http.Client{Timeout: timeout, Transport: transport}, nil
+}
+
+// apply 为请求加上认证信息
+func (a authConfig) apply(req *http.Request) error {
+	switch strings.ToLower(a.Type) {
+	case "":
+	case "basic":
+		req.SetBasicAuth(a.Username, a.Password)
+	case "bearer":
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+	case "api_key":
+		if strings.ToLower(a.In) == "query" {
+			q := req.URL.Query()
+			q.Set(a.Key, a.Value)
+			req.URL.RawQuery = q.Encode()
+		} else {
+			req.Header.Set(a.Key, a.Value)
+		}
+	default:
+		return fmt.Errorf("http: unsupported auth type %q", a.Type)
+	}
+	return nil
+}
+
+func (a authConfig) validate() error {
+	switch strings.ToLower(a.Type) {
+	case "", "basic":
+	case "bearer":
+		if a.Token == "" {
+			return fmt.Errorf("http: bearer auth requires token")
+		}
+	case "api_key":
+		if a.Key == "" {
+			return fmt.Errorf("http: api_key auth requires key")
+		}
+	default:
+		return fmt.Errorf("http: unsupported auth type %q", a.Type)
+	}
+	return nil
+}
+
+// resolveURL 相对路径拼接到 base_url
+func resolveURL(base, ref string) (*url.URL, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, err
+	}
+	if u.IsAbs() || base == "" {
+		return u, nil
+	}
+	b, err := url.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(b.Path, "/") {
+		b.Path += "/"
+	}
+	return b.ResolveReference(&url.URL{Path: strings.TrimPrefix(u.Path, "/"), RawQuery: u.RawQuery}), nil
+}
@@ -1,104 +1,222 @@
 package httpclient
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
-	"math/rand"
 	"net/http"
-	"sensor-edge/protocols"
+	"sort"
+	"sync"
 	"time"
 
+	"sensor-edge/protocols"
 )
 
+// HTTPClient HTTP/REST 客户端。点位地址为取值表达式：$ 开头为 JSONPath，/ 开头为 XPath；
+// 点位分组的 function 选择 requests 中的具名请求，为空或不存在时使用默认请求（url）
 type HTTPClient struct {
-	URL    string
-	Method string
+	conf     deviceConfig
+	client   *http.Client
+	requests map[string]*compiledRequest // "" 为默认请求
+
+	pmu    sync.RWMutex
+	points map[string]pointTable // 设备 ID -> 点位配置
 }
 
+// pointTable 按地址和点位名索引的点位配置
+type pointTable map[string]protocols.PointConfig
+
+// Init 连接参数：
+//
+//	base_url: https://api.example.com/v1
+//	url: /devices/{{.DeviceID}}/status   # 默认请求，完整 URL 或相对 base_url 的路径
+//	method: GET
+//	headers: {Accept: application/json}
+//	query: {fields: all}
+//	body: ""
+//	timeout: 5000                        # 单次请求超时时间(毫秒)
+//	auth: {type: bearer, token: "********"}   # basic | bearer | api_key
+//	tls: {insecure_skip_verify: false, ca_file: "", cert_file: "", key_file: ""}
+//	requests:                            # 具名请求，点位分组的 function 引用
+//	  alarms:
+//	    url: /alarms
+//	    pagination: {type: page, param: page, start: 1, size_param: per_page, size: 50, items: $.data}
+//
+// 点位的写入请求在点位配置的 write 中定义（格式同 requests，method 默认 POST）
 func (h *HTTPClient) Init(config map[string]interface{}) error {
-	h.URL = config["url"].(string)
-	h.Method = config["method"].(string)
-	if mock, ok := config["mock"].(bool); ok && mock {
-		h.URL = "MOCK"
+	var conf deviceConfig
+	if err := decodeConfig(config, &conf); err != nil {
+		return fmt.Errorf("http: invalid config: %v", err)
+	}
+	if err := conf.Auth.validate(); err != nil {
+		return err
+	}
+	if conf.URL == "" && len(conf.Requests) == 0 {
+		return fmt.Errorf("http: url or requests is required")
+	}
+	requests := make(map[string]*compiledRequest)
+	if conf.URL != "" {
+		cr, err := compileRequest("default", conf.requestConfig, "GET")
+		if err != nil {
+			return err
+		}
+		requests[""] = cr
+	}
+	for name, rc := range conf.Requests {
+		cr, err := compileRequest(name, rc, "GET")
+		if err != nil {
+			return err
+		}
+		requests[name] = cr
 	}
+	client, err := newHTTPClient(conf)
+	if err != nil {
+		return err
+	}
+	h.conf, h.client, h.requests = conf, client, requests
 	return nil
 }
 
+// SetPointConfigs 记录设备的点位配置（写入模板 write）
+func (h *HTTPClient) SetPointConfigs(deviceID string, points []protocols.PointConfig) {
+	table := make(pointTable, len(points)*2)
+	for _, p := range points {
+		if p.Address != "" {
+			table[p.Address] = p
+		}
+		if p.PointID != "" {
+			table[p.PointID] = p
+		}
+	}
+	h.pmu.Lock()
+	defer h.pmu.Unlock()
+	if h.points == nil {
+		h.points = make(map[string]pointTable)
+	}
+	h.points[deviceID] = table
+}
+
+// Read 用默认请求读取设备配置的全部点位，PointID 为点位名
 func (h *HTTPClient) Read(deviceID string) ([]protocols.PointValue, error) {
-	if h.URL == "MOCK" {
-		now := time.Now().Unix()
-		// 根据设备ID自定义不同mock数据
-		switch deviceID {
-		case "plc1":
-			// 模拟风机状态和转速
-			fanStatus := rand.Intn(2) == 1
-			fanSpeed := 400 + rand.Intn(2000) // 400~2400
-			return []protocols.PointValue{
-				{PointID: "d100", Value: fanStatus, Quality: "good", Timestamp: now},
-				{PointID: "d101", Value: fanSpeed, Quality: "good", Timestamp: now},
-			}, nil
-		case "sensor_http":
-			// 模拟温度
-			temp := 20.0 + rand.Float64()*80.0 // 20~100
-			return []protocols.PointValue{
-				{PointID: "temperature", Value: temp, Quality: "good", Timestamp: now},
-			}, nil
-		default:
-			return []protocols.PointValue{}, nil
+	h.pmu.RLock()
+	names := make(map[string]string)
+	for key, p := range h.points[deviceID] {
+		if key == p.PointID {
+			names[p.PointID] = p.Address
 		}
 	}
-	resp, err := http.Get(h.URL)
-	if err != nil {
-		// 网络异常时返回模拟数据
-		return []protocols.PointValue{
-			{
-				PointID:   "http_temp",
-				Value:     22.0,
-				Quality:   "mock",
-				Timestamp: time.Now().Unix(),
-			},
-		}, nil
-	}
-	defer resp.Body.Close()
-
-	body, _ := io.ReadAll(resp.Body)
-
-	var parsed map[string]interface{}
-	if err := json.Unmarshal(body, &parsed); err != nil {
-		return nil, err
-	}
-
-	val := parsed["temp"] // 假设返回 {"temp": 23.5}
-	return []protocols.PointValue{
-		{
-			PointID:   "http_temp",
-			Value:     val,
-			Quality:   "good",
-			Timestamp: time.Now().Unix(),
-		},
-	}, nil
+	h.pmu.RUnlock()
+	ids := make([]string, 0, len(names))
+	for id := range names {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	exprs := make([]string, len(ids))
+	for i, id := range ids {
+		exprs[i] = names[id]
+	}
+	values, err := h.readPoints(deviceID, "", exprs)
+	for i := range values {
+		values[i].PointID = ids[i]
+	}
+	return values, err
 }
 
-func (h *HTTPClient) ReadBatch(deviceID , number string, points []string) ([]protocols.PointValue, error) {
-	//不支持批量读取，直接返回空
-	 return nil, nil
+// ReadBatch 执行 function 对应的请求（含分页），按各点位的取值表达式取值，PointID 为表达式。
+// 请求失败时全部点位为 bad 并返回错误；表达式无匹配的点位为 bad
+func (h *HTTPClient) ReadBatch(deviceID string, function string, points []string) ([]protocols.PointValue, error) {
+	if len(points) == 0 {
+		return nil, nil
+	}
+	return h.readPoints(deviceID, function, points)
 }
 
+func (h *HTTPClient) readPoints(deviceID, function string, exprs []string) ([]protocols.PointValue, error) {
+	values := make([]protocols.PointValue, len(exprs))
+	cr, ok := h.requests[function]
+	if !ok {
+		cr, ok = h.requests[""]
+	}
+	if !ok {
+		for i, expr := range exprs {
+			values[i] = badValue(expr)
+		}
+		return values, fmt.Errorf("http: no request for function %q", function)
+	}
+	pages, err := h.fetch(cr, templateData{DeviceID: deviceID})
+	if err != nil {
+		for i, expr := range exprs {
+			values[i] = badValue(expr)
+		}
+		return values, err
+	}
+	for i, expr := range exprs {
+		v, err := extract(pages, expr)
+		if err != nil {
+			values[i] = badValue(expr)
+			continue
+		}
+		values[i] = goodValue(expr, v)
+	}
+	return values, nil
+}
+
+// Write 按点位配置中的 write 请求模板写入，{{.Value}} 为写入值。point 为点位地址或点位名
 func (h *HTTPClient) Write(point string, value interface{}) error {
-	// mock写入直接打印
-	if h.URL == "MOCK" {
-		fmt.Printf("[MOCK WRITE] %s <- %v\n", point, value)
-		return nil
+	deviceID, p, ok := h.lookupPoint(point)
+	if !ok {
+		return fmt.Errorf("http: point %s not configured", point)
 	}
-	// 真实http写入可扩展
-	return nil
+	raw, ok := p.Options["write"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("http: point %s has no write request", point)
+	}
+	var rc requestConfig
+	if err := decodeConfig(raw, &rc); err != nil {
+		return fmt.Errorf("http: point %s: invalid write request: %v", point, err)
+	}
+	rc.Pagination = nil
+	cr, err := compileRequest(p.PointID+".write", rc, "POST")
+	if err != nil {
+		return err
+	}
+	req, err := h.newRequest(cr, templateData{DeviceID: deviceID, Point: p.PointID, Value: value}, nil, "")
+	if err != nil {
+		return err
+	}
+	_, _, err = h.do(req)
+	return err
+}
+
+func (h *HTTPClient) lookupPoint(point string) (string, protocols.PointConfig, bool) {
+	h.pmu.RLock()
+	defer h.pmu.RUnlock()
+	for deviceID, table := range h.points {
+		if p, ok := table[point]; ok {
+			return deviceID, p, true
+		}
+	}
+	return "", protocols.PointConfig{}, false
 }
 
 func (h *HTTPClient) Close() error {
+	if h.client != nil {
+		h.client.CloseIdleConnections()
+	}
 	return nil
 }
 
+// Reconnect 关闭空闲连接，下次请求重新建立
+func (h *HTTPClient) Reconnect() error {
+	return h.Close()
+}
+
+func goodValue(id string, v interface{}) protocols.PointValue {
+	return protocols.PointValue{PointID: id, Value: v, Quality: "good", Timestamp: time.Now().Unix()}
+}
+
+func badValue(id string) protocols.PointValue {
+	return protocols.PointValue{PointID: id, Value: nil, Quality: "bad", Timestamp: time.Now().Unix()}
+}
+
 func NewHTTPClient() protocols.Protocol {
 	return &HTTPClient{}
 }
@@ -106,7 +224,3 @@ func NewHTTPClient() protocols.Protocol {
 func init() {
 	protocols.Register("http", NewHTTPClient)
 }
-
-func (h *HTTPClient) Reconnect() error {
-	return nil
-}
\ No newline at end of file
@@ -0,0 +1,190 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"sensor-edge/protocols"
+)
+
+const statusXML = `<?xml version="1.0"?>
+<status><unit id="1"><temp>21.5</temp></unit><unit id="2"><temp>23.0</temp></unit><mode state="auto"/></status>`
+
+func TestExtract(t *testing.T) {
+	doc := &page{body: []byte(`{"meta":{"temp":23.5,"ok":true},"items":[{"id":"a","v":1},{"id":"b","v":2},{"id":"c","v":3}]}`)}
+	xml := &page{body: []byte(statusXML)}
+	cases := []struct {
+		p    *page
+		expr string
+		want string
+	}{
+		{doc, "$.meta.temp", "23.5"},
+		{doc, "$['meta']['ok']", "true"},
+		{doc, "$.items[-1].v", "3"},
+		{doc, "$.items[?(@.id == 'b')].v", "2"},
+		{doc, "$.items[?(@.v > 1)].id", "[b c]"},
+		{doc, "$.items[0:2].id", "[a b]"},
+		{doc, "$..v", "[1 2 3]"},
+		{xml, "/status/unit[2]/temp", "23.0"},
+		{xml, "/status/unit[@id='1']/temp", "21.5"},
+		{xml, "//temp", "[21.5 23.0]"},
+		{xml, "/status/mode/@state", "auto"},
+	}
+	for _, c := range cases {
+		v, err := extract([]*page{c.p}, c.expr)
+		if err != nil {
+			t.Errorf("%s: %v", c.expr, err)
+			continue
+		}
+		if got := fmt.Sprint(v); got != c.want {
+			t.Errorf("%s = %s, want %s", c.expr, got, c.want)
+		}
+	}
+	for _, expr := range []string{"$.meta.missing", "/status/unit[3]", "temp"} {
+		if _, err := extract([]*page{doc, xml}, expr); err == nil {
+			t.Errorf("%s: expect error", expr)
+		}
+	}
+}
+
+// testServer 模拟设备接口：/status 需要 bearer 认证，/items 按页返回，/xml 返回 XML，/setpoint 记录写入
+func testServer(t *testing.T) (*httptest.Server, func() []string) {
+	var mu sync.Mutex
+	var writes []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `{"device":%q,"temp":23.5,"fan":{"speed":1200}}`, r.URL.Query().Get("device"))
+	})
+	mux.HandleFunc("/api/items", func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		items := map[int][]int{1: {1, 2}, 2: {3, 4}, 3: {5}}[page]
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": items, "total": 5})
+	})
+	mux.HandleFunc("/api/xml", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, statusXML)
+	})
+	mux.HandleFunc("/api/setpoint", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		writes = append(writes, r.Method+" "+r.URL.RequestURI()+" "+r.Header.Get("Content-Type")+" "+string(body))
+		mu.Unlock()
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), writes...)
+	}
+}
+
+func TestReadBatch(t *testing.T) {
+	srv, writes := testServer(t)
+	h := &HTTPClient{}
+	err := h.Init(map[string]interface{}{
+		"base_url": srv.URL + "/api",
+		"url":      "/status",
+		"query":    map[string]interface{}{"device": "{{.DeviceID}}"},
+		"auth":     map[string]interface{}{"type": "bearer", "token": "secret"},
+		"requests": map[string]interface{}{
+			"items": map[string]interface{}{
+				"url":        "items",
+				"pagination": map[string]interface{}{"type": "page", "param": "page", "start": 1, "size": 2, "items": "$.data"},
+			},
+			"xml": map[string]interface{}{"url": "/xml"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.SetPointConfigs("meter_1", []protocols.PointConfig{
+		{PointID: "temperature", Address: "$.temp"},
+		{PointID: "setpoint", Address: "$.setpoint", Options: map[string]interface{}{
+			"write": map[string]interface{}{"url": "/setpoint?device={{.DeviceID}}", "body": `{"point":"{{.Point}}","value":{{json .Value}}}`},
+		}},
+	})
+
+	values, err := h.ReadBatch("meter_1", "", []string{"$.temp", "$.fan.speed", "$.device", "$.missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"23.5 good", "1200 good", "meter_1 good", "<nil> bad"}
+	for i, v := range values {
+		if got := fmt.Sprint(v.Value, " ", v.Quality); got != want[i] || v.PointID == "" {
+			t.Errorf("%s = %s, want %s", v.PointID, got, want[i])
+		}
+	}
+
+	values, err = h.ReadBatch("meter_1", "items", []string{"$.data[*]", "$.total"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(values[0].Value) != "[1 2 3 4 5]" || values[1].Value != 5.0 {
+		t.Errorf("paged values: %+v", values)
+	}
+
+	values, err = h.ReadBatch("meter_1", "xml", []string{"/status/unit[@id='2']/temp"})
+	if err != nil || values[0].Value != "23.0" {
+		t.Errorf("xml values: %+v, %v", values, err)
+	}
+
+	values, err = h.Read("meter_1")
+	if err != nil || len(values) != 2 || values[1].PointID != "temperature" || values[1].Value != 23.5 || values[0].Quality != "bad" {
+		t.Errorf("Read: %+v, %v", values, err)
+	}
+
+	if err := h.Write("$.setpoint", 21.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Write("temperature", 1); err == nil {
+		t.Error("write without template: expect error")
+	}
+	if got := writes(); len(got) != 1 || got[0] != `POST /api/setpoint?device=meter_1 application/json {"point":"setpoint","value":21.5}` {
+		t.Errorf("writes: %q", got)
+	}
+}
+
+func TestReadFailure(t *testing.T) {
+	srv, _ := testServer(t)
+	h := &HTTPClient{}
+	// 缺少认证：401
+	if err := h.Init(map[string]interface{}{"url": srv.URL + "/api/status"}); err != nil {
+		t.Fatal(err)
+	}
+	values, err := h.ReadBatch("meter_1", "", []string{"$.temp"})
+	if se, ok := err.(*StatusError); !ok || se.Status != http.StatusUnauthorized {
+		t.Errorf("expect 401 StatusError, got %v", err)
+	}
+	if len(values) != 1 || values[0].Quality != "bad" || values[0].Value != nil {
+		t.Errorf("expect bad value, got %+v", values)
+	}
+	// 连接失败
+	srv.Close()
+	h.Reconnect()
+	values, err = h.ReadBatch("meter_1", "", []string{"$.temp"})
+	if err == nil || len(values) != 1 || values[0].Quality != "bad" || values[0].Value != nil {
+		t.Errorf("expect bad value and error, got %+v, %v", values, err)
+	}
+
+	for _, bad := range []map[string]interface{}{
+		{},
+		{"url": "/x", "auth": map[string]interface{}{"type": "bearer"}},
+		{"url": "/x", "auth": map[string]interface{}{"type": "digest"}},
+		{"url": "/x", "pagination": map[string]interface{}{"type": "page"}},
+		{"url": "/{{.Missing"},
+	} {
+		if err := (&HTTPClient{}).Init(bad); err == nil {
+			t.Errorf("%v: expect error", bad)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"sensor-edge/utils"
+)
+
+const maxBodySize = 16 << 20 // 单个响应体上限
+
+// templateData 请求模板可用的数据
+type templateData struct {
+	DeviceID string
+	Point    string
+	Value    interface{}
+}
+
+func jsonString(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func render(t *template.Template, data templateData) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// page 一页响应体，按取值表达式的类型惰性解析为 JSON 或 XML
+type page struct {
+	body    []byte
+	json    interface{}
+	jsonErr error
+	xml     *utils.XMLNode
+	xmlErr  error
+	parsed  [2]bool
+}
+
+func (p *page) jsonDoc() (interface{}, error) {
+	if !p.parsed[0] {
+		p.parsed[0] = true
+		p.jsonErr = json.Unmarshal(p.body, &p.json)
+	}
+	return p.json, p.jsonErr
+}
+
+func (p *page) xmlDoc() (*utils.XMLNode, error) {
+	if !p.parsed[1] {
+		p.parsed[1] = true
+		p.xml, p.xmlErr = utils.ParseXML(p.body)
+	}
+	return p.xml, p.xmlErr
+}
+
+// expression 点位取值表达式：$ 开头为 JSONPath，/ 开头为 XPath
+type expression struct {
+	json *utils.JSONPath
+	xml  *utils.XPath
+}
+
+func compileExpression(expr string) (expression, error) {
+	s := strings.TrimSpace(expr)
+	switch {
+	case strings.HasPrefix(s, "$"):
+		p, err := utils.CompileJSONPath(s)
+		return expression{json: p}, err
+	case strings.HasPrefix(s, "/"):
+		p, err := utils.CompileXPath(s)
+		return expression{xml: p}, err
+	}
+	return expression{}, fmt.Errorf("unsupported expression %q: use JSONPath ($...) or XPath (/...)", expr)
+}
+
+func (e expression) definite() bool {
+	if e.json != nil {
+		return e.json.Definite()
+	}
+	return e.xml.Definite()
+}
+
+func (e expression) eval(p *page) ([]interface{}, error) {
+	if e.json != nil {
+		doc, err := p.jsonDoc()
+		if err != nil {
+			return nil, fmt.Errorf("invalid json response: %v", err)
+		}
+		return e.json.Eval(doc), nil
+	}
+	doc, err := p.xmlDoc()
+	if err != nil {
+		return nil, fmt.Errorf("invalid xml response: %v", err)
+	}
+	return e.xml.Eval(doc), nil
+}
+
+// extract 在各页响应中求值：固定路径取第一个匹配的页，含通配、过滤的表达式合并各页结果。
+// 只有一个匹配时返回该值，多个匹配返回 []interface{}，没有匹配返回错误
+func extract(pages []*page, expr string) (interface{}, error) {
+	e, err := compileExpression(expr)
+	if err != nil {
+		return nil, err
+	}
+	var matches []interface{}
+	for _, p := range pages {
+		values, err := e.eval(p)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, values...)
+		if len(matches) > 0 && e.definite() {
+			break
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("no match for %s", expr)
+	case 1:
+		return matches[0], nil
+	}
+	return matches, nil
+}
+
+// StatusError 非 2xx 响应
+type StatusError struct {
+	Method string
+	URL    string
+	Status int
+	Body   string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("http: %s %s: status %d: %s", e.Method, e.URL, e.Status, e.Body)
+}
+
+// newRequest 渲染模板生成请求；pageURL 不为空时（link 分页）直接请求该 URL，extra 为分页参数
+func (h *HTTPClient) newRequest(cr *compiledRequest, data templateData, extra url.Values, pageURL string) (*http.Request, error) {
+	ref := pageURL
+	if ref == "" {
+		s, err := render(cr.url, data)
+		if err != nil {
+			return nil, err
+		}
+		ref = s
+	}
+	u, err := resolveURL(h.conf.BaseURL, ref)
+	if err != nil {
+		return nil, err
+	}
+	if pageURL == "" {
+		q := u.Query()
+		for k, t := range cr.query {
+			v, err := render(t, data)
+			if err != nil {
+				return nil, err
+			}
+			q.Set(k, v)
+		}
+		for k := range extra {
+			q.Set(k, extra.Get(k))
+		}
+		u.RawQuery = q.Encode()
+	}
+	body, err := render(cr.body, data)
+	if err != nil {
+		return nil, err
+	}
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(cr.method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range h.conf.Headers {
+		req.Header.Set(k, v)
+	}
+	for k, t := range cr.headers {
+		v, err := render(t, data)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set(k, v)
+	}
+	if body != "" && req.Header.Get("Content-Type") == "" {
+		if trimmed := strings.TrimSpace(body); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	}
+	if err := h.conf.Auth.apply(req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// do 发送请求，非 2xx 响应返回 *StatusError
+func (h *HTTPClient) do(req *http.Request) ([]byte, http.Header, error) {
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		snippet := string(body)
+		if len(snippet) > 200 {
+			snippet = snippet[:200]
+		}
+		return nil, nil, &StatusError{Method: req.Method, URL: req.URL.Redacted(), Status: resp.StatusCode, Body: snippet}
+	}
+	return body, resp.Header, nil
+}
+
+// fetch 执行请求模板，配置分页时依次请求各页（最多 max_pages 页）
+func (h *HTTPClient) fetch(cr *compiledRequest, data templateData) ([]*page, error) {
+	p := cr.pagination
+	var pages []*page
+	var cursor, nextURL string
+	for n := 0; ; n++ {
+		extra := url.Values{}
+		if p != nil {
+			switch p.Type {
+			case "page":
+				extra.Set(p.Param, strconv.Itoa(p.Start+n))
+			case "offset":
+				extra.Set(p.Param, strconv.Itoa(p.Start+n*p.Size))
+			case "cursor":
+				if n > 0 {
+					extra.Set(p.Param, cursor)
+				}
+			}
+			if p.SizeParam != "" && p.Size > 0 {
+				extra.Set(p.SizeParam, strconv.Itoa(p.Size))
+			}
+		}
+		req, err := h.newRequest(cr, data, extra, nextURL)
+		if err != nil {
+			return nil, err
+		}
+		body, header, err := h.do(req)
+		if err != nil {
+			return nil, err
+		}
+		pg := &page{body: body}
+		pages = append(pages, pg)
+		if p == nil || n+1 >= p.MaxPages {
+			return pages, nil
+		}
+		switch p.Type {
+		case "page", "offset":
+			items, _ := extract([]*page{pg}, p.Items)
+			list, ok := items.([]interface{})
+			if !ok || len(list) == 0 || (p.Size > 0 && len(list) < p.Size) {
+				return pages, nil
+			}
+		case "cursor":
+			next, err := extract([]*page{pg}, p.Next)
+			if err != nil || next == nil || fmt.Sprint(next) == "" {
+				return pages, nil
+			}
+			cursor = fmt.Sprint(next)
+		case "link":
+			next := ""
+			if p.Next != "" {
+				if v, err := extract([]*page{pg}, p.Next); err == nil && v != nil {
+					next = fmt.Sprint(v)
+				}
+			} else {
+				next = linkNext(header.Get("Link"))
+			}
+			if next == "" {
+				return pages, nil
+			}
+			u, err := req.URL.Parse(next)
+			if err != nil {
+				return nil, err
+			}
+			nextURL = u.String()
+		}
+	}
+}
+
+var linkNextPattern = regexp.MustCompile(`<([^>]*)>\s*;[^,]*rel="?next"?`)
+
+// linkNext 取 Link 响应头中 rel="next" 的 URL
+func linkNext(link string) string {
+	if m := linkNextPattern.FindStringSubmatch(link); m != nil {
+		return m[1]
+	}
+	return ""
+}
@@ -0,0 +1,313 @@
+package iec104
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 类型标识（IEC 60870-5-101/104 7.2.1）
+const (
+	typeSinglePoint        byte = 1  // M_SP_NA_1 单点信息
+	typeDoublePoint        byte = 3  // M_DP_NA_1 双点信息
+	typeStepPosition       byte = 5  // M_ST_NA_1 步位置信息
+	typeBitstring          byte = 7  // M_BO_NA_1 32 比特串
+	typeNormalized         byte = 9  // M_ME_NA_1 测量值，规一化值
+	typeScaled             byte = 11 // M_ME_NB_1 测量值，标度化值
+	typeFloat              byte = 13 // M_ME_NC_1 测量值，短浮点数
+	typeCounter            byte = 15 // M_IT_NA_1 累计量
+	typeNormalizedNoQ      byte = 21 // M_ME_ND_1 不带品质描述的规一化值
+	typeSinglePointTime    byte = 30 // M_SP_TB_1 带 CP56Time2a 时标的单点信息
+	typeDoublePointTime    byte = 31 // M_DP_TB_1
+	typeStepPositionTime   byte = 32 // M_ST_TB_1
+	typeBitstringTime      byte = 33 // M_BO_TB_1
+	typeNormalizedTime     byte = 34 // M_ME_TD_1
+	typeScaledTime         byte = 35 // M_ME_TE_1
+	typeFloatTime          byte = 36 // M_ME_TF_1
+	typeCounterTime        byte = 37 // M_IT_TB_1
+	typeSingleCommand      byte = 45 // C_SC_NA_1 单命令
+	typeDoubleCommand      byte = 46 // C_DC_NA_1 双命令
+	typeSetpointNorm       byte = 48 // C_SE_NA_1 设定值命令，规一化值
+	typeSetpointScaled     byte = 49 // C_SE_NB_1 设定值命令，标度化值
+	typeSetpointFloat      byte = 50 // C_SE_NC_1 设定值命令，短浮点数
+	typeSingleCommandTime  byte = 58 // C_SC_TA_1 带时标的单命令
+	typeDoubleCommandTime  byte = 59 // C_DC_TA_1
+	typeSetpointNormTime   byte = 61 // C_SE_TA_1
+	typeSetpointScaledTime byte = 62 // C_SE_TB_1
+	typeSetpointFloatTime  byte = 63 // C_SE_TC_1
+	typeInterrogation      byte = 100
+	typeCounterInterrog    byte = 101
+)
+
+var typeNames = map[byte]string{
+	typeSinglePoint: "M_SP_NA_1", typeDoublePoint: "M_DP_NA_1", typeStepPosition: "M_ST_NA_1",
+	typeBitstring: "M_BO_NA_1", typeNormalized: "M_ME_NA_1", typeScaled: "M_ME_NB_1",
+	typeFloat: "M_ME_NC_1", typeCounter: "M_IT_NA_1", typeNormalizedNoQ: "M_ME_ND_1",
+	typeSinglePointTime: "M_SP_TB_1", typeDoublePointTime: "M_DP_TB_1", typeStepPositionTime: "M_ST_TB_1",
+	typeBitstringTime: "M_BO_TB_1", typeNormalizedTime: "M_ME_TD_1", typeScaledTime: "M_ME_TE_1",
+	typeFloatTime: "M_ME_TF_1", typeCounterTime: "M_IT_TB_1",
+	typeSingleCommand: "C_SC_NA_1", typeDoubleCommand: "C_DC_NA_1", typeSetpointNorm: "C_SE_NA_1",
+	typeSetpointScaled: "C_SE_NB_1", typeSetpointFloat: "C_SE_NC_1",
+	typeSingleCommandTime: "C_SC_TA_1", typeDoubleCommandTime: "C_DC_TA_1", typeSetpointNormTime: "C_SE_TA_1",
+	typeSetpointScaledTime: "C_SE_TB_1", typeSetpointFloatTime: "C_SE_TC_1",
+	typeInterrogation: "C_IC_NA_1", typeCounterInterrog: "C_CI_NA_1",
+}
+
+// parseType 类型标识可以写数字或助记符（如 13、M_ME_NC_1）
+func parseType(s string) (byte, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		if n >= 0 && n < 256 && typeNames[byte(n)] != "" {
+			return byte(n), nil
+		}
+		return 0, fmt.Errorf("unsupported type id %d", n)
+	}
+	for id, name := range typeNames {
+		if strings.EqualFold(name, s) {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown type %q", s)
+}
+
+// baseType 带时标的监视类型归为对应的不带时标类型，点位按基本类型索引
+func baseType(t byte) byte {
+	switch {
+	case t >= typeSinglePointTime && t <= typeCounterTime:
+		return typeSinglePoint + (t-typeSinglePointTime)*2
+	case t >= typeSingleCommandTime && t <= typeSetpointFloatTime:
+		return t - (typeSingleCommandTime - typeSingleCommand)
+	}
+	return t
+}
+
+func isMonitorType(t byte) bool {
+	t = baseType(t)
+	return t >= typeSinglePoint && t <= typeCounter && t%2 == 1 || t == typeNormalizedNoQ
+}
+
+func isCommandType(t byte) bool {
+	t = baseType(t)
+	return t == typeSingleCommand || t == typeDoubleCommand || t >= typeSetpointNorm && t <= typeSetpointFloat
+}
+
+// 传送原因
+const (
+	causeSpontaneous byte = 3
+	causeAct         byte = 6
+	causeActCon      byte = 7
+	causeActTerm     byte = 10
+	causeInrogen     byte = 20 // 响应站召唤
+	causeReqcogen    byte = 37 // 响应计数量召唤
+)
+
+// causeNames 否定确认的传送原因（44~47）
+var causeNames = map[byte]string{
+	44: "unknown type id", 45: "unknown cause of transmission", 46: "unknown common address", 47: "unknown information object address",
+}
+
+// asdu 应用服务数据单元，传送原因 2 字节、公共地址 2 字节、信息对象地址 3 字节
+type asdu struct {
+	typeID   byte
+	sq       bool // 顺序的信息元素，只有第一个对象带地址
+	count    int
+	cause    byte
+	negative bool
+	test     bool
+	orig     byte
+	ca       uint16
+	body     []byte // 信息对象
+}
+
+func decodeASDU(b []byte) (*asdu, error) {
+	if len(b) < 6 {
+		return nil, fmt.Errorf("asdu too short: %d bytes", len(b))
+	}
+	return &asdu{
+		typeID:   b[0],
+		sq:       b[1]&0x80 != 0,
+		count:    int(b[1] & 0x7f),
+		cause:    b[2] & 0x3f,
+		negative: b[2]&0x40 != 0,
+		test:     b[2]&0x80 != 0,
+		orig:     b[3],
+		ca:       binary.LittleEndian.Uint16(b[4:]),
+		body:     b[6:],
+	}, nil
+}
+
+func (a *asdu) encode() []byte {
+	b := []byte{a.typeID, byte(a.count), a.cause, a.orig, byte(a.ca), byte(a.ca >> 8)}
+	if a.sq {
+		b[1] |= 0x80
+	}
+	if a.negative {
+		b[2] |= 0x40
+	}
+	if a.test {
+		b[2] |= 0x80
+	}
+	return append(b, a.body...)
+}
+
+// ioa 第一个信息对象的地址
+func (a *asdu) ioa() uint32 {
+	if len(a.body) < 3 {
+		return 0
+	}
+	return readIOA(a.body)
+}
+
+func readIOA(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16
+}
+
+func appendIOA(b []byte, ioa uint32) []byte {
+	return append(b, byte(ioa), byte(ioa>>8), byte(ioa>>16))
+}
+
+// infoObject 解码后的监视信息
+type infoObject struct {
+	ioa     uint32
+	value   interface{}
+	quality string
+	ts      time.Time // 设备时标，无时标或时标无效时为零值
+}
+
+// elementSize 监视类型信息元素（含品质描述和时标）的字节数
+func elementSize(t byte) int {
+	size := map[byte]int{
+		typeSinglePoint: 1, typeDoublePoint: 1, typeStepPosition: 2, typeBitstring: 5,
+		typeNormalized: 3, typeScaled: 3, typeFloat: 5, typeCounter: 5, typeNormalizedNoQ: 2,
+	}[baseType(t)]
+	if t != baseType(t) {
+		size += 7
+	}
+	return size
+}
+
+// objects 解码监视类型的全部信息对象，时标按 loc 解释
+func (a *asdu) objects(loc *time.Location) ([]infoObject, error) {
+	if !isMonitorType(a.typeID) {
+		return nil, fmt.Errorf("type %d is not a monitor type", a.typeID)
+	}
+	size := elementSize(a.typeID)
+	want := a.count * (size + 3)
+	if a.sq {
+		want = 3 + a.count*size
+	}
+	if a.count == 0 || len(a.body) != want {
+		return nil, fmt.Errorf("type %d: %d objects in %d bytes", a.typeID, a.count, len(a.body))
+	}
+	objs := make([]infoObject, a.count)
+	b := a.body
+	base := readIOA(b)
+	for i := range objs {
+		if a.sq {
+			if i == 0 {
+				b = b[3:]
+			}
+			objs[i].ioa = base + uint32(i)
+		} else {
+			objs[i].ioa = readIOA(b)
+			b = b[3:]
+		}
+		objs[i].value, objs[i].quality = decodeElement(baseType(a.typeID), b[:size])
+		if a.typeID != baseType(a.typeID) {
+			objs[i].ts, _ = decodeCP56(b[size-7:size], loc)
+		}
+		b = b[size:]
+	}
+	return objs, nil
+}
+
+// decodeElement 按基本类型解码信息元素
+func decodeElement(t byte, e []byte) (interface{}, string) {
+	switch t {
+	case typeSinglePoint:
+		return e[0]&0x01 != 0, qualityOf(e[0] & 0xf0)
+	case typeDoublePoint:
+		switch e[0] & 0x03 {
+		case 1:
+			return false, qualityOf(e[0] & 0xf0)
+		case 2:
+			return true, qualityOf(e[0] & 0xf0)
+		}
+		if e[0]&0x80 != 0 {
+			return nil, "bad:invalid"
+		}
+		return nil, "bad:indeterminate"
+	case typeStepPosition:
+		return int8(e[0]<<1) >> 1, qualityOf(e[1])
+	case typeBitstring:
+		return binary.LittleEndian.Uint32(e), qualityOf(e[4])
+	case typeNormalized:
+		return float64(int16(binary.LittleEndian.Uint16(e))) / 32768, qualityOf(e[2])
+	case typeScaled:
+		return int16(binary.LittleEndian.Uint16(e)), qualityOf(e[2])
+	case typeFloat:
+		return math.Float32frombits(binary.LittleEndian.Uint32(e)), qualityOf(e[4])
+	case typeCounter:
+		return int32(binary.LittleEndian.Uint32(e)), counterQuality(e[4])
+	case typeNormalizedNoQ:
+		return float64(int16(binary.LittleEndian.Uint16(e))) / 32768, "good"
+	}
+	return nil, "bad"
+}
+
+// qualityOf 品质描述词：IV 无效为 bad，NT/SB/BL/OV 为 uncertain 并列出原因
+func qualityOf(q byte) string {
+	if q&0x80 != 0 {
+		return "bad:invalid"
+	}
+	var flags []string
+	for _, f := range []struct {
+		bit  byte
+		name string
+	}{{0x40, "not_topical"}, {0x20, "substituted"}, {0x10, "blocked"}, {0x01, "overflow"}} {
+		if q&f.bit != 0 {
+			flags = append(flags, f.name)
+		}
+	}
+	if len(flags) == 0 {
+		return "good"
+	}
+	return "uncertain:" + strings.Join(flags, ",")
+}
+
+// counterQuality 累计量顺序记号中的 IV/CA/CY
+func counterQuality(s byte) string {
+	if s&0x80 != 0 {
+		return "bad:invalid"
+	}
+	var flags []string
+	if s&0x40 != 0 {
+		flags = append(flags, "adjusted")
+	}
+	if s&0x20 != 0 {
+		flags = append(flags, "carry")
+	}
+	if len(flags) == 0 {
+		return "good"
+	}
+	return "uncertain:" + strings.Join(flags, ",")
+}
+
+// decodeCP56 七字节时标 CP56Time2a，IV 位置位时返回错误
+func decodeCP56(b []byte, loc *time.Location) (time.Time, error) {
+	if b[2]&0x80 != 0 {
+		return time.Time{}, fmt.Errorf("invalid time tag")
+	}
+	ms := int(binary.LittleEndian.Uint16(b))
+	return time.Date(2000+int(b[6]&0x7f), time.Month(b[5]&0x0f), int(b[4]&0x1f),
+		int(b[3]&0x1f), int(b[2]&0x3f), ms/1000, ms%1000*int(time.Millisecond), loc), nil
+}
+
+func appendCP56(b []byte, t time.Time) []byte {
+	ms := t.Second()*1000 + t.Nanosecond()/int(time.Millisecond)
+	dow := (int(t.Weekday())+6)%7 + 1 // 1 为星期一
+	return append(b, byte(ms), byte(ms>>8), byte(t.Minute()), byte(t.Hour()),
+		byte(t.Day()|dow<<5), byte(t.Month()), byte(t.Year()-2000))
+}
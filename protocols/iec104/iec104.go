@@ -0,0 +1,688 @@
+package iec104
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sensor-edge/protocols"
+	"sensor-edge/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IEC104Client IEC 60870-5-104 主站（客户端）。连接建立后发送 STARTDT 并对各公共地址总召唤，
+// 之后站端的突发（自发）上送和召唤响应更新缓存，Read/ReadBatch 返回各点位的最新值。
+// 点位地址为 "公共地址:信息对象地址:类型标识"（公共地址省略时取设备的 common_address），
+// 类型标识可写数字或助记符，带时标的类型与不带时标的类型归为同一点位。
+// 点位分组的 function 为 gi（总召唤）或 ci（计数量召唤）时每次采集先召唤再返回
+type IEC104Client struct {
+	conf deviceConfig
+	addr string
+	tm   timers
+	loc  *time.Location
+
+	mu   sync.Mutex // 连接建立、召唤和命令串行进行
+	link *link
+
+	vmu     sync.RWMutex
+	values  map[pointKey]sample
+	devices map[string]*device
+	waiter  *waiter
+}
+
+// deviceConfig 连接参数，t0~t3 单位为秒
+type deviceConfig struct {
+	IP            string  `yaml:"ip"`
+	Port          int     `yaml:"port"`
+	CommonAddress int     `yaml:"common_address"`
+	Originator    int     `yaml:"originator_address"`
+	T0            float64 `yaml:"t0"`
+	T1            float64 `yaml:"t1"`
+	T2            float64 `yaml:"t2"`
+	T3            float64 `yaml:"t3"`
+	K             int     `yaml:"k"`
+	W             int     `yaml:"w"`
+	Timeout       int     `yaml:"timeout"`   // 等待召唤结束和命令确认的时间(毫秒)
+	QOI           int     `yaml:"qoi"`       // 召唤限定词，20 为站召唤
+	QCC           int     `yaml:"qcc"`       // 计数量召唤限定词，5 为总的请求计数量
+	TimeZone      string  `yaml:"time_zone"` // 时标的时区，默认本地时区
+}
+
+// pointKey 点位按公共地址、信息对象地址和基本类型标识索引
+type pointKey struct {
+	ca     uint16
+	ioa    uint32
+	typeID byte
+}
+
+// sample 点位最新值，ts 为站端时标（无时标时为接收时间）
+type sample struct {
+	value   interface{}
+	quality string
+	ts      time.Time
+}
+
+// device 一个公共地址上的点位配置
+type device struct {
+	ca      uint16
+	points  map[string]*point // 点位名和地址 -> 点位
+	names   []string
+	handler func(values []protocols.PointValue)
+}
+
+// point 解析好地址和命令参数的点位
+type point struct {
+	name       string
+	key        pointKey
+	command    byte // 写入使用的命令类型，0 为不可写
+	commandIOA uint32
+	selectCmd  bool // 先选择后执行
+	qualifier  byte // 命令的 QU 或设定值的 QL
+}
+
+// waiter 等待召唤或命令的确认和结束
+type waiter struct {
+	typeID byte
+	ca     uint16
+	ioa    uint32
+	ch     chan *asdu
+}
+
+// Init 连接参数：
+//
+//	ip: 192.168.1.70
+//	port: 2404
+//	common_address: 1       # 设备的公共地址，可在设备配置中单独指定
+//	originator_address: 0
+//	t0: 30                  # 建立连接超时(秒)
+//	t1: 15                  # 发送或测试 APDU 的确认超时(秒)
+//	t2: 10                  # 无数据时确认接收的超时(秒)，t2 < t1
+//	t3: 20                  # 空闲时发送测试帧的间隔(秒)
+//	k: 12                   # 未被确认的 I 帧最大数目
+//	w: 8                    # 接收 w 个 I 帧后确认
+//	timeout: 10000          # 等待召唤结束和命令确认的时间(毫秒)
+//	qoi: 20
+//	qcc: 5
+//	time_zone: Asia/Shanghai
+//
+// 点位配置 command 指定写入使用的命令类型（如 C_SC_NA_1、C_SE_TC_1），默认按监视类型选择
+// 单命令/双命令/设定值；command_ioa 为命令的信息对象地址，select: true 时先选择后执行，qualifier 为 QU/QL
+func (c *IEC104Client) Init(config map[string]interface{}) error {
+	var conf deviceConfig
+	if err := decodeConfig(config, &conf); err != nil {
+		return fmt.Errorf("iec104: invalid config: %v", err)
+	}
+	if conf.IP == "" {
+		return fmt.Errorf("iec104: ip is required")
+	}
+	if conf.Port == 0 {
+		conf.Port = 2404
+	}
+	if conf.CommonAddress == 0 {
+		conf.CommonAddress = 1
+	}
+	if conf.CommonAddress < 1 || conf.CommonAddress > 65535 {
+		return fmt.Errorf("iec104: invalid common_address %d", conf.CommonAddress)
+	}
+	if conf.QOI == 0 {
+		conf.QOI = 20
+	}
+	if conf.QCC == 0 {
+		conf.QCC = 5
+	}
+	if conf.Timeout <= 0 {
+		conf.Timeout = 10000
+	}
+	seconds := func(v, def float64) time.Duration {
+		if v <= 0 {
+			v = def
+		}
+		return time.Duration(v * float64(time.Second))
+	}
+	tm := timers{t0: seconds(conf.T0, 30), t1: seconds(conf.T1, 15), t2: seconds(conf.T2, 10), t3: seconds(conf.T3, 20), k: conf.K, w: conf.W}
+	if tm.k <= 0 {
+		tm.k = 12
+	}
+	if tm.w <= 0 {
+		tm.w = 8
+	}
+	if tm.t2 >= tm.t1 || tm.w > tm.k {
+		return fmt.Errorf("iec104: require t2 < t1 and w <= k")
+	}
+	loc := time.Local
+	if conf.TimeZone != "" {
+		l, err := time.LoadLocation(conf.TimeZone)
+		if err != nil {
+			return fmt.Errorf("iec104: invalid time_zone: %v", err)
+		}
+		loc = l
+	}
+	c.conf, c.tm, c.loc = conf, tm, loc
+	c.addr = net.JoinHostPort(conf.IP, strconv.Itoa(conf.Port))
+	return nil
+}
+
+// decodeConfig 把 YAML 解析出的 map 转换为结构体
+func decodeConfig(config map[string]interface{}, out interface{}) error {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, out)
+}
+
+// ConfigureDevice 记录设备的公共地址，同一站端的多个公共地址共享连接
+func (c *IEC104Client) ConfigureDevice(deviceID string, config map[string]interface{}) error {
+	ca := c.conf.CommonAddress
+	if v, ok := utils.ToFloat64(config["common_address"]); ok {
+		ca = int(v)
+	}
+	if ca < 1 || ca > 65535 {
+		return fmt.Errorf("iec104: device %s: invalid common_address %d", deviceID, ca)
+	}
+	c.vmu.Lock()
+	defer c.vmu.Unlock()
+	d := c.deviceLocked(deviceID)
+	d.ca = uint16(ca)
+	return nil
+}
+
+func (c *IEC104Client) deviceLocked(deviceID string) *device {
+	if c.devices == nil {
+		c.devices = make(map[string]*device)
+	}
+	d, ok := c.devices[deviceID]
+	if !ok {
+		d = &device{ca: uint16(c.conf.CommonAddress), points: make(map[string]*point)}
+		c.devices[deviceID] = d
+	}
+	return d
+}
+
+// SetPointConfigs 解析点位地址和命令参数，无效的点位读取为 bad
+func (c *IEC104Client) SetPointConfigs(deviceID string, points []protocols.PointConfig) {
+	c.vmu.Lock()
+	defer c.vmu.Unlock()
+	d := c.deviceLocked(deviceID)
+	d.points = make(map[string]*point, len(points)*2)
+	d.names = nil
+	for _, pc := range points {
+		p, err := parsePoint(pc, d.ca)
+		if err != nil {
+			log.Printf("[IEC104] device %s point %s: %v", deviceID, pc.PointID, err)
+			continue
+		}
+		if pc.PointID != "" {
+			if _, ok := d.points[pc.PointID]; !ok {
+				d.names = append(d.names, pc.PointID)
+			}
+			d.points[pc.PointID] = p
+		}
+		d.points[pc.Address] = p
+	}
+	sort.Strings(d.names)
+}
+
+// SetValueHandler 注册突发上送的回调（实现 protocols.PointSubscriber），召唤响应不回调，PointID 为点位名
+func (c *IEC104Client) SetValueHandler(deviceID string, handler func(values []protocols.PointValue)) {
+	c.vmu.Lock()
+	defer c.vmu.Unlock()
+	c.deviceLocked(deviceID).handler = handler
+}
+
+// parsePoint 地址为 "ca:ioa:type" 或 "ioa:type"
+func parsePoint(pc protocols.PointConfig, defaultCA uint16) (*point, error) {
+	parts := strings.Split(pc.Address, ":")
+	if len(parts) == 2 {
+		parts = append([]string{strconv.Itoa(int(defaultCA))}, parts...)
+	}
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid address %q, expect ca:ioa:type", pc.Address)
+	}
+	ca, err := strconv.Atoi(parts[0])
+	if err != nil || ca < 1 || ca > 65535 {
+		return nil, fmt.Errorf("invalid common address in %q", pc.Address)
+	}
+	ioa, err := strconv.Atoi(parts[1])
+	if err != nil || ioa < 0 || ioa > 0xffffff {
+		return nil, fmt.Errorf("invalid information object address in %q", pc.Address)
+	}
+	t, err := parseType(parts[2])
+	if err != nil {
+		return nil, err
+	}
+	if !isMonitorType(t) && !isCommandType(t) {
+		return nil, fmt.Errorf("type %s cannot be used as a point", typeNames[t])
+	}
+	p := &point{name: pc.PointID, key: pointKey{ca: uint16(ca), ioa: uint32(ioa), typeID: baseType(t)}, commandIOA: uint32(ioa)}
+	// 命令类型的点位只能写入
+	if isCommandType(t) {
+		p.command = t
+	} else {
+		p.command = map[byte]byte{
+			typeSinglePoint: typeSingleCommand, typeDoublePoint: typeDoubleCommand,
+			typeNormalized: typeSetpointNorm, typeScaled: typeSetpointScaled, typeFloat: typeSetpointFloat,
+		}[p.key.typeID]
+	}
+	switch v := pc.Options["command"].(type) {
+	case nil:
+	case string, int, float64:
+		if p.command, err = parseType(fmt.Sprint(v)); err != nil {
+			return nil, err
+		}
+		if !isCommandType(p.command) {
+			return nil, fmt.Errorf("%s is not a command type", typeNames[p.command])
+		}
+	default:
+		return nil, fmt.Errorf("invalid command %v", v)
+	}
+	if v, ok := utils.ToFloat64(pc.Options["command_ioa"]); ok {
+		if v < 0 || v > 0xffffff {
+			return nil, fmt.Errorf("invalid command_ioa %v", v)
+		}
+		p.commandIOA = uint32(v)
+	}
+	p.selectCmd, _ = pc.Options["select"].(bool)
+	if v, ok := utils.ToFloat64(pc.Options["qualifier"]); ok {
+		if v < 0 || v > 127 || v > 31 && baseType(p.command) <= typeDoubleCommand {
+			return nil, fmt.Errorf("invalid qualifier %v", v)
+		}
+		p.qualifier = byte(v)
+	}
+	return p, nil
+}
+
+// connect 建立连接、启动数据传输并对各公共地址总召唤，调用方持有 c.mu
+func (c *IEC104Client) connect() error {
+	if c.link != nil && c.link.alive() {
+		return nil
+	}
+	push := make(chan []update, pushQueue)
+	l, err := dial(c.addr, c.tm, func(a *asdu) { c.onASDU(a, push) })
+	if err != nil {
+		return fmt.Errorf("iec104: connect %s: %v", c.addr, err)
+	}
+	go pushLoop(l.closed, push)
+	if err := l.sendU(uStartDTAct); err != nil {
+		l.close(err)
+		return fmt.Errorf("iec104: STARTDT: %v", err)
+	}
+	c.link = l
+	// 新连接上的值以总召唤结果为准
+	c.vmu.Lock()
+	c.values = make(map[pointKey]sample)
+	cas := make(map[uint16]bool)
+	for _, d := range c.devices {
+		cas[d.ca] = true
+	}
+	c.vmu.Unlock()
+	if len(cas) == 0 {
+		cas[uint16(c.conf.CommonAddress)] = true
+	}
+	for ca := range cas {
+		if err := c.interrogate(typeInterrogation, ca, byte(c.conf.QOI)); err != nil {
+			log.Printf("[IEC104] general interrogation of %s ca %d failed: %v", c.addr, ca, err)
+		}
+	}
+	return nil
+}
+
+// interrogate 发送总召唤或计数量召唤并等待召唤结束
+func (c *IEC104Client) interrogate(typeID byte, ca uint16, qualifier byte) error {
+	return c.transact(&asdu{typeID: typeID, count: 1, cause: causeAct, orig: byte(c.conf.Originator), ca: ca,
+		body: append(appendIOA(nil, 0), qualifier)}, true)
+}
+
+// transact 发送激活并等待确认，term 为 true 时继续等待激活结束；否定确认返回错误
+func (c *IEC104Client) transact(req *asdu, term bool) error {
+	w := &waiter{typeID: req.typeID, ca: req.ca, ioa: req.ioa(), ch: make(chan *asdu, 16)}
+	c.vmu.Lock()
+	c.waiter = w
+	c.vmu.Unlock()
+	defer func() {
+		c.vmu.Lock()
+		c.waiter = nil
+		c.vmu.Unlock()
+	}()
+	if err := c.link.sendI(req); err != nil {
+		return err
+	}
+	timer := time.NewTimer(time.Duration(c.conf.Timeout) * time.Millisecond)
+	defer timer.Stop()
+	name := typeNames[req.typeID]
+	for {
+		select {
+		case a := <-w.ch:
+			switch {
+			case a.negative:
+				if s, ok := causeNames[a.cause]; ok {
+					return fmt.Errorf("iec104: %s ca %d ioa %d rejected: %s", name, req.ca, w.ioa, s)
+				}
+				return fmt.Errorf("iec104: %s ca %d ioa %d rejected", name, req.ca, w.ioa)
+			case a.cause == causeActCon && !term, a.cause == causeActTerm:
+				return nil
+			}
+		case <-c.link.closed:
+			return c.link.err
+		case <-timer.C:
+			return fmt.Errorf("iec104: %s ca %d ioa %d timeout", name, req.ca, w.ioa)
+		}
+	}
+}
+
+// onASDU 在连接的读协程中调用：监视信息更新缓存，确认和结束交给等待者，突发上送交给 pushLoop 回调
+func (c *IEC104Client) onASDU(a *asdu, push chan<- []update) {
+	if !isMonitorType(a.typeID) {
+		c.vmu.RLock()
+		w := c.waiter
+		c.vmu.RUnlock()
+		if w != nil && w.typeID == a.typeID && w.ca == a.ca && w.ioa == a.ioa() {
+			select {
+			case w.ch <- a:
+			default:
+			}
+		}
+		return
+	}
+	objs, err := a.objects(c.loc)
+	if err != nil {
+		log.Printf("[IEC104] %s: %v", c.addr, err)
+		return
+	}
+	now := time.Now()
+	// 召唤响应在采集时读取，其他原因（突发、周期等）的变化立即回调
+	spont := a.cause < causeInrogen || a.cause > causeReqcogen+4
+	var updates []update
+	c.vmu.Lock()
+	if c.values == nil {
+		c.values = make(map[pointKey]sample)
+	}
+	for _, o := range objs {
+		s := sample{value: o.value, quality: o.quality, ts: o.ts}
+		if s.ts.IsZero() {
+			s.ts = now
+		}
+		c.values[pointKey{ca: a.ca, ioa: o.ioa, typeID: baseType(a.typeID)}] = s
+	}
+	if spont {
+		for _, d := range c.devices {
+			if d.handler == nil || d.ca != a.ca {
+				continue
+			}
+			var values []protocols.PointValue
+			for _, name := range d.names {
+				p := d.points[name]
+				if p.key.typeID != baseType(a.typeID) {
+					continue
+				}
+				for _, o := range objs {
+					if o.ioa == p.key.ioa {
+						values = append(values, c.valueLocked(name, p))
+					}
+				}
+			}
+			if len(values) > 0 {
+				updates = append(updates, update{d.handler, values})
+			}
+		}
+	}
+	c.vmu.Unlock()
+	if len(updates) == 0 {
+		return
+	}
+	select {
+	case push <- updates:
+	default:
+		// 回调积压，丢弃本次推送；值已缓存，下一次采集照常返回
+		log.Printf("[IEC104] %s: push queue full, drop %d updates", c.addr, len(updates))
+	}
+}
+
+// pushQueue 等待回调的突发上送数
+const pushQueue = 64
+
+// update 一次突发上送中属于同一设备的点位值
+type update struct {
+	handler func([]protocols.PointValue)
+	values  []protocols.PointValue
+}
+
+// pushLoop 依次调用突发上送的回调，慢的北向上报不阻塞读协程（否则 I 帧无法在 t1/t2 内确认），连接关闭时退出
+func pushLoop(closed <-chan struct{}, push <-chan []update) {
+	for {
+		select {
+		case <-closed:
+			return
+		case updates := <-push:
+			for _, u := range updates {
+				u.handler(u.values)
+			}
+		}
+	}
+}
+
+func (c *IEC104Client) valueLocked(id string, p *point) protocols.PointValue {
+	s, ok := c.values[p.key]
+	if !ok {
+		return badValue(id)
+	}
+	v := protocols.PointValue{PointID: id, Value: s.value, Quality: s.quality, Timestamp: s.ts.Unix()}
+	if strings.HasPrefix(s.quality, "bad") {
+		v.Value = nil
+	}
+	return v
+}
+
+// Read 返回设备全部点位的最新值，PointID 为点位名
+func (c *IEC104Client) Read(deviceID string) ([]protocols.PointValue, error) {
+	c.vmu.RLock()
+	var names []string
+	if d := c.devices[deviceID]; d != nil {
+		names = append(names, d.names...)
+	}
+	c.vmu.RUnlock()
+	return c.readPoints(deviceID, "", names)
+}
+
+// ReadBatch 返回点位的最新值，points 为点位地址或点位名；function 为 gi/ci 时先对设备的公共地址召唤。
+// 连接断开时重新连接，失败时全部点位为 bad 并返回错误；未收到过数据的点位为 bad
+func (c *IEC104Client) ReadBatch(deviceID string, function string, points []string) ([]protocols.PointValue, error) {
+	if len(points) == 0 {
+		return nil, nil
+	}
+	return c.readPoints(deviceID, function, points)
+}
+
+func (c *IEC104Client) readPoints(deviceID, function string, keys []string) ([]protocols.PointValue, error) {
+	values := make([]protocols.PointValue, len(keys))
+	for i, key := range keys {
+		values[i] = badValue(key)
+	}
+	c.vmu.RLock()
+	ca := uint16(c.conf.CommonAddress)
+	if d := c.devices[deviceID]; d != nil {
+		ca = d.ca
+	}
+	c.vmu.RUnlock()
+	c.mu.Lock()
+	err := c.connect()
+	if err == nil {
+		switch strings.ToLower(function) {
+		case "":
+		case "gi", "interrogation":
+			err = c.interrogate(typeInterrogation, ca, byte(c.conf.QOI))
+		case "ci", "counter":
+			err = c.interrogate(typeCounterInterrog, ca, byte(c.conf.QCC))
+		default:
+			err = fmt.Errorf("iec104: unsupported function %q", function)
+		}
+	}
+	alive := c.link != nil && c.link.alive()
+	c.mu.Unlock()
+	if !alive {
+		return values, err
+	}
+	c.vmu.RLock()
+	defer c.vmu.RUnlock()
+	d := c.devices[deviceID]
+	for i, key := range keys {
+		var p *point
+		if d != nil {
+			p = d.points[key]
+		}
+		if p == nil {
+			// 未配置的点位直接按地址读取
+			if p, _ = parsePoint(protocols.PointConfig{Address: key}, ca); p == nil {
+				continue
+			}
+		}
+		values[i] = c.valueLocked(key, p)
+	}
+	return values, err
+}
+
+// Write 按点位的命令类型发送单命令、双命令或设定值命令并等待激活确认，point 为点位地址或点位名。
+// 单命令/双命令的值为 bool 或数值（非 0 为合/on）
+func (c *IEC104Client) Write(point string, value interface{}) error {
+	p, ok := c.lookupPoint(point)
+	if !ok {
+		return fmt.Errorf("iec104: point %s not configured", point)
+	}
+	if p.command == 0 {
+		return fmt.Errorf("iec104: point %s is not writable", point)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.connect(); err != nil {
+		return err
+	}
+	if p.selectCmd {
+		req, err := c.command(p, value, true)
+		if err != nil {
+			return err
+		}
+		if err := c.transact(req, false); err != nil {
+			return err
+		}
+	}
+	req, err := c.command(p, value, false)
+	if err != nil {
+		return err
+	}
+	return c.transact(req, false)
+}
+
+func (c *IEC104Client) lookupPoint(point string) (*point, bool) {
+	c.vmu.RLock()
+	defer c.vmu.RUnlock()
+	for _, d := range c.devices {
+		if p, ok := d.points[point]; ok {
+			return p, true
+		}
+	}
+	return nil, false
+}
+
+// command 编码命令 ASDU，sel 为选择命令（S/E=1）
+func (c *IEC104Client) command(p *point, value interface{}, sel bool) (*asdu, error) {
+	body := appendIOA(nil, p.commandIOA)
+	se := byte(0)
+	if sel {
+		se = 0x80
+	}
+	switch baseType(p.command) {
+	case typeSingleCommand, typeDoubleCommand:
+		on, ok := toBool(value)
+		if !ok {
+			return nil, fmt.Errorf("iec104: invalid command value %v", value)
+		}
+		state := byte(0)
+		if on {
+			state = 1
+		}
+		if baseType(p.command) == typeDoubleCommand {
+			state++ // DCS：1 分，2 合
+		}
+		body = append(body, state|p.qualifier<<2|se)
+	default:
+		v, ok := utils.ToFloat64(value)
+		if !ok {
+			return nil, fmt.Errorf("iec104: invalid setpoint value %v", value)
+		}
+		switch baseType(p.command) {
+		case typeSetpointNorm:
+			if v < -1 || v >= 1 {
+				return nil, fmt.Errorf("iec104: normalized setpoint %v out of range [-1, 1)", v)
+			}
+			body = binary.LittleEndian.AppendUint16(body, uint16(int16(math.Round(v*32768))))
+		case typeSetpointScaled:
+			if v < math.MinInt16 || v > math.MaxInt16 {
+				return nil, fmt.Errorf("iec104: scaled setpoint %v out of range", v)
+			}
+			body = binary.LittleEndian.AppendUint16(body, uint16(int16(math.Round(v))))
+		default:
+			body = binary.LittleEndian.AppendUint32(body, math.Float32bits(float32(v)))
+		}
+		body = append(body, p.qualifier|se)
+	}
+	if p.command != baseType(p.command) {
+		body = appendCP56(body, time.Now().In(c.loc))
+	}
+	return &asdu{typeID: p.command, count: 1, cause: causeAct, orig: byte(c.conf.Originator), ca: p.key.ca, body: body}, nil
+}
+
+func toBool(v interface{}) (bool, bool) {
+	if b, ok := v.(bool); ok {
+		return b, true
+	}
+	switch s := v.(type) {
+	case string:
+		switch strings.ToLower(s) {
+		case "true", "on":
+			return true, true
+		case "false", "off":
+			return false, true
+		}
+	}
+	f, ok := utils.ToFloat64(v)
+	return f != 0, ok
+}
+
+// Close 停止数据传输并断开连接
+func (c *IEC104Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.link != nil && c.link.alive() {
+		c.link.sendU(uStopDTAct)
+		c.link.close(errLinkClosed)
+	}
+	return nil
+}
+
+// Reconnect 断开后重新连接并总召唤
+func (c *IEC104Client) Reconnect() error {
+	c.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.connect()
+}
+
+func badValue(id string) protocols.PointValue {
+	return protocols.PointValue{PointID: id, Value: nil, Quality: "bad", Timestamp: time.Now().Unix()}
+}
+
+func NewIEC104Client() protocols.Protocol {
+	return &IEC104Client{}
+}
+
+func init() {
+	protocols.Register("iec104", NewIEC104Client)
+}
@@ -0,0 +1,299 @@
+package iec104
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sensor-edge/protocols"
+)
+
+// eventTime 子站突发上送的时标
+var eventTime = time.Date(2024, 1, 2, 3, 4, 5, 678e6, time.Local)
+
+// slave 模拟公共地址为 1 的 IEC 104 子站：
+//   - 总召唤：单点 1=分、双点 2=合、浮点 100~101（顺序元素，101 无效）、标度化值 200（非当前值）
+//   - 计数量召唤：累计量 300
+//   - 单命令 10 执行后突发上送带时标的单点 1，双命令 11 和浮点设定值 20 肯定确认，其他地址否定确认
+type slave struct {
+	t  *testing.T
+	ln net.Listener
+
+	mu     sync.Mutex
+	conn   net.Conn
+	ns, nr uint16
+	events []string // 收到的 U 帧和命令
+}
+
+func newSlave(t *testing.T) *slave {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &slave{t: t, ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conn, s.ns, s.nr = conn, 0, 0
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *slave) record(event string) {
+	s.mu.Lock()
+	s.events = append(s.events, event)
+	s.mu.Unlock()
+}
+
+func (s *slave) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		apdu := make([]byte, header[1])
+		if _, err := io.ReadFull(conn, apdu); err != nil {
+			return
+		}
+		switch {
+		case apdu[0]&0x01 == 0:
+			ns := uint16(apdu[0])>>1 | uint16(apdu[1])<<7
+			s.mu.Lock()
+			if ns != s.nr {
+				s.t.Errorf("slave: send sequence %d, expect %d", ns, s.nr)
+			}
+			s.nr++
+			s.mu.Unlock()
+			a, err := decodeASDU(apdu[4:])
+			if err != nil {
+				s.t.Error(err)
+				return
+			}
+			s.handle(a)
+		case apdu[0] == uStartDTAct:
+			s.record("STARTDT")
+			conn.Write([]byte{0x68, 4, uStartDTCon, 0, 0, 0})
+		case apdu[0] == uStopDTAct:
+			s.record("STOPDT")
+			conn.Write([]byte{0x68, 4, uStopDTCon, 0, 0, 0})
+		case apdu[0] == uTestFRAct:
+			conn.Write([]byte{0x68, 4, uTestFRCon, 0, 0, 0})
+		}
+	}
+}
+
+// send 发送 I 帧，接收序号随帧确认
+func (s *slave) send(a *asdu) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body := a.encode()
+	frame := []byte{0x68, byte(len(body) + 4), byte(s.ns << 1), byte(s.ns >> 7), byte(s.nr << 1), byte(s.nr >> 7)}
+	s.ns++
+	s.conn.Write(append(frame, body...))
+}
+
+func (s *slave) reply(req *asdu, cause byte, negative bool) {
+	resp := *req
+	resp.cause, resp.negative = cause, negative
+	s.send(&resp)
+}
+
+func (s *slave) handle(a *asdu) {
+	switch a.typeID {
+	case typeInterrogation:
+		s.reply(a, causeActCon, false)
+		s.send(&asdu{typeID: typeSinglePoint, count: 2, cause: causeInrogen, ca: 1, body: []byte{1, 0, 0, 0x00, 3, 0, 0, 0x01}})
+		s.send(&asdu{typeID: typeDoublePoint, count: 1, cause: causeInrogen, ca: 1, body: []byte{2, 0, 0, 0x02}})
+		body := appendIOA(nil, 100)
+		body = binary.LittleEndian.AppendUint32(body, math.Float32bits(21.5))
+		body = append(body, 0x00)
+		body = binary.LittleEndian.AppendUint32(body, math.Float32bits(-3.25))
+		body = append(body, 0x80)
+		s.send(&asdu{typeID: typeFloat, sq: true, count: 2, cause: causeInrogen, ca: 1, body: body})
+		s.send(&asdu{typeID: typeScaled, count: 1, cause: causeInrogen, ca: 1, body: []byte{200, 0, 0, 0xd2, 0x04, 0x40}})
+		s.reply(a, causeActTerm, false)
+	case typeCounterInterrog:
+		s.reply(a, causeActCon, false)
+		s.send(&asdu{typeID: typeCounter, count: 1, cause: causeReqcogen, ca: 1, body: []byte{0x2c, 0x01, 0, 0x88, 0x13, 0, 0, 0x21}})
+		s.reply(a, causeActTerm, false)
+	case typeSingleCommand, typeDoubleCommand, typeSetpointFloat:
+		ioa, e := a.ioa(), a.body[3:]
+		s.record(fmt.Sprintf("%s ioa=%d % X", typeNames[a.typeID], ioa, e))
+		if ioa != 10 && ioa != 11 && ioa != 20 {
+			s.reply(a, 47, true)
+			return
+		}
+		s.reply(a, causeActCon, false)
+		if ioa == 10 {
+			s.send(&asdu{typeID: typeSinglePointTime, count: 1, cause: causeSpontaneous, ca: 1,
+				body: appendCP56([]byte{1, 0, 0, e[0] & 0x01}, eventTime)})
+		}
+	default:
+		s.reply(a, 44, true)
+	}
+}
+
+func (s *slave) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conn.Close()
+}
+
+func (s *slave) lastEvents(n int) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return strings.Join(s.events[max(len(s.events)-n, 0):], "; ")
+}
+
+func TestIEC104Client(t *testing.T) {
+	s := newSlave(t)
+	addr := s.ln.Addr().(*net.TCPAddr)
+	c := &IEC104Client{}
+	if err := c.Init(map[string]interface{}{"ip": "127.0.0.1", "port": addr.Port, "t1": 2, "t2": 1, "t3": 5, "timeout": 2000}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if err := c.ConfigureDevice("rtu", map[string]interface{}{"common_address": 1}); err != nil {
+		t.Fatal(err)
+	}
+	c.SetPointConfigs("rtu", []protocols.PointConfig{
+		{PointID: "breaker", Address: "1:M_SP_NA_1", Options: map[string]interface{}{"command_ioa": 10}},
+		{PointID: "alarm", Address: "1:3:1"},
+		{PointID: "switch", Address: "1:2:M_DP_TB_1", Options: map[string]interface{}{"command": "C_DC_NA_1", "command_ioa": 11, "select": true}},
+		{PointID: "flow", Address: "1:100:13"},
+		{PointID: "level", Address: "1:101:M_ME_NC_1"},
+		{PointID: "power", Address: "1:200:11"},
+		{PointID: "energy", Address: "1:300:M_IT_NA_1"},
+		{PointID: "setpoint", Address: "1:20:C_SE_NC_1"},
+		{PointID: "unknown", Address: "1:99:C_SC_NA_1"},
+	})
+	pushed := make(chan []protocols.PointValue, 4)
+	c.SetValueHandler("rtu", func(values []protocols.PointValue) { pushed <- values })
+
+	show := func(values []protocols.PointValue) string {
+		var parts []string
+		for _, v := range values {
+			parts = append(parts, fmt.Sprintf("%s=%v %s", v.PointID, v.Value, v.Quality))
+		}
+		return strings.Join(parts, "; ")
+	}
+	// 首次读取建立连接并总召唤，召唤响应不回调
+	values, err := c.Read("rtu")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "alarm=true good; breaker=false good; energy=<nil> bad; flow=21.5 good; level=<nil> bad:invalid; " +
+		"power=1234 uncertain:not_topical; setpoint=<nil> bad; switch=true good; unknown=<nil> bad"
+	if got := show(values); got != want {
+		t.Errorf("read:\n got %s\nwant %s", got, want)
+	}
+	if got := s.lastEvents(1); got != "STARTDT" {
+		t.Errorf("slave events %s", got)
+	}
+	values, err = c.ReadBatch("rtu", "ci", []string{"energy", "1:100:M_ME_TF_1"})
+	if err != nil || show(values) != "energy=5000 uncertain:carry; 1:100:M_ME_TF_1=21.5 good" {
+		t.Errorf("counter interrogation = %s, %v", show(values), err)
+	}
+
+	// 单命令执行后子站突发上送带时标的变位
+	if err := c.Write("breaker", true); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case values := <-pushed:
+		if show(values) != "breaker=true good" || values[0].Timestamp != eventTime.Unix() {
+			t.Errorf("pushed %s at %d", show(values), values[0].Timestamp)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no spontaneous update")
+	}
+	if err := c.Write("switch", "off"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Write("setpoint", 42.5); err != nil {
+		t.Fatal(err)
+	}
+	want = "C_SC_NA_1 ioa=10 01; C_DC_NA_1 ioa=11 81; C_DC_NA_1 ioa=11 01; C_SE_NC_1 ioa=20 00 00 2A 42 00"
+	if got := s.lastEvents(4); got != want {
+		t.Errorf("commands:\n got %s\nwant %s", got, want)
+	}
+	if err := c.Write("unknown", 1); err == nil || !strings.Contains(err.Error(), "unknown information object address") {
+		t.Errorf("expect negative confirmation, got %v", err)
+	}
+	if err := c.Write("flow", "abc"); err == nil {
+		t.Error("expect invalid setpoint error")
+	}
+
+	// 连接断开后下一次读取重新连接并总召唤
+	s.drop()
+	time.Sleep(100 * time.Millisecond)
+	values, err = c.ReadBatch("rtu", "", []string{"flow", "energy"})
+	if err != nil || show(values) != "flow=21.5 good; energy=<nil> bad" {
+		t.Errorf("after reconnect = %s, %v", show(values), err)
+	}
+	c.Close()
+	if got := s.lastEvents(1); got != "STOPDT" {
+		t.Errorf("slave events %s", got)
+	}
+}
+
+// TestSlowHandler 回调阻塞时读协程照常处理命令确认
+func TestSlowHandler(t *testing.T) {
+	s := newSlave(t)
+	c := &IEC104Client{}
+	if err := c.Init(map[string]interface{}{"ip": "127.0.0.1", "port": s.ln.Addr().(*net.TCPAddr).Port, "common_address": 1, "timeout": 1000}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetPointConfigs("rtu", []protocols.PointConfig{
+		{PointID: "breaker", Address: "1:M_SP_NA_1", Options: map[string]interface{}{"command_ioa": 10}},
+		{PointID: "switch", Address: "2:M_DP_NA_1", Options: map[string]interface{}{"command_ioa": 11}},
+	})
+	called, release := make(chan struct{}, 1), make(chan struct{})
+	defer close(release)
+	c.SetValueHandler("rtu", func([]protocols.PointValue) {
+		called <- struct{}{}
+		<-release
+	})
+	if err := c.Write("breaker", true); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-called:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no spontaneous update")
+	}
+	if err := c.Write("switch", false); err != nil {
+		t.Errorf("command while handler blocked: %v", err)
+	}
+}
+
+func TestCP56Time(t *testing.T) {
+	b := appendCP56(nil, eventTime)
+	if b[4]>>5 != 2 { // 2024-01-02 为星期二
+		t.Errorf("day of week = %d", b[4]>>5)
+	}
+	got, err := decodeCP56(b, time.Local)
+	if err != nil || !got.Equal(eventTime) {
+		t.Errorf("decode = %v, %v", got, err)
+	}
+	b[2] |= 0x80
+	if _, err := decodeCP56(b, time.Local); err == nil {
+		t.Error("expect invalid time tag")
+	}
+}
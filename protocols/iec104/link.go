@@ -0,0 +1,254 @@
+package iec104
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// U 帧控制域
+const (
+	uStartDTAct byte = 0x07
+	uStartDTCon byte = 0x0b
+	uStopDTAct  byte = 0x13
+	uStopDTCon  byte = 0x23
+	uTestFRAct  byte = 0x43
+	uTestFRCon  byte = 0x83
+)
+
+const maxAPDULength = 253
+
+var errLinkClosed = errors.New("iec104: connection closed")
+
+// link 一条 IEC 104 连接：收发 APDU，维护收发序号、k/w 窗口和 t1/t2/t3 超时。
+// 收到的 ASDU 在读协程中依次交给 onASDU
+type link struct {
+	conn   net.Conn
+	timers timers
+	onASDU func(*asdu)
+
+	mu        sync.Mutex
+	cond      *sync.Cond  // 发送窗口、U 帧确认和连接关闭的等待
+	ns, nr    uint16      // 下一个发送序号、下一个期望的接收序号
+	unacked   []time.Time // 已发送未被确认的 I 帧的发送时间，按序号顺序
+	recvCount int         // 已接收未确认的 I 帧数
+	recvSince time.Time   // 最早一个未确认 I 帧的接收时间
+	lastRecv  time.Time
+	uPending  byte // 等待确认的 U 帧，0 为无
+	uSent     time.Time
+	err       error
+	closed    chan struct{}
+}
+
+// timers 超时参数和窗口大小
+type timers struct {
+	t0, t1, t2, t3 time.Duration
+	k, w           int
+}
+
+func dial(addr string, tm timers, onASDU func(*asdu)) (*link, error) {
+	conn, err := net.DialTimeout("tcp", addr, tm.t0)
+	if err != nil {
+		return nil, err
+	}
+	l := &link{conn: conn, timers: tm, onASDU: onASDU, lastRecv: time.Now(), closed: make(chan struct{})}
+	l.cond = sync.NewCond(&l.mu)
+	go l.readLoop()
+	go l.timerLoop()
+	return l, nil
+}
+
+// alive 连接未关闭
+func (l *link) alive() bool {
+	select {
+	case <-l.closed:
+		return false
+	default:
+		return true
+	}
+}
+
+// close 关闭连接并唤醒全部等待者，err 为关闭原因
+func (l *link) close(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closeLocked(err)
+}
+
+func (l *link) closeLocked(err error) {
+	if l.err != nil {
+		return
+	}
+	l.err = err
+	l.conn.Close()
+	close(l.closed)
+	l.cond.Broadcast()
+}
+
+func (l *link) writeLocked(frame []byte) error {
+	l.conn.SetWriteDeadline(time.Now().Add(l.timers.t1))
+	if _, err := l.conn.Write(frame); err != nil {
+		l.closeLocked(err)
+		return err
+	}
+	return nil
+}
+
+// sendU 发送 U 帧激活（STARTDT/STOPDT/TESTFR）并等待确认，t1 内未确认时关闭连接
+func (l *link) sendU(act byte) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for l.uPending != 0 && l.err == nil {
+		l.cond.Wait()
+	}
+	if l.err != nil {
+		return l.err
+	}
+	if err := l.writeLocked([]byte{0x68, 4, act, 0, 0, 0}); err != nil {
+		return err
+	}
+	l.uPending, l.uSent = act, time.Now()
+	for l.uPending == act && l.err == nil {
+		l.cond.Wait()
+	}
+	return l.err
+}
+
+// sendI 发送 I 帧，已发送未确认的帧达到 k 时等待确认
+func (l *link) sendI(a *asdu) error {
+	body := a.encode()
+	if len(body)+4 > maxAPDULength {
+		return fmt.Errorf("iec104: asdu too long: %d bytes", len(body))
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for len(l.unacked) >= l.timers.k && l.err == nil {
+		l.cond.Wait()
+	}
+	if l.err != nil {
+		return l.err
+	}
+	frame := []byte{0x68, byte(len(body) + 4), byte(l.ns << 1), byte(l.ns >> 7), byte(l.nr << 1), byte(l.nr >> 7)}
+	if err := l.writeLocked(append(frame, body...)); err != nil {
+		return err
+	}
+	l.ns = (l.ns + 1) % 32768
+	l.unacked = append(l.unacked, time.Now())
+	l.recvCount = 0 // I 帧携带了接收序号
+	return nil
+}
+
+func (l *link) sendSLocked() error {
+	l.recvCount = 0
+	return l.writeLocked([]byte{0x68, 4, 0x01, 0, byte(l.nr << 1), byte(l.nr >> 7)})
+}
+
+// ackLocked 对端确认了序号 nr 之前的 I 帧
+func (l *link) ackLocked(nr uint16) error {
+	n := int((l.ns + 32768 - nr) % 32768) // 仍未确认的帧数
+	if n > len(l.unacked) {
+		return fmt.Errorf("iec104: invalid receive sequence %d (send sequence %d)", nr, l.ns)
+	}
+	l.unacked = l.unacked[len(l.unacked)-n:]
+	l.cond.Broadcast()
+	return nil
+}
+
+func (l *link) readLoop() {
+	header := make([]byte, 2)
+	for {
+		if _, err := io.ReadFull(l.conn, header); err != nil {
+			l.close(err)
+			return
+		}
+		if header[0] != 0x68 || header[1] < 4 || header[1] > maxAPDULength {
+			l.close(fmt.Errorf("iec104: invalid apdu header % X", header))
+			return
+		}
+		apdu := make([]byte, header[1])
+		if _, err := io.ReadFull(l.conn, apdu); err != nil {
+			l.close(err)
+			return
+		}
+		a, err := l.receive(apdu)
+		if err != nil {
+			l.close(err)
+			return
+		}
+		if a != nil {
+			l.onASDU(a)
+		}
+	}
+}
+
+// receive 处理一个 APDU 的控制域，I 帧返回其中的 ASDU
+func (l *link) receive(apdu []byte) (*asdu, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lastRecv = time.Now()
+	ctrl := apdu[:4]
+	nr := uint16(ctrl[2])>>1 | uint16(ctrl[3])<<7
+	switch {
+	case ctrl[0]&0x01 == 0: // I 帧
+		ns := uint16(ctrl[0])>>1 | uint16(ctrl[1])<<7
+		if ns != l.nr {
+			return nil, fmt.Errorf("iec104: send sequence %d, expect %d", ns, l.nr)
+		}
+		if err := l.ackLocked(nr); err != nil {
+			return nil, err
+		}
+		l.nr = (l.nr + 1) % 32768
+		if l.recvCount == 0 {
+			l.recvSince = l.lastRecv
+		}
+		if l.recvCount++; l.recvCount >= l.timers.w {
+			if err := l.sendSLocked(); err != nil {
+				return nil, err
+			}
+		}
+		return decodeASDU(apdu[4:])
+	case ctrl[0]&0x03 == 0x01: // S 帧
+		return nil, l.ackLocked(nr)
+	}
+	switch ctrl[0] { // U 帧
+	case uTestFRAct:
+		return nil, l.writeLocked([]byte{0x68, 4, uTestFRCon, 0, 0, 0})
+	case uStartDTCon, uStopDTCon, uTestFRCon:
+		if l.uPending != 0 && ctrl[0] == (l.uPending&^0x03)<<1|0x03 {
+			l.uPending = 0
+			l.cond.Broadcast()
+		}
+	}
+	return nil, nil
+}
+
+// timerLoop t1：发出的 I 帧或 U 帧超时未确认时关闭连接；t2：收到的 I 帧超时未确认时发送 S 帧；
+// t3：长时间没有收到任何帧时发送 TESTFR
+func (l *link) timerLoop() {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-l.closed:
+			return
+		case now := <-ticker.C:
+			l.mu.Lock()
+			switch {
+			case len(l.unacked) > 0 && now.Sub(l.unacked[0]) > l.timers.t1:
+				l.closeLocked(fmt.Errorf("iec104: t1 timeout waiting for acknowledgement"))
+			case l.uPending != 0 && now.Sub(l.uSent) > l.timers.t1:
+				l.closeLocked(fmt.Errorf("iec104: t1 timeout waiting for U frame %02X confirmation", l.uPending))
+			case l.recvCount > 0 && now.Sub(l.recvSince) >= l.timers.t2:
+				l.sendSLocked()
+			case l.uPending == 0 && now.Sub(l.lastRecv) >= l.timers.t3:
+				if l.writeLocked([]byte{0x68, 4, uTestFRAct, 0, 0, 0}) == nil {
+					l.uPending, l.uSent = uTestFRAct, now
+				}
+			}
+			l.mu.Unlock()
+		}
+	}
+}
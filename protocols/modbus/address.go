@@ -0,0 +1,201 @@
+package modbus
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/goburrow/modbus"
+)
+
+// Area Modbus 数据区
+type Area byte
+
+const (
+	AreaUnknown         Area = iota // 地址未指明数据区，由功能码决定
+	AreaCoil                        // 0x 线圈，读01，写05/15
+	AreaDiscreteInput               // 1x 离散输入，读02，只读
+	AreaInputRegister               // 3x 输入寄存器，读04，只读
+	AreaHoldingRegister             // 4x 保持寄存器，读03，写06/16
+)
+
+func (a Area) String() string {
+	switch a {
+	case AreaCoil:
+		return "coil"
+	case AreaDiscreteInput:
+		return "discrete_input"
+	case AreaInputRegister:
+		return "input_register"
+	case AreaHoldingRegister:
+		return "holding_register"
+	default:
+		return "unknown"
+	}
+}
+
+// ReadFunction 返回读取该数据区使用的功能码
+func (a Area) ReadFunction() byte {
+	switch a {
+	case AreaCoil:
+		return modbus.FuncCodeReadCoils
+	case AreaDiscreteInput:
+		return modbus.FuncCodeReadDiscreteInputs
+	case AreaInputRegister:
+		return modbus.FuncCodeReadInputRegisters
+	default:
+		return modbus.FuncCodeReadHoldingRegisters
+	}
+}
+
+// IsBit 数据区是否为位类型（线圈/离散输入）
+func (a Area) IsBit() bool {
+	return a == AreaCoil || a == AreaDiscreteInput
+}
+
+// Address 解析后的 Modbus 地址
+type Address struct {
+	Area   Area
+	Offset uint16 // 协议帧中的零基偏移
+	Bit    int    // 寄存器内位号 0~15，-1 表示整寄存器
+}
+
+// HasBit 是否为寄存器内的位地址
+func (a Address) HasBit() bool {
+	return a.Bit >= 0
+}
+
+// resolve 地址未指明数据区时使用 area
+func (a Address) resolve(area Area) Address {
+	if a.Area == AreaUnknown {
+		a.Area = area
+	}
+	return a
+}
+
+// areaPrefixes 显式数据区前缀，冒号后的数字为零基偏移
+var areaPrefixes = map[string]Area{
+	"co": AreaCoil,
+	"di": AreaDiscreteInput,
+	"ir": AreaInputRegister,
+	"hr": AreaHoldingRegister,
+}
+
+// ParseAddress 解析点位地址，支持：
+//   - Modicon 5位/6位地址：00001/10001/30001/40001（1~9999），000001~465536（1~65536）
+//   - 显式数据区：hr:100、ir:5、co:3、di:7，数字为零基偏移，可用 0x 前缀写十六进制
+//   - 十六进制零基偏移：0x0010，数据区由功能码决定
+//   - 少于5位的十进制数：一基地址，数据区由功能码决定（兼容旧配置，如 "1" 表示偏移0）
+//   - 寄存器位地址：40001.3、hr:0.15、30001.0
+func ParseAddress(point string) (Address, error) {
+	addr := Address{Bit: -1}
+	s := strings.TrimSpace(point)
+	if s == "" {
+		return addr, fmt.Errorf("invalid point address")
+	}
+	if i := strings.LastIndex(s, "."); i >= 0 {
+		bit, err := strconv.Atoi(s[i+1:])
+		if err != nil || bit < 0 || bit > 15 {
+			return addr, fmt.Errorf("invalid bit index in address %s", point)
+		}
+		addr.Bit = bit
+		s = s[:i]
+	}
+	if i := strings.Index(s, ":"); i >= 0 {
+		area, ok := areaPrefixes[strings.ToLower(s[:i])]
+		if !ok {
+			return addr, fmt.Errorf("invalid area prefix in address %s", point)
+		}
+		offset, err := parseOffset(s[i+1:])
+		if err != nil {
+			return addr, fmt.Errorf("invalid offset in address %s: %v", point, err)
+		}
+		addr.Area = area
+		addr.Offset = offset
+		return addr.check(point)
+	}
+	if strings.HasPrefix(strings.ToLower(s), "0x") {
+		offset, err := parseOffset(s)
+		if err != nil {
+			return addr, fmt.Errorf("invalid offset in address %s: %v", point, err)
+		}
+		addr.Offset = offset
+		return addr.check(point)
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return addr, fmt.Errorf("invalid point address %s", point)
+		}
+	}
+	if len(s) < 5 {
+		n, _ := strconv.Atoi(s)
+		if n < 1 || n > 65536 {
+			return addr, fmt.Errorf("address %s out of range", point)
+		}
+		addr.Offset = uint16(n - 1)
+		return addr.check(point)
+	}
+	if len(s) > 6 {
+		return addr, fmt.Errorf("address %s too long", point)
+	}
+	switch s[0] {
+	case '0':
+		addr.Area = AreaCoil
+	case '1':
+		addr.Area = AreaDiscreteInput
+	case '3':
+		addr.Area = AreaInputRegister
+	case '4':
+		addr.Area = AreaHoldingRegister
+	default:
+		return addr, fmt.Errorf("invalid area prefix in address %s", point)
+	}
+	n, _ := strconv.Atoi(s[1:])
+	max := 9999
+	if len(s) == 6 {
+		max = 65536
+	}
+	if n < 1 || n > max {
+		return addr, fmt.Errorf("address %s out of range", point)
+	}
+	addr.Offset = uint16(n - 1)
+	return addr.check(point)
+}
+
+func (a Address) check(point string) (Address, error) {
+	if a.HasBit() && a.Area.IsBit() {
+		return a, fmt.Errorf("bit index not allowed for %s address %s", a.Area, point)
+	}
+	return a, nil
+}
+
+// parseOffset 0x/0X 开头按十六进制，否则按十进制（前导 0 不表示八进制）
+func parseOffset(s string) (uint16, error) {
+	base := 10
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		s, base = s[2:], 16
+	}
+	n, err := strconv.ParseUint(s, base, 16)
+	if err != nil {
+		return 0, err
+	}
+	return uint16(n), nil
+}
+
+// areaFromFunction 功能码对应的数据区，未指定功能码时默认保持寄存器
+func areaFromFunction(function string) Area {
+	fc, err := strconv.Atoi(strings.TrimSpace(function))
+	if err != nil {
+		return AreaHoldingRegister
+	}
+	switch fc {
+	case modbus.FuncCodeReadCoils, modbus.FuncCodeWriteSingleCoil, modbus.FuncCodeWriteMultipleCoils:
+		return AreaCoil
+	case modbus.FuncCodeReadDiscreteInputs:
+		return AreaDiscreteInput
+	case modbus.FuncCodeReadInputRegisters:
+		return AreaInputRegister
+	default:
+		return AreaHoldingRegister
+	}
+}
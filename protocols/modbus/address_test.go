@@ -0,0 +1,75 @@
+package modbus
+
+import (
+	"testing"
+
+	"sensor-edge/protocols"
+)
+
+func TestParseAddress(t *testing.T) {
+	cases := []struct {
+		in     string
+		area   Area
+		offset uint16
+		bit    int
+	}{
+		{"00001", AreaCoil, 0, -1},
+		{"10001", AreaDiscreteInput, 0, -1},
+		{"30001", AreaInputRegister, 0, -1},
+		{"40001", AreaHoldingRegister, 0, -1},
+		{"49999", AreaHoldingRegister, 9998, -1},
+		{"100001", AreaDiscreteInput, 0, -1},
+		{"400001", AreaHoldingRegister, 0, -1},
+		{"465536", AreaHoldingRegister, 65535, -1},
+		{"0x0010", AreaUnknown, 16, -1},
+		{"1", AreaUnknown, 0, -1},
+		{"100", AreaUnknown, 99, -1},
+		{"hr:100", AreaHoldingRegister, 100, -1},
+		{"IR:5", AreaInputRegister, 5, -1},
+		{"co:3", AreaCoil, 3, -1},
+		{"di:7", AreaDiscreteInput, 7, -1},
+		{"hr:0x20", AreaHoldingRegister, 32, -1},
+		{"hr:0x10", AreaHoldingRegister, 16, -1},
+		{"hr:010", AreaHoldingRegister, 10, -1},
+		{"ir:09", AreaInputRegister, 9, -1},
+		{"0X0010", AreaUnknown, 16, -1},
+		{"40001.3", AreaHoldingRegister, 0, 3},
+		{"hr:10.15", AreaHoldingRegister, 10, 15},
+	}
+	for _, c := range cases {
+		addr, err := ParseAddress(c.in)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.in, err)
+			continue
+		}
+		if addr.Area != c.area || addr.Offset != c.offset || addr.Bit != c.bit {
+			t.Errorf("%s: got %+v", c.in, addr)
+		}
+	}
+}
+
+func TestParseAddressInvalid(t *testing.T) {
+	for _, in := range []string{"", "50001", "40000", "4000000", "466537", "xx:1", "hr:70000", "40001.16", "00001.1", "abc", "hr:0b11", "hr:1_0", "hr:0o7", "hr:0x", "hr:-1"} {
+		if _, err := ParseAddress(in); err == nil {
+			t.Errorf("%s: expect error", in)
+		}
+	}
+}
+
+func TestReadPointsByArea(t *testing.T) {
+	fc := &fakeClient{regs: map[uint16]uint16{0: 0x0008, 1: 7}}
+	vals, err := readPoints(fc, AreaUnknown, []protocols.PointConfig{
+		{PointID: "flag", Address: "40001.3"},
+		{PointID: "raw", Address: "0x0001"},
+	}, defaultPlanOptions())
+	if err != nil {
+		t.Fatalf("readPoints failed: %v", err)
+	}
+	got := map[string]interface{}{}
+	for _, v := range vals {
+		got[v.PointID] = v.Value
+	}
+	if got["flag"] != true || got["raw"] != uint16(7) {
+		t.Errorf("unexpected values: %+v", vals)
+	}
+}
@@ -0,0 +1,259 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"sensor-edge/protocols"
+
+	"github.com/goburrow/modbus"
+)
+
+// 批量读取逻辑与传输层无关，ModbusTCP / ModbusRTU 共用
+
+const (
+	maxRegsPerRequest = 125  // 单次读寄存器最大数量（协议上限）
+	maxBitsPerRequest = 2000 // 单次读线圈/离散输入最大数量（协议上限）
+)
+
+// readBatch 按功能码和地址批量读取：地址自带数据区（40001、hr:0 等）时以地址为准，
+// 否则使用功能码对应的数据区；function 为空时仅依据地址，默认保持寄存器。
+// 点位格式从 table 中查找，用于确定多寄存器点位（Float/Long/Double）的长度
+func readBatch(client modbus.Client, function string, points []string, table pointTable, opts planOptions) ([]protocols.PointValue, error) {
+	return readPoints(client, areaFromFunction(function), batchPointConfigs(points, table), opts)
+}
+
+// batchPointConfigs 以地址作为 PointID 构造点位配置，返回值仍按地址回填
+func batchPointConfigs(points []string, table pointTable) []protocols.PointConfig {
+	pointConfigs := make([]protocols.PointConfig, len(points))
+	for i, pt := range points {
+		_, format := table.lookup(pt)
+		pointConfigs[i] = protocols.PointConfig{
+			PointID: pt,
+			Address: pt,
+			Format:  format,
+		}
+	}
+	return pointConfigs
+}
+
+// modbusPoint 已解析地址的点位
+type modbusPoint struct {
+	name   string
+	addr   Address
+	format string
+}
+
+// readPoints 按数据区分组、规划读请求后逐块读取，禁止读取的点位返回 bad；
+// 块读取遇到地址类异常时拆分重试，只有非法地址上的点位返回 bad
+func readPoints(client modbus.Client, defaultArea Area, points []protocols.PointConfig, opts planOptions) ([]protocols.PointValue, error) {
+	if len(points) == 0 {
+		return nil, nil
+	}
+	groups, err := groupPoints(defaultArea, points)
+	if err != nil {
+		return nil, err
+	}
+	var results []protocols.PointValue
+	for _, area := range readAreas {
+		blocks, skipped := opts.plan(area, groups[area])
+		for _, b := range blocks {
+			values, err := readBlockIsolated(client, b, opts)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, values...)
+		}
+		for _, p := range skipped {
+			results = append(results, badValue(p.name))
+		}
+	}
+	return results, nil
+}
+
+// registerCount 根据格式返回点位占用的寄存器数量
+func registerCount(format string) int {
+	f := strings.ToUpper(format)
+	if strings.HasPrefix(f, "FLOAT") || strings.HasPrefix(f, "LONG") {
+		return 2
+	}
+	if strings.HasPrefix(f, "DOUBLE") {
+		return 4
+	}
+	return 1
+}
+
+// pointRegisterCount 位地址点位固定占1个寄存器
+func pointRegisterCount(p modbusPoint) int {
+	if p.addr.HasBit() {
+		return 1
+	}
+	return registerCount(p.format)
+}
+
+func badValue(name string) protocols.PointValue {
+	return protocols.PointValue{
+		PointID:   name,
+		Value:     nil,
+		Quality:   "bad",
+		Timestamp: time.Now().Unix(),
+	}
+}
+
+// readBlockIsolated 读取一个块，块因异常码02/03失败时按点位二分后分别重试：
+// 单个点位仍失败则该点位返回 bad 并记录其地址范围；拆分后两半都成功时，
+// 说明是中间的空洞地址非法，记录空洞范围，下一轮规划不再跨过它合并
+func readBlockIsolated(client modbus.Client, b readBlock, opts planOptions) ([]protocols.PointValue, error) {
+	values, err := readBlockValues(client, b)
+	if err == nil || !isAddressException(err) {
+		return values, err
+	}
+	if len(b.points) == 1 {
+		opts.bad.add(b.area, b.start, b.end())
+		return []protocols.PointValue{badValue(b.points[0].name)}, nil
+	}
+	left, right := splitBlock(b)
+	leftValues, err := readBlockIsolated(client, left, opts)
+	if err != nil {
+		return nil, err
+	}
+	rightValues, err := readBlockIsolated(client, right, opts)
+	if err != nil {
+		return nil, err
+	}
+	if allGood(leftValues) && allGood(rightValues) && right.start > left.end()+1 {
+		opts.bad.add(b.area, left.end()+1, right.start-1)
+	}
+	return append(leftValues, rightValues...), nil
+}
+
+// splitBlock 将块内点位（已按地址排序）对半拆成两个块
+func splitBlock(b readBlock) (readBlock, readBlock) {
+	mid := len(b.points) / 2
+	return blockOf(b.area, b.points[:mid]), blockOf(b.area, b.points[mid:])
+}
+
+// blockOf 覆盖给定点位的最小块
+func blockOf(area Area, points []modbusPoint) readBlock {
+	b := readBlock{area: area, points: points}
+	first := true
+	end := 0
+	for _, p := range points {
+		s, e := pointSpan(p)
+		if first || s < b.start {
+			b.start = s
+		}
+		if first || e > end {
+			end = e
+		}
+		first = false
+	}
+	b.count = end - b.start + 1
+	return b
+}
+
+func allGood(values []protocols.PointValue) bool {
+	for _, v := range values {
+		if v.Quality != "good" {
+			return false
+		}
+	}
+	return true
+}
+
+// readBlockValues 执行一次块读取并拆分为各点位的值
+func readBlockValues(client modbus.Client, b readBlock) ([]protocols.PointValue, error) {
+	start, quantity := uint16(b.start), uint16(b.count)
+	switch b.area {
+	case AreaCoil:
+		bits, err := client.ReadCoils(start, quantity)
+		if err != nil {
+			return nil, fmt.Errorf("modbus coils batch read failed: %w", err)
+		}
+		return decodeBits(b, bits), nil
+	case AreaDiscreteInput:
+		bits, err := client.ReadDiscreteInputs(start, quantity)
+		if err != nil {
+			return nil, fmt.Errorf("modbus discrete inputs batch read failed: %w", err)
+		}
+		return decodeBits(b, bits), nil
+	case AreaInputRegister:
+		regVals, err := client.ReadInputRegisters(start, quantity)
+		if err != nil {
+			return nil, fmt.Errorf("modbus input batch read failed: %w", err)
+		}
+		return decodeRegisters(b, regVals), nil
+	default:
+		regVals, err := client.ReadHoldingRegisters(start, quantity)
+		if err != nil {
+			return nil, fmt.Errorf("modbus batch read failed: %w", err)
+		}
+		return decodeRegisters(b, regVals), nil
+	}
+}
+
+// decodeRegisters 按点位偏移从块数据中取值：位地址返回 bool，单寄存器返回 uint16，
+// 多寄存器返回 []uint16；响应长度不足的点位返回 bad
+func decodeRegisters(b readBlock, regVals []byte) []protocols.PointValue {
+	results := make([]protocols.PointValue, 0, len(b.points))
+	for _, p := range b.points {
+		offset := int(p.addr.Offset) - b.start
+		regCount := pointRegisterCount(p)
+		if offset+regCount > len(regVals)/2 {
+			results = append(results, badValue(p.name))
+			continue
+		}
+		vals := make([]uint16, regCount)
+		for r := 0; r < regCount; r++ {
+			vals[r] = binary.BigEndian.Uint16(regVals[(offset+r)*2 : (offset+r)*2+2])
+		}
+		var val interface{} = vals
+		if p.addr.HasBit() {
+			val = (vals[0]>>uint(p.addr.Bit))&0x01 == 0x01
+		} else if regCount == 1 {
+			val = vals[0]
+		}
+		results = append(results, protocols.PointValue{
+			PointID:   p.name,
+			Value:     val,
+			Quality:   "good",
+			Timestamp: time.Now().Unix(),
+		})
+	}
+	return results
+}
+
+// decodeBits 按 Modbus 位顺序（低位在前）取出各点位的值
+func decodeBits(b readBlock, bits []byte) []protocols.PointValue {
+	results := make([]protocols.PointValue, 0, len(b.points))
+	for _, p := range b.points {
+		offset := int(p.addr.Offset) - b.start
+		if offset/8 >= len(bits) {
+			results = append(results, badValue(p.name))
+			continue
+		}
+		results = append(results, protocols.PointValue{
+			PointID:   p.name,
+			Value:     (bits[offset/8]>>(uint(offset)%8))&0x01 == 0x01,
+			Quality:   "good",
+			Timestamp: time.Now().Unix(),
+		})
+	}
+	return results
+}
+
+// toInt 兼容 YAML/JSON 解析出的各种数值类型
+func toInt(v interface{}) (int, bool) {
+	switch vv := v.(type) {
+	case int:
+		return vv, true
+	case int64:
+		return int(vv), true
+	case float64:
+		return int(vv), true
+	default:
+		return 0, false
+	}
+}
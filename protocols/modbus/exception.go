@@ -0,0 +1,81 @@
+package modbus
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/goburrow/modbus"
+)
+
+// badRangeTTL 读取异常的地址范围在此时间内跳过，过期后重新尝试（设备可能已更换程序或配置）
+const badRangeTTL = 10 * time.Minute
+
+// exceptionCode 从错误中取出 Modbus 异常码，非异常响应（超时、断线等）返回 0
+func exceptionCode(err error) byte {
+	var me *modbus.ModbusError
+	if errors.As(err, &me) {
+		return me.ExceptionCode
+	}
+	return 0
+}
+
+// isAddressException 是否为地址类异常：02 非法数据地址、03 非法数据值（数量越界）。
+// 这类异常只与请求的地址范围有关，拆小请求后其余地址仍可读
+func isAddressException(err error) bool {
+	code := exceptionCode(err)
+	return code == modbus.ExceptionCodeIllegalDataAddress || code == modbus.ExceptionCodeIllegalDataValue
+}
+
+// badRanges 读取中发现的非法地址范围，块读取规划时与禁止地址一样跳过
+type badRanges struct {
+	mu     sync.Mutex
+	ranges []badRange
+}
+
+type badRange struct {
+	addressRange
+	expires time.Time
+}
+
+func newBadRanges() *badRanges {
+	return &badRanges{}
+}
+
+// add 记录非法地址范围
+func (s *badRanges) add(area Area, start, end int) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ranges = append(s.ranges, badRange{
+		addressRange: addressRange{area: area, start: start, end: end},
+		expires:      time.Now().Add(badRangeTTL),
+	})
+	log.Printf("[MODBUS] %s 地址 %d~%d 读取异常，%v 内跳过", area, start, end, badRangeTTL)
+}
+
+// overlaps 是否与未过期的非法地址范围重叠，顺带清理过期记录
+func (s *badRanges) overlaps(area Area, start, end int) bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	live := s.ranges[:0]
+	hit := false
+	for _, r := range s.ranges {
+		if now.After(r.expires) {
+			continue
+		}
+		live = append(live, r)
+		if r.overlaps(area, start, end) {
+			hit = true
+		}
+	}
+	s.ranges = live
+	return hit
+}
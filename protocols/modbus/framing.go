@@ -0,0 +1,221 @@
+package modbus
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/goburrow/modbus"
+)
+
+// 帧格式：标准 MBAP，或串口服务器“透明传输”模式下直接转发的 RTU/ASCII 帧
+const (
+	FramingMBAP         = "mbap"
+	FramingRTUOverTCP   = "rtu_over_tcp"
+	FramingASCIIOverTCP = "ascii_over_tcp"
+)
+
+// frameHandler 是 ModbusTCP 使用的传输层，屏蔽不同帧格式的差异
+type frameHandler interface {
+	modbus.ClientHandler
+	Connect() error
+	Close() error
+	setSlave(slaveId byte)
+	address() string
+}
+
+// newFrameHandler 按帧格式创建 handler
+func newFrameHandler(framing, addr string, timeout time.Duration, slaveId byte) (frameHandler, error) {
+	switch framing {
+	case "", FramingMBAP:
+		h := modbus.NewTCPClientHandler(addr)
+		h.Timeout = timeout
+		h.SlaveId = slaveId
+		return &mbapHandler{h}, nil
+	case FramingRTUOverTCP:
+		p := modbus.NewRTUClientHandler("")
+		p.SlaveId = slaveId
+		return &rtuOverTCPHandler{RTUClientHandler: p, conn: tcpStream{addr: addr, timeout: timeout}}, nil
+	case FramingASCIIOverTCP:
+		p := modbus.NewASCIIClientHandler("")
+		p.SlaveId = slaveId
+		return &asciiOverTCPHandler{ASCIIClientHandler: p, conn: tcpStream{addr: addr, timeout: timeout}}, nil
+	default:
+		return nil, fmt.Errorf("invalid framing: %s", framing)
+	}
+}
+
+// mbapHandler 标准 Modbus TCP（MBAP 报文头）
+type mbapHandler struct {
+	*modbus.TCPClientHandler
+}
+
+func (h *mbapHandler) setSlave(slaveId byte) { h.SlaveId = slaveId }
+func (h *mbapHandler) address() string       { return h.Address }
+
+// rtuOverTCPHandler 复用 RTU 帧编解码（含 CRC），通过 TCP 收发
+type rtuOverTCPHandler struct {
+	*modbus.RTUClientHandler // 仅使用其 Encode/Decode/Verify
+	conn                     tcpStream
+}
+
+func (h *rtuOverTCPHandler) Send(aduRequest []byte) ([]byte, error) {
+	return h.conn.send(aduRequest, readRTUFrame)
+}
+func (h *rtuOverTCPHandler) Connect() error        { return h.conn.connect() }
+func (h *rtuOverTCPHandler) Close() error          { return h.conn.close() }
+func (h *rtuOverTCPHandler) setSlave(slaveId byte) { h.SlaveId = slaveId }
+func (h *rtuOverTCPHandler) address() string       { return h.conn.addr }
+
+// asciiOverTCPHandler 复用 ASCII 帧编解码（含 LRC），通过 TCP 收发
+type asciiOverTCPHandler struct {
+	*modbus.ASCIIClientHandler // 仅使用其 Encode/Decode/Verify
+	conn                       tcpStream
+}
+
+func (h *asciiOverTCPHandler) Send(aduRequest []byte) ([]byte, error) {
+	return h.conn.send(aduRequest, readASCIIFrame)
+}
+func (h *asciiOverTCPHandler) Connect() error        { return h.conn.connect() }
+func (h *asciiOverTCPHandler) Close() error          { return h.conn.close() }
+func (h *asciiOverTCPHandler) setSlave(slaveId byte) { h.SlaveId = slaveId }
+func (h *asciiOverTCPHandler) address() string       { return h.conn.addr }
+
+// tcpStream 是无报文头的 TCP 字节流，帧边界由 readFrame 根据帧内容判断
+type tcpStream struct {
+	addr    string
+	timeout time.Duration
+
+	mu     sync.Mutex
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func (s *tcpStream) connect() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dial()
+}
+
+// dial 建立连接，调用方需持有锁
+func (s *tcpStream) dial() error {
+	if s.conn != nil {
+		return nil
+	}
+	conn, err := net.DialTimeout("tcp", s.addr, s.timeout)
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	s.reader = bufio.NewReader(conn)
+	return nil
+}
+
+func (s *tcpStream) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.drop()
+}
+
+// drop 关闭连接，调用方需持有锁
+func (s *tcpStream) drop() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	s.reader = nil
+	return err
+}
+
+// send 发送请求并读取一帧响应；出错时断开连接，避免残留字节导致后续帧错位
+func (s *tcpStream) send(aduRequest []byte, readFrame func(r *bufio.Reader, req []byte) ([]byte, error)) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.dial(); err != nil {
+		return nil, err
+	}
+	if s.timeout > 0 {
+		if err := s.conn.SetDeadline(time.Now().Add(s.timeout)); err != nil {
+			s.drop()
+			return nil, err
+		}
+	}
+	if _, err := s.conn.Write(aduRequest); err != nil {
+		s.drop()
+		return nil, err
+	}
+	aduResponse, err := readFrame(s.reader, aduRequest)
+	if err != nil {
+		s.drop()
+		return nil, err
+	}
+	return aduResponse, nil
+}
+
+// readRTUFrame 根据功能码推算 RTU 响应长度（无静默间隔可用，只能按内容判断）
+func readRTUFrame(r *bufio.Reader, req []byte) ([]byte, error) {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+	fc := head[1]
+	var rest int
+	switch {
+	case fc&0x80 != 0:
+		// 异常响应：异常码 + CRC
+		rest = 1 + 2
+	case fc == modbus.FuncCodeReadCoils, fc == modbus.FuncCodeReadDiscreteInputs,
+		fc == modbus.FuncCodeReadHoldingRegisters, fc == modbus.FuncCodeReadInputRegisters,
+		fc == modbus.FuncCodeReadWriteMultipleRegisters:
+		count, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		head = append(head, count)
+		rest = int(count) + 2
+	case fc == modbus.FuncCodeWriteSingleCoil, fc == modbus.FuncCodeWriteSingleRegister,
+		fc == modbus.FuncCodeWriteMultipleCoils, fc == modbus.FuncCodeWriteMultipleRegisters:
+		rest = 4 + 2
+	case fc == modbus.FuncCodeMaskWriteRegister:
+		rest = 6 + 2
+	case fc == modbus.FuncCodeReadFIFOQueue:
+		countBuf := make([]byte, 2)
+		if _, err := io.ReadFull(r, countBuf); err != nil {
+			return nil, err
+		}
+		head = append(head, countBuf...)
+		rest = int(binary.BigEndian.Uint16(countBuf)) + 2
+	default:
+		return nil, fmt.Errorf("modbus: unsupported function code '%v' in rtu response", fc)
+	}
+	frame := make([]byte, len(head)+rest)
+	copy(frame, head)
+	if _, err := io.ReadFull(r, frame[len(head):]); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// readASCIIFrame 读取以 ':' 开头、CRLF 结尾的一帧
+func readASCIIFrame(r *bufio.Reader, req []byte) ([]byte, error) {
+	// 丢弃帧起始符之前的噪声字节
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b == ':' {
+			break
+		}
+	}
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{':'}, line...), nil
+}
@@ -0,0 +1,118 @@
+package modbus
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func crc16(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+func lrc(data []byte) byte {
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+	return -sum
+}
+
+// holdingResponse 生成 03 功能码响应 PDU（含从站号）：寄存器值 = 地址 + 100
+func holdingResponse(slave byte, addr, qty uint16) []byte {
+	resp := []byte{slave, 0x03, byte(qty * 2)}
+	for i := uint16(0); i < qty; i++ {
+		resp = binary.BigEndian.AppendUint16(resp, addr+i+100)
+	}
+	return resp
+}
+
+// startGateway 模拟透明传输模式的串口服务器
+func startGateway(t *testing.T, framing string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for {
+			switch framing {
+			case FramingRTUOverTCP:
+				req := make([]byte, 8)
+				if _, err := io.ReadFull(r, req); err != nil {
+					return
+				}
+				resp := holdingResponse(req[0], binary.BigEndian.Uint16(req[2:]), binary.BigEndian.Uint16(req[4:]))
+				conn.Write(binary.LittleEndian.AppendUint16(resp, crc16(resp)))
+			case FramingASCIIOverTCP:
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				req, _ := hex.DecodeString(strings.TrimSpace(line)[1:])
+				resp := holdingResponse(req[0], binary.BigEndian.Uint16(req[2:]), binary.BigEndian.Uint16(req[4:]))
+				resp = append(resp, lrc(resp))
+				conn.Write([]byte(":" + strings.ToUpper(hex.EncodeToString(resp)) + "\r\n"))
+			}
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestModbusTCPFraming(t *testing.T) {
+	for _, framing := range []string{FramingRTUOverTCP, FramingASCIIOverTCP} {
+		t.Run(framing, func(t *testing.T) {
+			host, portStr, _ := net.SplitHostPort(startGateway(t, framing))
+			port, _ := strconv.Atoi(portStr)
+			m := &ModbusTCP{}
+			err := m.Init(map[string]interface{}{
+				"ip":       host,
+				"port":     port,
+				"slave_id": 3,
+				"framing":  framing,
+			})
+			if err != nil {
+				t.Fatalf("Init failed: %v", err)
+			}
+			defer m.Close()
+			m.SetSlave(5)
+			vals, err := m.ReadBatch("dev", "03", []string{"40001", "40002"})
+			if err != nil {
+				t.Fatalf("ReadBatch failed: %v", err)
+			}
+			if len(vals) != 2 || vals[0].Value != uint16(100) || vals[1].Value != uint16(101) {
+				t.Errorf("unexpected values: %+v", vals)
+			}
+		})
+	}
+}
+
+func TestModbusTCPInvalidFraming(t *testing.T) {
+	m := &ModbusTCP{}
+	err := m.Init(map[string]interface{}{"ip": "127.0.0.1", "port": 502, "slave_id": 1, "framing": "udp"})
+	if err == nil {
+		t.Error("invalid framing not detected")
+	}
+}
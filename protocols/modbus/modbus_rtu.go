@@ -0,0 +1,305 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"sensor-edge/protocols"
+
+	"github.com/goburrow/modbus"
+)
+
+// serialLine 表示一条共享的 RS-485 总线，同一串口上的多个从站复用同一个 handler，
+// 通过 lock 保证同一时刻总线上只有一个事务
+type serialLine struct {
+	lock       sync.Mutex
+	handler    *modbus.RTUClientHandler
+	client     modbus.Client
+	frameDelay time.Duration // 帧间延时，部分仪表响应后需要额外静默时间
+	refs       int
+}
+
+var (
+	serialLines   = make(map[string]*serialLine)
+	serialLinesMu sync.Mutex
+)
+
+// ModbusRTU 是 Modbus RTU 串口驱动，每个实例对应总线上的一个从站
+type ModbusRTU struct {
+	line    *serialLine
+	port    string // 串口名（如 /dev/ttyUSB0、COM1）
+	slaveId byte
+	points  pointTable
+	plan    planOptions
+}
+
+// rtuConfig 串口参数
+type rtuConfig struct {
+	port       string
+	baudRate   int
+	dataBits   int
+	parity     string
+	stopBits   int
+	timeout    time.Duration
+	frameDelay time.Duration
+}
+
+func parseRTUConfig(config map[string]interface{}) (rtuConfig, error) {
+	c := rtuConfig{
+		baudRate: 9600,
+		dataBits: 8,
+		parity:   "N",
+		stopBits: 1,
+		timeout:  2 * time.Second,
+	}
+	port, ok := config["port"].(string)
+	if !ok || port == "" {
+		return c, fmt.Errorf("invalid serial port: %v", config["port"])
+	}
+	c.port = port
+	if v, ok := toInt(config["baudrate"]); ok {
+		c.baudRate = v
+	}
+	if v, ok := toInt(config["data_bits"]); ok {
+		c.dataBits = v
+	}
+	if v, ok := config["parity"].(string); ok {
+		switch strings.ToUpper(v) {
+		case "N", "NONE":
+			c.parity = "N"
+		case "E", "EVEN":
+			c.parity = "E"
+		case "O", "ODD":
+			c.parity = "O"
+		default:
+			return c, fmt.Errorf("invalid parity: %s", v)
+		}
+	}
+	if v, ok := toInt(config["stop_bits"]); ok {
+		if v != 1 && v != 2 {
+			return c, fmt.Errorf("invalid stop_bits: %d", v)
+		}
+		c.stopBits = v
+	}
+	if v, ok := toInt(config["timeout"]); ok && v > 0 {
+		c.timeout = time.Duration(v) * time.Millisecond
+	}
+	if v, ok := toInt(config["frame_delay"]); ok && v > 0 {
+		c.frameDelay = time.Duration(v) * time.Millisecond
+	}
+	return c, nil
+}
+
+// acquireSerialLine 获取（或创建）串口对应的共享总线，串口参数以首次打开时为准
+func acquireSerialLine(c rtuConfig) (*serialLine, error) {
+	serialLinesMu.Lock()
+	defer serialLinesMu.Unlock()
+	if line, ok := serialLines[c.port]; ok {
+		line.refs++
+		return line, nil
+	}
+	handler := modbus.NewRTUClientHandler(c.port)
+	handler.BaudRate = c.baudRate
+	handler.DataBits = c.dataBits
+	handler.Parity = c.parity
+	handler.StopBits = c.stopBits
+	handler.Timeout = c.timeout
+	if err := handler.Connect(); err != nil {
+		return nil, err
+	}
+	line := &serialLine{
+		handler:    handler,
+		client:     modbus.NewClient(handler),
+		frameDelay: c.frameDelay,
+		refs:       1,
+	}
+	serialLines[c.port] = line
+	log.Printf("[MODBUS-RTU] 打开串口 %s %d %d%s%d", c.port, c.baudRate, c.dataBits, c.parity, c.stopBits)
+	return line, nil
+}
+
+// releaseSerialLine 释放引用，最后一个从站关闭时关闭串口
+func releaseSerialLine(port string) error {
+	serialLinesMu.Lock()
+	defer serialLinesMu.Unlock()
+	line, ok := serialLines[port]
+	if !ok {
+		return nil
+	}
+	line.refs--
+	if line.refs > 0 {
+		return nil
+	}
+	delete(serialLines, port)
+	return line.handler.Close()
+}
+
+func (m *ModbusRTU) Init(config map[string]interface{}) error {
+	c, err := parseRTUConfig(config)
+	if err != nil {
+		return err
+	}
+	slaveId := 1
+	if v, ok := toInt(config["slave_id"]); ok {
+		slaveId = v
+	}
+	if slaveId < 1 || slaveId > 247 {
+		return fmt.Errorf("invalid slave_id: %d", slaveId)
+	}
+	plan, err := parsePlanOptions(config)
+	if err != nil {
+		return err
+	}
+	line, err := acquireSerialLine(c)
+	if err != nil {
+		return err
+	}
+	m.line = line
+	m.port = c.port
+	m.slaveId = byte(slaveId)
+	m.plan = plan
+	return nil
+}
+
+func (m *ModbusRTU) Read(deviceID string) ([]protocols.PointValue, error) {
+	regs, err := m.client().ReadHoldingRegisters(0, 10)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]protocols.PointValue, len(regs)/2)
+	for i := 0; i < len(regs)/2; i++ {
+		values[i] = protocols.PointValue{
+			PointID:   fmt.Sprintf("reg%d", i),
+			Value:     binary.BigEndian.Uint16(regs[i*2 : i*2+2]),
+			Quality:   "good",
+			Timestamp: time.Now().Unix(),
+		}
+	}
+	return values, nil
+}
+
+// ReadBatch 与 ModbusTCP 相同的功能码分流与块读取规划逻辑
+func (m *ModbusRTU) ReadBatch(deviceID string, function string, points []string) ([]protocols.PointValue, error) {
+	return readBatch(m.client(), function, points, m.points, m.plan)
+}
+
+// ReadPlan 返回 ReadBatch 对这些点位将发出的读请求
+func (m *ModbusRTU) ReadPlan(deviceID string, function string, points []string) (protocols.ReadPlan, error) {
+	return buildReadPlan(areaFromFunction(function), batchPointConfigs(points, m.points), m.plan)
+}
+
+// Write 按点位配置的 Format 编码写入，point 可为地址或点位名
+func (m *ModbusRTU) Write(point string, value interface{}) error {
+	addr, format := m.points.lookup(point)
+	return writePoint(m.client(), addr, format, value)
+}
+
+// SetPointConfigs 记录本从站的点位配置
+func (m *ModbusRTU) SetPointConfigs(deviceID string, points []protocols.PointConfig) {
+	m.points = newPointTable(points)
+}
+
+func (m *ModbusRTU) Close() error {
+	if m.line == nil {
+		return nil
+	}
+	m.line = nil
+	return releaseSerialLine(m.port)
+}
+
+// Reconnect 重新打开串口，总线上的其他从站共用新连接
+func (m *ModbusRTU) Reconnect() error {
+	if m.line == nil {
+		return fmt.Errorf("modbus rtu: not initialized")
+	}
+	m.line.lock.Lock()
+	defer m.line.lock.Unlock()
+	_ = m.line.handler.Close()
+	if err := m.line.handler.Connect(); err != nil {
+		log.Printf("[MODBUS-RTU] 重新打开串口失败: %s, %v", m.port, err)
+		return err
+	}
+	log.Printf("[MODBUS-RTU] 重新打开串口成功: %s", m.port)
+	return nil
+}
+
+// client 返回绑定本从站的客户端，每次调用独占总线
+func (m *ModbusRTU) client() modbus.Client {
+	return &slaveClient{line: m.line, slaveId: m.slaveId}
+}
+
+func NewModbusRTU() protocols.Protocol {
+	return &ModbusRTU{}
+}
+
+func init() {
+	protocols.Register("modbus_rtu", NewModbusRTU)
+}
+
+// slaveClient 在共享总线上以指定从站号执行单次事务
+type slaveClient struct {
+	line    *serialLine
+	slaveId byte
+}
+
+// do 加锁、切换从站号并在事务结束后保持帧间延时
+func (c *slaveClient) do(fn func(modbus.Client) ([]byte, error)) ([]byte, error) {
+	c.line.lock.Lock()
+	defer c.line.lock.Unlock()
+	c.line.handler.SlaveId = c.slaveId
+	results, err := fn(c.line.client)
+	if c.line.frameDelay > 0 {
+		time.Sleep(c.line.frameDelay)
+	}
+	return results, err
+}
+
+func (c *slaveClient) ReadCoils(address, quantity uint16) ([]byte, error) {
+	return c.do(func(cl modbus.Client) ([]byte, error) { return cl.ReadCoils(address, quantity) })
+}
+
+func (c *slaveClient) ReadDiscreteInputs(address, quantity uint16) ([]byte, error) {
+	return c.do(func(cl modbus.Client) ([]byte, error) { return cl.ReadDiscreteInputs(address, quantity) })
+}
+
+func (c *slaveClient) WriteSingleCoil(address, value uint16) ([]byte, error) {
+	return c.do(func(cl modbus.Client) ([]byte, error) { return cl.WriteSingleCoil(address, value) })
+}
+
+func (c *slaveClient) WriteMultipleCoils(address, quantity uint16, value []byte) ([]byte, error) {
+	return c.do(func(cl modbus.Client) ([]byte, error) { return cl.WriteMultipleCoils(address, quantity, value) })
+}
+
+func (c *slaveClient) ReadInputRegisters(address, quantity uint16) ([]byte, error) {
+	return c.do(func(cl modbus.Client) ([]byte, error) { return cl.ReadInputRegisters(address, quantity) })
+}
+
+func (c *slaveClient) ReadHoldingRegisters(address, quantity uint16) ([]byte, error) {
+	return c.do(func(cl modbus.Client) ([]byte, error) { return cl.ReadHoldingRegisters(address, quantity) })
+}
+
+func (c *slaveClient) WriteSingleRegister(address, value uint16) ([]byte, error) {
+	return c.do(func(cl modbus.Client) ([]byte, error) { return cl.WriteSingleRegister(address, value) })
+}
+
+func (c *slaveClient) WriteMultipleRegisters(address, quantity uint16, value []byte) ([]byte, error) {
+	return c.do(func(cl modbus.Client) ([]byte, error) { return cl.WriteMultipleRegisters(address, quantity, value) })
+}
+
+func (c *slaveClient) ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress, writeQuantity uint16, value []byte) ([]byte, error) {
+	return c.do(func(cl modbus.Client) ([]byte, error) {
+		return cl.ReadWriteMultipleRegisters(readAddress, readQuantity, writeAddress, writeQuantity, value)
+	})
+}
+
+func (c *slaveClient) MaskWriteRegister(address, andMask, orMask uint16) ([]byte, error) {
+	return c.do(func(cl modbus.Client) ([]byte, error) { return cl.MaskWriteRegister(address, andMask, orMask) })
+}
+
+func (c *slaveClient) ReadFIFOQueue(address uint16) ([]byte, error) {
+	return c.do(func(cl modbus.Client) ([]byte, error) { return cl.ReadFIFOQueue(address) })
+}
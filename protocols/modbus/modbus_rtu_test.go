@@ -0,0 +1,101 @@
+//go:build linux
+
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+	"testing"
+	"unsafe"
+)
+
+// openPty 打开一对伪终端，返回主端文件和从端设备路径
+func openPty(t *testing.T) (*os.File, string) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("pty not available: %v", err)
+	}
+	var unlock int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, master.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); errno != 0 {
+		master.Close()
+		t.Skipf("unlockpt failed: %v", errno)
+	}
+	var n uint32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, master.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); errno != 0 {
+		master.Close()
+		t.Skipf("ptsname failed: %v", errno)
+	}
+	return master, fmt.Sprintf("/dev/pts/%d", n)
+}
+
+// serveRTU 模拟总线上的多个从站，仅应答 03 功能码：寄存器值 = 从站号*1000 + 地址
+func serveRTU(master *os.File) {
+	req := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(master, req); err != nil {
+			return
+		}
+		slave, fc := req[0], req[1]
+		addr := binary.BigEndian.Uint16(req[2:4])
+		qty := binary.BigEndian.Uint16(req[4:6])
+		if fc != 0x03 {
+			continue
+		}
+		resp := []byte{slave, fc, byte(qty * 2)}
+		for i := uint16(0); i < qty; i++ {
+			resp = binary.BigEndian.AppendUint16(resp, uint16(slave)*1000+addr+i)
+		}
+		resp = binary.LittleEndian.AppendUint16(resp, crc16(resp))
+		master.Write(resp)
+	}
+}
+
+func TestModbusRTUMultiSlave(t *testing.T) {
+	master, slavePath := openPty(t)
+	defer master.Close()
+	go serveRTU(master)
+
+	newDevice := func(slaveId int) *ModbusRTU {
+		m := &ModbusRTU{}
+		err := m.Init(map[string]interface{}{
+			"port":      slavePath,
+			"baudrate":  19200,
+			"parity":    "E",
+			"stop_bits": 1,
+			"timeout":   1000,
+			"slave_id":  slaveId,
+		})
+		if err != nil {
+			t.Fatalf("Init slave %d failed: %v", slaveId, err)
+		}
+		return m
+	}
+	dev1 := newDevice(1)
+	dev2 := newDevice(2)
+	defer dev1.Close()
+	defer dev2.Close()
+	if dev1.line != dev2.line {
+		t.Fatal("slaves on the same port should share one serial line")
+	}
+
+	for _, tc := range []struct {
+		dev  *ModbusRTU
+		want uint16
+	}{{dev1, 1000}, {dev2, 2000}} {
+		vals, err := tc.dev.ReadBatch("dev", "03", []string{"40001", "40002", "40003"})
+		if err != nil {
+			t.Fatalf("ReadBatch failed: %v", err)
+		}
+		if len(vals) != 3 {
+			t.Fatalf("expect 3 values, got %d", len(vals))
+		}
+		for i, v := range vals {
+			if v.Value != tc.want+uint16(i) || v.Quality != "good" {
+				t.Errorf("slave %d point %s: got %v(%s), want %d", tc.dev.slaveId, v.PointID, v.Value, v.Quality, tc.want+uint16(i))
+			}
+		}
+	}
+}
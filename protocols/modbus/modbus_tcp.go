@@ -6,7 +6,6 @@ import (
 	"io"
 	"log"
 	"net"
-	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -18,12 +17,22 @@ import (
 
 type ModbusTCP struct {
 	client    modbus.Client
-	handler   *modbus.TCPClientHandler
+	handler   frameHandler
 	lock      sync.Mutex // 保证重连线程安全
 	failCount int        // 连续失败计数
 	ip        string     // 记录设备IP
 	port      int        // 记录端口
 	slaveId   byte       // 记录slaveId
+	framing   string     // 帧格式：mbap（默认）/rtu_over_tcp/ascii_over_tcp
+	slavesMu  sync.RWMutex
+	slaves    map[byte]*slaveConfig // 从站号 -> 从站配置，多个从站可共享同一连接
+	devices   map[string]byte       // 设备ID -> 从站号
+}
+
+// slaveConfig 从站的点位配置和块读取规划参数
+type slaveConfig struct {
+	points pointTable
+	plan   planOptions
 }
 
 func (m *ModbusTCP) Init(config map[string]interface{}) error {
@@ -53,10 +62,19 @@ func (m *ModbusTCP) Init(config map[string]interface{}) error {
 	default:
 		return fmt.Errorf("invalid slave_id type: %T", v)
 	}
+	framing := FramingMBAP
+	if v, ok := config["framing"].(string); ok && v != "" {
+		framing = v
+	}
+	plan, err := parsePlanOptions(config)
+	if err != nil {
+		return err
+	}
 	addr := fmt.Sprintf("%s:%d", ip, port)
-	handler := modbus.NewTCPClientHandler(addr)
-	handler.Timeout = 5 * time.Second
-	handler.SlaveId = slaveId
+	handler, err := newFrameHandler(framing, addr, 5*time.Second, slaveId)
+	if err != nil {
+		return err
+	}
 	if err := handler.Connect(); err != nil {
 		return err
 	}
@@ -65,6 +83,8 @@ func (m *ModbusTCP) Init(config map[string]interface{}) error {
 	m.ip = ip
 	m.port = port
 	m.slaveId = slaveId
+	m.framing = framing
+	m.slave(slaveId).plan = plan
 	return nil
 }
 
@@ -83,9 +103,10 @@ func (m *ModbusTCP) ForceReconnect() error {
 		m.client = nil
 	}
 	addr := fmt.Sprintf("%s:%d", m.ip, m.port)
-	handler := modbus.NewTCPClientHandler(addr)
-	handler.Timeout = 5 * time.Second
-	handler.SlaveId = m.slaveId
+	handler, err := newFrameHandler(m.framing, addr, 5*time.Second, m.slaveId)
+	if err != nil {
+		return err
+	}
 	if err := handler.Connect(); err != nil {
 		log.Printf("[MODBUS] 强制重连失败: %v", err)
 		m.handler = nil
@@ -94,7 +115,7 @@ func (m *ModbusTCP) ForceReconnect() error {
 	}
 	m.handler = handler
 	m.client = modbus.NewClient(handler)
-	log.Printf("[MODBUS] 强制重连成功: %s", handler.Address)
+	log.Printf("[MODBUS] 强制重连成功: %s", handler.address())
 	return nil
 }
 
@@ -229,14 +250,7 @@ func (m *ModbusTCP) WriteSingleRegister(address, value uint16) error {
 }
 
 func (m *ModbusTCP) WriteMultipleCoils(address uint16, values []bool) error {
-	byteCount := (len(values) + 7) / 8
-	buf := make([]byte, byteCount)
-	for i, b := range values {
-		if b {
-			buf[i/8] |= 1 << (uint(i) % 8)
-		}
-	}
-	_, err := m.client.WriteMultipleCoils(address, uint16(len(values)), buf)
+	_, err := m.client.WriteMultipleCoils(address, uint16(len(values)), packBits(values))
 	return err
 }
 
@@ -267,349 +281,91 @@ func (m *ModbusTCP) Read(deviceID string) ([]protocols.PointValue, error) {
 	return values, nil
 }
 
-// ReadBatch 实现接口要求的方法：接受功能码和点位地址
+// ReadBatch 实现接口要求的方法：接受功能码和点位地址，按设备的规划参数合并读取
 func (m *ModbusTCP) ReadBatch(deviceID string, function string, points []string) ([]protocols.PointValue, error) {
-	// 仅支持03/04/01/02功能码，默认03
-	if function == "" {
-		function = "03"
-	}
-	pointConfigs := make([]protocols.PointConfig, len(points))
-	for i, pt := range points {
-		pointConfigs[i] = protocols.PointConfig{
-			PointID: pt,
-			Address: pt,
-			Format:  "", // 默认无格式解析
-		}
-	}
-	// 按功能码分流
-	switch function {
-	case "03":
-		return m.ReadBatchWithFormat(deviceID, pointConfigs)
-	case "04":
-		return m.readInputRegistersBatch(deviceID, pointConfigs)
-	case "01":
-		return m.readCoilsBatch(deviceID, pointConfigs)
-	case "02":
-		return m.readDiscreteInputsBatch(deviceID, pointConfigs)
-	default:
-		return m.ReadBatchWithFormat(deviceID, pointConfigs)
-	}
+	cfg := m.deviceConfig(deviceID)
+	return readBatch(m.client, function, points, cfg.points, cfg.plan)
 }
 
 // 批量读取保持寄存器（功能码03），支持格式化
 func (m *ModbusTCP) ReadBatchWithFormat(deviceID string, points []protocols.PointConfig) ([]protocols.PointValue, error) {
-	// 已集成底层断线自动重连能力（ReadHoldingRegisters）
-	if len(points) == 0 {
-		return nil, nil
-	}
-	type addrPoint struct {
-		addr   uint16
-		name   string
-		format string
-	}
-	var addrPoints []addrPoint
-	for _, pt := range points {
-		addr, err := parseAddress(pt.Address)
-		if err != nil {
-			return nil, fmt.Errorf("parse address for point %s failed: %v", pt.Address, err)
-		}
-		addrPoints = append(addrPoints, addrPoint{addr, pt.PointID, pt.Format})
-	}
-	sort.Slice(addrPoints, func(i, j int) bool { return addrPoints[i].addr < addrPoints[j].addr })
+	return readPoints(m.client, AreaHoldingRegister, points, m.deviceConfig(deviceID).plan)
+}
 
-	getRegCount := func(format string) int {
-		f := strings.ToUpper(format)
-		if strings.HasPrefix(f, "FLOAT") || strings.HasPrefix(f, "LONG") {
-			return 2
-		}
-		if strings.HasPrefix(f, "DOUBLE") {
-			return 4
-		}
-		return 1
-	}
+// ReadPlan 返回 ReadBatch 对这些点位将发出的读请求
+func (m *ModbusTCP) ReadPlan(deviceID string, function string, points []string) (protocols.ReadPlan, error) {
+	cfg := m.deviceConfig(deviceID)
+	return buildReadPlan(areaFromFunction(function), batchPointConfigs(points, cfg.points), cfg.plan)
+}
 
-	var results []protocols.PointValue
-	n := len(addrPoints)
-	i := 0
-	const maxRegs = 125
-	for i < n {
-		start := i
-		end := i
-		maxAddr := addrPoints[start].addr + uint16(getRegCount(addrPoints[start].format)) - 1
-		for j := i + 1; j < n; j++ {
-			need := getRegCount(addrPoints[j].format)
-			if addrPoints[j].addr <= maxAddr+1 && (addrPoints[j].addr+uint16(need)-addrPoints[start].addr) < maxRegs {
-				if addrPoints[j].addr+uint16(need)-1 > maxAddr {
-					maxAddr = addrPoints[j].addr + uint16(need) - 1
-				}
-				end = j
-			} else {
-				break
-			}
-		}
-		baseAddr := addrPoints[start].addr
-		quantity := maxAddr - baseAddr + 1
-		regVals, err := m.client.ReadHoldingRegisters(baseAddr, quantity)
-		if err != nil {
-			return nil, fmt.Errorf("modbus batch read failed: %v", err)
-		}
-		for k := start; k <= end; k++ {
-			offset := addrPoints[k].addr - baseAddr
-			regCount := getRegCount(addrPoints[k].format)
-			if int(offset)+regCount > len(regVals)/2 {
-				results = append(results, protocols.PointValue{
-					PointID:   addrPoints[k].name,
-					Value:     nil,
-					Quality:   "bad",
-					Timestamp: time.Now().Unix(),
-				})
-				continue
-			}
-			vals := make([]uint16, regCount)
-			for r := 0; r < regCount; r++ {
-				vals[r] = binary.BigEndian.Uint16(regVals[(int(offset)+r)*2 : (int(offset)+r)*2+2])
-			}
-			var val interface{} = vals
-			if regCount == 1 {
-				val = vals[0]
-			}
-			results = append(results, protocols.PointValue{
-				PointID:   addrPoints[k].name,
-				Value:     val,
-				Quality:   "good",
-				Timestamp: time.Now().Unix(),
-			})
-		}
-		i = end + 1
-	}
-	return results, nil
+// Write 按点位配置的 Format 编码写入，point 可为地址或点位名
+func (m *ModbusTCP) Write(point string, value interface{}) error {
+	m.slavesMu.RLock()
+	var table pointTable
+	if cfg, ok := m.slaves[m.slaveId]; ok {
+		table = cfg.points
+	}
+	m.slavesMu.RUnlock()
+	addr, format := table.lookup(point)
+	return writePoint(m.client, addr, format, value)
 }
 
-// 新增：批量读取输入寄存器（功能码04）
-func (m *ModbusTCP) readInputRegistersBatch(deviceID string, points []protocols.PointConfig) ([]protocols.PointValue, error) {
-	// 已集成底层断线自动重连能力（ReadInputRegisters）
-	if len(points) == 0 {
-		return nil, nil
-	}
-	type addrPoint struct {
-		addr   uint16
-		name   string
-		format string
-	}
-	var addrPoints []addrPoint
-	for _, pt := range points {
-		addr, err := parseAddress(pt.Address)
-		if err != nil {
-			return nil, fmt.Errorf("parse address for point %s failed: %v", pt.Address, err)
-		}
-		addrPoints = append(addrPoints, addrPoint{addr, pt.PointID, pt.Format})
+// ConfigureDevice 记录设备对应的从站号及其块读取规划参数
+func (m *ModbusTCP) ConfigureDevice(deviceID string, config map[string]interface{}) error {
+	plan, err := parsePlanOptions(config)
+	if err != nil {
+		return err
 	}
-	sort.Slice(addrPoints, func(i, j int) bool { return addrPoints[i].addr < addrPoints[j].addr })
-
-	getRegCount := func(format string) int {
-		f := strings.ToUpper(format)
-		if strings.HasPrefix(f, "FLOAT") || strings.HasPrefix(f, "LONG") {
-			return 2
-		}
-		if strings.HasPrefix(f, "DOUBLE") {
-			return 4
-		}
-		return 1
+	m.slavesMu.Lock()
+	defer m.slavesMu.Unlock()
+	slaveId := m.slaveId
+	if v, ok := toInt(config["slave_id"]); ok {
+		slaveId = byte(v)
 	}
-
-	var results []protocols.PointValue
-	n := len(addrPoints)
-	i := 0
-	const maxRegs = 125
-	for i < n {
-		start := i
-		end := i
-		maxAddr := addrPoints[start].addr + uint16(getRegCount(addrPoints[start].format)) - 1
-		for j := i + 1; j < n; j++ {
-			need := getRegCount(addrPoints[j].format)
-			if addrPoints[j].addr <= maxAddr+1 && (addrPoints[j].addr+uint16(need)-addrPoints[start].addr) < maxRegs {
-				if addrPoints[j].addr+uint16(need)-1 > maxAddr {
-					maxAddr = addrPoints[j].addr + uint16(need) - 1
-				}
-				end = j
-			} else {
-				break
-			}
-		}
-		baseAddr := addrPoints[start].addr
-		quantity := maxAddr - baseAddr + 1
-		regVals, err := m.client.ReadInputRegisters(baseAddr, quantity)
-		if err != nil {
-			return nil, fmt.Errorf("modbus input batch read failed: %v", err)
-		}
-		for k := start; k <= end; k++ {
-			offset := addrPoints[k].addr - baseAddr
-			regCount := getRegCount(addrPoints[k].format)
-			if int(offset)+regCount > len(regVals)/2 {
-				results = append(results, protocols.PointValue{
-					PointID:   addrPoints[k].name,
-					Value:     nil,
-					Quality:   "bad",
-					Timestamp: time.Now().Unix(),
-				})
-				continue
-			}
-			vals := make([]uint16, regCount)
-			for r := 0; r < regCount; r++ {
-				vals[r] = binary.BigEndian.Uint16(regVals[(int(offset)+r)*2 : (int(offset)+r)*2+2])
-			}
-			var val interface{} = vals
-			if regCount == 1 {
-				val = vals[0]
-			}
-			results = append(results, protocols.PointValue{
-				PointID:   addrPoints[k].name,
-				Value:     val,
-				Quality:   "good",
-				Timestamp: time.Now().Unix(),
-			})
-		}
-		i = end + 1
+	if m.devices == nil {
+		m.devices = make(map[string]byte)
 	}
-	return results, nil
+	m.devices[deviceID] = slaveId
+	m.slave(slaveId).plan = plan
+	return nil
 }
 
-// 新增：批量读取线圈（功能码01）
-func (m *ModbusTCP) readCoilsBatch(deviceID string, points []protocols.PointConfig) ([]protocols.PointValue, error) {
-	// 已集成底层断线自动重连能力（ReadCoils）
-	if len(points) == 0 {
-		return nil, nil
-	}
-	type addrPoint struct {
-		addr uint16
-		name string
-	}
-	var addrPoints []addrPoint
-	for _, pt := range points {
-		addr, err := parseAddress(pt.Address)
-		if err != nil {
-			return nil, fmt.Errorf("parse address for point %s failed: %v", pt.Address, err)
-		}
-		addrPoints = append(addrPoints, addrPoint{addr, pt.PointID})
-	}
-	sort.Slice(addrPoints, func(i, j int) bool { return addrPoints[i].addr < addrPoints[j].addr })
-
-	var results []protocols.PointValue
-	n := len(addrPoints)
-	i := 0
-	const maxCoils = 2000
-	for i < n {
-		start := i
-		end := i
-		maxAddr := addrPoints[start].addr
-		for j := i + 1; j < n; j++ {
-			if addrPoints[j].addr == maxAddr+1 && (addrPoints[j].addr-addrPoints[start].addr) < maxCoils {
-				maxAddr = addrPoints[j].addr
-				end = j
-			} else {
-				break
-			}
-		}
-		baseAddr := addrPoints[start].addr
-		quantity := maxAddr - baseAddr + 1
-		coils, err := m.client.ReadCoils(baseAddr, quantity)
-		if err != nil {
-			return nil, fmt.Errorf("modbus coils batch read failed: %v", err)
-		}
-		for k := start; k <= end; k++ {
-			offset := addrPoints[k].addr - baseAddr
-			val := (coils[offset/8]>>(offset%8))&0x01 == 0x01
-			results = append(results, protocols.PointValue{
-				PointID:   addrPoints[k].name,
-				Value:     val,
-				Quality:   "good",
-				Timestamp: time.Now().Unix(),
-			})
-		}
-		i = end + 1
+// SetPointConfigs 记录设备所在从站的点位配置，未调用 ConfigureDevice 时按当前从站处理
+func (m *ModbusTCP) SetPointConfigs(deviceID string, points []protocols.PointConfig) {
+	m.slavesMu.Lock()
+	defer m.slavesMu.Unlock()
+	slaveId, ok := m.devices[deviceID]
+	if !ok {
+		slaveId = m.slaveId
 	}
-	return results, nil
+	m.slave(slaveId).points = newPointTable(points)
 }
 
-// 新增：批量读取离散输入（功能码02）
-func (m *ModbusTCP) readDiscreteInputsBatch(deviceID string, points []protocols.PointConfig) ([]protocols.PointValue, error) {
-	// 已集成底层断线自动重连能力（ReadDiscreteInputs）
-	if len(points) == 0 {
-		return nil, nil
-	}
-	type addrPoint struct {
-		addr uint16
-		name string
-	}
-	var addrPoints []addrPoint
-	for _, pt := range points {
-		addr, err := parseAddress(pt.Address)
-		if err != nil {
-			return nil, fmt.Errorf("parse address for point %s failed: %v", pt.Address, err)
-		}
-		addrPoints = append(addrPoints, addrPoint{addr, pt.PointID})
+// slave 返回（或创建）从站配置，调用方需持有写锁或处于初始化阶段
+func (m *ModbusTCP) slave(slaveId byte) *slaveConfig {
+	if m.slaves == nil {
+		m.slaves = make(map[byte]*slaveConfig)
 	}
-	sort.Slice(addrPoints, func(i, j int) bool { return addrPoints[i].addr < addrPoints[j].addr })
-
-	var results []protocols.PointValue
-	n := len(addrPoints)
-	i := 0
-	const maxInputs = 2000
-	for i < n {
-		start := i
-		end := i
-		maxAddr := addrPoints[start].addr
-		for j := i + 1; j < n; j++ {
-			if addrPoints[j].addr == maxAddr+1 && (addrPoints[j].addr-addrPoints[start].addr) < maxInputs {
-				maxAddr = addrPoints[j].addr
-				end = j
-			} else {
-				break
-			}
-		}
-		baseAddr := addrPoints[start].addr
-		quantity := maxAddr - baseAddr + 1
-		inputs, err := m.client.ReadDiscreteInputs(baseAddr, quantity)
-		if err != nil {
-			return nil, fmt.Errorf("modbus discrete inputs batch read failed: %v", err)
-		}
-		for k := start; k <= end; k++ {
-			offset := addrPoints[k].addr - baseAddr
-			val := (inputs[offset/8]>>(offset%8))&0x01 == 0x01
-			results = append(results, protocols.PointValue{
-				PointID:   addrPoints[k].name,
-				Value:     val,
-				Quality:   "good",
-				Timestamp: time.Now().Unix(),
-			})
-		}
-		i = end + 1
+	cfg, ok := m.slaves[slaveId]
+	if !ok {
+		cfg = &slaveConfig{plan: defaultPlanOptions()}
+		m.slaves[slaveId] = cfg
 	}
-	return results, nil
+	return cfg
 }
 
-func (m *ModbusTCP) Write(point string, value interface{}) error {
-	addr, err := parseAddress(point)
-	if err != nil {
-		return err
+// deviceConfig 返回设备所在从站配置的副本
+func (m *ModbusTCP) deviceConfig(deviceID string) slaveConfig {
+	m.slavesMu.RLock()
+	defer m.slavesMu.RUnlock()
+	slaveId, ok := m.devices[deviceID]
+	if !ok {
+		slaveId = m.slaveId
 	}
-	switch v := value.(type) {
-	case bool:
-		var val uint16 = 0x0000
-		if v {
-			val = 0xFF00
-		}
-		_, err := m.client.WriteSingleCoil(addr, val)
-		return err
-	case uint16:
-		_, err := m.client.WriteSingleRegister(addr, v)
-		return err
-	case int:
-		_, err := m.client.WriteSingleRegister(addr, uint16(v))
-		return err
-	default:
-		return fmt.Errorf("unsupported value type: %T", value)
+	if cfg, ok := m.slaves[slaveId]; ok {
+		return *cfg
 	}
+	return slaveConfig{plan: defaultPlanOptions()}
 }
 
 func (m *ModbusTCP) Close() error {
@@ -619,25 +375,6 @@ func (m *ModbusTCP) Close() error {
 	return nil
 }
 
-func parseAddress(point string) (uint16, error) {
-	if len(point) < 2 {
-		return 0, fmt.Errorf("invalid point address")
-	}
-	var base int
-	switch point[0] {
-	case '4':
-		base = 40001
-	default:
-		base = 1
-	}
-	var addr int
-	_, err := fmt.Sscanf(point, "%d", &addr)
-	if err != nil {
-		return 0, err
-	}
-	return uint16(addr - base), nil
-}
-
 func NewModbusTCP() protocols.Protocol {
 	return &ModbusTCP{}
 }
@@ -647,8 +384,9 @@ func init() {
 }
 
 func (m *ModbusTCP) SetSlave(slaveId byte) {
+	m.slaveId = slaveId
 	if m.handler != nil {
-		m.handler.SlaveId = slaveId
+		m.handler.setSlave(slaveId)
 	}
 }
 
@@ -0,0 +1,225 @@
+package modbus
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"sensor-edge/protocols"
+)
+
+// planOptions 块读取规划参数，按设备配置：
+//
+//	max_gap: 4                       # 两个点位之间允许一并读取的空洞数量（寄存器/位），默认0即仅合并相邻地址
+//	max_registers_per_request: 64    # 单次读寄存器上限（03/04），默认125
+//	max_coils_per_request: 800       # 单次读线圈/离散输入上限（01/02），默认2000
+//	forbidden_addresses:             # 禁止读取的地址（区间），读这些地址会导致设备报异常
+//	  - 40100-40120
+//	  - hr:300-310
+type planOptions struct {
+	maxGap       int
+	maxRegisters int
+	maxBits      int
+	forbidden    []addressRange
+	bad          *badRanges // 读取时发现的非法地址，副本之间共享
+}
+
+// addressRange 闭区间地址范围，area 为 AreaUnknown 时对所有数据区生效
+type addressRange struct {
+	area       Area
+	start, end int
+}
+
+func (r addressRange) overlaps(area Area, start, end int) bool {
+	if r.area != AreaUnknown && r.area != area {
+		return false
+	}
+	return start <= r.end && end >= r.start
+}
+
+func defaultPlanOptions() planOptions {
+	return planOptions{maxRegisters: maxRegsPerRequest, maxBits: maxBitsPerRequest, bad: newBadRanges()}
+}
+
+// parsePlanOptions 从设备配置读取规划参数，超出协议上限的值按上限处理
+func parsePlanOptions(config map[string]interface{}) (planOptions, error) {
+	o := defaultPlanOptions()
+	if v, ok := toInt(config["max_gap"]); ok {
+		if v < 0 {
+			return o, fmt.Errorf("invalid max_gap: %d", v)
+		}
+		o.maxGap = v
+	}
+	if v, ok := toInt(config["max_registers_per_request"]); ok {
+		if v < 1 {
+			return o, fmt.Errorf("invalid max_registers_per_request: %d", v)
+		}
+		if v < maxRegsPerRequest {
+			o.maxRegisters = v
+		}
+	}
+	if v, ok := toInt(config["max_coils_per_request"]); ok {
+		if v < 1 {
+			return o, fmt.Errorf("invalid max_coils_per_request: %d", v)
+		}
+		if v < maxBitsPerRequest {
+			o.maxBits = v
+		}
+	}
+	if list, ok := config["forbidden_addresses"].([]interface{}); ok {
+		for _, item := range list {
+			r, err := parseAddressRange(fmt.Sprint(item))
+			if err != nil {
+				return o, err
+			}
+			o.forbidden = append(o.forbidden, r)
+		}
+	}
+	return o, nil
+}
+
+// parseAddressRange 解析 "40100"、"40100-40120"、"hr:10-20" 形式的地址区间，
+// 两端须属于同一数据区
+func parseAddressRange(s string) (addressRange, error) {
+	from, to := s, s
+	if i := strings.Index(s, "-"); i >= 0 {
+		from, to = strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1:])
+		if j := strings.Index(from, ":"); j >= 0 && !strings.Contains(to, ":") {
+			to = from[:j+1] + to
+		}
+	}
+	start, err := ParseAddress(from)
+	if err != nil {
+		return addressRange{}, fmt.Errorf("invalid forbidden address %s: %v", s, err)
+	}
+	end, err := ParseAddress(to)
+	if err != nil {
+		return addressRange{}, fmt.Errorf("invalid forbidden address %s: %v", s, err)
+	}
+	if start.Area != end.Area || start.Offset > end.Offset {
+		return addressRange{}, fmt.Errorf("invalid forbidden address range %s", s)
+	}
+	return addressRange{area: start.Area, start: int(start.Offset), end: int(end.Offset)}, nil
+}
+
+// readBlock 规划出的一次读请求
+type readBlock struct {
+	area   Area
+	start  int
+	count  int
+	points []modbusPoint
+}
+
+func (b readBlock) end() int {
+	return b.start + b.count - 1
+}
+
+// limit 单次请求的数量上限
+func (o planOptions) limit(area Area) int {
+	if area.IsBit() {
+		return o.maxBits
+	}
+	return o.maxRegisters
+}
+
+// isForbidden 是否覆盖配置的禁止地址或读取时发现的非法地址
+func (o planOptions) isForbidden(area Area, start, end int) bool {
+	for _, r := range o.forbidden {
+		if r.overlaps(area, start, end) {
+			return true
+		}
+	}
+	return o.bad.overlaps(area, start, end)
+}
+
+// pointSpan 点位占用的地址区间（位数据区固定1个地址）
+func pointSpan(p modbusPoint) (int, int) {
+	start := int(p.addr.Offset)
+	if p.addr.Area.IsBit() {
+		return start, start
+	}
+	return start, start + pointRegisterCount(p) - 1
+}
+
+// plan 将同一数据区的点位按地址排序后贪心合并：与当前块的空洞不超过 maxGap、
+// 合并后不超过单次上限且不覆盖禁止地址时并入，否则另起一块。
+// 自身落在禁止地址或已知非法地址内的点位不读取，通过 skipped 返回；单个点位超过上限时单独成块
+func (o planOptions) plan(area Area, points []modbusPoint) (blocks []readBlock, skipped []modbusPoint) {
+	sorted := make([]modbusPoint, len(points))
+	copy(sorted, points)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].addr.Offset < sorted[j].addr.Offset })
+
+	limit := o.limit(area)
+	var cur *readBlock
+	for _, p := range sorted {
+		start, end := pointSpan(p)
+		if end > 0xFFFF || o.isForbidden(area, start, end) {
+			skipped = append(skipped, p)
+			continue
+		}
+		if cur != nil {
+			newEnd := cur.end()
+			if end > newEnd {
+				newEnd = end
+			}
+			if start-cur.end()-1 <= o.maxGap && newEnd-cur.start+1 <= limit && !o.isForbidden(area, cur.start, newEnd) {
+				cur.count = newEnd - cur.start + 1
+				cur.points = append(cur.points, p)
+				continue
+			}
+			blocks = append(blocks, *cur)
+		}
+		cur = &readBlock{area: area, start: start, count: end - start + 1, points: []modbusPoint{p}}
+	}
+	if cur != nil {
+		blocks = append(blocks, *cur)
+	}
+	return blocks, skipped
+}
+
+// groupPoints 解析点位地址并按数据区分组
+func groupPoints(defaultArea Area, points []protocols.PointConfig) (map[Area][]modbusPoint, error) {
+	if defaultArea == AreaUnknown {
+		defaultArea = AreaHoldingRegister
+	}
+	groups := make(map[Area][]modbusPoint)
+	for _, pt := range points {
+		addr, err := ParseAddress(pt.Address)
+		if err != nil {
+			return nil, fmt.Errorf("parse address for point %s failed: %v", pt.Address, err)
+		}
+		addr = addr.resolve(defaultArea)
+		groups[addr.Area] = append(groups[addr.Area], modbusPoint{pt.PointID, addr, pt.Format})
+	}
+	return groups, nil
+}
+
+// readAreas 各数据区的读取顺序
+var readAreas = []Area{AreaCoil, AreaDiscreteInput, AreaInputRegister, AreaHoldingRegister}
+
+// buildReadPlan 计算点位的读请求计划，不访问设备，供调试接口使用
+func buildReadPlan(defaultArea Area, points []protocols.PointConfig, opts planOptions) (protocols.ReadPlan, error) {
+	var plan protocols.ReadPlan
+	groups, err := groupPoints(defaultArea, points)
+	if err != nil {
+		return plan, err
+	}
+	for _, area := range readAreas {
+		blocks, skipped := opts.plan(area, groups[area])
+		for _, b := range blocks {
+			req := protocols.ReadRequest{
+				Function: fmt.Sprintf("%02d", area.ReadFunction()),
+				Start:    b.start,
+				Quantity: b.count,
+			}
+			for _, p := range b.points {
+				req.Points = append(req.Points, p.name)
+			}
+			plan.Requests = append(plan.Requests, req)
+		}
+		for _, p := range skipped {
+			plan.Skipped = append(plan.Skipped, p.name)
+		}
+	}
+	return plan, nil
+}
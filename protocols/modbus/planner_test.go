@@ -0,0 +1,123 @@
+package modbus
+
+import (
+	"reflect"
+	"testing"
+
+	"sensor-edge/protocols"
+)
+
+func TestReadPlan(t *testing.T) {
+	points := []protocols.PointConfig{
+		{PointID: "a", Address: "40001"},
+		{PointID: "b", Address: "40003", Format: "Float AB CD"},
+		{PointID: "c", Address: "40010"},
+		{PointID: "d", Address: "40012"},
+		{PointID: "e", Address: "40016"},
+		{PointID: "f", Address: "40017"},
+		{PointID: "x", Address: "00001"},
+		{PointID: "y", Address: "00004"},
+	}
+	opts, err := parsePlanOptions(map[string]interface{}{
+		"max_gap":                   7,
+		"max_registers_per_request": 8,
+		"max_coils_per_request":     2,
+		"forbidden_addresses":       []interface{}{"40013-40015", "40017"},
+	})
+	if err != nil {
+		t.Fatalf("parsePlanOptions failed: %v", err)
+	}
+	plan, err := buildReadPlan(AreaHoldingRegister, points, opts)
+	if err != nil {
+		t.Fatalf("buildReadPlan failed: %v", err)
+	}
+	want := []protocols.ReadRequest{
+		{Function: "01", Start: 0, Quantity: 1, Points: []string{"x"}},
+		{Function: "01", Start: 3, Quantity: 1, Points: []string{"y"}},
+		// 40001~40004 跨过1个空洞合并；并入 40010 会超过单次上限，另起一块
+		{Function: "03", Start: 0, Quantity: 4, Points: []string{"a", "b"}},
+		{Function: "03", Start: 9, Quantity: 3, Points: []string{"c", "d"}},
+		// 40012 与 40016 之间是禁止地址，不能合并
+		{Function: "03", Start: 15, Quantity: 1, Points: []string{"e"}},
+	}
+	if !reflect.DeepEqual(plan.Requests, want) {
+		t.Errorf("unexpected plan:\n got %+v\nwant %+v", plan.Requests, want)
+	}
+	if !reflect.DeepEqual(plan.Skipped, []string{"f"}) {
+		t.Errorf("unexpected skipped points: %v", plan.Skipped)
+	}
+
+	fc := &fakeClient{regs: map[uint16]uint16{0: 1, 2: 0x4120, 3: 0, 9: 9, 11: 11, 15: 15}}
+	vals, err := readPoints(fc, AreaHoldingRegister, points[:6], opts)
+	if err != nil {
+		t.Fatalf("readPoints failed: %v", err)
+	}
+	got := map[string]protocols.PointValue{}
+	for _, v := range vals {
+		got[v.PointID] = v
+	}
+	if !reflect.DeepEqual(got["b"].Value, []uint16{0x4120, 0}) || got["d"].Value != uint16(11) || got["e"].Value != uint16(15) {
+		t.Errorf("unexpected values: %+v", vals)
+	}
+	if got["f"].Quality != "bad" {
+		t.Errorf("forbidden point should be bad, got %+v", got["f"])
+	}
+}
+
+func TestParsePlanOptionsInvalid(t *testing.T) {
+	for _, cfg := range []map[string]interface{}{
+		{"max_gap": -1},
+		{"max_registers_per_request": 0},
+		{"forbidden_addresses": []interface{}{"40010-30020"}},
+		{"forbidden_addresses": []interface{}{"40020-40010"}},
+	} {
+		if _, err := parsePlanOptions(cfg); err == nil {
+			t.Errorf("%v: expect error", cfg)
+		}
+	}
+}
+
+func TestReadPointsIsolatesIllegalAddress(t *testing.T) {
+	fc := &fakeClient{
+		regs:    map[uint16]uint16{0: 10, 1: 11, 2: 12, 5: 15, 6: 16},
+		illegal: map[uint16]bool{2: true, 4: true},
+	}
+	points := []protocols.PointConfig{
+		{PointID: "a", Address: "40001"},
+		{PointID: "b", Address: "40002"},
+		{PointID: "c", Address: "40003"},
+		{PointID: "d", Address: "40006"},
+		{PointID: "e", Address: "40007"},
+	}
+	opts := defaultPlanOptions()
+	opts.maxGap = 2
+	read := func() map[string]protocols.PointValue {
+		vals, err := readPoints(fc, AreaHoldingRegister, points, opts)
+		if err != nil {
+			t.Fatalf("readPoints failed: %v", err)
+		}
+		got := map[string]protocols.PointValue{}
+		for _, v := range vals {
+			got[v.PointID] = v
+		}
+		return got
+	}
+	check := func(got map[string]protocols.PointValue) {
+		if got["c"].Quality != "bad" {
+			t.Errorf("point on illegal address should be bad, got %+v", got["c"])
+		}
+		for name, want := range map[string]uint16{"a": 10, "b": 11, "d": 15, "e": 16} {
+			if got[name].Quality != "good" || got[name].Value != want {
+				t.Errorf("point %s: got %+v, want %d", name, got[name], want)
+			}
+		}
+	}
+	check(read())
+
+	// 第二轮直接跳过已知非法地址：40001~40002 与 40006~40007 各一次请求
+	fc.reads = 0
+	check(read())
+	if fc.reads != 2 {
+		t.Errorf("expect 2 requests after learning bad ranges, got %d", fc.reads)
+	}
+}
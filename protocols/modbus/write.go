@@ -0,0 +1,183 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"sensor-edge/protocols"
+	"sensor-edge/utils"
+
+	"github.com/goburrow/modbus"
+)
+
+// pointTable 按地址和点位名索引的点位配置，写入时据此查找 Format
+type pointTable map[string]protocols.PointConfig
+
+func newPointTable(points []protocols.PointConfig) pointTable {
+	t := make(pointTable, len(points)*2)
+	for _, p := range points {
+		if p.Address != "" {
+			t[p.Address] = p
+		}
+		if p.PointID != "" {
+			t[p.PointID] = p
+		}
+	}
+	return t
+}
+
+// lookup 返回点位对应的地址和格式，未配置的点位按原样作为地址、无格式处理
+func (t pointTable) lookup(point string) (string, string) {
+	if p, ok := t[point]; ok {
+		return p.Address, p.Format
+	}
+	return point, ""
+}
+
+// writePoint 按地址数据区和点位格式编码并写入：
+//   - 线圈：bool/0/1 用05，[]bool 用15
+//   - 保持寄存器：单寄存器值用06，多寄存器格式（Long/Float/Double）及 []uint16 用16，
+//     位地址（40001.3）先读后写对应位
+//   - 离散输入、输入寄存器只读
+//
+// 地址未指明数据区时沿用旧规则：bool/[]bool 写线圈，其余写保持寄存器
+func writePoint(client modbus.Client, point, format string, value interface{}) error {
+	addr, err := ParseAddress(point)
+	if err != nil {
+		return err
+	}
+	if addr.Area == AreaUnknown {
+		switch value.(type) {
+		case bool, []bool:
+			addr.Area = AreaCoil
+		default:
+			addr.Area = AreaHoldingRegister
+		}
+	}
+	switch addr.Area {
+	case AreaDiscreteInput, AreaInputRegister:
+		return fmt.Errorf("address %s is read-only (%s)", point, addr.Area)
+	case AreaCoil:
+		return writeCoils(client, addr.Offset, value)
+	}
+	if addr.HasBit() {
+		return writeRegisterBit(client, addr, value)
+	}
+	if strings.EqualFold(format, "bool") {
+		format = ""
+	}
+	switch v := value.(type) {
+	case bool:
+		value = 0
+		if v {
+			value = 1
+		}
+	case []uint16:
+		if len(v) == 0 || len(v) > 123 {
+			return fmt.Errorf("invalid register count: %d", len(v))
+		}
+		buf := make([]byte, 2*len(v))
+		for i, r := range v {
+			binary.BigEndian.PutUint16(buf[2*i:], r)
+		}
+		_, err := client.WriteMultipleRegisters(addr.Offset, uint16(len(v)), buf)
+		return err
+	}
+	raw, err := encodeRegisters(format, value)
+	if err != nil {
+		return fmt.Errorf("encode value for point %s failed: %v", point, err)
+	}
+	if len(raw) == 2 {
+		_, err = client.WriteSingleRegister(addr.Offset, binary.BigEndian.Uint16(raw))
+		return err
+	}
+	_, err = client.WriteMultipleRegisters(addr.Offset, uint16(len(raw)/2), raw)
+	return err
+}
+
+// writeCoils 写单个线圈（05）或连续线圈（15）
+func writeCoils(client modbus.Client, offset uint16, value interface{}) error {
+	if v, ok := value.([]bool); ok {
+		if len(v) == 0 || len(v) > 1968 {
+			return fmt.Errorf("invalid coil count: %d", len(v))
+		}
+		_, err := client.WriteMultipleCoils(offset, uint16(len(v)), packBits(v))
+		return err
+	}
+	on, err := toBool(value)
+	if err != nil {
+		return err
+	}
+	var val uint16 = 0x0000
+	if on {
+		val = 0xFF00
+	}
+	_, err = client.WriteSingleCoil(offset, val)
+	return err
+}
+
+// writeRegisterBit 读出寄存器当前值，修改指定位后写回（非原子操作）
+func writeRegisterBit(client modbus.Client, addr Address, value interface{}) error {
+	on, err := toBool(value)
+	if err != nil {
+		return err
+	}
+	results, err := client.ReadHoldingRegisters(addr.Offset, 1)
+	if err != nil {
+		return fmt.Errorf("read register before bit write failed: %v", err)
+	}
+	if len(results) < 2 {
+		return fmt.Errorf("read register before bit write failed: short response")
+	}
+	reg := binary.BigEndian.Uint16(results)
+	if on {
+		reg |= 1 << uint(addr.Bit)
+	} else {
+		reg &^= 1 << uint(addr.Bit)
+	}
+	_, err = client.WriteSingleRegister(addr.Offset, reg)
+	return err
+}
+
+// toBool 接受 bool 或数值 0/1
+func toBool(value interface{}) (bool, error) {
+	if b, ok := value.(bool); ok {
+		return b, nil
+	}
+	f, ok := utils.ToFloat64(value)
+	if !ok || (f != 0 && f != 1) {
+		return false, fmt.Errorf("invalid bool value: %v", value)
+	}
+	return f == 1, nil
+}
+
+// encodeRegisters 将值编码为寄存器字节；未配置格式时按单寄存器写入，
+// 接受 -32768~65535 的整数（负数按补码），超出范围报错
+func encodeRegisters(format string, value interface{}) ([]byte, error) {
+	if format != "" && utils.CanonicalFormat(format) != "" {
+		return utils.EncodeFormat(format, value)
+	}
+	if format != "" {
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	}
+	f, ok := utils.ToFloat64(value)
+	if !ok {
+		return nil, fmt.Errorf("unsupported value type: %T", value)
+	}
+	if f < 0 {
+		return utils.EncodeFormat(utils.FormatInt, f)
+	}
+	return utils.EncodeFormat(utils.FormatUInt, f)
+}
+
+// packBits 按 Modbus 线圈顺序（低位在前）打包
+func packBits(values []bool) []byte {
+	buf := make([]byte, (len(values)+7)/8)
+	for i, b := range values {
+		if b {
+			buf[i/8] |= 1 << (uint(i) % 8)
+		}
+	}
+	return buf
+}
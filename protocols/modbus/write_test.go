@@ -0,0 +1,136 @@
+package modbus
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"sensor-edge/protocols"
+
+	"github.com/goburrow/modbus"
+)
+
+// fakeClient 记录最后一次写请求，读保持寄存器返回 regs 中的值，
+// 读取范围包含 illegal 中的地址时返回异常码02
+type fakeClient struct {
+	modbus.Client
+	fc       byte
+	address  uint16
+	quantity uint16
+	data     []byte
+	regs     map[uint16]uint16
+	illegal  map[uint16]bool
+	reads    int
+}
+
+func (c *fakeClient) ReadHoldingRegisters(address, quantity uint16) ([]byte, error) {
+	c.reads++
+	for i := uint16(0); i < quantity; i++ {
+		if c.illegal[address+i] {
+			return nil, &modbus.ModbusError{
+				FunctionCode:  modbus.FuncCodeReadHoldingRegisters | 0x80,
+				ExceptionCode: modbus.ExceptionCodeIllegalDataAddress,
+			}
+		}
+	}
+	var out []byte
+	for i := uint16(0); i < quantity; i++ {
+		out = binary.BigEndian.AppendUint16(out, c.regs[address+i])
+	}
+	return out, nil
+}
+
+func (c *fakeClient) WriteSingleCoil(address, value uint16) ([]byte, error) {
+	c.fc, c.address, c.quantity = modbus.FuncCodeWriteSingleCoil, address, 1
+	c.data = binary.BigEndian.AppendUint16(nil, value)
+	return nil, nil
+}
+
+func (c *fakeClient) WriteMultipleCoils(address, quantity uint16, value []byte) ([]byte, error) {
+	c.fc, c.address, c.quantity, c.data = modbus.FuncCodeWriteMultipleCoils, address, quantity, value
+	return nil, nil
+}
+
+func (c *fakeClient) WriteSingleRegister(address, value uint16) ([]byte, error) {
+	c.fc, c.address, c.quantity = modbus.FuncCodeWriteSingleRegister, address, 1
+	c.data = binary.BigEndian.AppendUint16(nil, value)
+	return nil, nil
+}
+
+func (c *fakeClient) WriteMultipleRegisters(address, quantity uint16, value []byte) ([]byte, error) {
+	c.fc, c.address, c.quantity, c.data = modbus.FuncCodeWriteMultipleRegisters, address, quantity, value
+	return nil, nil
+}
+
+func TestWriteWithFormat(t *testing.T) {
+	fc := &fakeClient{}
+	m := &ModbusTCP{client: fc}
+	m.SetPointConfigs("dev", []protocols.PointConfig{
+		{PointID: "setpoint", Address: "40001", Format: "Float AB CD"},
+		{PointID: "energy", Address: "40011", Format: "Double GH EF CD AB"},
+		{PointID: "count", Address: "40021", Format: "Long AB CD"},
+		{PointID: "offset", Address: "40031", Format: "INT"},
+	})
+
+	cases := []struct {
+		point    string
+		value    interface{}
+		fc       byte
+		address  uint16
+		quantity uint16
+		data     []byte
+	}{
+		{"setpoint", 12.5, modbus.FuncCodeWriteMultipleRegisters, 0, 2, []byte{0x41, 0x48, 0x00, 0x00}},
+		{"40011", 1.0, modbus.FuncCodeWriteMultipleRegisters, 10, 4, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x3F, 0xF0}},
+		{"count", 70000, modbus.FuncCodeWriteMultipleRegisters, 20, 2, []byte{0x00, 0x01, 0x11, 0x70}},
+		{"offset", -2, modbus.FuncCodeWriteSingleRegister, 30, 1, []byte{0xFF, 0xFE}},
+		{"00041", true, modbus.FuncCodeWriteSingleCoil, 40, 1, []byte{0xFF, 0x00}},
+		{"co:50", []bool{true, false, true}, modbus.FuncCodeWriteMultipleCoils, 50, 3, []byte{0x05}},
+		{"40061", true, modbus.FuncCodeWriteSingleRegister, 60, 1, []byte{0x00, 0x01}},
+		{"400071", 7, modbus.FuncCodeWriteSingleRegister, 70, 1, []byte{0x00, 0x07}},
+	}
+	for _, c := range cases {
+		if err := m.Write(c.point, c.value); err != nil {
+			t.Errorf("Write %s failed: %v", c.point, err)
+			continue
+		}
+		if fc.fc != c.fc || fc.address != c.address || fc.quantity != c.quantity || string(fc.data) != string(c.data) {
+			t.Errorf("Write %s: got fc=%d addr=%d qty=%d data=% x", c.point, fc.fc, fc.address, fc.quantity, fc.data)
+		}
+	}
+}
+
+func TestWriteOutOfRange(t *testing.T) {
+	m := &ModbusTCP{client: &fakeClient{}}
+	m.SetPointConfigs("dev", []protocols.PointConfig{
+		{PointID: "offset", Address: "40031", Format: "INT"},
+	})
+	if err := m.Write("offset", 40000); err == nil {
+		t.Error("INT overflow not detected")
+	}
+	if err := m.Write("40001", 70000); err == nil {
+		t.Error("unformatted register overflow not detected")
+	}
+	if err := m.Write("30001", 1); err == nil {
+		t.Error("write to input register not rejected")
+	}
+	if err := m.Write("10001", true); err == nil {
+		t.Error("write to discrete input not rejected")
+	}
+}
+
+func TestWriteRegisterBit(t *testing.T) {
+	fc := &fakeClient{regs: map[uint16]uint16{4: 0x00F0}}
+	m := &ModbusTCP{client: fc}
+	if err := m.Write("40005.0", true); err != nil {
+		t.Fatalf("Write bit failed: %v", err)
+	}
+	if fc.fc != modbus.FuncCodeWriteSingleRegister || fc.address != 4 || binary.BigEndian.Uint16(fc.data) != 0x00F1 {
+		t.Errorf("set bit: got fc=%d addr=%d data=% x", fc.fc, fc.address, fc.data)
+	}
+	if err := m.Write("hr:4.7", 0); err != nil {
+		t.Fatalf("Write bit failed: %v", err)
+	}
+	if binary.BigEndian.Uint16(fc.data) != 0x0070 {
+		t.Errorf("clear bit: got data=% x", fc.data)
+	}
+}
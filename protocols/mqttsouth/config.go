@@ -0,0 +1,157 @@
+package mqttsouth
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// connConfig 连接级配置，同一 broker 的设备共享一个连接
+type connConfig struct {
+	Broker    string    `yaml:"broker"` // tcp://host:1883 | ssl://host:8883 | ws://host/mqtt
+	ClientID  string    `yaml:"client_id"`
+	Username  string    `yaml:"username"`
+	Password  string    `yaml:"password"`
+	QoS       int       `yaml:"qos"`        // 订阅和命令的默认 QoS
+	KeepAlive int       `yaml:"keep_alive"` // 心跳间隔(秒)，默认 30
+	Timeout   int       `yaml:"timeout"`    // 连接、订阅和发布的等待时间(毫秒)，默认 5000
+	TLS       tlsConfig `yaml:"tls"`
+}
+
+type tlsConfig struct {
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"` // 客户端证书（双向认证）
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+}
+
+// deviceConfig 设备级配置：订阅的主题、负载格式和命令模板。
+// topics 和 command 为 text/template 模板，可用 {{.DeviceID}}、{{.Point}}、{{.Value}}（命令）和 {{json .Value}}
+type deviceConfig struct {
+	Topics       []string      `yaml:"topics"` // 主题过滤器，支持 + 和 # 通配符
+	Payload      payloadConfig `yaml:"payload"`
+	Command      commandConfig `yaml:"command"`
+	StaleTimeout int           `yaml:"stale_timeout"` // 超过该时间(秒)未更新的点位为 bad:stale，0 为不过期
+}
+
+// payloadConfig 负载解码：
+//   - json：点位地址为 JSONPath（$ 开头）或顶层字段名
+//   - csv：点位地址为列序号（从 0 开始）或 header 为 true 时的列名，取消息中的最后一行
+//   - binary：点位地址同 raw 协议（偏移、偏移:长度、字节.位、re:<正则>），按点位 Format 解码
+type payloadConfig struct {
+	Type      string `yaml:"type"`      // json(默认) | csv | binary
+	Separator string `yaml:"separator"` // csv 分隔符，默认逗号
+	Header    bool   `yaml:"header"`    // csv 第一行为列名
+	Timestamp string `yaml:"timestamp"` // 时间戳的取值地址（Unix 秒/毫秒或 RFC3339），未配置时为接收时间
+}
+
+// commandConfig 写入命令，点位配置的 command 可逐项覆盖。
+// payload 未配置时 binary 设备按点位 Format 编码写入值，其他设备为 {{json .Value}}
+type commandConfig struct {
+	Topic   string `yaml:"topic"`
+	Payload string `yaml:"payload"`
+	QoS     *int   `yaml:"qos"`
+	Retain  bool   `yaml:"retain"`
+}
+
+// decodeConfig 把 YAML 解析出的 map 转换为结构体
+func decodeConfig(config map[string]interface{}, out interface{}) error {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, out)
+}
+
+var templateFuncs = template.FuncMap{
+	"json": jsonString,
+}
+
+// templateData 模板变量
+type templateData struct {
+	DeviceID string
+	Point    string
+	Value    interface{}
+}
+
+func jsonString(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func renderTemplate(name, text string, data templateData) (string, error) {
+	t, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// validTopicFilter 检查主题过滤器：+ 占据整个层级，# 只能是最后一个层级
+func validTopicFilter(filter string) error {
+	if filter == "" {
+		return fmt.Errorf("empty topic filter")
+	}
+	levels := strings.Split(filter, "/")
+	for i, l := range levels {
+		if strings.Contains(l, "+") && l != "+" || strings.Contains(l, "#") && (l != "#" || i != len(levels)-1) {
+			return fmt.Errorf("invalid topic filter %q", filter)
+		}
+	}
+	return nil
+}
+
+// matchTopic 判断主题是否匹配过滤器，$ 开头的系统主题不匹配首层通配符
+func matchTopic(filter, topic string) bool {
+	if strings.HasPrefix(topic, "$") && (strings.HasPrefix(filter, "+") || strings.HasPrefix(filter, "#")) {
+		return false
+	}
+	f, t := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, l := range f {
+		if l == "#" {
+			return true
+		}
+		if i >= len(t) || l != "+" && l != t[i] {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
+
+func newTLSConfig(conf tlsConfig) (*tls.Config, error) {
+	tlsConf := &tls.Config{
+		InsecureSkipVerify: conf.InsecureSkipVerify,
+		ServerName:         conf.ServerName,
+	}
+	if conf.CAFile != "" {
+		pem, err := os.ReadFile(conf.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("mqtt_south: read ca_file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("mqtt_south: no certificate found in %s", conf.CAFile)
+		}
+		tlsConf.RootCAs = pool
+	}
+	if conf.CertFile != "" || conf.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("mqtt_south: load client certificate: %v", err)
+		}
+		tlsConf.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConf, nil
+}
@@ -0,0 +1,542 @@
+package mqttsouth
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sensor-edge/protocols"
+	"sensor-edge/utils"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// MQTTSouth 南向 MQTT 驱动：订阅设备主动上报的主题，按 JSONPath、CSV 列或二进制偏移从消息中取值并缓存最新值，
+// Read/ReadBatch 返回各点位最近一次收到的值，与轮询设备一致；写入按命令模板发布到设备的命令主题。
+// 同一 broker 的设备共享连接，主题、负载格式和命令模板由 ConfigureDevice 按设备配置
+type MQTTSouth struct {
+	conf     connConfig
+	defaults map[string]interface{} // Init 的配置，未调用 ConfigureDevice 的设备按此配置
+	timeout  time.Duration
+	client   mqtt.Client
+
+	mu      sync.RWMutex
+	devices map[string]*device
+
+	push  chan []update // 等待回调的点位更新，由 pushLoop 依次回调
+	pmu   sync.Mutex
+	stopc chan struct{} // 关闭时停止 pushLoop
+}
+
+// device 设备的订阅、点位和最新值
+type device struct {
+	id      string
+	conf    deviceConfig
+	topics  []string
+	configs []protocols.PointConfig
+	names   []string          // 排序后的点位名
+	points  map[string]*point // 点位名 -> 点位
+	byAddr  map[string]*point // 地址 -> 点位
+	stamp   *point            // 负载中的时间戳
+	last    map[string]sample // 点位名 -> 最新值
+	handler func(values []protocols.PointValue)
+}
+
+// sample 点位最新值，ts 为负载中的时间戳（未配置时同 received）
+type sample struct {
+	value    interface{}
+	ts       time.Time
+	received time.Time
+}
+
+// Init 连接参数：
+//
+//	broker: tcp://192.168.1.30:1883     # ssl:// 或 tls:// 时按 tls 建立加密连接
+//	client_id: edge-south-1
+//	username: edge
+//	password: "********"
+//	qos: 1
+//	keep_alive: 30                      # 心跳间隔(秒)
+//	timeout: 5000                       # 连接、订阅和发布的等待时间(毫秒)
+//	tls: {insecure_skip_verify: false, ca_file: "", cert_file: "", key_file: ""}
+//
+// 设备参数（见 ConfigureDevice）也可以写在这里，作为未单独配置的设备的默认值。
+// 连接在后台建立并自动重连，断开期间读取返回错误
+func (m *MQTTSouth) Init(config map[string]interface{}) error {
+	var conf connConfig
+	if err := decodeConfig(config, &conf); err != nil {
+		return fmt.Errorf("mqtt_south: invalid config: %v", err)
+	}
+	if conf.Broker == "" {
+		return fmt.Errorf("mqtt_south: broker is required")
+	}
+	if conf.QoS < 0 || conf.QoS > 2 {
+		return fmt.Errorf("mqtt_south: invalid qos %d", conf.QoS)
+	}
+	if conf.ClientID == "" {
+		conf.ClientID = fmt.Sprintf("sensor-edge-%d", time.Now().UnixNano())
+	}
+	if conf.KeepAlive <= 0 {
+		conf.KeepAlive = 30
+	}
+	m.timeout = 5 * time.Second
+	if conf.Timeout > 0 {
+		m.timeout = time.Duration(conf.Timeout) * time.Millisecond
+	}
+	opts := mqtt.NewClientOptions().
+		AddBroker(conf.Broker).
+		SetClientID(conf.ClientID).
+		SetUsername(conf.Username).
+		SetPassword(conf.Password).
+		SetKeepAlive(time.Duration(conf.KeepAlive) * time.Second).
+		SetConnectTimeout(m.timeout).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetDefaultPublishHandler(m.onMessage).
+		SetOnConnectHandler(m.onConnect).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			log.Printf("[MQTT-SOUTH] connection to %s lost: %v", conf.Broker, err)
+		})
+	scheme, _, _ := strings.Cut(conf.Broker, "://")
+	switch strings.ToLower(scheme) {
+	case "ssl", "tls", "mqtts", "wss":
+		tlsConf, err := newTLSConfig(conf.TLS)
+		if err != nil {
+			return err
+		}
+		opts.SetTLSConfig(tlsConf)
+	}
+	m.conf, m.defaults = conf, config
+	m.push = make(chan []update, pushQueue)
+	m.startPush()
+	m.client = mqtt.NewClient(opts)
+	m.client.Connect()
+	return nil
+}
+
+// ConfigureDevice 设备参数：
+//
+//	topics: ["site1/{{.DeviceID}}/telemetry", "site1/{{.DeviceID}}/+/state"]
+//	payload: {type: json, timestamp: $.ts}   # json | csv(separator/header) | binary
+//	command: {topic: "site1/{{.DeviceID}}/set/{{.Point}}", payload: "{{json .Value}}", qos: 1, retain: false}
+//	stale_timeout: 60                        # 超过该时间(秒)未更新的点位为 bad:stale
+//
+// 点位配置的 topic 把点位限定在匹配的主题上，command 覆盖设备的命令模板
+func (m *MQTTSouth) ConfigureDevice(deviceID string, config map[string]interface{}) error {
+	var conf deviceConfig
+	if err := decodeConfig(config, &conf); err != nil {
+		return fmt.Errorf("mqtt_south: invalid device config: %v", err)
+	}
+	if len(conf.Topics) == 0 {
+		return fmt.Errorf("mqtt_south: device %s: topics is required", deviceID)
+	}
+	switch conf.Payload.Type = strings.ToLower(conf.Payload.Type); conf.Payload.Type {
+	case "":
+		conf.Payload.Type = "json"
+	case "json", "csv", "binary":
+	default:
+		return fmt.Errorf("mqtt_south: device %s: unsupported payload type %q", deviceID, conf.Payload.Type)
+	}
+	if conf.Payload.Separator == "" {
+		conf.Payload.Separator = ","
+	}
+	if len([]rune(conf.Payload.Separator)) != 1 {
+		return fmt.Errorf("mqtt_south: device %s: separator must be a single character", deviceID)
+	}
+	if q := conf.Command.QoS; q != nil && (*q < 0 || *q > 2) {
+		return fmt.Errorf("mqtt_south: device %s: invalid command qos %d", deviceID, *q)
+	}
+	d := &device{id: deviceID, conf: conf, last: make(map[string]sample)}
+	for i, t := range conf.Topics {
+		topic, err := renderTemplate(fmt.Sprintf("topics[%d]", i), t, templateData{DeviceID: deviceID})
+		if err == nil {
+			err = validTopicFilter(topic)
+		}
+		if err != nil {
+			return fmt.Errorf("mqtt_south: device %s: %v", deviceID, err)
+		}
+		d.topics = append(d.topics, topic)
+	}
+	if conf.Payload.Timestamp != "" {
+		p, err := compilePoint(conf.Payload.Type, protocols.PointConfig{PointID: "timestamp", Address: conf.Payload.Timestamp}, deviceID)
+		if err != nil {
+			return fmt.Errorf("mqtt_south: device %s: timestamp: %v", deviceID, err)
+		}
+		d.stamp = p
+	}
+
+	m.mu.Lock()
+	if old, ok := m.devices[deviceID]; ok {
+		d.handler = old.handler
+		d.setPoints(old.configs)
+	}
+	if m.devices == nil {
+		m.devices = make(map[string]*device)
+	}
+	m.devices[deviceID] = d
+	m.mu.Unlock()
+
+	// 未连接时订阅在连接建立后统一进行
+	if m.client.IsConnectionOpen() {
+		m.subscribe(d.topics)
+	}
+	return nil
+}
+
+// SetPointConfigs 记录设备的点位配置并解析取值地址，地址无效的点位读取为 bad
+func (m *MQTTSouth) SetPointConfigs(deviceID string, points []protocols.PointConfig) {
+	if !m.ensureDevice(deviceID) {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.devices[deviceID].setPoints(points)
+}
+
+// SetValueHandler 注册收到消息时的回调（实现 protocols.PointSubscriber），values 为该消息更新的点位，PointID 为点位名
+func (m *MQTTSouth) SetValueHandler(deviceID string, handler func(values []protocols.PointValue)) {
+	if !m.ensureDevice(deviceID) {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.devices[deviceID].handler = handler
+}
+
+// ensureDevice 未调用 ConfigureDevice 的设备按 Init 的配置创建
+func (m *MQTTSouth) ensureDevice(deviceID string) bool {
+	m.mu.RLock()
+	_, ok := m.devices[deviceID]
+	m.mu.RUnlock()
+	if ok {
+		return true
+	}
+	if err := m.ConfigureDevice(deviceID, m.defaults); err != nil {
+		log.Printf("[MQTT-SOUTH] %v", err)
+		return false
+	}
+	return true
+}
+
+func (d *device) setPoints(points []protocols.PointConfig) {
+	d.configs = points
+	d.points = make(map[string]*point, len(points))
+	d.byAddr = make(map[string]*point, len(points))
+	d.names = nil
+	for _, pc := range points {
+		if pc.PointID == "" {
+			continue
+		}
+		p, err := compilePoint(d.conf.Payload.Type, pc, d.id)
+		if err != nil {
+			log.Printf("[MQTT-SOUTH] device %s point %s: %v", d.id, pc.PointID, err)
+			p = nil
+		}
+		if _, ok := d.points[pc.PointID]; !ok {
+			d.names = append(d.names, pc.PointID)
+		}
+		d.points[pc.PointID] = p
+		if p != nil {
+			d.byAddr[pc.Address] = p
+		}
+	}
+	sort.Strings(d.names)
+}
+
+// onConnect 连接（含自动重连）建立后重新订阅全部设备的主题
+func (m *MQTTSouth) onConnect(mqtt.Client) {
+	m.mu.RLock()
+	var topics []string
+	for _, d := range m.devices {
+		topics = append(topics, d.topics...)
+	}
+	m.mu.RUnlock()
+	if len(topics) > 0 {
+		m.subscribe(topics)
+	}
+}
+
+// subscribe 消息统一由默认回调分发，不注册按主题的回调，避免重叠的过滤器重复处理同一条消息
+func (m *MQTTSouth) subscribe(topics []string) {
+	filters := make(map[string]byte, len(topics))
+	for _, t := range topics {
+		filters[t] = byte(m.conf.QoS)
+	}
+	token := m.client.SubscribeMultiple(filters, nil)
+	if !token.WaitTimeout(m.timeout) {
+		log.Printf("[MQTT-SOUTH] subscribe %v timeout", topics)
+	} else if err := token.Error(); err != nil {
+		log.Printf("[MQTT-SOUTH] subscribe %v failed: %v", topics, err)
+	}
+}
+
+// onMessage 把消息分发给订阅了该主题的设备，更新最新值后把设备的回调交给 pushLoop
+func (m *MQTTSouth) onMessage(_ mqtt.Client, msg mqtt.Message) {
+	var updates []update
+	now := time.Now()
+	m.mu.Lock()
+	for _, d := range m.devices {
+		if !d.subscribes(msg.Topic()) {
+			continue
+		}
+		values, err := d.update(msg.Topic(), msg.Payload(), now)
+		if err != nil {
+			log.Printf("[MQTT-SOUTH] device %s: decode message of %s failed: %v", d.id, msg.Topic(), err)
+			continue
+		}
+		if len(values) > 0 && d.handler != nil {
+			updates = append(updates, update{d.handler, values})
+		}
+	}
+	m.mu.Unlock()
+	if len(updates) == 0 {
+		return
+	}
+	select {
+	case m.push <- updates:
+	default:
+		// 回调积压，丢弃本次推送；值已缓存，下一次采集照常返回
+		log.Printf("[MQTT-SOUTH] push queue full, drop %d updates from %s", len(updates), msg.Topic())
+	}
+}
+
+// pushQueue 等待回调的消息数
+const pushQueue = 64
+
+// update 一条消息中属于同一设备的点位值
+type update struct {
+	handler func([]protocols.PointValue)
+	values  []protocols.PointValue
+}
+
+// startPush 启动 pushLoop（已在运行时不重复启动）
+func (m *MQTTSouth) startPush() {
+	m.pmu.Lock()
+	defer m.pmu.Unlock()
+	if m.stopc == nil {
+		m.stopc = make(chan struct{})
+		go pushLoop(m.stopc, m.push)
+	}
+}
+
+// pushLoop 依次调用设备的回调，慢的北向上报不阻塞 paho 的消息分发（否则同一 broker 上所有设备的消息都会停滞）
+func pushLoop(stop <-chan struct{}, push <-chan []update) {
+	for {
+		select {
+		case <-stop:
+			return
+		case updates := <-push:
+			for _, u := range updates {
+				u.handler(u.values)
+			}
+		}
+	}
+}
+
+func (d *device) subscribes(topic string) bool {
+	for _, f := range d.topics {
+		if matchTopic(f, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// update 解码消息并更新其中包含的点位，消息不含的点位保持原值
+func (d *device) update(topic string, raw []byte, now time.Time) ([]protocols.PointValue, error) {
+	pl, err := decodePayload(d.conf.Payload, raw)
+	if err != nil {
+		return nil, err
+	}
+	ts := now
+	if d.stamp != nil {
+		if v, err := d.stamp.extract(pl); err == nil {
+			if t, err := parseTimestamp(v); err == nil {
+				ts = t
+			}
+		}
+	}
+	var values []protocols.PointValue
+	for _, name := range d.names {
+		p := d.points[name]
+		if p == nil || p.topic != "" && !matchTopic(p.topic, topic) {
+			continue
+		}
+		v, err := p.extract(pl)
+		if err != nil {
+			continue
+		}
+		d.last[name] = sample{value: v, ts: ts, received: now}
+		values = append(values, protocols.PointValue{PointID: name, Value: v, Quality: "good", Timestamp: ts.Unix()})
+	}
+	return values, nil
+}
+
+// Read 返回设备全部点位的最新值，PointID 为点位名
+func (m *MQTTSouth) Read(deviceID string) ([]protocols.PointValue, error) {
+	m.mu.RLock()
+	var names []string
+	if d := m.devices[deviceID]; d != nil {
+		names = append(names, d.names...)
+	}
+	m.mu.RUnlock()
+	return m.readPoints(deviceID, names)
+}
+
+// ReadBatch 返回点位的最新值，points 为点位地址或点位名，function 不使用。
+// 从未收到、超过 stale_timeout 未更新或地址无效的点位为 bad；与 broker 断开时全部为 bad 并返回错误
+func (m *MQTTSouth) ReadBatch(deviceID string, function string, points []string) ([]protocols.PointValue, error) {
+	if len(points) == 0 {
+		return nil, nil
+	}
+	return m.readPoints(deviceID, points)
+}
+
+func (m *MQTTSouth) readPoints(deviceID string, keys []string) ([]protocols.PointValue, error) {
+	values := make([]protocols.PointValue, len(keys))
+	for i, key := range keys {
+		values[i] = badValue(key, "bad")
+	}
+	if !m.client.IsConnectionOpen() {
+		return values, fmt.Errorf("mqtt_south: not connected to %s", m.conf.Broker)
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	d := m.devices[deviceID]
+	if d == nil {
+		return values, fmt.Errorf("mqtt_south: device %s not configured", deviceID)
+	}
+	stale := time.Duration(d.conf.StaleTimeout) * time.Second
+	for i, key := range keys {
+		p := d.points[key]
+		if p == nil {
+			p = d.byAddr[key]
+		}
+		if p == nil {
+			continue
+		}
+		s, ok := d.last[p.conf.PointID]
+		switch {
+		case !ok:
+		case stale > 0 && time.Since(s.received) > stale:
+			values[i] = badValue(key, "bad:stale")
+		default:
+			values[i] = protocols.PointValue{PointID: key, Value: s.value, Quality: "good", Timestamp: s.ts.Unix()}
+		}
+	}
+	return values, nil
+}
+
+// Write 按命令模板发布写入值，point 为点位地址或点位名
+func (m *MQTTSouth) Write(point string, value interface{}) error {
+	d, p, ok := m.lookupPoint(point)
+	if !ok {
+		return fmt.Errorf("mqtt_south: point %s not configured", point)
+	}
+	cmd := d.conf.Command
+	if raw, ok := p.conf.Options["command"].(map[string]interface{}); ok {
+		// 点位的 qos 不能写回设备配置
+		if cmd.QoS != nil {
+			q := *cmd.QoS
+			cmd.QoS = &q
+		}
+		if err := decodeConfig(raw, &cmd); err != nil {
+			return fmt.Errorf("mqtt_south: point %s: invalid command: %v", point, err)
+		}
+		if q := cmd.QoS; q != nil && (*q < 0 || *q > 2) {
+			return fmt.Errorf("mqtt_south: point %s: invalid command qos %d", point, *q)
+		}
+	}
+	if cmd.Topic == "" {
+		return fmt.Errorf("mqtt_south: point %s has no command topic", point)
+	}
+	data := templateData{DeviceID: d.id, Point: p.conf.PointID, Value: value}
+	topic, err := renderTemplate(p.conf.PointID+".command.topic", cmd.Topic, data)
+	if err != nil {
+		return fmt.Errorf("mqtt_south: point %s: %v", point, err)
+	}
+	if topic == "" || strings.ContainsAny(topic, "+#") {
+		return fmt.Errorf("mqtt_south: point %s: invalid command topic %q", point, topic)
+	}
+	var payload []byte
+	switch {
+	case cmd.Payload != "":
+		s, err := renderTemplate(p.conf.PointID+".command.payload", cmd.Payload, data)
+		if err != nil {
+			return fmt.Errorf("mqtt_south: point %s: %v", point, err)
+		}
+		payload = []byte(s)
+	case d.conf.Payload.Type == "binary" && p.conf.Format != "":
+		if payload, err = utils.EncodeFormat(p.conf.Format, value); err != nil {
+			return fmt.Errorf("mqtt_south: point %s: %v", point, err)
+		}
+	default:
+		s, err := jsonString(value)
+		if err != nil {
+			return fmt.Errorf("mqtt_south: point %s: %v", point, err)
+		}
+		payload = []byte(s)
+	}
+	qos := m.conf.QoS
+	if cmd.QoS != nil {
+		qos = *cmd.QoS
+	}
+	token := m.client.Publish(topic, byte(qos), cmd.Retain, payload)
+	if !token.WaitTimeout(m.timeout) {
+		return fmt.Errorf("mqtt_south: publish %s timeout", topic)
+	}
+	return token.Error()
+}
+
+func (m *MQTTSouth) lookupPoint(point string) (*device, *point, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, d := range m.devices {
+		if p := d.points[point]; p != nil {
+			return d, p, true
+		}
+		if p := d.byAddr[point]; p != nil {
+			return d, p, true
+		}
+	}
+	return nil, nil, false
+}
+
+func (m *MQTTSouth) Close() error {
+	if m.client != nil {
+		m.client.Disconnect(250)
+	}
+	m.pmu.Lock()
+	if m.stopc != nil {
+		close(m.stopc)
+		m.stopc = nil
+	}
+	m.pmu.Unlock()
+	return nil
+}
+
+// Reconnect 断开后重新连接，订阅在连接建立后恢复
+func (m *MQTTSouth) Reconnect() error {
+	if m.client == nil {
+		return fmt.Errorf("mqtt_south: not initialized")
+	}
+	m.client.Disconnect(250)
+	m.startPush()
+	m.client.Connect()
+	return nil
+}
+
+func badValue(id, quality string) protocols.PointValue {
+	return protocols.PointValue{PointID: id, Value: nil, Quality: quality, Timestamp: time.Now().Unix()}
+}
+
+func NewMQTTSouth() protocols.Protocol {
+	return &MQTTSouth{}
+}
+
+func init() {
+	protocols.Register("mqtt_south", NewMQTTSouth)
+}
@@ -0,0 +1,350 @@
+package mqttsouth
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"sensor-edge/protocols"
+)
+
+func TestMatchTopic(t *testing.T) {
+	cases := []struct {
+		filter, topic string
+		want          bool
+	}{
+		{"plant/+/temp", "plant/a/temp", true},
+		{"plant/+/temp", "plant/a/b/temp", false},
+		{"plant/#", "plant", true},
+		{"plant/#", "plant/a/b", true},
+		{"plant/a", "plant/a/b", false},
+		{"+/a", "/a", true},
+		{"#", "$SYS/uptime", false},
+	}
+	for _, c := range cases {
+		if got := matchTopic(c.filter, c.topic); got != c.want {
+			t.Errorf("matchTopic(%q, %q) = %v", c.filter, c.topic, got)
+		}
+	}
+	for _, f := range []string{"a/b+", "a/#/b", ""} {
+		if validTopicFilter(f) == nil {
+			t.Errorf("%q: expect invalid filter", f)
+		}
+	}
+}
+
+// broker 最小的 MQTT 3.1.1 broker：支持 CONNECT/SUBSCRIBE/PUBLISH(QoS 0/1)/PINGREQ，
+// 统一以 QoS 0 转发，记录客户端发布的消息
+type broker struct {
+	ln net.Listener
+
+	mu        sync.Mutex
+	subs      map[net.Conn][]string
+	published []string // 主题 + " " + 负载
+}
+
+func newBroker(t *testing.T) *broker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := &broker{ln: ln, subs: make(map[net.Conn][]string)}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+func (b *broker) serve(conn net.Conn) {
+	defer func() {
+		b.mu.Lock()
+		delete(b.subs, conn)
+		b.mu.Unlock()
+		conn.Close()
+	}()
+	r := bufio.NewReader(conn)
+	for {
+		header, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		size, mul := 0, 1
+		for {
+			c, err := r.ReadByte()
+			if err != nil {
+				return
+			}
+			size += int(c&0x7f) * mul
+			mul *= 128
+			if c&0x80 == 0 {
+				break
+			}
+		}
+		body := make([]byte, size)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return
+		}
+		switch header >> 4 {
+		case 1: // CONNECT
+			conn.Write([]byte{0x20, 0x02, 0x00, 0x00})
+		case 3: // PUBLISH
+			n := int(binary.BigEndian.Uint16(body))
+			topic, rest := string(body[2:2+n]), body[2+n:]
+			if qos := header >> 1 & 3; qos > 0 {
+				conn.Write([]byte{0x40, 0x02, rest[0], rest[1]})
+				rest = rest[2:]
+			}
+			b.mu.Lock()
+			b.published = append(b.published, topic+" "+string(rest))
+			b.mu.Unlock()
+			b.publish(topic, rest)
+		case 8: // SUBSCRIBE
+			granted := []byte{}
+			var filters []string
+			for rest := body[2:]; len(rest) > 2; {
+				n := int(binary.BigEndian.Uint16(rest))
+				filters = append(filters, string(rest[2:2+n]))
+				granted = append(granted, min(rest[2+n], 1))
+				rest = rest[3+n:]
+			}
+			b.mu.Lock()
+			b.subs[conn] = append(b.subs[conn], filters...)
+			b.mu.Unlock()
+			conn.Write(append([]byte{0x90, byte(2 + len(granted)), body[0], body[1]}, granted...))
+		case 12: // PINGREQ
+			conn.Write([]byte{0xd0, 0x00})
+		case 14: // DISCONNECT
+			return
+		}
+	}
+}
+
+// publish 按订阅转发，每个连接只收到一次
+func (b *broker) publish(topic string, payload []byte) {
+	body := append(binary.BigEndian.AppendUint16(nil, uint16(len(topic))), topic...)
+	body = append(body, payload...)
+	packet := []byte{0x30}
+	for n := len(body); ; {
+		c := byte(n % 128)
+		if n /= 128; n > 0 {
+			c |= 0x80
+		}
+		packet = append(packet, c)
+		if n == 0 {
+			break
+		}
+	}
+	packet = append(packet, body...)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for conn, filters := range b.subs {
+		for _, f := range filters {
+			if matchTopic(f, topic) {
+				conn.Write(packet)
+				break
+			}
+		}
+	}
+}
+
+func (b *broker) waitSubscriptions(t *testing.T, n int) {
+	t.Helper()
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		b.mu.Lock()
+		count := 0
+		for _, filters := range b.subs {
+			count += len(filters)
+		}
+		b.mu.Unlock()
+		if count >= n {
+			return
+		}
+	}
+	t.Fatalf("expect %d subscriptions", n)
+}
+
+// waitPublished 等待客户端发布的消息，QoS 0 的发布在写出后即返回
+func (b *broker) waitPublished(t *testing.T, want string) {
+	t.Helper()
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		b.mu.Lock()
+		for _, p := range b.published {
+			if p == want {
+				b.mu.Unlock()
+				return
+			}
+		}
+		b.mu.Unlock()
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	t.Errorf("%q not published, got %q", want, b.published)
+}
+
+func TestMQTTSouth(t *testing.T) {
+	b := newBroker(t)
+	m := &MQTTSouth{}
+	if err := m.Init(map[string]interface{}{"broker": "tcp://" + b.ln.Addr().String(), "qos": 1, "timeout": 2000}); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	devices := map[string]map[string]interface{}{
+		"boiler": {
+			"topics":  []interface{}{"plant/{{.DeviceID}}/+"},
+			"payload": map[string]interface{}{"timestamp": "$.ts"},
+			"command": map[string]interface{}{"topic": "plant/{{.DeviceID}}/set/{{.Point}}", "payload": `{"value":{{json .Value}}}`},
+		},
+		"meter": {"topics": []interface{}{"plant/meter/csv"}, "payload": map[string]interface{}{"type": "csv", "header": true}, "stale_timeout": 60},
+		"scale": {"topics": []interface{}{"plant/scale/raw"}, "payload": map[string]interface{}{"type": "binary"}, "command": map[string]interface{}{"topic": "plant/scale/cmd"}},
+	}
+	for id, conf := range devices {
+		if err := m.ConfigureDevice(id, conf); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.ConfigureDevice("bad", map[string]interface{}{"topics": []interface{}{"a/#/b"}}); err == nil {
+		t.Error("expect invalid topic error")
+	}
+	m.SetPointConfigs("boiler", []protocols.PointConfig{
+		{PointID: "temp", Address: "$.data.temp"},
+		{PointID: "status", Address: "status", Options: map[string]interface{}{
+			"command": map[string]interface{}{"topic": "plant/boiler/status/set", "payload": "{{.Value}}", "qos": 0},
+		}},
+		{PointID: "door", Address: "$.open", Options: map[string]interface{}{"topic": "plant/{{.DeviceID}}/door"}},
+	})
+	m.SetPointConfigs("meter", []protocols.PointConfig{{PointID: "temp", Address: "temp"}, {PointID: "hum", Address: "1"}})
+	m.SetPointConfigs("scale", []protocols.PointConfig{
+		{PointID: "weight", Address: "0", Format: "Float AB CD"},
+		{PointID: "stable", Address: "4.0"},
+		{PointID: "unit", Address: "5:2", Format: "STRING"},
+	})
+	pushed := make(chan []protocols.PointValue, 4)
+	m.SetValueHandler("boiler", func(values []protocols.PointValue) { pushed <- values })
+	b.waitSubscriptions(t, 3)
+
+	expectPush := func(want string) {
+		t.Helper()
+		select {
+		case values := <-pushed:
+			got := ""
+			for _, v := range values {
+				got += fmt.Sprintf("%s=%v@%d ", v.PointID, v.Value, v.Timestamp)
+			}
+			if got != want {
+				t.Errorf("pushed %q, want %q", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no push for %s", want)
+		}
+	}
+	expectRead := func(deviceID string, points []string, want string) {
+		t.Helper()
+		values, err := m.ReadBatch(deviceID, "", points)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		for _, v := range values {
+			got += fmt.Sprintf("%s=%v %s; ", v.PointID, v.Value, v.Quality)
+		}
+		if got != want {
+			t.Errorf("%s read %q, want %q", deviceID, got, want)
+		}
+	}
+
+	// 只推送消息中包含的点位，时间戳取自负载
+	b.publish("plant/boiler/telemetry", []byte(`{"ts": 1700000000, "data": {"temp": 21.5}, "status": "ok"}`))
+	expectPush("status=ok@1700000000 temp=21.5@1700000000 ")
+	b.publish("plant/boiler/other", []byte(`{"open": true}`)) // door 限定在 door 主题
+	b.publish("plant/boiler/door", []byte(`{"open": true}`))
+	expectPush(fmt.Sprintf("door=true@%d ", time.Now().Unix()))
+	values, err := m.Read("boiler")
+	if err != nil || fmt.Sprint(values[0].PointID, values[0].Value, values[1].PointID, values[2].Value) != "doortruestatus21.5" {
+		t.Errorf("read boiler = %+v, %v", values, err)
+	}
+
+	b.publish("plant/meter/csv", []byte("temp,hum\n20.5,40\n21, 41\n"))
+	b.publish("plant/scale/raw", []byte{0x41, 0xbc, 0x00, 0x00, 0x01, 'k', 'g'})
+	time.Sleep(100 * time.Millisecond)
+	expectRead("meter", []string{"temp", "1", "none"}, "temp=21 good; 1=41 good; none=<nil> bad; ")
+	expectRead("scale", []string{"weight", "stable", "unit"}, "weight=23.5 good; stable=true good; unit=kg good; ")
+
+	// 超过 stale_timeout 未更新
+	m.mu.Lock()
+	s := m.devices["meter"].last["temp"]
+	s.received = s.received.Add(-time.Minute)
+	m.devices["meter"].last["temp"] = s
+	m.mu.Unlock()
+	expectRead("meter", []string{"temp", "hum"}, "temp=<nil> bad:stale; hum=41 good; ")
+
+	// 写入按命令模板发布
+	writes := []struct {
+		point string
+		value interface{}
+		want  string
+	}{
+		{"$.data.temp", 60, `plant/boiler/set/temp {"value":60}`},
+		{"status", "stop", "plant/boiler/status/set stop"},
+		{"weight", 12.5, "plant/scale/cmd A\x48\x00\x00"},
+	}
+	for _, w := range writes {
+		if err := m.Write(w.point, w.value); err != nil {
+			t.Fatal(err)
+		}
+		b.waitPublished(t, w.want)
+	}
+	if err := m.Write("hum", 1); err == nil {
+		t.Error("expect no command topic error")
+	}
+}
+
+// TestSlowHandler 回调阻塞时其他设备的消息照常更新
+func TestSlowHandler(t *testing.T) {
+	b := newBroker(t)
+	m := &MQTTSouth{}
+	if err := m.Init(map[string]interface{}{"broker": "tcp://" + b.ln.Addr().String(), "timeout": 2000}); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	for _, id := range []string{"boiler", "meter"} {
+		if err := m.ConfigureDevice(id, map[string]interface{}{"topics": []interface{}{"plant/{{.DeviceID}}"}}); err != nil {
+			t.Fatal(err)
+		}
+		m.SetPointConfigs(id, []protocols.PointConfig{{PointID: "temp", Address: "$.temp"}})
+	}
+	called, release := make(chan struct{}, 1), make(chan struct{})
+	defer close(release)
+	m.SetValueHandler("boiler", func([]protocols.PointValue) {
+		called <- struct{}{}
+		<-release
+	})
+	b.waitSubscriptions(t, 2)
+	b.publish("plant/boiler", []byte(`{"temp": 1}`))
+	select {
+	case <-called:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no push")
+	}
+	b.publish("plant/meter", []byte(`{"temp": 2}`))
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		values, _ := m.ReadBatch("meter", "", []string{"temp"})
+		if values[0].Quality == "good" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("meter not updated while boiler handler blocked")
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package mqttsouth
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"sensor-edge/protocols"
+	"sensor-edge/utils"
+)
+
+// payload 一条消息按设备负载类型解码后的内容
+type payload struct {
+	typ    string
+	raw    []byte
+	json   interface{}
+	header map[string]int // csv 列名 -> 列序号
+	record []string
+}
+
+func decodePayload(conf payloadConfig, raw []byte) (*payload, error) {
+	p := &payload{typ: conf.Type, raw: raw}
+	switch conf.Type {
+	case "json":
+		if err := json.Unmarshal(raw, &p.json); err != nil {
+			return nil, err
+		}
+	case "csv":
+		r := csv.NewReader(bytes.NewReader(raw))
+		r.Comma = []rune(conf.Separator)[0]
+		r.FieldsPerRecord = -1
+		r.TrimLeadingSpace = true
+		records, err := r.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		if conf.Header {
+			if len(records) < 2 {
+				return nil, fmt.Errorf("csv payload has no data row")
+			}
+			p.header = make(map[string]int, len(records[0]))
+			for i, name := range records[0] {
+				p.header[strings.TrimSpace(name)] = i
+			}
+			records = records[1:]
+		}
+		if len(records) == 0 {
+			return nil, fmt.Errorf("empty csv payload")
+		}
+		p.record = records[len(records)-1]
+	}
+	return p, nil
+}
+
+// point 解析好取值地址的点位
+type point struct {
+	conf   protocols.PointConfig
+	topic  string          // 点位的主题过滤器，为空时设备订阅的全部主题
+	path   *utils.JSONPath // json：$ 开头的地址
+	column int             // csv：列序号，-1 为按列名
+	raw    utils.RawPoint  // binary
+}
+
+func compilePoint(typ string, pc protocols.PointConfig, deviceID string) (*point, error) {
+	p := &point{conf: pc, column: -1}
+	if topic, ok := pc.Options["topic"].(string); ok && topic != "" {
+		s, err := renderTemplate(pc.PointID+".topic", topic, templateData{DeviceID: deviceID, Point: pc.PointID})
+		if err != nil {
+			return nil, err
+		}
+		if err := validTopicFilter(s); err != nil {
+			return nil, err
+		}
+		p.topic = s
+	}
+	if pc.Address == "" {
+		return nil, fmt.Errorf("address is required")
+	}
+	var err error
+	switch typ {
+	case "json":
+		if strings.HasPrefix(pc.Address, "$") {
+			p.path, err = utils.CompileJSONPath(pc.Address)
+		}
+	case "csv":
+		if n, e := strconv.Atoi(pc.Address); e == nil {
+			if n < 0 {
+				return nil, fmt.Errorf("invalid column %d", n)
+			}
+			p.column = n
+		}
+	case "binary":
+		p.raw, err = utils.ParseRawPoint(pc.Address, pc.Format, pc.Options)
+	}
+	return p, err
+}
+
+// extract 从消息中取值，消息不含该点位时返回错误
+func (p *point) extract(pl *payload) (interface{}, error) {
+	switch pl.typ {
+	case "json":
+		if p.path == nil {
+			m, ok := pl.json.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("payload is not an object")
+			}
+			v, ok := m[p.conf.Address]
+			if !ok {
+				return nil, fmt.Errorf("no field %s", p.conf.Address)
+			}
+			return v, nil
+		}
+		matches := p.path.Eval(pl.json)
+		switch len(matches) {
+		case 0:
+			return nil, fmt.Errorf("no match for %s", p.conf.Address)
+		case 1:
+			return matches[0], nil
+		}
+		return matches, nil
+	case "csv":
+		i := p.column
+		if i < 0 {
+			var ok bool
+			if i, ok = pl.header[p.conf.Address]; !ok {
+				return nil, fmt.Errorf("no column %s", p.conf.Address)
+			}
+		}
+		if i >= len(pl.record) {
+			return nil, fmt.Errorf("column %d beyond record length %d", i, len(pl.record))
+		}
+		s := strings.TrimSpace(pl.record[i])
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, nil
+		}
+		return s, nil
+	}
+	return p.raw.Extract(pl.raw)
+}
+
+// parseTimestamp 负载中的时间戳：大于 1e12 的数值按毫秒，其余按秒；字符串按 RFC3339 或数值
+func parseTimestamp(v interface{}) (time.Time, error) {
+	if s, ok := v.(string); ok {
+		if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return t, nil
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid timestamp %q", s)
+		}
+		v = f
+	}
+	f, ok := utils.ToFloat64(v)
+	if !ok || f <= 0 {
+		return time.Time{}, fmt.Errorf("invalid timestamp %v", v)
+	}
+	if f > 1e12 {
+		return time.UnixMilli(int64(f)), nil
+	}
+	return time.Unix(int64(f), int64((f-float64(int64(f)))*1e9)), nil
+}
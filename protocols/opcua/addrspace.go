@@ -0,0 +1,379 @@
+package opcua
+
+import (
+	"strings"
+	"time"
+)
+
+// 标准节点、类型和引用类型的 NodeId（ns=0）
+const (
+	idReferences              = 31
+	idNonHierarchical         = 32
+	idHierarchicalReferences  = 33
+	idHasChild                = 34
+	idOrganizes               = 35
+	idHasTypeDefinition       = 40
+	idAggregates              = 44
+	idHasSubtype              = 45
+	idHasProperty             = 46
+	idHasComponent            = 47
+	idBaseObjectType          = 58
+	idFolderType              = 61
+	idBaseDataVariableType    = 63
+	idPropertyType            = 68
+	idRootFolder              = 84
+	idObjectsFolder           = 85
+	idTypesFolder             = 86
+	idViewsFolder             = 87
+	idServerStatusDataType    = 862
+	idServerStatusEncoding    = 864
+	idServerState             = 852
+	idEUInformation           = 887
+	idEUInformationEncoding   = 889
+	idServerType              = 2004
+	idServerStatusType        = 2138
+	idServer                  = 2253
+	idServerArray             = 2254
+	idNamespaceArray          = 2255
+	idServerStatus            = 2256
+	idServerStatusStartTime   = 2257
+	idServerStatusCurrentTime = 2258
+	idServerStatusState       = 2259
+)
+
+// referenceSupertypes 引用类型的父类型，用于 IncludeSubtypes 的匹配
+var referenceSupertypes = map[uint32]uint32{
+	idNonHierarchical:        idReferences,
+	idHierarchicalReferences: idReferences,
+	idHasChild:               idHierarchicalReferences,
+	idOrganizes:              idHierarchicalReferences,
+	idAggregates:             idHasChild,
+	idHasSubtype:             idHasChild,
+	idHasProperty:            idAggregates,
+	idHasComponent:           idAggregates,
+	idHasTypeDefinition:      idNonHierarchical,
+}
+
+// 变量的访问级别
+const (
+	accessRead  = 0x01
+	accessWrite = 0x02
+)
+
+// unitsNamespace EUInformation 的单位命名空间（UNECE）
+const unitsNamespace = "http://www.opcfoundation.org/UA/units/un/cefact"
+
+// nodeRef 节点的一条引用
+type nodeRef struct {
+	typeID  uint32
+	forward bool
+	target  *addrNode
+}
+
+// addrNode 地址空间中的节点。变量的值由 value 保存，或由 dynamic 在读取时计算
+type addrNode struct {
+	id          NodeID
+	class       uint32
+	browseName  QualifiedName
+	displayName LocalizedText
+	description LocalizedText
+	typeDef     *addrNode
+	refs        []nodeRef
+
+	dataType NodeID
+	builtin  byte // 网关变量的内置类型，写入和刷新时按此类型转换
+	rank     int32
+	access   byte
+	value    DataValue
+	dynamic  func() DataValue
+
+	folder, name string // 网关变量所属的文件夹和变量名，写入时转发
+}
+
+// addressSpace 按 NodeId 文本索引的节点
+type addressSpace map[string]*addrNode
+
+func (as addressSpace) node(id NodeID) *addrNode {
+	return as[id.String()]
+}
+
+// add 添加节点；parent 不为空时建立 parent 到节点的 refType 引用及其反向引用
+func (as addressSpace) add(n *addrNode, parent *addrNode, refType uint32) *addrNode {
+	if n.displayName.Text == "" {
+		n.displayName = LocalizedText{Text: n.browseName.Name}
+	}
+	as[n.id.String()] = n
+	if parent != nil {
+		parent.refs = append(parent.refs, nodeRef{typeID: refType, forward: true, target: n})
+		n.refs = append(n.refs, nodeRef{typeID: refType, forward: false, target: parent})
+	}
+	if n.typeDef != nil {
+		n.refs = append(n.refs, nodeRef{typeID: idHasTypeDefinition, forward: true, target: n.typeDef})
+	}
+	return n
+}
+
+// standardNode 命名空间 0 的节点
+func (as addressSpace) standardNode(id uint32, class uint32, name string, typeDef *addrNode, parent *addrNode, refType uint32) *addrNode {
+	return as.add(&addrNode{
+		id:         NewNumericNodeID(0, id),
+		class:      class,
+		browseName: QualifiedName{Name: name},
+		typeDef:    typeDef,
+	}, parent, refType)
+}
+
+// newAddressSpace 建立标准节点：Root、Objects/Types/Views 文件夹和 Server 对象（命名空间表、服务器状态）
+func newAddressSpace(namespaces []string, serverURI string, started time.Time, status func() ExtensionObject) addressSpace {
+	as := make(addressSpace)
+	for id, name := range map[uint32]string{
+		idReferences:             "References",
+		idNonHierarchical:        "NonHierarchicalReferences",
+		idHierarchicalReferences: "HierarchicalReferences",
+		idHasChild:               "HasChild",
+		idOrganizes:              "Organizes",
+		idHasTypeDefinition:      "HasTypeDefinition",
+		idAggregates:             "Aggregates",
+		idHasSubtype:             "HasSubtype",
+		idHasProperty:            "HasProperty",
+		idHasComponent:           "HasComponent",
+	} {
+		as.standardNode(id, nodeClassReferenceType, name, nil, nil, 0)
+	}
+	for id, name := range map[uint32]string{
+		uint32(typeBoolean): "Boolean", uint32(typeSByte): "SByte", uint32(typeByte): "Byte",
+		uint32(typeInt16): "Int16", uint32(typeUInt16): "UInt16", uint32(typeInt32): "Int32",
+		uint32(typeUInt32): "UInt32", uint32(typeInt64): "Int64", uint32(typeUInt64): "UInt64",
+		uint32(typeFloat): "Float", uint32(typeDouble): "Double", uint32(typeString): "String",
+		uint32(typeDateTime): "DateTime", idServerStatusDataType: "ServerStatusDataType",
+		idServerState: "ServerState", idEUInformation: "EUInformation",
+	} {
+		as.standardNode(id, nodeClassDataType, name, nil, nil, 0)
+	}
+	baseObject := as.standardNode(idBaseObjectType, nodeClassObjectType, "BaseObjectType", nil, nil, 0)
+	folderType := as.standardNode(idFolderType, nodeClassObjectType, "FolderType", nil, baseObject, idHasSubtype)
+	serverType := as.standardNode(idServerType, nodeClassObjectType, "ServerType", nil, baseObject, idHasSubtype)
+	baseVariable := as.standardNode(idBaseDataVariableType, nodeClassVariableType, "BaseDataVariableType", nil, nil, 0)
+	as.standardNode(idPropertyType, nodeClassVariableType, "PropertyType", nil, nil, 0)
+	statusType := as.standardNode(idServerStatusType, nodeClassVariableType, "ServerStatusType", nil, baseVariable, idHasSubtype)
+
+	root := as.standardNode(idRootFolder, nodeClassObject, "Root", folderType, nil, 0)
+	objects := as.standardNode(idObjectsFolder, nodeClassObject, "Objects", folderType, root, idOrganizes)
+	as.standardNode(idTypesFolder, nodeClassObject, "Types", folderType, root, idOrganizes)
+	as.standardNode(idViewsFolder, nodeClassObject, "Views", folderType, root, idOrganizes)
+
+	server := as.standardNode(idServer, nodeClassObject, "Server", serverType, objects, idOrganizes)
+	property := as.node(NewNumericNodeID(0, idPropertyType))
+	as.variable(as.standardNode(idServerArray, nodeClassVariable, "ServerArray", property, server, idHasProperty),
+		typeString, 1, DataValue{Value: []string{serverURI}})
+	as.variable(as.standardNode(idNamespaceArray, nodeClassVariable, "NamespaceArray", property, server, idHasProperty),
+		typeString, 1, DataValue{Value: namespaces})
+	st := as.variable(as.standardNode(idServerStatus, nodeClassVariable, "ServerStatus", statusType, server, idHasComponent),
+		0, -1, DataValue{})
+	st.dataType = NewNumericNodeID(0, idServerStatusDataType)
+	st.dynamic = func() DataValue { return DataValue{Value: status(), SourceTimestamp: time.Now()} }
+	as.variable(as.standardNode(idServerStatusStartTime, nodeClassVariable, "StartTime", baseVariable, st, idHasComponent),
+		typeDateTime, -1, DataValue{Value: started})
+	as.variable(as.standardNode(idServerStatusCurrentTime, nodeClassVariable, "CurrentTime", baseVariable, st, idHasComponent),
+		typeDateTime, -1, DataValue{}).dynamic = func() DataValue { return DataValue{Value: time.Now(), SourceTimestamp: time.Now()} }
+	state := as.variable(as.standardNode(idServerStatusState, nodeClassVariable, "State", baseVariable, st, idHasComponent),
+		0, -1, DataValue{Value: int32(0)}) // Running
+	state.dataType = NewNumericNodeID(0, idServerState)
+	return as
+}
+
+// variable 设置只读变量的数据类型和值，rank 为 -1（标量）或 1（一维数组）
+func (as addressSpace) variable(n *addrNode, t byte, rank int32, v DataValue) *addrNode {
+	n.dataType, n.builtin, n.rank, n.access, n.value = NewNumericNodeID(0, uint32(t)), t, rank, accessRead, v
+	return n
+}
+
+// isSubtype t 是否为引用类型 base 或其子类型
+func isSubtype(t, base uint32) bool {
+	for ; t != 0; t = referenceSupertypes[t] {
+		if t == base {
+			return true
+		}
+	}
+	return false
+}
+
+// matchReference 引用是否满足浏览条件，refType 为空表示所有引用
+func matchReference(ref nodeRef, refType NodeID, includeSubtypes bool) bool {
+	if refType.IsNull() {
+		return true
+	}
+	if refType.Namespace != 0 || refType.Type != idNumeric {
+		return false
+	}
+	if includeSubtypes {
+		return isSubtype(ref.typeID, refType.Numeric)
+	}
+	return ref.typeID == refType.Numeric
+}
+
+// browse 按浏览描述筛选节点的引用
+func (as addressSpace) browse(b browseDescription) ([]referenceDescription, StatusCode) {
+	n := as.node(b.NodeID)
+	if n == nil {
+		return nil, StatusBadNodeIDUnknown
+	}
+	if b.BrowseDirection > browseBoth {
+		return nil, StatusBadBrowseDirectionInvalid
+	}
+	if !b.ReferenceTypeID.IsNull() {
+		if t := as.node(b.ReferenceTypeID); t == nil || t.class != nodeClassReferenceType {
+			return nil, StatusBadReferenceTypeIDInvalid
+		}
+	}
+	var refs []referenceDescription
+	for _, ref := range n.refs {
+		if b.BrowseDirection == browseForward && !ref.forward || b.BrowseDirection == browseInverse && ref.forward {
+			continue
+		}
+		if !matchReference(ref, b.ReferenceTypeID, b.IncludeSubtypes) {
+			continue
+		}
+		if b.NodeClassMask != 0 && b.NodeClassMask&ref.target.class == 0 {
+			continue
+		}
+		refs = append(refs, ref.describe(b.ResultMask))
+	}
+	return refs, StatusGood
+}
+
+// describe 按 ResultMask 填写引用描述
+func (ref nodeRef) describe(mask uint32) referenceDescription {
+	t := ref.target
+	rd := referenceDescription{NodeID: t.id}
+	if mask&resultReferenceType != 0 {
+		rd.ReferenceTypeID = NewNumericNodeID(0, ref.typeID)
+	}
+	if mask&resultIsForward != 0 {
+		rd.IsForward = ref.forward
+	}
+	if mask&resultNodeClass != 0 {
+		rd.NodeClass = t.class
+	}
+	if mask&resultBrowseName != 0 {
+		rd.BrowseName = t.browseName
+	}
+	if mask&resultDisplayName != 0 {
+		rd.DisplayName = t.displayName
+	}
+	if mask&resultTypeDefinition != 0 && t.typeDef != nil {
+		rd.TypeDefinition = t.typeDef.id
+	}
+	return rd
+}
+
+// translate 从起始节点按相对路径查找目标节点
+func (as addressSpace) translate(p browsePath) browsePathResult {
+	start := as.node(p.StartingNode)
+	if start == nil {
+		return browsePathResult{StatusCode: StatusBadNodeIDUnknown}
+	}
+	if len(p.RelativePath) == 0 {
+		return browsePathResult{StatusCode: StatusBadNothingToDo}
+	}
+	current := []*addrNode{start}
+	for _, el := range p.RelativePath {
+		if el.TargetName.Name == "" {
+			return browsePathResult{StatusCode: StatusBadBrowseNameInvalid}
+		}
+		var next []*addrNode
+		for _, n := range current {
+			for _, ref := range n.refs {
+				if ref.forward == el.IsInverse || !matchReference(ref, el.ReferenceTypeID, el.IncludeSubtypes) {
+					continue
+				}
+				if ref.target.browseName == el.TargetName {
+					next = append(next, ref.target)
+				}
+			}
+		}
+		if len(next) == 0 {
+			return browsePathResult{StatusCode: StatusBadNoMatch}
+		}
+		current = next
+	}
+	r := browsePathResult{}
+	for _, n := range current {
+		r.Targets = append(r.Targets, browsePathTarget{TargetID: n.id, RemainingPathIndex: 0xFFFFFFFF})
+	}
+	return r
+}
+
+// attribute 读取节点属性
+func (n *addrNode) attribute(attr uint32) DataValue {
+	switch attr {
+	case attrNodeID:
+		return DataValue{Value: n.id}
+	case attrNodeClass:
+		return DataValue{Value: int32(n.class)}
+	case attrBrowseName:
+		return DataValue{Value: n.browseName}
+	case attrDisplayName:
+		return DataValue{Value: n.displayName}
+	case attrDescription:
+		return DataValue{Value: n.description}
+	case attrWriteMask, attrUserWriteMask:
+		return DataValue{Value: uint32(0)}
+	}
+	switch n.class {
+	case nodeClassObject:
+		if attr == attrEventNotifier {
+			return DataValue{Value: byte(0)}
+		}
+	case nodeClassObjectType, nodeClassVariableType, nodeClassDataType:
+		if attr == attrIsAbstract {
+			return DataValue{Value: false}
+		}
+	case nodeClassReferenceType:
+		switch attr {
+		case attrIsAbstract, attrSymmetric:
+			return DataValue{Value: false}
+		case attrInverseName:
+			return DataValue{Value: LocalizedText{}}
+		}
+	case nodeClassVariable:
+		switch attr {
+		case attrValue:
+			if n.dynamic != nil {
+				return n.dynamic()
+			}
+			return n.value
+		case attrDataType:
+			return DataValue{Value: n.dataType}
+		case attrValueRank:
+			return DataValue{Value: n.rank}
+		case attrArrayDimensions:
+			if n.rank == 1 {
+				return DataValue{Value: []uint32{0}}
+			}
+			return DataValue{Status: StatusBadAttributeIDInvalid}
+		case attrAccessLevel, attrUserAccessLevel:
+			return DataValue{Value: n.access}
+		case attrMinSampling:
+			return DataValue{Value: float64(0)}
+		case attrHistorizing:
+			return DataValue{Value: false}
+		}
+	}
+	return DataValue{Status: StatusBadAttributeIDInvalid}
+}
+
+// euInformation EngineeringUnits 属性的值，单位代码未知（-1），显示名为配置的单位文本
+func euInformation(unit string) ExtensionObject {
+	var e encoder
+	e.string(unitsNamespace)
+	e.int32(-1)
+	e.localizedText(LocalizedText{Text: unit})
+	e.localizedText(LocalizedText{Text: unit})
+	return ExtensionObject{TypeID: NewNumericNodeID(0, idEUInformationEncoding), Body: e.bytes()}
+}
+
+// gatewayNodeID 网关变量的 NodeId：ns=1;s=<文件夹>.<变量>
+func gatewayNodeID(path ...string) NodeID {
+	return NewStringNodeID(1, strings.Join(path, "."))
+}
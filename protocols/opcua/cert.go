@@ -0,0 +1,166 @@
+package opcua
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// loadCertificate 加载 PEM 或 DER 格式的证书和 RSA 私钥；文件未配置时生成自签名证书，
+// 证书的 URI SAN 为 appURI（OPC UA 要求与 ApplicationUri 一致）
+func loadCertificate(certFile, keyFile, appURI string) ([]byte, *rsa.PrivateKey, error) {
+	if certFile == "" && keyFile == "" {
+		return generateCertificate(appURI)
+	}
+	if certFile == "" || keyFile == "" {
+		return nil, nil, errors.New("opcua: cert_file and key_file must be set together")
+	}
+	cert, err := readPEM(certFile, "CERTIFICATE")
+	if err != nil {
+		return nil, nil, err
+	}
+	if _, err := x509.ParseCertificate(cert); err != nil {
+		return nil, nil, fmt.Errorf("opcua: %s: %w", certFile, err)
+	}
+	kb, err := readPEM(keyFile, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := parsePrivateKey(kb)
+	if err != nil {
+		return nil, nil, fmt.Errorf("opcua: %s: %w", keyFile, err)
+	}
+	return cert, key, nil
+}
+
+// loadCertificateFile 加载单个证书文件（如固定的服务端证书）
+func loadCertificateFile(file string) ([]byte, error) {
+	cert, err := readPEM(file, "CERTIFICATE")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := x509.ParseCertificate(cert); err != nil {
+		return nil, fmt.Errorf("opcua: %s: %w", file, err)
+	}
+	return cert, nil
+}
+
+// loadTrustList 加载目录下的全部证书文件（.pem/.crt/.der/.cer）
+func loadTrustList(dir string) ([][]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("opcua: %w", err)
+	}
+	var certs [][]byte
+	for _, e := range entries {
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".pem", ".crt", ".der", ".cer":
+		default:
+			continue
+		}
+		if e.IsDir() {
+			continue
+		}
+		cert, err := loadCertificateFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("opcua: no certificate in %s", dir)
+	}
+	return certs, nil
+}
+
+// readPEM 读取文件，PEM 格式时返回第一个匹配类型的块（typ 为空时不限类型），否则按 DER 返回
+func readPEM(file, typ string) ([]byte, error) {
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("opcua: %w", err)
+	}
+	rest := b
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if typ == "" || block.Type == typ {
+			return block.Bytes, nil
+		}
+	}
+	return b, nil
+}
+
+func parsePrivateKey(b []byte) (*rsa.PrivateKey, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(b); err == nil {
+		return key, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(b)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := k.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("not an RSA private key")
+	}
+	return key, nil
+}
+
+func generateCertificate(appURI string) ([]byte, *rsa.PrivateKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "sensor-edge", Organization: []string{"sensor-edge"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment | x509.KeyUsageKeyEncipherment | x509.KeyUsageDataEncipherment | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+	}
+	if u, err := url.Parse(appURI); err == nil && appURI != "" {
+		tmpl.URIs = []*url.URL{u}
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, key, nil
+}
+
+// publicKey 取证书（或证书链的第一个证书）中的 RSA 公钥
+func publicKey(cert []byte) (*rsa.PublicKey, error) {
+	certs, err := x509.ParseCertificates(cert)
+	if err != nil || len(certs) == 0 {
+		return nil, fmt.Errorf("opcua: invalid certificate: %v", err)
+	}
+	pub, ok := certs[0].PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return nil, errors.New("opcua: certificate does not contain an RSA public key")
+	}
+	return pub, nil
+}
+
+func thumbprint(cert []byte) []byte {
+	h := sha1.Sum(cert)
+	return h[:]
+}
@@ -0,0 +1,539 @@
+package opcua
+
+import (
+	"crypto/hmac"
+	"crypto/rsa"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// 传输层参数
+const (
+	protocolVersion   = 0
+	defaultBufferSize = 65535
+	minBufferSize     = 8192
+	maxMessageSize    = 16 << 20
+	headerSize        = 12 // MessageType(3) + ChunkType(1) + MessageSize(4) + SecureChannelId(4)
+	sequenceSize      = 8  // SequenceNumber(4) + RequestId(4)
+)
+
+// 报文类型
+const (
+	msgHello       = "HEL"
+	msgAcknowledge = "ACK"
+	msgError       = "ERR"
+	msgOpen        = "OPN"
+	msgMessage     = "MSG"
+	msgClose       = "CLO"
+)
+
+// transportError 对端发送的 ERR 报文或本端检测到的传输层错误
+type transportError struct {
+	Status StatusCode
+	Reason string
+}
+
+func (e *transportError) Error() string {
+	if e.Reason == "" {
+		return "opcua: " + e.Status.String()
+	}
+	return fmt.Sprintf("opcua: %s: %s", e.Status, e.Reason)
+}
+
+// abortError 对端中止了一条多分块报文
+type abortError struct {
+	requestID uint32
+	status    StatusCode
+}
+
+func (e *abortError) Error() string {
+	return fmt.Sprintf("opcua: request %d aborted: %s", e.requestID, e.status)
+}
+
+// hello HEL 报文；ACK 报文为不含 EndpointURL 的同样字段
+type hello struct {
+	ReceiveBufferSize uint32
+	SendBufferSize    uint32
+	MaxMessageSize    uint32
+	MaxChunkCount     uint32
+	EndpointURL       string
+}
+
+func (h *hello) encode(ack bool) []byte {
+	var e encoder
+	e.uint32(protocolVersion)
+	e.uint32(h.ReceiveBufferSize)
+	e.uint32(h.SendBufferSize)
+	e.uint32(h.MaxMessageSize)
+	e.uint32(h.MaxChunkCount)
+	if !ack {
+		e.string(h.EndpointURL)
+	}
+	return e.bytes()
+}
+
+func decodeHello(b []byte, ack bool) (*hello, error) {
+	d := newDecoder(b)
+	d.uint32()
+	h := &hello{ReceiveBufferSize: d.uint32(), SendBufferSize: d.uint32(), MaxMessageSize: d.uint32(), MaxChunkCount: d.uint32()}
+	if !ack {
+		h.EndpointURL = d.string()
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	if h.ReceiveBufferSize < minBufferSize || h.SendBufferSize < minBufferSize {
+		return nil, &transportError{Status: StatusBadTCPMessageTooLarge, Reason: "buffer size too small"}
+	}
+	return h, nil
+}
+
+// writeRaw 写入 HEL/ACK/ERR 等无安全处理的报文
+func writeRaw(w io.Writer, msgType string, body []byte) error {
+	b := make([]byte, 8, 8+len(body))
+	copy(b, msgType)
+	b[3] = 'F'
+	binary.LittleEndian.PutUint32(b[4:], uint32(8+len(body)))
+	_, err := w.Write(append(b, body...))
+	return err
+}
+
+func writeError(w io.Writer, status StatusCode, reason string) error {
+	var e encoder
+	e.uint32(uint32(status))
+	e.string(reason)
+	return writeRaw(w, msgError, e.bytes())
+}
+
+func decodeError(b []byte) error {
+	d := newDecoder(b)
+	e := &transportError{Status: StatusCode(d.uint32()), Reason: d.string()}
+	if d.err != nil {
+		return &transportError{Status: StatusBadDecodingError, Reason: "invalid ERR message"}
+	}
+	return e
+}
+
+// readChunk 读取一个完整分块（含 8 字节报文头）
+func readChunk(r io.Reader, max uint32) ([]byte, error) {
+	hdr := make([]byte, 8)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, err
+	}
+	size := binary.LittleEndian.Uint32(hdr[4:])
+	if size < 8 || size > max {
+		return nil, &transportError{Status: StatusBadTCPMessageTooLarge, Reason: fmt.Sprintf("chunk size %d", size)}
+	}
+	b := make([]byte, size)
+	copy(b, hdr)
+	if _, err := io.ReadFull(r, b[8:]); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// channelToken 一个安全令牌的对称密钥，local 用于发送，remote 用于接收
+type channelToken struct {
+	id     uint32
+	local  *symKeys
+	remote *symKeys
+}
+
+// secureChannel 安全通道，客户端与服务端共用分块、签名和加密的处理
+type secureChannel struct {
+	conn   net.Conn
+	server bool
+	policy *securityPolicy
+	mode   uint32
+
+	localCert  []byte
+	localKey   *rsa.PrivateKey
+	remoteCert []byte
+	remoteKey  *rsa.PublicKey
+
+	sendBufferSize uint32 // 对端的接收缓冲区
+	recvBufferSize uint32
+	maxMessageSize uint32 // 对端可接收的最大报文，0 为不限
+
+	wmu       sync.Mutex
+	channelID uint32
+	seq       uint32
+
+	mu        sync.Mutex
+	tokens    map[uint32]*channelToken
+	sendToken uint32
+	latest    uint32 // 最近启用的令牌
+
+	chunks map[uint32][]byte // 未收齐的多分块报文，只在读协程中使用
+}
+
+func newSecureChannel(conn net.Conn, server bool) *secureChannel {
+	return &secureChannel{
+		conn:           conn,
+		server:         server,
+		sendBufferSize: defaultBufferSize,
+		recvBufferSize: defaultBufferSize,
+		tokens:         make(map[uint32]*channelToken),
+		chunks:         make(map[uint32][]byte),
+	}
+}
+
+func (c *secureChannel) signing() bool { return !c.policy.isNone() && c.mode >= securityModeSign }
+
+func (c *secureChannel) encrypting() bool {
+	return !c.policy.isNone() && c.mode == securityModeSignAndEncrypt
+}
+
+// installToken 按双方 nonce 派生新令牌的密钥；客户端立即用新令牌发送，
+// 服务端在收到客户端使用新令牌的报文后再切换
+func (c *secureChannel) installToken(id uint32, localNonce, remoteNonce []byte) {
+	t := &channelToken{
+		id:     id,
+		local:  c.policy.deriveKeys(remoteNonce, localNonce),
+		remote: c.policy.deriveKeys(localNonce, remoteNonce),
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.tokens[id] = t
+	c.latest = id
+	if !c.server || c.sendToken == 0 {
+		c.sendToken = id
+	}
+	// 只保留当前和上一个令牌
+	for tid := range c.tokens {
+		if tid != id && tid != c.sendToken && tid != id-1 {
+			delete(c.tokens, tid)
+		}
+	}
+}
+
+func (c *secureChannel) token(id uint32) *channelToken {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := c.tokens[id]
+	if t != nil && c.server && id > c.sendToken {
+		c.sendToken = id
+	}
+	return t
+}
+
+// writeMessage 发送一条报文，OPN 使用非对称安全处理，MSG/CLO 使用当前令牌并按缓冲区大小分块
+func (c *secureChannel) writeMessage(msgType string, requestID uint32, body []byte) error {
+	if c.maxMessageSize > 0 && uint32(len(body)) > c.maxMessageSize {
+		return &transportError{Status: StatusBadTCPMessageTooLarge, Reason: fmt.Sprintf("message size %d", len(body))}
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if msgType == msgOpen {
+		b, err := c.asymmetricChunk(requestID, body)
+		if err != nil {
+			return err
+		}
+		_, err = c.conn.Write(b)
+		return err
+	}
+	c.mu.Lock()
+	t := c.tokens[c.sendToken]
+	c.mu.Unlock()
+	if t == nil {
+		return &transportError{Status: StatusBadSecureChannelClosed, Reason: "no security token"}
+	}
+	max := c.maxChunkBody()
+	for {
+		n, chunkType := len(body), byte('F')
+		if n > max {
+			n, chunkType = max, 'C'
+		}
+		b, err := c.symmetricChunk(t, msgType, chunkType, requestID, body[:n])
+		if err != nil {
+			return err
+		}
+		if _, err := c.conn.Write(b); err != nil {
+			return err
+		}
+		body = body[n:]
+		if chunkType == 'F' {
+			return nil
+		}
+	}
+}
+
+// maxChunkBody 单个 MSG 分块可容纳的报文体长度
+func (c *secureChannel) maxChunkBody() int {
+	size := int(c.sendBufferSize) - headerSize - 4
+	sig := 0
+	if c.signing() {
+		sig = 32
+	}
+	if c.encrypting() {
+		return size/16*16 - sequenceSize - sig - 1
+	}
+	return size - sequenceSize - sig
+}
+
+func (c *secureChannel) nextSequence() uint32 {
+	c.seq++
+	if c.seq == 0 || c.seq > 0xFFFFFC00 {
+		c.seq = 1
+	}
+	return c.seq
+}
+
+// appendPadding 追加填充，使 n 字节明文加上填充和签名后为 block 的整数倍
+func appendPadding(b []byte, n, block, sigLen int, extra bool) []byte {
+	total := n + 1 + sigLen
+	if extra {
+		total++
+	}
+	pad := (block - total%block) % block
+	for i := 0; i <= pad; i++ {
+		b = append(b, byte(pad))
+	}
+	if extra {
+		b = append(b, byte(pad>>8))
+	}
+	return b
+}
+
+// stripPadding 去掉明文末尾的填充，b 不含签名
+func stripPadding(b []byte, extra bool) ([]byte, error) {
+	n := len(b)
+	pad := 0
+	if extra {
+		if n < 1 {
+			return nil, errors.New("opcua: invalid padding")
+		}
+		pad = int(b[n-1]) << 8
+		n--
+	}
+	if n < 1 {
+		return nil, errors.New("opcua: invalid padding")
+	}
+	pad |= int(b[n-1])
+	if pad+1 > n {
+		return nil, errors.New("opcua: invalid padding")
+	}
+	return b[:n-1-pad], nil
+}
+
+func (c *secureChannel) symmetricChunk(t *channelToken, msgType string, chunkType byte, requestID uint32, body []byte) ([]byte, error) {
+	b := make([]byte, headerSize+4, headerSize+4+sequenceSize+len(body)+64)
+	copy(b, msgType)
+	b[3] = chunkType
+	binary.LittleEndian.PutUint32(b[8:], c.channelID)
+	binary.LittleEndian.PutUint32(b[12:], t.id)
+	b = binary.LittleEndian.AppendUint32(b, c.nextSequence())
+	b = binary.LittleEndian.AppendUint32(b, requestID)
+	b = append(b, body...)
+	sigLen := 0
+	if c.signing() {
+		sigLen = 32
+	}
+	if c.encrypting() {
+		b = appendPadding(b, len(b)-headerSize-4, 16, sigLen, false)
+	}
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(b)+sigLen))
+	if c.signing() {
+		b = append(b, t.local.mac(b)...)
+	}
+	if c.encrypting() {
+		enc, err := t.local.encrypt(b[headerSize+4:])
+		if err != nil {
+			return nil, err
+		}
+		copy(b[headerSize+4:], enc)
+	}
+	return b, nil
+}
+
+func (c *secureChannel) asymmetricChunk(requestID uint32, body []byte) ([]byte, error) {
+	var e encoder
+	e.buf = make([]byte, headerSize)
+	copy(e.buf, msgOpen)
+	e.buf[3] = 'F'
+	binary.LittleEndian.PutUint32(e.buf[8:], c.channelID)
+	e.string(c.policy.uri)
+	if c.policy.isNone() {
+		e.byteString(nil)
+		e.byteString(nil)
+	} else {
+		e.byteString(c.localCert)
+		e.byteString(thumbprint(c.remoteCert))
+	}
+	start := len(e.buf)
+	e.uint32(c.nextSequence())
+	e.uint32(requestID)
+	e.buf = append(e.buf, body...)
+	b := e.bytes()
+	if c.policy.isNone() {
+		binary.LittleEndian.PutUint32(b[4:], uint32(len(b)))
+		return b, nil
+	}
+	keySize := c.remoteKey.Size()
+	plainBlock := keySize - oaepSHA1Overhead
+	sigLen := c.localKey.Size()
+	b = appendPadding(b, len(b)-start, plainBlock, sigLen, keySize > 256)
+	plainLen := len(b) - start + sigLen
+	binary.LittleEndian.PutUint32(b[4:], uint32(start+plainLen/plainBlock*keySize))
+	sig, err := asymSign(c.localKey, b)
+	if err != nil {
+		return nil, err
+	}
+	enc, err := asymEncrypt(c.remoteKey, append(b[start:], sig...))
+	if err != nil {
+		return nil, err
+	}
+	return append(b[:start], enc...), nil
+}
+
+// readMessage 读取并组装一条完整报文，返回报文类型、请求 ID 和报文体
+func (c *secureChannel) readMessage() (string, uint32, []byte, error) {
+	for {
+		b, err := readChunk(c.conn, c.recvBufferSize)
+		if err != nil {
+			return "", 0, nil, err
+		}
+		msgType := string(b[:3])
+		if msgType == msgError {
+			return "", 0, nil, decodeError(b[8:])
+		}
+		if len(b) < headerSize {
+			return "", 0, nil, &transportError{Status: StatusBadTCPMessageTypeInvalid, Reason: "chunk too short"}
+		}
+		var plain []byte
+		switch msgType {
+		case msgOpen:
+			plain, err = c.openAsymmetric(b)
+		case msgMessage, msgClose:
+			plain, err = c.openSymmetric(b)
+		default:
+			err = &transportError{Status: StatusBadTCPMessageTypeInvalid, Reason: msgType}
+		}
+		if err != nil {
+			return "", 0, nil, err
+		}
+		if len(plain) < sequenceSize {
+			return "", 0, nil, &transportError{Status: StatusBadDecodingError, Reason: "chunk too short"}
+		}
+		requestID := binary.LittleEndian.Uint32(plain[4:])
+		body := plain[sequenceSize:]
+		switch b[3] {
+		case 'C':
+			c.chunks[requestID] = append(c.chunks[requestID], body...)
+			if len(c.chunks[requestID]) > maxMessageSize {
+				return "", 0, nil, &transportError{Status: StatusBadTCPMessageTooLarge, Reason: "message too large"}
+			}
+		case 'A':
+			delete(c.chunks, requestID)
+			d := newDecoder(body)
+			return "", 0, nil, &abortError{requestID: requestID, status: StatusCode(d.uint32())}
+		default:
+			if prev, ok := c.chunks[requestID]; ok {
+				body = append(prev, body...)
+				delete(c.chunks, requestID)
+			}
+			return msgType, requestID, body, nil
+		}
+	}
+}
+
+func (c *secureChannel) openSymmetric(b []byte) ([]byte, error) {
+	if len(b) < headerSize+4 {
+		return nil, &transportError{Status: StatusBadDecodingError, Reason: "chunk too short"}
+	}
+	if id := binary.LittleEndian.Uint32(b[8:]); id != c.channelID {
+		return nil, &transportError{Status: StatusBadTCPSecureChannelUnknown, Reason: fmt.Sprintf("channel %d", id)}
+	}
+	t := c.token(binary.LittleEndian.Uint32(b[12:]))
+	if t == nil {
+		return nil, &transportError{Status: StatusBadSecureChannelTokenUnknown}
+	}
+	if c.encrypting() {
+		dec, err := t.remote.decrypt(b[headerSize+4:])
+		if err != nil {
+			return nil, &transportError{Status: StatusBadSecurityChecksFailed, Reason: err.Error()}
+		}
+		copy(b[headerSize+4:], dec)
+	}
+	if c.signing() {
+		n := len(b) - 32
+		if n < headerSize+4 || !hmac.Equal(t.remote.mac(b[:n]), b[n:]) {
+			return nil, &transportError{Status: StatusBadSecurityChecksFailed, Reason: "invalid signature"}
+		}
+		b = b[:n]
+	}
+	plain := b[headerSize+4:]
+	if c.encrypting() {
+		var err error
+		if plain, err = stripPadding(plain, false); err != nil {
+			return nil, &transportError{Status: StatusBadSecurityChecksFailed, Reason: err.Error()}
+		}
+	}
+	return plain, nil
+}
+
+// openAsymmetric 解析 OPN 分块。服务端在收到第一个 OPN 时根据安全头确定策略和客户端证书
+func (c *secureChannel) openAsymmetric(b []byte) ([]byte, error) {
+	d := newDecoder(b[headerSize:])
+	uri := d.string()
+	senderCert := d.byteString()
+	receiverThumb := d.byteString()
+	if d.err != nil {
+		return nil, &transportError{Status: StatusBadDecodingError, Reason: "invalid security header"}
+	}
+	start := headerSize + d.pos
+	if c.policy == nil {
+		p, err := findPolicy(uri)
+		if err != nil {
+			return nil, &transportError{Status: StatusBadSecurityPolicyRejected, Reason: uri}
+		}
+		c.policy = p
+	} else if uri != c.policy.uri {
+		return nil, &transportError{Status: StatusBadSecurityPolicyRejected, Reason: uri}
+	}
+	if c.policy.isNone() {
+		return b[start:], nil
+	}
+	if c.remoteCert == nil {
+		pub, err := publicKey(senderCert)
+		if err != nil {
+			return nil, &transportError{Status: StatusBadCertificateInvalid, Reason: err.Error()}
+		}
+		c.remoteCert, c.remoteKey = senderCert, pub
+	} else if !hmac.Equal(senderCert, c.remoteCert) {
+		return nil, &transportError{Status: StatusBadCertificateInvalid, Reason: "sender certificate changed"}
+	}
+	if !hmac.Equal(receiverThumb, thumbprint(c.localCert)) {
+		return nil, &transportError{Status: StatusBadCertificateInvalid, Reason: "receiver certificate thumbprint mismatch"}
+	}
+	plain, err := asymDecrypt(c.localKey, b[start:])
+	if err != nil {
+		return nil, &transportError{Status: StatusBadSecurityChecksFailed, Reason: err.Error()}
+	}
+	sigLen := c.remoteKey.Size()
+	if len(plain) < sigLen {
+		return nil, &transportError{Status: StatusBadSecurityChecksFailed, Reason: "chunk too short"}
+	}
+	n := len(plain) - sigLen
+	signed := append(append([]byte(nil), b[:start]...), plain[:n]...)
+	if err := asymVerify(c.remoteKey, signed, plain[n:]); err != nil {
+		return nil, &transportError{Status: StatusBadSecurityChecksFailed, Reason: "invalid signature"}
+	}
+	plain, err = stripPadding(plain[:n], c.localKey.Size() > 256)
+	if err != nil {
+		return nil, &transportError{Status: StatusBadSecurityChecksFailed, Reason: err.Error()}
+	}
+	return plain, nil
+}
+
+// close 发送 CLO（可选）并关闭连接
+func (c *secureChannel) close(requestID uint32, body []byte) error {
+	if body != nil {
+		c.writeMessage(msgClose, requestID, body)
+	}
+	return c.conn.Close()
+}
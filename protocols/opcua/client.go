@@ -0,0 +1,572 @@
+package opcua
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// errClosed 连接已关闭
+var errClosed = errors.New("opcua: connection closed")
+
+// clientOptions 建立会话所需的参数，由驱动配置解析得到
+type clientOptions struct {
+	endpoint       string
+	policy         *securityPolicy
+	mode           uint32
+	cert           []byte // 应用实例证书
+	key            *rsa.PrivateKey
+	appURI         string
+	serverCert     []byte   // 固定的服务端证书
+	trusted        [][]byte // 受信任的服务端证书
+	trustAny       bool     // 接受端点返回的任意证书
+	allowPlaintext bool     // 允许在未加密的通道上发送明文密码
+	auth           authOptions
+	timeout        time.Duration
+	sessionTimeout time.Duration
+	tokenLifetime  uint32 // 安全令牌生命周期(毫秒)
+}
+
+// authOptions 用户身份：anonymous、username 或 certificate
+type authOptions struct {
+	kind     string
+	username string
+	password string
+	cert     []byte
+	key      *rsa.PrivateKey
+}
+
+type reply struct {
+	msg message
+	err error
+}
+
+// client 一个安全通道上的会话，请求按 requestId 匹配响应，可并发调用
+type client struct {
+	opts *clientOptions
+	ch   *secureChannel
+	ep   endpointDescription
+
+	mu        sync.Mutex
+	pending   map[uint32]chan reply
+	requestID uint32
+	handle    uint32
+	authToken NodeID
+	err       error
+	renew     *time.Timer
+
+	channelNonce []byte
+	sessionNonce []byte
+}
+
+// dial 选择端点、打开安全通道并创建、激活会话
+func dial(opts *clientOptions) (*client, error) {
+	ep, err := selectEndpoint(opts)
+	if err != nil {
+		return nil, err
+	}
+	c, err := openChannel(opts, opts.policy, opts.mode, ep.ServerCertificate)
+	if err != nil {
+		return nil, err
+	}
+	c.ep = ep
+	if err := c.createSession(); err != nil {
+		c.close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// selectEndpoint 通过无安全通道调用 GetEndpoints，选出与配置的安全策略和模式一致的端点
+func selectEndpoint(opts *clientOptions) (endpointDescription, error) {
+	c, err := openChannel(opts, securityPolicies[0], securityModeNone, nil)
+	if err != nil {
+		return endpointDescription{}, err
+	}
+	defer c.close()
+	resp, err := c.send(&getEndpointsRequest{EndpointURL: opts.endpoint})
+	if err != nil {
+		return endpointDescription{}, err
+	}
+	var found []string
+	for _, ep := range resp.(*getEndpointsResponse).Endpoints {
+		if ep.SecurityPolicyURI == opts.policy.uri && ep.SecurityMode == opts.mode {
+			if !opts.policy.isNone() && !opts.trusts(ep.ServerCertificate) {
+				return ep, errors.New("opcua: server certificate is not trusted (server_cert/trusted_certs)")
+			}
+			return ep, nil
+		}
+		found = append(found, fmt.Sprintf("%s/%s", policyName(ep.SecurityPolicyURI), modeName(ep.SecurityMode)))
+	}
+	return endpointDescription{}, fmt.Errorf("opcua: no endpoint with %s/%s, server offers %v",
+		policyName(opts.policy.uri), modeName(opts.mode), found)
+}
+
+// trusts 服务端证书与 server_cert 或 trusted_certs 中的某个证书一致
+func (opts *clientOptions) trusts(cert []byte) bool {
+	if opts.trustAny {
+		return true
+	}
+	if opts.serverCert != nil && bytes.Equal(cert, opts.serverCert) {
+		return true
+	}
+	for _, c := range opts.trusted {
+		if bytes.Equal(cert, c) {
+			return true
+		}
+	}
+	return false
+}
+
+func policyName(uri string) string {
+	if len(uri) > len(policyURIPrefix) && uri[:len(policyURIPrefix)] == policyURIPrefix {
+		return uri[len(policyURIPrefix):]
+	}
+	return uri
+}
+
+func modeName(mode uint32) string {
+	switch mode {
+	case securityModeNone:
+		return "None"
+	case securityModeSign:
+		return "Sign"
+	case securityModeSignAndEncrypt:
+		return "SignAndEncrypt"
+	}
+	return fmt.Sprintf("mode(%d)", mode)
+}
+
+// openChannel 建立 TCP 连接，完成 HEL/ACK 和 OpenSecureChannel
+func openChannel(opts *clientOptions, policy *securityPolicy, mode uint32, serverCert []byte) (*client, error) {
+	u, err := url.Parse(opts.endpoint)
+	if err != nil || u.Scheme != "opc.tcp" || u.Host == "" {
+		return nil, fmt.Errorf("opcua: invalid endpoint %q", opts.endpoint)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "4840")
+	}
+	conn, err := net.DialTimeout("tcp", host, opts.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("opcua: %w", err)
+	}
+	ch := newSecureChannel(conn, false)
+	ch.policy, ch.mode = policy, mode
+	if !policy.isNone() {
+		pub, err := publicKey(serverCert)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		ch.localCert, ch.localKey = opts.cert, opts.key
+		ch.remoteCert, ch.remoteKey = serverCert, pub
+	}
+	c := &client{opts: opts, ch: ch, pending: make(map[uint32]chan reply)}
+	conn.SetDeadline(time.Now().Add(opts.timeout))
+	if err := c.hello(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := c.openSecureChannel(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	go c.readLoop()
+	return c, nil
+}
+
+func (c *client) hello() error {
+	h := hello{ReceiveBufferSize: defaultBufferSize, SendBufferSize: defaultBufferSize, MaxMessageSize: maxMessageSize, EndpointURL: c.opts.endpoint}
+	if err := writeRaw(c.ch.conn, msgHello, h.encode(false)); err != nil {
+		return fmt.Errorf("opcua: %w", err)
+	}
+	b, err := readChunk(c.ch.conn, defaultBufferSize)
+	if err != nil {
+		return fmt.Errorf("opcua: %w", err)
+	}
+	switch string(b[:3]) {
+	case msgAcknowledge:
+		ack, err := decodeHello(b[8:], true)
+		if err != nil {
+			return err
+		}
+		c.ch.sendBufferSize = min(ack.ReceiveBufferSize, defaultBufferSize)
+		c.ch.maxMessageSize = ack.MaxMessageSize
+		return nil
+	case msgError:
+		return decodeError(b[8:])
+	}
+	return &transportError{Status: StatusBadTCPMessageTypeInvalid, Reason: "expect ACK"}
+}
+
+// openSecureChannel 首次打开通道，同步读取响应（读协程尚未启动）
+func (c *client) openSecureChannel() error {
+	req, err := c.openRequest(0)
+	if err != nil {
+		return err
+	}
+	id := c.nextRequestID()
+	if err := c.ch.writeMessage(msgOpen, id, encodeMessage(req)); err != nil {
+		return fmt.Errorf("opcua: %w", err)
+	}
+	msgType, _, body, err := c.ch.readMessage()
+	if err != nil {
+		return err
+	}
+	if msgType != msgOpen {
+		return &transportError{Status: StatusBadTCPMessageTypeInvalid, Reason: "expect OPN"}
+	}
+	m, err := decodeMessage(body)
+	if err != nil {
+		return err
+	}
+	if err := checkResponse(m); err != nil {
+		return err
+	}
+	resp, ok := m.(*openSecureChannelResponse)
+	if !ok {
+		return fmt.Errorf("opcua: unexpected response %T", m)
+	}
+	c.ch.channelID = resp.SecurityToken.ChannelID
+	c.installToken(resp)
+	return nil
+}
+
+func (c *client) openRequest(requestType uint32) (*openSecureChannelRequest, error) {
+	nonce, err := c.ch.policy.nonce()
+	if err != nil {
+		return nil, err
+	}
+	c.channelNonce = nonce
+	return &openSecureChannelRequest{
+		Header:            requestHeader{Timestamp: time.Now(), TimeoutHint: uint32(c.opts.timeout.Milliseconds())},
+		RequestType:       requestType,
+		SecurityMode:      c.ch.mode,
+		ClientNonce:       nonce,
+		RequestedLifetime: c.opts.tokenLifetime,
+	}, nil
+}
+
+// installToken 启用新令牌，并在生命周期的 75% 时续订
+func (c *client) installToken(resp *openSecureChannelResponse) {
+	t := resp.SecurityToken
+	c.ch.installToken(t.TokenID, c.channelNonce, resp.ServerNonce)
+	if t.RevisedLifetime == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return
+	}
+	c.renew = time.AfterFunc(time.Duration(t.RevisedLifetime)*time.Millisecond*3/4, c.renewToken)
+}
+
+func (c *client) renewToken() {
+	req, err := c.openRequest(1)
+	if err == nil {
+		var m message
+		if m, err = c.call(msgOpen, req, c.opts.timeout); err == nil {
+			c.installToken(m.(*openSecureChannelResponse))
+			return
+		}
+	}
+	c.fail(fmt.Errorf("opcua: renew secure channel: %w", err))
+}
+
+func (c *client) nextRequestID() uint32 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requestID++
+	return c.requestID
+}
+
+// readLoop 读取响应并交给等待的请求，通道出错时所有等待的请求返回错误
+func (c *client) readLoop() {
+	for {
+		msgType, id, body, err := c.ch.readMessage()
+		if err != nil {
+			var ae *abortError
+			if errors.As(err, &ae) {
+				c.deliver(ae.requestID, reply{err: ae})
+				continue
+			}
+			c.fail(err)
+			return
+		}
+		if msgType == msgClose {
+			c.fail(errClosed)
+			return
+		}
+		m, err := decodeMessage(body)
+		c.deliver(id, reply{msg: m, err: err})
+	}
+}
+
+func (c *client) deliver(id uint32, r reply) {
+	c.mu.Lock()
+	ch, ok := c.pending[id]
+	delete(c.pending, id)
+	c.mu.Unlock()
+	if ok {
+		ch <- r
+	}
+}
+
+// fail 关闭连接，之后的请求均返回 err
+func (c *client) fail(err error) {
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return
+	}
+	c.err = err
+	if c.renew != nil {
+		c.renew.Stop()
+	}
+	pending := c.pending
+	c.pending = nil
+	c.mu.Unlock()
+	c.ch.conn.Close()
+	for _, ch := range pending {
+		ch <- reply{err: err}
+	}
+}
+
+// alive 连接是否可用
+func (c *client) alive() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err == nil
+}
+
+// send 发送服务请求并等待响应，ServiceFault 或服务结果为 Bad 时返回对应的 StatusCode
+func (c *client) send(req request) (message, error) {
+	return c.call(msgMessage, req, c.opts.timeout)
+}
+
+func (c *client) call(msgType string, req request, timeout time.Duration) (message, error) {
+	ch := make(chan reply, 1)
+	c.mu.Lock()
+	if c.err != nil {
+		err := c.err
+		c.mu.Unlock()
+		return nil, err
+	}
+	c.requestID++
+	c.handle++
+	id := c.requestID
+	h := req.header()
+	h.AuthenticationToken = c.authToken
+	h.Timestamp = time.Now()
+	h.RequestHandle = c.handle
+	h.TimeoutHint = uint32(timeout.Milliseconds())
+	c.pending[id] = ch
+	c.mu.Unlock()
+
+	if err := c.ch.writeMessage(msgType, id, encodeMessage(req)); err != nil {
+		c.fail(fmt.Errorf("opcua: %w", err))
+		return nil, err
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-ch:
+		if r.err != nil {
+			return nil, r.err
+		}
+		if err := checkResponse(r.msg); err != nil {
+			return nil, err
+		}
+		return r.msg, nil
+	case <-timer.C:
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+		return nil, fmt.Errorf("opcua: request %T timed out", req)
+	}
+}
+
+func checkResponse(m message) error {
+	resp, ok := m.(response)
+	if !ok {
+		return fmt.Errorf("opcua: unexpected message %T", m)
+	}
+	if s := resp.responseHeader().ServiceResult; s.IsBad() {
+		return s
+	}
+	return nil
+}
+
+// createSession 创建并激活会话
+func (c *client) createSession() error {
+	nonce, err := randomNonce(32)
+	if err != nil {
+		return err
+	}
+	req := &createSessionRequest{
+		ClientDescription: applicationDescription{
+			ApplicationURI:  c.opts.appURI,
+			ProductURI:      "urn:sensor-edge",
+			ApplicationName: LocalizedText{Text: "sensor-edge"},
+			ApplicationType: 1,
+		},
+		EndpointURL:             c.opts.endpoint,
+		SessionName:             fmt.Sprintf("sensor-edge-%d", time.Now().UnixNano()),
+		ClientNonce:             nonce,
+		ClientCertificate:       c.opts.cert,
+		RequestedSessionTimeout: float64(c.opts.sessionTimeout.Milliseconds()),
+	}
+	m, err := c.send(req)
+	if err != nil {
+		return fmt.Errorf("opcua: create session: %w", err)
+	}
+	resp := m.(*createSessionResponse)
+	if !c.ch.policy.isNone() {
+		data := append(append([]byte(nil), c.opts.cert...), nonce...)
+		if err := asymVerify(c.ch.remoteKey, data, resp.ServerSignature.Signature); err != nil {
+			return errors.New("opcua: create session: invalid server signature")
+		}
+	}
+	c.mu.Lock()
+	c.authToken = resp.AuthenticationToken
+	c.sessionNonce = resp.ServerNonce
+	c.mu.Unlock()
+	return c.activateSession()
+}
+
+func (c *client) activateSession() error {
+	req := &activateSessionRequest{LocaleIDs: []string{"en"}}
+	serverCert := c.ep.ServerCertificate
+	if !c.ch.policy.isNone() {
+		sig, err := asymSign(c.opts.key, append(append([]byte(nil), serverCert...), c.sessionNonce...))
+		if err != nil {
+			return err
+		}
+		req.ClientSignature = signatureData{Algorithm: algorithmRsaSha256, Signature: sig}
+	}
+	token, err := c.identityToken(req)
+	if err != nil {
+		return err
+	}
+	req.UserIdentityToken = token
+	m, err := c.send(req)
+	if err != nil {
+		return fmt.Errorf("opcua: activate session: %w", err)
+	}
+	c.mu.Lock()
+	c.sessionNonce = m.(*activateSessionResponse).ServerNonce
+	c.mu.Unlock()
+	return nil
+}
+
+// identityToken 按端点的用户令牌策略生成身份令牌；用户名密码在令牌策略或通道策略不为 None 时用服务端证书加密，
+// 加密前校验服务端证书（通道策略为 None 时 selectEndpoint 不校验）；令牌策略为 None 且通道不加密时密码以明文发送，
+// 需要 allow_plaintext_password: true
+func (c *client) identityToken(req *activateSessionRequest) (ExtensionObject, error) {
+	kind := map[string]uint32{"": tokenAnonymous, "anonymous": tokenAnonymous, "username": tokenUserName, "certificate": tokenCertificate}[c.opts.auth.kind]
+	var policy *userTokenPolicy
+	for i, p := range c.ep.UserIdentityTokens {
+		if p.TokenType == kind {
+			policy = &c.ep.UserIdentityTokens[i]
+			break
+		}
+	}
+	if policy == nil {
+		return ExtensionObject{}, fmt.Errorf("opcua: endpoint does not accept %s identity", c.opts.auth.kind)
+	}
+	tokenPolicy := c.ch.policy
+	if policy.SecurityPolicyURI != "" {
+		p, err := findPolicy(policy.SecurityPolicyURI)
+		if err != nil {
+			return ExtensionObject{}, err
+		}
+		tokenPolicy = p
+	}
+	var token encodable
+	switch kind {
+	case tokenAnonymous:
+		token = &anonymousIdentityToken{PolicyID: policy.PolicyID}
+	case tokenUserName:
+		t := &userNameIdentityToken{PolicyID: policy.PolicyID, UserName: c.opts.auth.username, Password: []byte(c.opts.auth.password)}
+		if tokenPolicy.isNone() && !c.ch.encrypting() && !c.opts.allowPlaintext {
+			return ExtensionObject{}, errors.New("opcua: refusing to send the password in plain text over an unencrypted channel (allow_plaintext_password)")
+		}
+		if !tokenPolicy.isNone() {
+			if !c.opts.trusts(c.ep.ServerCertificate) {
+				return ExtensionObject{}, errors.New("opcua: server certificate is not trusted, refusing to encrypt the password to it (server_cert/trusted_certs)")
+			}
+			pub, err := publicKey(c.ep.ServerCertificate)
+			if err != nil {
+				return ExtensionObject{}, err
+			}
+			if t.Password, err = encryptPassword(pub, c.opts.auth.password, c.sessionNonce); err != nil {
+				return ExtensionObject{}, err
+			}
+			t.EncryptionAlgorithm = algorithmRsaOaep
+		}
+		token = t
+	case tokenCertificate:
+		token = &x509IdentityToken{PolicyID: policy.PolicyID, CertificateData: c.opts.auth.cert}
+		sig, err := asymSign(c.opts.auth.key, append(append([]byte(nil), c.ep.ServerCertificate...), c.sessionNonce...))
+		if err != nil {
+			return ExtensionObject{}, err
+		}
+		req.UserTokenSignature = signatureData{Algorithm: algorithmRsaSha256, Signature: sig}
+	}
+	var e encoder
+	token.encode(&e)
+	return ExtensionObject{TypeID: NewNumericNodeID(0, token.typeID()), Body: e.bytes()}, nil
+}
+
+// close 关闭会话（删除订阅）和安全通道
+func (c *client) close() error {
+	if c.alive() {
+		c.mu.Lock()
+		hasSession := !c.authToken.IsNull()
+		c.mu.Unlock()
+		if hasSession {
+			c.call(msgMessage, &closeSessionRequest{DeleteSubscriptions: true}, min(c.opts.timeout, time.Second))
+		}
+		id := c.nextRequestID()
+		c.ch.writeMessage(msgClose, id, encodeMessage(&closeSecureChannelRequest{Header: requestHeader{Timestamp: time.Now()}}))
+	}
+	c.fail(errClosed)
+	return nil
+}
+
+// read 读取一组节点的属性
+func (c *client) read(nodes []readValueID) ([]DataValue, error) {
+	m, err := c.send(&readRequest{TimestampsToReturn: timestampsBoth, NodesToRead: nodes})
+	if err != nil {
+		return nil, err
+	}
+	results := m.(*readResponse).Results
+	if len(results) != len(nodes) {
+		return nil, fmt.Errorf("opcua: read returned %d results for %d nodes", len(results), len(nodes))
+	}
+	return results, nil
+}
+
+// write 写入节点的 Value 属性
+func (c *client) write(node NodeID, value interface{}) error {
+	m, err := c.send(&writeRequest{NodesToWrite: []writeValue{{NodeID: node, AttributeID: attrValue, Value: DataValue{Value: value}}}})
+	if err != nil {
+		return err
+	}
+	results := m.(*writeResponse).Results
+	if len(results) != 1 {
+		return fmt.Errorf("opcua: write returned %d results", len(results))
+	}
+	if results[0].IsBad() {
+		return results[0]
+	}
+	return nil
+}
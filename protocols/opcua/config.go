@@ -0,0 +1,154 @@
+package opcua
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// deviceConfig 设备级配置，endpoint 未配置时由 ip/port 组成 opc.tcp://ip:port
+type deviceConfig struct {
+	Endpoint           string     `yaml:"endpoint"`
+	IP                 string     `yaml:"ip"`
+	Port               int        `yaml:"port"`
+	SecurityPolicy     string     `yaml:"security_policy"` // None | Basic256Sha256 | Aes128_Sha256_RsaOaep
+	SecurityMode       string     `yaml:"security_mode"`   // None | Sign | SignAndEncrypt
+	CertFile           string     `yaml:"cert_file"`       // 应用实例证书，未配置时生成自签名证书
+	KeyFile            string     `yaml:"key_file"`
+	ApplicationURI     string     `yaml:"application_uri"`
+	ServerCert         string     `yaml:"server_cert"`              // 固定的服务端证书
+	TrustedCerts       string     `yaml:"trusted_certs"`            // 受信任的服务端证书目录
+	TrustAnyServerCert bool       `yaml:"trust_any_server_cert"`    // 接受端点返回的任意证书
+	AllowPlaintextPwd  bool       `yaml:"allow_plaintext_password"` // 允许在未加密的通道上发送明文密码
+	Auth               authConfig `yaml:"auth"`
+	Timeout            int        `yaml:"timeout"`             // 单次请求超时时间(毫秒)
+	SessionTimeout     int        `yaml:"session_timeout"`     // 会话超时时间(毫秒)
+	MaxNodesPerRead    int        `yaml:"max_nodes_per_read"`  // 单个 Read 请求的节点数上限
+	PublishingInterval int        `yaml:"publishing_interval"` // 订阅发布周期(毫秒)
+	SamplingInterval   int        `yaml:"sampling_interval"`   // 监视项采样周期(毫秒)
+}
+
+// authConfig 用户身份：anonymous（默认）、username（username/password）、certificate（cert_file/key_file）
+type authConfig struct {
+	Type     string `yaml:"type"`
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+// decodeConfig 把 YAML 解析出的 map 转换为结构体
+func decodeConfig(config map[string]interface{}, out interface{}) error {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, out)
+}
+
+func parseMode(s string) (uint32, error) {
+	switch strings.ToLower(s) {
+	case "", "none":
+		return securityModeNone, nil
+	case "sign":
+		return securityModeSign, nil
+	case "signandencrypt", "sign_and_encrypt":
+		return securityModeSignAndEncrypt, nil
+	}
+	return 0, fmt.Errorf("opcua: invalid security_mode %q", s)
+}
+
+// clientOptions 校验配置并加载证书
+func (conf *deviceConfig) clientOptions() (*clientOptions, error) {
+	endpoint := conf.Endpoint
+	if endpoint == "" {
+		if conf.IP == "" {
+			return nil, fmt.Errorf("opcua: endpoint or ip is required")
+		}
+		port := conf.Port
+		if port == 0 {
+			port = 4840
+		}
+		endpoint = "opc.tcp://" + net.JoinHostPort(conf.IP, strconv.Itoa(port))
+	}
+	policy, err := findPolicy(conf.SecurityPolicy)
+	if err != nil {
+		return nil, err
+	}
+	mode, err := parseMode(conf.SecurityMode)
+	if err != nil {
+		return nil, err
+	}
+	if conf.SecurityMode == "" && !policy.isNone() {
+		mode = securityModeSignAndEncrypt
+	}
+	if policy.isNone() != (mode == securityModeNone) {
+		return nil, fmt.Errorf("opcua: security_mode %s does not match security_policy %s", modeName(mode), policyName(policy.uri))
+	}
+	appURI := conf.ApplicationURI
+	if appURI == "" {
+		appURI = "urn:sensor-edge:client"
+	}
+	opts := &clientOptions{
+		endpoint:       endpoint,
+		policy:         policy,
+		mode:           mode,
+		appURI:         appURI,
+		timeout:        millis(conf.Timeout, 5000),
+		sessionTimeout: millis(conf.SessionTimeout, 60000),
+		tokenLifetime:  3600000,
+		auth:           authOptions{kind: strings.ToLower(conf.Auth.Type), username: conf.Auth.Username, password: conf.Auth.Password},
+	}
+	// 证书身份和加密的安全策略都需要应用实例证书
+	if !policy.isNone() || conf.CertFile != "" || opts.auth.kind == "certificate" {
+		if opts.cert, opts.key, err = loadCertificate(conf.CertFile, conf.KeyFile, appURI); err != nil {
+			return nil, err
+		}
+	}
+	if conf.ServerCert != "" {
+		if opts.serverCert, err = loadCertificateFile(conf.ServerCert); err != nil {
+			return nil, err
+		}
+	}
+	if conf.TrustedCerts != "" {
+		if opts.trusted, err = loadTrustList(conf.TrustedCerts); err != nil {
+			return nil, err
+		}
+	}
+	// 签名和加密只有在认证了服务端证书时才有意义，否则用户名密码会加密给任何应答的一方
+	opts.trustAny = conf.TrustAnyServerCert
+	opts.allowPlaintext = conf.AllowPlaintextPwd
+	if !policy.isNone() && opts.serverCert == nil && len(opts.trusted) == 0 && !opts.trustAny {
+		return nil, fmt.Errorf("opcua: security_policy %s requires server_cert or trusted_certs (or trust_any_server_cert: true)", policyName(policy.uri))
+	}
+	switch opts.auth.kind {
+	case "", "anonymous":
+	case "username":
+		if conf.Auth.Username == "" {
+			return nil, fmt.Errorf("opcua: auth.username is required")
+		}
+	case "certificate":
+		if conf.Auth.CertFile == "" {
+			// 未单独配置用户证书时使用应用实例证书
+			opts.auth.cert, opts.auth.key = opts.cert, opts.key
+			break
+		}
+		if opts.auth.cert, opts.auth.key, err = loadCertificate(conf.Auth.CertFile, conf.Auth.KeyFile, appURI); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("opcua: invalid auth type %q", conf.Auth.Type)
+	}
+	return opts, nil
+}
+
+func millis(v, def int) time.Duration {
+	if v <= 0 {
+		v = def
+	}
+	return time.Duration(v) * time.Millisecond
+}
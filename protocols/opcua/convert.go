@@ -0,0 +1,310 @@
+package opcua
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dataTypeBuiltins 常用派生数据类型对应的内置类型
+var dataTypeBuiltins = map[uint32]byte{
+	26:  typeDouble,   // Number
+	27:  typeInt64,    // Integer
+	28:  typeUInt64,   // UInteger
+	29:  typeInt32,    // Enumeration
+	288: typeUInt32,   // IntegerId
+	289: typeUInt32,   // Counter
+	290: typeDouble,   // Duration
+	294: typeDateTime, // UtcTime
+	295: typeString,   // LocaleId
+}
+
+// builtinType 数据类型节点对应的内置类型，未知类型返回 false
+func builtinType(dataType NodeID) (byte, bool) {
+	if dataType.Namespace != 0 || dataType.Type != idNumeric {
+		return 0, false
+	}
+	if dataType.Numeric >= uint32(typeBoolean) && dataType.Numeric <= uint32(typeDiagnosticInfo) {
+		return byte(dataType.Numeric), true
+	}
+	t, ok := dataTypeBuiltins[dataType.Numeric]
+	return t, ok
+}
+
+// variantType Variant 值的内置类型，数组返回元素类型
+func variantType(v interface{}) byte {
+	var e encoder
+	e.variant(v)
+	return e.buf[0] & 0x3F
+}
+
+// convertValue 把写入值转换为内置类型 t 的 Go 值；值为切片时逐个元素转换
+func convertValue(v interface{}, t byte) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice && t != typeByteString && !isBytes(v) {
+		items := make([]interface{}, rv.Len())
+		for i := range items {
+			x, err := convertScalar(rv.Index(i).Interface(), t)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+			items[i] = x
+		}
+		return typedSlice(items, t), nil
+	}
+	return convertScalar(v, t)
+}
+
+func isBytes(v interface{}) bool {
+	_, ok := v.([]byte)
+	return ok
+}
+
+// typedSlice 把已转换的元素放入对应类型的切片，使其编码为该类型的数组
+func typedSlice(items []interface{}, t byte) interface{} {
+	if len(items) == 0 {
+		return items
+	}
+	s := reflect.MakeSlice(reflect.SliceOf(reflect.TypeOf(items[0])), len(items), len(items))
+	for i, x := range items {
+		s.Index(i).Set(reflect.ValueOf(x))
+	}
+	switch s.Interface().(type) {
+	case []bool, []int8, []int16, []uint16, []int32, []uint32, []int64, []uint64, []float32, []float64, []string, []time.Time, []LocalizedText:
+		return s.Interface()
+	}
+	return items
+}
+
+func convertScalar(v interface{}, t byte) (interface{}, error) {
+	switch t {
+	case typeBoolean:
+		return toBool(v)
+	case typeSByte, typeByte, typeInt16, typeUInt16, typeInt32, typeUInt32, typeInt64, typeUInt64:
+		return toInteger(v, t)
+	case typeFloat:
+		f, err := toFloat(v)
+		if err != nil {
+			return nil, err
+		}
+		if math.Abs(f) > math.MaxFloat32 && !math.IsInf(f, 0) {
+			return nil, fmt.Errorf("%v out of range for Float", v)
+		}
+		return float32(f), nil
+	case typeDouble:
+		return toFloat(v)
+	case typeString:
+		if b, ok := v.([]byte); ok {
+			return string(b), nil
+		}
+		return fmt.Sprint(v), nil
+	case typeLocalizedText:
+		if lt, ok := v.(LocalizedText); ok {
+			return lt, nil
+		}
+		return LocalizedText{Text: fmt.Sprint(v)}, nil
+	case typeDateTime:
+		return toTime(v)
+	case typeByteString:
+		switch x := v.(type) {
+		case []byte:
+			return x, nil
+		case string:
+			b, err := hex.DecodeString(strings.ReplaceAll(x, " ", ""))
+			if err != nil {
+				return nil, fmt.Errorf("invalid hex string %q", x)
+			}
+			return b, nil
+		}
+	case typeNodeID:
+		if n, ok := v.(NodeID); ok {
+			return n, nil
+		}
+		return ParseNodeID(fmt.Sprint(v))
+	case typeStatusCode:
+		n, err := toInteger(v, typeUInt32)
+		if err != nil {
+			return nil, err
+		}
+		return StatusCode(n.(uint32)), nil
+	}
+	return nil, fmt.Errorf("cannot convert %T to data type %d", v, t)
+}
+
+func toBool(v interface{}) (bool, error) {
+	switch x := v.(type) {
+	case bool:
+		return x, nil
+	case string:
+		switch strings.ToLower(strings.TrimSpace(x)) {
+		case "true", "1", "on":
+			return true, nil
+		case "false", "0", "off":
+			return false, nil
+		}
+		return false, fmt.Errorf("invalid boolean %q", x)
+	}
+	f, err := toFloat(v)
+	if err != nil {
+		return false, err
+	}
+	return f != 0, nil
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch x := v.(type) {
+	case bool:
+		if x {
+			return 1, nil
+		}
+		return 0, nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid number %q", x)
+		}
+		return f, nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	}
+	return 0, fmt.Errorf("cannot convert %T to number", v)
+}
+
+// integerRanges 整数类型的取值范围
+var integerRanges = map[byte][2]float64{
+	typeSByte:  {math.MinInt8, math.MaxInt8},
+	typeByte:   {0, math.MaxUint8},
+	typeInt16:  {math.MinInt16, math.MaxInt16},
+	typeUInt16: {0, math.MaxUint16},
+	typeInt32:  {math.MinInt32, math.MaxInt32},
+	typeUInt32: {0, math.MaxUint32},
+	typeInt64:  {math.MinInt64, math.MaxInt64},
+	typeUInt64: {0, math.MaxUint64},
+}
+
+func toInteger(v interface{}, t byte) (interface{}, error) {
+	var i int64
+	var u uint64
+	var neg bool
+	switch x := v.(type) {
+	case string:
+		s := strings.TrimSpace(x)
+		if n, err := strconv.ParseInt(s, 0, 64); err == nil {
+			i, u, neg = n, uint64(n), n < 0
+		} else if n, err := strconv.ParseUint(s, 0, 64); err == nil {
+			i, u = int64(n), n
+		} else {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil || f != math.Trunc(f) {
+				return nil, fmt.Errorf("invalid integer %q", x)
+			}
+			return toInteger(f, t)
+		}
+	default:
+		rv := reflect.ValueOf(v)
+		switch rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i = rv.Int()
+			u, neg = uint64(i), i < 0
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			u = rv.Uint()
+			i = int64(u)
+		default:
+			f, err := toFloat(v)
+			if err != nil {
+				return nil, err
+			}
+			if f != math.Trunc(f) {
+				return nil, fmt.Errorf("%v is not an integer", v)
+			}
+			r := integerRanges[t]
+			if f < r[0] || f > r[1] {
+				return nil, fmt.Errorf("%v out of range for data type %d", v, t)
+			}
+			if f < 0 {
+				i, u, neg = int64(f), uint64(int64(f)), true
+			} else {
+				u = uint64(f)
+				i = int64(u)
+			}
+		}
+	}
+	r := integerRanges[t]
+	if neg && float64(i) < r[0] || !neg && float64(u) > r[1] {
+		return nil, fmt.Errorf("%v out of range for data type %d", v, t)
+	}
+	switch t {
+	case typeSByte:
+		return int8(i), nil
+	case typeByte:
+		return uint8(u), nil
+	case typeInt16:
+		return int16(i), nil
+	case typeUInt16:
+		return uint16(u), nil
+	case typeInt32:
+		return int32(i), nil
+	case typeUInt32:
+		return uint32(u), nil
+	case typeInt64:
+		if !neg && u > math.MaxInt64 {
+			return nil, fmt.Errorf("%v out of range for data type %d", v, t)
+		}
+		return i, nil
+	}
+	return u, nil
+}
+
+func toTime(v interface{}) (time.Time, error) {
+	switch x := v.(type) {
+	case time.Time:
+		return x, nil
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(x))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid time %q", x)
+		}
+		return t, nil
+	}
+	f, err := toFloat(v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// 数值按 Unix 秒处理
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+}
+
+// pointValueOf 把读取到的 Variant 值转换为采集流程使用的值
+func pointValueOf(v interface{}) interface{} {
+	switch x := v.(type) {
+	case LocalizedText:
+		return x.Text
+	case QualifiedName:
+		return x.Name
+	case NodeID:
+		return x.String()
+	case StatusCode:
+		return uint32(x)
+	case ExtensionObject:
+		return x.Body
+	case []LocalizedText:
+		out := make([]string, len(x))
+		for i, t := range x {
+			out[i] = t.Text
+		}
+		return out
+	}
+	return v
+}
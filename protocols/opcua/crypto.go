@@ -0,0 +1,212 @@
+package opcua
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 安全策略 URI
+const (
+	policyURIPrefix           = "http://opcfoundation.org/UA/SecurityPolicy#"
+	PolicyNone                = policyURIPrefix + "None"
+	PolicyBasic256Sha256      = policyURIPrefix + "Basic256Sha256"
+	PolicyAes128Sha256RsaOaep = policyURIPrefix + "Aes128_Sha256_RsaOaep"
+)
+
+// 签名与加密算法 URI（CreateSession/ActivateSession 中的签名和用户密码加密）
+const (
+	algorithmRsaSha256 = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	algorithmRsaOaep   = "http://www.w3.org/2001/04/xmlenc#rsa-oaep"
+)
+
+// oaepSHA1Overhead RSA-OAEP(SHA1) 每个加密块的填充开销
+const oaepSHA1Overhead = 2*sha1.Size + 2
+
+// securityPolicy 安全策略的算法参数；None 策略的各长度为 0
+type securityPolicy struct {
+	uri        string
+	signKeyLen int // 对称签名密钥长度（HMAC-SHA256）
+	encKeyLen  int // 对称加密密钥长度（AES-CBC）
+	nonceLen   int
+}
+
+var securityPolicies = []*securityPolicy{
+	{uri: PolicyNone},
+	{uri: PolicyBasic256Sha256, signKeyLen: 32, encKeyLen: 32, nonceLen: 32},
+	{uri: PolicyAes128Sha256RsaOaep, signKeyLen: 32, encKeyLen: 16, nonceLen: 32},
+}
+
+// findPolicy 按 URI 或简称（None、Basic256Sha256、Aes128_Sha256_RsaOaep）查找安全策略
+func findPolicy(name string) (*securityPolicy, error) {
+	if name == "" {
+		name = "None"
+	}
+	for _, p := range securityPolicies {
+		if p.uri == name || strings.EqualFold(strings.TrimPrefix(p.uri, policyURIPrefix), name) {
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("opcua: unsupported security policy %q", name)
+}
+
+func (p *securityPolicy) isNone() bool { return p.uri == PolicyNone }
+
+func (p *securityPolicy) nonce() ([]byte, error) {
+	if p.nonceLen == 0 {
+		return nil, nil
+	}
+	return randomNonce(p.nonceLen)
+}
+
+func randomNonce(n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	return b, err
+}
+
+// asymSign RSA-PKCS1-v1_5-SHA256 签名
+func asymSign(key *rsa.PrivateKey, data []byte) ([]byte, error) {
+	h := sha256.Sum256(data)
+	return rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+}
+
+func asymVerify(pub *rsa.PublicKey, data, sig []byte) error {
+	h := sha256.Sum256(data)
+	return rsa.VerifyPKCS1v15(pub, crypto.SHA256, h[:], sig)
+}
+
+// asymEncrypt RSA-OAEP(SHA1) 分块加密，明文长度须为块长度的整数倍或最后一块不足
+func asymEncrypt(pub *rsa.PublicKey, data []byte) ([]byte, error) {
+	block := pub.Size() - oaepSHA1Overhead
+	var out []byte
+	for len(data) > 0 {
+		n := min(block, len(data))
+		c, err := rsa.EncryptOAEP(sha1.New(), rand.Reader, pub, data[:n], nil)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c...)
+		data = data[n:]
+	}
+	return out, nil
+}
+
+func asymDecrypt(key *rsa.PrivateKey, data []byte) ([]byte, error) {
+	block := key.Size()
+	if len(data)%block != 0 {
+		return nil, errors.New("opcua: invalid asymmetric cipher text length")
+	}
+	var out []byte
+	for i := 0; i < len(data); i += block {
+		p, err := rsa.DecryptOAEP(sha1.New(), rand.Reader, key, data[i:i+block], nil)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, p...)
+	}
+	return out, nil
+}
+
+// pSHA256 TLS 的 P_SHA256 伪随机函数
+func pSHA256(secret, seed []byte, n int) []byte {
+	var out []byte
+	a := seed
+	for len(out) < n {
+		m := hmac.New(sha256.New, secret)
+		m.Write(a)
+		a = m.Sum(nil)
+		m = hmac.New(sha256.New, secret)
+		m.Write(a)
+		m.Write(seed)
+		out = m.Sum(out)
+	}
+	return out[:n]
+}
+
+// symKeys 一个方向上的对称密钥
+type symKeys struct {
+	sign []byte
+	enc  []byte
+	iv   []byte
+}
+
+// deriveKeys 派生对称密钥：发送方密钥以对方 nonce 为 secret、己方 nonce 为 seed
+func (p *securityPolicy) deriveKeys(secret, seed []byte) *symKeys {
+	if p.isNone() {
+		return nil
+	}
+	b := pSHA256(secret, seed, p.signKeyLen+p.encKeyLen+aes.BlockSize)
+	return &symKeys{
+		sign: b[:p.signKeyLen],
+		enc:  b[p.signKeyLen : p.signKeyLen+p.encKeyLen],
+		iv:   b[p.signKeyLen+p.encKeyLen:],
+	}
+}
+
+func (k *symKeys) mac(data []byte) []byte {
+	m := hmac.New(sha256.New, k.sign)
+	m.Write(data)
+	return m.Sum(nil)
+}
+
+func (k *symKeys) encrypt(data []byte) ([]byte, error) {
+	b, err := aes.NewCipher(k.enc)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("opcua: plain text is not a multiple of the block size")
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCEncrypter(b, k.iv).CryptBlocks(out, data)
+	return out, nil
+}
+
+func (k *symKeys) decrypt(data []byte) ([]byte, error) {
+	b, err := aes.NewCipher(k.enc)
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("opcua: invalid cipher text length")
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(b, k.iv).CryptBlocks(out, data)
+	return out, nil
+}
+
+// encryptPassword 按 UserNameIdentityToken 的格式加密密码：长度(4) + 密码 + 服务端 nonce
+func encryptPassword(pub *rsa.PublicKey, password string, serverNonce []byte) ([]byte, error) {
+	var e encoder
+	e.uint32(uint32(len(password) + len(serverNonce)))
+	e.buf = append(e.buf, password...)
+	e.buf = append(e.buf, serverNonce...)
+	return asymEncrypt(pub, e.bytes())
+}
+
+// decryptPassword 解密 encryptPassword 的结果并校验服务端 nonce
+func decryptPassword(key *rsa.PrivateKey, data, serverNonce []byte) (string, error) {
+	b, err := asymDecrypt(key, data)
+	if err != nil {
+		return "", err
+	}
+	d := newDecoder(b)
+	n := int(d.uint32())
+	if d.err != nil || n > len(b)-4 || n < len(serverNonce) {
+		return "", errors.New("opcua: invalid encrypted password")
+	}
+	b = b[4 : 4+n]
+	if !hmac.Equal(b[n-len(serverNonce):], serverNonce) {
+		return "", errors.New("opcua: password nonce mismatch")
+	}
+	return string(b[:n-len(serverNonce)]), nil
+}
@@ -0,0 +1,703 @@
+package opcua
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// 内置数据类型编号（OPC UA Part 6 5.1.2）
+const (
+	typeNull            byte = 0
+	typeBoolean         byte = 1
+	typeSByte           byte = 2
+	typeByte            byte = 3
+	typeInt16           byte = 4
+	typeUInt16          byte = 5
+	typeInt32           byte = 6
+	typeUInt32          byte = 7
+	typeInt64           byte = 8
+	typeUInt64          byte = 9
+	typeFloat           byte = 10
+	typeDouble          byte = 11
+	typeString          byte = 12
+	typeDateTime        byte = 13
+	typeGuid            byte = 14
+	typeByteString      byte = 15
+	typeXMLElement      byte = 16
+	typeNodeID          byte = 17
+	typeExpandedNodeID  byte = 18
+	typeStatusCode      byte = 19
+	typeQualifiedName   byte = 20
+	typeLocalizedText   byte = 21
+	typeExtensionObject byte = 22
+	typeDataValue       byte = 23
+	typeVariant         byte = 24
+	typeDiagnosticInfo  byte = 25
+)
+
+var errShortBuffer = errors.New("opcua: message truncated")
+
+// maxArrayLength 解码数组长度上限，防止畸形报文导致大量分配
+const maxArrayLength = 1 << 20
+
+// epoch DateTime 的起点（1601-01-01 UTC），单位 100 纳秒
+var epoch = time.Date(1601, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// QualifiedName 带命名空间的名称
+type QualifiedName struct {
+	NamespaceIndex uint16
+	Name           string
+}
+
+// LocalizedText 本地化文本
+type LocalizedText struct {
+	Locale string
+	Text   string
+}
+
+// ExtensionObject 结构体类型的值，Body 为二进制编码的内容
+type ExtensionObject struct {
+	TypeID NodeID
+	Body   []byte
+}
+
+// DataValue 属性值及其状态和时间戳
+type DataValue struct {
+	Value           interface{}
+	Status          StatusCode
+	SourceTimestamp time.Time
+	ServerTimestamp time.Time
+}
+
+// encoder 二进制编码（小端）
+type encoder struct {
+	buf []byte
+}
+
+func (e *encoder) bytes() []byte { return e.buf }
+
+func (e *encoder) byte(v byte) { e.buf = append(e.buf, v) }
+
+func (e *encoder) bool(v bool) {
+	if v {
+		e.byte(1)
+	} else {
+		e.byte(0)
+	}
+}
+
+func (e *encoder) uint16(v uint16) { e.buf = binary.LittleEndian.AppendUint16(e.buf, v) }
+func (e *encoder) uint32(v uint32) { e.buf = binary.LittleEndian.AppendUint32(e.buf, v) }
+func (e *encoder) uint64(v uint64) { e.buf = binary.LittleEndian.AppendUint64(e.buf, v) }
+func (e *encoder) int32(v int32)   { e.uint32(uint32(v)) }
+func (e *encoder) float32(v float32) {
+	e.uint32(math.Float32bits(v))
+}
+func (e *encoder) float64(v float64) {
+	e.uint64(math.Float64bits(v))
+}
+
+// string 空字符串编码为 null（长度 -1）
+func (e *encoder) string(s string) {
+	if s == "" {
+		e.int32(-1)
+		return
+	}
+	e.int32(int32(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+// byteString nil 编码为 null（长度 -1）
+func (e *encoder) byteString(b []byte) {
+	if b == nil {
+		e.int32(-1)
+		return
+	}
+	e.int32(int32(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+func (e *encoder) dateTime(t time.Time) {
+	if t.IsZero() {
+		e.uint64(0)
+		return
+	}
+	e.uint64(uint64(t.Sub(epoch) / 100))
+}
+
+func (e *encoder) guid(g [16]byte) { e.buf = append(e.buf, g[:]...) }
+
+func (e *encoder) stringArray(v []string) {
+	if v == nil {
+		e.int32(-1)
+		return
+	}
+	e.int32(int32(len(v)))
+	for _, s := range v {
+		e.string(s)
+	}
+}
+
+func (e *encoder) uint32Array(v []uint32) {
+	if v == nil {
+		e.int32(-1)
+		return
+	}
+	e.int32(int32(len(v)))
+	for _, x := range v {
+		e.uint32(x)
+	}
+}
+
+func (e *encoder) statusCodes(v []StatusCode) {
+	if v == nil {
+		e.int32(-1)
+		return
+	}
+	e.int32(int32(len(v)))
+	for _, x := range v {
+		e.uint32(uint32(x))
+	}
+}
+
+func (e *encoder) qualifiedName(q QualifiedName) {
+	e.uint16(q.NamespaceIndex)
+	e.string(q.Name)
+}
+
+func (e *encoder) localizedText(t LocalizedText) {
+	var mask byte
+	if t.Locale != "" {
+		mask |= 0x01
+	}
+	if t.Text != "" {
+		mask |= 0x02
+	}
+	e.byte(mask)
+	if t.Locale != "" {
+		e.string(t.Locale)
+	}
+	if t.Text != "" {
+		e.string(t.Text)
+	}
+}
+
+// extensionObject TypeID 为空时编码为无内容的 ExtensionObject
+func (e *encoder) extensionObject(x ExtensionObject) {
+	e.nodeID(x.TypeID)
+	if x.TypeID.IsNull() && x.Body == nil {
+		e.byte(0)
+		return
+	}
+	e.byte(1)
+	e.byteString(x.Body)
+}
+
+// encodable 可编码为 ExtensionObject 的结构体
+type encodable interface {
+	typeID() uint32 // 二进制编码的 NodeId（ns=0）
+	encode(e *encoder)
+}
+
+// object 把结构体编码为 ExtensionObject，v 为 nil 时编码为空
+func (e *encoder) object(v encodable) {
+	if v == nil {
+		e.extensionObject(ExtensionObject{})
+		return
+	}
+	var body encoder
+	v.encode(&body)
+	e.extensionObject(ExtensionObject{TypeID: NewNumericNodeID(0, v.typeID()), Body: body.bytes()})
+}
+
+func (e *encoder) dataValue(v DataValue) {
+	var mask byte
+	if v.Value != nil {
+		mask |= 0x01
+	}
+	if v.Status != 0 {
+		mask |= 0x02
+	}
+	if !v.SourceTimestamp.IsZero() {
+		mask |= 0x04
+	}
+	if !v.ServerTimestamp.IsZero() {
+		mask |= 0x08
+	}
+	e.byte(mask)
+	if v.Value != nil {
+		e.variant(v.Value)
+	}
+	if v.Status != 0 {
+		e.uint32(uint32(v.Status))
+	}
+	if !v.SourceTimestamp.IsZero() {
+		e.dateTime(v.SourceTimestamp)
+	}
+	if !v.ServerTimestamp.IsZero() {
+		e.dateTime(v.ServerTimestamp)
+	}
+}
+
+// variant 按 Go 类型编码 Variant，支持内置类型的标量和一维切片，nil 编码为空 Variant
+func (e *encoder) variant(v interface{}) {
+	switch x := v.(type) {
+	case nil:
+		e.byte(typeNull)
+	case bool:
+		e.byte(typeBoolean)
+		e.bool(x)
+	case int8:
+		e.byte(typeSByte)
+		e.byte(byte(x))
+	case uint8:
+		e.byte(typeByte)
+		e.byte(x)
+	case int16:
+		e.byte(typeInt16)
+		e.uint16(uint16(x))
+	case uint16:
+		e.byte(typeUInt16)
+		e.uint16(x)
+	case int32:
+		e.byte(typeInt32)
+		e.int32(x)
+	case uint32:
+		e.byte(typeUInt32)
+		e.uint32(x)
+	case int64:
+		e.byte(typeInt64)
+		e.uint64(uint64(x))
+	case uint64:
+		e.byte(typeUInt64)
+		e.uint64(x)
+	case float32:
+		e.byte(typeFloat)
+		e.float32(x)
+	case float64:
+		e.byte(typeDouble)
+		e.float64(x)
+	case string:
+		e.byte(typeString)
+		e.string(x)
+	case time.Time:
+		e.byte(typeDateTime)
+		e.dateTime(x)
+	case []byte:
+		e.byte(typeByteString)
+		e.byteString(x)
+	case NodeID:
+		e.byte(typeNodeID)
+		e.nodeID(x)
+	case StatusCode:
+		e.byte(typeStatusCode)
+		e.uint32(uint32(x))
+	case QualifiedName:
+		e.byte(typeQualifiedName)
+		e.qualifiedName(x)
+	case LocalizedText:
+		e.byte(typeLocalizedText)
+		e.localizedText(x)
+	case ExtensionObject:
+		e.byte(typeExtensionObject)
+		e.extensionObject(x)
+	case []bool:
+		encodeArray(e, typeBoolean, x, e.bool)
+	case []int8:
+		encodeArray(e, typeSByte, x, func(v int8) { e.byte(byte(v)) })
+	case []int16:
+		encodeArray(e, typeInt16, x, func(v int16) { e.uint16(uint16(v)) })
+	case []uint16:
+		encodeArray(e, typeUInt16, x, e.uint16)
+	case []int32:
+		encodeArray(e, typeInt32, x, e.int32)
+	case []uint32:
+		encodeArray(e, typeUInt32, x, e.uint32)
+	case []int64:
+		encodeArray(e, typeInt64, x, func(v int64) { e.uint64(uint64(v)) })
+	case []uint64:
+		encodeArray(e, typeUInt64, x, e.uint64)
+	case []float32:
+		encodeArray(e, typeFloat, x, e.float32)
+	case []float64:
+		encodeArray(e, typeDouble, x, e.float64)
+	case []string:
+		encodeArray(e, typeString, x, e.string)
+	case []time.Time:
+		encodeArray(e, typeDateTime, x, e.dateTime)
+	case []LocalizedText:
+		encodeArray(e, typeLocalizedText, x, e.localizedText)
+	case []ExtensionObject:
+		encodeArray(e, typeExtensionObject, x, e.extensionObject)
+	case []interface{}:
+		// 元素类型不一的数组编码为 Variant 数组
+		encodeArray(e, typeVariant, x, e.variant)
+	default:
+		// 调用方保证只传入支持的类型，未知类型按字符串编码避免生成畸形报文
+		e.byte(typeString)
+		e.string(fmt.Sprint(x))
+	}
+}
+
+func encodeArray[T any](e *encoder, t byte, v []T, enc func(T)) {
+	e.byte(t | 0x80)
+	e.int32(int32(len(v)))
+	for _, x := range v {
+		enc(x)
+	}
+}
+
+// decoder 二进制解码，出错后后续读取均返回零值，由调用方检查 err
+type decoder struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func newDecoder(b []byte) *decoder { return &decoder{buf: b} }
+
+func (d *decoder) read(n int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	if n < 0 || d.pos+n > len(d.buf) {
+		d.err = errShortBuffer
+		return nil
+	}
+	b := d.buf[d.pos : d.pos+n]
+	d.pos += n
+	return b
+}
+
+func (d *decoder) fail(format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf("opcua: "+format, args...)
+	}
+}
+
+func (d *decoder) byte() byte {
+	if b := d.read(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (d *decoder) bool() bool { return d.byte() != 0 }
+
+func (d *decoder) uint16() uint16 {
+	if b := d.read(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (d *decoder) uint32() uint32 {
+	if b := d.read(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (d *decoder) uint64() uint64 {
+	if b := d.read(8); b != nil {
+		return binary.LittleEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (d *decoder) int32() int32     { return int32(d.uint32()) }
+func (d *decoder) float32() float32 { return math.Float32frombits(d.uint32()) }
+func (d *decoder) float64() float64 { return math.Float64frombits(d.uint64()) }
+
+// length 读取数组或字符串长度，-1 表示 null
+func (d *decoder) length() int {
+	n := d.int32()
+	if n < -1 || n > maxArrayLength*16 {
+		d.fail("invalid length %d", n)
+		return -1
+	}
+	return int(n)
+}
+
+func (d *decoder) string() string {
+	n := d.length()
+	if n <= 0 {
+		return ""
+	}
+	return string(d.read(n))
+}
+
+func (d *decoder) byteString() []byte {
+	n := d.length()
+	if n < 0 {
+		return nil
+	}
+	return append([]byte{}, d.read(n)...)
+}
+
+func (d *decoder) dateTime() time.Time {
+	v := int64(d.uint64())
+	if v <= 0 || v == math.MaxInt64 {
+		return time.Time{}
+	}
+	return epoch.Add(time.Duration(v) * 100)
+}
+
+func (d *decoder) guid() [16]byte {
+	var g [16]byte
+	copy(g[:], d.read(16))
+	return g
+}
+
+// arrayLength 读取数组长度，null 返回 -1
+func (d *decoder) arrayLength() int {
+	n := d.int32()
+	if n < -1 || n > maxArrayLength {
+		d.fail("invalid array length %d", n)
+		return -1
+	}
+	return int(n)
+}
+
+func (d *decoder) stringArray() []string {
+	n := d.arrayLength()
+	if n < 0 {
+		return nil
+	}
+	out := make([]string, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		out = append(out, d.string())
+	}
+	return out
+}
+
+func (d *decoder) uint32Array() []uint32 {
+	n := d.arrayLength()
+	if n < 0 {
+		return nil
+	}
+	out := make([]uint32, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		out = append(out, d.uint32())
+	}
+	return out
+}
+
+func (d *decoder) statusCodes() []StatusCode {
+	n := d.arrayLength()
+	if n < 0 {
+		return nil
+	}
+	out := make([]StatusCode, 0, n)
+	for i := 0; i < n && d.err == nil; i++ {
+		out = append(out, StatusCode(d.uint32()))
+	}
+	return out
+}
+
+func (d *decoder) qualifiedName() QualifiedName {
+	return QualifiedName{NamespaceIndex: d.uint16(), Name: d.string()}
+}
+
+func (d *decoder) localizedText() LocalizedText {
+	var t LocalizedText
+	mask := d.byte()
+	if mask&0x01 != 0 {
+		t.Locale = d.string()
+	}
+	if mask&0x02 != 0 {
+		t.Text = d.string()
+	}
+	return t
+}
+
+func (d *decoder) extensionObject() ExtensionObject {
+	x := ExtensionObject{TypeID: d.nodeID()}
+	switch d.byte() {
+	case 0:
+	case 1, 2:
+		x.Body = d.byteString()
+	default:
+		d.fail("invalid extension object encoding")
+	}
+	return x
+}
+
+// diagnosticInfo 跳过 DiagnosticInfo
+func (d *decoder) diagnosticInfo() {
+	for depth := 0; d.err == nil; depth++ {
+		if depth > 16 {
+			d.fail("diagnostic info nested too deep")
+			return
+		}
+		mask := d.byte()
+		for _, bit := range []byte{0x01, 0x02, 0x04} {
+			if mask&bit != 0 {
+				d.int32()
+			}
+		}
+		if mask&0x40 != 0 {
+			d.int32()
+		}
+		if mask&0x08 != 0 {
+			d.string()
+		}
+		if mask&0x10 != 0 {
+			d.uint32()
+		}
+		if mask&0x20 == 0 {
+			return
+		}
+	}
+}
+
+func (d *decoder) diagnosticInfos() {
+	n := d.arrayLength()
+	for i := 0; i < n && d.err == nil; i++ {
+		d.diagnosticInfo()
+	}
+}
+
+func (d *decoder) dataValue() DataValue {
+	var v DataValue
+	mask := d.byte()
+	if mask&0x01 != 0 {
+		v.Value = d.variant()
+	}
+	if mask&0x02 != 0 {
+		v.Status = StatusCode(d.uint32())
+	}
+	if mask&0x04 != 0 {
+		v.SourceTimestamp = d.dateTime()
+	}
+	if mask&0x10 != 0 {
+		d.uint16()
+	}
+	if mask&0x08 != 0 {
+		v.ServerTimestamp = d.dateTime()
+	}
+	if mask&0x20 != 0 {
+		d.uint16()
+	}
+	return v
+}
+
+// variant 解码 Variant：标量返回对应的 Go 类型，数组返回切片（多维数组按一维返回）
+func (d *decoder) variant() interface{} {
+	mask := d.byte()
+	t := mask & 0x3F
+	if mask&0x80 == 0 {
+		return d.scalar(t)
+	}
+	n := d.arrayLength()
+	var v interface{}
+	switch t {
+	case typeBoolean:
+		v = decodeArray(d, n, d.bool)
+	case typeSByte:
+		v = decodeArray(d, n, func() int8 { return int8(d.byte()) })
+	case typeByte:
+		v = decodeArray(d, n, d.byte)
+	case typeInt16:
+		v = decodeArray(d, n, func() int16 { return int16(d.uint16()) })
+	case typeUInt16:
+		v = decodeArray(d, n, d.uint16)
+	case typeInt32:
+		v = decodeArray(d, n, d.int32)
+	case typeUInt32:
+		v = decodeArray(d, n, d.uint32)
+	case typeInt64:
+		v = decodeArray(d, n, func() int64 { return int64(d.uint64()) })
+	case typeUInt64:
+		v = decodeArray(d, n, d.uint64)
+	case typeFloat:
+		v = decodeArray(d, n, d.float32)
+	case typeDouble:
+		v = decodeArray(d, n, d.float64)
+	case typeString:
+		v = decodeArray(d, n, d.string)
+	case typeDateTime:
+		v = decodeArray(d, n, d.dateTime)
+	default:
+		v = decodeArray(d, n, func() interface{} { return d.scalar(t) })
+	}
+	if mask&0x40 != 0 {
+		dims := d.arrayLength()
+		for i := 0; i < dims && d.err == nil; i++ {
+			d.int32()
+		}
+	}
+	return v
+}
+
+func decodeArray[T any](d *decoder, n int, dec func() T) []T {
+	if n < 0 {
+		return nil
+	}
+	out := make([]T, 0, min(n, 1024))
+	for i := 0; i < n && d.err == nil; i++ {
+		out = append(out, dec())
+	}
+	return out
+}
+
+func (d *decoder) scalar(t byte) interface{} {
+	switch t {
+	case typeNull:
+		return nil
+	case typeBoolean:
+		return d.bool()
+	case typeSByte:
+		return int8(d.byte())
+	case typeByte:
+		return d.byte()
+	case typeInt16:
+		return int16(d.uint16())
+	case typeUInt16:
+		return d.uint16()
+	case typeInt32:
+		return d.int32()
+	case typeUInt32:
+		return d.uint32()
+	case typeInt64:
+		return int64(d.uint64())
+	case typeUInt64:
+		return d.uint64()
+	case typeFloat:
+		return d.float32()
+	case typeDouble:
+		return d.float64()
+	case typeString, typeXMLElement:
+		return d.string()
+	case typeDateTime:
+		return d.dateTime()
+	case typeGuid:
+		return NodeID{Type: idGuid, Guid: d.guid()}.guidString()
+	case typeByteString:
+		return d.byteString()
+	case typeNodeID:
+		return d.nodeID()
+	case typeExpandedNodeID:
+		return d.expandedNodeID()
+	case typeStatusCode:
+		return StatusCode(d.uint32())
+	case typeQualifiedName:
+		return d.qualifiedName()
+	case typeLocalizedText:
+		return d.localizedText()
+	case typeExtensionObject:
+		return d.extensionObject()
+	case typeDataValue:
+		return d.dataValue()
+	case typeVariant:
+		return d.variant()
+	case typeDiagnosticInfo:
+		d.diagnosticInfo()
+		return nil
+	}
+	d.fail("unsupported variant type %d", t)
+	return nil
+}
@@ -0,0 +1,202 @@
+package opcua
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// NodeId 标识符类型
+const (
+	idNumeric byte = iota
+	idString
+	idGuid
+	idOpaque
+)
+
+// NodeID 节点标识，文本格式同 OPC UA 规范：ns=2;s=Line1.Temp、i=2258、ns=1;g=<guid>、ns=1;b=<base64>
+type NodeID struct {
+	Namespace uint16
+	Type      byte
+	Numeric   uint32
+	StringID  string
+	Guid      [16]byte // 二进制编码顺序（前三段小端）
+	Opaque    []byte
+}
+
+// NewNumericNodeID 数值型 NodeId
+func NewNumericNodeID(ns uint16, id uint32) NodeID {
+	return NodeID{Namespace: ns, Type: idNumeric, Numeric: id}
+}
+
+// NewStringNodeID 字符串型 NodeId
+func NewStringNodeID(ns uint16, id string) NodeID {
+	return NodeID{Namespace: ns, Type: idString, StringID: id}
+}
+
+// ParseNodeID 解析 NodeId 文本，省略 ns= 时命名空间为 0
+func ParseNodeID(s string) (NodeID, error) {
+	var id NodeID
+	rest := strings.TrimSpace(s)
+	if strings.HasPrefix(rest, "ns=") {
+		i := strings.Index(rest, ";")
+		if i < 0 {
+			return id, fmt.Errorf("opcua: invalid node id %q", s)
+		}
+		ns, err := strconv.ParseUint(rest[3:i], 10, 16)
+		if err != nil {
+			return id, fmt.Errorf("opcua: invalid namespace in node id %q", s)
+		}
+		id.Namespace, rest = uint16(ns), rest[i+1:]
+	}
+	if len(rest) < 2 || rest[1] != '=' {
+		return id, fmt.Errorf("opcua: invalid node id %q", s)
+	}
+	v := rest[2:]
+	switch rest[0] {
+	case 'i':
+		n, err := strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return id, fmt.Errorf("opcua: invalid numeric node id %q", s)
+		}
+		id.Type, id.Numeric = idNumeric, uint32(n)
+	case 's':
+		if v == "" {
+			return id, fmt.Errorf("opcua: empty string node id %q", s)
+		}
+		id.Type, id.StringID = idString, v
+	case 'g':
+		b, err := hex.DecodeString(strings.ReplaceAll(v, "-", ""))
+		if err != nil || len(b) != 16 {
+			return id, fmt.Errorf("opcua: invalid guid node id %q", s)
+		}
+		id.Type = idGuid
+		binary.LittleEndian.PutUint32(id.Guid[0:], binary.BigEndian.Uint32(b[0:]))
+		binary.LittleEndian.PutUint16(id.Guid[4:], binary.BigEndian.Uint16(b[4:]))
+		binary.LittleEndian.PutUint16(id.Guid[6:], binary.BigEndian.Uint16(b[6:]))
+		copy(id.Guid[8:], b[8:])
+	case 'b':
+		b, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return id, fmt.Errorf("opcua: invalid opaque node id %q", s)
+		}
+		id.Type, id.Opaque = idOpaque, b
+	default:
+		return id, fmt.Errorf("opcua: invalid node id %q", s)
+	}
+	return id, nil
+}
+
+// IsNull 是否为空 NodeId（ns=0;i=0）
+func (n NodeID) IsNull() bool {
+	return n.Namespace == 0 && n.Type == idNumeric && n.Numeric == 0
+}
+
+func (n NodeID) guidString() string {
+	g := n.Guid
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x", binary.LittleEndian.Uint32(g[0:]),
+		binary.LittleEndian.Uint16(g[4:]), binary.LittleEndian.Uint16(g[6:]), g[8:10], g[10:])
+}
+
+func (n NodeID) String() string {
+	var id string
+	switch n.Type {
+	case idString:
+		id = "s=" + n.StringID
+	case idGuid:
+		id = "g=" + n.guidString()
+	case idOpaque:
+		id = "b=" + base64.StdEncoding.EncodeToString(n.Opaque)
+	default:
+		id = "i=" + strconv.FormatUint(uint64(n.Numeric), 10)
+	}
+	if n.Namespace == 0 {
+		return id
+	}
+	return "ns=" + strconv.Itoa(int(n.Namespace)) + ";" + id
+}
+
+// Equal 比较两个 NodeId
+func (n NodeID) Equal(o NodeID) bool {
+	return n.String() == o.String()
+}
+
+func (e *encoder) nodeID(n NodeID) {
+	switch n.Type {
+	case idNumeric:
+		switch {
+		case n.Namespace == 0 && n.Numeric <= 0xFF:
+			e.byte(0x00)
+			e.byte(byte(n.Numeric))
+		case n.Namespace <= 0xFF && n.Numeric <= 0xFFFF:
+			e.byte(0x01)
+			e.byte(byte(n.Namespace))
+			e.uint16(uint16(n.Numeric))
+		default:
+			e.byte(0x02)
+			e.uint16(n.Namespace)
+			e.uint32(n.Numeric)
+		}
+	case idString:
+		e.byte(0x03)
+		e.uint16(n.Namespace)
+		e.string(n.StringID)
+	case idGuid:
+		e.byte(0x04)
+		e.uint16(n.Namespace)
+		e.guid(n.Guid)
+	case idOpaque:
+		e.byte(0x05)
+		e.uint16(n.Namespace)
+		e.byteString(n.Opaque)
+	}
+}
+
+func (d *decoder) nodeIDWithFlags() (NodeID, byte) {
+	var n NodeID
+	b := d.byte()
+	switch b & 0x0F {
+	case 0x00:
+		n.Numeric = uint32(d.byte())
+	case 0x01:
+		n.Namespace = uint16(d.byte())
+		n.Numeric = uint32(d.uint16())
+	case 0x02:
+		n.Namespace = d.uint16()
+		n.Numeric = d.uint32()
+	case 0x03:
+		n.Namespace, n.Type = d.uint16(), idString
+		n.StringID = d.string()
+	case 0x04:
+		n.Namespace, n.Type = d.uint16(), idGuid
+		n.Guid = d.guid()
+	case 0x05:
+		n.Namespace, n.Type = d.uint16(), idOpaque
+		n.Opaque = d.byteString()
+	default:
+		d.fail("invalid node id encoding %#x", b)
+	}
+	return n, b & 0xF0
+}
+
+func (d *decoder) nodeID() NodeID {
+	n, _ := d.nodeIDWithFlags()
+	return n
+}
+
+// expandedNodeID 解码 ExpandedNodeId，忽略命名空间 URI 和服务器索引
+func (d *decoder) expandedNodeID() NodeID {
+	n, flags := d.nodeIDWithFlags()
+	if flags&0x80 != 0 {
+		d.string()
+	}
+	if flags&0x40 != 0 {
+		d.uint32()
+	}
+	return n
+}
+
+func (e *encoder) expandedNodeID(n NodeID) { e.nodeID(n) }
@@ -0,0 +1,347 @@
+package opcua
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"sensor-edge/protocols"
+)
+
+// OPCUAClient OPC UA 客户端驱动。点位地址为 NodeId（如 ns=2;s=Line1.Temp），读取节点的 Value 属性；
+// 点位配置 subscribe: true 时创建监视项，变化通过 SetValueHandler 注册的回调上报
+type OPCUAClient struct {
+	conf deviceConfig
+	opts *clientOptions
+
+	lock sync.Mutex // 保护 conn 的建立和关闭
+	conn *client
+
+	pmu       sync.RWMutex
+	points    map[string]pointTable // 设备 ID -> 点位配置
+	dataTypes map[string]byte       // NodeId -> 写入时使用的内置类型
+
+	smu        sync.Mutex
+	handlers   map[string]func([]protocols.PointValue)
+	sub        *subscriptionState
+	subDirty   bool
+	publishing map[*client]bool
+}
+
+// pointTable 按地址和点位名索引的点位配置
+type pointTable map[string]protocols.PointConfig
+
+// Init 连接参数：
+//
+//	endpoint: opc.tcp://192.168.1.50:4840   # 或用 ip/port
+//	security_policy: Basic256Sha256         # None | Basic256Sha256 | Aes128_Sha256_RsaOaep
+//	security_mode: SignAndEncrypt           # None | Sign | SignAndEncrypt
+//	cert_file: ""                           # 应用实例证书和私钥，未配置时生成自签名证书
+//	key_file: ""
+//	server_cert: ""                         # 固定的服务端证书
+//	trusted_certs: ""                       # 受信任的服务端证书目录；安全策略不为 None 时二者至少配置一个，
+//	trust_any_server_cert: false            # 或显式接受任意服务端证书（不认证服务端）；用户名令牌加密密码时同样校验
+//	allow_plaintext_password: false         # 通道和用户名令牌都不加密时才需要，允许明文发送密码
+//	auth: {type: username, username: operator, password: "********"}  # anonymous | username | certificate
+//	timeout: 5000                           # 单次请求超时时间(毫秒)
+//	max_nodes_per_read: 100
+//	publishing_interval: 1000               # 订阅发布周期(毫秒)
+//	sampling_interval: 500                  # 监视项采样周期(毫秒)，点位可用 sampling_interval 单独配置
+//
+// Init 只校验配置和加载证书，首次读写时建立连接
+func (c *OPCUAClient) Init(config map[string]interface{}) error {
+	var conf deviceConfig
+	if err := decodeConfig(config, &conf); err != nil {
+		return fmt.Errorf("opcua: invalid config: %v", err)
+	}
+	opts, err := conf.clientOptions()
+	if err != nil {
+		return err
+	}
+	if conf.MaxNodesPerRead <= 0 {
+		conf.MaxNodesPerRead = 100
+	}
+	if conf.SamplingInterval <= 0 {
+		conf.SamplingInterval = 500
+	}
+	c.conf, c.opts = conf, opts
+	c.dataTypes = make(map[string]byte)
+	c.publishing = make(map[*client]bool)
+	return nil
+}
+
+// session 返回可用的会话，断开时重新连接并重建订阅
+func (c *OPCUAClient) session() (*client, error) {
+	c.lock.Lock()
+	conn := c.conn
+	if conn == nil || !conn.alive() {
+		if conn != nil {
+			conn.close()
+		}
+		var err error
+		if conn, err = dial(c.opts); err != nil {
+			c.conn = nil
+			c.lock.Unlock()
+			return nil, err
+		}
+		c.conn = conn
+	}
+	c.lock.Unlock()
+	c.syncSubscriptions(conn)
+	return conn, nil
+}
+
+// checkSession 会话失效（如服务端重启后会话 ID 无效）时关闭连接，下次请求重新建立
+func (c *OPCUAClient) checkSession(conn *client, err error) {
+	var s StatusCode
+	if !errors.As(err, &s) {
+		return
+	}
+	switch s {
+	case StatusBadSessionIDInvalid, StatusBadSessionClosed, StatusBadSessionNotActivated, StatusBadSecureChannelIDInvalid:
+		conn.fail(err)
+	}
+}
+
+// SetPointConfigs 记录设备的点位配置
+func (c *OPCUAClient) SetPointConfigs(deviceID string, points []protocols.PointConfig) {
+	table := make(pointTable, len(points)*2)
+	for _, p := range points {
+		if p.Address != "" {
+			table[p.Address] = p
+		}
+		if p.PointID != "" {
+			table[p.PointID] = p
+		}
+	}
+	c.pmu.Lock()
+	if c.points == nil {
+		c.points = make(map[string]pointTable)
+	}
+	c.points[deviceID] = table
+	c.pmu.Unlock()
+	c.smu.Lock()
+	c.subDirty = true
+	c.smu.Unlock()
+}
+
+// SetValueHandler 注册监视项通知的回调（实现 protocols.PointSubscriber），回调的 PointID 为点位名。
+// 订阅在下一次读取时建立
+func (c *OPCUAClient) SetValueHandler(deviceID string, handler func(values []protocols.PointValue)) {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	if c.handlers == nil {
+		c.handlers = make(map[string]func([]protocols.PointValue))
+	}
+	c.handlers[deviceID] = handler
+	c.subDirty = true
+}
+
+// Read 读取设备配置的全部点位，PointID 为点位名
+func (c *OPCUAClient) Read(deviceID string) ([]protocols.PointValue, error) {
+	c.pmu.RLock()
+	names := make(map[string]string)
+	for key, p := range c.points[deviceID] {
+		if key == p.PointID {
+			names[p.PointID] = p.Address
+		}
+	}
+	c.pmu.RUnlock()
+	ids := make([]string, 0, len(names))
+	for id := range names {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	addrs := make([]string, len(ids))
+	for i, id := range ids {
+		addrs[i] = names[id]
+	}
+	values, err := c.readNodes(addrs)
+	for i := range values {
+		values[i].PointID = ids[i]
+	}
+	return values, err
+}
+
+// ReadBatch 按 max_nodes_per_read 分批读取节点的 Value 属性，PointID 为点位地址（NodeId）。
+// 订阅有效且已收到通知的点位直接返回通知值；状态码为 Bad 的节点质量为 bad:<状态名>
+func (c *OPCUAClient) ReadBatch(deviceID string, function string, points []string) ([]protocols.PointValue, error) {
+	if len(points) == 0 {
+		return nil, nil
+	}
+	return c.readNodes(points)
+}
+
+func (c *OPCUAClient) readNodes(addrs []string) ([]protocols.PointValue, error) {
+	values := make([]protocols.PointValue, len(addrs))
+	for i, addr := range addrs {
+		values[i] = badValue(addr)
+	}
+	conn, err := c.session()
+	if err != nil {
+		return values, err
+	}
+	var nodes []readValueID
+	var index []int
+	for i, addr := range addrs {
+		if v, ok := c.cachedValue(conn, addr); ok {
+			values[i] = v
+			continue
+		}
+		node, err := ParseNodeID(addr)
+		if err != nil {
+			values[i].Quality = "bad:" + statusName(StatusBadNodeIDInvalid)
+			continue
+		}
+		nodes = append(nodes, readValueID{NodeID: node, AttributeID: attrValue})
+		index = append(index, i)
+	}
+	for start := 0; start < len(nodes); start += c.conf.MaxNodesPerRead {
+		end := min(start+c.conf.MaxNodesPerRead, len(nodes))
+		results, err := conn.read(nodes[start:end])
+		if err != nil {
+			c.checkSession(conn, err)
+			return values, err
+		}
+		for j, dv := range results {
+			i := index[start+j]
+			values[i] = dataValuePoint(addrs[i], dv)
+		}
+	}
+	return values, nil
+}
+
+// dataValuePoint 把 DataValue 转换为 PointValue，时间戳优先取源时间戳
+func dataValuePoint(id string, dv DataValue) protocols.PointValue {
+	ts := dv.SourceTimestamp
+	if ts.IsZero() {
+		ts = dv.ServerTimestamp
+	}
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+	pv := protocols.PointValue{PointID: id, Timestamp: ts.Unix()}
+	switch {
+	case dv.Status.IsBad():
+		pv.Quality = "bad:" + statusName(dv.Status)
+	case dv.Status.IsUncertain():
+		pv.Value, pv.Quality = pointValueOf(dv.Value), "uncertain"
+	default:
+		pv.Value, pv.Quality = pointValueOf(dv.Value), "good"
+	}
+	return pv
+}
+
+// Write 写入节点的 Value 属性。point 为点位地址、点位名或 NodeId；写入值按节点的 DataType 转换，
+// DataType 不是内置类型时按当前值的类型转换
+func (c *OPCUAClient) Write(point string, value interface{}) error {
+	addr := point
+	c.pmu.RLock()
+	for _, table := range c.points {
+		if p, ok := table[point]; ok {
+			addr = p.Address
+			break
+		}
+	}
+	c.pmu.RUnlock()
+	node, err := ParseNodeID(addr)
+	if err != nil {
+		return fmt.Errorf("opcua: point %s: %v", point, err)
+	}
+	conn, err := c.session()
+	if err != nil {
+		return err
+	}
+	t, err := c.dataType(conn, node)
+	if err != nil {
+		c.checkSession(conn, err)
+		return fmt.Errorf("opcua: point %s: %w", point, err)
+	}
+	v, err := convertValue(value, t)
+	if err != nil {
+		return fmt.Errorf("opcua: point %s: %v", point, err)
+	}
+	if err := conn.write(node, v); err != nil {
+		if errors.Is(err, StatusBadTypeMismatch) {
+			c.pmu.Lock()
+			delete(c.dataTypes, node.String())
+			c.pmu.Unlock()
+		}
+		c.checkSession(conn, err)
+		return fmt.Errorf("opcua: write %s: %w", point, err)
+	}
+	return nil
+}
+
+// dataType 读取并缓存节点的内置数据类型
+func (c *OPCUAClient) dataType(conn *client, node NodeID) (byte, error) {
+	key := node.String()
+	c.pmu.RLock()
+	t, ok := c.dataTypes[key]
+	c.pmu.RUnlock()
+	if ok {
+		return t, nil
+	}
+	results, err := conn.read([]readValueID{{NodeID: node, AttributeID: attrDataType}, {NodeID: node, AttributeID: attrValue}})
+	if err != nil {
+		return 0, err
+	}
+	if results[0].Status.IsBad() {
+		return 0, results[0].Status
+	}
+	dt, _ := results[0].Value.(NodeID)
+	if t, ok = builtinType(dt); !ok {
+		if results[1].Status.IsBad() || results[1].Value == nil {
+			return 0, fmt.Errorf("unsupported data type %s", dt)
+		}
+		t = variantType(results[1].Value)
+	}
+	c.pmu.Lock()
+	c.dataTypes[key] = t
+	c.pmu.Unlock()
+	return t, nil
+}
+
+// Close 关闭会话和安全通道
+func (c *OPCUAClient) Close() error {
+	c.lock.Lock()
+	conn := c.conn
+	c.conn = nil
+	c.lock.Unlock()
+	if conn != nil {
+		conn.close()
+	}
+	return nil
+}
+
+// Reconnect 关闭并重新建立会话，订阅随之重建
+func (c *OPCUAClient) Reconnect() error {
+	c.Close()
+	_, err := c.session()
+	return err
+}
+
+func badValue(id string) protocols.PointValue {
+	return protocols.PointValue{PointID: id, Value: nil, Quality: "bad", Timestamp: time.Now().Unix()}
+}
+
+// optionInt 点位选项中的整数
+func optionInt(options map[string]interface{}, key string) (int, bool) {
+	switch v := options[key].(type) {
+	case int:
+		return v, true
+	case float64:
+		return int(v), true
+	}
+	return 0, false
+}
+
+func NewOPCUAClient() protocols.Protocol {
+	return &OPCUAClient{}
+}
+
+func init() {
+	protocols.Register("opcua", NewOPCUAClient)
+}
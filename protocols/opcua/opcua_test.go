@@ -0,0 +1,640 @@
+package opcua
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"sensor-edge/protocols"
+)
+
+func TestNodeID(t *testing.T) {
+	for _, s := range []string{"i=2258", "ns=2;s=Line1.Temp", "ns=1;i=70000", "ns=3;g=72962b91-fa75-4ae6-8d28-b404dc7daf63", "ns=1;b=AQID"} {
+		id, err := ParseNodeID(s)
+		if err != nil {
+			t.Fatalf("%s: %v", s, err)
+		}
+		if id.String() != s {
+			t.Errorf("%s: formatted as %s", s, id)
+		}
+		var e encoder
+		e.nodeID(id)
+		if got := newDecoder(e.bytes()).nodeID(); !got.Equal(id) {
+			t.Errorf("%s: decoded as %s", s, got)
+		}
+	}
+	for _, s := range []string{"", "ns=2", "x=1", "ns=a;i=1", "i=abc", "ns=1;g=1234"} {
+		if _, err := ParseNodeID(s); err == nil {
+			t.Errorf("%q: expect error", s)
+		}
+	}
+}
+
+func TestConvertValue(t *testing.T) {
+	cases := []struct {
+		v    interface{}
+		t    byte
+		want interface{}
+	}{
+		{42, typeDouble, 42.0},
+		{"42", typeInt16, int16(42)},
+		{23.0, typeUInt32, uint32(23)},
+		{1, typeBoolean, true},
+		{"off", typeBoolean, false},
+		{3.5, typeFloat, float32(3.5)},
+		{12, typeString, "12"},
+		{[]interface{}{1, 2}, typeInt32, []int32{1, 2}},
+		{"01 ff", typeByteString, []byte{1, 0xff}},
+	}
+	for _, c := range cases {
+		got, err := convertValue(c.v, c.t)
+		if err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("convert %v to %d: got %#v, %v; want %#v", c.v, c.t, got, err, c.want)
+		}
+	}
+	for _, c := range []struct {
+		v interface{}
+		t byte
+	}{{70000, typeInt16}, {-1, typeUInt32}, {1.5, typeInt32}, {"abc", typeDouble}, {"yes?", typeBoolean}} {
+		if _, err := convertValue(c.v, c.t); err == nil {
+			t.Errorf("convert %v to %d: expect error", c.v, c.t)
+		}
+	}
+}
+
+// simNode 模拟服务端的变量节点
+type simNode struct {
+	dataType NodeID
+	value    DataValue
+}
+
+// simServer 进程内的 OPC UA 模拟服务端：提供 None、Basic256Sha256（Sign/SignAndEncrypt）和
+// Aes128_Sha256_RsaOaep（SignAndEncrypt）端点，匿名、用户名（operator/secret）和证书身份
+type simServer struct {
+	ln       net.Listener
+	url      string
+	cert     []byte
+	key      *rsa.PrivateKey
+	lifetime uint32 // 安全令牌生命周期(毫秒)
+
+	mu        sync.Mutex
+	nodes     map[string]*simNode
+	userCert  []byte
+	renewals  int
+	channelID uint32
+}
+
+func newSimServer(t *testing.T) *simServer {
+	cert, key, err := generateCertificate("urn:sim:server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &simServer{ln: ln, url: "opc.tcp://" + ln.Addr().String(), cert: cert, key: key, lifetime: 60000, nodes: map[string]*simNode{
+		"ns=2;s=Temp":    {NewNumericNodeID(0, uint32(typeDouble)), DataValue{Value: 21.5}},
+		"ns=2;i=1001":    {NewNumericNodeID(0, uint32(typeInt16)), DataValue{Value: int16(-3)}},
+		"ns=2;s=Running": {NewNumericNodeID(0, uint32(typeBoolean)), DataValue{Value: true}},
+		"ns=2;s=Name":    {NewNumericNodeID(0, uint32(typeLocalizedText)), DataValue{Value: LocalizedText{Text: "Line 1"}}},
+		"ns=2;s=Mode":    {NewNumericNodeID(2, 3000), DataValue{Value: uint32(1)}}, // 自定义枚举类型，按当前值类型写入
+		"ns=2;s=Fault":   {NewNumericNodeID(0, uint32(typeDouble)), DataValue{Status: StatusBadNoCommunication}},
+	}}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *simServer) set(node string, v interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nodes[node].value = DataValue{Value: v, SourceTimestamp: time.Now()}
+}
+
+func (s *simServer) get(node string) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nodes[node].value.Value
+}
+
+func (s *simServer) endpoints() []endpointDescription {
+	tokens := []userTokenPolicy{
+		{PolicyID: "anonymous", TokenType: tokenAnonymous},
+		{PolicyID: "username", TokenType: tokenUserName, SecurityPolicyURI: PolicyBasic256Sha256},
+		{PolicyID: "certificate", TokenType: tokenCertificate, SecurityPolicyURI: PolicyBasic256Sha256},
+	}
+	app := applicationDescription{ApplicationURI: "urn:sim:server", ApplicationName: LocalizedText{Text: "sim"}}
+	var eps []endpointDescription
+	for _, p := range []struct {
+		uri  string
+		mode uint32
+	}{
+		{PolicyNone, securityModeNone},
+		{PolicyBasic256Sha256, securityModeSign},
+		{PolicyBasic256Sha256, securityModeSignAndEncrypt},
+		{PolicyAes128Sha256RsaOaep, securityModeSignAndEncrypt},
+	} {
+		eps = append(eps, endpointDescription{EndpointURL: s.url, Server: app, ServerCertificate: s.cert,
+			SecurityMode: p.mode, SecurityPolicyURI: p.uri, UserIdentityTokens: tokens})
+	}
+	return eps
+}
+
+// simSession 一个连接上的会话状态
+type simSession struct {
+	ch          *secureChannel
+	authToken   NodeID
+	nonce       []byte
+	activated   bool
+	subID       uint32
+	interval    time.Duration
+	items       map[uint32]string // clientHandle -> NodeId
+	sent        map[uint32]DataValue
+	clientCert  []byte
+	clientNonce []byte
+}
+
+func (s *simServer) serve(conn net.Conn) {
+	defer conn.Close()
+	ch := newSecureChannel(conn, true)
+	ch.localCert, ch.localKey = s.cert, s.key
+	b, err := readChunk(conn, defaultBufferSize)
+	if err != nil || string(b[:3]) != msgHello {
+		return
+	}
+	h, err := decodeHello(b[8:], false)
+	if err != nil {
+		return
+	}
+	ch.sendBufferSize = min(h.ReceiveBufferSize, defaultBufferSize)
+	ack := hello{ReceiveBufferSize: defaultBufferSize, SendBufferSize: defaultBufferSize, MaxMessageSize: maxMessageSize}
+	writeRaw(conn, msgAcknowledge, ack.encode(true))
+	sess := &simSession{ch: ch}
+	for {
+		msgType, id, body, err := ch.readMessage()
+		if err != nil {
+			return
+		}
+		m, err := decodeMessage(body)
+		if err != nil {
+			writeError(conn, StatusBadDecodingError, err.Error())
+			return
+		}
+		switch req := m.(type) {
+		case *openSecureChannelRequest:
+			if msgType != msgOpen || !s.open(ch, id, req) {
+				return
+			}
+		case *closeSecureChannelRequest:
+			return
+		case *publishRequest:
+			go s.publish(sess, id, req)
+		case request:
+			ch.writeMessage(msgMessage, id, encodeMessage(s.handle(sess, req)))
+		}
+	}
+}
+
+func (s *simServer) open(ch *secureChannel, id uint32, req *openSecureChannelRequest) bool {
+	if req.RequestType == 0 {
+		valid := false
+		for _, ep := range s.endpoints() {
+			valid = valid || ep.SecurityPolicyURI == ch.policy.uri && ep.SecurityMode == req.SecurityMode
+		}
+		if !valid {
+			writeError(ch.conn, StatusBadSecurityModeRejected, "")
+			return false
+		}
+		s.mu.Lock()
+		s.channelID++
+		ch.channelID = s.channelID
+		s.mu.Unlock()
+		ch.mode = req.SecurityMode
+	} else {
+		s.mu.Lock()
+		s.renewals++
+		s.mu.Unlock()
+	}
+	nonce, _ := ch.policy.nonce()
+	ch.mu.Lock()
+	tokenID := ch.latest + 1
+	ch.mu.Unlock()
+	resp := &openSecureChannelResponse{
+		Header:        responseHeader{Timestamp: time.Now(), RequestHandle: req.Header.RequestHandle},
+		SecurityToken: channelSecurityToken{ChannelID: ch.channelID, TokenID: tokenID, CreatedAt: time.Now(), RevisedLifetime: s.lifetime},
+		ServerNonce:   nonce,
+	}
+	if err := ch.writeMessage(msgOpen, id, encodeMessage(resp)); err != nil {
+		return false
+	}
+	ch.installToken(tokenID, nonce, req.ClientNonce)
+	return true
+}
+
+func fault(req request, status StatusCode) response {
+	return &serviceFault{Header: responseHeader{Timestamp: time.Now(), RequestHandle: req.header().RequestHandle, ServiceResult: status}}
+}
+
+func (s *simServer) handle(sess *simSession, req request) response {
+	h := responseHeader{Timestamp: time.Now(), RequestHandle: req.header().RequestHandle}
+	switch r := req.(type) {
+	case *getEndpointsRequest:
+		return &getEndpointsResponse{Header: h, Endpoints: s.endpoints()}
+	case *createSessionRequest:
+		sess.nonce, _ = randomNonce(32)
+		sess.authToken = NewNumericNodeID(1, uint32(time.Now().UnixNano()))
+		sess.clientCert, sess.clientNonce = r.ClientCertificate, r.ClientNonce
+		resp := &createSessionResponse{Header: h, SessionID: NewNumericNodeID(1, 1), AuthenticationToken: sess.authToken,
+			RevisedSessionTimeout: r.RequestedSessionTimeout, ServerNonce: sess.nonce, ServerCertificate: s.cert, ServerEndpoints: s.endpoints()}
+		if !sess.ch.policy.isNone() {
+			sig, _ := asymSign(s.key, append(append([]byte(nil), r.ClientCertificate...), r.ClientNonce...))
+			resp.ServerSignature = signatureData{Algorithm: algorithmRsaSha256, Signature: sig}
+		}
+		return resp
+	}
+	if sess.authToken.IsNull() || !req.header().AuthenticationToken.Equal(sess.authToken) {
+		return fault(req, StatusBadSessionIDInvalid)
+	}
+	if r, ok := req.(*activateSessionRequest); ok {
+		if status := s.activate(sess, r); status != StatusGood {
+			return fault(req, status)
+		}
+		sess.activated = true
+		sess.nonce, _ = randomNonce(32)
+		return &activateSessionResponse{Header: h, ServerNonce: sess.nonce}
+	}
+	if !sess.activated {
+		return fault(req, StatusBadSessionNotActivated)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r := req.(type) {
+	case *readRequest:
+		resp := &readResponse{Header: h}
+		for _, rv := range r.NodesToRead {
+			n, ok := s.nodes[rv.NodeID.String()]
+			switch {
+			case !ok:
+				resp.Results = append(resp.Results, DataValue{Status: StatusBadNodeIDUnknown})
+			case rv.AttributeID == attrValue:
+				resp.Results = append(resp.Results, n.value)
+			case rv.AttributeID == attrDataType:
+				resp.Results = append(resp.Results, DataValue{Value: n.dataType})
+			default:
+				resp.Results = append(resp.Results, DataValue{Status: StatusBadAttributeIDInvalid})
+			}
+		}
+		return resp
+	case *writeRequest:
+		resp := &writeResponse{Header: h}
+		for _, w := range r.NodesToWrite {
+			n, ok := s.nodes[w.NodeID.String()]
+			switch {
+			case !ok:
+				resp.Results = append(resp.Results, StatusBadNodeIDUnknown)
+			case variantType(w.Value.Value) != variantType(n.value.Value) && n.value.Value != nil:
+				resp.Results = append(resp.Results, StatusBadTypeMismatch)
+			default:
+				n.value = DataValue{Value: w.Value.Value, SourceTimestamp: time.Now()}
+				resp.Results = append(resp.Results, StatusGood)
+			}
+		}
+		return resp
+	case *createSubscriptionRequest:
+		sess.subID++
+		sess.interval = time.Duration(r.RequestedPublishingInterval) * time.Millisecond
+		sess.items, sess.sent = make(map[uint32]string), make(map[uint32]DataValue)
+		return &createSubscriptionResponse{Header: h, SubscriptionID: sess.subID, RevisedPublishingInterval: r.RequestedPublishingInterval,
+			RevisedLifetimeCount: r.RequestedLifetimeCount, RevisedMaxKeepAliveCount: r.RequestedMaxKeepAliveCount}
+	case *createMonitoredItemsRequest:
+		if r.SubscriptionID != sess.subID {
+			return fault(req, StatusBadSubscriptionIDInvalid)
+		}
+		resp := &createMonitoredItemsResponse{Header: h}
+		for i, it := range r.ItemsToCreate {
+			node := it.ItemToMonitor.NodeID.String()
+			if _, ok := s.nodes[node]; !ok {
+				resp.Results = append(resp.Results, monitoredItemCreateResult{StatusCode: StatusBadNodeIDUnknown})
+				continue
+			}
+			sess.items[it.ClientHandle] = node
+			resp.Results = append(resp.Results, monitoredItemCreateResult{MonitoredItemID: uint32(i + 1), RevisedSamplingInterval: it.SamplingInterval, RevisedQueueSize: 1})
+		}
+		return resp
+	case *deleteSubscriptionsRequest:
+		sess.items = nil
+		return &deleteSubscriptionsResponse{Header: h, Results: make([]StatusCode, len(r.SubscriptionIDs))}
+	case *closeSessionRequest:
+		sess.activated = false
+		return &closeSessionResponse{Header: h}
+	}
+	return fault(req, StatusBadServiceUnsupported)
+}
+
+func (s *simServer) activate(sess *simSession, r *activateSessionRequest) StatusCode {
+	if !sess.ch.policy.isNone() {
+		data := append(append([]byte(nil), s.cert...), sess.nonce...)
+		if asymVerify(sess.ch.remoteKey, data, r.ClientSignature.Signature) != nil {
+			return StatusBadApplicationSignatureInvalid
+		}
+	}
+	switch r.UserIdentityToken.TypeID.Numeric {
+	case idAnonymousIdentityToken:
+		return StatusGood
+	case idUserNameIdentityToken:
+		var tok userNameIdentityToken
+		decodeObject(r.UserIdentityToken, &tok)
+		if tok.EncryptionAlgorithm != algorithmRsaOaep {
+			return StatusBadIdentityTokenInvalid
+		}
+		password, err := decryptPassword(s.key, tok.Password, sess.nonce)
+		if err != nil || tok.UserName != "operator" || password != "secret" {
+			return StatusBadUserAccessDenied
+		}
+		return StatusGood
+	case idX509IdentityToken:
+		var tok x509IdentityToken
+		decodeObject(r.UserIdentityToken, &tok)
+		pub, err := publicKey(tok.CertificateData)
+		if err != nil {
+			return StatusBadIdentityTokenInvalid
+		}
+		if asymVerify(pub, append(append([]byte(nil), s.cert...), sess.nonce...), r.UserTokenSignature.Signature) != nil {
+			return StatusBadUserSignatureInvalid
+		}
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if !bytes.Equal(tok.CertificateData, s.userCert) {
+			return StatusBadIdentityTokenRejected
+		}
+		return StatusGood
+	}
+	return StatusBadIdentityTokenInvalid
+}
+
+// publish 每个发布周期检查监视项的值，有变化时返回通知，否则在保活次数后返回空的保活响应
+func (s *simServer) publish(sess *simSession, id uint32, req *publishRequest) {
+	h := responseHeader{RequestHandle: req.Header.RequestHandle}
+	for i := 0; i < maxKeepAliveCount; i++ {
+		s.mu.Lock()
+		interval, subID := sess.interval, sess.subID
+		var n dataChangeNotification
+		for handle, node := range sess.items {
+			v := s.nodes[node].value
+			if last, ok := sess.sent[handle]; !ok || !reflect.DeepEqual(last, v) {
+				sess.sent[handle] = v
+				n.MonitoredItems = append(n.MonitoredItems, monitoredItemNotification{ClientHandle: handle, Value: v})
+			}
+		}
+		items := sess.items
+		s.mu.Unlock()
+		if items == nil {
+			h.Timestamp, h.ServiceResult = time.Now(), StatusBadNoSubscription
+			sess.ch.writeMessage(msgMessage, id, encodeMessage(&serviceFault{Header: h}))
+			return
+		}
+		if len(n.MonitoredItems) > 0 {
+			var e encoder
+			n.encode(&e)
+			h.Timestamp = time.Now()
+			resp := &publishResponse{Header: h, SubscriptionID: subID, NotificationMessage: notificationMessage{
+				SequenceNumber: uint32(time.Now().UnixNano()), PublishTime: time.Now(),
+				NotificationData: []ExtensionObject{{TypeID: NewNumericNodeID(0, idDataChangeNotification), Body: e.bytes()}},
+			}}
+			sess.ch.writeMessage(msgMessage, id, encodeMessage(resp))
+			return
+		}
+		time.Sleep(interval)
+	}
+	h.Timestamp = time.Now()
+	sess.ch.writeMessage(msgMessage, id, encodeMessage(&publishResponse{Header: h, SubscriptionID: sess.subID}))
+}
+
+// writeKeyPair 生成用户证书并写入 PEM 文件
+func writeKeyPair(t *testing.T) (certFile, keyFile string, cert []byte) {
+	cert, key, err := generateCertificate("urn:sim:user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "user.pem"), filepath.Join(dir, "user.key")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600)
+	return certFile, keyFile, cert
+}
+
+var simPoints = []protocols.PointConfig{
+	{PointID: "temp", Address: "ns=2;s=Temp"},
+	{PointID: "count", Address: "ns=2;i=1001"},
+	{PointID: "running", Address: "ns=2;s=Running"},
+	{PointID: "name", Address: "ns=2;s=Name"},
+	{PointID: "mode", Address: "ns=2;s=Mode"},
+}
+
+func TestClientSecurityModes(t *testing.T) {
+	sim := newSimServer(t)
+	userCertFile, userKeyFile, userCert := writeKeyPair(t)
+	sim.userCert = userCert
+	trustDir := t.TempDir()
+	serverCertFile := filepath.Join(trustDir, "server.der")
+	if err := os.WriteFile(serverCertFile, sim.cert, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name   string
+		config map[string]interface{}
+	}{
+		{"None/anonymous", map[string]interface{}{}},
+		{"None/username", map[string]interface{}{"server_cert": serverCertFile, // 用户名令牌策略 Basic256Sha256，按服务端证书加密密码
+			"auth": map[string]interface{}{"type": "username", "username": "operator", "password": "secret"}}},
+		{"Sign/username", map[string]interface{}{"security_policy": "Basic256Sha256", "security_mode": "Sign", "server_cert": serverCertFile,
+			"auth": map[string]interface{}{"type": "username", "username": "operator", "password": "secret"}}},
+		{"SignAndEncrypt/certificate", map[string]interface{}{"security_policy": "Aes128_Sha256_RsaOaep", "trusted_certs": trustDir,
+			"auth": map[string]interface{}{"type": "certificate", "cert_file": userCertFile, "key_file": userKeyFile}}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.config["endpoint"] = sim.url
+			tc.config["timeout"] = 2000
+			tc.config["max_nodes_per_read"] = 2
+			c := &OPCUAClient{}
+			if err := c.Init(tc.config); err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			c.SetPointConfigs("line1", simPoints)
+
+			sim.set("ns=2;s=Temp", 21.5)
+			sim.set("ns=2;i=1001", int16(-3))
+			values, err := c.ReadBatch("line1", "", []string{"ns=2;s=Temp", "ns=2;i=1001", "ns=2;s=Name", "ns=2;s=Fault", "ns=2;s=Missing", "bad id"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []string{"21.5 good", "-3 good", "Line 1 good", "<nil> bad:BadNoCommunication", "<nil> bad:BadNodeIdUnknown", "<nil> bad:BadNodeIdInvalid"}
+			for i, v := range values {
+				if got := fmt.Sprint(v.Value, " ", v.Quality); got != want[i] {
+					t.Errorf("%s = %s, want %s", v.PointID, got, want[i])
+				}
+			}
+
+			// 写入值按节点数据类型转换
+			for _, w := range []struct {
+				point string
+				value interface{}
+				node  string
+				want  interface{}
+			}{
+				{"temp", 42, "ns=2;s=Temp", 42.0},
+				{"count", "17", "ns=2;i=1001", int16(17)},
+				{"ns=2;s=Running", 0, "ns=2;s=Running", false},
+				{"mode", 2.0, "ns=2;s=Mode", uint32(2)},
+			} {
+				if err := c.Write(w.point, w.value); err != nil {
+					t.Fatalf("write %s: %v", w.point, err)
+				}
+				if got := sim.get(w.node); got != w.want {
+					t.Errorf("write %s: server has %#v, want %#v", w.point, got, w.want)
+				}
+			}
+			if err := c.Write("count", 70000); err == nil {
+				t.Error("expect out of range error")
+			}
+			var s StatusCode
+			if err := c.Write("ns=2;s=Missing", 1); !errors.As(err, &s) || s != StatusBadNodeIDUnknown {
+				t.Errorf("write missing node: %v", err)
+			}
+
+			values, err = c.Read("line1")
+			if err != nil || len(values) != 5 || values[0].PointID != "count" || values[0].Value != int16(17) {
+				t.Errorf("Read: %+v, %v", values, err)
+			}
+		})
+	}
+
+	bad := []map[string]interface{}{
+		{"endpoint": sim.url, "security_policy": "Basic256Sha256", "security_mode": "SignAndEncrypt", "trust_any_server_cert": true,
+			"auth": map[string]interface{}{"type": "username", "username": "operator", "password": "wrong"}},
+		{"endpoint": sim.url, "auth": map[string]interface{}{"type": "certificate"}},                                            // 未受信任的证书
+		{"endpoint": sim.url, "security_policy": "Basic256Sha256", "server_cert": userCertFile},                                 // 服务端证书不一致
+		{"endpoint": sim.url, "auth": map[string]interface{}{"type": "username", "username": "operator", "password": "secret"}}, // None 通道上不向未受信任的证书加密密码
+	}
+	for _, config := range bad {
+		c := &OPCUAClient{}
+		if err := c.Init(config); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.ReadBatch("line1", "", []string{"ns=2;s=Temp"}); err == nil {
+			t.Errorf("%v: expect activation error", config["auth"])
+		}
+		c.Close()
+	}
+	for _, config := range []map[string]interface{}{
+		{},
+		{"endpoint": "http://127.0.0.1"},
+		{"ip": "127.0.0.1", "security_policy": "Basic128Rsa15"},
+		{"ip": "127.0.0.1", "security_policy": "None", "security_mode": "Sign"},
+		{"ip": "127.0.0.1", "security_policy": "Basic256Sha256"}, // 未配置受信任的服务端证书
+		{"ip": "127.0.0.1", "security_policy": "Basic256Sha256", "trusted_certs": t.TempDir()},
+		{"ip": "127.0.0.1", "auth": map[string]interface{}{"type": "kerberos"}},
+	} {
+		c := &OPCUAClient{}
+		err := c.Init(config)
+		if err == nil {
+			_, err = c.ReadBatch("x", "", []string{"i=1"})
+		}
+		if err == nil {
+			t.Errorf("%v: expect error", config)
+		}
+	}
+}
+
+func TestSubscriptionAndRenew(t *testing.T) {
+	sim := newSimServer(t)
+	sim.lifetime = 400 // 300 毫秒后续订安全令牌
+	c := &OPCUAClient{}
+	err := c.Init(map[string]interface{}{
+		"endpoint": sim.url, "security_policy": "Basic256Sha256", "security_mode": "SignAndEncrypt", "trust_any_server_cert": true,
+		"publishing_interval": 50, "timeout": 2000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	pushed := make(chan protocols.PointValue, 16)
+	c.SetValueHandler("line1", func(values []protocols.PointValue) {
+		for _, v := range values {
+			pushed <- v
+		}
+	})
+	c.SetPointConfigs("line1", []protocols.PointConfig{
+		{PointID: "temp", Address: "ns=2;s=Temp", Options: map[string]interface{}{"subscribe": true}},
+		{PointID: "missing", Address: "ns=2;s=Missing", Options: map[string]interface{}{"subscribe": true}},
+		{PointID: "count", Address: "ns=2;i=1001"},
+	})
+	expectPush := func(want float64) {
+		t.Helper()
+		select {
+		case v := <-pushed:
+			if v.PointID != "temp" || v.Value != want || v.Quality != "good" {
+				t.Errorf("pushed %+v, want temp = %v", v, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no notification for %v", want)
+		}
+	}
+	if _, err := c.ReadBatch("line1", "", []string{"ns=2;i=1001"}); err != nil {
+		t.Fatal(err)
+	}
+	expectPush(21.5)
+	sim.set("ns=2;s=Temp", 22.75)
+	expectPush(22.75)
+
+	// 订阅的点位返回通知值，被拒绝的监视项继续轮询
+	values, err := c.ReadBatch("line1", "", []string{"ns=2;s=Temp", "ns=2;s=Missing", "ns=2;i=1001"})
+	if err != nil || values[0].Value != 22.75 || values[1].Quality != "bad:BadNodeIdUnknown" || values[2].Value != int16(-3) {
+		t.Errorf("ReadBatch: %+v, %v", values, err)
+	}
+
+	// 令牌续订后通道继续可用
+	time.Sleep(700 * time.Millisecond)
+	sim.mu.Lock()
+	renewals := sim.renewals
+	sim.mu.Unlock()
+	if renewals == 0 {
+		t.Error("expect secure channel renewal")
+	}
+	sim.set("ns=2;s=Temp", 23.0)
+	expectPush(23.0)
+	if err := c.Write("count", 5); err != nil {
+		t.Fatal(err)
+	}
+
+	// 服务端断开后重新连接并重建订阅
+	c.lock.Lock()
+	c.conn.ch.conn.Close()
+	c.lock.Unlock()
+	time.Sleep(50 * time.Millisecond)
+	if _, err := c.ReadBatch("line1", "", []string{"ns=2;i=1001"}); err != nil {
+		t.Fatal(err)
+	}
+	expectPush(23.0)
+}
@@ -0,0 +1,921 @@
+package opcua
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// 服务端限制
+const (
+	defaultServerPort      = 4840
+	defaultMaxSessions     = 50
+	maxSubscriptions       = 100 // 每个会话
+	maxMonitoredItems      = 10000
+	maxPublishRequests     = 10 // 每个会话排队的 Publish 请求
+	maxRetransmitQueue     = 10 // 每个订阅保留的未确认通知
+	maxContinuationPoints  = 10 // 每个会话的浏览续传点
+	minSessionTimeout      = 10 * time.Second
+	maxSessionTimeout      = time.Hour
+	minTokenLifetime       = 10000   // 毫秒
+	maxTokenLifetime       = 3600000 // 毫秒
+	helloTimeout           = 10 * time.Second
+	serverTick             = 50 * time.Millisecond
+	transportProfileBinary = "http://opcfoundation.org/UA-Profile/Transport/uatcp-uasc-uabinary"
+)
+
+// ServerConfig 服务端参数
+type ServerConfig struct {
+	Listen           string            // 监听地址，默认 :4840
+	EndpointURL      string            // 对外公布的端点 URL，默认 opc.tcp://<主机名>:<端口>
+	ApplicationURI   string            // 默认 urn:sensor-edge:gateway，同时作为网关节点的命名空间（ns=1）
+	ApplicationName  string            // 默认 sensor-edge
+	CertFile         string            // 应用实例证书和私钥(PEM/DER)，未配置时每次启动生成自签名证书
+	KeyFile          string            //
+	SecurityPolicies []string          // None | Basic256Sha256 | Aes128_Sha256_RsaOaep，默认 None 和 Basic256Sha256
+	Anonymous        bool              // 允许匿名登录
+	Users            map[string]string // 用户名 -> 密码
+	MaxSessions      int               // 默认 50
+}
+
+// ServerFolder Objects 下的一个文件夹，NodeId 为 ns=1;s=<Name>
+type ServerFolder struct {
+	Name        string
+	DisplayName string
+	Description string
+	Variables   []ServerVariable
+}
+
+// ServerVariable 文件夹下的变量，NodeId 为 ns=1;s=<文件夹>.<Name>
+type ServerVariable struct {
+	Name        string
+	Description string
+	Unit        string // 非空时添加 EngineeringUnits 属性
+	DataType    string // Boolean | SByte | Byte | Int16 | UInt16 | Int32 | UInt32 | Int64 | UInt64 | Float | Double | String | DateTime，默认 Double
+	Writable    bool
+}
+
+// serverDataTypes 变量可用的内置数据类型
+var serverDataTypes = map[string]byte{
+	"Boolean": typeBoolean, "SByte": typeSByte, "Byte": typeByte,
+	"Int16": typeInt16, "UInt16": typeUInt16, "Int32": typeInt32, "UInt32": typeUInt32,
+	"Int64": typeInt64, "UInt64": typeUInt64, "Float": typeFloat, "Double": typeDouble,
+	"String": typeString, "DateTime": typeDateTime,
+}
+
+// ErrUnknownVariable Update 的变量不在地址空间中
+var ErrUnknownVariable = errors.New("opcua: unknown variable")
+
+// WriteHandler 客户端写入可写变量时调用，返回错误时写入失败（错误为 StatusCode 时原样返回给客户端）
+type WriteHandler func(folder, variable string, value interface{}) error
+
+// Server OPC UA 服务端：对外呈现 Objects 下由调用方定义的文件夹和变量，支持 Browse、Read、Write、
+// TranslateBrowsePathsToNodeIds 和订阅（数据变化通知）。变量值由 Update 刷新，可写变量的写入经 WriteHandler 转发。
+// 不校验客户端应用证书的信任链
+type Server struct {
+	conf      ServerConfig
+	ln        net.Listener
+	cert      []byte
+	key       *rsa.PrivateKey
+	app       applicationDescription
+	endpoints []endpointDescription
+	userToken *securityPolicy // 用户名令牌的加密策略
+	started   time.Time
+	done      chan struct{}
+
+	mu        sync.Mutex
+	space     addressSpace
+	monitors  map[*addrNode]map[*monitoredItem]bool
+	sessions  map[string]*serverSession // AuthenticationToken -> 会话
+	channels  map[*secureChannel]bool
+	lastID    uint32 // 通道、会话、订阅和监视项的编号
+	writer    WriteHandler
+	outbox    []outMessage
+	closeOnce sync.Once
+}
+
+// outMessage 待发送的应答，在释放锁后发送
+type outMessage struct {
+	ch        *secureChannel
+	requestID uint32
+	msg       message
+}
+
+// NewServer 监听 conf.Listen 并开始服务
+func NewServer(conf ServerConfig, folders []ServerFolder) (*Server, error) {
+	if conf.Listen == "" {
+		conf.Listen = fmt.Sprintf(":%d", defaultServerPort)
+	}
+	if conf.ApplicationURI == "" {
+		conf.ApplicationURI = "urn:sensor-edge:gateway"
+	}
+	if conf.ApplicationName == "" {
+		conf.ApplicationName = "sensor-edge"
+	}
+	if conf.MaxSessions <= 0 {
+		conf.MaxSessions = defaultMaxSessions
+	}
+	if len(conf.SecurityPolicies) == 0 {
+		conf.SecurityPolicies = []string{"None", "Basic256Sha256"}
+	}
+	if !conf.Anonymous && len(conf.Users) == 0 {
+		return nil, errors.New("opcua: server requires anonymous or users")
+	}
+	s := &Server{
+		conf:     conf,
+		started:  time.Now(),
+		done:     make(chan struct{}),
+		monitors: make(map[*addrNode]map[*monitoredItem]bool),
+		sessions: make(map[string]*serverSession),
+		channels: make(map[*secureChannel]bool),
+	}
+	var policies []*securityPolicy
+	for _, name := range conf.SecurityPolicies {
+		p, err := findPolicy(name)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+		if !p.isNone() && s.userToken == nil {
+			s.userToken = p
+		}
+	}
+	if s.userToken == nil && len(conf.Users) > 0 {
+		log.Printf("[OPCUA-SERVER] only security policy None is enabled, user passwords are sent in plain text")
+	}
+	var err error
+	if s.cert, s.key, err = loadCertificate(conf.CertFile, conf.KeyFile, conf.ApplicationURI); err != nil {
+		return nil, err
+	}
+	s.space = newAddressSpace([]string{"http://opcfoundation.org/UA/", conf.ApplicationURI}, conf.ApplicationURI, s.started, s.serverStatus)
+	if err := s.addFolders(folders); err != nil {
+		return nil, err
+	}
+	if s.ln, err = net.Listen("tcp", conf.Listen); err != nil {
+		return nil, fmt.Errorf("opcua: listen %s failed: %v", conf.Listen, err)
+	}
+	if s.conf.EndpointURL == "" {
+		s.conf.EndpointURL = defaultEndpointURL(s.ln.Addr())
+	}
+	s.app = applicationDescription{
+		ApplicationURI:  conf.ApplicationURI,
+		ProductURI:      "urn:sensor-edge",
+		ApplicationName: LocalizedText{Text: conf.ApplicationName},
+		DiscoveryURLs:   []string{s.conf.EndpointURL},
+	}
+	s.endpoints = s.buildEndpoints(policies)
+	log.Printf("[OPCUA-SERVER] listening on %s (%s) with %d folders", s.ln.Addr(), s.conf.EndpointURL, len(folders))
+	go s.accept()
+	go s.run()
+	return s, nil
+}
+
+// defaultEndpointURL 监听地址未指定主机时使用本机主机名
+func defaultEndpointURL(addr net.Addr) string {
+	host, port, _ := net.SplitHostPort(addr.String())
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		if name, err := os.Hostname(); err == nil {
+			host = name
+		}
+	}
+	return "opc.tcp://" + net.JoinHostPort(host, port)
+}
+
+// addFolders 在 Objects 下建立文件夹和变量
+func (s *Server) addFolders(folders []ServerFolder) error {
+	objects := s.space.node(NewNumericNodeID(0, idObjectsFolder))
+	folderType := s.space.node(NewNumericNodeID(0, idFolderType))
+	variableType := s.space.node(NewNumericNodeID(0, idBaseDataVariableType))
+	propertyType := s.space.node(NewNumericNodeID(0, idPropertyType))
+	for _, f := range folders {
+		if f.Name == "" {
+			return errors.New("opcua: folder name is required")
+		}
+		id := gatewayNodeID(f.Name)
+		if s.space.node(id) != nil {
+			return fmt.Errorf("opcua: duplicate node %s", id)
+		}
+		folder := s.space.add(&addrNode{
+			id:          id,
+			class:       nodeClassObject,
+			browseName:  QualifiedName{NamespaceIndex: 1, Name: f.Name},
+			displayName: LocalizedText{Text: f.DisplayName},
+			description: LocalizedText{Text: f.Description},
+			typeDef:     folderType,
+		}, objects, idOrganizes)
+		for _, v := range f.Variables {
+			if v.DataType == "" {
+				v.DataType = "Double"
+			}
+			t, ok := serverDataTypes[v.DataType]
+			if !ok {
+				return fmt.Errorf("opcua: variable %s.%s: unsupported data type %q", f.Name, v.Name, v.DataType)
+			}
+			id := gatewayNodeID(f.Name, v.Name)
+			if v.Name == "" || s.space.node(id) != nil {
+				return fmt.Errorf("opcua: invalid or duplicate variable %s", id)
+			}
+			n := s.space.add(&addrNode{
+				id:          id,
+				class:       nodeClassVariable,
+				browseName:  QualifiedName{NamespaceIndex: 1, Name: v.Name},
+				description: LocalizedText{Text: v.Description},
+				typeDef:     variableType,
+				folder:      f.Name,
+				name:        v.Name,
+			}, folder, idOrganizes)
+			s.space.variable(n, t, -1, DataValue{Status: StatusBadWaitingForInitialData})
+			if v.Writable {
+				n.access |= accessWrite
+			}
+			if v.Unit != "" {
+				eu := s.space.add(&addrNode{
+					id:         gatewayNodeID(f.Name, v.Name, "EngineeringUnits"),
+					class:      nodeClassVariable,
+					browseName: QualifiedName{Name: "EngineeringUnits"},
+					typeDef:    propertyType,
+				}, n, idHasProperty)
+				s.space.variable(eu, 0, -1, DataValue{Value: euInformation(v.Unit)})
+				eu.dataType = NewNumericNodeID(0, idEUInformation)
+			}
+		}
+	}
+	return nil
+}
+
+// buildEndpoints 每个加密策略提供 Sign 和 SignAndEncrypt 两个端点
+func (s *Server) buildEndpoints(policies []*securityPolicy) []endpointDescription {
+	var tokens []userTokenPolicy
+	if s.conf.Anonymous {
+		tokens = append(tokens, userTokenPolicy{PolicyID: "anonymous", TokenType: tokenAnonymous})
+	}
+	if len(s.conf.Users) > 0 {
+		p := userTokenPolicy{PolicyID: "username", TokenType: tokenUserName}
+		if s.userToken != nil {
+			p.SecurityPolicyURI = s.userToken.uri
+		}
+		tokens = append(tokens, p)
+	}
+	var eps []endpointDescription
+	for _, p := range policies {
+		modes := []uint32{securityModeSign, securityModeSignAndEncrypt}
+		if p.isNone() {
+			modes = []uint32{securityModeNone}
+		}
+		for _, mode := range modes {
+			eps = append(eps, endpointDescription{
+				EndpointURL:         s.conf.EndpointURL,
+				Server:              s.app,
+				ServerCertificate:   s.cert,
+				SecurityMode:        mode,
+				SecurityPolicyURI:   p.uri,
+				UserIdentityTokens:  tokens,
+				TransportProfileURI: transportProfileBinary,
+				SecurityLevel:       byte(mode),
+			})
+		}
+	}
+	return eps
+}
+
+// Addr 实际监听地址
+func (s *Server) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+// EndpointURL 对外公布的端点 URL
+func (s *Server) EndpointURL() string {
+	return s.conf.EndpointURL
+}
+
+// SetWriteHandler 设置写入回调，未设置时可写变量的写入返回 BadNotWritable
+func (s *Server) SetWriteHandler(h WriteHandler) {
+	s.mu.Lock()
+	s.writer = h
+	s.mu.Unlock()
+}
+
+// Update 刷新变量的值，value 为 nil 表示采集失败（状态为 BadNoCommunication）
+func (s *Server) Update(folder, variable string, value interface{}, ts time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.space.node(gatewayNodeID(folder, variable))
+	if n == nil || n.folder == "" {
+		return fmt.Errorf("%w %s.%s", ErrUnknownVariable, folder, variable)
+	}
+	dv := DataValue{Status: StatusBadNoCommunication, SourceTimestamp: ts}
+	if value != nil {
+		if _, ok := integerRanges[n.builtin]; ok {
+			if f, ok := value.(float64); ok {
+				value = math.Round(f)
+			}
+		}
+		v, err := convertValue(value, n.builtin)
+		if err != nil {
+			return fmt.Errorf("opcua: variable %s.%s: %v", folder, variable, err)
+		}
+		dv = DataValue{Value: v, SourceTimestamp: ts}
+	}
+	s.setValue(n, dv)
+	return nil
+}
+
+// Close 停止监听并断开所有连接
+func (s *Server) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		err = s.ln.Close()
+		s.mu.Lock()
+		for ch := range s.channels {
+			ch.conn.Close()
+		}
+		s.mu.Unlock()
+	})
+	return err
+}
+
+func (s *Server) accept() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+			log.Printf("[OPCUA-SERVER] accept: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		go s.serve(conn)
+	}
+}
+
+// serve 处理一个连接：HEL/ACK、打开安全通道，然后按顺序处理请求
+func (s *Server) serve(conn net.Conn) {
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(helloTimeout))
+	b, err := readChunk(conn, defaultBufferSize)
+	if err != nil || string(b[:3]) != msgHello {
+		return
+	}
+	h, err := decodeHello(b[8:], false)
+	if err != nil {
+		writeError(conn, StatusBadTCPMessageTypeInvalid, err.Error())
+		return
+	}
+	ch := newSecureChannel(conn, true)
+	ch.localCert, ch.localKey = s.cert, s.key
+	ch.sendBufferSize = min(h.ReceiveBufferSize, defaultBufferSize)
+	ch.maxMessageSize = h.MaxMessageSize
+	ack := hello{ReceiveBufferSize: defaultBufferSize, SendBufferSize: defaultBufferSize, MaxMessageSize: maxMessageSize}
+	if err := writeRaw(conn, msgAcknowledge, ack.encode(true)); err != nil {
+		return
+	}
+	s.mu.Lock()
+	s.channels[ch] = true
+	s.mu.Unlock()
+	defer s.channelClosed(ch)
+
+	lifetime := time.Duration(0)
+	for {
+		if lifetime > 0 {
+			// 客户端须在令牌过期前续订，超时未收到任何报文时关闭连接
+			conn.SetReadDeadline(time.Now().Add(lifetime * 5 / 4))
+		}
+		msgType, id, body, err := ch.readMessage()
+		if err != nil {
+			var te *transportError
+			if errors.As(err, &te) {
+				writeError(conn, te.Status, te.Reason)
+			}
+			return
+		}
+		m, err := decodeMessage(body)
+		if err != nil {
+			writeError(conn, StatusBadDecodingError, err.Error())
+			return
+		}
+		switch req := m.(type) {
+		case *openSecureChannelRequest:
+			if msgType != msgOpen {
+				return
+			}
+			if lifetime = s.open(ch, id, req); lifetime == 0 {
+				return
+			}
+		case *closeSecureChannelRequest:
+			return
+		case request:
+			if msgType != msgMessage || ch.channelID == 0 {
+				return
+			}
+			if resp := s.handle(ch, id, req); resp != nil {
+				s.reply(ch, id, resp)
+			}
+			s.flush()
+		}
+	}
+}
+
+// open 处理 OpenSecureChannel（新建或续订），返回令牌生命周期，失败时返回 0
+func (s *Server) open(ch *secureChannel, id uint32, req *openSecureChannelRequest) time.Duration {
+	if req.RequestType == 0 {
+		if ch.channelID != 0 {
+			writeError(ch.conn, StatusBadTCPSecureChannelUnknown, "channel already opened")
+			return 0
+		}
+		valid := false
+		for _, ep := range s.endpoints {
+			valid = valid || ep.SecurityPolicyURI == ch.policy.uri && ep.SecurityMode == req.SecurityMode
+		}
+		if !valid {
+			writeError(ch.conn, StatusBadSecurityPolicyRejected, "no matching endpoint")
+			return 0
+		}
+		if !ch.policy.isNone() && len(req.ClientNonce) < ch.policy.nonceLen {
+			writeError(ch.conn, StatusBadNonceInvalid, "")
+			return 0
+		}
+		s.mu.Lock()
+		s.lastID++
+		ch.channelID = s.lastID
+		s.mu.Unlock()
+		ch.mode = req.SecurityMode
+	} else if ch.channelID == 0 {
+		writeError(ch.conn, StatusBadTCPSecureChannelUnknown, "renew before open")
+		return 0
+	}
+	lifetime := req.RequestedLifetime
+	if lifetime == 0 {
+		lifetime = maxTokenLifetime
+	}
+	lifetime = min(max(lifetime, minTokenLifetime), maxTokenLifetime)
+	nonce, err := ch.policy.nonce()
+	if err != nil {
+		return 0
+	}
+	ch.mu.Lock()
+	tokenID := ch.latest + 1
+	ch.mu.Unlock()
+	resp := &openSecureChannelResponse{
+		Header:        responseHeader{Timestamp: time.Now(), RequestHandle: req.Header.RequestHandle},
+		SecurityToken: channelSecurityToken{ChannelID: ch.channelID, TokenID: tokenID, CreatedAt: time.Now(), RevisedLifetime: lifetime},
+		ServerNonce:   nonce,
+	}
+	if err := ch.writeMessage(msgOpen, id, encodeMessage(resp)); err != nil {
+		return 0
+	}
+	ch.installToken(tokenID, nonce, req.ClientNonce)
+	return time.Duration(lifetime) * time.Millisecond
+}
+
+// channelClosed 连接断开：会话保留到超时，客户端可在新通道上重新激活
+func (s *Server) channelClosed(ch *secureChannel) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.channels, ch)
+	for _, sess := range s.sessions {
+		if sess.ch == ch {
+			sess.ch = nil
+			sess.publishQ = nil
+		}
+	}
+}
+
+func (s *Server) reply(ch *secureChannel, id uint32, resp message) {
+	if err := ch.writeMessage(msgMessage, id, encodeMessage(resp)); err != nil {
+		log.Printf("[OPCUA-SERVER] send %T: %v", resp, err)
+	}
+}
+
+// flush 发送订阅产生的应答
+func (s *Server) flush() {
+	s.mu.Lock()
+	out := s.outbox
+	s.outbox = nil
+	s.mu.Unlock()
+	for _, m := range out {
+		s.reply(m.ch, m.requestID, m.msg)
+	}
+}
+
+func serviceFaultFor(req request, status StatusCode) *serviceFault {
+	return &serviceFault{Header: responseHeader{Timestamp: time.Now(), RequestHandle: req.header().RequestHandle, ServiceResult: status}}
+}
+
+// handle 处理一个服务请求，Publish 请求排队时返回 nil
+func (s *Server) handle(ch *secureChannel, requestID uint32, req request) message {
+	h := responseHeader{Timestamp: time.Now(), RequestHandle: req.header().RequestHandle}
+	switch r := req.(type) {
+	case *getEndpointsRequest:
+		return &getEndpointsResponse{Header: h, Endpoints: s.endpoints}
+	case *findServersRequest:
+		return &findServersResponse{Header: h, Servers: []applicationDescription{s.app}}
+	case *createSessionRequest:
+		return s.createSession(ch, h, r)
+	}
+	s.mu.Lock()
+	sess := s.sessions[req.header().AuthenticationToken.String()]
+	if sess == nil || req.header().AuthenticationToken.IsNull() {
+		s.mu.Unlock()
+		return serviceFaultFor(req, StatusBadSessionIDInvalid)
+	}
+	if r, ok := req.(*activateSessionRequest); ok {
+		defer s.mu.Unlock()
+		return s.activateSession(ch, sess, h, r)
+	}
+	if sess.ch != ch {
+		s.mu.Unlock()
+		return serviceFaultFor(req, StatusBadSecureChannelIDInvalid)
+	}
+	if !sess.activated {
+		s.mu.Unlock()
+		return serviceFaultFor(req, StatusBadSessionNotActivated)
+	}
+	sess.lastSeen = time.Now()
+	if r, ok := req.(*writeRequest); ok {
+		s.mu.Unlock()
+		return s.write(h, r)
+	}
+	defer s.mu.Unlock()
+	switch r := req.(type) {
+	case *closeSessionRequest:
+		s.closeSession(sess, StatusBadSessionClosed)
+		return &closeSessionResponse{Header: h}
+	case *readRequest:
+		return s.read(h, r)
+	case *browseRequest:
+		return s.browse(sess, h, r)
+	case *browseNextRequest:
+		return s.browseNext(sess, h, r)
+	case *translateBrowsePathsRequest:
+		if len(r.BrowsePaths) == 0 {
+			return serviceFaultFor(req, StatusBadNothingToDo)
+		}
+		resp := &translateBrowsePathsResponse{Header: h}
+		for _, p := range r.BrowsePaths {
+			resp.Results = append(resp.Results, s.space.translate(p))
+		}
+		return resp
+	case *createSubscriptionRequest:
+		return s.createSubscription(sess, h, r)
+	case *modifySubscriptionRequest:
+		return s.modifySubscription(sess, h, r)
+	case *setPublishingModeRequest:
+		return s.setPublishingMode(sess, h, r)
+	case *deleteSubscriptionsRequest:
+		return s.deleteSubscriptions(sess, h, r)
+	case *createMonitoredItemsRequest:
+		return s.createMonitoredItems(sess, h, r)
+	case *modifyMonitoredItemsRequest:
+		return s.modifyMonitoredItems(sess, h, r)
+	case *setMonitoringModeRequest:
+		return s.setMonitoringMode(sess, h, r)
+	case *deleteMonitoredItemsRequest:
+		return s.deleteMonitoredItems(sess, h, r)
+	case *publishRequest:
+		return s.publish(sess, ch, requestID, r)
+	case *republishRequest:
+		return s.republish(sess, h, r)
+	}
+	return serviceFaultFor(req, StatusBadServiceUnsupported)
+}
+
+// serverSession 客户端会话
+type serverSession struct {
+	id         NodeID
+	authToken  NodeID
+	name       string
+	ch         *secureChannel
+	nonce      []byte // 最近一次 CreateSession/ActivateSession 返回的服务端 nonce
+	clientCert []byte
+	timeout    time.Duration
+	lastSeen   time.Time
+	activated  bool
+	subs       map[uint32]*serverSubscription
+	expired    []*serverSubscription // 超时删除、尚未通知客户端的订阅
+	publishQ   []*pendingPublish
+	browseCPs  map[string]*continuationPoint
+}
+
+// continuationPoint 浏览结果中未返回的引用
+type continuationPoint struct {
+	refs []referenceDescription
+	max  int
+}
+
+func (s *Server) createSession(ch *secureChannel, h responseHeader, r *createSessionRequest) message {
+	secure := !ch.policy.isNone()
+	if secure && (len(r.ClientNonce) < 32 || !bytes.Equal(r.ClientCertificate, ch.remoteCert)) {
+		return &serviceFault{Header: withStatus(h, StatusBadSecurityChecksFailed)}
+	}
+	nonce, err := randomNonce(32)
+	if err != nil {
+		return &serviceFault{Header: withStatus(h, StatusBadInternalError)}
+	}
+	token, err := randomNonce(16)
+	if err != nil {
+		return &serviceFault{Header: withStatus(h, StatusBadInternalError)}
+	}
+	timeout := time.Duration(r.RequestedSessionTimeout) * time.Millisecond
+	if timeout == 0 {
+		timeout = time.Minute
+	}
+	timeout = min(max(timeout, minSessionTimeout), maxSessionTimeout)
+
+	s.mu.Lock()
+	if len(s.sessions) >= s.conf.MaxSessions {
+		s.mu.Unlock()
+		return &serviceFault{Header: withStatus(h, StatusBadTooManySessions)}
+	}
+	s.lastID++
+	sess := &serverSession{
+		id:         NewNumericNodeID(1, s.lastID),
+		authToken:  NodeID{Type: idOpaque, Opaque: token},
+		name:       r.SessionName,
+		ch:         ch,
+		nonce:      nonce,
+		clientCert: r.ClientCertificate,
+		timeout:    timeout,
+		lastSeen:   time.Now(),
+		subs:       make(map[uint32]*serverSubscription),
+		browseCPs:  make(map[string]*continuationPoint),
+	}
+	s.sessions[sess.authToken.String()] = sess
+	s.mu.Unlock()
+
+	resp := &createSessionResponse{
+		Header:                h,
+		SessionID:             sess.id,
+		AuthenticationToken:   sess.authToken,
+		RevisedSessionTimeout: float64(timeout.Milliseconds()),
+		ServerNonce:           nonce,
+		ServerCertificate:     s.cert,
+		ServerEndpoints:       s.endpoints,
+	}
+	if secure {
+		sig, err := asymSign(s.key, append(append([]byte(nil), r.ClientCertificate...), r.ClientNonce...))
+		if err != nil {
+			return &serviceFault{Header: withStatus(h, StatusBadInternalError)}
+		}
+		resp.ServerSignature = signatureData{Algorithm: algorithmRsaSha256, Signature: sig}
+	}
+	return resp
+}
+
+func withStatus(h responseHeader, status StatusCode) responseHeader {
+	h.ServiceResult = status
+	return h
+}
+
+// activateSession 校验客户端签名和用户身份；会话可在同一客户端的新通道上重新激活
+func (s *Server) activateSession(ch *secureChannel, sess *serverSession, h responseHeader, r *activateSessionRequest) message {
+	if !ch.policy.isNone() {
+		if !bytes.Equal(sess.clientCert, ch.remoteCert) {
+			return &serviceFault{Header: withStatus(h, StatusBadSecurityChecksFailed)}
+		}
+		data := append(append([]byte(nil), s.cert...), sess.nonce...)
+		if asymVerify(ch.remoteKey, data, r.ClientSignature.Signature) != nil {
+			return &serviceFault{Header: withStatus(h, StatusBadApplicationSignatureInvalid)}
+		}
+	} else if sess.ch != ch && sess.ch != nil {
+		return &serviceFault{Header: withStatus(h, StatusBadSecureChannelIDInvalid)}
+	}
+	if status := s.checkIdentity(ch, sess, r.UserIdentityToken); status != StatusGood {
+		return &serviceFault{Header: withStatus(h, status)}
+	}
+	nonce, err := randomNonce(32)
+	if err != nil {
+		return &serviceFault{Header: withStatus(h, StatusBadInternalError)}
+	}
+	if sess.ch != ch {
+		sess.publishQ = nil
+	}
+	sess.ch, sess.nonce, sess.activated, sess.lastSeen = ch, nonce, true, time.Now()
+	return &activateSessionResponse{Header: h, ServerNonce: nonce}
+}
+
+// checkIdentity 校验用户身份令牌：匿名或用户名/密码
+func (s *Server) checkIdentity(ch *secureChannel, sess *serverSession, x ExtensionObject) StatusCode {
+	switch {
+	case x.TypeID.IsNull() || x.TypeID.Numeric == idAnonymousIdentityToken:
+		if !s.conf.Anonymous {
+			return StatusBadIdentityTokenRejected
+		}
+		return StatusGood
+	case x.TypeID.Numeric == idUserNameIdentityToken:
+		if len(s.conf.Users) == 0 {
+			return StatusBadIdentityTokenRejected
+		}
+		var tok userNameIdentityToken
+		if decodeObject(x, &tok) != nil {
+			return StatusBadIdentityTokenInvalid
+		}
+		var password string
+		switch tok.EncryptionAlgorithm {
+		case "":
+			// 明文密码只接受未配置加密策略或通道已加密的情况
+			if s.userToken != nil && !ch.encrypting() {
+				return StatusBadIdentityTokenInvalid
+			}
+			password = string(tok.Password)
+		case algorithmRsaOaep:
+			p, err := decryptPassword(s.key, tok.Password, sess.nonce)
+			if err != nil {
+				return StatusBadIdentityTokenInvalid
+			}
+			password = p
+		default:
+			return StatusBadIdentityTokenInvalid
+		}
+		want, ok := s.conf.Users[tok.UserName]
+		if !ok || subtle.ConstantTimeCompare([]byte(want), []byte(password)) != 1 {
+			return StatusBadUserAccessDenied
+		}
+		return StatusGood
+	}
+	return StatusBadIdentityTokenInvalid
+}
+
+// closeSession 删除会话及其订阅，排队的 Publish 请求返回 status
+func (s *Server) closeSession(sess *serverSession, status StatusCode) {
+	for _, sub := range sess.subs {
+		s.removeSubscription(sub)
+	}
+	for _, p := range sess.publishQ {
+		s.outbox = append(s.outbox, outMessage{p.ch, p.requestID, &serviceFault{Header: responseHeader{Timestamp: time.Now(), RequestHandle: p.handle, ServiceResult: status}}})
+	}
+	sess.publishQ = nil
+	delete(s.sessions, sess.authToken.String())
+}
+
+// read 读取节点属性，Value 属性按 TimestampsToReturn 返回时间戳
+func (s *Server) read(h responseHeader, r *readRequest) message {
+	if r.TimestampsToReturn > timestampsNeither {
+		return &serviceFault{Header: withStatus(h, StatusBadTimestampsToReturnInvalid)}
+	}
+	if len(r.NodesToRead) == 0 {
+		return &serviceFault{Header: withStatus(h, StatusBadNothingToDo)}
+	}
+	resp := &readResponse{Header: h}
+	for _, rv := range r.NodesToRead {
+		n := s.space.node(rv.NodeID)
+		switch {
+		case n == nil:
+			resp.Results = append(resp.Results, DataValue{Status: StatusBadNodeIDUnknown})
+		case rv.IndexRange != "":
+			resp.Results = append(resp.Results, DataValue{Status: StatusBadIndexRangeInvalid})
+		case rv.AttributeID == attrValue:
+			resp.Results = append(resp.Results, withTimestamps(n.attribute(attrValue), r.TimestampsToReturn))
+		default:
+			resp.Results = append(resp.Results, n.attribute(rv.AttributeID))
+		}
+	}
+	return resp
+}
+
+// withTimestamps 按 TimestampsToReturn 保留源时间戳并填写服务端时间戳
+func withTimestamps(dv DataValue, ts uint32) DataValue {
+	switch ts {
+	case timestampsSource:
+		dv.ServerTimestamp = time.Time{}
+	case timestampsServer:
+		dv.SourceTimestamp, dv.ServerTimestamp = time.Time{}, time.Now()
+	case timestampsBoth:
+		dv.ServerTimestamp = time.Now()
+	default:
+		dv.SourceTimestamp, dv.ServerTimestamp = time.Time{}, time.Time{}
+	}
+	return dv
+}
+
+// write 写入可写变量的 Value 属性：先经 WriteHandler 转发到源设备，成功后刷新变量值。调用时不持有锁
+func (s *Server) write(h responseHeader, r *writeRequest) message {
+	if len(r.NodesToWrite) == 0 {
+		return &serviceFault{Header: withStatus(h, StatusBadNothingToDo)}
+	}
+	resp := &writeResponse{Header: h, Results: make([]StatusCode, len(r.NodesToWrite))}
+	for i, w := range r.NodesToWrite {
+		s.mu.Lock()
+		n, writer := s.space.node(w.NodeID), s.writer
+		s.mu.Unlock()
+		switch {
+		case n == nil:
+			resp.Results[i] = StatusBadNodeIDUnknown
+			continue
+		case w.AttributeID != attrValue || n.access&accessWrite == 0:
+			resp.Results[i] = StatusBadNotWritable
+			continue
+		case w.IndexRange != "":
+			resp.Results[i] = StatusBadWriteNotSupported
+			continue
+		case writer == nil:
+			resp.Results[i] = StatusBadNotWritable
+			continue
+		}
+		v, err := convertValue(pointValueOf(w.Value.Value), n.builtin)
+		if w.Value.Value == nil || err != nil {
+			resp.Results[i] = StatusBadTypeMismatch
+			continue
+		}
+		if err := writer(n.folder, n.name, v); err != nil {
+			log.Printf("[OPCUA-SERVER] write %s: %v", n.id, err)
+			var status StatusCode
+			if !errors.As(err, &status) {
+				status = StatusBadCommunicationError
+			}
+			resp.Results[i] = status
+			continue
+		}
+		s.mu.Lock()
+		s.setValue(n, DataValue{Value: v, SourceTimestamp: time.Now()})
+		s.mu.Unlock()
+	}
+	s.flush()
+	return resp
+}
+
+// browse 浏览节点的引用，超过 RequestedMaxReferencesPerNode 时返回续传点
+func (s *Server) browse(sess *serverSession, h responseHeader, r *browseRequest) message {
+	if !r.ViewID.IsNull() {
+		return &serviceFault{Header: withStatus(h, StatusBadViewIDUnknown)}
+	}
+	if len(r.NodesToBrowse) == 0 {
+		return &serviceFault{Header: withStatus(h, StatusBadNothingToDo)}
+	}
+	resp := &browseResponse{Header: h}
+	for _, b := range r.NodesToBrowse {
+		refs, status := s.space.browse(b)
+		if status != StatusGood {
+			resp.Results = append(resp.Results, browseResult{StatusCode: status})
+			continue
+		}
+		resp.Results = append(resp.Results, s.browsePage(sess, refs, int(r.RequestedMaxReferencesPerNode)))
+	}
+	return resp
+}
+
+// browsePage 返回最多 max 条引用，其余保存为续传点
+func (s *Server) browsePage(sess *serverSession, refs []referenceDescription, max int) browseResult {
+	if max <= 0 || len(refs) <= max {
+		return browseResult{References: refs}
+	}
+	if len(sess.browseCPs) >= maxContinuationPoints {
+		return browseResult{StatusCode: StatusBadNoContinuationPoints}
+	}
+	cp, err := randomNonce(8)
+	if err != nil {
+		return browseResult{StatusCode: StatusBadInternalError}
+	}
+	sess.browseCPs[string(cp)] = &continuationPoint{refs: refs[max:], max: max}
+	return browseResult{ContinuationPoint: cp, References: refs[:max]}
+}
+
+func (s *Server) browseNext(sess *serverSession, h responseHeader, r *browseNextRequest) message {
+	if len(r.ContinuationPoints) == 0 {
+		return &serviceFault{Header: withStatus(h, StatusBadNothingToDo)}
+	}
+	resp := &browseNextResponse{Header: h}
+	for _, cp := range r.ContinuationPoints {
+		c, ok := sess.browseCPs[string(cp)]
+		if !ok {
+			resp.Results = append(resp.Results, browseResult{StatusCode: StatusBadContinuationPointInvalid})
+			continue
+		}
+		delete(sess.browseCPs, string(cp))
+		if r.ReleaseContinuationPoints {
+			resp.Results = append(resp.Results, browseResult{})
+			continue
+		}
+		resp.Results = append(resp.Results, s.browsePage(sess, c.refs, c.max))
+	}
+	return resp
+}
+
+// serverStatus Server.ServerStatus 变量的值（ServerStatusDataType）
+func (s *Server) serverStatus() ExtensionObject {
+	var e encoder
+	e.dateTime(s.started)
+	e.dateTime(time.Now())
+	e.int32(0) // Running
+	e.string(s.app.ProductURI)
+	e.string("sensor-edge")
+	e.string(s.conf.ApplicationName)
+	e.string("1.0")
+	e.string(strconv.FormatInt(s.started.Unix(), 10))
+	e.dateTime(s.started)
+	e.uint32(0)
+	e.localizedText(LocalizedText{})
+	return ExtensionObject{TypeID: NewNumericNodeID(0, idServerStatusEncoding), Body: e.bytes()}
+}
@@ -0,0 +1,657 @@
+package opcua
+
+import (
+	"log"
+	"math"
+	"reflect"
+	"time"
+)
+
+// 订阅参数限制
+const (
+	minPublishingInterval = 100 * time.Millisecond
+	maxPublishingInterval = time.Hour
+	defaultKeepAliveCount = 10
+	maxServerKeepAlive    = 10000
+	maxServerLifetime     = 100000
+	maxQueueSize          = 100
+)
+
+// statusOverflow 监视项队列溢出时在值的状态码中设置的信息位
+const statusOverflow StatusCode = 0x480
+
+// serverSubscription 会话中的订阅
+type serverSubscription struct {
+	id               uint32
+	sess             *serverSession
+	interval         time.Duration
+	lifetime         uint32 // 无 Publish 请求可用时允许的发布周期数
+	keepAlive        uint32 // 无通知时发送保活消息的周期数
+	maxNotifications uint32
+	enabled          bool
+	items            map[uint32]*monitoredItem
+	next             time.Time // 下一个发布周期
+	seq              uint32    // 最近发送的通知序号
+	keepAliveCount   uint32
+	lifetimeCount    uint32
+	messageSent      bool
+	late             bool // 有待发送的通知但没有 Publish 请求
+	retransmit       []notificationMessage
+}
+
+// monitoredItem 监视项，值变化由 setValue 推入队列，在发布周期内发送
+type monitoredItem struct {
+	id            uint32
+	sub           *serverSubscription
+	node          *addrNode
+	attr          uint32
+	handle        uint32
+	mode          uint32
+	timestamps    uint32
+	queueSize     uint32
+	discardOldest bool
+	filter        dataChangeFilter
+	last          *DataValue
+	queue         []DataValue
+}
+
+// pendingPublish 排队的 Publish 请求
+type pendingPublish struct {
+	ch        *secureChannel
+	requestID uint32
+	handle    uint32
+	deadline  time.Time // 零值表示不超时
+	results   []StatusCode
+}
+
+// run 定时处理会话超时、Publish 超时和订阅的发布周期
+func (s *Server) run() {
+	t := time.NewTicker(serverTick)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-t.C:
+			s.mu.Lock()
+			for _, sess := range s.sessions {
+				if now.Sub(sess.lastSeen) > sess.timeout {
+					log.Printf("[OPCUA-SERVER] session %q timed out", sess.name)
+					s.closeSession(sess, StatusBadSessionIDInvalid)
+					continue
+				}
+				s.expirePublish(sess, now)
+				for _, sub := range sess.subs {
+					if now.Before(sub.next) {
+						continue
+					}
+					sub.next = sub.next.Add(sub.interval)
+					if sub.next.Before(now) {
+						sub.next = now.Add(sub.interval)
+					}
+					s.cycle(sub, now)
+				}
+			}
+			s.mu.Unlock()
+			s.flush()
+		}
+	}
+}
+
+// expirePublish 超过 TimeoutHint 的 Publish 请求返回 BadTimeout
+func (s *Server) expirePublish(sess *serverSession, now time.Time) {
+	q := sess.publishQ[:0]
+	for _, p := range sess.publishQ {
+		if !p.deadline.IsZero() && now.After(p.deadline) {
+			s.outbox = append(s.outbox, outMessage{p.ch, p.requestID, &serviceFault{Header: responseHeader{Timestamp: now, RequestHandle: p.handle, ServiceResult: StatusBadTimeout}}})
+			continue
+		}
+		q = append(q, p)
+	}
+	sess.publishQ = q
+}
+
+// cycle 一个发布周期：有通知时发送通知，否则按保活计数发送保活消息；长期没有 Publish 请求时删除订阅
+func (s *Server) cycle(sub *serverSubscription, now time.Time) {
+	for _, it := range sub.items {
+		if it.node.dynamic != nil {
+			it.sample(it.node.attribute(it.attr))
+		}
+	}
+	pending := sub.enabled && sub.hasNotifications()
+	if len(sub.sess.publishQ) > 0 {
+		switch {
+		case pending:
+			s.sendNotifications(sub, now)
+		case !sub.messageSent || sub.keepAliveCount+1 >= sub.keepAlive:
+			s.sendKeepAlive(sub, now)
+		default:
+			sub.keepAliveCount++
+		}
+		return
+	}
+	sub.late = pending || !sub.messageSent || sub.keepAliveCount+1 >= sub.keepAlive
+	if !sub.late {
+		sub.keepAliveCount++
+	}
+	sub.lifetimeCount++
+	if sub.lifetimeCount >= sub.lifetime {
+		log.Printf("[OPCUA-SERVER] subscription %d of session %q expired", sub.id, sub.sess.name)
+		s.removeSubscription(sub)
+		sub.sess.expired = append(sub.sess.expired, sub)
+	}
+}
+
+func (sub *serverSubscription) hasNotifications() bool {
+	for _, it := range sub.items {
+		if it.mode == monitoringReporting && len(it.queue) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// nextPublish 取出最早的 Publish 请求
+func (sess *serverSession) nextPublish() *pendingPublish {
+	p := sess.publishQ[0]
+	sess.publishQ = sess.publishQ[1:]
+	return p
+}
+
+// sendPublish 用排队的 Publish 请求发送通知消息
+func (s *Server) sendPublish(sub *serverSubscription, msg notificationMessage, more bool) {
+	p := sub.sess.nextPublish()
+	resp := &publishResponse{
+		Header:              responseHeader{Timestamp: msg.PublishTime, RequestHandle: p.handle},
+		SubscriptionID:      sub.id,
+		MoreNotifications:   more,
+		NotificationMessage: msg,
+		Results:             p.results,
+	}
+	for _, m := range sub.retransmit {
+		resp.AvailableSequenceNumbers = append(resp.AvailableSequenceNumbers, m.SequenceNumber)
+	}
+	s.outbox = append(s.outbox, outMessage{p.ch, p.requestID, resp})
+	sub.messageSent, sub.late = true, false
+	sub.keepAliveCount, sub.lifetimeCount = 0, 0
+}
+
+// sendKeepAlive 保活消息不含通知，序号为下一条通知的序号
+func (s *Server) sendKeepAlive(sub *serverSubscription, now time.Time) {
+	s.sendPublish(sub, notificationMessage{SequenceNumber: nextSequence(sub.seq), PublishTime: now}, false)
+}
+
+// sendNotifications 发送队列中的数据变化，超过 MaxNotificationsPerPublish 时分多条消息发送
+func (s *Server) sendNotifications(sub *serverSubscription, now time.Time) {
+	for len(sub.sess.publishQ) > 0 {
+		var n dataChangeNotification
+		more := false
+		for _, it := range sub.items {
+			if it.mode != monitoringReporting {
+				continue
+			}
+			for len(it.queue) > 0 {
+				if sub.maxNotifications > 0 && uint32(len(n.MonitoredItems)) >= sub.maxNotifications {
+					more = true
+					break
+				}
+				n.MonitoredItems = append(n.MonitoredItems, monitoredItemNotification{ClientHandle: it.handle, Value: it.queue[0]})
+				it.queue = it.queue[1:]
+			}
+		}
+		if len(n.MonitoredItems) == 0 {
+			return
+		}
+		sub.seq = nextSequence(sub.seq)
+		msg := notificationMessage{SequenceNumber: sub.seq, PublishTime: now, NotificationData: []ExtensionObject{encodeObject(&n)}}
+		sub.retransmit = append(sub.retransmit, msg)
+		if len(sub.retransmit) > maxRetransmitQueue {
+			sub.retransmit = sub.retransmit[1:]
+		}
+		s.sendPublish(sub, msg, more)
+		if !more {
+			return
+		}
+		sub.late = true
+	}
+}
+
+// nextSequence 序号从 1 开始，回绕时跳过 0
+func nextSequence(seq uint32) uint32 {
+	if seq == math.MaxUint32 {
+		return 1
+	}
+	return seq + 1
+}
+
+// encodeObject 把结构体编码为 ExtensionObject
+func encodeObject(v encodable) ExtensionObject {
+	var e encoder
+	v.encode(&e)
+	return ExtensionObject{TypeID: NewNumericNodeID(0, v.typeID()), Body: e.bytes()}
+}
+
+// setValue 刷新变量的值并推送给监视项
+func (s *Server) setValue(n *addrNode, dv DataValue) {
+	n.value = dv
+	for it := range s.monitors[n] {
+		if it.attr == attrValue {
+			it.sample(dv)
+		}
+	}
+}
+
+// sample 按过滤器判断值是否变化，变化时推入队列
+func (it *monitoredItem) sample(dv DataValue) {
+	if it.mode == monitoringDisabled || it.last != nil && !it.changed(*it.last, dv) {
+		return
+	}
+	it.last = &dv
+	dv = withTimestamps(dv, it.timestamps)
+	if uint32(len(it.queue)) < it.queueSize {
+		it.queue = append(it.queue, dv)
+		return
+	}
+	// 队列已满：丢弃最旧或最新的值，并设置溢出标志
+	dv.Status |= statusOverflow
+	if it.discardOldest {
+		it.queue = append(it.queue[1:], dv)
+	} else {
+		it.queue[len(it.queue)-1] = dv
+	}
+}
+
+// changed 按 DataChangeFilter 的触发条件和绝对死区比较两个值
+func (it *monitoredItem) changed(old, dv DataValue) bool {
+	if old.Status != dv.Status {
+		return true
+	}
+	if it.filter.Trigger == triggerStatusValueTimestamp && !old.SourceTimestamp.Equal(dv.SourceTimestamp) {
+		return true
+	}
+	if it.filter.Trigger == triggerStatus {
+		return false
+	}
+	if it.filter.DeadbandType == deadbandAbsolute {
+		a, errA := toFloat(old.Value)
+		b, errB := toFloat(dv.Value)
+		if errA == nil && errB == nil {
+			return math.Abs(a-b) > it.filter.DeadbandValue
+		}
+	}
+	return !reflect.DeepEqual(old.Value, dv.Value)
+}
+
+// reviseSubscription 修正发布间隔、保活计数和生命周期计数
+func reviseSubscription(interval float64, lifetime, keepAlive uint32) (time.Duration, uint32, uint32) {
+	d := minPublishingInterval
+	if !math.IsNaN(interval) && interval > 0 {
+		d = min(max(time.Duration(interval*float64(time.Millisecond)), minPublishingInterval), maxPublishingInterval)
+	}
+	if keepAlive == 0 {
+		keepAlive = defaultKeepAliveCount
+	}
+	keepAlive = min(keepAlive, maxServerKeepAlive)
+	lifetime = min(max(lifetime, 3*keepAlive), maxServerLifetime)
+	return d, lifetime, keepAlive
+}
+
+func (s *Server) createSubscription(sess *serverSession, h responseHeader, r *createSubscriptionRequest) message {
+	if len(sess.subs) >= maxSubscriptions {
+		return &serviceFault{Header: withStatus(h, StatusBadTooManySubscriptions)}
+	}
+	s.lastID++
+	sub := &serverSubscription{
+		id:               s.lastID,
+		sess:             sess,
+		maxNotifications: r.MaxNotificationsPerPublish,
+		enabled:          r.PublishingEnabled,
+		items:            make(map[uint32]*monitoredItem),
+	}
+	sub.interval, sub.lifetime, sub.keepAlive = reviseSubscription(r.RequestedPublishingInterval, r.RequestedLifetimeCount, r.RequestedMaxKeepAliveCount)
+	sub.next = time.Now().Add(sub.interval)
+	sess.subs[sub.id] = sub
+	return &createSubscriptionResponse{
+		Header:                    h,
+		SubscriptionID:            sub.id,
+		RevisedPublishingInterval: float64(sub.interval.Milliseconds()),
+		RevisedLifetimeCount:      sub.lifetime,
+		RevisedMaxKeepAliveCount:  sub.keepAlive,
+	}
+}
+
+func (s *Server) modifySubscription(sess *serverSession, h responseHeader, r *modifySubscriptionRequest) message {
+	sub := sess.subs[r.SubscriptionID]
+	if sub == nil {
+		return &serviceFault{Header: withStatus(h, StatusBadSubscriptionIDInvalid)}
+	}
+	sub.interval, sub.lifetime, sub.keepAlive = reviseSubscription(r.RequestedPublishingInterval, r.RequestedLifetimeCount, r.RequestedMaxKeepAliveCount)
+	sub.maxNotifications = r.MaxNotificationsPerPublish
+	sub.next = time.Now().Add(sub.interval)
+	return &modifySubscriptionResponse{
+		Header:                    h,
+		RevisedPublishingInterval: float64(sub.interval.Milliseconds()),
+		RevisedLifetimeCount:      sub.lifetime,
+		RevisedMaxKeepAliveCount:  sub.keepAlive,
+	}
+}
+
+func (s *Server) setPublishingMode(sess *serverSession, h responseHeader, r *setPublishingModeRequest) message {
+	if len(r.SubscriptionIDs) == 0 {
+		return &serviceFault{Header: withStatus(h, StatusBadNothingToDo)}
+	}
+	resp := &setPublishingModeResponse{Header: h}
+	for _, id := range r.SubscriptionIDs {
+		sub := sess.subs[id]
+		if sub == nil {
+			resp.Results = append(resp.Results, StatusBadSubscriptionIDInvalid)
+			continue
+		}
+		sub.enabled = r.PublishingEnabled
+		resp.Results = append(resp.Results, StatusGood)
+	}
+	return resp
+}
+
+func (s *Server) deleteSubscriptions(sess *serverSession, h responseHeader, r *deleteSubscriptionsRequest) message {
+	if len(r.SubscriptionIDs) == 0 {
+		return &serviceFault{Header: withStatus(h, StatusBadNothingToDo)}
+	}
+	resp := &deleteSubscriptionsResponse{Header: h}
+	for _, id := range r.SubscriptionIDs {
+		sub := sess.subs[id]
+		if sub == nil {
+			resp.Results = append(resp.Results, StatusBadSubscriptionIDInvalid)
+			continue
+		}
+		s.removeSubscription(sub)
+		resp.Results = append(resp.Results, StatusGood)
+	}
+	// 没有订阅后排队的 Publish 请求不会再被使用
+	if len(sess.subs) == 0 {
+		for _, p := range sess.publishQ {
+			s.outbox = append(s.outbox, outMessage{p.ch, p.requestID, &serviceFault{Header: responseHeader{Timestamp: time.Now(), RequestHandle: p.handle, ServiceResult: StatusBadNoSubscription}}})
+		}
+		sess.publishQ = nil
+	}
+	return resp
+}
+
+// removeSubscription 删除订阅及其监视项
+func (s *Server) removeSubscription(sub *serverSubscription) {
+	for _, it := range sub.items {
+		s.unmonitor(it)
+	}
+	delete(sub.sess.subs, sub.id)
+}
+
+func (s *Server) unmonitor(it *monitoredItem) {
+	delete(s.monitors[it.node], it)
+	if len(s.monitors[it.node]) == 0 {
+		delete(s.monitors, it.node)
+	}
+	delete(it.sub.items, it.id)
+}
+
+// monitoredCount 服务端全部监视项的数量
+func (s *Server) monitoredCount() int {
+	n := 0
+	for _, items := range s.monitors {
+		n += len(items)
+	}
+	return n
+}
+
+// parseFilter 只支持 Value 属性上的 DataChangeFilter（绝对死区）
+func parseFilter(attr uint32, x ExtensionObject) (dataChangeFilter, StatusCode) {
+	f := dataChangeFilter{Trigger: triggerStatusValue}
+	if x.TypeID.IsNull() {
+		return f, StatusGood
+	}
+	if attr != attrValue {
+		return f, StatusBadFilterNotAllowed
+	}
+	if x.TypeID.Numeric != idDataChangeFilter {
+		return f, StatusBadMonitoredItemFilterUnsupported
+	}
+	if decodeObject(x, &f) != nil || f.Trigger > triggerStatusValueTimestamp {
+		return f, StatusBadMonitoredItemFilterInvalid
+	}
+	switch {
+	case f.DeadbandType == deadbandNone:
+	case f.DeadbandType == deadbandAbsolute && f.DeadbandValue >= 0:
+	default:
+		return f, StatusBadMonitoredItemFilterUnsupported
+	}
+	return f, StatusGood
+}
+
+// reviseItem 修正采样间隔和队列长度；值变化由 Update 推送，采样间隔仅作回显
+func reviseItem(sub *serverSubscription, sampling float64, queueSize uint32) (float64, uint32) {
+	if sampling < 0 || math.IsNaN(sampling) {
+		sampling = float64(sub.interval.Milliseconds())
+	}
+	return sampling, min(max(queueSize, 1), maxQueueSize)
+}
+
+func (s *Server) createMonitoredItems(sess *serverSession, h responseHeader, r *createMonitoredItemsRequest) message {
+	sub := sess.subs[r.SubscriptionID]
+	switch {
+	case sub == nil:
+		return &serviceFault{Header: withStatus(h, StatusBadSubscriptionIDInvalid)}
+	case r.TimestampsToReturn > timestampsNeither:
+		return &serviceFault{Header: withStatus(h, StatusBadTimestampsToReturnInvalid)}
+	case len(r.ItemsToCreate) == 0:
+		return &serviceFault{Header: withStatus(h, StatusBadNothingToDo)}
+	}
+	resp := &createMonitoredItemsResponse{Header: h}
+	total := s.monitoredCount()
+	for _, req := range r.ItemsToCreate {
+		n := s.space.node(req.ItemToMonitor.NodeID)
+		attr := req.ItemToMonitor.AttributeID
+		var status StatusCode
+		switch {
+		case n == nil:
+			status = StatusBadNodeIDUnknown
+		case n.attribute(attr).Status == StatusBadAttributeIDInvalid:
+			status = StatusBadAttributeIDInvalid
+		case req.ItemToMonitor.IndexRange != "":
+			status = StatusBadIndexRangeInvalid
+		case req.MonitoringMode > monitoringReporting:
+			status = StatusBadMonitoringModeInvalid
+		case total >= maxMonitoredItems:
+			status = StatusBadTooManyMonitoredItems
+		}
+		filter, filterStatus := parseFilter(attr, req.Filter)
+		if status == StatusGood {
+			status = filterStatus
+		}
+		if status != StatusGood {
+			resp.Results = append(resp.Results, monitoredItemCreateResult{StatusCode: status})
+			continue
+		}
+		s.lastID++
+		it := &monitoredItem{
+			id:            s.lastID,
+			sub:           sub,
+			node:          n,
+			attr:          attr,
+			handle:        req.ClientHandle,
+			mode:          req.MonitoringMode,
+			timestamps:    r.TimestampsToReturn,
+			discardOldest: req.DiscardOldest,
+			filter:        filter,
+		}
+		sampling, queueSize := reviseItem(sub, req.SamplingInterval, req.QueueSize)
+		it.queueSize = queueSize
+		sub.items[it.id] = it
+		if s.monitors[n] == nil {
+			s.monitors[n] = make(map[*monitoredItem]bool)
+		}
+		s.monitors[n][it] = true
+		total++
+		// 第一个采样为当前值
+		it.sample(n.attribute(attr))
+		resp.Results = append(resp.Results, monitoredItemCreateResult{MonitoredItemID: it.id, RevisedSamplingInterval: sampling, RevisedQueueSize: queueSize})
+	}
+	return resp
+}
+
+func (s *Server) modifyMonitoredItems(sess *serverSession, h responseHeader, r *modifyMonitoredItemsRequest) message {
+	sub := sess.subs[r.SubscriptionID]
+	switch {
+	case sub == nil:
+		return &serviceFault{Header: withStatus(h, StatusBadSubscriptionIDInvalid)}
+	case r.TimestampsToReturn > timestampsNeither:
+		return &serviceFault{Header: withStatus(h, StatusBadTimestampsToReturnInvalid)}
+	case len(r.ItemsToModify) == 0:
+		return &serviceFault{Header: withStatus(h, StatusBadNothingToDo)}
+	}
+	resp := &modifyMonitoredItemsResponse{Header: h}
+	for _, req := range r.ItemsToModify {
+		it := sub.items[req.MonitoredItemID]
+		if it == nil {
+			resp.Results = append(resp.Results, monitoredItemModifyResult{StatusCode: StatusBadMonitoredItemIDInvalid})
+			continue
+		}
+		filter, status := parseFilter(it.attr, req.Filter)
+		if status != StatusGood {
+			resp.Results = append(resp.Results, monitoredItemModifyResult{StatusCode: status})
+			continue
+		}
+		sampling, queueSize := reviseItem(sub, req.SamplingInterval, req.QueueSize)
+		it.handle, it.timestamps, it.filter, it.discardOldest, it.queueSize = req.ClientHandle, r.TimestampsToReturn, filter, req.DiscardOldest, queueSize
+		if uint32(len(it.queue)) > queueSize {
+			it.queue = it.queue[uint32(len(it.queue))-queueSize:]
+		}
+		resp.Results = append(resp.Results, monitoredItemModifyResult{RevisedSamplingInterval: sampling, RevisedQueueSize: queueSize})
+	}
+	return resp
+}
+
+func (s *Server) setMonitoringMode(sess *serverSession, h responseHeader, r *setMonitoringModeRequest) message {
+	sub := sess.subs[r.SubscriptionID]
+	switch {
+	case sub == nil:
+		return &serviceFault{Header: withStatus(h, StatusBadSubscriptionIDInvalid)}
+	case r.MonitoringMode > monitoringReporting:
+		return &serviceFault{Header: withStatus(h, StatusBadMonitoringModeInvalid)}
+	case len(r.MonitoredItemIDs) == 0:
+		return &serviceFault{Header: withStatus(h, StatusBadNothingToDo)}
+	}
+	resp := &setMonitoringModeResponse{Header: h}
+	for _, id := range r.MonitoredItemIDs {
+		it := sub.items[id]
+		if it == nil {
+			resp.Results = append(resp.Results, StatusBadMonitoredItemIDInvalid)
+			continue
+		}
+		prev := it.mode
+		it.mode = r.MonitoringMode
+		switch {
+		case it.mode == monitoringDisabled:
+			it.queue, it.last = nil, nil
+		case prev == monitoringDisabled:
+			// 重新启用时以当前值作为第一个采样
+			it.sample(it.node.attribute(it.attr))
+		}
+		resp.Results = append(resp.Results, StatusGood)
+	}
+	return resp
+}
+
+func (s *Server) deleteMonitoredItems(sess *serverSession, h responseHeader, r *deleteMonitoredItemsRequest) message {
+	sub := sess.subs[r.SubscriptionID]
+	switch {
+	case sub == nil:
+		return &serviceFault{Header: withStatus(h, StatusBadSubscriptionIDInvalid)}
+	case len(r.MonitoredItemIDs) == 0:
+		return &serviceFault{Header: withStatus(h, StatusBadNothingToDo)}
+	}
+	resp := &deleteMonitoredItemsResponse{Header: h}
+	for _, id := range r.MonitoredItemIDs {
+		it := sub.items[id]
+		if it == nil {
+			resp.Results = append(resp.Results, StatusBadMonitoredItemIDInvalid)
+			continue
+		}
+		s.unmonitor(it)
+		resp.Results = append(resp.Results, StatusGood)
+	}
+	return resp
+}
+
+// publish 确认已收到的通知并排队 Publish 请求；有过期订阅或积压的通知时立即应答
+func (s *Server) publish(sess *serverSession, ch *secureChannel, requestID uint32, r *publishRequest) message {
+	h := responseHeader{Timestamp: time.Now(), RequestHandle: r.Header.RequestHandle}
+	results := make([]StatusCode, 0, len(r.Acknowledgements))
+	for _, ack := range r.Acknowledgements {
+		results = append(results, sess.acknowledge(ack))
+	}
+	if len(sess.subs) == 0 && len(sess.expired) == 0 {
+		return &serviceFault{Header: withStatus(h, StatusBadNoSubscription)}
+	}
+	if len(sess.publishQ) >= maxPublishRequests {
+		p := sess.nextPublish()
+		s.outbox = append(s.outbox, outMessage{p.ch, p.requestID, &serviceFault{Header: responseHeader{Timestamp: h.Timestamp, RequestHandle: p.handle, ServiceResult: StatusBadTooManyPublishRequests}}})
+	}
+	p := &pendingPublish{ch: ch, requestID: requestID, handle: r.Header.RequestHandle, results: results}
+	if r.Header.TimeoutHint > 0 {
+		p.deadline = h.Timestamp.Add(time.Duration(r.Header.TimeoutHint) * time.Millisecond)
+	}
+	sess.publishQ = append(sess.publishQ, p)
+
+	if len(sess.expired) > 0 {
+		sub := sess.expired[0]
+		sess.expired = sess.expired[1:]
+		sub.seq = nextSequence(sub.seq)
+		n := statusChangeNotification{Status: StatusBadTimeout}
+		s.sendPublish(sub, notificationMessage{SequenceNumber: sub.seq, PublishTime: h.Timestamp, NotificationData: []ExtensionObject{encodeObject(&n)}}, false)
+		return nil
+	}
+	for _, sub := range sess.subs {
+		if sub.late {
+			s.cycleLate(sub, h.Timestamp)
+			break
+		}
+	}
+	return nil
+}
+
+// cycleLate 积压的订阅收到 Publish 请求后立即发送
+func (s *Server) cycleLate(sub *serverSubscription, now time.Time) {
+	if sub.enabled && sub.hasNotifications() {
+		s.sendNotifications(sub, now)
+		return
+	}
+	s.sendKeepAlive(sub, now)
+}
+
+// acknowledge 从重发队列删除已确认的通知
+func (sess *serverSession) acknowledge(ack subscriptionAcknowledgement) StatusCode {
+	sub := sess.subs[ack.SubscriptionID]
+	if sub == nil {
+		return StatusBadSubscriptionIDInvalid
+	}
+	for i, m := range sub.retransmit {
+		if m.SequenceNumber == ack.SequenceNumber {
+			sub.retransmit = append(sub.retransmit[:i], sub.retransmit[i+1:]...)
+			return StatusGood
+		}
+	}
+	return StatusBadSequenceNumberUnknown
+}
+
+// republish 重发尚未确认的通知
+func (s *Server) republish(sess *serverSession, h responseHeader, r *republishRequest) message {
+	sub := sess.subs[r.SubscriptionID]
+	if sub == nil {
+		return &serviceFault{Header: withStatus(h, StatusBadSubscriptionIDInvalid)}
+	}
+	for _, m := range sub.retransmit {
+		if m.SequenceNumber == r.RetransmitSequenceNumber {
+			return &republishResponse{Header: h, NotificationMessage: m}
+		}
+	}
+	return &serviceFault{Header: withStatus(h, StatusBadMessageNotAvailable)}
+}
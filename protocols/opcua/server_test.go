@@ -0,0 +1,260 @@
+package opcua
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"sensor-edge/protocols"
+)
+
+var serverFolders = []ServerFolder{
+	{Name: "boiler", DisplayName: "锅炉", Description: "1 号锅炉", Variables: []ServerVariable{
+		{Name: "temp", Description: "出水温度", Unit: "°C"},
+		{Name: "setpoint", Unit: "°C", Writable: true},
+		{Name: "running", DataType: "Boolean"},
+		{Name: "count", DataType: "Int32", Writable: true},
+	}},
+	{Name: "meter"},
+}
+
+func newTestServer(t *testing.T) (*Server, map[string]interface{}) {
+	srv, err := NewServer(ServerConfig{
+		Listen:    "127.0.0.1:0",
+		Anonymous: true,
+		Users:     map[string]string{"operator": "secret"},
+	}, serverFolders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { srv.Close() })
+	writes := make(map[string]interface{})
+	var mu sync.Mutex
+	srv.SetWriteHandler(func(folder, variable string, value interface{}) error {
+		if variable == "count" && value == int32(13) {
+			return errors.New("device offline")
+		}
+		mu.Lock()
+		writes[folder+"."+variable] = value
+		mu.Unlock()
+		return nil
+	})
+	srv.Update("boiler", "temp", 21.5, time.Now())
+	srv.Update("boiler", "running", 1, time.Now())
+	srv.Update("boiler", "count", 6.6, time.Now())
+	return srv, writes
+}
+
+func TestServerReadWrite(t *testing.T) {
+	srv, _ := newTestServer(t)
+	endpoint := "opc.tcp://" + srv.Addr().String()
+	if err := srv.Update("boiler", "missing", 1, time.Now()); err == nil {
+		t.Error("expect unknown variable error")
+	}
+	if err := srv.Update("boiler", "count", "abc", time.Now()); err == nil {
+		t.Error("expect conversion error")
+	}
+
+	cases := []map[string]interface{}{
+		{},
+		{"security_policy": "Basic256Sha256", "security_mode": "Sign", "trust_any_server_cert": true,
+			"auth": map[string]interface{}{"type": "username", "username": "operator", "password": "secret"}},
+		{"security_policy": "Basic256Sha256", "security_mode": "SignAndEncrypt", "trust_any_server_cert": true},
+	}
+	for _, config := range cases {
+		t.Run(fmt.Sprint(config["security_mode"]), func(t *testing.T) {
+			srv, writes := newTestServer(t)
+			config["endpoint"] = "opc.tcp://" + srv.Addr().String()
+			config["timeout"] = 2000
+			c := &OPCUAClient{}
+			if err := c.Init(config); err != nil {
+				t.Fatal(err)
+			}
+			defer c.Close()
+			values, err := c.ReadBatch("boiler", "", []string{"ns=1;s=boiler.temp", "ns=1;s=boiler.running", "ns=1;s=boiler.count", "ns=1;s=boiler.setpoint", "ns=1;s=boiler.none"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []string{"21.5 good", "true good", "7 good", "<nil> bad:BadWaitingForInitialData", "<nil> bad:BadNodeIdUnknown"}
+			for i, v := range values {
+				if got := fmt.Sprint(v.Value, " ", v.Quality); got != want[i] {
+					t.Errorf("%s = %s, want %s", v.PointID, got, want[i])
+				}
+			}
+
+			// 写入转发到处理函数，成功后变量取写入的值
+			if err := c.Write("ns=1;s=boiler.setpoint", "55"); err != nil {
+				t.Fatal(err)
+			}
+			if writes["boiler.setpoint"] != 55.0 {
+				t.Errorf("forwarded %v", writes)
+			}
+			values, _ = c.ReadBatch("boiler", "", []string{"ns=1;s=boiler.setpoint"})
+			if values[0].Value != 55.0 {
+				t.Errorf("setpoint = %v", values[0].Value)
+			}
+			var s StatusCode
+			if err := c.Write("ns=1;s=boiler.temp", 1); !errors.As(err, &s) || s != StatusBadNotWritable {
+				t.Errorf("write read-only: %v", err)
+			}
+			if err := c.Write("ns=1;s=boiler.count", 13); !errors.As(err, &s) || s != StatusBadCommunicationError {
+				t.Errorf("write failed device: %v", err)
+			}
+		})
+	}
+
+	for _, auth := range []map[string]interface{}{
+		{"type": "username", "username": "operator", "password": "wrong"},
+		{"type": "username", "username": "nobody", "password": "secret"},
+	} {
+		c := &OPCUAClient{}
+		c.Init(map[string]interface{}{"endpoint": endpoint, "security_policy": "Basic256Sha256", "trust_any_server_cert": true, "auth": auth})
+		if _, err := c.ReadBatch("boiler", "", []string{"ns=1;s=boiler.temp"}); err == nil {
+			t.Errorf("%v: expect activation error", auth)
+		}
+		c.Close()
+	}
+}
+
+// TestServerPlaintextPassword 只启用 None 策略的服务端要求明文密码，客户端需显式允许
+func TestServerPlaintextPassword(t *testing.T) {
+	srv, err := NewServer(ServerConfig{Listen: "127.0.0.1:0", SecurityPolicies: []string{"None"}, Users: map[string]string{"operator": "secret"}}, serverFolders)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	for _, allow := range []bool{false, true} {
+		c := &OPCUAClient{}
+		if err := c.Init(map[string]interface{}{"endpoint": "opc.tcp://" + srv.Addr().String(), "allow_plaintext_password": allow,
+			"auth": map[string]interface{}{"type": "username", "username": "operator", "password": "secret"}}); err != nil {
+			t.Fatal(err)
+		}
+		_, err := c.ReadBatch("boiler", "", []string{"ns=1;s=boiler.temp"})
+		if (err == nil) != allow {
+			t.Errorf("allow_plaintext_password %v: %v", allow, err)
+		}
+		c.Close()
+	}
+}
+
+func TestServerBrowse(t *testing.T) {
+	srv, _ := newTestServer(t)
+	c := &OPCUAClient{}
+	if err := c.Init(map[string]interface{}{"endpoint": "opc.tcp://" + srv.Addr().String()}); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	conn, err := c.session()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 分页浏览文件夹下的变量
+	m, err := conn.send(&browseRequest{RequestedMaxReferencesPerNode: 3, NodesToBrowse: []browseDescription{{
+		NodeID: gatewayNodeID("boiler"), BrowseDirection: browseForward, ReferenceTypeID: NewNumericNodeID(0, idHierarchicalReferences),
+		IncludeSubtypes: true, NodeClassMask: nodeClassVariable, ResultMask: 0x3f,
+	}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := m.(*browseResponse).Results[0]
+	names := []string{}
+	for _, r := range res.References {
+		names = append(names, r.BrowseName.Name)
+	}
+	if res.ContinuationPoint == nil {
+		t.Fatalf("expect continuation point, got %v", names)
+	}
+	m, err = conn.send(&browseNextRequest{ContinuationPoints: [][]byte{res.ContinuationPoint}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range m.(*browseNextResponse).Results[0].References {
+		names = append(names, r.BrowseName.Name)
+	}
+	if fmt.Sprint(names) != "[temp setpoint running count]" {
+		t.Errorf("browse boiler = %v", names)
+	}
+
+	// 路径解析和属性读取
+	m, err = conn.send(&translateBrowsePathsRequest{BrowsePaths: []browsePath{
+		{StartingNode: NewNumericNodeID(0, idRootFolder), RelativePath: []relativePathElement{
+			{ReferenceTypeID: NewNumericNodeID(0, idHierarchicalReferences), IncludeSubtypes: true, TargetName: QualifiedName{Name: "Objects"}},
+			{ReferenceTypeID: NewNumericNodeID(0, idHierarchicalReferences), IncludeSubtypes: true, TargetName: QualifiedName{NamespaceIndex: 1, Name: "boiler"}},
+			{ReferenceTypeID: NewNumericNodeID(0, idHierarchicalReferences), IncludeSubtypes: true, TargetName: QualifiedName{NamespaceIndex: 1, Name: "temp"}},
+			{ReferenceTypeID: NewNumericNodeID(0, idHasProperty), TargetName: QualifiedName{Name: "EngineeringUnits"}},
+		}},
+		{StartingNode: NewNumericNodeID(0, idObjectsFolder), RelativePath: []relativePathElement{
+			{ReferenceTypeID: NewNumericNodeID(0, idOrganizes), TargetName: QualifiedName{NamespaceIndex: 1, Name: "pump"}},
+		}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := m.(*translateBrowsePathsResponse).Results
+	if len(paths[0].Targets) != 1 || paths[0].Targets[0].TargetID.String() != "ns=1;s=boiler.temp.EngineeringUnits" || paths[1].StatusCode != StatusBadNoMatch {
+		t.Errorf("translate = %+v", paths)
+	}
+	values, err := conn.read([]readValueID{
+		{NodeID: gatewayNodeID("boiler", "temp"), AttributeID: attrDescription},
+		{NodeID: gatewayNodeID("boiler", "count"), AttributeID: attrDataType},
+		{NodeID: gatewayNodeID("boiler", "count"), AttributeID: attrAccessLevel},
+		{NodeID: gatewayNodeID("boiler", "temp", "EngineeringUnits"), AttributeID: attrValue},
+		{NodeID: NewNumericNodeID(0, idNamespaceArray), AttributeID: attrValue},
+		{NodeID: gatewayNodeID("boiler"), AttributeID: attrValueRank},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	eu, _ := values[3].Value.(ExtensionObject)
+	if values[0].Value != (LocalizedText{Text: "出水温度"}) || fmt.Sprint(values[1].Value) != "i=6" ||
+		values[2].Value != byte(accessRead|accessWrite) || eu.TypeID.Numeric != idEUInformationEncoding ||
+		fmt.Sprint(values[4].Value) != "[http://opcfoundation.org/UA/ urn:sensor-edge:gateway]" || values[5].Status != StatusBadAttributeIDInvalid {
+		t.Errorf("read attributes = %+v", values)
+	}
+}
+
+func TestServerSubscription(t *testing.T) {
+	srv, _ := newTestServer(t)
+	c := &OPCUAClient{}
+	err := c.Init(map[string]interface{}{
+		"endpoint": "opc.tcp://" + srv.Addr().String(), "security_policy": "Basic256Sha256", "security_mode": "SignAndEncrypt", "trust_any_server_cert": true,
+		"publishing_interval": 100, "timeout": 2000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	pushed := make(chan protocols.PointValue, 16)
+	c.SetValueHandler("boiler", func(values []protocols.PointValue) {
+		for _, v := range values {
+			pushed <- v
+		}
+	})
+	c.SetPointConfigs("boiler", []protocols.PointConfig{
+		{PointID: "temp", Address: "ns=1;s=boiler.temp", Options: map[string]interface{}{"subscribe": true}},
+	})
+	expectPush := func(want string) {
+		t.Helper()
+		select {
+		case v := <-pushed:
+			if got := fmt.Sprint(v.Value, " ", v.Quality); got != want {
+				t.Errorf("pushed %s, want %s", got, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("no notification for %s", want)
+		}
+	}
+	if _, err := c.ReadBatch("boiler", "", []string{"ns=1;s=boiler.temp"}); err != nil {
+		t.Fatal(err)
+	}
+	expectPush("21.5 good")
+	srv.Update("boiler", "temp", 22.0, time.Now())
+	expectPush("22 good")
+	// 相同的值不产生通知，采集失败推送坏质量
+	srv.Update("boiler", "temp", 22.0, time.Now())
+	srv.Update("boiler", "temp", nil, time.Now())
+	expectPush("<nil> bad:BadNoCommunication")
+}
@@ -0,0 +1,1778 @@
+package opcua
+
+import (
+	"fmt"
+	"time"
+)
+
+// 二进制编码的类型 NodeId（ns=0）
+const (
+	idAnonymousIdentityToken      = 321
+	idUserNameIdentityToken       = 324
+	idX509IdentityToken           = 327
+	idServiceFault                = 397
+	idFindServersRequest          = 422
+	idFindServersResponse         = 425
+	idGetEndpointsRequest         = 428
+	idGetEndpointsResponse        = 431
+	idOpenSecureChannelRequest    = 446
+	idOpenSecureChannelResponse   = 449
+	idCloseSecureChannelRequest   = 452
+	idCreateSessionRequest        = 461
+	idCreateSessionResponse       = 464
+	idActivateSessionRequest      = 467
+	idActivateSessionResponse     = 470
+	idCloseSessionRequest         = 473
+	idCloseSessionResponse        = 476
+	idBrowseRequest               = 527
+	idBrowseResponse              = 530
+	idBrowseNextRequest           = 533
+	idBrowseNextResponse          = 536
+	idTranslateBrowsePathsRequest = 554
+	idTranslateBrowsePathsResp    = 557
+	idReadRequest                 = 631
+	idReadResponse                = 634
+	idWriteRequest                = 673
+	idWriteResponse               = 676
+	idCreateMonitoredItemsRequest = 751
+	idCreateMonitoredItemsResp    = 754
+	idModifyMonitoredItemsRequest = 763
+	idModifyMonitoredItemsResp    = 766
+	idSetMonitoringModeRequest    = 769
+	idSetMonitoringModeResponse   = 772
+	idDeleteMonitoredItemsRequest = 781
+	idDeleteMonitoredItemsResp    = 784
+	idCreateSubscriptionRequest   = 787
+	idCreateSubscriptionResponse  = 790
+	idModifySubscriptionRequest   = 793
+	idModifySubscriptionResponse  = 796
+	idSetPublishingModeRequest    = 799
+	idSetPublishingModeResponse   = 802
+	idDataChangeFilter            = 724
+	idDataChangeNotification      = 811
+	idStatusChangeNotification    = 820
+	idPublishRequest              = 826
+	idPublishResponse             = 829
+	idRepublishRequest            = 832
+	idRepublishResponse           = 835
+	idDeleteSubscriptionsRequest  = 847
+	idDeleteSubscriptionsResponse = 850
+)
+
+// 属性编号
+const (
+	attrNodeID          = 1
+	attrNodeClass       = 2
+	attrBrowseName      = 3
+	attrDisplayName     = 4
+	attrDescription     = 5
+	attrWriteMask       = 6
+	attrUserWriteMask   = 7
+	attrIsAbstract      = 8
+	attrSymmetric       = 9
+	attrInverseName     = 10
+	attrEventNotifier   = 12
+	attrValue           = 13
+	attrDataType        = 14
+	attrValueRank       = 15
+	attrArrayDimensions = 16
+	attrAccessLevel     = 17
+	attrUserAccessLevel = 18
+	attrMinSampling     = 19
+	attrHistorizing     = 20
+)
+
+// 安全模式
+const (
+	securityModeNone           = 1
+	securityModeSign           = 2
+	securityModeSignAndEncrypt = 3
+)
+
+// 用户令牌类型
+const (
+	tokenAnonymous   = 0
+	tokenUserName    = 1
+	tokenCertificate = 2
+)
+
+// message 可编码、解码的服务报文
+type message interface {
+	encodable
+	decode(d *decoder)
+}
+
+// messageTypes 按类型 NodeId 创建报文，用于解码
+var messageTypes = map[uint32]func() message{
+	idServiceFault:                func() message { return &serviceFault{} },
+	idGetEndpointsRequest:         func() message { return &getEndpointsRequest{} },
+	idGetEndpointsResponse:        func() message { return &getEndpointsResponse{} },
+	idOpenSecureChannelRequest:    func() message { return &openSecureChannelRequest{} },
+	idOpenSecureChannelResponse:   func() message { return &openSecureChannelResponse{} },
+	idCloseSecureChannelRequest:   func() message { return &closeSecureChannelRequest{} },
+	idCreateSessionRequest:        func() message { return &createSessionRequest{} },
+	idCreateSessionResponse:       func() message { return &createSessionResponse{} },
+	idActivateSessionRequest:      func() message { return &activateSessionRequest{} },
+	idActivateSessionResponse:     func() message { return &activateSessionResponse{} },
+	idCloseSessionRequest:         func() message { return &closeSessionRequest{} },
+	idCloseSessionResponse:        func() message { return &closeSessionResponse{} },
+	idReadRequest:                 func() message { return &readRequest{} },
+	idReadResponse:                func() message { return &readResponse{} },
+	idWriteRequest:                func() message { return &writeRequest{} },
+	idWriteResponse:               func() message { return &writeResponse{} },
+	idCreateSubscriptionRequest:   func() message { return &createSubscriptionRequest{} },
+	idCreateSubscriptionResponse:  func() message { return &createSubscriptionResponse{} },
+	idCreateMonitoredItemsRequest: func() message { return &createMonitoredItemsRequest{} },
+	idCreateMonitoredItemsResp:    func() message { return &createMonitoredItemsResponse{} },
+	idPublishRequest:              func() message { return &publishRequest{} },
+	idPublishResponse:             func() message { return &publishResponse{} },
+	idDeleteSubscriptionsRequest:  func() message { return &deleteSubscriptionsRequest{} },
+	idDeleteSubscriptionsResponse: func() message { return &deleteSubscriptionsResponse{} },
+	idFindServersRequest:          func() message { return &findServersRequest{} },
+	idFindServersResponse:         func() message { return &findServersResponse{} },
+	idBrowseRequest:               func() message { return &browseRequest{} },
+	idBrowseResponse:              func() message { return &browseResponse{} },
+	idBrowseNextRequest:           func() message { return &browseNextRequest{} },
+	idBrowseNextResponse:          func() message { return &browseNextResponse{} },
+	idTranslateBrowsePathsRequest: func() message { return &translateBrowsePathsRequest{} },
+	idTranslateBrowsePathsResp:    func() message { return &translateBrowsePathsResponse{} },
+	idModifySubscriptionRequest:   func() message { return &modifySubscriptionRequest{} },
+	idModifySubscriptionResponse:  func() message { return &modifySubscriptionResponse{} },
+	idSetPublishingModeRequest:    func() message { return &setPublishingModeRequest{} },
+	idSetPublishingModeResponse:   func() message { return &setPublishingModeResponse{} },
+	idModifyMonitoredItemsRequest: func() message { return &modifyMonitoredItemsRequest{} },
+	idModifyMonitoredItemsResp:    func() message { return &modifyMonitoredItemsResponse{} },
+	idSetMonitoringModeRequest:    func() message { return &setMonitoringModeRequest{} },
+	idSetMonitoringModeResponse:   func() message { return &setMonitoringModeResponse{} },
+	idDeleteMonitoredItemsRequest: func() message { return &deleteMonitoredItemsRequest{} },
+	idDeleteMonitoredItemsResp:    func() message { return &deleteMonitoredItemsResponse{} },
+	idRepublishRequest:            func() message { return &republishRequest{} },
+	idRepublishResponse:           func() message { return &republishResponse{} },
+}
+
+// encodeMessage 编码报文体：类型 NodeId + 报文
+func encodeMessage(m message) []byte {
+	var e encoder
+	e.nodeID(NewNumericNodeID(0, m.typeID()))
+	m.encode(&e)
+	return e.bytes()
+}
+
+// decodeMessage 解码报文体，未知类型返回 *unknownMessage（只含请求头，用于应答 ServiceFault）
+func decodeMessage(b []byte) (message, error) {
+	d := newDecoder(b)
+	id := d.expandedNodeID()
+	if d.err != nil {
+		return nil, d.err
+	}
+	var m message
+	if id.Namespace == 0 && id.Type == idNumeric {
+		if f, ok := messageTypes[id.Numeric]; ok {
+			m = f()
+		}
+	}
+	if m == nil {
+		m = &unknownMessage{id: id}
+	}
+	m.decode(d)
+	if d.err != nil {
+		return nil, fmt.Errorf("decode %T: %w", m, d.err)
+	}
+	return m, nil
+}
+
+type unknownMessage struct {
+	id     NodeID
+	Header requestHeader
+}
+
+func (m *unknownMessage) typeID() uint32         { return m.id.Numeric }
+func (m *unknownMessage) encode(e *encoder)      {}
+func (m *unknownMessage) decode(d *decoder)      { m.Header.decode(d) }
+func (m *unknownMessage) header() *requestHeader { return &m.Header }
+
+// request 请求报文
+type request interface {
+	message
+	header() *requestHeader
+}
+
+// response 应答报文
+type response interface {
+	message
+	responseHeader() *responseHeader
+}
+
+type requestHeader struct {
+	AuthenticationToken NodeID
+	Timestamp           time.Time
+	RequestHandle       uint32
+	ReturnDiagnostics   uint32
+	AuditEntryID        string
+	TimeoutHint         uint32
+}
+
+func (h *requestHeader) encode(e *encoder) {
+	e.nodeID(h.AuthenticationToken)
+	e.dateTime(h.Timestamp)
+	e.uint32(h.RequestHandle)
+	e.uint32(h.ReturnDiagnostics)
+	e.string(h.AuditEntryID)
+	e.uint32(h.TimeoutHint)
+	e.extensionObject(ExtensionObject{})
+}
+
+func (h *requestHeader) decode(d *decoder) {
+	h.AuthenticationToken = d.nodeID()
+	h.Timestamp = d.dateTime()
+	h.RequestHandle = d.uint32()
+	h.ReturnDiagnostics = d.uint32()
+	h.AuditEntryID = d.string()
+	h.TimeoutHint = d.uint32()
+	d.extensionObject()
+}
+
+type responseHeader struct {
+	Timestamp     time.Time
+	RequestHandle uint32
+	ServiceResult StatusCode
+}
+
+func (h *responseHeader) encode(e *encoder) {
+	e.dateTime(h.Timestamp)
+	e.uint32(h.RequestHandle)
+	e.uint32(uint32(h.ServiceResult))
+	e.byte(0)   // ServiceDiagnostics
+	e.int32(-1) // StringTable
+	e.extensionObject(ExtensionObject{})
+}
+
+func (h *responseHeader) decode(d *decoder) {
+	h.Timestamp = d.dateTime()
+	h.RequestHandle = d.uint32()
+	h.ServiceResult = StatusCode(d.uint32())
+	d.diagnosticInfo()
+	d.stringArray()
+	d.extensionObject()
+}
+
+type serviceFault struct {
+	Header responseHeader
+}
+
+func (m *serviceFault) typeID() uint32                  { return idServiceFault }
+func (m *serviceFault) encode(e *encoder)               { m.Header.encode(e) }
+func (m *serviceFault) decode(d *decoder)               { m.Header.decode(d) }
+func (m *serviceFault) responseHeader() *responseHeader { return &m.Header }
+
+type applicationDescription struct {
+	ApplicationURI      string
+	ProductURI          string
+	ApplicationName     LocalizedText
+	ApplicationType     uint32 // 0 Server, 1 Client
+	GatewayServerURI    string
+	DiscoveryProfileURI string
+	DiscoveryURLs       []string
+}
+
+func (a *applicationDescription) encode(e *encoder) {
+	e.string(a.ApplicationURI)
+	e.string(a.ProductURI)
+	e.localizedText(a.ApplicationName)
+	e.uint32(a.ApplicationType)
+	e.string(a.GatewayServerURI)
+	e.string(a.DiscoveryProfileURI)
+	e.stringArray(a.DiscoveryURLs)
+}
+
+func (a *applicationDescription) decode(d *decoder) {
+	a.ApplicationURI = d.string()
+	a.ProductURI = d.string()
+	a.ApplicationName = d.localizedText()
+	a.ApplicationType = d.uint32()
+	a.GatewayServerURI = d.string()
+	a.DiscoveryProfileURI = d.string()
+	a.DiscoveryURLs = d.stringArray()
+}
+
+type userTokenPolicy struct {
+	PolicyID          string
+	TokenType         uint32
+	IssuedTokenType   string
+	IssuerEndpointURL string
+	SecurityPolicyURI string
+}
+
+type endpointDescription struct {
+	EndpointURL         string
+	Server              applicationDescription
+	ServerCertificate   []byte
+	SecurityMode        uint32
+	SecurityPolicyURI   string
+	UserIdentityTokens  []userTokenPolicy
+	TransportProfileURI string
+	SecurityLevel       byte
+}
+
+func (ep *endpointDescription) encode(e *encoder) {
+	e.string(ep.EndpointURL)
+	ep.Server.encode(e)
+	e.byteString(ep.ServerCertificate)
+	e.uint32(ep.SecurityMode)
+	e.string(ep.SecurityPolicyURI)
+	e.int32(int32(len(ep.UserIdentityTokens)))
+	for _, t := range ep.UserIdentityTokens {
+		e.string(t.PolicyID)
+		e.uint32(t.TokenType)
+		e.string(t.IssuedTokenType)
+		e.string(t.IssuerEndpointURL)
+		e.string(t.SecurityPolicyURI)
+	}
+	e.string(ep.TransportProfileURI)
+	e.byte(ep.SecurityLevel)
+}
+
+func (ep *endpointDescription) decode(d *decoder) {
+	ep.EndpointURL = d.string()
+	ep.Server.decode(d)
+	ep.ServerCertificate = d.byteString()
+	ep.SecurityMode = d.uint32()
+	ep.SecurityPolicyURI = d.string()
+	n := d.arrayLength()
+	for i := 0; i < n && d.err == nil; i++ {
+		ep.UserIdentityTokens = append(ep.UserIdentityTokens, userTokenPolicy{
+			PolicyID:          d.string(),
+			TokenType:         d.uint32(),
+			IssuedTokenType:   d.string(),
+			IssuerEndpointURL: d.string(),
+			SecurityPolicyURI: d.string(),
+		})
+	}
+	ep.TransportProfileURI = d.string()
+	ep.SecurityLevel = d.byte()
+}
+
+func encodeEndpoints(e *encoder, eps []endpointDescription) {
+	e.int32(int32(len(eps)))
+	for i := range eps {
+		eps[i].encode(e)
+	}
+}
+
+func decodeEndpoints(d *decoder) []endpointDescription {
+	n := d.arrayLength()
+	var eps []endpointDescription
+	for i := 0; i < n && d.err == nil; i++ {
+		var ep endpointDescription
+		ep.decode(d)
+		eps = append(eps, ep)
+	}
+	return eps
+}
+
+type signatureData struct {
+	Algorithm string
+	Signature []byte
+}
+
+func (s *signatureData) encode(e *encoder) {
+	e.string(s.Algorithm)
+	e.byteString(s.Signature)
+}
+
+func (s *signatureData) decode(d *decoder) {
+	s.Algorithm = d.string()
+	s.Signature = d.byteString()
+}
+
+type getEndpointsRequest struct {
+	Header      requestHeader
+	EndpointURL string
+	LocaleIDs   []string
+	ProfileURIs []string
+}
+
+func (m *getEndpointsRequest) typeID() uint32         { return idGetEndpointsRequest }
+func (m *getEndpointsRequest) header() *requestHeader { return &m.Header }
+func (m *getEndpointsRequest) encode(e *encoder) {
+	m.Header.encode(e)
+	e.string(m.EndpointURL)
+	e.stringArray(m.LocaleIDs)
+	e.stringArray(m.ProfileURIs)
+}
+func (m *getEndpointsRequest) decode(d *decoder) {
+	m.Header.decode(d)
+	m.EndpointURL = d.string()
+	m.LocaleIDs = d.stringArray()
+	m.ProfileURIs = d.stringArray()
+}
+
+type getEndpointsResponse struct {
+	Header    responseHeader
+	Endpoints []endpointDescription
+}
+
+func (m *getEndpointsResponse) typeID() uint32                  { return idGetEndpointsResponse }
+func (m *getEndpointsResponse) responseHeader() *responseHeader { return &m.Header }
+func (m *getEndpointsResponse) encode(e *encoder) {
+	m.Header.encode(e)
+	encodeEndpoints(e, m.Endpoints)
+}
+func (m *getEndpointsResponse) decode(d *decoder) {
+	m.Header.decode(d)
+	m.Endpoints = decodeEndpoints(d)
+}
+
+type openSecureChannelRequest struct {
+	Header                requestHeader
+	ClientProtocolVersion uint32
+	RequestType           uint32 // 0 Issue, 1 Renew
+	SecurityMode          uint32
+	ClientNonce           []byte
+	RequestedLifetime     uint32
+}
+
+func (m *openSecureChannelRequest) typeID() uint32         { return idOpenSecureChannelRequest }
+func (m *openSecureChannelRequest) header() *requestHeader { return &m.Header }
+func (m *openSecureChannelRequest) encode(e *encoder) {
+	m.Header.encode(e)
+	e.uint32(m.ClientProtocolVersion)
+	e.uint32(m.RequestType)
+	e.uint32(m.SecurityMode)
+	e.byteString(m.ClientNonce)
+	e.uint32(m.RequestedLifetime)
+}
+func (m *openSecureChannelRequest) decode(d *decoder) {
+	m.Header.decode(d)
+	m.ClientProtocolVersion = d.uint32()
+	m.RequestType = d.uint32()
+	m.SecurityMode = d.uint32()
+	m.ClientNonce = d.byteString()
+	m.RequestedLifetime = d.uint32()
+}
+
+type channelSecurityToken struct {
+	ChannelID       uint32
+	TokenID         uint32
+	CreatedAt       time.Time
+	RevisedLifetime uint32 // 毫秒
+}
+
+type openSecureChannelResponse struct {
+	Header                responseHeader
+	ServerProtocolVersion uint32
+	SecurityToken         channelSecurityToken
+	ServerNonce           []byte
+}
+
+func (m *openSecureChannelResponse) typeID() uint32                  { return idOpenSecureChannelResponse }
+func (m *openSecureChannelResponse) responseHeader() *responseHeader { return &m.Header }
+func (m *openSecureChannelResponse) encode(e *encoder) {
+	m.Header.encode(e)
+	e.uint32(m.ServerProtocolVersion)
+	e.uint32(m.SecurityToken.ChannelID)
+	e.uint32(m.SecurityToken.TokenID)
+	e.dateTime(m.SecurityToken.CreatedAt)
+	e.uint32(m.SecurityToken.RevisedLifetime)
+	e.byteString(m.ServerNonce)
+}
+func (m *openSecureChannelResponse) decode(d *decoder) {
+	m.Header.decode(d)
+	m.ServerProtocolVersion = d.uint32()
+	m.SecurityToken = channelSecurityToken{ChannelID: d.uint32(), TokenID: d.uint32(), CreatedAt: d.dateTime(), RevisedLifetime: d.uint32()}
+	m.ServerNonce = d.byteString()
+}
+
+type closeSecureChannelRequest struct {
+	Header requestHeader
+}
+
+func (m *closeSecureChannelRequest) typeID() uint32         { return idCloseSecureChannelRequest }
+func (m *closeSecureChannelRequest) header() *requestHeader { return &m.Header }
+func (m *closeSecureChannelRequest) encode(e *encoder)      { m.Header.encode(e) }
+func (m *closeSecureChannelRequest) decode(d *decoder)      { m.Header.decode(d) }
+
+type createSessionRequest struct {
+	Header                  requestHeader
+	ClientDescription       applicationDescription
+	ServerURI               string
+	EndpointURL             string
+	SessionName             string
+	ClientNonce             []byte
+	ClientCertificate       []byte
+	RequestedSessionTimeout float64 // 毫秒
+	MaxResponseMessageSize  uint32
+}
+
+func (m *createSessionRequest) typeID() uint32         { return idCreateSessionRequest }
+func (m *createSessionRequest) header() *requestHeader { return &m.Header }
+func (m *createSessionRequest) encode(e *encoder) {
+	m.Header.encode(e)
+	m.ClientDescription.encode(e)
+	e.string(m.ServerURI)
+	e.string(m.EndpointURL)
+	e.string(m.SessionName)
+	e.byteString(m.ClientNonce)
+	e.byteString(m.ClientCertificate)
+	e.float64(m.RequestedSessionTimeout)
+	e.uint32(m.MaxResponseMessageSize)
+}
+func (m *createSessionRequest) decode(d *decoder) {
+	m.Header.decode(d)
+	m.ClientDescription.decode(d)
+	m.ServerURI = d.string()
+	m.EndpointURL = d.string()
+	m.SessionName = d.string()
+	m.ClientNonce = d.byteString()
+	m.ClientCertificate = d.byteString()
+	m.RequestedSessionTimeout = d.float64()
+	m.MaxResponseMessageSize = d.uint32()
+}
+
+type createSessionResponse struct {
+	Header                responseHeader
+	SessionID             NodeID
+	AuthenticationToken   NodeID
+	RevisedSessionTimeout float64
+	ServerNonce           []byte
+	ServerCertificate     []byte
+	ServerEndpoints       []endpointDescription
+	ServerSignature       signatureData
+	MaxRequestMessageSize uint32
+}
+
+func (m *createSessionResponse) typeID() uint32                  { return idCreateSessionResponse }
+func (m *createSessionResponse) responseHeader() *responseHeader { return &m.Header }
+func (m *createSessionResponse) encode(e *encoder) {
+	m.Header.encode(e)
+	e.nodeID(m.SessionID)
+	e.nodeID(m.AuthenticationToken)
+	e.float64(m.RevisedSessionTimeout)
+	e.byteString(m.ServerNonce)
+	e.byteString(m.ServerCertificate)
+	encodeEndpoints(e, m.ServerEndpoints)
+	e.int32(-1) // ServerSoftwareCertificates
+	m.ServerSignature.encode(e)
+	e.uint32(m.MaxRequestMessageSize)
+}
+func (m *createSessionResponse) decode(d *decoder) {
+	m.Header.decode(d)
+	m.SessionID = d.nodeID()
+	m.AuthenticationToken = d.nodeID()
+	m.RevisedSessionTimeout = d.float64()
+	m.ServerNonce = d.byteString()
+	m.ServerCertificate = d.byteString()
+	m.ServerEndpoints = decodeEndpoints(d)
+	n := d.arrayLength()
+	for i := 0; i < n && d.err == nil; i++ {
+		d.byteString()
+		d.byteString()
+	}
+	m.ServerSignature.decode(d)
+	m.MaxRequestMessageSize = d.uint32()
+}
+
+type anonymousIdentityToken struct {
+	PolicyID string
+}
+
+func (t *anonymousIdentityToken) typeID() uint32    { return idAnonymousIdentityToken }
+func (t *anonymousIdentityToken) encode(e *encoder) { e.string(t.PolicyID) }
+func (t *anonymousIdentityToken) decode(d *decoder) { t.PolicyID = d.string() }
+
+type userNameIdentityToken struct {
+	PolicyID            string
+	UserName            string
+	Password            []byte
+	EncryptionAlgorithm string
+}
+
+func (t *userNameIdentityToken) typeID() uint32 { return idUserNameIdentityToken }
+func (t *userNameIdentityToken) encode(e *encoder) {
+	e.string(t.PolicyID)
+	e.string(t.UserName)
+	e.byteString(t.Password)
+	e.string(t.EncryptionAlgorithm)
+}
+func (t *userNameIdentityToken) decode(d *decoder) {
+	t.PolicyID = d.string()
+	t.UserName = d.string()
+	t.Password = d.byteString()
+	t.EncryptionAlgorithm = d.string()
+}
+
+type x509IdentityToken struct {
+	PolicyID        string
+	CertificateData []byte
+}
+
+func (t *x509IdentityToken) typeID() uint32 { return idX509IdentityToken }
+func (t *x509IdentityToken) encode(e *encoder) {
+	e.string(t.PolicyID)
+	e.byteString(t.CertificateData)
+}
+func (t *x509IdentityToken) decode(d *decoder) {
+	t.PolicyID = d.string()
+	t.CertificateData = d.byteString()
+}
+
+type activateSessionRequest struct {
+	Header             requestHeader
+	ClientSignature    signatureData
+	LocaleIDs          []string
+	UserIdentityToken  ExtensionObject
+	UserTokenSignature signatureData
+}
+
+func (m *activateSessionRequest) typeID() uint32         { return idActivateSessionRequest }
+func (m *activateSessionRequest) header() *requestHeader { return &m.Header }
+func (m *activateSessionRequest) encode(e *encoder) {
+	m.Header.encode(e)
+	m.ClientSignature.encode(e)
+	e.int32(-1) // ClientSoftwareCertificates
+	e.stringArray(m.LocaleIDs)
+	e.extensionObject(m.UserIdentityToken)
+	m.UserTokenSignature.encode(e)
+}
+func (m *activateSessionRequest) decode(d *decoder) {
+	m.Header.decode(d)
+	m.ClientSignature.decode(d)
+	n := d.arrayLength()
+	for i := 0; i < n && d.err == nil; i++ {
+		d.byteString()
+		d.byteString()
+	}
+	m.LocaleIDs = d.stringArray()
+	m.UserIdentityToken = d.extensionObject()
+	m.UserTokenSignature.decode(d)
+}
+
+type activateSessionResponse struct {
+	Header      responseHeader
+	ServerNonce []byte
+	Results     []StatusCode
+}
+
+func (m *activateSessionResponse) typeID() uint32                  { return idActivateSessionResponse }
+func (m *activateSessionResponse) responseHeader() *responseHeader { return &m.Header }
+func (m *activateSessionResponse) encode(e *encoder) {
+	m.Header.encode(e)
+	e.byteString(m.ServerNonce)
+	e.statusCodes(m.Results)
+	e.int32(-1)
+}
+func (m *activateSessionResponse) decode(d *decoder) {
+	m.Header.decode(d)
+	m.ServerNonce = d.byteString()
+	m.Results = d.statusCodes()
+	d.diagnosticInfos()
+}
+
+type closeSessionRequest struct {
+	Header              requestHeader
+	DeleteSubscriptions bool
+}
+
+func (m *closeSessionRequest) typeID() uint32         { return idCloseSessionRequest }
+func (m *closeSessionRequest) header() *requestHeader { return &m.Header }
+func (m *closeSessionRequest) encode(e *encoder) {
+	m.Header.encode(e)
+	e.bool(m.DeleteSubscriptions)
+}
+func (m *closeSessionRequest) decode(d *decoder) {
+	m.Header.decode(d)
+	m.DeleteSubscriptions = d.bool()
+}
+
+type closeSessionResponse struct {
+	Header responseHeader
+}
+
+func (m *closeSessionResponse) typeID() uint32                  { return idCloseSessionResponse }
+func (m *closeSessionResponse) responseHeader() *responseHeader { return &m.Header }
+func (m *closeSessionResponse) encode(e *encoder)               { m.Header.encode(e) }
+func (m *closeSessionResponse) decode(d *decoder)               { m.Header.decode(d) }
+
+type readValueID struct {
+	NodeID      NodeID
+	AttributeID uint32
+	IndexRange  string
+	Encoding    QualifiedName
+}
+
+func (r *readValueID) encode(e *encoder) {
+	e.nodeID(r.NodeID)
+	e.uint32(r.AttributeID)
+	e.string(r.IndexRange)
+	e.qualifiedName(r.Encoding)
+}
+
+func (r *readValueID) decode(d *decoder) {
+	r.NodeID = d.nodeID()
+	r.AttributeID = d.uint32()
+	r.IndexRange = d.string()
+	r.Encoding = d.qualifiedName()
+}
+
+// 返回的时间戳
+const (
+	timestampsSource  = 0
+	timestampsServer  = 1
+	timestampsBoth    = 2
+	timestampsNeither = 3
+)
+
+type readRequest struct {
+	Header             requestHeader
+	MaxAge             float64
+	TimestampsToReturn uint32
+	NodesToRead        []readValueID
+}
+
+func (m *readRequest) typeID() uint32         { return idReadRequest }
+func (m *readRequest) header() *requestHeader { return &m.Header }
+func (m *readRequest) encode(e *encoder) {
+	m.Header.encode(e)
+	e.float64(m.MaxAge)
+	e.uint32(m.TimestampsToReturn)
+	e.int32(int32(len(m.NodesToRead)))
+	for i := range m.NodesToRead {
+		m.NodesToRead[i].encode(e)
+	}
+}
+func (m *readRequest) decode(d *decoder) {
+	m.Header.decode(d)
+	m.MaxAge = d.float64()
+	m.TimestampsToReturn = d.uint32()
+	m.NodesToRead = decodeArray(d, d.arrayLength(), func() readValueID {
+		var r readValueID
+		r.decode(d)
+		return r
+	})
+}
+
+type readResponse struct {
+	Header  responseHeader
+	Results []DataValue
+}
+
+func (m *readResponse) typeID() uint32                  { return idReadResponse }
+func (m *readResponse) responseHeader() *responseHeader { return &m.Header }
+func (m *readResponse) encode(e *encoder) {
+	m.Header.encode(e)
+	e.int32(int32(len(m.Results)))
+	for _, v := range m.Results {
+		e.dataValue(v)
+	}
+	e.int32(-1)
+}
+func (m *readResponse) decode(d *decoder) {
+	m.Header.decode(d)
+	m.Results = decodeArray(d, d.arrayLength(), d.dataValue)
+	d.diagnosticInfos()
+}
+
+type writeValue struct {
+	NodeID      NodeID
+	AttributeID uint32
+	IndexRange  string
+	Value       DataValue
+}
+
+type writeRequest struct {
+	Header       requestHeader
+	NodesToWrite []writeValue
+}
+
+func (m *writeRequest) typeID() uint32         { return idWriteRequest }
+func (m *writeRequest) header() *requestHeader { return &m.Header }
+func (m *writeRequest) encode(e *encoder) {
+	m.Header.encode(e)
+	e.int32(int32(len(m.NodesToWrite)))
+	for _, w := range m.NodesToWrite {
+		e.nodeID(w.NodeID)
+		e.uint32(w.AttributeID)
+		e.string(w.IndexRange)
+		e.dataValue(w.Value)
+	}
+}
+func (m *writeRequest) decode(d *decoder) {
+	m.Header.decode(d)
+	m.NodesToWrite = decodeArray(d, d.arrayLength(), func() writeValue {
+		return writeValue{NodeID: d.nodeID(), AttributeID: d.uint32(), IndexRange: d.string(), Value: d.dataValue()}
+	})
+}
+
+type writeResponse struct {
+	Header  responseHeader
+	Results []StatusCode
+}
+
+func (m *writeResponse) typeID() uint32                  { return idWriteResponse }
+func (m *writeResponse) responseHeader() *responseHeader { return &m.Header }
+func (m *writeResponse) encode(e *encoder) {
+	m.Header.encode(e)
+	e.statusCodes(m.Results)
+	e.int32(-1)
+}
+func (m *writeResponse) decode(d *decoder) {
+	m.Header.decode(d)
+	m.Results = d.statusCodes()
+	d.diagnosticInfos()
+}
+
+type createSubscriptionRequest struct {
+	Header                      requestHeader
+	RequestedPublishingInterval float64
+	RequestedLifetimeCount      uint32
+	RequestedMaxKeepAliveCount  uint32
+	MaxNotificationsPerPublish  uint32
+	PublishingEnabled           bool
+	Priority                    byte
+}
+
+func (m *createSubscriptionRequest) typeID() uint32         { return idCreateSubscriptionRequest }
+func (m *createSubscriptionRequest) header() *requestHeader { return &m.Header }
+func (m *createSubscriptionRequest) encode(e *encoder) {
+	m.Header.encode(e)
+	e.float64(m.RequestedPublishingInterval)
+	e.uint32(m.RequestedLifetimeCount)
+	e.uint32(m.RequestedMaxKeepAliveCount)
+	e.uint32(m.MaxNotificationsPerPublish)
+	e.bool(m.PublishingEnabled)
+	e.byte(m.Priority)
+}
+func (m *createSubscriptionRequest) decode(d *decoder) {
+	m.Header.decode(d)
+	m.RequestedPublishingInterval = d.float64()
+	m.RequestedLifetimeCount = d.uint32()
+	m.RequestedMaxKeepAliveCount = d.uint32()
+	m.MaxNotificationsPerPublish = d.uint32()
+	m.PublishingEnabled = d.bool()
+	m.Priority = d.byte()
+}
+
+type createSubscriptionResponse struct {
+	Header                    responseHeader
+	SubscriptionID            uint32
+	RevisedPublishingInterval float64
+	RevisedLifetimeCount      uint32
+	RevisedMaxKeepAliveCount  uint32
+}
+
+func (m *createSubscriptionResponse) typeID() uint32                  { return idCreateSubscriptionResponse }
+func (m *createSubscriptionResponse) responseHeader() *responseHeader { return &m.Header }
+func (m *createSubscriptionResponse) encode(e *encoder) {
+	m.Header.encode(e)
+	e.uint32(m.SubscriptionID)
+	e.float64(m.RevisedPublishingInterval)
+	e.uint32(m.RevisedLifetimeCount)
+	e.uint32(m.RevisedMaxKeepAliveCount)
+}
+func (m *createSubscriptionResponse) decode(d *decoder) {
+	m.Header.decode(d)
+	m.SubscriptionID = d.uint32()
+	m.RevisedPublishingInterval = d.float64()
+	m.RevisedLifetimeCount = d.uint32()
+	m.RevisedMaxKeepAliveCount = d.uint32()
+}
+
+// 监视模式
+const (
+	monitoringDisabled  = 0
+	monitoringSampling  = 1
+	monitoringReporting = 2
+)
+
+// 数据变化过滤器的触发条件和死区类型
+const (
+	triggerStatus               = 0
+	triggerStatusValue          = 1
+	triggerStatusValueTimestamp = 2
+	deadbandNone                = 0
+	deadbandAbsolute            = 1
+)
+
+// dataChangeFilter 监视项的数据变化过滤器
+type dataChangeFilter struct {
+	Trigger       uint32
+	DeadbandType  uint32
+	DeadbandValue float64
+}
+
+func (f *dataChangeFilter) typeID() uint32 { return idDataChangeFilter }
+func (f *dataChangeFilter) encode(e *encoder) {
+	e.uint32(f.Trigger)
+	e.uint32(f.DeadbandType)
+	e.float64(f.DeadbandValue)
+}
+func (f *dataChangeFilter) decode(d *decoder) {
+	f.Trigger = d.uint32()
+	f.DeadbandType = d.uint32()
+	f.DeadbandValue = d.float64()
+}
+
+type monitoredItemCreateRequest struct {
+	ItemToMonitor    readValueID
+	MonitoringMode   uint32
+	ClientHandle     uint32
+	SamplingInterval float64
+	Filter           ExtensionObject
+	QueueSize        uint32
+	DiscardOldest    bool
+}
+
+type createMonitoredItemsRequest struct {
+	Header             requestHeader
+	SubscriptionID     uint32
+	TimestampsToReturn uint32
+	ItemsToCreate      []monitoredItemCreateRequest
+}
+
+func (m *createMonitoredItemsRequest) typeID() uint32         { return idCreateMonitoredItemsRequest }
+func (m *createMonitoredItemsRequest) header() *requestHeader { return &m.Header }
+func (m *createMonitoredItemsRequest) encode(e *encoder) {
+	m.Header.encode(e)
+	e.uint32(m.SubscriptionID)
+	e.uint32(m.TimestampsToReturn)
+	e.int32(int32(len(m.ItemsToCreate)))
+	for i := range m.ItemsToCreate {
+		it := &m.ItemsToCreate[i]
+		it.ItemToMonitor.encode(e)
+		e.uint32(it.MonitoringMode)
+		e.uint32(it.ClientHandle)
+		e.float64(it.SamplingInterval)
+		e.extensionObject(it.Filter)
+		e.uint32(it.QueueSize)
+		e.bool(it.DiscardOldest)
+	}
+}
+func (m *createMonitoredItemsRequest) decode(d *decoder) {
+	m.Header.decode(d)
+	m.SubscriptionID = d.uint32()
+	m.TimestampsToReturn = d.uint32()
+	m.ItemsToCreate = decodeArray(d, d.arrayLength(), func() monitoredItemCreateRequest {
+		var it monitoredItemCreateRequest
+		it.ItemToMonitor.decode(d)
+		it.MonitoringMode = d.uint32()
+		it.ClientHandle = d.uint32()
+		it.SamplingInterval = d.float64()
+		it.Filter = d.extensionObject()
+		it.QueueSize = d.uint32()
+		it.DiscardOldest = d.bool()
+		return it
+	})
+}
+
+type monitoredItemCreateResult struct {
+	StatusCode              StatusCode
+	MonitoredItemID         uint32
+	RevisedSamplingInterval float64
+	RevisedQueueSize        uint32
+}
+
+type createMonitoredItemsResponse struct {
+	Header  responseHeader
+	Results []monitoredItemCreateResult
+}
+
+func (m *createMonitoredItemsResponse) typeID() uint32                  { return idCreateMonitoredItemsResp }
+func (m *createMonitoredItemsResponse) responseHeader() *responseHeader { return &m.Header }
+func (m *createMonitoredItemsResponse) encode(e *encoder) {
+	m.Header.encode(e)
+	e.int32(int32(len(m.Results)))
+	for _, r := range m.Results {
+		e.uint32(uint32(r.StatusCode))
+		e.uint32(r.MonitoredItemID)
+		e.float64(r.RevisedSamplingInterval)
+		e.uint32(r.RevisedQueueSize)
+		e.extensionObject(ExtensionObject{})
+	}
+	e.int32(-1)
+}
+func (m *createMonitoredItemsResponse) decode(d *decoder) {
+	m.Header.decode(d)
+	m.Results = decodeArray(d, d.arrayLength(), func() monitoredItemCreateResult {
+		r := monitoredItemCreateResult{StatusCode: StatusCode(d.uint32()), MonitoredItemID: d.uint32(), RevisedSamplingInterval: d.float64(), RevisedQueueSize: d.uint32()}
+		d.extensionObject()
+		return r
+	})
+	d.diagnosticInfos()
+}
+
+type subscriptionAcknowledgement struct {
+	SubscriptionID uint32
+	SequenceNumber uint32
+}
+
+type publishRequest struct {
+	Header           requestHeader
+	Acknowledgements []subscriptionAcknowledgement
+}
+
+func (m *publishRequest) typeID() uint32         { return idPublishRequest }
+func (m *publishRequest) header() *requestHeader { return &m.Header }
+func (m *publishRequest) encode(e *encoder) {
+	m.Header.encode(e)
+	e.int32(int32(len(m.Acknowledgements)))
+	for _, a := range m.Acknowledgements {
+		e.uint32(a.SubscriptionID)
+		e.uint32(a.SequenceNumber)
+	}
+}
+func (m *publishRequest) decode(d *decoder) {
+	m.Header.decode(d)
+	m.Acknowledgements = decodeArray(d, d.arrayLength(), func() subscriptionAcknowledgement {
+		return subscriptionAcknowledgement{SubscriptionID: d.uint32(), SequenceNumber: d.uint32()}
+	})
+}
+
+type notificationMessage struct {
+	SequenceNumber   uint32
+	PublishTime      time.Time
+	NotificationData []ExtensionObject
+}
+
+func (n *notificationMessage) encode(e *encoder) {
+	e.uint32(n.SequenceNumber)
+	e.dateTime(n.PublishTime)
+	e.int32(int32(len(n.NotificationData)))
+	for _, x := range n.NotificationData {
+		e.extensionObject(x)
+	}
+}
+
+func (n *notificationMessage) decode(d *decoder) {
+	n.SequenceNumber = d.uint32()
+	n.PublishTime = d.dateTime()
+	n.NotificationData = decodeArray(d, d.arrayLength(), d.extensionObject)
+}
+
+type publishResponse struct {
+	Header                   responseHeader
+	SubscriptionID           uint32
+	AvailableSequenceNumbers []uint32
+	MoreNotifications        bool
+	NotificationMessage      notificationMessage
+	Results                  []StatusCode
+}
+
+func (m *publishResponse) typeID() uint32                  { return idPublishResponse }
+func (m *publishResponse) responseHeader() *responseHeader { return &m.Header }
+func (m *publishResponse) encode(e *encoder) {
+	m.Header.encode(e)
+	e.uint32(m.SubscriptionID)
+	e.uint32Array(m.AvailableSequenceNumbers)
+	e.bool(m.MoreNotifications)
+	m.NotificationMessage.encode(e)
+	e.statusCodes(m.Results)
+	e.int32(-1)
+}
+func (m *publishResponse) decode(d *decoder) {
+	m.Header.decode(d)
+	m.SubscriptionID = d.uint32()
+	m.AvailableSequenceNumbers = d.uint32Array()
+	m.MoreNotifications = d.bool()
+	m.NotificationMessage.decode(d)
+	m.Results = d.statusCodes()
+	d.diagnosticInfos()
+}
+
+type monitoredItemNotification struct {
+	ClientHandle uint32
+	Value        DataValue
+}
+
+// dataChangeNotification 订阅的数据变化通知
+type dataChangeNotification struct {
+	MonitoredItems []monitoredItemNotification
+}
+
+func (n *dataChangeNotification) typeID() uint32 { return idDataChangeNotification }
+func (n *dataChangeNotification) encode(e *encoder) {
+	e.int32(int32(len(n.MonitoredItems)))
+	for _, it := range n.MonitoredItems {
+		e.uint32(it.ClientHandle)
+		e.dataValue(it.Value)
+	}
+	e.int32(-1)
+}
+func (n *dataChangeNotification) decode(d *decoder) {
+	n.MonitoredItems = decodeArray(d, d.arrayLength(), func() monitoredItemNotification {
+		return monitoredItemNotification{ClientHandle: d.uint32(), Value: d.dataValue()}
+	})
+	d.diagnosticInfos()
+}
+
+// statusChangeNotification 订阅状态变化（如订阅超时被服务端删除）
+type statusChangeNotification struct {
+	Status StatusCode
+}
+
+func (n *statusChangeNotification) typeID() uint32 { return idStatusChangeNotification }
+func (n *statusChangeNotification) encode(e *encoder) {
+	e.uint32(uint32(n.Status))
+	e.byte(0)
+}
+func (n *statusChangeNotification) decode(d *decoder) {
+	n.Status = StatusCode(d.uint32())
+	d.diagnosticInfo()
+}
+
+type deleteSubscriptionsRequest struct {
+	Header          requestHeader
+	SubscriptionIDs []uint32
+}
+
+func (m *deleteSubscriptionsRequest) typeID() uint32         { return idDeleteSubscriptionsRequest }
+func (m *deleteSubscriptionsRequest) header() *requestHeader { return &m.Header }
+func (m *deleteSubscriptionsRequest) encode(e *encoder) {
+	m.Header.encode(e)
+	e.uint32Array(m.SubscriptionIDs)
+}
+func (m *deleteSubscriptionsRequest) decode(d *decoder) {
+	m.Header.decode(d)
+	m.SubscriptionIDs = d.uint32Array()
+}
+
+type deleteSubscriptionsResponse struct {
+	Header  responseHeader
+	Results []StatusCode
+}
+
+func (m *deleteSubscriptionsResponse) typeID() uint32                  { return idDeleteSubscriptionsResponse }
+func (m *deleteSubscriptionsResponse) responseHeader() *responseHeader { return &m.Header }
+func (m *deleteSubscriptionsResponse) encode(e *encoder) {
+	m.Header.encode(e)
+	e.statusCodes(m.Results)
+	e.int32(-1)
+}
+func (m *deleteSubscriptionsResponse) decode(d *decoder) {
+	m.Header.decode(d)
+	m.Results = d.statusCodes()
+	d.diagnosticInfos()
+}
+
+// decodeObject 解码 ExtensionObject 的内容
+func decodeObject(x ExtensionObject, v interface{ decode(d *decoder) }) error {
+	d := newDecoder(x.Body)
+	v.decode(d)
+	return d.err
+}
+
+type findServersRequest struct {
+	Header      requestHeader
+	EndpointURL string
+	LocaleIDs   []string
+	ServerURIs  []string
+}
+
+func (m *findServersRequest) typeID() uint32         { return idFindServersRequest }
+func (m *findServersRequest) header() *requestHeader { return &m.Header }
+func (m *findServersRequest) encode(e *encoder) {
+	m.Header.encode(e)
+	e.string(m.EndpointURL)
+	e.stringArray(m.LocaleIDs)
+	e.stringArray(m.ServerURIs)
+}
+func (m *findServersRequest) decode(d *decoder) {
+	m.Header.decode(d)
+	m.EndpointURL = d.string()
+	m.LocaleIDs = d.stringArray()
+	m.ServerURIs = d.stringArray()
+}
+
+type findServersResponse struct {
+	Header  responseHeader
+	Servers []applicationDescription
+}
+
+func (m *findServersResponse) typeID() uint32                  { return idFindServersResponse }
+func (m *findServersResponse) responseHeader() *responseHeader { return &m.Header }
+func (m *findServersResponse) encode(e *encoder) {
+	m.Header.encode(e)
+	e.int32(int32(len(m.Servers)))
+	for i := range m.Servers {
+		m.Servers[i].encode(e)
+	}
+}
+func (m *findServersResponse) decode(d *decoder) {
+	m.Header.decode(d)
+	m.Servers = decodeArray(d, d.arrayLength(), func() applicationDescription {
+		var a applicationDescription
+		a.decode(d)
+		return a
+	})
+}
+
+// 浏览方向
+const (
+	browseForward = 0
+	browseInverse = 1
+	browseBoth    = 2
+)
+
+// 节点类别
+const (
+	nodeClassObject        = 1
+	nodeClassVariable      = 2
+	nodeClassObjectType    = 8
+	nodeClassVariableType  = 16
+	nodeClassReferenceType = 32
+	nodeClassDataType      = 64
+)
+
+// ReferenceDescription 中需要返回的字段（BrowseDescription.ResultMask）
+const (
+	resultReferenceType  = 0x01
+	resultIsForward      = 0x02
+	resultNodeClass      = 0x04
+	resultBrowseName     = 0x08
+	resultDisplayName    = 0x10
+	resultTypeDefinition = 0x20
+)
+
+type browseDescription struct {
+	NodeID          NodeID
+	BrowseDirection uint32
+	ReferenceTypeID NodeID
+	IncludeSubtypes bool
+	NodeClassMask   uint32
+	ResultMask      uint32
+}
+
+type referenceDescription struct {
+	ReferenceTypeID NodeID
+	IsForward       bool
+	NodeID          NodeID
+	BrowseName      QualifiedName
+	DisplayName     LocalizedText
+	NodeClass       uint32
+	TypeDefinition  NodeID
+}
+
+type browseResult struct {
+	StatusCode        StatusCode
+	ContinuationPoint []byte
+	References        []referenceDescription
+}
+
+func (r *browseResult) encode(e *encoder) {
+	e.uint32(uint32(r.StatusCode))
+	e.byteString(r.ContinuationPoint)
+	e.int32(int32(len(r.References)))
+	for _, ref := range r.References {
+		e.nodeID(ref.ReferenceTypeID)
+		e.bool(ref.IsForward)
+		e.expandedNodeID(ref.NodeID)
+		e.qualifiedName(ref.BrowseName)
+		e.localizedText(ref.DisplayName)
+		e.uint32(ref.NodeClass)
+		e.expandedNodeID(ref.TypeDefinition)
+	}
+}
+
+func (r *browseResult) decode(d *decoder) {
+	r.StatusCode = StatusCode(d.uint32())
+	r.ContinuationPoint = d.byteString()
+	r.References = decodeArray(d, d.arrayLength(), func() referenceDescription {
+		return referenceDescription{
+			ReferenceTypeID: d.nodeID(),
+			IsForward:       d.bool(),
+			NodeID:          d.expandedNodeID(),
+			BrowseName:      d.qualifiedName(),
+			DisplayName:     d.localizedText(),
+			NodeClass:       d.uint32(),
+			TypeDefinition:  d.expandedNodeID(),
+		}
+	})
+}
+
+func encodeBrowseResults(e *encoder, results []browseResult) {
+	e.int32(int32(len(results)))
+	for i := range results {
+		results[i].encode(e)
+	}
+	e.int32(-1)
+}
+
+func decodeBrowseResults(d *decoder) []browseResult {
+	results := decodeArray(d, d.arrayLength(), func() browseResult {
+		var r browseResult
+		r.decode(d)
+		return r
+	})
+	d.diagnosticInfos()
+	return results
+}
+
+type browseRequest struct {
+	Header                        requestHeader
+	ViewID                        NodeID
+	ViewTimestamp                 time.Time
+	ViewVersion                   uint32
+	RequestedMaxReferencesPerNode uint32
+	NodesToBrowse                 []browseDescription
+}
+
+func (m *browseRequest) typeID() uint32         { return idBrowseRequest }
+func (m *browseRequest) header() *requestHeader { return &m.Header }
+func (m *browseRequest) encode(e *encoder) {
+	m.Header.encode(e)
+	e.nodeID(m.ViewID)
+	e.dateTime(m.ViewTimestamp)
+	e.uint32(m.ViewVersion)
+	e.uint32(m.RequestedMaxReferencesPerNode)
+	e.int32(int32(len(m.NodesToBrowse)))
+	for _, b := range m.NodesToBrowse {
+		e.nodeID(b.NodeID)
+		e.uint32(b.BrowseDirection)
+		e.nodeID(b.ReferenceTypeID)
+		e.bool(b.IncludeSubtypes)
+		e.uint32(b.NodeClassMask)
+		e.uint32(b.ResultMask)
+	}
+}
+func (m *browseRequest) decode(d *decoder) {
+	m.Header.decode(d)
+	m.ViewID = d.nodeID()
+	m.ViewTimestamp = d.dateTime()
+	m.ViewVersion = d.uint32()
+	m.RequestedMaxReferencesPerNode = d.uint32()
+	m.NodesToBrowse = decodeArray(d, d.arrayLength(), func() browseDescription {
+		return browseDescription{
+			NodeID:          d.nodeID(),
+			BrowseDirection: d.uint32(),
+			ReferenceTypeID: d.nodeID(),
+			IncludeSubtypes: d.bool(),
+			NodeClassMask:   d.uint32(),
+			ResultMask:      d.uint32(),
+		}
+	})
+}
+
+type browseResponse struct {
+	Header  responseHeader
+	Results []browseResult
+}
+
+func (m *browseResponse) typeID() uint32                  { return idBrowseResponse }
+func (m *browseResponse) responseHeader() *responseHeader { return &m.Header }
+func (m *browseResponse) encode(e *encoder) {
+	m.Header.encode(e)
+	encodeBrowseResults(e, m.Results)
+}
+func (m *browseResponse) decode(d *decoder) {
+	m.Header.decode(d)
+	m.Results = decodeBrowseResults(d)
+}
+
+type browseNextRequest struct {
+	Header                    requestHeader
+	ReleaseContinuationPoints bool
+	ContinuationPoints        [][]byte
+}
+
+func (m *browseNextRequest) typeID() uint32         { return idBrowseNextRequest }
+func (m *browseNextRequest) header() *requestHeader { return &m.Header }
+func (m *browseNextRequest) encode(e *encoder) {
+	m.Header.encode(e)
+	e.bool(m.ReleaseContinuationPoints)
+	e.int32(int32(len(m.ContinuationPoints)))
+	for _, cp := range m.ContinuationPoints {
+		e.byteString(cp)
+	}
+}
+func (m *browseNextRequest) decode(d *decoder) {
+	m.Header.decode(d)
+	m.ReleaseContinuationPoints = d.bool()
+	m.ContinuationPoints = decodeArray(d, d.arrayLength(), d.byteString)
+}
+
+type browseNextResponse struct {
+	Header  responseHeader
+	Results []browseResult
+}
+
+func (m *browseNextResponse) typeID() uint32                  { return idBrowseNextResponse }
+func (m *browseNextResponse) responseHeader() *responseHeader { return &m.Header }
+func (m *browseNextResponse) encode(e *encoder) {
+	m.Header.encode(e)
+	encodeBrowseResults(e, m.Results)
+}
+func (m *browseNextResponse) decode(d *decoder) {
+	m.Header.decode(d)
+	m.Results = decodeBrowseResults(d)
+}
+
+type relativePathElement struct {
+	ReferenceTypeID NodeID
+	IsInverse       bool
+	IncludeSubtypes bool
+	TargetName      QualifiedName
+}
+
+type browsePath struct {
+	StartingNode NodeID
+	RelativePath []relativePathElement
+}
+
+type browsePathTarget struct {
+	TargetID           NodeID
+	RemainingPathIndex uint32
+}
+
+type browsePathResult struct {
+	StatusCode StatusCode
+	Targets    []browsePathTarget
+}
+
+type translateBrowsePathsRequest struct {
+	Header      requestHeader
+	BrowsePaths []browsePath
+}
+
+func (m *translateBrowsePathsRequest) typeID() uint32         { return idTranslateBrowsePathsRequest }
+func (m *translateBrowsePathsRequest) header() *requestHeader { return &m.Header }
+func (m *translateBrowsePathsRequest) encode(e *encoder) {
+	m.Header.encode(e)
+	e.int32(int32(len(m.BrowsePaths)))
+	for _, p := range m.BrowsePaths {
+		e.nodeID(p.StartingNode)
+		e.int32(int32(len(p.RelativePath)))
+		for _, el := range p.RelativePath {
+			e.nodeID(el.ReferenceTypeID)
+			e.bool(el.IsInverse)
+			e.bool(el.IncludeSubtypes)
+			e.qualifiedName(el.TargetName)
+		}
+	}
+}
+func (m *translateBrowsePathsRequest) decode(d *decoder) {
+	m.Header.decode(d)
+	m.BrowsePaths = decodeArray(d, d.arrayLength(), func() browsePath {
+		return browsePath{
+			StartingNode: d.nodeID(),
+			RelativePath: decodeArray(d, d.arrayLength(), func() relativePathElement {
+				return relativePathElement{ReferenceTypeID: d.nodeID(), IsInverse: d.bool(), IncludeSubtypes: d.bool(), TargetName: d.qualifiedName()}
+			}),
+		}
+	})
+}
+
+type translateBrowsePathsResponse struct {
+	Header  responseHeader
+	Results []browsePathResult
+}
+
+func (m *translateBrowsePathsResponse) typeID() uint32                  { return idTranslateBrowsePathsResp }
+func (m *translateBrowsePathsResponse) responseHeader() *responseHeader { return &m.Header }
+func (m *translateBrowsePathsResponse) encode(e *encoder) {
+	m.Header.encode(e)
+	e.int32(int32(len(m.Results)))
+	for _, r := range m.Results {
+		e.uint32(uint32(r.StatusCode))
+		e.int32(int32(len(r.Targets)))
+		for _, t := range r.Targets {
+			e.expandedNodeID(t.TargetID)
+			e.uint32(t.RemainingPathIndex)
+		}
+	}
+	e.int32(-1)
+}
+func (m *translateBrowsePathsResponse) decode(d *decoder) {
+	m.Header.decode(d)
+	m.Results = decodeArray(d, d.arrayLength(), func() browsePathResult {
+		return browsePathResult{
+			StatusCode: StatusCode(d.uint32()),
+			Targets: decodeArray(d, d.arrayLength(), func() browsePathTarget {
+				return browsePathTarget{TargetID: d.expandedNodeID(), RemainingPathIndex: d.uint32()}
+			}),
+		}
+	})
+	d.diagnosticInfos()
+}
+
+type modifySubscriptionRequest struct {
+	Header                      requestHeader
+	SubscriptionID              uint32
+	RequestedPublishingInterval float64
+	RequestedLifetimeCount      uint32
+	RequestedMaxKeepAliveCount  uint32
+	MaxNotificationsPerPublish  uint32
+	Priority                    byte
+}
+
+func (m *modifySubscriptionRequest) typeID() uint32         { return idModifySubscriptionRequest }
+func (m *modifySubscriptionRequest) header() *requestHeader { return &m.Header }
+func (m *modifySubscriptionRequest) encode(e *encoder) {
+	m.Header.encode(e)
+	e.uint32(m.SubscriptionID)
+	e.float64(m.RequestedPublishingInterval)
+	e.uint32(m.RequestedLifetimeCount)
+	e.uint32(m.RequestedMaxKeepAliveCount)
+	e.uint32(m.MaxNotificationsPerPublish)
+	e.byte(m.Priority)
+}
+func (m *modifySubscriptionRequest) decode(d *decoder) {
+	m.Header.decode(d)
+	m.SubscriptionID = d.uint32()
+	m.RequestedPublishingInterval = d.float64()
+	m.RequestedLifetimeCount = d.uint32()
+	m.RequestedMaxKeepAliveCount = d.uint32()
+	m.MaxNotificationsPerPublish = d.uint32()
+	m.Priority = d.byte()
+}
+
+type modifySubscriptionResponse struct {
+	Header                    responseHeader
+	RevisedPublishingInterval float64
+	RevisedLifetimeCount      uint32
+	RevisedMaxKeepAliveCount  uint32
+}
+
+func (m *modifySubscriptionResponse) typeID() uint32                  { return idModifySubscriptionResponse }
+func (m *modifySubscriptionResponse) responseHeader() *responseHeader { return &m.Header }
+func (m *modifySubscriptionResponse) encode(e *encoder) {
+	m.Header.encode(e)
+	e.float64(m.RevisedPublishingInterval)
+	e.uint32(m.RevisedLifetimeCount)
+	e.uint32(m.RevisedMaxKeepAliveCount)
+}
+func (m *modifySubscriptionResponse) decode(d *decoder) {
+	m.Header.decode(d)
+	m.RevisedPublishingInterval = d.float64()
+	m.RevisedLifetimeCount = d.uint32()
+	m.RevisedMaxKeepAliveCount = d.uint32()
+}
+
+type setPublishingModeRequest struct {
+	Header            requestHeader
+	PublishingEnabled bool
+	SubscriptionIDs   []uint32
+}
+
+func (m *setPublishingModeRequest) typeID() uint32         { return idSetPublishingModeRequest }
+func (m *setPublishingModeRequest) header() *requestHeader { return &m.Header }
+func (m *setPublishingModeRequest) encode(e *encoder) {
+	m.Header.encode(e)
+	e.bool(m.PublishingEnabled)
+	e.uint32Array(m.SubscriptionIDs)
+}
+func (m *setPublishingModeRequest) decode(d *decoder) {
+	m.Header.decode(d)
+	m.PublishingEnabled = d.bool()
+	m.SubscriptionIDs = d.uint32Array()
+}
+
+type setPublishingModeResponse struct {
+	Header  responseHeader
+	Results []StatusCode
+}
+
+func (m *setPublishingModeResponse) typeID() uint32                  { return idSetPublishingModeResponse }
+func (m *setPublishingModeResponse) responseHeader() *responseHeader { return &m.Header }
+func (m *setPublishingModeResponse) encode(e *encoder) {
+	m.Header.encode(e)
+	e.statusCodes(m.Results)
+	e.int32(-1)
+}
+func (m *setPublishingModeResponse) decode(d *decoder) {
+	m.Header.decode(d)
+	m.Results = d.statusCodes()
+	d.diagnosticInfos()
+}
+
+type monitoredItemModifyRequest struct {
+	MonitoredItemID  uint32
+	ClientHandle     uint32
+	SamplingInterval float64
+	Filter           ExtensionObject
+	QueueSize        uint32
+	DiscardOldest    bool
+}
+
+type monitoredItemModifyResult struct {
+	StatusCode              StatusCode
+	RevisedSamplingInterval float64
+	RevisedQueueSize        uint32
+}
+
+type modifyMonitoredItemsRequest struct {
+	Header             requestHeader
+	SubscriptionID     uint32
+	TimestampsToReturn uint32
+	ItemsToModify      []monitoredItemModifyRequest
+}
+
+func (m *modifyMonitoredItemsRequest) typeID() uint32         { return idModifyMonitoredItemsRequest }
+func (m *modifyMonitoredItemsRequest) header() *requestHeader { return &m.Header }
+func (m *modifyMonitoredItemsRequest) encode(e *encoder) {
+	m.Header.encode(e)
+	e.uint32(m.SubscriptionID)
+	e.uint32(m.TimestampsToReturn)
+	e.int32(int32(len(m.ItemsToModify)))
+	for _, it := range m.ItemsToModify {
+		e.uint32(it.MonitoredItemID)
+		e.uint32(it.ClientHandle)
+		e.float64(it.SamplingInterval)
+		e.extensionObject(it.Filter)
+		e.uint32(it.QueueSize)
+		e.bool(it.DiscardOldest)
+	}
+}
+func (m *modifyMonitoredItemsRequest) decode(d *decoder) {
+	m.Header.decode(d)
+	m.SubscriptionID = d.uint32()
+	m.TimestampsToReturn = d.uint32()
+	m.ItemsToModify = decodeArray(d, d.arrayLength(), func() monitoredItemModifyRequest {
+		return monitoredItemModifyRequest{
+			MonitoredItemID:  d.uint32(),
+			ClientHandle:     d.uint32(),
+			SamplingInterval: d.float64(),
+			Filter:           d.extensionObject(),
+			QueueSize:        d.uint32(),
+			DiscardOldest:    d.bool(),
+		}
+	})
+}
+
+type modifyMonitoredItemsResponse struct {
+	Header  responseHeader
+	Results []monitoredItemModifyResult
+}
+
+func (m *modifyMonitoredItemsResponse) typeID() uint32                  { return idModifyMonitoredItemsResp }
+func (m *modifyMonitoredItemsResponse) responseHeader() *responseHeader { return &m.Header }
+func (m *modifyMonitoredItemsResponse) encode(e *encoder) {
+	m.Header.encode(e)
+	e.int32(int32(len(m.Results)))
+	for _, r := range m.Results {
+		e.uint32(uint32(r.StatusCode))
+		e.float64(r.RevisedSamplingInterval)
+		e.uint32(r.RevisedQueueSize)
+		e.extensionObject(ExtensionObject{})
+	}
+	e.int32(-1)
+}
+func (m *modifyMonitoredItemsResponse) decode(d *decoder) {
+	m.Header.decode(d)
+	m.Results = decodeArray(d, d.arrayLength(), func() monitoredItemModifyResult {
+		r := monitoredItemModifyResult{StatusCode: StatusCode(d.uint32()), RevisedSamplingInterval: d.float64(), RevisedQueueSize: d.uint32()}
+		d.extensionObject()
+		return r
+	})
+	d.diagnosticInfos()
+}
+
+type setMonitoringModeRequest struct {
+	Header           requestHeader
+	SubscriptionID   uint32
+	MonitoringMode   uint32
+	MonitoredItemIDs []uint32
+}
+
+func (m *setMonitoringModeRequest) typeID() uint32         { return idSetMonitoringModeRequest }
+func (m *setMonitoringModeRequest) header() *requestHeader { return &m.Header }
+func (m *setMonitoringModeRequest) encode(e *encoder) {
+	m.Header.encode(e)
+	e.uint32(m.SubscriptionID)
+	e.uint32(m.MonitoringMode)
+	e.uint32Array(m.MonitoredItemIDs)
+}
+func (m *setMonitoringModeRequest) decode(d *decoder) {
+	m.Header.decode(d)
+	m.SubscriptionID = d.uint32()
+	m.MonitoringMode = d.uint32()
+	m.MonitoredItemIDs = d.uint32Array()
+}
+
+type setMonitoringModeResponse struct {
+	Header  responseHeader
+	Results []StatusCode
+}
+
+func (m *setMonitoringModeResponse) typeID() uint32                  { return idSetMonitoringModeResponse }
+func (m *setMonitoringModeResponse) responseHeader() *responseHeader { return &m.Header }
+func (m *setMonitoringModeResponse) encode(e *encoder) {
+	m.Header.encode(e)
+	e.statusCodes(m.Results)
+	e.int32(-1)
+}
+func (m *setMonitoringModeResponse) decode(d *decoder) {
+	m.Header.decode(d)
+	m.Results = d.statusCodes()
+	d.diagnosticInfos()
+}
+
+type deleteMonitoredItemsRequest struct {
+	Header           requestHeader
+	SubscriptionID   uint32
+	MonitoredItemIDs []uint32
+}
+
+func (m *deleteMonitoredItemsRequest) typeID() uint32         { return idDeleteMonitoredItemsRequest }
+func (m *deleteMonitoredItemsRequest) header() *requestHeader { return &m.Header }
+func (m *deleteMonitoredItemsRequest) encode(e *encoder) {
+	m.Header.encode(e)
+	e.uint32(m.SubscriptionID)
+	e.uint32Array(m.MonitoredItemIDs)
+}
+func (m *deleteMonitoredItemsRequest) decode(d *decoder) {
+	m.Header.decode(d)
+	m.SubscriptionID = d.uint32()
+	m.MonitoredItemIDs = d.uint32Array()
+}
+
+type deleteMonitoredItemsResponse struct {
+	Header  responseHeader
+	Results []StatusCode
+}
+
+func (m *deleteMonitoredItemsResponse) typeID() uint32                  { return idDeleteMonitoredItemsResp }
+func (m *deleteMonitoredItemsResponse) responseHeader() *responseHeader { return &m.Header }
+func (m *deleteMonitoredItemsResponse) encode(e *encoder) {
+	m.Header.encode(e)
+	e.statusCodes(m.Results)
+	e.int32(-1)
+}
+func (m *deleteMonitoredItemsResponse) decode(d *decoder) {
+	m.Header.decode(d)
+	m.Results = d.statusCodes()
+	d.diagnosticInfos()
+}
+
+type republishRequest struct {
+	Header                   requestHeader
+	SubscriptionID           uint32
+	RetransmitSequenceNumber uint32
+}
+
+func (m *republishRequest) typeID() uint32         { return idRepublishRequest }
+func (m *republishRequest) header() *requestHeader { return &m.Header }
+func (m *republishRequest) encode(e *encoder) {
+	m.Header.encode(e)
+	e.uint32(m.SubscriptionID)
+	e.uint32(m.RetransmitSequenceNumber)
+}
+func (m *republishRequest) decode(d *decoder) {
+	m.Header.decode(d)
+	m.SubscriptionID = d.uint32()
+	m.RetransmitSequenceNumber = d.uint32()
+}
+
+type republishResponse struct {
+	Header              responseHeader
+	NotificationMessage notificationMessage
+}
+
+func (m *republishResponse) typeID() uint32                  { return idRepublishResponse }
+func (m *republishResponse) responseHeader() *responseHeader { return &m.Header }
+func (m *republishResponse) encode(e *encoder) {
+	m.Header.encode(e)
+	m.NotificationMessage.encode(e)
+}
+func (m *republishResponse) decode(d *decoder) {
+	m.Header.decode(d)
+	m.NotificationMessage.decode(d)
+}
@@ -0,0 +1,177 @@
+package opcua
+
+import "fmt"
+
+// StatusCode OPC UA 状态码，高两位为 Good(00)/Uncertain(01)/Bad(10)
+type StatusCode uint32
+
+const (
+	StatusGood                              StatusCode = 0
+	StatusUncertain                         StatusCode = 0x40000000
+	StatusBadUnexpectedError                StatusCode = 0x80010000
+	StatusBadInternalError                  StatusCode = 0x80020000
+	StatusBadCommunicationError             StatusCode = 0x80050000
+	StatusBadEncodingError                  StatusCode = 0x80060000
+	StatusBadDecodingError                  StatusCode = 0x80070000
+	StatusBadTimeout                        StatusCode = 0x800A0000
+	StatusBadServiceUnsupported             StatusCode = 0x800B0000
+	StatusBadShutdown                       StatusCode = 0x800C0000
+	StatusBadNothingToDo                    StatusCode = 0x800F0000
+	StatusBadTooManyOperations              StatusCode = 0x80100000
+	StatusBadCertificateInvalid             StatusCode = 0x80120000
+	StatusBadSecurityChecksFailed           StatusCode = 0x80130000
+	StatusBadCertificateUntrusted           StatusCode = 0x801A0000
+	StatusBadUserAccessDenied               StatusCode = 0x801F0000
+	StatusBadIdentityTokenInvalid           StatusCode = 0x80200000
+	StatusBadIdentityTokenRejected          StatusCode = 0x80210000
+	StatusBadSecureChannelIDInvalid         StatusCode = 0x80220000
+	StatusBadNonceInvalid                   StatusCode = 0x80240000
+	StatusBadSessionIDInvalid               StatusCode = 0x80250000
+	StatusBadSessionClosed                  StatusCode = 0x80260000
+	StatusBadSessionNotActivated            StatusCode = 0x80270000
+	StatusBadSubscriptionIDInvalid          StatusCode = 0x80280000
+	StatusBadRequestHeaderInvalid           StatusCode = 0x802A0000
+	StatusBadTimestampsToReturnInvalid      StatusCode = 0x802B0000
+	StatusBadNoCommunication                StatusCode = 0x80310000
+	StatusBadWaitingForInitialData          StatusCode = 0x80320000
+	StatusBadNodeIDInvalid                  StatusCode = 0x80330000
+	StatusBadNodeIDUnknown                  StatusCode = 0x80340000
+	StatusBadAttributeIDInvalid             StatusCode = 0x80350000
+	StatusBadIndexRangeInvalid              StatusCode = 0x80360000
+	StatusBadNotReadable                    StatusCode = 0x803A0000
+	StatusBadNotWritable                    StatusCode = 0x803B0000
+	StatusBadOutOfRange                     StatusCode = 0x803C0000
+	StatusBadNotSupported                   StatusCode = 0x803D0000
+	StatusBadMonitoringModeInvalid          StatusCode = 0x80410000
+	StatusBadMonitoredItemIDInvalid         StatusCode = 0x80420000
+	StatusBadMonitoredItemFilterInvalid     StatusCode = 0x80430000
+	StatusBadMonitoredItemFilterUnsupported StatusCode = 0x80440000
+	StatusBadFilterNotAllowed               StatusCode = 0x80450000
+	StatusBadContinuationPointInvalid       StatusCode = 0x804A0000
+	StatusBadNoContinuationPoints           StatusCode = 0x804B0000
+	StatusBadReferenceTypeIDInvalid         StatusCode = 0x804C0000
+	StatusBadBrowseDirectionInvalid         StatusCode = 0x804D0000
+	StatusBadSecurityModeRejected           StatusCode = 0x80540000
+	StatusBadSecurityPolicyRejected         StatusCode = 0x80550000
+	StatusBadTooManySessions                StatusCode = 0x80560000
+	StatusBadUserSignatureInvalid           StatusCode = 0x80570000
+	StatusBadApplicationSignatureInvalid    StatusCode = 0x80580000
+	StatusBadBrowseNameInvalid              StatusCode = 0x80600000
+	StatusBadViewIDUnknown                  StatusCode = 0x806B0000
+	StatusBadNoMatch                        StatusCode = 0x806F0000
+	StatusBadWriteNotSupported              StatusCode = 0x80730000
+	StatusBadTypeMismatch                   StatusCode = 0x80740000
+	StatusBadTooManySubscriptions           StatusCode = 0x80770000
+	StatusBadTooManyPublishRequests         StatusCode = 0x80780000
+	StatusBadNoSubscription                 StatusCode = 0x80790000
+	StatusBadSequenceNumberUnknown          StatusCode = 0x807A0000
+	StatusBadMessageNotAvailable            StatusCode = 0x807B0000
+	StatusBadTCPMessageTypeInvalid          StatusCode = 0x807E0000
+	StatusBadTCPSecureChannelUnknown        StatusCode = 0x807F0000
+	StatusBadTCPMessageTooLarge             StatusCode = 0x80800000
+	StatusBadTCPEndpointURLInvalid          StatusCode = 0x80830000
+	StatusBadSecureChannelClosed            StatusCode = 0x80860000
+	StatusBadSecureChannelTokenUnknown      StatusCode = 0x80870000
+	StatusBadInvalidArgument                StatusCode = 0x80AB0000
+	StatusBadTooManyMonitoredItems          StatusCode = 0x80DB0000
+)
+
+var statusNames = map[StatusCode]string{
+	StatusGood:                              "Good",
+	StatusUncertain:                         "Uncertain",
+	StatusBadUnexpectedError:                "BadUnexpectedError",
+	StatusBadInternalError:                  "BadInternalError",
+	StatusBadCommunicationError:             "BadCommunicationError",
+	StatusBadEncodingError:                  "BadEncodingError",
+	StatusBadDecodingError:                  "BadDecodingError",
+	StatusBadTimeout:                        "BadTimeout",
+	StatusBadServiceUnsupported:             "BadServiceUnsupported",
+	StatusBadShutdown:                       "BadShutdown",
+	StatusBadNothingToDo:                    "BadNothingToDo",
+	StatusBadTooManyOperations:              "BadTooManyOperations",
+	StatusBadCertificateInvalid:             "BadCertificateInvalid",
+	StatusBadSecurityChecksFailed:           "BadSecurityChecksFailed",
+	StatusBadCertificateUntrusted:           "BadCertificateUntrusted",
+	StatusBadUserAccessDenied:               "BadUserAccessDenied",
+	StatusBadIdentityTokenInvalid:           "BadIdentityTokenInvalid",
+	StatusBadIdentityTokenRejected:          "BadIdentityTokenRejected",
+	StatusBadSecureChannelIDInvalid:         "BadSecureChannelIdInvalid",
+	StatusBadNonceInvalid:                   "BadNonceInvalid",
+	StatusBadSessionIDInvalid:               "BadSessionIdInvalid",
+	StatusBadSessionClosed:                  "BadSessionClosed",
+	StatusBadSessionNotActivated:            "BadSessionNotActivated",
+	StatusBadSubscriptionIDInvalid:          "BadSubscriptionIdInvalid",
+	StatusBadRequestHeaderInvalid:           "BadRequestHeaderInvalid",
+	StatusBadTimestampsToReturnInvalid:      "BadTimestampsToReturnInvalid",
+	StatusBadNoCommunication:                "BadNoCommunication",
+	StatusBadWaitingForInitialData:          "BadWaitingForInitialData",
+	StatusBadNodeIDInvalid:                  "BadNodeIdInvalid",
+	StatusBadNodeIDUnknown:                  "BadNodeIdUnknown",
+	StatusBadAttributeIDInvalid:             "BadAttributeIdInvalid",
+	StatusBadIndexRangeInvalid:              "BadIndexRangeInvalid",
+	StatusBadNotReadable:                    "BadNotReadable",
+	StatusBadNotWritable:                    "BadNotWritable",
+	StatusBadOutOfRange:                     "BadOutOfRange",
+	StatusBadNotSupported:                   "BadNotSupported",
+	StatusBadMonitoringModeInvalid:          "BadMonitoringModeInvalid",
+	StatusBadMonitoredItemIDInvalid:         "BadMonitoredItemIdInvalid",
+	StatusBadMonitoredItemFilterInvalid:     "BadMonitoredItemFilterInvalid",
+	StatusBadMonitoredItemFilterUnsupported: "BadMonitoredItemFilterUnsupported",
+	StatusBadFilterNotAllowed:               "BadFilterNotAllowed",
+	StatusBadContinuationPointInvalid:       "BadContinuationPointInvalid",
+	StatusBadNoContinuationPoints:           "BadNoContinuationPoints",
+	StatusBadReferenceTypeIDInvalid:         "BadReferenceTypeIdInvalid",
+	StatusBadBrowseDirectionInvalid:         "BadBrowseDirectionInvalid",
+	StatusBadSecurityModeRejected:           "BadSecurityModeRejected",
+	StatusBadSecurityPolicyRejected:         "BadSecurityPolicyRejected",
+	StatusBadTooManySessions:                "BadTooManySessions",
+	StatusBadUserSignatureInvalid:           "BadUserSignatureInvalid",
+	StatusBadApplicationSignatureInvalid:    "BadApplicationSignatureInvalid",
+	StatusBadBrowseNameInvalid:              "BadBrowseNameInvalid",
+	StatusBadViewIDUnknown:                  "BadViewIdUnknown",
+	StatusBadNoMatch:                        "BadNoMatch",
+	StatusBadWriteNotSupported:              "BadWriteNotSupported",
+	StatusBadTypeMismatch:                   "BadTypeMismatch",
+	StatusBadTooManySubscriptions:           "BadTooManySubscriptions",
+	StatusBadTooManyPublishRequests:         "BadTooManyPublishRequests",
+	StatusBadNoSubscription:                 "BadNoSubscription",
+	StatusBadSequenceNumberUnknown:          "BadSequenceNumberUnknown",
+	StatusBadMessageNotAvailable:            "BadMessageNotAvailable",
+	StatusBadTCPMessageTypeInvalid:          "BadTcpMessageTypeInvalid",
+	StatusBadTCPSecureChannelUnknown:        "BadTcpSecureChannelUnknown",
+	StatusBadTCPMessageTooLarge:             "BadTcpMessageTooLarge",
+	StatusBadTCPEndpointURLInvalid:          "BadTcpEndpointUrlInvalid",
+	StatusBadSecureChannelClosed:            "BadSecureChannelClosed",
+	StatusBadSecureChannelTokenUnknown:      "BadSecureChannelTokenUnknown",
+	StatusBadInvalidArgument:                "BadInvalidArgument",
+	StatusBadTooManyMonitoredItems:          "BadTooManyMonitoredItems",
+}
+
+// IsGood 严重性为 Good
+func (s StatusCode) IsGood() bool { return s&0xC0000000 == 0 }
+
+// IsBad 严重性为 Bad
+func (s StatusCode) IsBad() bool { return s&0x80000000 != 0 }
+
+// IsUncertain 严重性为 Uncertain
+func (s StatusCode) IsUncertain() bool { return s&0xC0000000 == 0x40000000 }
+
+func (s StatusCode) String() string {
+	if name, ok := statusNames[s&0xFFFF0000]; ok {
+		return fmt.Sprintf("%s (0x%08X)", name, uint32(s))
+	}
+	return fmt.Sprintf("0x%08X", uint32(s))
+}
+
+// statusName 状态码名称，未知状态码为十六进制
+func statusName(s StatusCode) string {
+	if name, ok := statusNames[s&0xFFFF0000]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%08X", uint32(s))
+}
+
+// Error 使 StatusCode 可作为 error 返回
+func (s StatusCode) Error() string {
+	return "opcua: " + s.String()
+}
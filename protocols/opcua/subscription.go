@@ -0,0 +1,240 @@
+package opcua
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"sensor-edge/protocols"
+)
+
+// 订阅参数：保活次数和生命周期次数（须不小于保活次数的 3 倍）
+const (
+	maxKeepAliveCount = 10
+	lifetimeCount     = 60
+)
+
+// monitoredPoint 一个监视项对应的点位及其最近一次通知的值
+type monitoredPoint struct {
+	deviceID string
+	name     string
+	address  string
+	value    interface{}
+	quality  string
+	updated  time.Time
+	received bool
+}
+
+// subscriptionState 当前会话上的订阅
+type subscriptionState struct {
+	conn      *client
+	id        uint32
+	interval  time.Duration
+	items     map[uint32]*monitoredPoint // clientHandle -> 点位
+	byAddress map[string]*monitoredPoint
+}
+
+func (c *client) createSubscription(interval time.Duration) (uint32, time.Duration, error) {
+	m, err := c.send(&createSubscriptionRequest{
+		RequestedPublishingInterval: float64(interval.Milliseconds()),
+		RequestedLifetimeCount:      lifetimeCount,
+		RequestedMaxKeepAliveCount:  maxKeepAliveCount,
+		PublishingEnabled:           true,
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	resp := m.(*createSubscriptionResponse)
+	return resp.SubscriptionID, time.Duration(resp.RevisedPublishingInterval * float64(time.Millisecond)), nil
+}
+
+func (c *client) createMonitoredItems(subID uint32, items []monitoredItemCreateRequest) ([]monitoredItemCreateResult, error) {
+	m, err := c.send(&createMonitoredItemsRequest{SubscriptionID: subID, TimestampsToReturn: timestampsBoth, ItemsToCreate: items})
+	if err != nil {
+		return nil, err
+	}
+	results := m.(*createMonitoredItemsResponse).Results
+	if len(results) != len(items) {
+		return nil, errors.New("opcua: create monitored items returned wrong number of results")
+	}
+	return results, nil
+}
+
+func (c *client) deleteSubscriptions(ids ...uint32) error {
+	_, err := c.send(&deleteSubscriptionsRequest{SubscriptionIDs: ids})
+	return err
+}
+
+// syncSubscriptions 按当前点位配置重建订阅：为已注册回调的设备中 subscribe: true 的点位创建监视项
+func (c *OPCUAClient) syncSubscriptions(conn *client) {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	if !c.subDirty && (c.sub == nil || c.sub.conn == conn) {
+		return
+	}
+	c.subDirty = false
+	if c.sub != nil && c.sub.conn == conn {
+		conn.deleteSubscriptions(c.sub.id)
+	}
+	c.sub = nil
+
+	var points []*monitoredPoint
+	var items []monitoredItemCreateRequest
+	c.pmu.RLock()
+	for deviceID, table := range c.points {
+		if c.handlers[deviceID] == nil {
+			continue
+		}
+		for key, p := range table {
+			if subscribe, _ := p.Options["subscribe"].(bool); key != p.Address || !subscribe {
+				continue
+			}
+			node, err := ParseNodeID(p.Address)
+			if err != nil {
+				continue
+			}
+			sampling := c.conf.SamplingInterval
+			if v, ok := optionInt(p.Options, "sampling_interval"); ok {
+				sampling = v
+			}
+			points = append(points, &monitoredPoint{deviceID: deviceID, name: p.PointID, address: p.Address})
+			items = append(items, monitoredItemCreateRequest{
+				ItemToMonitor:    readValueID{NodeID: node, AttributeID: attrValue},
+				MonitoringMode:   monitoringReporting,
+				ClientHandle:     uint32(len(items) + 1),
+				SamplingInterval: float64(sampling),
+				QueueSize:        1,
+				DiscardOldest:    true,
+			})
+		}
+	}
+	c.pmu.RUnlock()
+	if len(items) == 0 {
+		return
+	}
+
+	id, interval, err := conn.createSubscription(millis(c.conf.PublishingInterval, 1000))
+	if err != nil {
+		log.Printf("[OPCUA] create subscription: %v", err)
+		c.subDirty = true
+		return
+	}
+	results, err := conn.createMonitoredItems(id, items)
+	if err != nil {
+		log.Printf("[OPCUA] create monitored items: %v", err)
+		conn.deleteSubscriptions(id)
+		c.subDirty = true
+		return
+	}
+	sub := &subscriptionState{conn: conn, id: id, interval: interval, items: make(map[uint32]*monitoredPoint), byAddress: make(map[string]*monitoredPoint)}
+	for i, r := range results {
+		// 被拒绝的监视项继续轮询
+		if r.StatusCode.IsBad() {
+			log.Printf("[OPCUA] monitor %s: %v", points[i].address, r.StatusCode)
+			continue
+		}
+		sub.items[items[i].ClientHandle] = points[i]
+		sub.byAddress[points[i].address] = points[i]
+	}
+	c.sub = sub
+	if !c.publishing[conn] {
+		c.publishing[conn] = true
+		go c.publishLoop(conn)
+	}
+}
+
+// publishLoop 保持一个未完成的 Publish 请求，收到的通知交给订阅处理，连接断开或会话没有订阅时退出
+func (c *OPCUAClient) publishLoop(conn *client) {
+	defer func() {
+		c.smu.Lock()
+		delete(c.publishing, conn)
+		c.smu.Unlock()
+	}()
+	var acks []subscriptionAcknowledgement
+	for conn.alive() {
+		c.smu.Lock()
+		timeout := c.opts.timeout
+		if c.sub != nil && c.sub.conn == conn {
+			timeout += c.sub.interval * (maxKeepAliveCount + 1)
+		}
+		c.smu.Unlock()
+		m, err := conn.call(msgMessage, &publishRequest{Acknowledgements: acks}, timeout)
+		acks = nil
+		if err != nil {
+			var s StatusCode
+			if errors.As(err, &s) && s == StatusBadNoSubscription {
+				return
+			}
+			if conn.alive() {
+				log.Printf("[OPCUA] publish: %v", err)
+				time.Sleep(time.Second)
+			}
+			continue
+		}
+		resp := m.(*publishResponse)
+		if len(resp.NotificationMessage.NotificationData) > 0 {
+			acks = append(acks, subscriptionAcknowledgement{SubscriptionID: resp.SubscriptionID, SequenceNumber: resp.NotificationMessage.SequenceNumber})
+		}
+		c.handleNotifications(conn, resp)
+	}
+}
+
+func (c *OPCUAClient) handleNotifications(conn *client, resp *publishResponse) {
+	pushed := make(map[string][]protocols.PointValue)
+	c.smu.Lock()
+	sub := c.sub
+	if sub == nil || sub.conn != conn || sub.id != resp.SubscriptionID {
+		c.smu.Unlock()
+		return
+	}
+	for _, x := range resp.NotificationMessage.NotificationData {
+		switch x.TypeID.Numeric {
+		case idDataChangeNotification:
+			var n dataChangeNotification
+			if err := decodeObject(x, &n); err != nil {
+				log.Printf("[OPCUA] data change notification: %v", err)
+				continue
+			}
+			for _, it := range n.MonitoredItems {
+				p, ok := sub.items[it.ClientHandle]
+				if !ok {
+					continue
+				}
+				pv := dataValuePoint(p.name, it.Value)
+				p.value, p.quality, p.updated, p.received = pv.Value, pv.Quality, time.Unix(pv.Timestamp, 0), true
+				pushed[p.deviceID] = append(pushed[p.deviceID], pv)
+			}
+		case idStatusChangeNotification:
+			var n statusChangeNotification
+			decodeObject(x, &n)
+			log.Printf("[OPCUA] subscription %d status: %v", sub.id, n.Status)
+			if n.Status.IsBad() {
+				c.sub, c.subDirty = nil, true
+			}
+		}
+	}
+	handlers := make(map[string]func([]protocols.PointValue), len(pushed))
+	for deviceID := range pushed {
+		handlers[deviceID] = c.handlers[deviceID]
+	}
+	c.smu.Unlock()
+	for deviceID, values := range pushed {
+		if h := handlers[deviceID]; h != nil {
+			h(values)
+		}
+	}
+}
+
+// cachedValue 订阅有效且已收到通知的点位返回最近的通知值
+func (c *OPCUAClient) cachedValue(conn *client, address string) (protocols.PointValue, bool) {
+	c.smu.Lock()
+	defer c.smu.Unlock()
+	if c.sub == nil || c.sub.conn != conn {
+		return protocols.PointValue{}, false
+	}
+	p, ok := c.sub.byAddress[address]
+	if !ok || !p.received {
+		return protocols.PointValue{}, false
+	}
+	return protocols.PointValue{PointID: address, Value: p.value, Quality: p.quality, Timestamp: p.updated.Unix()}, true
+}
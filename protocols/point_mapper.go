@@ -12,6 +12,8 @@ func MapPointsToConfig(points []types.PointMapping) []PointConfig {
 			Type:      p.Type,
 			Unit:      p.Unit,
 			Transform: p.Transform,
+			Format:    p.Format,
+			Options:   p.Options,
 		})
 	}
 	return configs
@@ -1,13 +1,20 @@
 package protocols
 
+import "strings"
+
 // PointValue 定义了数据点的结构体
 type PointValue struct {
 	PointID   string
 	Value     interface{}
-	Quality   string
+	Quality   string // good | uncertain[:原因] | bad[:原因]
 	Timestamp int64
 }
 
+// IsBadQuality 质量为 bad 或 bad:<原因> 时值不可用；uncertain 的值可用，只是可信度存疑
+func IsBadQuality(quality string) bool {
+	return quality == "bad" || strings.HasPrefix(quality, "bad:")
+}
+
 // Protocol 是所有协议模块需实现的通用接口
 type Protocol interface {
 	Init(config map[string]interface{}) error
@@ -18,3 +25,50 @@ type Protocol interface {
 	Close() error
 	Reconnect() error // 新增重连接口，便于处理连接异常
 }
+
+// PointConfigurable 是可选接口：需要完整点位配置（格式、类型等）的驱动实现该接口，
+// 采集主流程在创建客户端后注入该设备的点位配置
+type PointConfigurable interface {
+	SetPointConfigs(deviceID string, points []PointConfig)
+}
+
+// DeviceConfigurable 是可选接口：多个设备共享同一客户端（如同一网关下的多个从站）时，
+// 采集主流程通过该接口注入每台设备自己的配置
+type DeviceConfigurable interface {
+	ConfigureDevice(deviceID string, config map[string]interface{}) error
+}
+
+// PointSubscriber 是可选接口：支持设备主动上报变化（如 BACnet COV）的驱动实现该接口，
+// 采集主流程为每台设备注册回调，驱动在两次轮询之间收到变化时调用，values 只包含变化的点位
+type PointSubscriber interface {
+	SetValueHandler(deviceID string, handler func(values []PointValue))
+}
+
+// PriorityCommander 是可选接口：按优先级命令点位的驱动（如 BACnet 可命令对象）实现该接口，
+// 调试接口据此查看各优先级由谁占用并释放某一优先级的命令
+type PriorityCommander interface {
+	// ReadPriorityArray 返回 16 个优先级上的命令值（下标 0 为优先级 1），未被占用的为 nil
+	ReadPriorityArray(point string) ([]interface{}, error)
+	// Relinquish 释放点位在 priority 上的命令，priority 为 0 时使用点位配置的优先级
+	Relinquish(point string, priority int) error
+}
+
+// ReadRequest 一次读请求，Start/Quantity 为协议帧中的起始地址和数量
+type ReadRequest struct {
+	Function string   `json:"function"`
+	Start    int      `json:"start"`
+	Quantity int      `json:"quantity"`
+	Points   []string `json:"points"`
+}
+
+// ReadPlan 一组点位的读请求计划，Skipped 为不会读取的点位（如落在禁止读取的地址内）
+type ReadPlan struct {
+	Requests []ReadRequest `json:"requests"`
+	Skipped  []string      `json:"skipped,omitempty"`
+}
+
+// ReadPlanner 是可选接口：合并读取的驱动返回 ReadBatch 将要发出的请求，不访问设备，
+// 用于调试接口查看设备每轮采集的往返次数
+type ReadPlanner interface {
+	ReadPlan(deviceID string, function string, points []string) (ReadPlan, error)
+}
@@ -0,0 +1,299 @@
+package s7
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Area S7 存储区
+type Area byte
+
+const (
+	AreaDB     Area = 0x84 // 数据块
+	AreaMerker Area = 0x83 // M 位存储区
+	AreaInput  Area = 0x81 // I/E 过程映像输入
+	AreaOutput Area = 0x82 // Q/A 过程映像输出
+)
+
+func (a Area) String() string {
+	switch a {
+	case AreaDB:
+		return "DB"
+	case AreaMerker:
+		return "M"
+	case AreaInput:
+		return "I"
+	case AreaOutput:
+		return "Q"
+	default:
+		return fmt.Sprintf("area(0x%02X)", byte(a))
+	}
+}
+
+// Size 地址中的数据宽度标识
+type Size byte
+
+const (
+	SizeBit    Size = 'X'
+	SizeByte   Size = 'B'
+	SizeWord   Size = 'W'
+	SizeDWord  Size = 'D'
+	SizeLWord  Size = 'L' // 8 字节，用于 LREAL
+	SizeString Size = 'S' // S7 STRING：最大长度、实际长度各 1 字节，后跟字符
+)
+
+// bytes 数据宽度对应的字节数，位地址按 1 字节读取
+func (s Size) bytes() int {
+	switch s {
+	case SizeWord:
+		return 2
+	case SizeDWord:
+		return 4
+	case SizeLWord:
+		return 8
+	default:
+		return 1
+	}
+}
+
+// Address 解析后的 S7 地址
+type Address struct {
+	Area   Area
+	DB     int // 数据块号，仅 AreaDB 有效
+	Start  int // 起始字节
+	Bit    int // 位号 0~7，非位地址为 -1
+	Size   Size
+	StrLen int // STRING 的最大字符数
+}
+
+// Length 读写该地址占用的字节数
+func (a Address) Length() int {
+	if a.Size == SizeString {
+		return a.StrLen + 2
+	}
+	return a.Size.bytes()
+}
+
+func (a Address) String() string {
+	var loc string
+	switch a.Size {
+	case SizeBit:
+		loc = fmt.Sprintf("%d.%d", a.Start, a.Bit)
+	case SizeString:
+		loc = fmt.Sprintf("S%d.%d", a.Start, a.StrLen)
+	default:
+		loc = fmt.Sprintf("%c%d", a.Size, a.Start)
+	}
+	if a.Area == AreaDB {
+		if a.Size == SizeBit {
+			loc = "X" + loc
+		}
+		return fmt.Sprintf("DB%d.DB%s", a.DB, loc)
+	}
+	return a.Area.String() + loc
+}
+
+// areaLetters 非 DB 存储区的前缀，兼容德文助记符（E 输入、A 输出）
+var areaLetters = map[byte]Area{
+	'M': AreaMerker,
+	'I': AreaInput,
+	'E': AreaInput,
+	'Q': AreaOutput,
+	'A': AreaOutput,
+}
+
+const (
+	maxByteOffset = 65535
+	maxStringLen  = 254
+)
+
+// ParseAddress 解析 S7 地址（不区分大小写），支持：
+//   - 数据块：DB10.DBX0.3、DB1.DBB2、DB1.DBW4、DB10.DBD4、DB1.DBL8（8 字节）、DB5.DBS10.20（STRING[20]）
+//   - M/I/Q 区：M0.1、MB1、MW20、MD4、I0.1、IW64、Q1.0、QD8，E/A 与 I/Q 等价
+func ParseAddress(address string) (Address, error) {
+	addr := Address{Bit: -1}
+	s := strings.ToUpper(strings.TrimSpace(address))
+	if strings.HasPrefix(s, "DB") {
+		dot := strings.Index(s, ".")
+		if dot < 0 || !strings.HasPrefix(s[dot+1:], "DB") {
+			return addr, fmt.Errorf("invalid s7 address %s", address)
+		}
+		db, err := strconv.Atoi(s[2:dot])
+		if err != nil || db < 1 || db > 65535 {
+			return addr, fmt.Errorf("invalid db number in s7 address %s", address)
+		}
+		addr.Area, addr.DB = AreaDB, db
+		s = s[dot+3:]
+		if s == "" || s[0] < 'A' || s[0] > 'Z' {
+			return addr, fmt.Errorf("missing size in s7 address %s", address)
+		}
+	} else {
+		if s == "" {
+			return addr, fmt.Errorf("invalid s7 address %s", address)
+		}
+		area, ok := areaLetters[s[0]]
+		if !ok {
+			return addr, fmt.Errorf("invalid area in s7 address %s", address)
+		}
+		addr.Area = area
+		s = s[1:]
+	}
+	addr.Size = SizeBit
+	if s != "" && s[0] >= 'A' && s[0] <= 'Z' {
+		addr.Size = Size(s[0])
+		s = s[1:]
+	}
+	var err error
+	switch addr.Size {
+	case SizeBit:
+		addr.Start, addr.Bit, err = parsePair(s, 7)
+	case SizeString:
+		addr.Start, addr.StrLen, err = parsePair(s, maxStringLen)
+		if err == nil && addr.StrLen < 1 {
+			err = fmt.Errorf("string length must be 1~%d", maxStringLen)
+		}
+	case SizeByte, SizeWord, SizeDWord, SizeLWord:
+		addr.Start, err = parseByteOffset(s)
+	default:
+		err = fmt.Errorf("unknown size %c", addr.Size)
+	}
+	if err != nil {
+		return addr, fmt.Errorf("invalid s7 address %s: %v", address, err)
+	}
+	return addr, nil
+}
+
+// parsePair 解析 "字节.n" 形式（位地址或 STRING 长度），n 不超过 max
+func parsePair(s string, max int) (int, int, error) {
+	dot := strings.Index(s, ".")
+	if dot < 0 {
+		return 0, 0, fmt.Errorf("missing .n after byte offset")
+	}
+	start, err := parseByteOffset(s[:dot])
+	if err != nil {
+		return 0, 0, err
+	}
+	n, err := strconv.Atoi(s[dot+1:])
+	if err != nil || n < 0 || n > max {
+		return 0, 0, fmt.Errorf("%q out of range 0~%d", s[dot+1:], max)
+	}
+	return start, n, nil
+}
+
+func parseByteOffset(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 || n > maxByteOffset {
+		return 0, fmt.Errorf("invalid byte offset %q", s)
+	}
+	return n, nil
+}
+
+// DataType 点位的 S7 数据类型
+type DataType int
+
+const (
+	TypeBool DataType = iota + 1
+	TypeByte
+	TypeSInt
+	TypeWord
+	TypeInt
+	TypeDWord
+	TypeDInt
+	TypeReal
+	TypeLReal
+	TypeString
+)
+
+var dataTypeNames = map[DataType]string{
+	TypeBool:   "bool",
+	TypeByte:   "byte",
+	TypeSInt:   "sint",
+	TypeWord:   "word",
+	TypeInt:    "int",
+	TypeDWord:  "dword",
+	TypeDInt:   "dint",
+	TypeReal:   "real",
+	TypeLReal:  "lreal",
+	TypeString: "string",
+}
+
+func (t DataType) String() string {
+	if name, ok := dataTypeNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("type(%d)", int(t))
+}
+
+// typeAliases points.yaml 中 type 字段可用的写法：S7 类型名及通用类型名
+var typeAliases = map[string]DataType{
+	"bool":    TypeBool,
+	"boolean": TypeBool,
+	"byte":    TypeByte,
+	"usint":   TypeByte,
+	"uint8":   TypeByte,
+	"sint":    TypeSInt,
+	"int8":    TypeSInt,
+	"word":    TypeWord,
+	"uint":    TypeWord,
+	"uint16":  TypeWord,
+	"int":     TypeInt,
+	"int16":   TypeInt,
+	"dword":   TypeDWord,
+	"udint":   TypeDWord,
+	"uint32":  TypeDWord,
+	"dint":    TypeDInt,
+	"int32":   TypeDInt,
+	"real":    TypeReal,
+	"float":   TypeReal,
+	"float32": TypeReal,
+	"lreal":   TypeLReal,
+	"double":  TypeLReal,
+	"float64": TypeLReal,
+	"string":  TypeString,
+}
+
+// defaultTypes 未配置 type 时按地址宽度取默认类型
+var defaultTypes = map[Size]DataType{
+	SizeBit:    TypeBool,
+	SizeByte:   TypeByte,
+	SizeWord:   TypeInt,
+	SizeDWord:  TypeDInt,
+	SizeLWord:  TypeLReal,
+	SizeString: TypeString,
+}
+
+// size 类型对应的地址宽度
+func (t DataType) size() Size {
+	switch t {
+	case TypeBool:
+		return SizeBit
+	case TypeByte, TypeSInt:
+		return SizeByte
+	case TypeWord, TypeInt:
+		return SizeWord
+	case TypeDWord, TypeDInt, TypeReal:
+		return SizeDWord
+	case TypeLReal:
+		return SizeLWord
+	default:
+		return SizeString
+	}
+}
+
+// ParseType 解析点位类型，空串时按地址宽度取默认类型；类型与地址宽度不一致时报错，
+// 如 DB1.DBW0 不能配置为 real
+func ParseType(typ string, addr Address) (DataType, error) {
+	name := strings.ToLower(strings.TrimSpace(typ))
+	if name == "" {
+		return defaultTypes[addr.Size], nil
+	}
+	t, ok := typeAliases[name]
+	if !ok {
+		return 0, fmt.Errorf("unsupported s7 type %q", typ)
+	}
+	if t.size() != addr.Size {
+		return 0, fmt.Errorf("type %s does not match s7 address %s", t, addr)
+	}
+	return t, nil
+}
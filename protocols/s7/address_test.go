@@ -0,0 +1,72 @@
+package s7
+
+import "testing"
+
+func TestParseAddress(t *testing.T) {
+	cases := []struct {
+		in   string
+		want Address
+	}{
+		{"DB10.DBD4", Address{Area: AreaDB, DB: 10, Start: 4, Bit: -1, Size: SizeDWord}},
+		{"DB1.DBX0.3", Address{Area: AreaDB, DB: 1, Start: 0, Bit: 3, Size: SizeBit}},
+		{"db1.dbw2", Address{Area: AreaDB, DB: 1, Start: 2, Bit: -1, Size: SizeWord}},
+		{"DB5.DBS10.20", Address{Area: AreaDB, DB: 5, Start: 10, Bit: -1, Size: SizeString, StrLen: 20}},
+		{"DB2.DBL8", Address{Area: AreaDB, DB: 2, Start: 8, Bit: -1, Size: SizeLWord}},
+		{"MW20", Address{Area: AreaMerker, Start: 20, Bit: -1, Size: SizeWord}},
+		{"M0.1", Address{Area: AreaMerker, Start: 0, Bit: 1, Size: SizeBit}},
+		{"I0.1", Address{Area: AreaInput, Start: 0, Bit: 1, Size: SizeBit}},
+		{"E2.7", Address{Area: AreaInput, Start: 2, Bit: 7, Size: SizeBit}},
+		{"IW64", Address{Area: AreaInput, Start: 64, Bit: -1, Size: SizeWord}},
+		{"Q1.0", Address{Area: AreaOutput, Start: 1, Bit: 0, Size: SizeBit}},
+		{"QB3", Address{Area: AreaOutput, Start: 3, Bit: -1, Size: SizeByte}},
+		{"AD8", Address{Area: AreaOutput, Start: 8, Bit: -1, Size: SizeDWord}},
+	}
+	for _, c := range cases {
+		addr, err := ParseAddress(c.in)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.in, err)
+			continue
+		}
+		if addr != c.want {
+			t.Errorf("%s: got %+v", c.in, addr)
+		}
+	}
+}
+
+func TestParseAddressInvalid(t *testing.T) {
+	for _, in := range []string{"", "DB0.DBW0", "DB1.DBW", "DB1.W0", "DB1.DBX0", "DB1.DBX0.8", "DB1.DBS0.0",
+		"MW", "M0", "MW1.2", "Z0.0", "DB1.DBZ0", "MW70000", "abc"} {
+		if _, err := ParseAddress(in); err == nil {
+			t.Errorf("%s: expect error", in)
+		}
+	}
+}
+
+func TestParseType(t *testing.T) {
+	cases := []struct {
+		addr, typ string
+		want      DataType
+	}{
+		{"DB1.DBX0.0", "", TypeBool},
+		{"DB1.DBW0", "", TypeInt},
+		{"DB1.DBW0", "word", TypeWord},
+		{"DB1.DBD0", "float", TypeReal},
+		{"DB1.DBD0", "DINT", TypeDInt},
+		{"DB1.DBL0", "lreal", TypeLReal},
+		{"MB0", "sint", TypeSInt},
+		{"DB1.DBS0.10", "string", TypeString},
+	}
+	for _, c := range cases {
+		addr, _ := ParseAddress(c.addr)
+		got, err := ParseType(c.typ, addr)
+		if err != nil || got != c.want {
+			t.Errorf("%s %s: got %v, %v", c.addr, c.typ, got, err)
+		}
+	}
+	for _, c := range [][2]string{{"DB1.DBW0", "real"}, {"DB1.DBD0", "bool"}, {"MB0", "string"}, {"MW0", "decimal"}} {
+		addr, _ := ParseAddress(c[0])
+		if _, err := ParseType(c[1], addr); err == nil {
+			t.Errorf("%s %s: expect error", c[0], c[1])
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package s7
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"sensor-edge/protocols"
+
+	gos7 "github.com/robinson/gos7"
+)
+
+const (
+	maxItemsPerRequest = 20  // gos7 多变量读写单次最多 20 个变量
+	readRequestHeader  = 19  // 多变量读请求头（含 TPKT/COTP），gos7 按此计算请求长度
+	readItemSize       = 12  // 请求中每个变量的描述
+	readResponseHeader = 14  // 应答 PDU 的 S7 头和参数
+	readItemHeader     = 4   // 应答中每个变量的返回码、传输类型和长度
+	defaultPDULength   = 240 // 未取得协商值时按 S7 最小 PDU 规划
+
+	wordLenBit  = 0x01
+	wordLenByte = 0x02
+)
+
+// s7Point 已解析地址和类型的点位，name 为返回值中的 PointID
+type s7Point struct {
+	name string
+	addr Address
+	typ  DataType
+}
+
+// dataItem 点位对应的多变量读写项：位地址按位读取，其余按字节读取
+func (p s7Point) dataItem() gos7.S7DataItem {
+	item := gos7.S7DataItem{
+		Area:     int(p.addr.Area),
+		DBNumber: p.addr.DB,
+		Start:    p.addr.Start,
+		WordLen:  wordLenByte,
+		Amount:   p.addr.Length(),
+		Data:     make([]byte, p.addr.Length()),
+	}
+	if p.addr.Size == SizeBit {
+		item.WordLen = wordLenBit
+		item.Bit = p.addr.Bit
+		item.Amount = 1
+	}
+	return item
+}
+
+// readRequest 一次读请求：多变量读，或单个超出 PDU 的点位（如长 STRING）按存储区分段读取
+type readRequest struct {
+	points []s7Point
+	single bool
+}
+
+// responseSize 点位在应答中占用的字节数，奇数长度补齐为偶数
+func responseSize(p s7Point) int {
+	n := p.addr.Length()
+	if p.addr.Size == SizeBit {
+		n = 1
+	}
+	return readItemHeader + n + n%2
+}
+
+// planReads 按协商的 PDU 长度把点位依次装入多变量读请求，请求和应答都不超过 PDU，
+// 每个请求最多 20 个变量；单个点位的应答超过 PDU 时单独读取
+func planReads(points []s7Point, pduLength int) []readRequest {
+	if pduLength <= 0 {
+		pduLength = defaultPDULength
+	}
+	var requests []readRequest
+	var cur []s7Point
+	reqLen, respLen := readRequestHeader, readResponseHeader
+	flush := func() {
+		if len(cur) > 0 {
+			requests = append(requests, readRequest{points: cur})
+		}
+		cur = nil
+		reqLen, respLen = readRequestHeader, readResponseHeader
+	}
+	for _, p := range points {
+		size := responseSize(p)
+		if readResponseHeader+size > pduLength {
+			flush()
+			requests = append(requests, readRequest{points: []s7Point{p}, single: true})
+			continue
+		}
+		if len(cur) == maxItemsPerRequest || reqLen+readItemSize > pduLength || respLen+size > pduLength {
+			flush()
+		}
+		cur = append(cur, p)
+		reqLen += readItemSize
+		respLen += size
+	}
+	flush()
+	return requests
+}
+
+// readPoints 规划并执行读请求，返回值与 points 顺序一致：变量返回错误码的点位为 bad，
+// 通信失败时返回错误
+func readPoints(client gos7.Client, points []s7Point, pduLength int) ([]protocols.PointValue, error) {
+	var results []protocols.PointValue
+	for _, req := range planReads(points, pduLength) {
+		if req.single {
+			p := req.points[0]
+			buf := make([]byte, p.addr.Length())
+			if err := readArea(client, p.addr, buf); err != nil {
+				return nil, err
+			}
+			results = append(results, decodePoint(p, buf))
+			continue
+		}
+		items := make([]gos7.S7DataItem, len(req.points))
+		for i, p := range req.points {
+			items[i] = p.dataItem()
+		}
+		if err := client.AGReadMulti(items, len(items)); err != nil {
+			return nil, err
+		}
+		for i, p := range req.points {
+			if items[i].Error != "" {
+				results = append(results, badValue(p.name))
+				continue
+			}
+			results = append(results, decodePoint(p, items[i].Data))
+		}
+	}
+	return results, nil
+}
+
+// readArea 按存储区读取连续字节，gos7 会按 PDU 分段
+func readArea(client gos7.Client, addr Address, buf []byte) error {
+	switch addr.Area {
+	case AreaDB:
+		return client.AGReadDB(addr.DB, addr.Start, len(buf), buf)
+	case AreaMerker:
+		return client.AGReadMB(addr.Start, len(buf), buf)
+	case AreaInput:
+		return client.AGReadEB(addr.Start, len(buf), buf)
+	case AreaOutput:
+		return client.AGReadAB(addr.Start, len(buf), buf)
+	}
+	return fmt.Errorf("unsupported s7 area %s", addr.Area)
+}
+
+func decodePoint(p s7Point, data []byte) protocols.PointValue {
+	val, err := decodeValue(p, data)
+	if err != nil {
+		return badValue(p.name)
+	}
+	return protocols.PointValue{
+		PointID:   p.name,
+		Value:     val,
+		Quality:   "good",
+		Timestamp: time.Now().Unix(),
+	}
+}
+
+// decodeValue 按点位类型解码（S7 为大端字节序），STRING 取实际长度内的字符
+func decodeValue(p s7Point, data []byte) (interface{}, error) {
+	need := p.addr.Length()
+	if p.addr.Size == SizeBit {
+		need = 1
+	}
+	if len(data) < need {
+		return nil, fmt.Errorf("short data for %s", p.addr)
+	}
+	switch p.typ {
+	case TypeBool:
+		if p.addr.Size == SizeBit {
+			return data[0]&0x01 == 0x01, nil
+		}
+		return data[0] != 0, nil
+	case TypeByte:
+		return data[0], nil
+	case TypeSInt:
+		return int8(data[0]), nil
+	case TypeWord:
+		return binary.BigEndian.Uint16(data), nil
+	case TypeInt:
+		return int16(binary.BigEndian.Uint16(data)), nil
+	case TypeDWord:
+		return binary.BigEndian.Uint32(data), nil
+	case TypeDInt:
+		return int32(binary.BigEndian.Uint32(data)), nil
+	case TypeReal:
+		return math.Float32frombits(binary.BigEndian.Uint32(data)), nil
+	case TypeLReal:
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), nil
+	case TypeString:
+		n := int(data[1])
+		if n > int(data[0]) {
+			return nil, fmt.Errorf("invalid string length %d at %s", n, p.addr)
+		}
+		if n > need-2 {
+			n = need - 2 // PLC 中的字符串比配置的长度长时截断
+		}
+		return string(data[2 : 2+n]), nil
+	}
+	return nil, fmt.Errorf("unsupported s7 type %s", p.typ)
+}
+
+func badValue(name string) protocols.PointValue {
+	return protocols.PointValue{
+		PointID:   name,
+		Value:     nil,
+		Quality:   "bad",
+		Timestamp: time.Now().Unix(),
+	}
+}
@@ -0,0 +1,105 @@
+package s7
+
+import (
+	"math"
+	"testing"
+
+	gos7 "github.com/robinson/gos7"
+)
+
+// fakeClient 按字节数组模拟 DB1，只实现多变量读和 DB 读
+type fakeClient struct {
+	gos7.Client
+	db       []byte
+	requests int
+}
+
+func (f *fakeClient) AGReadMulti(items []gos7.S7DataItem, count int) error {
+	f.requests++
+	for i := range items[:count] {
+		it := &items[i]
+		if it.Area != int(AreaDB) || it.DBNumber != 1 || it.Start+len(it.Data) > len(f.db) {
+			it.Error = "CPU : Address out of range"
+			continue
+		}
+		if it.WordLen == wordLenBit {
+			it.Data[0] = f.db[it.Start] >> uint(it.Bit) & 0x01
+			continue
+		}
+		copy(it.Data, f.db[it.Start:])
+	}
+	return nil
+}
+
+func (f *fakeClient) AGReadDB(db, start, size int, buf []byte) error {
+	f.requests++
+	copy(buf, f.db[start:start+size])
+	return nil
+}
+
+func mustPoint(t *testing.T, address, typ string) s7Point {
+	p, err := parsePoint(address, address, typ)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPlanReads(t *testing.T) {
+	var points []s7Point
+	for i := 0; i < 45; i++ {
+		points = append(points, mustPoint(t, "DB1.DBW0", ""))
+	}
+	// 每个请求最多 20 个变量
+	if reqs := planReads(points, 960); len(reqs) != 3 || len(reqs[0].points) != 20 || len(reqs[2].points) != 5 {
+		t.Fatalf("unexpected plan: %d requests", len(reqs))
+	}
+	// PDU 240：请求 19+12*n ≤ 240 最多 18 个变量
+	if reqs := planReads(points[:20], 240); len(reqs) != 2 || len(reqs[0].points) != 18 {
+		t.Fatalf("unexpected plan for pdu 240: %+v", reqs)
+	}
+	// 应答受限：STRING[100] 占 4+102 字节，两个一组
+	strs := []s7Point{mustPoint(t, "DB1.DBS0.100", ""), mustPoint(t, "DB1.DBS0.100", ""), mustPoint(t, "DB1.DBS0.100", "")}
+	if reqs := planReads(strs, 240); len(reqs) != 2 || len(reqs[0].points) != 2 {
+		t.Fatalf("unexpected plan for strings: %+v", reqs)
+	}
+	// 超出 PDU 的点位单独读取，前后顺序不变
+	mixed := []s7Point{points[0], mustPoint(t, "DB1.DBS0.254", ""), points[1]}
+	reqs := planReads(mixed, 240)
+	if len(reqs) != 3 || reqs[0].single || !reqs[1].single || reqs[2].single {
+		t.Fatalf("unexpected plan for oversize: %+v", reqs)
+	}
+}
+
+func TestReadPoints(t *testing.T) {
+	db := make([]byte, 64)
+	db[0] = 0x08                   // DBX0.3
+	db[2], db[3] = 0xFF, 0xFE      // DBW2 = -2
+	bits := math.Float32bits(21.5) // DBD4
+	db[4], db[5], db[6], db[7] = byte(bits>>24), byte(bits>>16), byte(bits>>8), byte(bits)
+	copy(db[10:], []byte{10, 3, 'a', 'b', 'c'}) // DBS10.10
+	client := &fakeClient{db: db}
+	points := []s7Point{
+		mustPoint(t, "DB1.DBX0.3", "bool"),
+		mustPoint(t, "DB1.DBW2", "int"),
+		mustPoint(t, "DB1.DBD4", "real"),
+		mustPoint(t, "DB1.DBS10.10", "string"),
+		mustPoint(t, "DB2.DBW0", "word"),
+	}
+	values, err := readPoints(client, points, 240)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.requests != 1 {
+		t.Errorf("expect 1 request, got %d", client.requests)
+	}
+	want := []interface{}{true, int16(-2), float32(21.5), "abc", nil}
+	for i, v := range values {
+		if v.PointID != points[i].name || v.Value != want[i] {
+			t.Errorf("%s: got %v (%s)", points[i].name, v.Value, v.Quality)
+		}
+	}
+	if values[3].Quality != "good" || values[4].Quality != "bad" {
+		t.Errorf("unexpected quality: %s, %s", values[3].Quality, values[4].Quality)
+	}
+}
@@ -1,17 +1,27 @@
 package s7
 
 import (
-	"sensor-edge/protocols"
+	"fmt"
+	"log"
+	"sync"
 	"time"
 
+	"sensor-edge/protocols"
+
 	gos7 "github.com/robinson/gos7"
 )
 
 type S7Client struct {
 	client  gos7.Client
 	handler *gos7.TCPClientHandler
+
+	mu     sync.RWMutex
+	points map[string]pointTable // 设备 ID -> 点位配置，同一 PLC 上的多个设备共享客户端
 }
 
+// pointTable 按地址和点位名索引的点位配置
+type pointTable map[string]protocols.PointConfig
+
 func (s *S7Client) Init(config map[string]interface{}) error {
 	ip := config["ip"].(string)
 	// 兼容int/float64类型
@@ -43,26 +53,87 @@ func (s *S7Client) Init(config map[string]interface{}) error {
 	return nil
 }
 
+// SetPointConfigs 记录设备的点位地址和类型，地址或类型无效的点位读取时返回 bad
+func (s *S7Client) SetPointConfigs(deviceID string, points []protocols.PointConfig) {
+	table := make(pointTable, len(points)*2)
+	for _, p := range points {
+		if _, err := parsePoint(p.PointID, p.Address, p.Type); err != nil {
+			log.Printf("[S7] 设备 %s 点位 %s 配置无效: %v", deviceID, p.PointID, err)
+		}
+		if p.Address != "" {
+			table[p.Address] = p
+		}
+		if p.PointID != "" {
+			table[p.PointID] = p
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.points == nil {
+		s.points = make(map[string]pointTable)
+	}
+	s.points[deviceID] = table
+}
+
+// parsePoint 解析点位地址和类型，name 为返回值中的 PointID
+func parsePoint(name, address, typ string) (s7Point, error) {
+	addr, err := ParseAddress(address)
+	if err != nil {
+		return s7Point{}, err
+	}
+	t, err := ParseType(typ, addr)
+	if err != nil {
+		return s7Point{}, err
+	}
+	return s7Point{name: name, addr: addr, typ: t}, nil
+}
+
+// Read 读取设备配置的全部点位，PointID 为点位名
 func (s *S7Client) Read(deviceID string) ([]protocols.PointValue, error) {
-	buffer := make([]byte, 2)
-	err := s.client.AGReadDB(1, 0, 2, buffer) // 读取 DB1.DBX0.0 (示例)
+	s.mu.RLock()
+	var points []s7Point
+	var bad []protocols.PointValue
+	for key, p := range s.points[deviceID] {
+		if key != p.PointID {
+			continue
+		}
+		pt, err := parsePoint(p.PointID, p.Address, p.Type)
+		if err != nil {
+			bad = append(bad, badValue(p.PointID))
+			continue
+		}
+		points = append(points, pt)
+	}
+	s.mu.RUnlock()
+	values, err := s.readPoints(points)
 	if err != nil {
 		return nil, err
 	}
-
-	running := buffer[0]&1 == 1
-
-	return []protocols.PointValue{
-		{
-			PointID:   "fan_status",
-			Value:     running,
-			Quality:   "good",
-			Timestamp: time.Now().Unix(),
-		},
-	}, nil
+	return append(values, bad...), nil
 }
+
+// Write 写入单个点位，point 可为点位名或地址；未配置的地址按地址宽度取默认类型
 func (s *S7Client) Write(point string, value interface{}) error {
-	return nil
+	p, err := s.resolvePoint(point)
+	if err != nil {
+		return err
+	}
+	if s.client == nil {
+		return fmt.Errorf("s7 client not connected")
+	}
+	return writePoint(s.client, p, value)
+}
+
+// resolvePoint 在各设备的点位配置中查找点位（Write 不区分设备），找不到时把 point 当作地址
+func (s *S7Client) resolvePoint(point string) (s7Point, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, table := range s.points {
+		if p, ok := table[point]; ok {
+			return parsePoint(p.PointID, p.Address, p.Type)
+		}
+	}
+	return parsePoint(point, point, "")
 }
 
 func (s *S7Client) Close() error {
@@ -77,27 +148,83 @@ func init() {
 	protocols.Register("s7", NewS7Client)
 }
 
-// ReadBatch 批量读取接口，返回指定数据点
-func (c *S7Client) ReadBatch(deviceID string, function string, points []string) ([]protocols.PointValue, error) {
-	// function 参数暂未用到，保留兼容
+// ReadBatch 按地址批量读取，类型取自点位配置（未配置时按地址宽度取默认类型），
+// 一组点位用多变量读合并为尽量少的请求，返回值按地址回填；地址或类型无效、
+// PLC 返回错误码的点位为 bad。function 参数不影响读取
+func (s *S7Client) ReadBatch(deviceID string, function string, points []string) ([]protocols.PointValue, error) {
 	if len(points) == 0 {
 		return nil, nil
 	}
-	var values []protocols.PointValue
-	for _, pt := range points {
-		buffer := make([]byte, 2)
-		err := c.client.AGReadDB(1, 0, 2, buffer)
+	parsed, invalid := s.batchPoints(deviceID, points)
+	values, err := s.readPoints(parsed)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range invalid {
+		values = append(values, badValue(name))
+	}
+	return values, nil
+}
+
+// ReadPlan 返回 ReadBatch 对这些点位将发出的读请求：多变量读的 Quantity 为变量个数，
+// 单独读取的点位为起始字节和字节数
+func (s *S7Client) ReadPlan(deviceID string, function string, points []string) (protocols.ReadPlan, error) {
+	parsed, invalid := s.batchPoints(deviceID, points)
+	plan := protocols.ReadPlan{Skipped: invalid}
+	for _, req := range planReads(parsed, s.pduLength()) {
+		r := protocols.ReadRequest{Function: function, Quantity: len(req.points)}
+		if req.single {
+			r.Start, r.Quantity = req.points[0].addr.Start, req.points[0].addr.Length()
+		}
+		for _, p := range req.points {
+			r.Points = append(r.Points, p.name)
+		}
+		plan.Requests = append(plan.Requests, r)
+	}
+	return plan, nil
+}
+
+// batchPoints 解析 ReadBatch 的地址，返回可读取的点位和无效的地址
+func (s *S7Client) batchPoints(deviceID string, points []string) ([]s7Point, []string) {
+	s.mu.RLock()
+	table := s.points[deviceID]
+	s.mu.RUnlock()
+	var parsed []s7Point
+	var invalid []string
+	for _, addr := range points {
+		typ := ""
+		if p, ok := table[addr]; ok {
+			typ = p.Type
+		}
+		pt, err := parsePoint(addr, addr, typ)
 		if err != nil {
-			values = append(values, protocols.PointValue{PointID: pt, Quality: "bad", Value: nil, Timestamp: time.Now().Unix()})
+			invalid = append(invalid, addr)
 			continue
 		}
-		val := buffer[0]&1 == 1
-		values = append(values, protocols.PointValue{PointID: pt, Quality: "good", Value: val, Timestamp: time.Now().Unix()})
+		parsed = append(parsed, pt)
 	}
-	return values, nil
+	return parsed, invalid
 }
 
-func(c *S7Client) Reconnect() error {
+func (s *S7Client) readPoints(points []s7Point) ([]protocols.PointValue, error) {
+	if len(points) == 0 {
+		return nil, nil
+	}
+	if s.client == nil {
+		return nil, fmt.Errorf("s7 client not connected")
+	}
+	return readPoints(s.client, points, s.pduLength())
+}
+
+// pduLength 连接时协商的 PDU 长度
+func (s *S7Client) pduLength() int {
+	if s.handler == nil {
+		return 0
+	}
+	return s.handler.PDULength
+}
+
+func (c *S7Client) Reconnect() error {
 	if c.handler == nil {
 		return nil // 未初始化时不需要重连
 	}
@@ -107,4 +234,4 @@ func(c *S7Client) Reconnect() error {
 	}
 	c.client = gos7.NewClient(c.handler)
 	return nil
-}
\ No newline at end of file
+}
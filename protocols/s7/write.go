@@ -0,0 +1,151 @@
+package s7
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"sensor-edge/utils"
+
+	gos7 "github.com/robinson/gos7"
+)
+
+// writePoint 按点位类型编码并写入：位地址读出所在字节改写该位后写回（见 writeBit），
+// 其余按字节写入；STRING 只写实际长度和字符，保留 PLC 中的最大长度字节。
+// I 区为只读的过程映像输入，写入报错
+func writePoint(client gos7.Client, p s7Point, value interface{}) error {
+	if p.addr.Area == AreaInput {
+		return fmt.Errorf("s7 address %s is read-only (input area)", p.addr)
+	}
+	data, err := encodeValue(p, value)
+	if err != nil {
+		return fmt.Errorf("encode value for point %s failed: %v", p.name, err)
+	}
+	if p.addr.Size == SizeBit {
+		return writeBit(client, p.addr, data[0] == 1)
+	}
+	start := p.addr.Start
+	if p.addr.Size == SizeString {
+		start++
+	}
+	return writeArea(client, p.addr, start, data)
+}
+
+// writeBit 读出位所在的字节，改写该位后整字节写回（gos7 的多变量写会把报文打印到标准输出，不用它写位）。
+// 读和写是两次请求，不是原子操作：期间 PLC 程序改写同一字节其他位的结果会被覆盖，
+// 与 PLC 程序共用字节的位需要网关独占写入，或在 PLC 侧单独分配字节
+func writeBit(client gos7.Client, addr Address, on bool) error {
+	buf := make([]byte, 1)
+	if err := readArea(client, addr, buf); err != nil {
+		return fmt.Errorf("read %s before bit write failed: %v", addr, err)
+	}
+	mask := byte(1) << uint(addr.Bit)
+	if on {
+		buf[0] |= mask
+	} else {
+		buf[0] &^= mask
+	}
+	return writeArea(client, addr, addr.Start, buf)
+}
+
+// writeArea 从 start 字节起按存储区写入连续字节，gos7 会按 PDU 分段
+func writeArea(client gos7.Client, addr Address, start int, data []byte) error {
+	switch addr.Area {
+	case AreaDB:
+		return client.AGWriteDB(addr.DB, start, len(data), data)
+	case AreaMerker:
+		return client.AGWriteMB(start, len(data), data)
+	case AreaOutput:
+		return client.AGWriteAB(start, len(data), data)
+	}
+	return fmt.Errorf("s7 area %s is not writable", addr.Area)
+}
+
+// integerRanges 整数类型的取值范围
+var integerRanges = map[DataType][2]float64{
+	TypeByte:  {0, math.MaxUint8},
+	TypeSInt:  {math.MinInt8, math.MaxInt8},
+	TypeWord:  {0, math.MaxUint16},
+	TypeInt:   {math.MinInt16, math.MaxInt16},
+	TypeDWord: {0, math.MaxUint32},
+	TypeDInt:  {math.MinInt32, math.MaxInt32},
+}
+
+// encodeValue 按点位类型把值编码为大端字节：bool 接受 bool 或 0/1；整数类型接受整数值并检查范围；
+// real/lreal 接受任意数值；string 只接受字符串且不超过地址中的长度。类型不符时报错
+func encodeValue(p s7Point, value interface{}) ([]byte, error) {
+	switch p.typ {
+	case TypeBool:
+		on, err := toBool(value)
+		if err != nil {
+			return nil, err
+		}
+		if on {
+			return []byte{1}, nil
+		}
+		return []byte{0}, nil
+	case TypeString:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("type mismatch: %s needs a string value, got %T", p.typ, value)
+		}
+		if len(s) > p.addr.StrLen {
+			return nil, fmt.Errorf("string length %d exceeds %d", len(s), p.addr.StrLen)
+		}
+		return append([]byte{byte(len(s))}, s...), nil
+	}
+	if _, ok := value.(string); ok {
+		return nil, fmt.Errorf("type mismatch: %s needs a numeric value, got string", p.typ)
+	}
+	f, ok := utils.ToFloat64(value)
+	if !ok {
+		return nil, fmt.Errorf("type mismatch: %s needs a numeric value, got %T", p.typ, value)
+	}
+	buf := make([]byte, p.addr.Length())
+	switch p.typ {
+	case TypeReal:
+		if math.Abs(f) > math.MaxFloat32 && !math.IsInf(f, 0) {
+			return nil, fmt.Errorf("value %v out of real range", f)
+		}
+		binary.BigEndian.PutUint32(buf, math.Float32bits(float32(f)))
+		return buf, nil
+	case TypeLReal:
+		binary.BigEndian.PutUint64(buf, math.Float64bits(f))
+		return buf, nil
+	}
+	r, ok := integerRanges[p.typ]
+	if !ok {
+		return nil, fmt.Errorf("unsupported s7 type %s", p.typ)
+	}
+	if f != math.Trunc(f) {
+		return nil, fmt.Errorf("value %v is not an integer, cannot write as %s", f, p.typ)
+	}
+	if f < r[0] || f > r[1] {
+		return nil, fmt.Errorf("value %v out of %s range [%v, %v]", f, p.typ, r[0], r[1])
+	}
+	n := int64(f)
+	switch len(buf) {
+	case 1:
+		buf[0] = byte(n)
+	case 2:
+		binary.BigEndian.PutUint16(buf, uint16(n))
+	default:
+		binary.BigEndian.PutUint32(buf, uint32(n))
+	}
+	return buf, nil
+}
+
+// toBool 接受 bool 或数值 0/1
+func toBool(value interface{}) (bool, error) {
+	if b, ok := value.(bool); ok {
+		return b, nil
+	}
+	if _, ok := value.(string); ok {
+		return false, fmt.Errorf("type mismatch: bool needs a bool value, got string")
+	}
+	f, ok := utils.ToFloat64(value)
+	if !ok || (f != 0 && f != 1) {
+		return false, fmt.Errorf("invalid bool value: %v", value)
+	}
+	return f == 1, nil
+}
@@ -0,0 +1,102 @@
+package s7
+
+import (
+	"math"
+	"testing"
+
+	"sensor-edge/protocols"
+)
+
+func (f *fakeClient) AGWriteDB(db, start, size int, buf []byte) error {
+	f.requests++
+	copy(f.db[start:start+size], buf)
+	return nil
+}
+
+func TestWrite(t *testing.T) {
+	fake := &fakeClient{db: make([]byte, 64)}
+	fake.db[40] = 20 // DBS40.10 在 PLC 中声明为 STRING[20]
+	s := &S7Client{client: fake}
+	s.SetPointConfigs("plc", []protocols.PointConfig{
+		{PointID: "run", Address: "DB1.DBX0.3", Type: "bool"},
+		{PointID: "speed", Address: "DB1.DBW2", Type: "int"},
+		{PointID: "setpoint", Address: "DB1.DBD4", Type: "real"},
+		{PointID: "total", Address: "DB1.DBL16", Type: "lreal"},
+		{PointID: "count", Address: "DB1.DBD24", Type: "dword"},
+		{PointID: "recipe", Address: "DB1.DBS40.10", Type: "string"},
+	})
+	fake.db[0] = 0x01
+	writes := map[string]interface{}{
+		"run":        true,
+		"speed":      float64(-300), // JSON 数值
+		"setpoint":   21.5,
+		"total":      1e10,
+		"count":      uint32(70000),
+		"recipe":     "abc",
+		"DB1.DBB30":  200,
+		"DB1.DBX0.1": 1,
+	}
+	for point, v := range writes {
+		if err := s.Write(point, v); err != nil {
+			t.Fatalf("write %s: %v", point, err)
+		}
+	}
+	if fake.db[0] != 0x0B {
+		t.Errorf("bit write changed other bits: %08b", fake.db[0])
+	}
+	if fake.db[40] != 20 {
+		t.Errorf("string write overwrote max length: %d", fake.db[40])
+	}
+	names := []string{"speed", "setpoint", "total", "count", "recipe", "DB1.DBB30"}
+	want := []interface{}{int16(-300), float32(21.5), 1e10, uint32(70000), "abc", uint8(200)}
+	var points []s7Point
+	for _, name := range names {
+		p, err := s.resolvePoint(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		points = append(points, p)
+	}
+	values, err := readPoints(fake, points, 240)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range values {
+		if v.Value != want[i] {
+			t.Errorf("%s: got %v, want %v", names[i], v.Value, want[i])
+		}
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	s := &S7Client{client: &fakeClient{db: make([]byte, 64)}}
+	s.SetPointConfigs("plc", []protocols.PointConfig{
+		{PointID: "speed", Address: "DB1.DBW2", Type: "int"},
+		{PointID: "flag", Address: "DB1.DBX0.0", Type: "bool"},
+		{PointID: "name", Address: "DB1.DBS10.4", Type: "string"},
+		{PointID: "level", Address: "DB1.DBD4", Type: "real"},
+	})
+	cases := []struct {
+		point string
+		value interface{}
+	}{
+		{"I0.1", true},             // 输入区只读
+		{"IW4", 1},                 // 输入区只读
+		{"speed", 1.5},             // 非整数
+		{"speed", 40000},           // 超出 INT 范围
+		{"speed", "12"},            // 字符串写入数值点位
+		{"flag", 2},                // 非 0/1
+		{"flag", "true"},           // 字符串写入位
+		{"name", 12},               // 数值写入 STRING
+		{"name", "too long"},       // 超出 STRING 长度
+		{"level", []int{1}},        // 不支持的值类型
+		{"level", math.MaxFloat64}, // 超出 REAL 范围
+		{"DB1.DBQ0", 1},            // 无效地址
+		{"DB1.DBB0", -1},           // 超出 BYTE 范围
+	}
+	for _, c := range cases {
+		if err := s.Write(c.point, c.value); err == nil {
+			t.Errorf("write %s = %v: expect error", c.point, c.value)
+		}
+	}
+}
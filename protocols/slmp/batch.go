@@ -0,0 +1,364 @@
+package slmp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"sensor-edge/protocols"
+	"sensor-edge/utils"
+)
+
+// slmpPoint 已解析地址的点位，words 为字点位占用的字数（按 Format），位软元件为 0
+type slmpPoint struct {
+	name  string
+	addr  Address
+	words int
+}
+
+// span 点位占用的编号范围（闭区间）
+func (p slmpPoint) span() (int, int) {
+	if p.words == 0 {
+		return p.addr.Number, p.addr.Number
+	}
+	return p.addr.Number, p.addr.Number + p.words - 1
+}
+
+// wordCount 根据格式返回点位占用的字数，未配置或单字格式为 1
+func wordCount(format string) int {
+	if n := utils.FormatSize(format); n > 2 {
+		return n / 2
+	}
+	return 1
+}
+
+// parsePoints 解析点位地址，返回可读取的点位和地址无效的点位名
+func parsePoints(configs []protocols.PointConfig) ([]slmpPoint, []string) {
+	var points []slmpPoint
+	var bad []string
+	for _, c := range configs {
+		addr, err := ParseAddress(c.Address)
+		if err != nil {
+			bad = append(bad, c.PointID)
+			continue
+		}
+		p := slmpPoint{name: c.PointID, addr: addr}
+		if !addr.Device.Bit {
+			p.words = 1
+			if !addr.HasBit() {
+				p.words = wordCount(c.Format)
+			}
+		}
+		points = append(points, p)
+	}
+	return points, bad
+}
+
+// readRequest 规划出的一次读请求：批量读（字或位单位）或随机读
+type readRequest struct {
+	random bool
+	bit    bool
+	start  Address
+	count  int // 批量读的点数；随机读的字访问与双字访问点数之和
+	points []slmpPoint
+}
+
+func (r readRequest) command() uint16 {
+	if r.random {
+		return cmdRandomRead
+	}
+	return cmdBatchRead
+}
+
+// planReads 同一软元件的点位按编号排序后贪心合并为批量读（空洞不超过 maxGap，
+// 不超过单次点数上限）；只含一个字点位的块改为随机读，多个零散点位一次往返读完。
+// 只有一个零散点位时仍用批量读
+func planReads(points []slmpPoint, maxGap int) []readRequest {
+	groups := make(map[byte][]slmpPoint)
+	var codes []int
+	for _, p := range points {
+		code := p.addr.Device.Code
+		if _, ok := groups[code]; !ok {
+			codes = append(codes, int(code))
+		}
+		groups[code] = append(groups[code], p)
+	}
+	sort.Ints(codes)
+	var batches, singles []readRequest
+	for _, code := range codes {
+		for _, b := range mergeBlocks(groups[byte(code)], maxGap) {
+			if !b.bit && len(b.points) == 1 {
+				singles = append(singles, b)
+				continue
+			}
+			batches = append(batches, b)
+		}
+	}
+	if len(singles) < 2 {
+		return append(batches, singles...)
+	}
+	var cur *readRequest
+	for _, b := range singles {
+		p := b.points[0]
+		n := randomAccesses(p)
+		if cur != nil && cur.count+n > maxRandomRead {
+			batches = append(batches, *cur)
+			cur = nil
+		}
+		if cur == nil {
+			cur = &readRequest{random: true}
+		}
+		cur.count += n
+		cur.points = append(cur.points, p)
+	}
+	return append(batches, *cur)
+}
+
+// randomAccesses 随机读中点位占用的访问点数：单字按字访问，多字按双字访问（4 字为 2 个双字）
+func randomAccesses(p slmpPoint) int {
+	if p.words == 1 {
+		return 1
+	}
+	return (p.words + 1) / 2
+}
+
+// mergeBlocks 合并同一软元件的点位
+func mergeBlocks(points []slmpPoint, maxGap int) []readRequest {
+	sorted := make([]slmpPoint, len(points))
+	copy(sorted, points)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].addr.Number < sorted[j].addr.Number })
+	limit := maxBatchWords
+	bit := sorted[0].addr.Device.Bit
+	if bit {
+		limit = maxBatchBits
+	}
+	var blocks []readRequest
+	var cur *readRequest
+	for _, p := range sorted {
+		start, end := p.span()
+		if cur != nil {
+			curEnd := cur.start.Number + cur.count - 1
+			newEnd := curEnd
+			if end > newEnd {
+				newEnd = end
+			}
+			if start-curEnd-1 <= maxGap && newEnd-cur.start.Number+1 <= limit {
+				cur.count = newEnd - cur.start.Number + 1
+				cur.points = append(cur.points, p)
+				continue
+			}
+			blocks = append(blocks, *cur)
+		}
+		a := p.addr
+		a.Bit = -1
+		cur = &readRequest{bit: bit, start: a, count: end - start + 1, points: []slmpPoint{p}}
+	}
+	return append(blocks, *cur)
+}
+
+// readPoints 规划并执行读请求。PLC 对批量读返回软元件错误时该块点位为 bad；
+// 随机读返回软元件错误时逐个点位批量读，找出出错的点位；其他错误（通信失败等）直接返回
+func (s *SLMPClient) readPoints(points []slmpPoint) ([]protocols.PointValue, error) {
+	var results []protocols.PointValue
+	for _, req := range planReads(points, s.maxGap) {
+		values, err := s.readRequest(req)
+		if err != nil && isDeviceError(err) {
+			if req.random {
+				for _, p := range req.points {
+					single := readRequest{start: p.addr, count: p.words, points: []slmpPoint{p}}
+					single.start.Bit = -1
+					v, err := s.readRequest(single)
+					if err != nil && !isDeviceError(err) {
+						return nil, err
+					}
+					if err != nil {
+						v = []protocols.PointValue{badValue(p.name)}
+					}
+					results = append(results, v...)
+				}
+				continue
+			}
+			for _, p := range req.points {
+				results = append(results, badValue(p.name))
+			}
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, values...)
+	}
+	return results, nil
+}
+
+func isDeviceError(err error) bool {
+	var e *EndCodeError
+	return errors.As(err, &e) && e.IsDeviceError()
+}
+
+// readRequest 执行一次读请求并按点位取值
+func (s *SLMPClient) readRequest(req readRequest) ([]protocols.PointValue, error) {
+	if req.random {
+		return s.readRandomPoints(req.points)
+	}
+	results := make([]protocols.PointValue, 0, len(req.points))
+	if req.bit {
+		bits, err := s.ReadBits(req.start, req.count)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range req.points {
+			results = append(results, goodValue(p.name, bits[p.addr.Number-req.start.Number]))
+		}
+		return results, nil
+	}
+	words, err := s.ReadWords(req.start, req.count)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range req.points {
+		offset := p.addr.Number - req.start.Number
+		results = append(results, goodValue(p.name, wordValue(p, words[offset:offset+p.words])))
+	}
+	return results, nil
+}
+
+// readRandomPoints 单字点位按字访问，多字点位按双字访问后拆回低字在前的字序列
+func (s *SLMPClient) readRandomPoints(points []slmpPoint) ([]protocols.PointValue, error) {
+	var words, dwords []Address
+	for _, p := range points {
+		a := p.addr
+		a.Bit = -1
+		if p.words == 1 {
+			words = append(words, a)
+			continue
+		}
+		for i := 0; i < p.words; i += 2 {
+			d := a
+			d.Number += i
+			dwords = append(dwords, d)
+		}
+	}
+	w, d, err := s.ReadRandom(words, dwords)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]protocols.PointValue, 0, len(points))
+	wi, di := 0, 0
+	for _, p := range points {
+		if p.words == 1 {
+			results = append(results, goodValue(p.name, wordValue(p, w[wi:wi+1])))
+			wi++
+			continue
+		}
+		vals := make([]uint16, 0, p.words+1)
+		for i := 0; i < p.words; i += 2 {
+			vals = append(vals, uint16(d[di]), uint16(d[di]>>16))
+			di++
+		}
+		results = append(results, goodValue(p.name, wordValue(p, vals[:p.words])))
+	}
+	return results, nil
+}
+
+// wordValue 字内位地址返回 bool，单字返回 uint16，多字返回 []uint16（低字在前，
+// 三菱 32 位数据对应 Long CD AB / Float CD AB 格式）
+func wordValue(p slmpPoint, words []uint16) interface{} {
+	if p.addr.HasBit() {
+		return (words[0]>>uint(p.addr.Bit))&0x01 == 0x01
+	}
+	if len(words) == 1 {
+		return words[0]
+	}
+	out := make([]uint16, len(words))
+	copy(out, words)
+	return out
+}
+
+// encodeWordValue 按格式把值编码为字序列（与读取时的字序一致）；未配置格式时写单字，
+// 接受 -32768~65535 的整数（负数按补码），[]uint16 原样写入
+func encodeWordValue(format string, value interface{}) ([]uint16, error) {
+	if v, ok := value.([]uint16); ok {
+		if len(v) == 0 || len(v) > maxBatchWords {
+			return nil, fmt.Errorf("invalid word count: %d", len(v))
+		}
+		return v, nil
+	}
+	if b, ok := value.(bool); ok {
+		value = 0
+		if b {
+			value = 1
+		}
+	}
+	var raw []byte
+	var err error
+	switch {
+	case format != "" && utils.CanonicalFormat(format) != "":
+		raw, err = utils.EncodeFormat(format, value)
+	case format != "":
+		return nil, fmt.Errorf("unsupported format: %s", format)
+	default:
+		f, ok := utils.ToFloat64(value)
+		if !ok {
+			return nil, fmt.Errorf("unsupported value type: %T", value)
+		}
+		if f < 0 {
+			raw, err = utils.EncodeFormat(utils.FormatInt, f)
+		} else {
+			raw, err = utils.EncodeFormat(utils.FormatUInt, f)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	words := make([]uint16, len(raw)/2)
+	for i := range words {
+		words[i] = binary.BigEndian.Uint16(raw[2*i:])
+	}
+	return words, nil
+}
+
+// toBool 接受 bool 或数值 0/1
+func toBool(value interface{}) (bool, error) {
+	if b, ok := value.(bool); ok {
+		return b, nil
+	}
+	f, ok := utils.ToFloat64(value)
+	if !ok || (f != 0 && f != 1) {
+		return false, fmt.Errorf("invalid bool value: %v", value)
+	}
+	return f == 1, nil
+}
+
+// toInt 兼容 YAML/JSON 解析出的各种数值类型
+func toInt(v interface{}) (int, bool) {
+	switch vv := v.(type) {
+	case int:
+		return vv, true
+	case int64:
+		return int(vv), true
+	case float64:
+		return int(vv), true
+	}
+	return 0, false
+}
+
+func goodValue(name string, v interface{}) protocols.PointValue {
+	return protocols.PointValue{
+		PointID:   name,
+		Value:     v,
+		Quality:   "good",
+		Timestamp: time.Now().Unix(),
+	}
+}
+
+func badValue(name string) protocols.PointValue {
+	return protocols.PointValue{
+		PointID:   name,
+		Value:     nil,
+		Quality:   "bad",
+		Timestamp: time.Now().Unix(),
+	}
+}
@@ -0,0 +1,311 @@
+package slmp
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sensor-edge/protocols"
+)
+
+const simDeviceSize = 4096 // 模拟 PLC 每种软元件的点数，超出返回 0xC056
+
+// simPLC 按 3E/4E 二进制帧应答批量读写、随机读写的模拟 PLC
+type simPLC struct {
+	ln    net.Listener
+	frame FrameType
+	words map[byte][]uint16
+	bits  map[byte][]bool
+	stall atomic.Int32 // 大于 0 时下一次应答推迟到客户端超时之后
+}
+
+func newSimPLC(t *testing.T, frame FrameType) *simPLC {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &simPLC{ln: ln, frame: frame, words: make(map[byte][]uint16), bits: make(map[byte][]bool)}
+	for _, d := range devices {
+		if d.Bit {
+			p.bits[d.Code] = make([]bool, simDeviceSize)
+		} else {
+			p.words[d.Code] = make([]uint16, simDeviceSize)
+		}
+	}
+	go p.serve()
+	t.Cleanup(func() { ln.Close() })
+	return p
+}
+
+func (p *simPLC) client(t *testing.T) *SLMPClient {
+	c := &SLMPClient{}
+	frame := "3E"
+	if p.frame == Frame4E {
+		frame = "4E"
+	}
+	err := c.Init(map[string]interface{}{"ip": "127.0.0.1", "port": p.ln.Addr().(*net.TCPAddr).Port, "frame": frame, "timeout": 500})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func (p *simPLC) serve() {
+	for {
+		conn, err := p.ln.Accept()
+		if err != nil {
+			return
+		}
+		go p.handle(conn)
+	}
+}
+
+func (p *simPLC) handle(conn net.Conn) {
+	defer conn.Close()
+	for {
+		head := 9
+		if p.frame == Frame4E {
+			head = 13
+		}
+		req := make([]byte, head)
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		body := make([]byte, binary.LittleEndian.Uint16(req[head-2:]))
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+		cmd, sub := binary.LittleEndian.Uint16(body[2:]), binary.LittleEndian.Uint16(body[4:])
+		data, code := p.execute(cmd, sub, body[6:])
+		var resp []byte
+		if p.frame == Frame4E {
+			resp = []byte{0xD4, 0x00, req[2], req[3], 0x00, 0x00}
+		} else {
+			resp = []byte{0xD0, 0x00}
+		}
+		resp = append(resp, req[head-7:head-2]...)
+		if code != 0 {
+			data = append(req[head-7:head-2:head-2], body[2:6]...)
+		}
+		resp = binary.LittleEndian.AppendUint16(resp, uint16(2+len(data)))
+		resp = binary.LittleEndian.AppendUint16(resp, code)
+		if p.stall.Add(-1) >= 0 {
+			time.Sleep(time.Second)
+		}
+		conn.Write(append(resp, data...))
+	}
+}
+
+func device(data []byte) (byte, int) {
+	return data[3], int(data[0]) | int(data[1])<<8 | int(data[2])<<16
+}
+
+func (p *simPLC) execute(cmd, sub uint16, data []byte) ([]byte, uint16) {
+	switch cmd {
+	case cmdBatchRead, cmdBatchWrite:
+		code, start := device(data)
+		n := int(binary.LittleEndian.Uint16(data[4:]))
+		if sub == subBit {
+			bits, ok := p.bits[code]
+			if !ok {
+				return nil, 0xC05B
+			}
+			if start+n > len(bits) {
+				return nil, 0xC056
+			}
+			if cmd == cmdBatchWrite {
+				vals, _ := unpackBits(data[6:], n)
+				copy(bits[start:], vals)
+				return nil, 0
+			}
+			return packBits(bits[start : start+n]), 0
+		}
+		words, ok := p.words[code]
+		if !ok {
+			return nil, 0xC05B
+		}
+		if start+n > len(words) {
+			return nil, 0xC056
+		}
+		if cmd == cmdBatchWrite {
+			vals, _ := decodeWords(data[6:], n)
+			copy(words[start:], vals)
+			return nil, 0
+		}
+		return encodeWords(words[start : start+n]), 0
+	case cmdRandomRead:
+		nw, nd := int(data[0]), int(data[1])
+		var out []byte
+		var dw []byte
+		for i := 0; i < nw+nd; i++ {
+			code, n := device(data[2+4*i:])
+			words := p.words[code]
+			if n+2 > len(words) {
+				return nil, 0xC056
+			}
+			if i < nw {
+				out = binary.LittleEndian.AppendUint16(out, words[n])
+			} else {
+				dw = append(dw, encodeWords(words[n:n+2])...)
+			}
+		}
+		return append(out, dw...), 0
+	case cmdRandomWrite:
+		if sub == subBit {
+			for i := 0; i < int(data[0]); i++ {
+				code, n := device(data[1+5*i:])
+				p.bits[code][n] = data[1+5*i+4] == 1
+			}
+			return nil, 0
+		}
+		nw, nd := int(data[0]), int(data[1])
+		off := 2
+		for i := 0; i < nw; i++ {
+			code, n := device(data[off:])
+			p.words[code][n] = binary.LittleEndian.Uint16(data[off+4:])
+			off += 6
+		}
+		for i := 0; i < nd; i++ {
+			code, n := device(data[off:])
+			v, _ := decodeWords(data[off+4:], 2)
+			copy(p.words[code][n:], v)
+			off += 8
+		}
+		return nil, 0
+	}
+	return nil, 0xC059
+}
+
+func TestReadBatch(t *testing.T) {
+	for _, frame := range []FrameType{Frame3E, Frame4E} {
+		plc := newSimPLC(t, frame)
+		d, m, x := p(t, "D0").Device.Code, p(t, "M0").Device.Code, p(t, "X0").Device.Code
+		plc.words[d][100] = 1234
+		plc.words[d][101] = 0x0009                            // D101.0 / D101.3
+		plc.words[d][200], plc.words[d][201] = 0x0000, 0x41C8 // 25.0，低字在前
+		plc.words[d][500] = 7
+		plc.words[p(t, "W0").Device.Code][0x1A] = 0xBEEF
+		plc.bits[m][10], plc.bits[m][12] = true, true
+		plc.bits[x][0x1F] = true
+		c := plc.client(t)
+		c.SetPointConfigs("plc", []protocols.PointConfig{
+			{PointID: "temp", Address: "D200", Format: "Float CD AB"},
+		})
+		addrs := []string{"D100", "D101.0", "D101.3", "D200", "D500", "W1A", "M10", "M11", "M12", "X1F", "D5000", "Q1"}
+		values, err := c.ReadBatch("plc", "", addrs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]protocols.PointValue)
+		for _, v := range values {
+			got[v.PointID] = v
+		}
+		want := map[string]interface{}{
+			"D100": uint16(1234), "D101.0": true, "D101.3": true, "D500": uint16(7), "W1A": uint16(0xBEEF),
+			"M10": true, "M11": false, "M12": true, "X1F": true,
+		}
+		for addr, v := range want {
+			if got[addr].Value != v || got[addr].Quality != "good" {
+				t.Errorf("%v %s: got %v (%s), want %v", frame, addr, got[addr].Value, got[addr].Quality, v)
+			}
+		}
+		if w, ok := got["D200"].Value.([]uint16); !ok || len(w) != 2 || w[0] != 0 || w[1] != 0x41C8 {
+			t.Errorf("%v D200: got %v", frame, got["D200"].Value)
+		}
+		for _, addr := range []string{"D5000", "Q1"} {
+			if got[addr].Quality != "bad" {
+				t.Errorf("%v %s: expect bad, got %v", frame, addr, got[addr])
+			}
+		}
+		// D100/D101 批量读，M10~M12、X1F 位批量读，D200/D500/W1A/D5000 随机读（出错后逐个重读）
+		if len(values) != len(addrs) {
+			t.Errorf("%v: got %d values", frame, len(values))
+		}
+		plan, _ := c.ReadPlan("plc", "", addrs[:10])
+		if len(plan.Requests) != 4 || plan.Requests[3].Function != "0403" || plan.Requests[3].Quantity != 3 {
+			t.Errorf("%v: unexpected plan %+v", frame, plan)
+		}
+	}
+}
+
+func p(t *testing.T, s string) Address {
+	a, err := ParseAddress(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestWrite(t *testing.T) {
+	plc := newSimPLC(t, Frame3E)
+	c := plc.client(t)
+	c.SetPointConfigs("plc", []protocols.PointConfig{
+		{PointID: "setpoint", Address: "D10", Format: "Float CD AB"},
+		{PointID: "total", Address: "ZR100", Format: "Long CD AB"},
+	})
+	for point, v := range map[string]interface{}{
+		"setpoint": 25.0,
+		"total":    -100000,
+		"D20":      -2,
+		"D21.4":    true,
+		"M5":       1,
+		"Y1A":      true,
+		"W0":       []uint16{1, 2, 3},
+	} {
+		if err := c.Write(point, v); err != nil {
+			t.Fatalf("write %s: %v", point, err)
+		}
+	}
+	d := p(t, "D0").Device.Code
+	if w := plc.words[d]; w[10] != 0 || w[11] != 0x41C8 || w[20] != 0xFFFE || w[21] != 0x10 {
+		t.Errorf("unexpected D words: %v", w[10:22])
+	}
+	if w := plc.words[p(t, "ZR0").Device.Code]; uint32(w[100])|uint32(w[101])<<16 != uint32(0xFFFE7960) {
+		t.Errorf("unexpected ZR100: %04X %04X", w[100], w[101])
+	}
+	if !plc.bits[p(t, "M0").Device.Code][5] || !plc.bits[p(t, "Y0").Device.Code][0x1A] {
+		t.Error("bit write failed")
+	}
+	if w := plc.words[p(t, "W0").Device.Code]; w[0] != 1 || w[2] != 3 {
+		t.Errorf("unexpected W words: %v", w[:3])
+	}
+
+	if err := c.WritePoints(map[string]interface{}{"D30": 5, "setpoint": 1.5, "M6": true, "L1": false}); err != nil {
+		t.Fatal(err)
+	}
+	if w := plc.words[d]; w[30] != 5 || w[11] != 0x3FC0 || !plc.bits[p(t, "M0").Device.Code][6] {
+		t.Errorf("random write failed: D30=%d D11=%04X", w[30], w[11])
+	}
+
+	err := c.Write("D5000", 1)
+	var e *EndCodeError
+	if !errors.As(err, &e) || e.Code != 0xC056 || e.Command != cmdBatchWrite {
+		t.Errorf("expect end code 0xC056, got %v", err)
+	}
+	for point, v := range map[string]interface{}{"M1": 2, "D1": 70000, "D2": "x", "Q0": 1, "D3.1": 5} {
+		if err := c.Write(point, v); err == nil {
+			t.Errorf("write %s = %v: expect error", point, v)
+		}
+	}
+}
+
+// TestLateResponse 3E 帧超时后迟到的应答不会被当作下一次请求的应答
+func TestLateResponse(t *testing.T) {
+	plc := newSimPLC(t, Frame3E)
+	d := p(t, "D0")
+	plc.words[d.Device.Code][0], plc.words[d.Device.Code][1] = 11, 22
+	c := plc.client(t)
+	plc.stall.Store(1)
+	if _, err := c.ReadWords(p(t, "D0"), 1); err == nil {
+		t.Fatal("expect timeout")
+	}
+	words, err := c.ReadWords(p(t, "D1"), 1)
+	if err != nil || words[0] != 22 {
+		t.Errorf("D1 after timeout = %v, %v", words, err)
+	}
+}
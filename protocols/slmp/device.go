@@ -0,0 +1,119 @@
+package slmp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Device 软元件类型
+type Device struct {
+	Name string
+	Code byte // 二进制报文中的软元件代码（Q/L 系列，子指令 0000/0001）
+	Bit  bool // 位软元件
+	Hex  bool // 软元件编号为十六进制（X/Y/B/W）
+}
+
+// devices 支持的软元件，按名称长度从长到短匹配（SM/SD/ZR 优先于 M/D/R）
+var devices = []Device{
+	{Name: "SM", Code: 0x91, Bit: true},
+	{Name: "SD", Code: 0xA9},
+	{Name: "ZR", Code: 0xB0},
+	{Name: "D", Code: 0xA8},
+	{Name: "W", Code: 0xB4, Hex: true},
+	{Name: "R", Code: 0xAF},
+	{Name: "M", Code: 0x90, Bit: true},
+	{Name: "X", Code: 0x9C, Bit: true, Hex: true},
+	{Name: "Y", Code: 0x9D, Bit: true, Hex: true},
+	{Name: "B", Code: 0xA0, Bit: true, Hex: true},
+	{Name: "L", Code: 0x92, Bit: true},
+}
+
+// LookupDevice 按名称查找软元件（不区分大小写）
+func LookupDevice(name string) (Device, bool) {
+	for _, d := range devices {
+		if strings.EqualFold(d.Name, name) {
+			return d, true
+		}
+	}
+	return Device{}, false
+}
+
+const maxDeviceNumber = 0xFFFFFF // 二进制报文中软元件编号占 3 字节
+
+// Address 解析后的软元件地址
+type Address struct {
+	Device Device
+	Number int
+	Bit    int // 字软元件内的位号 0~15，-1 表示整字
+}
+
+// HasBit 是否为字软元件内的位地址（如 D100.3）
+func (a Address) HasBit() bool {
+	return a.Bit >= 0
+}
+
+// IsBit 读写时是否按位处理
+func (a Address) IsBit() bool {
+	return a.Device.Bit || a.HasBit()
+}
+
+func (a Address) String() string {
+	num := strconv.Itoa(a.Number)
+	if a.Device.Hex {
+		num = strings.ToUpper(strconv.FormatInt(int64(a.Number), 16))
+	}
+	if a.HasBit() {
+		return fmt.Sprintf("%s%s.%X", a.Device.Name, num, a.Bit)
+	}
+	return a.Device.Name + num
+}
+
+// ParseAddress 解析软元件地址（不区分大小写）：
+//   - 位软元件 SM/M/L 为十进制，X/Y/B 为十六进制：M100、X1F、Y20、B0A、SM400
+//   - 字软元件 D/R/ZR/SD 为十进制，W 为十六进制：D100、R2000、ZR10000、SD210、W1A0
+//   - 编号可用 0x 前缀强制按十六进制解析（D0x64 即 D100）
+//   - 字软元件内的位：D100.3、W10.F（位号 0~F）
+func ParseAddress(address string) (Address, error) {
+	addr := Address{Bit: -1}
+	s := strings.ToUpper(strings.TrimSpace(address))
+	i := 0
+	for i < len(s) && s[i] >= 'A' && s[i] <= 'Z' {
+		i++
+	}
+	// X/Y/B/W 的十六进制编号可能以字母开头（如 XA），回退到已知的软元件名
+	name, num := s[:i], s[i:]
+	dev, ok := LookupDevice(name)
+	for !ok && len(name) > 1 {
+		name, num = name[:len(name)-1], s[len(name)-1:]
+		dev, ok = LookupDevice(name)
+	}
+	if !ok {
+		return addr, fmt.Errorf("unknown slmp device in address %s", address)
+	}
+	addr.Device = dev
+	if j := strings.Index(num, "."); j >= 0 {
+		if dev.Bit {
+			return addr, fmt.Errorf("bit index not allowed for bit device %s", address)
+		}
+		bit, err := strconv.ParseUint(num[j+1:], 16, 8)
+		if err != nil || bit > 15 {
+			return addr, fmt.Errorf("invalid bit index in address %s", address)
+		}
+		addr.Bit = int(bit)
+		num = num[:j]
+	}
+	base := 10
+	if dev.Hex {
+		base = 16
+	}
+	if strings.HasPrefix(num, "0X") {
+		base, num = 16, num[2:]
+	}
+	n, err := strconv.ParseUint(num, base, 32)
+	if err != nil || num == "" || n > maxDeviceNumber {
+		return addr, fmt.Errorf("invalid device number in address %s", address)
+	}
+	addr.Number = int(n)
+	return addr, nil
+}
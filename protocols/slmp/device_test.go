@@ -0,0 +1,47 @@
+package slmp
+
+import "testing"
+
+func TestParseAddress(t *testing.T) {
+	cases := []struct {
+		in     string
+		device string
+		number int
+		bit    int
+	}{
+		{"D100", "D", 100, -1},
+		{"d0", "D", 0, -1},
+		{"D0x64", "D", 100, -1},
+		{"W1A0", "W", 0x1A0, -1},
+		{"R2000", "R", 2000, -1},
+		{"ZR10000", "ZR", 10000, -1},
+		{"M100", "M", 100, -1},
+		{"X1F", "X", 0x1F, -1},
+		{"XA", "X", 0xA, -1},
+		{"Y20", "Y", 0x20, -1},
+		{"BFF", "B", 0xFF, -1},
+		{"L5", "L", 5, -1},
+		{"SM400", "SM", 400, -1},
+		{"SD210", "SD", 210, -1},
+		{"D100.3", "D", 100, 3},
+		{"W10.F", "W", 0x10, 15},
+	}
+	for _, c := range cases {
+		addr, err := ParseAddress(c.in)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", c.in, err)
+			continue
+		}
+		if addr.Device.Name != c.device || addr.Number != c.number || addr.Bit != c.bit {
+			t.Errorf("%s: got %+v", c.in, addr)
+		}
+	}
+}
+
+func TestParseAddressInvalid(t *testing.T) {
+	for _, in := range []string{"", "D", "Q100", "D1A", "M100.1", "D100.G", "X1G", "D16777216", "100"} {
+		if _, err := ParseAddress(in); err == nil {
+			t.Errorf("%s: expect error", in)
+		}
+	}
+}
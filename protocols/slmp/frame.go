@@ -0,0 +1,209 @@
+package slmp
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// 指令和子指令（二进制码）
+const (
+	cmdBatchRead   = 0x0401
+	cmdBatchWrite  = 0x1401
+	cmdRandomRead  = 0x0403
+	cmdRandomWrite = 0x1402
+
+	subWord = 0x0000 // 字单位
+	subBit  = 0x0001 // 位单位
+)
+
+// FrameType 报文格式
+type FrameType int
+
+const (
+	Frame3E FrameType = 3
+	Frame4E FrameType = 4 // 带序列号，可区分同一连接上的多个应答
+)
+
+// route 请求目标：网络号、站号（PC 号）、请求目标模块 I/O 号和多点站号
+type route struct {
+	network   byte
+	pc        byte
+	moduleIO  uint16
+	station   byte
+	monitorTm uint16 // 监视定时器，单位 250ms
+}
+
+func defaultRoute() route {
+	return route{pc: 0xFF, moduleIO: 0x03FF, monitorTm: 4}
+}
+
+// encodeRequest 组装 3E/4E 二进制请求帧，4E 帧带序列号
+func encodeRequest(frame FrameType, serial uint16, r route, command, subcommand uint16, data []byte) []byte {
+	var buf []byte
+	if frame == Frame4E {
+		buf = []byte{0x54, 0x00, byte(serial), byte(serial >> 8), 0x00, 0x00}
+	} else {
+		buf = []byte{0x50, 0x00}
+	}
+	buf = append(buf, r.network, r.pc, byte(r.moduleIO), byte(r.moduleIO>>8), r.station)
+	// 请求数据长度：监视定时器之后的字节数
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(6+len(data)))
+	buf = binary.LittleEndian.AppendUint16(buf, r.monitorTm)
+	buf = binary.LittleEndian.AppendUint16(buf, command)
+	buf = binary.LittleEndian.AppendUint16(buf, subcommand)
+	return append(buf, data...)
+}
+
+// responseHeaderLen 应答帧中数据长度字段之前（含该字段）的字节数
+func responseHeaderLen(frame FrameType) int {
+	if frame == Frame4E {
+		return 13
+	}
+	return 9
+}
+
+// parseResponseHeader 校验应答头，返回序列号（3E 为 0）和之后的数据长度（含结束代码）
+func parseResponseHeader(frame FrameType, header []byte) (uint16, int, error) {
+	var serial uint16
+	if frame == Frame4E {
+		if header[0] != 0xD4 || header[1] != 0x00 {
+			return 0, 0, fmt.Errorf("slmp: invalid 4E response subheader % X", header[:2])
+		}
+		serial = binary.LittleEndian.Uint16(header[2:])
+	} else if header[0] != 0xD0 || header[1] != 0x00 {
+		return 0, 0, fmt.Errorf("slmp: invalid 3E response subheader % X", header[:2])
+	}
+	n := int(binary.LittleEndian.Uint16(header[len(header)-2:]))
+	if n < 2 {
+		return 0, 0, fmt.Errorf("slmp: invalid response length %d", n)
+	}
+	return serial, n, nil
+}
+
+// parseResponseBody 检查结束代码，返回应答数据；非 0 结束代码返回 *EndCodeError
+func parseResponseBody(body []byte) ([]byte, error) {
+	code := binary.LittleEndian.Uint16(body)
+	if code != 0 {
+		e := &EndCodeError{Code: code}
+		// 异常应答在结束代码后带出错信息：网络号、站号、模块 I/O、多点站号、指令、子指令
+		if len(body) >= 11 {
+			e.Command = binary.LittleEndian.Uint16(body[7:])
+			e.Subcommand = binary.LittleEndian.Uint16(body[9:])
+		}
+		return nil, e
+	}
+	return body[2:], nil
+}
+
+// EndCodeError 应答中的非 0 结束代码
+type EndCodeError struct {
+	Code       uint16
+	Command    uint16
+	Subcommand uint16
+}
+
+// endCodeTexts 常见结束代码的含义
+var endCodeTexts = map[uint16]string{
+	0xC050: "ASCII data cannot be converted to binary",
+	0xC051: "too many points for bit read/write",
+	0xC052: "too many points for word read/write",
+	0xC053: "too many points for random bit write",
+	0xC054: "too many points for random word read/write",
+	0xC056: "device address out of range",
+	0xC058: "request data length mismatch",
+	0xC059: "command or subcommand not supported",
+	0xC05B: "device cannot be accessed",
+	0xC05C: "request content error",
+	0xC05F: "request not executable on target",
+	0xC060: "request content error for bit device",
+	0xC061: "request data length error",
+	0xC06F: "communication data code mismatch",
+	0xC0D8: "too many blocks",
+	0x4030: "device does not exist",
+	0x4031: "device number out of range",
+	0x4080: "request data error",
+}
+
+func (e *EndCodeError) Error() string {
+	text, ok := endCodeTexts[e.Code]
+	if !ok {
+		text = "unknown error"
+	}
+	if e.Command != 0 {
+		return fmt.Sprintf("slmp: end code 0x%04X (%s) for command %04X/%04X", e.Code, text, e.Command, e.Subcommand)
+	}
+	return fmt.Sprintf("slmp: end code 0x%04X (%s)", e.Code, text)
+}
+
+// IsDeviceError 结束代码是否表示请求的软元件本身有问题（编号越界、不可访问等），
+// 此类错误只影响本次请求的点位，连接仍可用
+func (e *EndCodeError) IsDeviceError() bool {
+	switch e.Code {
+	case 0xC056, 0xC05B, 0xC05C, 0xC060, 0x4030, 0x4031:
+		return true
+	}
+	return false
+}
+
+// appendDevice 软元件编号（3 字节小端）和软元件代码
+func appendDevice(buf []byte, addr Address) []byte {
+	return append(buf, byte(addr.Number), byte(addr.Number>>8), byte(addr.Number>>16), addr.Device.Code)
+}
+
+// batchData 批量读写的请求数据：起始软元件和点数
+func batchData(addr Address, count int) []byte {
+	buf := appendDevice(nil, addr)
+	return binary.LittleEndian.AppendUint16(buf, uint16(count))
+}
+
+// packBits 位单位批量写：每点 4 位，前一点在高 4 位
+func packBits(values []bool) []byte {
+	buf := make([]byte, (len(values)+1)/2)
+	for i, v := range values {
+		if !v {
+			continue
+		}
+		if i%2 == 0 {
+			buf[i/2] |= 0x10
+		} else {
+			buf[i/2] |= 0x01
+		}
+	}
+	return buf
+}
+
+// unpackBits packBits 的逆操作
+func unpackBits(data []byte, count int) ([]bool, error) {
+	if len(data) < (count+1)/2 {
+		return nil, fmt.Errorf("slmp: short bit response: %d bytes for %d points", len(data), count)
+	}
+	values := make([]bool, count)
+	for i := range values {
+		if i%2 == 0 {
+			values[i] = data[i/2]&0x10 != 0
+		} else {
+			values[i] = data[i/2]&0x01 != 0
+		}
+	}
+	return values, nil
+}
+
+// decodeWords 字数据为小端
+func decodeWords(data []byte, count int) ([]uint16, error) {
+	if len(data) < 2*count {
+		return nil, fmt.Errorf("slmp: short word response: %d bytes for %d words", len(data), count)
+	}
+	words := make([]uint16, count)
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	return words, nil
+}
+
+func encodeWords(words []uint16) []byte {
+	buf := make([]byte, 0, 2*len(words))
+	for _, w := range words {
+		buf = binary.LittleEndian.AppendUint16(buf, w)
+	}
+	return buf
+}
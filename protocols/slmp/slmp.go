@@ -1,85 +1,300 @@
 package slmp
 
 import (
+	"encoding/binary"
+	"fmt"
+	"io"
 	"net"
-	"sensor-edge/protocols"
+	"strings"
+	"sync"
 	"time"
+
+	"sensor-edge/protocols"
+)
+
+// 单次请求的点数上限（Q/L 系列二进制码）
+const (
+	maxBatchWords      = 960
+	maxBatchBits       = 7168
+	maxRandomRead      = 192 // 字访问点数 + 双字访问点数
+	maxRandomWriteSize = 960 // 字访问每点 12、双字访问每点 14
+	maxRandomBits      = 188
 )
 
+// SLMPClient 三菱 SLMP（MC 协议）二进制 3E/4E 帧客户端
 type SLMPClient struct {
-	conn net.Conn
-	ip   string
-	port string
+	ip      string
+	port    string
+	frame   FrameType
+	route   route
+	timeout time.Duration
+	maxGap  int
+
+	mu     sync.Mutex // 串行化请求
+	conn   net.Conn
+	serial uint16
+
+	pmu    sync.RWMutex
+	points map[string]pointTable // 设备 ID -> 点位配置
 }
 
+// pointTable 按地址和点位名索引的点位配置，读写时据此查找 Format
+type pointTable map[string]protocols.PointConfig
+
+// Init 连接参数：
+//
+//	ip: 192.168.3.39
+//	port: 5000
+//	frame: 3E            # 3E(默认) | 4E
+//	network_no: 0        # 网络号
+//	pc_no: 255           # 站号（PC 号）
+//	module_io: 1023      # 请求目标模块 I/O 号，默认 0x03FF（CPU）
+//	station_no: 0        # 多点站号
+//	monitor_timer: 4     # 监视定时器（单位 250ms）
+//	timeout: 2000        # 单次请求超时时间(毫秒)
+//	max_gap: 0           # 同一软元件两个点位之间允许一并读取的空洞数量（字/位）
 func (s *SLMPClient) Init(config map[string]interface{}) error {
-	s.ip = config["ip"].(string)
-	s.port = config["port"].(string)
+	s.ip, _ = config["ip"].(string)
+	switch v := config["port"].(type) {
+	case string:
+		s.port = v
+	case int:
+		s.port = fmt.Sprint(v)
+	case float64:
+		s.port = fmt.Sprint(int(v))
+	}
+	if s.ip == "" || s.port == "" {
+		return fmt.Errorf("slmp: ip and port are required")
+	}
+	s.frame = Frame3E
+	if f, ok := config["frame"]; ok {
+		switch strings.ToUpper(fmt.Sprint(f)) {
+		case "3E":
+		case "4E":
+			s.frame = Frame4E
+		default:
+			return fmt.Errorf("slmp: unsupported frame %v", f)
+		}
+	}
+	s.route = defaultRoute()
+	if v, ok := toInt(config["network_no"]); ok {
+		s.route.network = byte(v)
+	}
+	if v, ok := toInt(config["pc_no"]); ok {
+		s.route.pc = byte(v)
+	}
+	if v, ok := toInt(config["module_io"]); ok {
+		s.route.moduleIO = uint16(v)
+	}
+	if v, ok := toInt(config["station_no"]); ok {
+		s.route.station = byte(v)
+	}
+	if v, ok := toInt(config["monitor_timer"]); ok {
+		s.route.monitorTm = uint16(v)
+	}
+	s.timeout = 3 * time.Second
+	if v, ok := toInt(config["timeout"]); ok && v > 0 {
+		s.timeout = time.Duration(v) * time.Millisecond
+	}
+	if v, ok := toInt(config["max_gap"]); ok {
+		if v < 0 {
+			return fmt.Errorf("slmp: invalid max_gap: %d", v)
+		}
+		s.maxGap = v
+	}
+	return s.Reconnect()
+}
 
-	var err error
-	s.conn, err = net.DialTimeout("tcp", s.ip+":"+s.port, 3*time.Second)
-	return err
+// SetPointConfigs 记录设备的点位配置（多字点位的 Format）
+func (s *SLMPClient) SetPointConfigs(deviceID string, points []protocols.PointConfig) {
+	table := make(pointTable, len(points)*2)
+	for _, p := range points {
+		if p.Address != "" {
+			table[p.Address] = p
+		}
+		if p.PointID != "" {
+			table[p.PointID] = p
+		}
+	}
+	s.pmu.Lock()
+	defer s.pmu.Unlock()
+	if s.points == nil {
+		s.points = make(map[string]pointTable)
+	}
+	s.points[deviceID] = table
+}
+
+// lookup 在各设备的点位配置中查找点位（写入不区分设备），返回地址和格式；
+// 未配置的点位按原样作为地址、无格式处理
+func (s *SLMPClient) lookup(point string) (string, string) {
+	s.pmu.RLock()
+	defer s.pmu.RUnlock()
+	for _, table := range s.points {
+		if p, ok := table[point]; ok {
+			return p.Address, p.Format
+		}
+	}
+	return point, ""
 }
 
+// Read 读取设备配置的全部点位，PointID 为点位名
 func (s *SLMPClient) Read(deviceID string) ([]protocols.PointValue, error) {
-	// 示例：读 D100（需根据三菱 SLMP 指令组装报文）
-	readCommand := []byte{
-		0x50, 0x00, 0x00, 0xFF, 0xFF, 0x03, 0x00, // 子头
-		0x0C, 0x00, 0x10, 0x00, // 请求长度/监视定时器
-		0x01, 0x04, 0x00, 0x00, // 读取命令（批量读取字）
-		0x00, 0x64, 0x00, 0x00, 0xA8, 0x00, // 读取 D100 开始 1 点
-	}
-
-	s.conn.Write(readCommand)
-	buf := make([]byte, 1024)
-	n, err := s.conn.Read(buf)
-	if err != nil || n < 14 {
+	s.pmu.RLock()
+	var configs []protocols.PointConfig
+	for key, p := range s.points[deviceID] {
+		if key == p.PointID {
+			configs = append(configs, p)
+		}
+	}
+	s.pmu.RUnlock()
+	points, bad := parsePoints(configs)
+	values, err := s.readPoints(points)
+	if err != nil {
 		return nil, err
 	}
-
-	val := int(buf[14]) + int(buf[15])<<8
-	return []protocols.PointValue{
-		{
-			PointID:   "d100",
-			Value:     val,
-			Quality:   "good",
-			Timestamp: time.Now().Unix(),
-		},
-	}, nil
+	for _, name := range bad {
+		values = append(values, badValue(name))
+	}
+	return values, nil
 }
 
-// ReadBatch 批量读取接口，返回指定数据点
+// ReadBatch 按地址批量读取，返回值按地址回填：同一软元件的相邻点位合并为批量读，
+// 零散的字点位合并为随机读；地址无效或 PLC 返回软元件错误的点位为 bad。
+// 位软元件返回 bool，字点位返回 uint16，多字格式（Float/Long/Double）返回 []uint16，
+// 由采集主流程按 Format 解码。function 参数不影响读取
 func (s *SLMPClient) ReadBatch(deviceID string, function string, points []string) ([]protocols.PointValue, error) {
-	// function 参数暂未用到，保留兼容
 	if len(points) == 0 {
 		return nil, nil
 	}
-	var values []protocols.PointValue
-	for _, pt := range points {
-		readCommand := []byte{
-			0x50, 0x00, 0x00, 0xFF, 0xFF, 0x03, 0x00,
-			0x0C, 0x00, 0x10, 0x00,
-			0x01, 0x04, 0x00, 0x00,
-			0x00, 0x64, 0x00, 0x00, 0xA8, 0x00,
-		}
-		s.conn.Write(readCommand)
-		buf := make([]byte, 1024)
-		n, err := s.conn.Read(buf)
-		if err != nil || n < 14 {
-			values = append(values, protocols.PointValue{PointID: pt, Quality: "bad", Value: nil, Timestamp: time.Now().Unix()})
-			continue
-		}
-		val := int(buf[14]) + int(buf[15])<<8
-		values = append(values, protocols.PointValue{PointID: pt, Quality: "good", Value: val, Timestamp: time.Now().Unix()})
+	parsed, bad := parsePoints(s.batchConfigs(deviceID, points))
+	values, err := s.readPoints(parsed)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range bad {
+		values = append(values, badValue(name))
 	}
 	return values, nil
 }
 
+// ReadPlan 返回 ReadBatch 对这些点位将发出的请求：批量读的 Start/Quantity 为起始编号和点数，
+// 随机读的 Quantity 为字和双字访问点数之和
+func (s *SLMPClient) ReadPlan(deviceID string, function string, points []string) (protocols.ReadPlan, error) {
+	parsed, bad := parsePoints(s.batchConfigs(deviceID, points))
+	plan := protocols.ReadPlan{Skipped: bad}
+	for _, req := range planReads(parsed, s.maxGap) {
+		r := protocols.ReadRequest{Function: fmt.Sprintf("%04X", req.command()), Start: req.start.Number, Quantity: req.count}
+		if req.random {
+			r.Start = 0
+		}
+		for _, p := range req.points {
+			r.Points = append(r.Points, p.name)
+		}
+		plan.Requests = append(plan.Requests, r)
+	}
+	return plan, nil
+}
+
+// batchConfigs 以地址作为 PointID 构造点位配置，格式取自设备的点位配置
+func (s *SLMPClient) batchConfigs(deviceID string, points []string) []protocols.PointConfig {
+	s.pmu.RLock()
+	table := s.points[deviceID]
+	s.pmu.RUnlock()
+	configs := make([]protocols.PointConfig, len(points))
+	for i, addr := range points {
+		configs[i] = protocols.PointConfig{PointID: addr, Address: addr, Format: table[addr].Format}
+	}
+	return configs
+}
+
+// Write 写入单个点位，point 可为地址或点位名：
+//   - 位软元件：bool 或 0/1，按位单位批量写
+//   - 字软元件：按点位 Format 编码（未配置时为单字 -32768~65535），[]uint16 原样写入连续字
+//   - 字内位（D100.3）：读出该字修改对应位后写回（非原子操作）
 func (s *SLMPClient) Write(point string, value interface{}) error {
-	return nil // TODO: 实现写操作
+	addr, format := s.lookup(point)
+	a, err := ParseAddress(addr)
+	if err != nil {
+		return err
+	}
+	switch {
+	case a.Device.Bit:
+		on, err := toBool(value)
+		if err != nil {
+			return err
+		}
+		return s.WriteBits(a, []bool{on})
+	case a.HasBit():
+		on, err := toBool(value)
+		if err != nil {
+			return err
+		}
+		words, err := s.ReadWords(a, 1)
+		if err != nil {
+			return fmt.Errorf("read word before bit write failed: %v", err)
+		}
+		if on {
+			words[0] |= 1 << uint(a.Bit)
+		} else {
+			words[0] &^= 1 << uint(a.Bit)
+		}
+		return s.WriteWords(a, words)
+	}
+	words, err := encodeWordValue(format, value)
+	if err != nil {
+		return fmt.Errorf("encode value for point %s failed: %v", point, err)
+	}
+	return s.WriteWords(a, words)
 }
+
+// WritePoints 一次写入多个零散点位（键为地址或点位名），字点位用随机写（字/双字访问），
+// 位软元件用位单位随机写；字内位地址不支持随机写
+func (s *SLMPClient) WritePoints(values map[string]interface{}) error {
+	var words []WordValue
+	var bits []BitValue
+	for point, value := range values {
+		addr, format := s.lookup(point)
+		a, err := ParseAddress(addr)
+		if err != nil {
+			return err
+		}
+		if a.HasBit() {
+			return fmt.Errorf("slmp: random write does not support word bit address %s", point)
+		}
+		if a.Device.Bit {
+			on, err := toBool(value)
+			if err != nil {
+				return fmt.Errorf("point %s: %v", point, err)
+			}
+			bits = append(bits, BitValue{Addr: a, Value: on})
+			continue
+		}
+		w, err := encodeWordValue(format, value)
+		if err != nil {
+			return fmt.Errorf("encode value for point %s failed: %v", point, err)
+		}
+		words = append(words, WordValue{Addr: a, Words: w})
+	}
+	if len(words) > 0 {
+		if err := s.WriteRandomWords(words); err != nil {
+			return err
+		}
+	}
+	if len(bits) > 0 {
+		return s.WriteRandomBits(bits)
+	}
+	return nil
+}
+
 func (s *SLMPClient) Close() error {
-	return s.conn.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
 }
 
 func NewSLMPClient() protocols.Protocol {
@@ -90,13 +305,227 @@ func init() {
 	protocols.Register("slmp", NewSLMPClient)
 }
 
-
 func (s *SLMPClient) Reconnect() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if s.conn != nil {
 		s.conn.Close() // 先关闭旧连接
 	}
+	return s.dial()
+}
+
+// dial 建立连接，调用方持有 mu
+func (s *SLMPClient) dial() error {
+	timeout := s.timeout
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
 	var err error
-	s.conn, err = net.DialTimeout("tcp", s.ip+":"+s.port, 3*time.Second)
+	s.conn, err = net.DialTimeout("tcp", s.ip+":"+s.port, timeout)
+	return err
+}
+
+// drop 收发出错或超时后关闭连接：3E 帧没有序列号，留着连接会把迟到的应答当作下一次请求的应答，
+// 下一次请求重新连接
+func (s *SLMPClient) drop(err error) ([]byte, error) {
+	s.conn.Close()
+	s.conn = nil
+	return nil, err
+}
+
+// request 发送一帧请求并等待应答，返回结束代码之后的数据。未连接时先建立连接
+func (s *SLMPClient) request(command, subcommand uint16, data []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		if err := s.dial(); err != nil {
+			return nil, fmt.Errorf("slmp: not connected: %v", err)
+		}
+	}
+	s.serial++
+	frame := encodeRequest(s.frame, s.serial, s.route, command, subcommand, data)
+	s.conn.SetDeadline(time.Now().Add(s.timeout))
+	if _, err := s.conn.Write(frame); err != nil {
+		return s.drop(err)
+	}
+	for {
+		header := make([]byte, responseHeaderLen(s.frame))
+		if _, err := io.ReadFull(s.conn, header); err != nil {
+			return s.drop(err)
+		}
+		serial, n, err := parseResponseHeader(s.frame, header)
+		if err != nil {
+			return s.drop(err)
+		}
+		body := make([]byte, n)
+		if _, err := io.ReadFull(s.conn, body); err != nil {
+			return s.drop(err)
+		}
+		// 4E 帧丢弃序列号不符的应答（如上一次超时请求的迟到应答）
+		if s.frame == Frame4E && serial != s.serial {
+			continue
+		}
+		return parseResponseBody(body)
+	}
+}
+
+// ReadWords 字单位批量读取 count 个字（位软元件每字 16 点）
+func (s *SLMPClient) ReadWords(addr Address, count int) ([]uint16, error) {
+	if count < 1 || count > maxBatchWords {
+		return nil, fmt.Errorf("slmp: invalid word count %d", count)
+	}
+	data, err := s.request(cmdBatchRead, subWord, batchData(addr, count))
+	if err != nil {
+		return nil, err
+	}
+	return decodeWords(data, count)
+}
+
+// ReadBits 位单位批量读取位软元件
+func (s *SLMPClient) ReadBits(addr Address, count int) ([]bool, error) {
+	if !addr.Device.Bit {
+		return nil, fmt.Errorf("slmp: %s is not a bit device", addr.Device.Name)
+	}
+	if count < 1 || count > maxBatchBits {
+		return nil, fmt.Errorf("slmp: invalid bit count %d", count)
+	}
+	data, err := s.request(cmdBatchRead, subBit, batchData(addr, count))
+	if err != nil {
+		return nil, err
+	}
+	return unpackBits(data, count)
+}
+
+// WriteWords 字单位批量写入连续字
+func (s *SLMPClient) WriteWords(addr Address, words []uint16) error {
+	if len(words) < 1 || len(words) > maxBatchWords {
+		return fmt.Errorf("slmp: invalid word count %d", len(words))
+	}
+	_, err := s.request(cmdBatchWrite, subWord, append(batchData(addr, len(words)), encodeWords(words)...))
 	return err
-	 
-}
\ No newline at end of file
+}
+
+// WriteBits 位单位批量写入连续位
+func (s *SLMPClient) WriteBits(addr Address, values []bool) error {
+	if !addr.Device.Bit {
+		return fmt.Errorf("slmp: %s is not a bit device", addr.Device.Name)
+	}
+	if len(values) < 1 || len(values) > maxBatchBits {
+		return fmt.Errorf("slmp: invalid bit count %d", len(values))
+	}
+	_, err := s.request(cmdBatchWrite, subBit, append(batchData(addr, len(values)), packBits(values)...))
+	return err
+}
+
+// ReadRandom 随机读取：words 按字访问，dwords 按双字访问（低字在前），两者合计不超过 192 点
+func (s *SLMPClient) ReadRandom(words, dwords []Address) ([]uint16, []uint32, error) {
+	if len(words)+len(dwords) < 1 || len(words)+len(dwords) > maxRandomRead {
+		return nil, nil, fmt.Errorf("slmp: invalid random read count %d", len(words)+len(dwords))
+	}
+	data := []byte{byte(len(words)), byte(len(dwords))}
+	for _, a := range words {
+		data = appendDevice(data, a)
+	}
+	for _, a := range dwords {
+		data = appendDevice(data, a)
+	}
+	resp, err := s.request(cmdRandomRead, subWord, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(resp) < 2*len(words)+4*len(dwords) {
+		return nil, nil, fmt.Errorf("slmp: short random read response: %d bytes", len(resp))
+	}
+	w, _ := decodeWords(resp, len(words))
+	d := make([]uint32, len(dwords))
+	for i := range d {
+		d[i] = binary.LittleEndian.Uint32(resp[2*len(words)+4*i:])
+	}
+	return w, d, nil
+}
+
+// WordValue 随机写的字点位：1 个字按字访问，2 个字按双字访问，更多的字拆分为多个双字
+type WordValue struct {
+	Addr  Address
+	Words []uint16
+}
+
+// BitValue 随机写的位点位
+type BitValue struct {
+	Addr  Address
+	Value bool
+}
+
+// WriteRandomWords 字单位随机写入，超出单次请求上限时分多次发送
+func (s *SLMPClient) WriteRandomWords(values []WordValue) error {
+	var words, dwords []byte
+	nw, nd, size := 0, 0, 0
+	flush := func() error {
+		if nw+nd == 0 {
+			return nil
+		}
+		data := append([]byte{byte(nw), byte(nd)}, words...)
+		_, err := s.request(cmdRandomWrite, subWord, append(data, dwords...))
+		words, dwords, nw, nd, size = nil, nil, 0, 0, 0
+		return err
+	}
+	for _, v := range values {
+		if len(v.Words) == 0 {
+			return fmt.Errorf("slmp: no data for %s", v.Addr)
+		}
+		if len(v.Words) == 1 {
+			if size+12 > maxRandomWriteSize || nw == 0xFF {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			words = appendDevice(words, v.Addr)
+			words = binary.LittleEndian.AppendUint16(words, v.Words[0])
+			nw, size = nw+1, size+12
+			continue
+		}
+		if len(v.Words)%2 != 0 {
+			return fmt.Errorf("slmp: odd word count %d for %s", len(v.Words), v.Addr)
+		}
+		for i := 0; i < len(v.Words); i += 2 {
+			if size+14 > maxRandomWriteSize || nd == 0xFF {
+				if err := flush(); err != nil {
+					return err
+				}
+			}
+			a := v.Addr
+			a.Number += i
+			dwords = appendDevice(dwords, a)
+			dwords = binary.LittleEndian.AppendUint16(dwords, v.Words[i])
+			dwords = binary.LittleEndian.AppendUint16(dwords, v.Words[i+1])
+			nd, size = nd+1, size+14
+		}
+	}
+	return flush()
+}
+
+// WriteRandomBits 位单位随机写入，超出单次请求上限时分多次发送
+func (s *SLMPClient) WriteRandomBits(values []BitValue) error {
+	for start := 0; start < len(values); start += maxRandomBits {
+		end := start + maxRandomBits
+		if end > len(values) {
+			end = len(values)
+		}
+		data := []byte{byte(end - start)}
+		for _, v := range values[start:end] {
+			if !v.Addr.Device.Bit {
+				return fmt.Errorf("slmp: %s is not a bit device", v.Addr.Device.Name)
+			}
+			data = appendDevice(data, v.Addr)
+			if v.Value {
+				data = append(data, 0x01)
+			} else {
+				data = append(data, 0x00)
+			}
+		}
+		if _, err := s.request(cmdRandomWrite, subBit, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+package snmp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+const (
+	defaultPort           = 161
+	defaultMaxOids        = 60 // 单个 GET 请求的 OID 数量上限
+	defaultMaxRepetitions = 10 // GETBULK 每个 OID 返回的行数
+)
+
+var authProtocols = map[string]gosnmp.SnmpV3AuthProtocol{
+	"MD5":    gosnmp.MD5,
+	"SHA":    gosnmp.SHA,
+	"SHA224": gosnmp.SHA224,
+	"SHA256": gosnmp.SHA256,
+	"SHA384": gosnmp.SHA384,
+	"SHA512": gosnmp.SHA512,
+}
+
+var privProtocols = map[string]gosnmp.SnmpV3PrivProtocol{
+	"DES":     gosnmp.DES,
+	"AES":     gosnmp.AES,
+	"AES192":  gosnmp.AES192,
+	"AES256":  gosnmp.AES256,
+	"AES192C": gosnmp.AES192C, // Cisco 扩展的密钥生成方式
+	"AES256C": gosnmp.AES256C,
+}
+
+// newGoSNMP 按设备配置构造 gosnmp 客户端（未连接）
+func newGoSNMP(config map[string]interface{}) (*gosnmp.GoSNMP, error) {
+	ip, _ := config["ip"].(string)
+	if ip == "" {
+		return nil, fmt.Errorf("snmp: ip is required")
+	}
+	port := defaultPort
+	if v, ok := toInt(config["port"]); ok {
+		port = v
+	}
+	if port <= 0 || port > 65535 {
+		return nil, fmt.Errorf("snmp: invalid port %v", config["port"])
+	}
+	g := &gosnmp.GoSNMP{
+		Target:         ip,
+		Port:           uint16(port),
+		Version:        gosnmp.Version2c,
+		Community:      "public",
+		Timeout:        2 * time.Second,
+		Retries:        2,
+		MaxOids:        defaultMaxOids,
+		MaxRepetitions: defaultMaxRepetitions,
+	}
+	if v, ok := config["community"].(string); ok && v != "" {
+		g.Community = v
+	}
+	if v, ok := toInt(config["timeout"]); ok && v > 0 {
+		g.Timeout = time.Duration(v) * time.Millisecond
+	}
+	if v, ok := toInt(config["retries"]); ok && v >= 0 {
+		g.Retries = v
+	}
+	if v, ok := toInt(config["max_oids"]); ok {
+		if v <= 0 {
+			return nil, fmt.Errorf("snmp: invalid max_oids: %d", v)
+		}
+		g.MaxOids = v
+	}
+	if v, ok := toInt(config["max_repetitions"]); ok {
+		if v <= 0 {
+			return nil, fmt.Errorf("snmp: invalid max_repetitions: %d", v)
+		}
+		g.MaxRepetitions = uint32(v)
+	}
+	version := "2c"
+	if v, ok := config["version"]; ok {
+		version = strings.TrimPrefix(strings.ToLower(fmt.Sprint(v)), "v")
+	}
+	switch version {
+	case "1":
+		g.Version = gosnmp.Version1
+	case "2c", "2":
+	case "3":
+		if err := setUSM(g, config); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("snmp: unsupported version %v", config["version"])
+	}
+	return g, nil
+}
+
+// setUSM 配置 SNMPv3 用户安全模型：security_level 决定是否认证、加密，
+// 对应级别需要的协议和口令必须配置（口令至少 8 个字符）
+func setUSM(g *gosnmp.GoSNMP, config map[string]interface{}) error {
+	usm := &gosnmp.UsmSecurityParameters{}
+	usm.UserName, _ = config["security_name"].(string)
+	if usm.UserName == "" {
+		return fmt.Errorf("snmp: security_name is required for v3")
+	}
+	level, _ := config["security_level"].(string)
+	switch strings.ToLower(level) {
+	case "noauthnopriv":
+		g.MsgFlags = gosnmp.NoAuthNoPriv
+	case "authnopriv":
+		g.MsgFlags = gosnmp.AuthNoPriv
+	case "authpriv", "":
+		g.MsgFlags = gosnmp.AuthPriv
+	default:
+		return fmt.Errorf("snmp: unsupported security_level %s", level)
+	}
+	if g.MsgFlags&gosnmp.AuthNoPriv != 0 {
+		name, _ := config["auth_protocol"].(string)
+		proto, ok := authProtocols[strings.ToUpper(name)]
+		if !ok {
+			return fmt.Errorf("snmp: unsupported auth_protocol %q", name)
+		}
+		usm.AuthenticationProtocol = proto
+		usm.AuthenticationPassphrase, _ = config["auth_passphrase"].(string)
+		if len(usm.AuthenticationPassphrase) < 8 {
+			return fmt.Errorf("snmp: auth_passphrase must be at least 8 characters")
+		}
+	}
+	if g.MsgFlags&gosnmp.AuthPriv == gosnmp.AuthPriv {
+		name, _ := config["priv_protocol"].(string)
+		proto, ok := privProtocols[strings.ToUpper(name)]
+		if !ok {
+			return fmt.Errorf("snmp: unsupported priv_protocol %q", name)
+		}
+		usm.PrivacyProtocol = proto
+		usm.PrivacyPassphrase, _ = config["priv_passphrase"].(string)
+		if len(usm.PrivacyPassphrase) < 8 {
+			return fmt.Errorf("snmp: priv_passphrase must be at least 8 characters")
+		}
+	}
+	g.Version = gosnmp.Version3
+	g.SecurityModel = gosnmp.UserSecurityModel
+	g.SecurityParameters = usm
+	g.ContextName, _ = config["context_name"].(string)
+	return nil
+}
+
+// toInt 兼容 YAML/JSON 解析出的数值类型和数字字符串
+func toInt(v interface{}) (int, bool) {
+	switch vv := v.(type) {
+	case int:
+		return vv, true
+	case int64:
+		return int(vv), true
+	case float64:
+		return int(vv), true
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(vv))
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// toBool 点位和设备选项中的开关，接受 bool 或 "true"/"false"
+func toBool(v interface{}) bool {
+	switch vv := v.(type) {
+	case bool:
+		return vv
+	case string:
+		b, _ := strconv.ParseBool(vv)
+		return b
+	}
+	return false
+}
@@ -0,0 +1,33 @@
+package snmp
+
+import "github.com/gosnmp/gosnmp"
+
+// counterState 计数器点位上次读到的原始值和对外的累计值
+type counterState struct {
+	raw   uint64
+	total uint64
+}
+
+// unwrap 把计数器原始值转换为不回绕的累计值（调用方持有 s.mu）：Counter32 比上次小
+// 视为越过 2^32 回绕，Counter64 比上次小视为设备重启后从 0 重新计数。
+// 首次读取时累计值等于原始值。设备重启导致的 Counter32 清零同样按回绕处理
+func (s *SNMPClient) unwrap(key string, typ gosnmp.Asn1BER, raw uint64) uint64 {
+	if s.counters == nil {
+		s.counters = make(map[string]*counterState)
+	}
+	c, ok := s.counters[key]
+	if !ok {
+		s.counters[key] = &counterState{raw: raw, total: raw}
+		return raw
+	}
+	switch {
+	case raw >= c.raw:
+		c.total += raw - c.raw
+	case typ == gosnmp.Counter32:
+		c.total += raw + (1 << 32) - c.raw
+	default:
+		c.total += raw
+	}
+	c.raw = raw
+	return c.total
+}
@@ -0,0 +1,208 @@
+package snmp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"sensor-edge/protocols"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// snmpPoint 已解析的点位，oid 带前导点（与 gosnmp 返回的 OID 格式一致）
+type snmpPoint struct {
+	name string
+	oid  string
+	walk bool // 表格列，遍历该 OID 下的全部行
+	wrap bool // Counter32/Counter64 转换为不回绕的累计值
+}
+
+// normalizeOID 校验数字 OID（如 1.3.6.1.2.1.1.3.0，前导点可选）并补上前导点
+func normalizeOID(s string) (string, error) {
+	oid := strings.TrimPrefix(strings.TrimSpace(s), ".")
+	if oid == "" {
+		return "", fmt.Errorf("empty oid")
+	}
+	for _, arc := range strings.Split(oid, ".") {
+		if _, err := strconv.ParseUint(arc, 10, 32); err != nil {
+			return "", fmt.Errorf("invalid oid %s", s)
+		}
+	}
+	return "." + oid, nil
+}
+
+// planReads 普通点位按 maxOids 分组为 GET 请求，表格列点位单独遍历
+func planReads(points []snmpPoint, maxOids int) ([][]snmpPoint, []snmpPoint) {
+	var gets [][]snmpPoint
+	var walks, cur []snmpPoint
+	for _, p := range points {
+		if p.walk {
+			walks = append(walks, p)
+			continue
+		}
+		if len(cur) == maxOids {
+			gets = append(gets, cur)
+			cur = nil
+		}
+		cur = append(cur, p)
+	}
+	if len(cur) > 0 {
+		gets = append(gets, cur)
+	}
+	return gets, walks
+}
+
+// readPoints 执行 GET 和表格列遍历
+func (s *SNMPClient) readPoints(deviceID string, points []snmpPoint) ([]protocols.PointValue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	gets, walks := planReads(points, s.client.MaxOids)
+	var results []protocols.PointValue
+	for _, chunk := range gets {
+		values, err := s.get(deviceID, chunk)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, values...)
+	}
+	for _, p := range walks {
+		values, err := s.walk(deviceID, p)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, values...)
+	}
+	return results, nil
+}
+
+// get 一次 GET 读取一组点位。应答带错误状态（v1 的 noSuchName、tooBig 等）时
+// 整组没有值，改为逐个 OID 重新读取，找出出错的点位
+func (s *SNMPClient) get(deviceID string, points []snmpPoint) ([]protocols.PointValue, error) {
+	oids := make([]string, len(points))
+	for i, p := range points {
+		oids[i] = p.oid
+	}
+	pkt, err := s.client.Get(oids)
+	if err != nil {
+		return nil, err
+	}
+	if pkt.Error != gosnmp.NoError {
+		if len(points) == 1 {
+			return []protocols.PointValue{badValue(points[0].name)}, nil
+		}
+		var results []protocols.PointValue
+		for _, p := range points {
+			values, err := s.get(deviceID, []snmpPoint{p})
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, values...)
+		}
+		return results, nil
+	}
+	if len(pkt.Variables) != len(points) {
+		return nil, fmt.Errorf("snmp: got %d variables for %d oids", len(pkt.Variables), len(points))
+	}
+	results := make([]protocols.PointValue, len(points))
+	for i, p := range points {
+		results[i] = s.pointValue(deviceID, p, p.name, pkt.Variables[i])
+	}
+	return results, nil
+}
+
+// walk 遍历表格列，每行的 PointID 为 <点位名>.<索引>，索引为行 OID 去掉列 OID 后的部分
+// （如 ifInOctets.3、ipAdEntIfIndex.192.168.1.1）。该列没有任何行时点位为 bad
+func (s *SNMPClient) walk(deviceID string, p snmpPoint) ([]protocols.PointValue, error) {
+	var pdus []gosnmp.SnmpPDU
+	var err error
+	if s.client.Version == gosnmp.Version1 {
+		pdus, err = s.client.WalkAll(p.oid)
+	} else {
+		pdus, err = s.client.BulkWalkAll(p.oid)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var results []protocols.PointValue
+	for _, v := range pdus {
+		index := strings.TrimPrefix(v.Name, p.oid+".")
+		if index == v.Name {
+			continue
+		}
+		results = append(results, s.pointValue(deviceID, p, p.name+"."+index, v))
+	}
+	if len(results) == 0 {
+		return []protocols.PointValue{badValue(p.name)}, nil
+	}
+	return results, nil
+}
+
+// pointValue 转换变量值，按点位配置处理计数器回绕
+func (s *SNMPClient) pointValue(deviceID string, p snmpPoint, name string, v gosnmp.SnmpPDU) protocols.PointValue {
+	value, ok := convertValue(v)
+	if !ok {
+		return badValue(name)
+	}
+	if p.wrap && (v.Type == gosnmp.Counter32 || v.Type == gosnmp.Counter64) {
+		value = s.unwrap(deviceID+"|"+name, v.Type, gosnmp.ToBigInt(v.Value).Uint64())
+	}
+	return goodValue(name, value)
+}
+
+// convertValue 按 ASN.1 类型转换为 Go 值：Integer 为 int，Counter32/Gauge32/TimeTicks 为 uint32，
+// Counter64 为 uint64，OctetString 为字符串（非文本内容如 MAC 地址转为冒号分隔的十六进制），
+// IpAddress/OID 为字符串。noSuchObject/noSuchInstance/endOfMibView 表示设备没有该 OID
+func convertValue(v gosnmp.SnmpPDU) (interface{}, bool) {
+	switch v.Type {
+	case gosnmp.NoSuchObject, gosnmp.NoSuchInstance, gosnmp.EndOfMibView, gosnmp.Null:
+		return nil, false
+	case gosnmp.OctetString:
+		b, _ := v.Value.([]byte)
+		return octetString(b), true
+	case gosnmp.Counter32, gosnmp.Gauge32, gosnmp.TimeTicks, gosnmp.Uinteger32:
+		return uint32(gosnmp.ToBigInt(v.Value).Uint64()), true
+	case gosnmp.Counter64:
+		return gosnmp.ToBigInt(v.Value).Uint64(), true
+	}
+	return v.Value, v.Value != nil
+}
+
+func octetString(b []byte) string {
+	printable := utf8.Valid(b)
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			printable = false
+			break
+		}
+	}
+	if printable {
+		return string(b)
+	}
+	hex := make([]string, len(b))
+	for i, c := range b {
+		hex[i] = fmt.Sprintf("%02x", c)
+	}
+	return strings.Join(hex, ":")
+}
+
+func goodValue(name string, v interface{}) protocols.PointValue {
+	return protocols.PointValue{
+		PointID:   name,
+		Value:     v,
+		Quality:   "good",
+		Timestamp: time.Now().Unix(),
+	}
+}
+
+func badValue(name string) protocols.PointValue {
+	return protocols.PointValue{
+		PointID:   name,
+		Value:     nil,
+		Quality:   "bad",
+		Timestamp: time.Now().Unix(),
+	}
+}
@@ -1,79 +1,179 @@
 package snmp
 
 import (
+	"sync"
+
 	"sensor-edge/protocols"
-	"time"
 
 	"github.com/gosnmp/gosnmp"
 )
 
+// SNMPClient SNMP v1/v2c/v3 客户端，点位地址为 OID。普通点位按 max_oids 合并为 GET，
+// 点位配置 walk: true 的为表格列，用 GETBULK（v1 为 GETNEXT）遍历，每行一个值
 type SNMPClient struct {
-	client *gosnmp.GoSNMP
-	oids   []string
+	client      *gosnmp.GoSNMP
+	counterWrap bool // 设备默认的计数器回绕处理，点位可用 counter_wrap 覆盖
+
+	mu       sync.Mutex // gosnmp 客户端不支持并发请求
+	counters map[string]*counterState
+
+	pmu    sync.RWMutex
+	points map[string]pointTable // 设备 ID -> 点位配置
 }
 
+// pointTable 按地址和点位名索引的点位配置
+type pointTable map[string]protocols.PointConfig
+
+// Init 连接参数：
+//
+//	ip: 192.168.1.10
+//	port: 161
+//	version: 2c            # 1 | 2c(默认) | 3
+//	community: public      # v1/v2c
+//	timeout: 2000          # 单次请求超时时间(毫秒)
+//	retries: 2
+//	max_oids: 60           # 单个 GET 请求的 OID 数量上限
+//	max_repetitions: 10    # GETBULK 每次返回的行数
+//	counter_wrap: false    # Counter32/Counter64 是否转换为不回绕的累计值
+//	# v3 用户安全模型
+//	security_name: user
+//	security_level: authPriv   # noAuthNoPriv | authNoPriv | authPriv
+//	auth_protocol: SHA         # MD5 | SHA | SHA224 | SHA256 | SHA384 | SHA512
+//	auth_passphrase: "********"
+//	priv_protocol: AES         # DES | AES | AES192 | AES256 | AES192C | AES256C
+//	priv_passphrase: "********"
+//	context_name: ""
 func (s *SNMPClient) Init(config map[string]interface{}) error {
-	s.client = &gosnmp.GoSNMP{
-		Target:    config["ip"].(string),
-		Port:      uint16(config["port"].(float64)),
-		Version:   gosnmp.Version2c,
-		Community: config["community"].(string),
-		Timeout:   2 * time.Second,
-		Retries:   2,
+	client, err := newGoSNMP(config)
+	if err != nil {
+		return err
 	}
-
-	s.oids = []string{config["oid"].(string)}
+	s.client = client
+	s.counterWrap = toBool(config["counter_wrap"])
 	return s.client.Connect()
 }
 
+// SetPointConfigs 记录设备的点位配置（walk、counter_wrap 选项）
+func (s *SNMPClient) SetPointConfigs(deviceID string, points []protocols.PointConfig) {
+	table := make(pointTable, len(points)*2)
+	for _, p := range points {
+		if p.Address != "" {
+			table[p.Address] = p
+		}
+		if p.PointID != "" {
+			table[p.PointID] = p
+		}
+	}
+	s.pmu.Lock()
+	defer s.pmu.Unlock()
+	if s.points == nil {
+		s.points = make(map[string]pointTable)
+	}
+	s.points[deviceID] = table
+}
+
+// Read 读取设备配置的全部点位，PointID 为点位名，表格列为 <点位名>.<索引>
 func (s *SNMPClient) Read(deviceID string) ([]protocols.PointValue, error) {
-	result, err := s.client.Get(s.oids)
+	s.pmu.RLock()
+	var configs []protocols.PointConfig
+	for key, p := range s.points[deviceID] {
+		if key == p.PointID {
+			configs = append(configs, p)
+		}
+	}
+	s.pmu.RUnlock()
+	points, bad := s.parsePoints(configs)
+	values, err := s.readPoints(deviceID, points)
 	if err != nil {
 		return nil, err
 	}
-
-	var val interface{}
-	for _, variable := range result.Variables {
-		switch variable.Type {
-		case gosnmp.Integer:
-			val = variable.Value.(int)
-		case gosnmp.OctetString:
-			val = string(variable.Value.([]byte))
-		default:
-			val = variable.Value
-		}
+	for _, name := range bad {
+		values = append(values, badValue(name))
 	}
-
-	return []protocols.PointValue{
-		{
-			PointID:   "snmp_metric",
-			Value:     val,
-			Quality:   "good",
-			Timestamp: time.Now().Unix(),
-		},
-	}, nil
+	return values, nil
 }
 
-// ReadBatch 批量读取接口，返回指定数据点
+// ReadBatch 按 OID 批量读取，返回值按地址回填，表格列每行为 <地址>.<索引>。
+// 设备不存在的 OID（noSuchObject/noSuchInstance）为 bad；请求超时等通信错误直接返回。
+// function 参数不影响读取
 func (s *SNMPClient) ReadBatch(deviceID string, function string, points []string) ([]protocols.PointValue, error) {
-	// function 参数暂未用到，保留兼容
 	if len(points) == 0 {
 		return nil, nil
 	}
-	var values []protocols.PointValue
-	for _, pt := range points {
-		result, err := s.client.Get([]string{pt})
-		if err != nil || len(result.Variables) == 0 {
-			values = append(values, protocols.PointValue{PointID: pt, Quality: "bad", Value: nil, Timestamp: time.Now().Unix()})
+	parsed, bad := s.parsePoints(s.batchConfigs(deviceID, points))
+	values, err := s.readPoints(deviceID, parsed)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range bad {
+		values = append(values, badValue(name))
+	}
+	return values, nil
+}
+
+// ReadPlan 返回 ReadBatch 对这些点位将发出的请求：GET 的 Quantity 为 OID 数量，
+// 表格列遍历的 Quantity 为每次 GETBULK 的行数（GETNEXT 为 1）
+func (s *SNMPClient) ReadPlan(deviceID string, function string, points []string) (protocols.ReadPlan, error) {
+	parsed, bad := s.parsePoints(s.batchConfigs(deviceID, points))
+	plan := protocols.ReadPlan{Skipped: bad}
+	gets, walks := planReads(parsed, s.client.MaxOids)
+	for _, chunk := range gets {
+		r := protocols.ReadRequest{Function: "get", Quantity: len(chunk)}
+		for _, p := range chunk {
+			r.Points = append(r.Points, p.name)
+		}
+		plan.Requests = append(plan.Requests, r)
+	}
+	for _, p := range walks {
+		r := protocols.ReadRequest{Function: "getbulk", Quantity: int(s.client.MaxRepetitions), Points: []string{p.name}}
+		if s.client.Version == gosnmp.Version1 {
+			r.Function, r.Quantity = "getnext", 1
+		}
+		plan.Requests = append(plan.Requests, r)
+	}
+	return plan, nil
+}
+
+// batchConfigs 以地址作为 PointID 构造点位配置，选项取自设备的点位配置
+func (s *SNMPClient) batchConfigs(deviceID string, points []string) []protocols.PointConfig {
+	s.pmu.RLock()
+	table := s.points[deviceID]
+	s.pmu.RUnlock()
+	configs := make([]protocols.PointConfig, len(points))
+	for i, addr := range points {
+		configs[i] = protocols.PointConfig{PointID: addr, Address: addr, Options: table[addr].Options}
+	}
+	return configs
+}
+
+// parsePoints 解析点位 OID 和选项，返回可读取的点位和 OID 无效的点位名
+func (s *SNMPClient) parsePoints(configs []protocols.PointConfig) ([]snmpPoint, []string) {
+	var points []snmpPoint
+	var bad []string
+	for _, c := range configs {
+		oid, err := normalizeOID(c.Address)
+		if err != nil {
+			bad = append(bad, c.PointID)
 			continue
 		}
-		val := result.Variables[0].Value
-		values = append(values, protocols.PointValue{PointID: pt, Quality: "good", Value: val, Timestamp: time.Now().Unix()})
+		p := snmpPoint{name: c.PointID, oid: oid, wrap: s.counterWrap}
+		if v, ok := c.Options["walk"]; ok {
+			p.walk = toBool(v)
+		}
+		if v, ok := c.Options["counter_wrap"]; ok {
+			p.wrap = toBool(v)
+		}
+		points = append(points, p)
 	}
-	return values, nil
+	return points, bad
 }
 
 func (s *SNMPClient) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client == nil || s.client.Conn == nil {
+		return nil
+	}
 	return s.client.Conn.Close()
 }
 
@@ -90,11 +190,15 @@ func init() {
 	protocols.Register("snmp", NewSNMPClient)
 }
 
-// SNMPClient 重新链接
+// Reconnect 重新创建 UDP 套接字
 func (s *SNMPClient) Reconnect() error {
 	if s.client == nil {
 		return nil // 未初始化时不需要重连
 	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.client.Conn != nil {
+		s.client.Conn.Close()
+	}
 	return s.client.Connect()
 }
-// SNMPClient 重新链接
\ No newline at end of file
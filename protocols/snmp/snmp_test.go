@@ -0,0 +1,313 @@
+package snmp
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"sensor-edge/protocols"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// simAgent 按 v1/v2c 应答 GET、GETNEXT、GETBULK 的模拟 SNMP 代理，
+// 单个请求的变量超过 maxVars 时返回 tooBig
+type simAgent struct {
+	conn    *net.UDPConn
+	version gosnmp.SnmpVersion
+	maxVars int
+
+	mu       sync.Mutex
+	mib      map[string]gosnmp.SnmpPDU
+	requests []gosnmp.PDUType
+}
+
+func newSimAgent(t *testing.T, version gosnmp.SnmpVersion) *simAgent {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := &simAgent{conn: conn, version: version, maxVars: 100, mib: make(map[string]gosnmp.SnmpPDU)}
+	go a.serve()
+	t.Cleanup(func() { conn.Close() })
+	return a
+}
+
+func (a *simAgent) set(oid string, typ gosnmp.Asn1BER, value interface{}) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.mib[oid] = gosnmp.SnmpPDU{Name: oid, Type: typ, Value: value}
+}
+
+func (a *simAgent) client(t *testing.T, config map[string]interface{}) *SNMPClient {
+	config["ip"] = "127.0.0.1"
+	config["port"] = a.conn.LocalAddr().(*net.UDPAddr).Port
+	config["timeout"] = 500
+	config["retries"] = 0
+	if a.version == gosnmp.Version1 {
+		config["version"] = 1
+	}
+	c := &SNMPClient{}
+	if err := c.Init(config); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func (a *simAgent) serve() {
+	buf := make([]byte, 65535)
+	decoder := &gosnmp.GoSNMP{Version: a.version}
+	for {
+		n, addr, err := a.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		req, err := decoder.SnmpDecodePacket(buf[:n])
+		if err != nil {
+			continue
+		}
+		resp := a.handle(req)
+		out, err := resp.MarshalMsg()
+		if err != nil {
+			continue
+		}
+		a.conn.WriteToUDP(out, addr)
+	}
+}
+
+func (a *simAgent) handle(req *gosnmp.SnmpPacket) *gosnmp.SnmpPacket {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.requests = append(a.requests, req.PDUType)
+	resp := &gosnmp.SnmpPacket{
+		Version:   a.version,
+		Community: req.Community,
+		PDUType:   gosnmp.GetResponse,
+		RequestID: req.RequestID,
+	}
+	if len(req.Variables) > a.maxVars {
+		resp.Error = gosnmp.TooBig
+		return resp
+	}
+	sorted := a.sortedOIDs()
+	for i, v := range req.Variables {
+		switch req.PDUType {
+		case gosnmp.GetRequest:
+			pdu, ok := a.mib[v.Name]
+			if !ok && a.version == gosnmp.Version1 {
+				resp.Error, resp.ErrorIndex = gosnmp.NoSuchName, uint8(i+1)
+				resp.Variables = req.Variables
+				return resp
+			}
+			if !ok {
+				pdu = gosnmp.SnmpPDU{Name: v.Name, Type: gosnmp.NoSuchInstance}
+			}
+			resp.Variables = append(resp.Variables, pdu)
+		case gosnmp.GetNextRequest:
+			resp.Variables = append(resp.Variables, a.next(sorted, v.Name, 1)...)
+		case gosnmp.GetBulkRequest:
+			resp.Variables = append(resp.Variables, a.next(sorted, v.Name, int(req.MaxRepetitions))...)
+		}
+	}
+	return resp
+}
+
+// next 按 OID 字典序返回 oid 之后的 n 个变量，到末尾时以 endOfMibView 结束
+func (a *simAgent) next(sorted []string, oid string, n int) []gosnmp.SnmpPDU {
+	i := sort.Search(len(sorted), func(i int) bool { return compareOID(sorted[i], oid) > 0 })
+	var out []gosnmp.SnmpPDU
+	for ; i < len(sorted) && len(out) < n; i++ {
+		out = append(out, a.mib[sorted[i]])
+	}
+	if len(out) < n {
+		out = append(out, gosnmp.SnmpPDU{Name: oid, Type: gosnmp.EndOfMibView})
+	}
+	return out
+}
+
+func (a *simAgent) sortedOIDs() []string {
+	oids := make([]string, 0, len(a.mib))
+	for oid := range a.mib {
+		oids = append(oids, oid)
+	}
+	sort.Slice(oids, func(i, j int) bool { return compareOID(oids[i], oids[j]) < 0 })
+	return oids
+}
+
+func compareOID(a, b string) int {
+	x, y := strings.Split(strings.Trim(a, "."), "."), strings.Split(strings.Trim(b, "."), ".")
+	for i := 0; i < len(x) && i < len(y); i++ {
+		m, _ := strconv.Atoi(x[i])
+		n, _ := strconv.Atoi(y[i])
+		if m != n {
+			return m - n
+		}
+	}
+	return len(x) - len(y)
+}
+
+const (
+	sysDescr    = ".1.3.6.1.2.1.1.1.0"
+	sysUpTime   = ".1.3.6.1.2.1.1.3.0"
+	ifInOctets  = ".1.3.6.1.2.1.2.2.1.10"
+	ifPhysAddr  = ".1.3.6.1.2.1.2.2.1.6"
+	ifHCInOctet = ".1.3.6.1.2.1.31.1.1.1.6.1"
+)
+
+func newIfAgent(t *testing.T, version gosnmp.SnmpVersion) *simAgent {
+	a := newSimAgent(t, version)
+	a.set(sysDescr, gosnmp.OctetString, []byte("edge switch"))
+	a.set(sysUpTime, gosnmp.TimeTicks, uint32(12345))
+	a.set(ifPhysAddr+".1", gosnmp.OctetString, []byte{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e})
+	for i := 1; i <= 12; i++ {
+		a.set(ifInOctets+"."+strconv.Itoa(i), gosnmp.Counter32, uint(i*100))
+	}
+	a.set(ifHCInOctet, gosnmp.Counter64, uint64(1)<<40)
+	return a
+}
+
+func TestReadBatch(t *testing.T) {
+	for _, version := range []gosnmp.SnmpVersion{gosnmp.Version2c, gosnmp.Version1} {
+		agent := newIfAgent(t, version)
+		c := agent.client(t, map[string]interface{}{"max_oids": 2, "max_repetitions": 5})
+		c.SetPointConfigs("sw", []protocols.PointConfig{
+			{PointID: "in_octets", Address: strings.TrimPrefix(ifInOctets, "."), Options: map[string]interface{}{"walk": true}},
+		})
+		addrs := []string{sysDescr, "1.3.6.1.2.1.1.3.0", ifPhysAddr + ".1", ".1.3.6.1.2.1.1.9.0", ifHCInOctet, "1.3.6.1.2.1.2.2.1.10", "sysName"}
+		values, err := c.ReadBatch("sw", "", addrs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := make(map[string]protocols.PointValue)
+		for _, v := range values {
+			got[v.PointID] = v
+		}
+		want := map[string]interface{}{
+			sysDescr:                  "edge switch",
+			"1.3.6.1.2.1.1.3.0":       uint32(12345),
+			ifPhysAddr + ".1":         "00:1a:2b:3c:4d:5e",
+			ifHCInOctet:               uint64(1) << 40,
+			"1.3.6.1.2.1.2.2.1.10.1":  uint32(100),
+			"1.3.6.1.2.1.2.2.1.10.12": uint32(1200),
+		}
+		for addr, v := range want {
+			if got[addr].Value != v || got[addr].Quality != "good" {
+				t.Errorf("%v %s: got %v (%s), want %v", version, addr, got[addr].Value, got[addr].Quality, v)
+			}
+		}
+		for _, addr := range []string{".1.3.6.1.2.1.1.9.0", "sysName"} {
+			if got[addr].Quality != "bad" {
+				t.Errorf("%v %s: expect bad, got %v", version, addr, got[addr])
+			}
+		}
+		// 5 个 GET 点位 + 12 行表格列 + 1 个无效 OID
+		if len(values) != 18 {
+			t.Errorf("%v: got %d values", version, len(values))
+		}
+		plan, _ := c.ReadPlan("sw", "", addrs)
+		if len(plan.Requests) != 4 || plan.Requests[2].Quantity != 1 || len(plan.Skipped) != 1 {
+			t.Errorf("%v: unexpected plan %+v", version, plan)
+		}
+	}
+}
+
+func TestGetFallback(t *testing.T) {
+	agent := newIfAgent(t, gosnmp.Version2c)
+	agent.mu.Lock()
+	agent.maxVars = 2
+	agent.mu.Unlock()
+	c := agent.client(t, map[string]interface{}{"max_oids": 3})
+	values, err := c.ReadBatch("sw", "", []string{sysDescr, sysUpTime, ifHCInOctet})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range values {
+		if v.Quality != "good" {
+			t.Errorf("%s: expect good, got %v", v.PointID, v)
+		}
+	}
+	// tooBig 后逐个 OID 重新读取
+	agent.mu.Lock()
+	defer agent.mu.Unlock()
+	if len(agent.requests) != 4 {
+		t.Errorf("expect 4 requests, got %d", len(agent.requests))
+	}
+}
+
+func TestCounterWrap(t *testing.T) {
+	agent := newIfAgent(t, gosnmp.Version2c)
+	c := agent.client(t, map[string]interface{}{"counter_wrap": true})
+	c.SetPointConfigs("sw", []protocols.PointConfig{
+		{PointID: "uptime", Address: sysUpTime},
+		{PointID: "in1", Address: ifInOctets + ".1", Options: map[string]interface{}{"counter_wrap": false}},
+	})
+	read := func(addr string) interface{} {
+		values, err := c.ReadBatch("sw", "", []string{addr})
+		if err != nil || len(values) != 1 {
+			t.Fatalf("read %s: %v %v", addr, values, err)
+		}
+		return values[0].Value
+	}
+	in2 := ifInOctets + ".2"
+	agent.set(in2, gosnmp.Counter32, uint(0xFFFFFF00))
+	if v := read(in2); v != uint64(0xFFFFFF00) {
+		t.Errorf("first read: got %v", v)
+	}
+	agent.set(in2, gosnmp.Counter32, uint(0x10))
+	if v := read(in2); v != uint64(0x100000010) {
+		t.Errorf("after wrap: got %v", v)
+	}
+	agent.set(ifHCInOctet, gosnmp.Counter64, uint64(5))
+	read(ifHCInOctet)
+	agent.set(ifHCInOctet, gosnmp.Counter64, uint64(2))
+	if v := read(ifHCInOctet); v != uint64(7) {
+		t.Errorf("after reset: got %v", v)
+	}
+	// 点位关闭回绕处理、非计数器类型不受影响
+	agent.set(ifInOctets+".1", gosnmp.Counter32, uint(1))
+	if v := read(ifInOctets + ".1"); v != uint32(1) {
+		t.Errorf("counter_wrap off: got %v", v)
+	}
+	if v := read(sysUpTime); v != uint32(12345) {
+		t.Errorf("timeticks: got %v", v)
+	}
+}
+
+func TestUSMConfig(t *testing.T) {
+	g, err := newGoSNMP(map[string]interface{}{
+		"ip": "10.0.0.1", "port": "1161", "version": "v3",
+		"security_name": "monitor", "security_level": "authPriv",
+		"auth_protocol": "sha256", "auth_passphrase": "authpass123",
+		"priv_protocol": "AES256C", "priv_passphrase": "privpass123",
+		"context_name": "vlan10",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	usm := g.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+	if g.Version != gosnmp.Version3 || g.Port != 1161 || g.MsgFlags != gosnmp.AuthPriv || g.ContextName != "vlan10" ||
+		usm.UserName != "monitor" || usm.AuthenticationProtocol != gosnmp.SHA256 || usm.PrivacyProtocol != gosnmp.AES256C {
+		t.Errorf("unexpected v3 config: %+v %+v", g, usm)
+	}
+	g, err = newGoSNMP(map[string]interface{}{"ip": "10.0.0.1", "version": 3, "security_name": "ro", "security_level": "noAuthNoPriv"})
+	if err != nil || g.MsgFlags != gosnmp.NoAuthNoPriv || g.Port != defaultPort {
+		t.Errorf("noAuthNoPriv: %v %+v", err, g)
+	}
+	for _, config := range []map[string]interface{}{
+		{"ip": "10.0.0.1", "version": 3},
+		{"ip": "10.0.0.1", "version": 3, "security_name": "u", "security_level": "authNoPriv", "auth_protocol": "SHA"},
+		{"ip": "10.0.0.1", "version": 3, "security_name": "u", "security_level": "authNoPriv", "auth_protocol": "SHA1", "auth_passphrase": "authpass123"},
+		{"ip": "10.0.0.1", "version": 3, "security_name": "u", "auth_protocol": "MD5", "auth_passphrase": "authpass123", "priv_protocol": "3DES", "priv_passphrase": "privpass123"},
+		{"ip": "10.0.0.1", "version": "4"},
+		{"ip": "10.0.0.1", "port": 70000},
+		{"port": 161},
+	} {
+		if _, err := newGoSNMP(config); err == nil {
+			t.Errorf("%v: expect error", config)
+		}
+	}
+}
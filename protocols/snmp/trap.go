@@ -0,0 +1,234 @@
+package snmp
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"sensor-edge/schema"
+	"sensor-edge/types"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+const (
+	snmpTrapOID     = ".1.3.6.1.6.3.1.1.4.1.0"           // v2c/v3 Trap 中标识 Trap 类型的变量
+	snmpTrapsPrefix = ".1.3.6.1.6.3.1.1.5"               // v1 通用 Trap（coldStart 等）对应的 v2 Trap OID 前缀
+	defaultEngineID = "80001f880473656e736f722d65646765" // RFC 3411 文本格式："sensor-edge"
+)
+
+// TrapEvent 一次 Trap 匹配规则后产生的设备点位值和报警
+type TrapEvent struct {
+	DeviceID string
+	Source   string
+	TrapOID  string
+	Values   map[string]interface{}
+	Alarms   []schema.AlarmInfo
+}
+
+// TrapReceiver SNMP Trap/Inform 接收器（v1/v2c/v3），Inform 由 gosnmp 自动应答
+type TrapReceiver struct {
+	listener    *gosnmp.TrapListener
+	communities map[string]bool
+	rules       []types.SnmpTrapRule
+	handler     func(TrapEvent)
+}
+
+// NewTrapReceiver 按配置创建接收器，规则中的 OID 统一为带前导点的格式
+func NewTrapReceiver(conf *types.SnmpTrapRules, handler func(TrapEvent)) (*TrapReceiver, error) {
+	r := &TrapReceiver{communities: make(map[string]bool), handler: handler}
+	for _, c := range conf.Communities {
+		r.communities[c] = true
+	}
+	for i, rule := range conf.Rules {
+		if rule.DeviceID == "" {
+			return nil, fmt.Errorf("snmp trap rule %d: device_id is required", i)
+		}
+		oid, err := normalizeOID(rule.TrapOID)
+		if err != nil {
+			return nil, fmt.Errorf("snmp trap rule %d: %v", i, err)
+		}
+		rule.TrapOID = oid
+		points := make([]types.SnmpTrapPoint, len(rule.Points))
+		for j, p := range rule.Points {
+			if points[j].OID, err = normalizeOID(p.OID); err != nil {
+				return nil, fmt.Errorf("snmp trap rule %d: %v", i, err)
+			}
+			points[j].Name = p.Name
+		}
+		rule.Points = points
+		r.rules = append(r.rules, rule)
+	}
+	params, err := trapParams(conf)
+	if err != nil {
+		return nil, err
+	}
+	r.listener = gosnmp.NewTrapListener()
+	r.listener.Params = params
+	r.listener.OnNewTrap = r.handle
+	return r, nil
+}
+
+// trapParams 配置 v3 用户表：Trap 以发送方为权威引擎，按报文中的用户名查找凭据；
+// Inform 以接收方为权威引擎，发送方先用引擎发现取得 engine_id
+func trapParams(conf *types.SnmpTrapRules) (*gosnmp.GoSNMP, error) {
+	params := &gosnmp.GoSNMP{Version: gosnmp.Version2c}
+	if len(conf.Users) == 0 {
+		return params, nil
+	}
+	engineID := conf.EngineID
+	if engineID == "" {
+		engineID = defaultEngineID
+	}
+	id, err := hex.DecodeString(strings.TrimPrefix(engineID, "0x"))
+	if err != nil || len(id) < 5 || len(id) > 32 {
+		return nil, fmt.Errorf("snmp trap: invalid engine_id %s", engineID)
+	}
+	table := gosnmp.NewSnmpV3SecurityParametersTable(params.Logger)
+	for _, u := range conf.Users {
+		usm, err := trapUser(u)
+		if err != nil {
+			return nil, err
+		}
+		if err := table.Add(u.SecurityName, usm); err != nil {
+			return nil, fmt.Errorf("snmp trap user %s: %v", u.SecurityName, err)
+		}
+	}
+	params.Version = gosnmp.Version3
+	params.SecurityModel = gosnmp.UserSecurityModel
+	params.SecurityParameters = &gosnmp.UsmSecurityParameters{AuthoritativeEngineID: string(id)}
+	params.TrapSecurityParametersTable = table
+	return params, nil
+}
+
+func trapUser(u types.SnmpTrapUser) (*gosnmp.UsmSecurityParameters, error) {
+	if u.SecurityName == "" {
+		return nil, fmt.Errorf("snmp trap: security_name is required")
+	}
+	usm := &gosnmp.UsmSecurityParameters{UserName: u.SecurityName, AuthenticationProtocol: gosnmp.NoAuth, PrivacyProtocol: gosnmp.NoPriv}
+	if u.AuthProtocol != "" {
+		proto, ok := authProtocols[strings.ToUpper(u.AuthProtocol)]
+		if !ok {
+			return nil, fmt.Errorf("snmp trap user %s: unsupported auth_protocol %q", u.SecurityName, u.AuthProtocol)
+		}
+		if len(u.AuthPassphrase) < 8 {
+			return nil, fmt.Errorf("snmp trap user %s: auth_passphrase must be at least 8 characters", u.SecurityName)
+		}
+		usm.AuthenticationProtocol, usm.AuthenticationPassphrase = proto, u.AuthPassphrase
+	}
+	if u.PrivProtocol != "" {
+		proto, ok := privProtocols[strings.ToUpper(u.PrivProtocol)]
+		if !ok || u.AuthProtocol == "" {
+			return nil, fmt.Errorf("snmp trap user %s: invalid priv_protocol %q", u.SecurityName, u.PrivProtocol)
+		}
+		if len(u.PrivPassphrase) < 8 {
+			return nil, fmt.Errorf("snmp trap user %s: priv_passphrase must be at least 8 characters", u.SecurityName)
+		}
+		usm.PrivacyProtocol, usm.PrivacyPassphrase = proto, u.PrivPassphrase
+	}
+	return usm, nil
+}
+
+// Start 在 addr（如 :162）上监听，监听成功后返回
+func (r *TrapReceiver) Start(addr string) error {
+	errCh := make(chan error, 1)
+	go func() { errCh <- r.listener.Listen(addr) }()
+	select {
+	case <-r.listener.Listening():
+		return nil
+	case err := <-errCh:
+		return err
+	}
+}
+
+func (r *TrapReceiver) Close() {
+	r.listener.Close()
+}
+
+func (r *TrapReceiver) handle(pkt *gosnmp.SnmpPacket, addr *net.UDPAddr) {
+	if pkt.Version != gosnmp.Version3 && len(r.communities) > 0 && !r.communities[pkt.Community] {
+		log.Printf("[SNMP] drop trap from %s: unknown community", addr.IP)
+		return
+	}
+	for _, e := range r.Match(pkt, addr.IP.String()) {
+		r.handler(e)
+	}
+}
+
+// Match 按规则把 Trap 转换为设备事件，同一设备的多条规则合并为一个事件
+func (r *TrapReceiver) Match(pkt *gosnmp.SnmpPacket, source string) []TrapEvent {
+	if pkt.PDUType == gosnmp.Trap && pkt.AgentAddress != "" && pkt.AgentAddress != "0.0.0.0" {
+		source = pkt.AgentAddress
+	}
+	oid := trapOID(pkt)
+	var events []TrapEvent
+	index := make(map[string]int)
+	for _, rule := range r.rules {
+		if !inSubtree(oid, rule.TrapOID) || (rule.Source != "" && rule.Source != source) {
+			continue
+		}
+		i, ok := index[rule.DeviceID]
+		if !ok {
+			i = len(events)
+			index[rule.DeviceID] = i
+			events = append(events, TrapEvent{DeviceID: rule.DeviceID, Source: source, TrapOID: oid, Values: make(map[string]interface{})})
+		}
+		e := &events[i]
+		for name, v := range rule.Values {
+			e.Values[name] = v
+		}
+		for _, v := range pkt.Variables {
+			for _, p := range rule.Points {
+				if !inSubtree(v.Name, p.OID) {
+					continue
+				}
+				if value, ok := convertValue(v); ok {
+					e.Values[p.Name+strings.TrimPrefix(v.Name, p.OID)] = value
+				}
+			}
+		}
+		if rule.Alarm != nil {
+			e.Alarms = append(e.Alarms, trapAlarm(rule.Alarm, oid, source, e.Values))
+		}
+	}
+	return events
+}
+
+// trapAlarm 生成报警，报警名默认为 Trap OID
+func trapAlarm(a *types.SnmpTrapAlarm, oid, source string, values map[string]interface{}) schema.AlarmInfo {
+	pairs := []string{"{source}", source, "{trap_oid}", oid}
+	for name, v := range values {
+		pairs = append(pairs, "{"+name+"}", fmt.Sprint(v))
+	}
+	alarm := schema.AlarmInfo{Name: a.Name, Level: a.Level, Message: strings.NewReplacer(pairs...).Replace(a.Message)}
+	if alarm.Name == "" {
+		alarm.Name = oid
+	}
+	return alarm
+}
+
+// trapOID 取 Trap 类型 OID：v2c/v3 为 snmpTrapOID.0 的值；v1 按 RFC 3584 转换，
+// 通用 Trap 为 snmpTraps.(generic+1)，企业专用 Trap 为 enterprise.0.specific
+func trapOID(pkt *gosnmp.SnmpPacket) string {
+	if pkt.PDUType == gosnmp.Trap {
+		if pkt.GenericTrap != 6 {
+			return snmpTrapsPrefix + "." + strconv.Itoa(pkt.GenericTrap+1)
+		}
+		return "." + strings.TrimPrefix(pkt.Enterprise, ".") + ".0." + strconv.Itoa(pkt.SpecificTrap)
+	}
+	for _, v := range pkt.Variables {
+		if v.Name == snmpTrapOID {
+			s, _ := v.Value.(string)
+			return "." + strings.TrimPrefix(s, ".")
+		}
+	}
+	return ""
+}
+
+// inSubtree oid 是否为 root 自身或在其子树下
+func inSubtree(oid, root string) bool {
+	return oid == root || strings.HasPrefix(oid, root+".")
+}
@@ -0,0 +1,170 @@
+package snmp
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"sensor-edge/types"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+const (
+	upsOnBattery    = ".1.3.6.1.4.1.318.0.5"
+	upsBatteryCap   = ".1.3.6.1.4.1.318.1.1.1.2.2.1.0"
+	ifOperStatus    = ".1.3.6.1.2.1.2.2.1.8"
+	linkDown        = ".1.3.6.1.6.3.1.1.5.3"
+	testAuthPass    = "authpass123"
+	testPrivPass    = "privpass123"
+	testSenderEngID = "\x80\x00\x1f\x88\x04sender"
+)
+
+var testRules = &types.SnmpTrapRules{
+	Communities: []string{"public"},
+	Users:       []types.SnmpTrapUser{{SecurityName: "ups", AuthProtocol: "SHA", AuthPassphrase: testAuthPass, PrivProtocol: "AES", PrivPassphrase: testPrivPass}},
+	Rules: []types.SnmpTrapRule{
+		{
+			TrapOID: upsOnBattery, DeviceID: "ups_1",
+			Points: []types.SnmpTrapPoint{{OID: upsBatteryCap, Name: "battery_capacity"}},
+			Values: map[string]any{"on_battery": true},
+			Alarm:  &types.SnmpTrapAlarm{Name: "on_battery", Level: "critical", Message: "{source} 切换到电池供电，剩余 {battery_capacity}%"},
+		},
+		{
+			TrapOID: linkDown, Source: "127.0.0.1", DeviceID: "switch_1",
+			Points: []types.SnmpTrapPoint{{OID: ifOperStatus, Name: "oper_status"}},
+			Alarm:  &types.SnmpTrapAlarm{Level: "warning", Message: "link down"},
+		},
+	},
+}
+
+func TestTrapMatch(t *testing.T) {
+	r, err := NewTrapReceiver(testRules, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v2 := &gosnmp.SnmpPacket{PDUType: gosnmp.SNMPv2Trap, Variables: []gosnmp.SnmpPDU{
+		{Name: ".1.3.6.1.2.1.1.3.0", Type: gosnmp.TimeTicks, Value: uint32(100)},
+		{Name: snmpTrapOID, Type: gosnmp.ObjectIdentifier, Value: linkDown},
+		{Name: ifOperStatus + ".3", Type: gosnmp.Integer, Value: 2},
+	}}
+	events := r.Match(v2, "127.0.0.1")
+	if len(events) != 1 || events[0].DeviceID != "switch_1" || events[0].Values["oper_status.3"] != 2 ||
+		len(events[0].Alarms) != 1 || events[0].Alarms[0].Name != linkDown {
+		t.Errorf("unexpected events: %+v", events)
+	}
+	if events := r.Match(v2, "10.0.0.9"); len(events) != 0 {
+		t.Errorf("source mismatch: %+v", events)
+	}
+	// v1 企业专用 Trap：enterprise.0.specific，来源取 agent-addr
+	v1 := &gosnmp.SnmpPacket{
+		PDUType:   gosnmp.Trap,
+		Variables: []gosnmp.SnmpPDU{{Name: upsBatteryCap, Type: gosnmp.Gauge32, Value: uint(87)}},
+		SnmpTrap:  gosnmp.SnmpTrap{Enterprise: ".1.3.6.1.4.1.318", AgentAddress: "192.168.1.20", GenericTrap: 6, SpecificTrap: 5},
+	}
+	events = r.Match(v1, "127.0.0.1")
+	if len(events) != 1 || events[0].Values["battery_capacity"] != uint32(87) || events[0].Values["on_battery"] != true ||
+		events[0].Alarms[0].Message != "192.168.1.20 切换到电池供电，剩余 87%" {
+		t.Errorf("unexpected v1 events: %+v", events)
+	}
+	// v1 通用 Trap linkDown（generic 2）
+	v1.GenericTrap, v1.AgentAddress = 2, ""
+	if events := r.Match(v1, "127.0.0.1"); len(events) != 1 || events[0].DeviceID != "switch_1" {
+		t.Errorf("unexpected generic trap events: %+v", events)
+	}
+
+	for _, bad := range []*types.SnmpTrapRules{
+		{Rules: []types.SnmpTrapRule{{TrapOID: linkDown}}},
+		{Rules: []types.SnmpTrapRule{{TrapOID: "linkDown", DeviceID: "d"}}},
+		{Users: []types.SnmpTrapUser{{SecurityName: "u", AuthProtocol: "SHA", AuthPassphrase: "short"}}},
+		{Users: []types.SnmpTrapUser{{SecurityName: "u", PrivProtocol: "AES", PrivPassphrase: testPrivPass}}},
+		{EngineID: "8000", Users: []types.SnmpTrapUser{{SecurityName: "u"}}},
+	} {
+		if _, err := NewTrapReceiver(bad, nil); err == nil {
+			t.Errorf("%+v: expect error", bad)
+		}
+	}
+}
+
+func TestTrapReceiver(t *testing.T) {
+	ln, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := ln.LocalAddr().(*net.UDPAddr).Port
+	ln.Close()
+	events := make(chan TrapEvent, 10)
+	r, err := NewTrapReceiver(testRules, func(e TrapEvent) { events <- e })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Start("127.0.0.1:" + strconv.Itoa(port)); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	onBattery := gosnmp.SnmpTrap{Variables: []gosnmp.SnmpPDU{
+		{Name: snmpTrapOID, Type: gosnmp.ObjectIdentifier, Value: upsOnBattery},
+		{Name: upsBatteryCap, Type: gosnmp.Gauge32, Value: uint(64)},
+	}}
+	senders := map[string]*gosnmp.GoSNMP{
+		"v2c":           {Version: gosnmp.Version2c, Community: "public"},
+		"bad community": {Version: gosnmp.Version2c, Community: "private"},
+		"v3": {Version: gosnmp.Version3, SecurityModel: gosnmp.UserSecurityModel, MsgFlags: gosnmp.AuthPriv,
+			SecurityParameters: &gosnmp.UsmSecurityParameters{
+				UserName: "ups", AuthoritativeEngineID: testSenderEngID, AuthoritativeEngineBoots: 1, AuthoritativeEngineTime: 1,
+				AuthenticationProtocol: gosnmp.SHA, AuthenticationPassphrase: testAuthPass,
+				PrivacyProtocol: gosnmp.AES, PrivacyPassphrase: testPrivPass,
+			}},
+		"v3 wrong key": {Version: gosnmp.Version3, SecurityModel: gosnmp.UserSecurityModel, MsgFlags: gosnmp.AuthNoPriv,
+			SecurityParameters: &gosnmp.UsmSecurityParameters{
+				UserName: "ups", AuthoritativeEngineID: testSenderEngID, AuthoritativeEngineBoots: 1, AuthoritativeEngineTime: 1,
+				AuthenticationProtocol: gosnmp.SHA, AuthenticationPassphrase: "wrongpass123",
+			}},
+	}
+	for name, g := range senders {
+		g.Target, g.Port, g.Timeout, g.Retries = "127.0.0.1", uint16(port), time.Second, 0
+		if err := g.Connect(); err != nil {
+			t.Fatal(err)
+		}
+		_, err := g.SendTrap(onBattery)
+		g.Conn.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		want := name == "v2c" || name == "v3"
+		select {
+		case e := <-events:
+			if !want {
+				t.Errorf("%s: unexpected event %+v", name, e)
+			} else if e.DeviceID != "ups_1" || e.Values["battery_capacity"] != uint32(64) || len(e.Alarms) != 1 || e.Alarms[0].Level != "critical" {
+				t.Errorf("%s: unexpected event %+v", name, e)
+			}
+		case <-time.After(300 * time.Millisecond):
+			if want {
+				t.Errorf("%s: no event received", name)
+			}
+		}
+	}
+
+	// Inform 需要接收方应答
+	g := &gosnmp.GoSNMP{Version: gosnmp.Version2c, Community: "public", Target: "127.0.0.1", Port: uint16(port), Timeout: time.Second, Retries: 0}
+	if err := g.Connect(); err != nil {
+		t.Fatal(err)
+	}
+	defer g.Conn.Close()
+	inform := onBattery
+	inform.IsInform = true
+	if _, err := g.SendTrap(inform); err != nil {
+		t.Fatalf("inform: %v", err)
+	}
+	select {
+	case e := <-events:
+		if e.DeviceID != "ups_1" {
+			t.Errorf("inform: unexpected event %+v", e)
+		}
+	case <-time.After(time.Second):
+		t.Error("inform: no event received")
+	}
+}
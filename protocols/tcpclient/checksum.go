@@ -0,0 +1,98 @@
+package tcpclient
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// compute 计算 data[start:] 的校验值，返回要追加在帧末的字节
+func (c *checksumConfig) compute(data []byte) ([]byte, error) {
+	if c.Start > len(data) {
+		return nil, fmt.Errorf("checksum start %d beyond frame length %d", c.Start, len(data))
+	}
+	payload := data[c.Start:]
+	if c.ASCII {
+		b, err := hex.DecodeString(string(payload))
+		if err != nil {
+			return nil, fmt.Errorf("checksum over non-hex ascii data: %v", err)
+		}
+		payload = b
+	}
+	var sum []byte
+	switch c.Type {
+	case "crc16":
+		crc := crc16(payload)
+		if strings.ToLower(c.ByteOrder) == "big" {
+			sum = []byte{byte(crc >> 8), byte(crc)}
+		} else {
+			sum = []byte{byte(crc), byte(crc >> 8)}
+		}
+	case "lrc":
+		var s byte
+		for _, b := range payload {
+			s += b
+		}
+		sum = []byte{-s}
+	case "sum8":
+		var s byte
+		for _, b := range payload {
+			s += b
+		}
+		sum = []byte{s}
+	case "xor8":
+		var s byte
+		for _, b := range payload {
+			s ^= b
+		}
+		sum = []byte{s}
+	}
+	if c.ASCII {
+		return []byte(strings.ToUpper(hex.EncodeToString(sum))), nil
+	}
+	return sum, nil
+}
+
+// size 校验值占用的字节数
+func (c *checksumConfig) size() int {
+	n := 1
+	if c.Type == "crc16" {
+		n = 2
+	}
+	if c.ASCII {
+		n *= 2
+	}
+	return n
+}
+
+// verify 校验 body 末尾的校验值
+func (c *checksumConfig) verify(body []byte) error {
+	n := c.size()
+	if len(body) < c.Start+n {
+		return fmt.Errorf("frame too short for %s checksum", c.Type)
+	}
+	want, err := c.compute(body[:len(body)-n])
+	if err != nil {
+		return err
+	}
+	if got := body[len(body)-n:]; !strings.EqualFold(string(got), string(want)) {
+		return fmt.Errorf("%s checksum mismatch: got % X, want % X", c.Type, got, want)
+	}
+	return nil
+}
+
+// crc16 Modbus CRC-16（多项式 0xA001，初值 0xFFFF）
+func crc16(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
@@ -0,0 +1,249 @@
+package tcpclient
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+
+	"sensor-edge/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// deviceConfig 设备级配置：默认请求（hex/ascii/checksum/suffix）和默认响应帧（response）之外，
+// requests 中的具名请求由点位分组的 function 选择
+type deviceConfig struct {
+	requestConfig `yaml:",inline"`
+	Transport     string                   `yaml:"transport"` // tcp(默认) | udp | serial
+	IP            string                   `yaml:"ip"`
+	Port          string                   `yaml:"port"` // tcp/udp 为端口号，serial 为串口名
+	BaudRate      int                      `yaml:"baudrate"`
+	DataBits      int                      `yaml:"data_bits"`
+	Parity        string                   `yaml:"parity"`
+	StopBits      int                      `yaml:"stop_bits"`
+	Timeout       int                      `yaml:"timeout"`     // 等待响应的超时时间(毫秒)
+	RequestHex    string                   `yaml:"request_hex"` // 兼容旧配置，等同 hex
+	Requests      map[string]requestConfig `yaml:"requests"`
+}
+
+// requestConfig 请求帧：hex（十六进制，可含空格）或 ascii（文本）二选一，均为 text/template 模板，
+// 可用 {{.DeviceID}}、{{.Point}}、{{.Value}}（写入）和 {{bytes "Float AB CD" .Value}}（按 Format 编码为十六进制）。
+// checksum 追加在帧末，suffix（十六进制）追加在校验之后；两者均为空时不发送请求，只等待设备主动上报的帧
+type requestConfig struct {
+	Hex      string          `yaml:"hex"`
+	ASCII    string          `yaml:"ascii"`
+	Checksum *checksumConfig `yaml:"checksum"`
+	Suffix   string          `yaml:"suffix"`
+	Response *responseConfig `yaml:"response"` // 为空时使用设备的 response
+}
+
+// checksumConfig 校验：crc16（Modbus）| lrc | sum8 | xor8，从第 start 字节算到校验值之前。
+// ascii 为 true 时参与计算的数据和校验值都是十六进制字符（如 Modbus ASCII 的 LRC）
+type checksumConfig struct {
+	Type      string `yaml:"type"`
+	Start     int    `yaml:"start"`
+	ByteOrder string `yaml:"byte_order"` // crc16：little(默认) | big
+	ASCII     bool   `yaml:"ascii"`
+}
+
+// responseConfig 响应分帧：
+//   - timeout（默认）：收到数据后静默 idle 毫秒视为一帧结束
+//   - length：固定帧长 length，或按长度字段（length_offset/length_size/length_order）计算，帧长 = 字段值 + length_adjust
+//   - delimiter：以 delimiter（文本）或 delimiter_hex 结尾
+//   - none：不等待响应（仅用于写入）
+//
+// 配置 checksum 时校验响应，delimiter 模式下校验值位于结束符之前，其余模式位于帧末
+type responseConfig struct {
+	Mode         string          `yaml:"mode"`
+	Length       int             `yaml:"length"`
+	LengthOffset int             `yaml:"length_offset"`
+	LengthSize   int             `yaml:"length_size"` // 1 | 2 | 4，为 0 时使用固定帧长
+	LengthOrder  string          `yaml:"length_order"`
+	LengthAdjust int             `yaml:"length_adjust"`
+	Delimiter    string          `yaml:"delimiter"`
+	DelimiterHex string          `yaml:"delimiter_hex"`
+	Idle         int             `yaml:"idle"`     // 默认 50 毫秒
+	MaxSize      int             `yaml:"max_size"` // 默认 4096 字节
+	Checksum     *checksumConfig `yaml:"checksum"`
+
+	delimiter []byte
+}
+
+// decodeConfig 把 YAML 解析出的 map 转换为结构体
+func decodeConfig(config map[string]interface{}, out interface{}) error {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return yaml.Unmarshal(data, out)
+}
+
+func (c *checksumConfig) validate() error {
+	switch c.Type {
+	case "crc16", "lrc", "sum8", "xor8":
+	default:
+		return fmt.Errorf("unsupported checksum type %q", c.Type)
+	}
+	switch strings.ToLower(c.ByteOrder) {
+	case "", "little", "big":
+	default:
+		return fmt.Errorf("invalid checksum byte_order %q", c.ByteOrder)
+	}
+	if c.Start < 0 {
+		return fmt.Errorf("invalid checksum start %d", c.Start)
+	}
+	return nil
+}
+
+// compile 校验响应配置并填充默认值
+func (r *responseConfig) compile() error {
+	if r.Idle <= 0 {
+		r.Idle = 50
+	}
+	if r.MaxSize <= 0 {
+		r.MaxSize = 4096
+	}
+	switch r.Mode {
+	case "", "timeout", "none":
+	case "length":
+		if r.LengthSize == 0 && r.Length <= 0 {
+			return fmt.Errorf("length response requires length or length_size")
+		}
+		switch r.LengthSize {
+		case 0, 1, 2, 4:
+		default:
+			return fmt.Errorf("invalid length_size %d", r.LengthSize)
+		}
+	case "delimiter":
+		r.delimiter = []byte(r.Delimiter)
+		if r.DelimiterHex != "" {
+			b, err := parseHex(r.DelimiterHex)
+			if err != nil {
+				return fmt.Errorf("invalid delimiter_hex: %v", err)
+			}
+			r.delimiter = b
+		}
+		if len(r.delimiter) == 0 {
+			return fmt.Errorf("delimiter response requires delimiter or delimiter_hex")
+		}
+	default:
+		return fmt.Errorf("unsupported response mode %q", r.Mode)
+	}
+	if r.Checksum != nil {
+		return r.Checksum.validate()
+	}
+	return nil
+}
+
+// compiledRequest 解析好模板的请求
+type compiledRequest struct {
+	body     *template.Template
+	ascii    bool
+	checksum *checksumConfig
+	suffix   []byte
+	response *responseConfig
+}
+
+// templateData 请求模板可用的数据
+type templateData struct {
+	DeviceID string
+	Point    string
+	Value    interface{}
+}
+
+var templateFuncs = template.FuncMap{
+	"bytes": formatHex,
+}
+
+// formatHex 按 Format 编码写入值，返回十六进制字符串
+func formatHex(format string, value interface{}) (string, error) {
+	var b []byte
+	var err error
+	switch strings.ToUpper(format) {
+	case "UINT8", "INT8":
+		v, ok := utils.ToFloat64(value)
+		if !ok || v < -128 || v > 255 {
+			return "", fmt.Errorf("format %s: invalid value %v", format, value)
+		}
+		b = []byte{byte(int(v))}
+	default:
+		b, err = utils.EncodeFormat(format, value)
+	}
+	return hex.EncodeToString(b), err
+}
+
+func compileRequest(name string, rc requestConfig, response *responseConfig) (*compiledRequest, error) {
+	if rc.Hex != "" && rc.ASCII != "" {
+		return nil, fmt.Errorf("raw request %s: hex and ascii are exclusive", name)
+	}
+	text := rc.Hex
+	cr := &compiledRequest{checksum: rc.Checksum, response: response}
+	if rc.ASCII != "" {
+		text, cr.ascii = rc.ASCII, true
+	}
+	t, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("raw request %s: %v", name, err)
+	}
+	cr.body = t
+	if cr.checksum != nil {
+		if err := cr.checksum.validate(); err != nil {
+			return nil, fmt.Errorf("raw request %s: %v", name, err)
+		}
+	}
+	if rc.Suffix != "" {
+		if cr.suffix, err = parseHex(rc.Suffix); err != nil {
+			return nil, fmt.Errorf("raw request %s: invalid suffix: %v", name, err)
+		}
+	}
+	if rc.Response != nil {
+		r := *rc.Response
+		if err := r.compile(); err != nil {
+			return nil, fmt.Errorf("raw request %s: %v", name, err)
+		}
+		cr.response = &r
+	}
+	return cr, nil
+}
+
+// build 渲染模板并追加校验和后缀，返回完整的请求帧
+func (cr *compiledRequest) build(data templateData) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := cr.body.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	frame := buf.Bytes()
+	if !cr.ascii {
+		b, err := parseHex(buf.String())
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex request: %v", err)
+		}
+		frame = b
+	}
+	if cr.checksum != nil {
+		sum, err := cr.checksum.compute(frame)
+		if err != nil {
+			return nil, err
+		}
+		frame = append(frame, sum...)
+	}
+	return append(frame, cr.suffix...), nil
+}
+
+// parseHex 解析十六进制字符串，忽略空白和 0x 前缀
+func parseHex(s string) ([]byte, error) {
+	s = strings.ReplaceAll(s, "0x", "")
+	s = strings.ReplaceAll(s, "0X", "")
+	s = strings.Join(strings.Fields(s), "")
+	return hex.DecodeString(s)
+}
+
+func (c deviceConfig) timeout() time.Duration {
+	if c.Timeout > 0 {
+		return time.Duration(c.Timeout) * time.Millisecond
+	}
+	return 2 * time.Second
+}
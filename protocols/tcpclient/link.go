@@ -0,0 +1,285 @@
+package tcpclient
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goburrow/serial"
+)
+
+// serialPoll 串口单次读取的等待时间，分帧逻辑按总超时循环读取
+const serialPoll = 20 * time.Millisecond
+
+// port 底层连接：TCP、UDP 或串口
+type port interface {
+	Write(b []byte) (int, error)
+	// readTimeout 最多等待 d 读取数据，超时返回 0, nil
+	readTimeout(b []byte, d time.Duration) (int, error)
+	Close() error
+}
+
+type netPort struct{ net.Conn }
+
+func (p netPort) readTimeout(b []byte, d time.Duration) (int, error) {
+	p.SetReadDeadline(time.Now().Add(d))
+	n, err := p.Read(b)
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return n, nil
+	}
+	return n, err
+}
+
+type serialPort struct{ serial.Port }
+
+func (p serialPort) readTimeout(b []byte, d time.Duration) (int, error) {
+	deadline := time.Now().Add(d)
+	for {
+		n, err := p.Read(b)
+		if err == serial.ErrTimeout {
+			err = nil
+		}
+		if n > 0 || err != nil || !time.Now().Before(deadline) {
+			return n, err
+		}
+	}
+}
+
+// link 一条连接，同一时刻只有一个请求/响应事务。串口按串口名在多个设备间共享
+type link struct {
+	mu   sync.Mutex
+	conf deviceConfig
+	port port
+	refs int
+}
+
+var (
+	serialLinks   = make(map[string]*link)
+	serialLinksMu sync.Mutex
+)
+
+// acquireLink 打开连接；串口已被其他设备打开时复用，串口参数以首次打开时为准
+func acquireLink(conf deviceConfig) (*link, error) {
+	if conf.Transport != "serial" {
+		l := &link{conf: conf, refs: 1}
+		return l, l.open()
+	}
+	serialLinksMu.Lock()
+	defer serialLinksMu.Unlock()
+	if l, ok := serialLinks[conf.Port]; ok {
+		l.refs++
+		return l, nil
+	}
+	l := &link{conf: conf, refs: 1}
+	if err := l.open(); err != nil {
+		return nil, err
+	}
+	serialLinks[conf.Port] = l
+	return l, nil
+}
+
+// release 释放引用，最后一个设备关闭时关闭连接
+func (l *link) release() error {
+	if l.conf.Transport == "serial" {
+		serialLinksMu.Lock()
+		defer serialLinksMu.Unlock()
+		if l.refs--; l.refs > 0 {
+			return nil
+		}
+		delete(serialLinks, l.conf.Port)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.closePort()
+}
+
+func (l *link) open() error {
+	c := l.conf
+	switch c.Transport {
+	case "tcp", "udp":
+		conn, err := net.DialTimeout(c.Transport, net.JoinHostPort(c.IP, c.Port), c.timeout())
+		if err != nil {
+			return err
+		}
+		l.port = netPort{conn}
+	case "serial":
+		p, err := serial.Open(&serial.Config{
+			Address:  c.Port,
+			BaudRate: c.BaudRate,
+			DataBits: c.DataBits,
+			StopBits: c.StopBits,
+			Parity:   c.Parity,
+			Timeout:  serialPoll,
+		})
+		if err != nil {
+			return err
+		}
+		l.port = serialPort{p}
+		log.Printf("[RAW] 打开串口 %s %d %d%s%d", c.Port, c.BaudRate, c.DataBits, c.Parity, c.StopBits)
+	default:
+		return fmt.Errorf("unsupported transport %q", c.Transport)
+	}
+	return nil
+}
+
+func (l *link) closePort() error {
+	if l.port == nil {
+		return nil
+	}
+	err := l.port.Close()
+	l.port = nil
+	return err
+}
+
+// reconnect 关闭并重新打开连接
+func (l *link) reconnect() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closePort()
+	return l.open()
+}
+
+// transact 发送请求并按 resp 分帧读取一帧响应。req 为空时不发送，只等待设备主动上报的帧；
+// resp 为 none 时发送后立即返回
+func (l *link) transact(req []byte, resp *responseConfig) ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.port == nil {
+		if err := l.open(); err != nil {
+			return nil, err
+		}
+	}
+	drained := l.drain()
+	if len(req) > 0 {
+		if _, err := l.port.Write(req); err != nil {
+			return nil, err
+		}
+	}
+	if resp.Mode == "none" {
+		return nil, nil
+	}
+	deadline := time.Now().Add(l.conf.timeout())
+	frame, err := readFrame(l.port, resp, deadline)
+	// 主动上报的设备清空缓冲后第一帧可能不完整，丢弃后取下一帧
+	if err == nil && len(req) == 0 && drained && resp.Mode == "delimiter" {
+		frame, err = readFrame(l.port, resp, deadline)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if resp.Checksum != nil {
+		body := frame
+		if resp.Mode == "delimiter" {
+			body = frame[:len(frame)-len(resp.delimiter)]
+		}
+		if err := resp.Checksum.verify(body); err != nil {
+			return nil, err
+		}
+	}
+	return frame, nil
+}
+
+// drain 丢弃上次事务残留的数据，返回是否丢弃了数据
+func (l *link) drain() bool {
+	buf := make([]byte, 1024)
+	drained := false
+	for i := 0; i < 64; i++ {
+		n, err := l.port.readTimeout(buf, time.Millisecond)
+		if n == 0 || err != nil {
+			break
+		}
+		drained = true
+	}
+	return drained
+}
+
+// readFrame 按分帧配置读取一帧，超过 deadline 仍未收齐时返回错误
+func readFrame(p port, r *responseConfig, deadline time.Time) ([]byte, error) {
+	var frame []byte
+	buf := make([]byte, 1024)
+	for {
+		wait := time.Until(deadline)
+		if r.Mode == "" || r.Mode == "timeout" {
+			if len(frame) > 0 && wait > time.Duration(r.Idle)*time.Millisecond {
+				wait = time.Duration(r.Idle) * time.Millisecond
+			}
+		}
+		if wait <= 0 {
+			if len(frame) == 0 {
+				return nil, fmt.Errorf("raw: response timeout")
+			}
+			if r.Mode == "" || r.Mode == "timeout" {
+				return frame, nil
+			}
+			return nil, fmt.Errorf("raw: incomplete frame (% X)", frame)
+		}
+		n, err := p.readTimeout(buf, wait)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			if (r.Mode == "" || r.Mode == "timeout") && len(frame) > 0 {
+				return frame, nil
+			}
+			continue
+		}
+		frame = append(frame, buf[:n]...)
+		if size, ok, err := frameSize(frame, r); err != nil {
+			return nil, err
+		} else if ok {
+			return frame[:size], nil
+		}
+		if len(frame) > r.MaxSize {
+			return nil, fmt.Errorf("raw: frame exceeds max_size %d", r.MaxSize)
+		}
+	}
+}
+
+// frameSize 判断已收到的数据是否包含完整的一帧，返回帧长
+func frameSize(frame []byte, r *responseConfig) (int, bool, error) {
+	switch r.Mode {
+	case "length":
+		size := r.Length
+		if r.LengthSize > 0 {
+			end := r.LengthOffset + r.LengthSize
+			if len(frame) < end {
+				return 0, false, nil
+			}
+			field := frame[r.LengthOffset:end]
+			var v uint32
+			switch r.LengthSize {
+			case 1:
+				v = uint32(field[0])
+			case 2:
+				if strings.ToLower(r.LengthOrder) == "little" {
+					v = uint32(binary.LittleEndian.Uint16(field))
+				} else {
+					v = uint32(binary.BigEndian.Uint16(field))
+				}
+			case 4:
+				if strings.ToLower(r.LengthOrder) == "little" {
+					v = binary.LittleEndian.Uint32(field)
+				} else {
+					v = binary.BigEndian.Uint32(field)
+				}
+			}
+			size = int(v) + r.LengthAdjust
+			if size < end || size > r.MaxSize {
+				return 0, false, fmt.Errorf("raw: invalid frame length %d", size)
+			}
+		}
+		return size, len(frame) >= size, nil
+	case "delimiter":
+		if i := bytes.Index(frame, r.delimiter); i >= 0 {
+			return i + len(r.delimiter), true, nil
+		}
+	}
+	return 0, false, nil
+}
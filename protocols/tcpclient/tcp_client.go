@@ -1,76 +1,273 @@
 package tcpclient
 
 import (
-	"net"
-	"sensor-edge/protocols"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"sensor-edge/protocols"
+	"sensor-edge/utils"
 )
 
+// TCPClient 通用原始报文驱动，支持 TCP、UDP 和串口。请求帧由十六进制或文本模板生成并追加校验，
+// 响应按长度、结束符或静默超时分帧，点位按偏移/长度/Format 或正则从响应帧中取值。
+// 点位分组的 function 选择 requests 中的具名请求，为空或不存在时使用默认请求
 type TCPClient struct {
-	conn    net.Conn
-	ip      string
-	port    string
-	request []byte
+	conf     deviceConfig
+	link     *link
+	requests map[string]*compiledRequest // "" 为默认请求
+
+	pmu    sync.RWMutex
+	points map[string]pointTable // 设备 ID -> 点位配置
 }
 
+// pointTable 按地址和点位名索引的点位配置
+type pointTable map[string]protocols.PointConfig
+
+// Init 连接参数：
+//
+//	transport: tcp          # tcp(默认) | udp | serial
+//	ip: 192.168.1.50        # tcp/udp
+//	port: 4001              # tcp/udp 为端口号，serial 为串口名（如 /dev/ttyUSB0）
+//	baudrate: 9600          # serial
+//	data_bits: 8
+//	parity: N               # N | E | O
+//	stop_bits: 1
+//	timeout: 2000           # 等待响应的超时时间(毫秒)
+//	hex: "01 03 00 00 00 02"        # 默认请求，或 ascii: "R\r\n"
+//	checksum: {type: crc16}         # crc16 | lrc | sum8 | xor8
+//	response: {mode: length, length_offset: 2, length_size: 1, length_adjust: 5, checksum: {type: crc16}}
+//	requests:                       # 具名请求，点位分组的 function 引用
+//	  weight: {ascii: "SI\r\n", response: {mode: delimiter, delimiter: "\r\n"}}
+//
+// 点位的写入请求在点位配置的 write 中定义（格式同 requests），{{.Value}} 为写入值
 func (t *TCPClient) Init(config map[string]interface{}) error {
-	t.ip = config["ip"].(string)
-	t.port = config["port"].(string)
-	t.request = []byte(config["request_hex"].(string)) // 示例："010300000002C40B"
+	var conf deviceConfig
+	if err := decodeConfig(config, &conf); err != nil {
+		return fmt.Errorf("raw: invalid config: %v", err)
+	}
+	if conf.Hex == "" {
+		conf.Hex = conf.RequestHex
+	}
+	switch conf.Transport = strings.ToLower(conf.Transport); conf.Transport {
+	case "":
+		conf.Transport = "tcp"
+		fallthrough
+	case "tcp", "udp":
+		if conf.IP == "" {
+			return fmt.Errorf("raw: ip is required")
+		}
+		if _, err := strconv.Atoi(conf.Port); err != nil {
+			return fmt.Errorf("raw: invalid port %q", conf.Port)
+		}
+	case "serial":
+		if conf.Port == "" {
+			return fmt.Errorf("raw: serial port is required")
+		}
+		if conf.BaudRate == 0 {
+			conf.BaudRate = 9600
+		}
+		if conf.DataBits == 0 {
+			conf.DataBits = 8
+		}
+		if conf.StopBits == 0 {
+			conf.StopBits = 1
+		}
+		switch strings.ToUpper(conf.Parity) {
+		case "", "N", "NONE":
+			conf.Parity = "N"
+		case "E", "EVEN":
+			conf.Parity = "E"
+		case "O", "ODD":
+			conf.Parity = "O"
+		default:
+			return fmt.Errorf("raw: invalid parity %q", conf.Parity)
+		}
+	default:
+		return fmt.Errorf("raw: unsupported transport %q", conf.Transport)
+	}
 
-	var err error
-	t.conn, err = net.DialTimeout("tcp", t.ip+":"+t.port, 3*time.Second)
-	return err
+	response := &responseConfig{}
+	if conf.Response != nil {
+		response = conf.Response
+	}
+	if err := response.compile(); err != nil {
+		return fmt.Errorf("raw: %v", err)
+	}
+	requests := make(map[string]*compiledRequest)
+	defaultReq := conf.requestConfig
+	defaultReq.Response = nil
+	cr, err := compileRequest("default", defaultReq, response)
+	if err != nil {
+		return err
+	}
+	requests[""] = cr
+	for name, rc := range conf.Requests {
+		cr, err := compileRequest(name, rc, response)
+		if err != nil {
+			return err
+		}
+		requests[name] = cr
+	}
+	l, err := acquireLink(conf)
+	if err != nil {
+		return err
+	}
+	t.conf, t.link, t.requests = conf, l, requests
+	return nil
 }
 
-func (t *TCPClient) Read(deviceID string) ([]protocols.PointValue, error) {
-	t.conn.Write(t.request)
-
-	buf := make([]byte, 1024)
-	n, err := t.conn.Read(buf)
-	if err != nil || n < 7 {
-		return nil, err
+// SetPointConfigs 记录设备的点位配置（Format、group、write）
+func (t *TCPClient) SetPointConfigs(deviceID string, points []protocols.PointConfig) {
+	table := make(pointTable, len(points)*2)
+	for _, p := range points {
+		if p.Address != "" {
+			table[p.Address] = p
+		}
+		if p.PointID != "" {
+			table[p.PointID] = p
+		}
 	}
+	t.pmu.Lock()
+	defer t.pmu.Unlock()
+	if t.points == nil {
+		t.points = make(map[string]pointTable)
+	}
+	t.points[deviceID] = table
+}
 
-	val := int(buf[3])<<8 + int(buf[4])
-	return []protocols.PointValue{
-		{
-			PointID:   "tcp_data",
-			Value:     val,
-			Quality:   "good",
-			Timestamp: time.Now().Unix(),
-		},
-	}, nil
+// Read 用默认请求读取设备配置的全部点位，PointID 为点位名
+func (t *TCPClient) Read(deviceID string) ([]protocols.PointValue, error) {
+	t.pmu.RLock()
+	var names []string
+	for key, p := range t.points[deviceID] {
+		if key == p.PointID {
+			names = append(names, key)
+		}
+	}
+	t.pmu.RUnlock()
+	sort.Strings(names)
+	values, err := t.readPoints(deviceID, "", names)
+	for i := range values {
+		values[i].PointID = names[i]
+	}
+	return values, err
 }
 
-// ReadBatch 批量读取接口，返回指定数据点
+// ReadBatch 发送 function 对应的请求，从响应帧中按点位地址取值，PointID 为地址。
+// 通信失败或响应校验失败时全部点位为 bad 并返回错误；地址无效或超出帧长的点位为 bad
 func (t *TCPClient) ReadBatch(deviceID string, function string, points []string) ([]protocols.PointValue, error) {
-	// function 参数暂未用到，保留兼容
 	if len(points) == 0 {
 		return nil, nil
 	}
-	var values []protocols.PointValue
-	for _, pt := range points {
-		t.conn.Write(t.request)
-		buf := make([]byte, 1024)
-		n, err := t.conn.Read(buf)
-		if err != nil || n < 7 {
-			values = append(values, protocols.PointValue{PointID: pt, Quality: "bad", Value: nil, Timestamp: time.Now().Unix()})
+	return t.readPoints(deviceID, function, points)
+}
+
+// readPoints keys 为点位地址或点位名，返回值的 PointID 与 keys 一一对应
+func (t *TCPClient) readPoints(deviceID, function string, keys []string) ([]protocols.PointValue, error) {
+	values := make([]protocols.PointValue, len(keys))
+	cr, ok := t.requests[function]
+	if !ok {
+		cr = t.requests[""]
+	}
+	frame, err := t.request(cr, templateData{DeviceID: deviceID})
+	t.pmu.RLock()
+	table := t.points[deviceID]
+	t.pmu.RUnlock()
+	for i, key := range keys {
+		if err != nil {
+			values[i] = badValue(key)
 			continue
 		}
-		val := int(buf[3])<<8 + int(buf[4])
-		values = append(values, protocols.PointValue{PointID: pt, Quality: "good", Value: val, Timestamp: time.Now().Unix()})
+		addr, pc := key, table[key]
+		if pc.Address != "" {
+			addr = pc.Address
+		}
+		p, perr := utils.ParseRawPoint(addr, pc.Format, pc.Options)
+		if perr != nil {
+			values[i] = badValue(key)
+			continue
+		}
+		v, perr := p.Extract(frame)
+		if perr != nil {
+			values[i] = badValue(key)
+			continue
+		}
+		values[i] = goodValue(key, v)
 	}
-	return values, nil
+	return values, err
 }
 
-func (t *TCPClient) Close() error {
-	return t.conn.Close()
+func (t *TCPClient) request(cr *compiledRequest, data templateData) ([]byte, error) {
+	req, err := cr.build(data)
+	if err != nil {
+		return nil, err
+	}
+	return t.link.transact(req, cr.response)
 }
 
+// Write 按点位配置中的 write 请求模板写入，point 为点位地址或点位名；
+// 响应按 write 中的 response（默认同设备）分帧并校验，response 为 none 时不等待响应
 func (t *TCPClient) Write(point string, value interface{}) error {
+	deviceID, p, ok := t.lookupPoint(point)
+	if !ok {
+		return fmt.Errorf("raw: point %s not configured", point)
+	}
+	raw, ok := p.Options["write"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("raw: point %s has no write request", point)
+	}
+	var rc requestConfig
+	if err := decodeConfig(raw, &rc); err != nil {
+		return fmt.Errorf("raw: point %s: invalid write request: %v", point, err)
+	}
+	if rc.Hex == "" && rc.ASCII == "" {
+		return fmt.Errorf("raw: point %s: write request requires hex or ascii", point)
+	}
+	cr, err := compileRequest(p.PointID+".write", rc, t.requests[""].response)
+	if err != nil {
+		return err
+	}
+	_, err = t.request(cr, templateData{DeviceID: deviceID, Point: p.PointID, Value: value})
+	return err
+}
 
-	return nil // TODO: 实现写操作
+func (t *TCPClient) lookupPoint(point string) (string, protocols.PointConfig, bool) {
+	t.pmu.RLock()
+	defer t.pmu.RUnlock()
+	for deviceID, table := range t.points {
+		if p, ok := table[point]; ok {
+			return deviceID, p, true
+		}
+	}
+	return "", protocols.PointConfig{}, false
+}
+
+func (t *TCPClient) Close() error {
+	if t.link == nil {
+		return nil
+	}
+	err := t.link.release()
+	t.link = nil
+	return err
+}
+
+func (t *TCPClient) Reconnect() error {
+	if t.link == nil {
+		return fmt.Errorf("raw: not initialized")
+	}
+	return t.link.reconnect()
+}
+
+func goodValue(id string, v interface{}) protocols.PointValue {
+	return protocols.PointValue{PointID: id, Value: v, Quality: "good", Timestamp: time.Now().Unix()}
+}
+
+func badValue(id string) protocols.PointValue {
+	return protocols.PointValue{PointID: id, Value: nil, Quality: "bad", Timestamp: time.Now().Unix()}
 }
 
 func NewTCPClient() protocols.Protocol {
@@ -79,13 +276,5 @@ func NewTCPClient() protocols.Protocol {
 
 func init() {
 	protocols.Register("tcpclient", NewTCPClient)
+	protocols.Register("raw", NewTCPClient)
 }
-
-func (t *TCPClient) Reconnect() error {
-	if t.conn != nil {
-		t.conn.Close() // 先关闭旧连接
-	}
-	var err error
-	t.conn, err = net.DialTimeout("tcp", t.ip+":"+t.port, 3*time.Second)
-	return err
-}	
\ No newline at end of file
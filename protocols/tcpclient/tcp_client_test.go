@@ -0,0 +1,257 @@
+package tcpclient
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"sensor-edge/protocols"
+)
+
+func TestChecksum(t *testing.T) {
+	frame := []byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x02}
+	cases := []struct {
+		c    checksumConfig
+		data []byte
+		want string
+	}{
+		{checksumConfig{Type: "crc16"}, frame, "C40B"},
+		{checksumConfig{Type: "crc16", ByteOrder: "big"}, frame, "0BC4"},
+		{checksumConfig{Type: "lrc"}, frame, "FA"},
+		{checksumConfig{Type: "sum8"}, frame, "06"},
+		{checksumConfig{Type: "xor8"}, frame, "00"},
+		// Modbus ASCII：跳过起始符 ':'，LRC 以十六进制字符追加
+		{checksumConfig{Type: "lrc", Start: 1, ASCII: true}, []byte(":010300000002"), "4641"},
+	}
+	for _, c := range cases {
+		sum, err := c.c.compute(c.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := fmt.Sprintf("%X", sum); got != c.want {
+			t.Errorf("%+v: got %s, want %s", c.c, got, c.want)
+		}
+		if err := c.c.verify(append(append([]byte(nil), c.data...), sum...)); err != nil {
+			t.Errorf("%+v: verify: %v", c.c, err)
+		}
+	}
+	if err := (&checksumConfig{Type: "crc16"}).verify([]byte{0x01, 0x03, 0x00, 0x00, 0x00, 0x02, 0xC4, 0x0C}); err == nil {
+		t.Error("expect crc mismatch")
+	}
+}
+
+// meter 模拟 Modbus RTU over TCP 仪表：功能码 03 返回 4 字节（float 23.5），功能码 06 原样回显；
+// corrupt 为 true 时响应的 CRC 错误
+type meter struct {
+	ln      net.Listener
+	mu      sync.Mutex
+	writes  []string
+	corrupt bool
+}
+
+func newMeter(t *testing.T) *meter {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &meter{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go m.serve(conn)
+		}
+	}()
+	return m
+}
+
+func (m *meter) serve(conn net.Conn) {
+	defer conn.Close()
+	sum := checksumConfig{Type: "crc16"}
+	buf := make([]byte, 256)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return
+		}
+		req := buf[:n]
+		if len(req) != 8 || sum.verify(req) != nil {
+			continue
+		}
+		var resp []byte
+		switch req[1] {
+		case 0x03:
+			resp = []byte{req[0], 0x03, 0x04, 0x41, 0xBC, 0x00, 0x00}
+		case 0x06:
+			m.mu.Lock()
+			m.writes = append(m.writes, fmt.Sprintf("% X", req[:6]))
+			m.mu.Unlock()
+			resp = append([]byte(nil), req[:6]...)
+		}
+		crc, _ := sum.compute(resp)
+		m.mu.Lock()
+		if m.corrupt {
+			crc[0]++
+		}
+		m.mu.Unlock()
+		// 分两次发送，验证按长度字段分帧
+		conn.Write(resp[:3])
+		conn.Write(append(resp[3:], crc...))
+	}
+}
+
+func TestTCPLengthFrame(t *testing.T) {
+	m := newMeter(t)
+	c := &TCPClient{}
+	err := c.Init(map[string]interface{}{
+		"ip":          "127.0.0.1",
+		"port":        m.ln.Addr().(*net.TCPAddr).Port,
+		"request_hex": "01 03 00 00 00 02",
+		"checksum":    map[string]interface{}{"type": "crc16"},
+		"response": map[string]interface{}{
+			"mode": "length", "length_offset": 2, "length_size": 1, "length_adjust": 5,
+			"checksum": map[string]interface{}{"type": "crc16"},
+		},
+		"timeout": 500,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetPointConfigs("meter_1", []protocols.PointConfig{
+		{PointID: "temperature", Address: "3", Format: "Float AB CD"},
+		{PointID: "setpoint", Address: "3:2", Format: "UINT", Options: map[string]interface{}{
+			"write": map[string]interface{}{
+				"hex":      `01 06 00 10 {{bytes "UINT" .Value}}`,
+				"checksum": map[string]interface{}{"type": "crc16"},
+				"response": map[string]interface{}{"mode": "length", "length": 8, "checksum": map[string]interface{}{"type": "crc16"}},
+			},
+		}},
+	})
+
+	values, err := c.ReadBatch("meter_1", "", []string{"3", "3:2", "3.6", "2", "9:2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"23.5 good", "16828 good", "true good", "4 good", "<nil> bad"}
+	for i, v := range values {
+		if got := fmt.Sprint(v.Value, " ", v.Quality); got != want[i] {
+			t.Errorf("%s = %s, want %s", v.PointID, got, want[i])
+		}
+	}
+
+	values, err = c.Read("meter_1")
+	if err != nil || len(values) != 2 || values[1].PointID != "temperature" || values[1].Value != float32(23.5) {
+		t.Errorf("Read: %+v, %v", values, err)
+	}
+
+	if err := c.Write("setpoint", 500); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Write("temperature", 1); err == nil {
+		t.Error("write without template: expect error")
+	}
+	m.mu.Lock()
+	if len(m.writes) != 1 || m.writes[0] != "01 06 00 10 01 F4" {
+		t.Errorf("writes: %q", m.writes)
+	}
+	m.corrupt = true
+	m.mu.Unlock()
+
+	values, err = c.ReadBatch("meter_1", "", []string{"3"})
+	if err == nil || !strings.Contains(err.Error(), "checksum") || values[0].Quality != "bad" || values[0].Value != nil {
+		t.Errorf("expect checksum error and bad value, got %+v, %v", values, err)
+	}
+
+	m.ln.Close()
+	c.Reconnect()
+	values, err = c.ReadBatch("meter_1", "", []string{"3"})
+	if err == nil || values[0].Quality != "bad" {
+		t.Errorf("expect error and bad value after server closed, got %+v, %v", values, err)
+	}
+}
+
+// TestUDPScale 模拟 ASCII 电子秤：收到 SI 命令后返回一行重量，另一条命令返回分两包发送、无结束符的状态
+func TestUDPScale(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			switch string(buf[:n]) {
+			case "SI\r\n":
+				pc.WriteTo([]byte("ST,GS,+0012.34kg\r\n"), addr)
+			case "ID1\r\n":
+				pc.WriteTo([]byte("ID:SCALE"), addr)
+				pc.WriteTo([]byte("-07"), addr)
+			}
+		}
+	}()
+
+	c := &TCPClient{}
+	err = c.Init(map[string]interface{}{
+		"transport": "udp",
+		"ip":        "127.0.0.1",
+		"port":      strconv.Itoa(pc.LocalAddr().(*net.UDPAddr).Port),
+		"ascii":     "SI\r\n",
+		"response":  map[string]interface{}{"mode": "delimiter", "delimiter": "\r\n"},
+		"requests": map[string]interface{}{
+			"id": map[string]interface{}{"ascii": "ID{{.DeviceID}}\r\n", "response": map[string]interface{}{"idle": 100}},
+		},
+		"timeout": 500,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetPointConfigs("1", []protocols.PointConfig{
+		{PointID: "unit", Address: `re:([a-z]+)\r\n`},
+		{PointID: "stable", Address: `re:^(ST|US),`},
+		{PointID: "model", Address: "0:8", Format: "STRING"},
+	})
+
+	values, err := c.ReadBatch("1", "", []string{`re:GS,\s*([-+\d.]+)`, `re:([a-z]+)\r\n`, `re:^(ST|US),`, `re:NET`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"12.34 good", "kg good", "ST good", "<nil> bad"}
+	for i, v := range values {
+		if got := fmt.Sprint(v.Value, " ", v.Quality); got != want[i] {
+			t.Errorf("%s = %s, want %s", v.PointID, got, want[i])
+		}
+	}
+
+	values, err = c.ReadBatch("1", "id", []string{"0:8", `re:-(\d+)$`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if values[0].Value != "ID:SCALE" || values[1].Value != 7.0 {
+		t.Errorf("id values: %+v", values)
+	}
+
+	for _, bad := range []map[string]interface{}{
+		{"port": 502},
+		{"ip": "127.0.0.1", "port": "x"},
+		{"transport": "can", "ip": "127.0.0.1", "port": 1},
+		{"ip": "127.0.0.1", "port": 1, "hex": "01", "ascii": "A"},
+		{"ip": "127.0.0.1", "port": 1, "checksum": map[string]interface{}{"type": "crc32"}},
+		{"ip": "127.0.0.1", "port": 1, "response": map[string]interface{}{"mode": "delimiter"}},
+	} {
+		if err := (&TCPClient{}).Init(bad); err == nil {
+			t.Errorf("%v: expect error", bad)
+		}
+	}
+}
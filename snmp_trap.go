@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+
+	"sensor-edge/config"
+	"sensor-edge/edgecompute"
+	"sensor-edge/protocols/snmp"
+	"sensor-edge/types"
+	"sensor-edge/uplink"
+)
+
+// startSnmpTrapReceiver 启动 Trap 接收：规则映射出的点位值推进边缘规则，
+// 与 Trap 报警一起按采集数据的上报路径立即发送
+func startSnmpTrapReceiver(conf types.SnmpTrapConfig, re *edgecompute.RuleEngine, uplinkMgr *uplink.UplinkManager) {
+	rulesFile := conf.RulesFile
+	if rulesFile == "" {
+		rulesFile = "configs/snmp_traps.yaml"
+	}
+	rules, err := config.LoadSnmpTrapRules(rulesFile)
+	if err != nil {
+		fmt.Printf("[SNMP] 加载 Trap 规则失败: %v\n", err)
+		return
+	}
+	receiver, err := snmp.NewTrapReceiver(rules, func(e snmp.TrapEvent) {
+		fmt.Printf("[SNMP] 收到 Trap %s (来源 %s) -> 设备 %s: %v\n", e.TrapOID, e.Source, e.DeviceID, e.Values)
+		alarms := e.Alarms
+		if re != nil && len(e.Values) > 0 {
+			re.ApplyRules(e.DeviceID, e.Values)
+			alarms = append(alarms, re.LastAlarms...)
+		}
+		payload := uplink.EncodeDataReport(e.DeviceID, e.Values, alarms, nil)
+		if err := uplinkMgr.SendToAll(payload); err != nil {
+			fmt.Printf("[Error] 设备 %s Trap 上报失败: %v\n", e.DeviceID, err)
+		}
+	})
+	if err != nil {
+		fmt.Printf("[SNMP] Trap 规则无效: %v\n", err)
+		return
+	}
+	listen := conf.Listen
+	if listen == "" {
+		listen = ":162"
+	}
+	if err := receiver.Start(listen); err != nil {
+		fmt.Printf("[SNMP] Trap 接收启动失败: %v\n", err)
+		return
+	}
+	fmt.Printf("[SNMP] Trap 接收已启动: %s, 规则 %d 条\n", listen, len(rules.Rules))
+}
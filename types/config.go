@@ -8,5 +8,26 @@ type DeviceConfig struct {
 }
 
 type Config struct {
-	Devices []DeviceConfig `yaml:"devices"`
+	Devices         []DeviceConfig        `yaml:"devices"`
+	DebugAddr       string                `yaml:"debug_addr"` // 调试 HTTP 服务监听地址，为空不启用
+	BacnetDiscovery BacnetDiscoveryConfig `yaml:"bacnet_discovery"`
+	SnmpTrap        SnmpTrapConfig        `yaml:"snmp_trap"`
+}
+
+// BacnetDiscoveryConfig BACnet Who-Is/I-Am 设备发现
+type BacnetDiscoveryConfig struct {
+	Enable        bool   `yaml:"enable"`
+	Listen        string `yaml:"listen"`         // 本地监听地址，默认 :47808
+	Broadcast     string `yaml:"broadcast"`      // Who-Is 目的地址，默认 255.255.255.255:47808
+	InstanceRange string `yaml:"instance_range"` // 设备实例号范围，如 "1000-2000"，为空发现全部
+	Wait          int    `yaml:"wait"`           // 等待 I-Am 的秒数，默认 3
+	PointsFile    string `yaml:"points_file"`    // 为新设备生成的点位配置写入该文件，为空不生成
+	ProtocolName  string `yaml:"protocol_name"`  // 新设备使用的 protocols.yaml 中 bacnet 参数实例名，默认第一个
+}
+
+// SnmpTrapConfig SNMP Trap/Inform 接收
+type SnmpTrapConfig struct {
+	Enable    bool   `yaml:"enable"`
+	Listen    string `yaml:"listen"`     // 本地监听地址，默认 :162
+	RulesFile string `yaml:"rules_file"` // 映射规则文件，默认 configs/snmp_traps.yaml
 }
@@ -15,6 +15,8 @@ type PointMapping struct {
 	Transform string    `yaml:"transform"` // 转换表达式
 	Format    string    `yaml:"format"`    // 格式化类型（如 INT、Long AB CD 等）
 	Alarm     AlarmRule `yaml:"alarm"`
+	// Options 协议专有的点位字段（如 BACnet 的 object_type/instance/property），由驱动自行解析
+	Options map[string]interface{} `yaml:",inline"`
 }
 
 type DevicePointSet struct {
@@ -0,0 +1,42 @@
+package types
+
+// SnmpTrapRules SNMP Trap/Inform 接收配置（configs/snmp_traps.yaml）
+type SnmpTrapRules struct {
+	Communities []string       `yaml:"communities"` // v1/v2c 允许的 community，为空不校验
+	EngineID    string         `yaml:"engine_id"`   // 本机 SNMPv3 引擎 ID（十六进制），v3 Inform 以接收方为权威引擎
+	Users       []SnmpTrapUser `yaml:"users"`       // v3 用户
+	Rules       []SnmpTrapRule `yaml:"rules"`
+}
+
+// SnmpTrapUser SNMPv3 USM 用户，security_level 由发送方报文决定
+type SnmpTrapUser struct {
+	SecurityName   string `yaml:"security_name"`
+	AuthProtocol   string `yaml:"auth_protocol"` // MD5 | SHA | SHA224 | SHA256 | SHA384 | SHA512，为空不认证
+	AuthPassphrase string `yaml:"auth_passphrase"`
+	PrivProtocol   string `yaml:"priv_protocol"` // DES | AES | AES192 | AES256 | AES192C | AES256C，为空不加密
+	PrivPassphrase string `yaml:"priv_passphrase"`
+}
+
+// SnmpTrapRule 按 Trap OID 和来源地址匹配 Trap，把变量映射为设备点位并产生报警。
+// OID 匹配自身及其子树；一个 Trap 可匹配多条规则
+type SnmpTrapRule struct {
+	TrapOID  string          `yaml:"trap_oid"` // v2c/v3 为 snmpTrapOID.0，v1 按 RFC 3584 转换（enterprise.0.specific）
+	Source   string          `yaml:"source"`   // 发送方 IP（v1 为 agent-addr），为空不限
+	DeviceID string          `yaml:"device_id"`
+	Points   []SnmpTrapPoint `yaml:"points"`
+	Alarm    *SnmpTrapAlarm  `yaml:"alarm"`
+	Values   map[string]any  `yaml:"values"` // 匹配时写入的固定点位值（如 on_battery: true）
+}
+
+// SnmpTrapPoint 变量 OID 到点位名的映射，变量 OID 在其子树下时点位名为 <name>.<索引>
+type SnmpTrapPoint struct {
+	OID  string `yaml:"oid"`
+	Name string `yaml:"name"`
+}
+
+// SnmpTrapAlarm 规则匹配时产生的报警，message 中的 {点位名}、{source}、{trap_oid} 替换为实际值
+type SnmpTrapAlarm struct {
+	Name    string `yaml:"name"`
+	Level   string `yaml:"level"`
+	Message string `yaml:"message"`
+}
@@ -0,0 +1,100 @@
+package bacnetserver
+
+import (
+	"fmt"
+	"os"
+
+	"sensor-edge/protocols/bacnet"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Mapping 北向 BACnet/IP 设备的对象映射配置，示例见 configs/bacnet_server.yaml
+type Mapping struct {
+	Device  DeviceConfig    `yaml:"device"`
+	Objects []ObjectBinding `yaml:"objects"`
+}
+
+// DeviceConfig 网关对外呈现的设备对象
+type DeviceConfig struct {
+	Instance         uint32 `yaml:"instance"`
+	Name             string `yaml:"name"`
+	Description      string `yaml:"description"`
+	VendorID         uint16 `yaml:"vendor_id"`
+	VendorName       string `yaml:"vendor_name"`
+	ModelName        string `yaml:"model_name"`
+	FirmwareRevision string `yaml:"firmware_revision"`
+}
+
+// ObjectBinding 一个采集点位到虚拟对象的映射
+type ObjectBinding struct {
+	DeviceID     string   `yaml:"device_id"`
+	Point        string   `yaml:"point"`  // 点位名（points.yaml 中的 name）
+	Object       string   `yaml:"object"` // 对象，如 analogValue:1，仅支持 analogValue/binaryValue/multiStateValue
+	Name         string   `yaml:"name"`   // objectName，为空时取 device_id_point
+	Description  string   `yaml:"description"`
+	Units        string   `yaml:"units"`         // analogValue 的单位，如 ℃、kW 或工程单位枚举值
+	COVIncrement float64  `yaml:"cov_increment"` // analogValue 的 COV 阈值，0 表示任何变化都通知
+	ActiveText   string   `yaml:"active_text"`   // binaryValue 的状态文本
+	InactiveText string   `yaml:"inactive_text"` // binaryValue 的状态文本
+	States       int      `yaml:"states"`        // multiStateValue 的状态数，配置了 state_text 时取其长度
+	StateText    []string `yaml:"state_text"`    // multiStateValue 的状态文本，依次对应状态 1..N
+
+	object bacnet.ServerObject
+}
+
+// LoadMapping 读取并校验映射文件
+func LoadMapping(file string) (*Mapping, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var m Mapping
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if err := m.resolve(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// resolve 解析对象标识和单位，并检查同一点位或对象是否重复映射
+func (m *Mapping) resolve() error {
+	points := make(map[string]bool)
+	for i := range m.Objects {
+		b := &m.Objects[i]
+		if b.DeviceID == "" || b.Point == "" {
+			return fmt.Errorf("mapping #%d: device_id and point are required", i+1)
+		}
+		key := b.DeviceID + "." + b.Point
+		if points[key] {
+			return fmt.Errorf("mapping %s: point mapped twice", key)
+		}
+		points[key] = true
+		obj, err := bacnet.ParseObjectID(b.Object)
+		if err != nil {
+			return fmt.Errorf("mapping %s: %v", key, err)
+		}
+		units, err := bacnet.ParseUnits(b.Units)
+		if err != nil {
+			return fmt.Errorf("mapping %s: %v", key, err)
+		}
+		name := b.Name
+		if name == "" {
+			name = b.DeviceID + "_" + b.Point
+		}
+		b.object = bacnet.ServerObject{
+			Object:       obj,
+			Name:         name,
+			Description:  b.Description,
+			Units:        units,
+			COVIncrement: b.COVIncrement,
+			ActiveText:   b.ActiveText,
+			InactiveText: b.InactiveText,
+			States:       b.States,
+			StateText:    b.StateText,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package bacnetserver
+
+import (
+	"encoding/json"
+	"log"
+
+	"sensor-edge/protocols/bacnet"
+	"sensor-edge/schema"
+)
+
+// Server 北向 BACnet/IP 设备：把采集点位映射为 analogValue/binaryValue/multiStateValue 对象，
+// 供 BMS 前端通过 Who-Is、ReadProperty、ReadPropertyMultiple 和 COV 访问。
+// 实现 uplink.Uplink 接口，采集主流程上报的 DataReport 经 Send 刷新对象的 presentValue
+type Server struct {
+	name    string
+	srv     *bacnet.Server
+	byPoint map[string]bacnet.ObjectID // 设备ID/点位名 -> 对象
+}
+
+// NewServer 监听 listen 地址（默认 :47808）并开始服务
+func NewServer(name, listen string, mapping *Mapping) (*Server, error) {
+	objects := make([]bacnet.ServerObject, len(mapping.Objects))
+	byPoint := make(map[string]bacnet.ObjectID, len(mapping.Objects))
+	for i, b := range mapping.Objects {
+		objects[i] = b.object
+		byPoint[b.DeviceID+"/"+b.Point] = b.object.Object
+	}
+	d := mapping.Device
+	srv, err := bacnet.NewServer(listen, bacnet.ServerDevice{
+		Instance:         d.Instance,
+		Name:             d.Name,
+		Description:      d.Description,
+		VendorID:         d.VendorID,
+		VendorName:       d.VendorName,
+		ModelName:        d.ModelName,
+		FirmwareRevision: d.FirmwareRevision,
+	}, objects)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{name: name, srv: srv, byPoint: byPoint}, nil
+}
+
+func (s *Server) Name() string { return s.name }
+func (s *Server) Type() string { return "bacnet_server" }
+
+// Send 接收 DataReport，刷新映射对象的 presentValue；nil 值（采集失败）保留上次的值并置 fault
+func (s *Server) Send(data []byte) error {
+	var report schema.DataReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return err
+	}
+	for point, value := range report.Data {
+		obj, ok := s.byPoint[report.DeviceID+"/"+point]
+		if !ok {
+			continue
+		}
+		if err := s.srv.Update(obj, value); err != nil {
+			log.Printf("[BACNET-SERVER] %s 点位 %s.%s 更新失败: %v", s.name, report.DeviceID, point, err)
+		}
+	}
+	return nil
+}
+
+// Close 停止服务
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
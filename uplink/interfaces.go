@@ -16,3 +16,12 @@ type Uplink interface {
 type Formatter interface {
 	Format(data map[string]interface{}) ([]byte, error)
 }
+
+// PointWriter 将北向写入转发到南向设备的指定点位
+type PointWriter = func(deviceID, point string, value interface{}) error
+
+// Writable 是可选接口：接受北向写入的通道（如 Modbus 从站）实现该接口，
+// 由主流程注入写入回调
+type Writable interface {
+	SetPointWriter(w PointWriter)
+}
@@ -6,10 +6,13 @@ import (
 	"log"
 	"os"
 	"sensor-edge/config"
+	"sensor-edge/uplink/bacnetserver"
 	httpuplink "sensor-edge/uplink/http"
 	kafkauplink "sensor-edge/uplink/kafka"
+	"sensor-edge/uplink/modbusserver"
 	mqttlink "sensor-edge/uplink/mqtt"
 	natsuplink "sensor-edge/uplink/nats"
+	"sensor-edge/uplink/opcuaserver"
 	redisuplink "sensor-edge/uplink/redis"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
@@ -54,12 +57,57 @@ func NewUplinkManagerFromConfig(cfgs []config.UplinkConfig) *UplinkManager {
 			uplinks = append(uplinks, &natsuplink.NatsUplink{}) // 生产应传入连接参数
 		case "redis":
 			uplinks = append(uplinks, &redisuplink.RedisUplink{}) // 生产应传入连接参数
+		case "modbus_server":
+			mapping, err := modbusserver.LoadMapping(c.Mapping)
+			if err != nil {
+				fmt.Println("[Uplink] Modbus server mapping error:", err)
+				continue
+			}
+			server, err := modbusserver.NewServer(c.Name, c.Listen, mapping)
+			if err != nil {
+				fmt.Println("[Uplink] Modbus server listen error:", err)
+				continue
+			}
+			uplinks = append(uplinks, server)
+		case "bacnet_server":
+			mapping, err := bacnetserver.LoadMapping(c.Mapping)
+			if err != nil {
+				fmt.Println("[Uplink] BACnet server mapping error:", err)
+				continue
+			}
+			server, err := bacnetserver.NewServer(c.Name, c.Listen, mapping)
+			if err != nil {
+				fmt.Println("[Uplink] BACnet server listen error:", err)
+				continue
+			}
+			uplinks = append(uplinks, server)
+		case "opcua_server":
+			mapping, err := opcuaserver.LoadMapping(c.Mapping)
+			if err != nil {
+				fmt.Println("[Uplink] OPC UA server mapping error:", err)
+				continue
+			}
+			server, err := opcuaserver.NewServer(c.Name, c.Listen, mapping)
+			if err != nil {
+				fmt.Println("[Uplink] OPC UA server listen error:", err)
+				continue
+			}
+			uplinks = append(uplinks, server)
 			// 可扩展其他协议
 		}
 	}
 	return &UplinkManager{uplinks: uplinks}
 }
 
+// SetPointWriter 为支持北向写入的通道注入写入回调
+func (m *UplinkManager) SetPointWriter(w PointWriter) {
+	for _, up := range m.uplinks {
+		if wu, ok := up.(Writable); ok {
+			wu.SetPointWriter(w)
+		}
+	}
+}
+
 func (m *UplinkManager) SendToAll(payload []byte) error {
 	data := make(map[string]interface{})
 	for _, up := range m.uplinks {
@@ -0,0 +1,95 @@
+package modbusserver
+
+import (
+	"fmt"
+	"os"
+
+	"sensor-edge/protocols/modbus"
+	"sensor-edge/utils"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Mapping 北向 Modbus 从站的点位映射配置，示例见 configs/modbus_server.yaml
+type Mapping struct {
+	UnitID int            `yaml:"unit_id"` // 响应的单元号，0 表示响应任意单元号
+	Points []PointBinding `yaml:"points"`
+}
+
+// PointBinding 一个采集点位到从站地址的映射
+type PointBinding struct {
+	DeviceID string `yaml:"device_id"`
+	Point    string `yaml:"point"`    // 点位名（points.yaml 中的 name）
+	Address  string `yaml:"address"`  // 从站地址，写法同南向：40001/30001/00001/10001 或 hr:0/ir:0/co:0/di:0
+	Format   string `yaml:"format"`   // 寄存器编码格式（Float AB CD 等），为空按单寄存器整数
+	Writable bool   `yaml:"writable"` // 允许 SCADA 写入并转发到源设备，仅线圈和保持寄存器可写
+
+	addr  modbus.Address
+	count int // 占用的寄存器（或位）数量
+}
+
+// LoadMapping 读取并校验映射文件
+func LoadMapping(file string) (*Mapping, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var m Mapping
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if err := m.resolve(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// resolve 解析地址和格式，并检查地址是否重叠
+func (m *Mapping) resolve() error {
+	if m.UnitID < 0 || m.UnitID > 255 {
+		return fmt.Errorf("invalid unit_id: %d", m.UnitID)
+	}
+	used := make(map[modbus.Area]map[int]string)
+	for i := range m.Points {
+		b := &m.Points[i]
+		if b.DeviceID == "" || b.Point == "" {
+			return fmt.Errorf("mapping #%d: device_id and point are required", i+1)
+		}
+		addr, err := modbus.ParseAddress(b.Address)
+		if err != nil {
+			return fmt.Errorf("mapping %s.%s: %v", b.DeviceID, b.Point, err)
+		}
+		if addr.Area == modbus.AreaUnknown {
+			return fmt.Errorf("mapping %s.%s: address %s must specify area", b.DeviceID, b.Point, b.Address)
+		}
+		if addr.HasBit() {
+			return fmt.Errorf("mapping %s.%s: bit address %s not supported", b.DeviceID, b.Point, b.Address)
+		}
+		if b.Writable && (addr.Area == modbus.AreaDiscreteInput || addr.Area == modbus.AreaInputRegister) {
+			return fmt.Errorf("mapping %s.%s: %s is read-only", b.DeviceID, b.Point, addr.Area)
+		}
+		b.addr = addr
+		b.count = 1
+		if !addr.Area.IsBit() && b.Format != "" {
+			f := utils.CanonicalFormat(b.Format)
+			if f == "" {
+				return fmt.Errorf("mapping %s.%s: unsupported format %s", b.DeviceID, b.Point, b.Format)
+			}
+			b.Format = f
+			b.count = utils.FormatSize(f) / 2
+		}
+		if int(addr.Offset)+b.count > 65536 {
+			return fmt.Errorf("mapping %s.%s: address %s out of range", b.DeviceID, b.Point, b.Address)
+		}
+		if used[addr.Area] == nil {
+			used[addr.Area] = make(map[int]string)
+		}
+		for off := int(addr.Offset); off < int(addr.Offset)+b.count; off++ {
+			if other, ok := used[addr.Area][off]; ok {
+				return fmt.Errorf("mapping %s.%s: address %s overlaps %s", b.DeviceID, b.Point, b.Address, other)
+			}
+			used[addr.Area][off] = b.DeviceID + "." + b.Point
+		}
+	}
+	return nil
+}
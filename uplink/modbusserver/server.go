@@ -0,0 +1,424 @@
+package modbusserver
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"strings"
+	"sync"
+
+	"sensor-edge/protocols/modbus"
+	"sensor-edge/schema"
+	"sensor-edge/utils"
+)
+
+// Modbus 功能码与异常码
+const (
+	fcReadCoils              = 0x01
+	fcReadDiscreteInputs     = 0x02
+	fcReadHoldingRegisters   = 0x03
+	fcReadInputRegisters     = 0x04
+	fcWriteSingleCoil        = 0x05
+	fcWriteSingleRegister    = 0x06
+	fcWriteMultipleCoils     = 0x0F
+	fcWriteMultipleRegisters = 0x10
+
+	exIllegalFunction    = 0x01
+	exIllegalDataAddress = 0x02
+	exIllegalDataValue   = 0x03
+	exServerDeviceFailed = 0x04
+	exGatewayNoResponse  = 0x0B
+)
+
+// Server 北向 Modbus TCP 从站：以采集数据刷新寄存器表供 SCADA/HMI 轮询，
+// 并把 SCADA 对可写点位的写入转发到源设备。实现 uplink.Uplink 接口，
+// 采集主流程上报的 DataReport 经 Send 进入寄存器表
+type Server struct {
+	name   string
+	unitID byte
+	ln     net.Listener
+	writer func(deviceID, point string, value interface{}) error
+
+	mu       sync.RWMutex
+	coils    []bool
+	discrete []bool
+	holding  []uint16
+	input    []uint16
+	byPoint  map[string]*PointBinding                 // 设备ID/点位名 -> 映射
+	owners   map[modbus.Area]map[uint16]*PointBinding // 数据区 -> 地址 -> 映射
+}
+
+// NewServer 监听 listen 地址并开始服务，mapping 为点位映射
+func NewServer(name, listen string, mapping *Mapping) (*Server, error) {
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		name:     name,
+		unitID:   byte(mapping.UnitID),
+		ln:       ln,
+		coils:    make([]bool, 65536),
+		discrete: make([]bool, 65536),
+		holding:  make([]uint16, 65536),
+		input:    make([]uint16, 65536),
+		byPoint:  make(map[string]*PointBinding),
+		owners:   make(map[modbus.Area]map[uint16]*PointBinding),
+	}
+	for i := range mapping.Points {
+		b := &mapping.Points[i]
+		s.byPoint[b.DeviceID+"/"+b.Point] = b
+		if s.owners[b.addr.Area] == nil {
+			s.owners[b.addr.Area] = make(map[uint16]*PointBinding)
+		}
+		for off := 0; off < b.count; off++ {
+			s.owners[b.addr.Area][b.addr.Offset+uint16(off)] = b
+		}
+	}
+	log.Printf("[MODBUS-SERVER] %s 监听 %s，映射点位 %d 个", name, ln.Addr(), len(mapping.Points))
+	go s.serve()
+	return s, nil
+}
+
+// Addr 实际监听地址
+func (s *Server) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+func (s *Server) Name() string { return s.name }
+func (s *Server) Type() string { return "modbus_server" }
+
+// SetPointWriter 注入写入回调，未注入时可写点位的写请求返回异常码04
+func (s *Server) SetPointWriter(w func(deviceID, point string, value interface{}) error) {
+	s.writer = w
+}
+
+// Send 接收 DataReport，刷新映射点位的寄存器；nil 值（采集失败）保持上次的值
+func (s *Server) Send(data []byte) error {
+	var report schema.DataReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for point, value := range report.Data {
+		b, ok := s.byPoint[report.DeviceID+"/"+point]
+		if !ok || value == nil {
+			continue
+		}
+		if err := s.store(b, value); err != nil {
+			log.Printf("[MODBUS-SERVER] %s 点位 %s.%s 编码失败: %v", s.name, b.DeviceID, b.Point, err)
+		}
+	}
+	return nil
+}
+
+// Close 停止监听
+func (s *Server) Close() error {
+	return s.ln.Close()
+}
+
+// store 将点位值编码写入寄存器表，调用方需持有写锁
+func (s *Server) store(b *PointBinding, value interface{}) error {
+	if b.addr.Area.IsBit() {
+		on, err := toBool(value)
+		if err != nil {
+			return err
+		}
+		if b.addr.Area == modbus.AreaCoil {
+			s.coils[b.addr.Offset] = on
+		} else {
+			s.discrete[b.addr.Offset] = on
+		}
+		return nil
+	}
+	raw, err := encodeValue(b.Format, value)
+	if err != nil {
+		return err
+	}
+	table := s.holding
+	if b.addr.Area == modbus.AreaInputRegister {
+		table = s.input
+	}
+	for i := 0; i < len(raw)/2; i++ {
+		table[int(b.addr.Offset)+i] = binary.BigEndian.Uint16(raw[2*i:])
+	}
+	return nil
+}
+
+// encodeValue 按格式编码寄存器字节；整数格式先四舍五入（采集值经 Transform 后可能带小数），
+// 未配置格式时按单寄存器整数，负数按补码
+func encodeValue(format string, value interface{}) ([]byte, error) {
+	if b, ok := value.(bool); ok {
+		value = 0
+		if b {
+			value = 1
+		}
+	}
+	f, ok := utils.ToFloat64(value)
+	if !ok {
+		return nil, fmt.Errorf("unsupported value type: %T", value)
+	}
+	if format == "" {
+		format = utils.FormatUInt
+		if f < 0 {
+			format = utils.FormatInt
+		}
+	}
+	if !strings.HasPrefix(format, "Float") && !strings.HasPrefix(format, "Double") {
+		f = math.Round(f)
+	}
+	return utils.EncodeFormat(format, f)
+}
+
+// decodeValue 将 SCADA 写入的寄存器字节按格式解码，未配置格式时按 UINT
+func decodeValue(format string, raw []byte) (interface{}, error) {
+	if format == "" {
+		format = utils.FormatUInt
+	}
+	v, err := utils.ParseFormat(format, raw)
+	if err != nil {
+		return nil, err
+	}
+	if f, ok := v.(float32); ok {
+		return float64(f), nil
+	}
+	return v, nil
+}
+
+func toBool(value interface{}) (bool, error) {
+	if b, ok := value.(bool); ok {
+		return b, nil
+	}
+	f, ok := utils.ToFloat64(value)
+	if !ok {
+		return false, fmt.Errorf("unsupported value type: %T", value)
+	}
+	return f != 0, nil
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handleConn(conn)
+	}
+}
+
+// handleConn 处理一个 SCADA 连接：MBAP 头（事务号、协议号、长度、单元号）+ PDU
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+	header := make([]byte, 7)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		length := int(binary.BigEndian.Uint16(header[4:6]))
+		if binary.BigEndian.Uint16(header[2:4]) != 0 || length < 2 || length > 254 {
+			log.Printf("[MODBUS-SERVER] %s 非法 MBAP 头，断开 %s", s.name, conn.RemoteAddr())
+			return
+		}
+		pdu := make([]byte, length-1)
+		if _, err := io.ReadFull(conn, pdu); err != nil {
+			return
+		}
+		var resp []byte
+		if s.unitID != 0 && header[6] != s.unitID {
+			resp = exception(pdu[0], exGatewayNoResponse)
+		} else {
+			resp = s.handle(pdu)
+		}
+		out := make([]byte, 7, 7+len(resp))
+		copy(out, header[:4])
+		binary.BigEndian.PutUint16(out[4:6], uint16(len(resp)+1))
+		out[6] = header[6]
+		if _, err := conn.Write(append(out, resp...)); err != nil {
+			return
+		}
+	}
+}
+
+func exception(fc, code byte) []byte {
+	return []byte{fc | 0x80, code}
+}
+
+// handle 处理一个请求 PDU，返回响应 PDU
+func (s *Server) handle(pdu []byte) []byte {
+	fc := pdu[0]
+	if len(pdu) < 5 {
+		return exception(fc, exIllegalDataValue)
+	}
+	addr := binary.BigEndian.Uint16(pdu[1:3])
+	value := binary.BigEndian.Uint16(pdu[3:5])
+	switch fc {
+	case fcReadCoils, fcReadDiscreteInputs:
+		return s.readBits(fc, addr, value)
+	case fcReadHoldingRegisters, fcReadInputRegisters:
+		return s.readRegisters(fc, addr, value)
+	case fcWriteSingleCoil:
+		if value != 0xFF00 && value != 0x0000 {
+			return exception(fc, exIllegalDataValue)
+		}
+		if code := s.writeCoils(addr, []bool{value == 0xFF00}); code != 0 {
+			return exception(fc, code)
+		}
+		return pdu[:5]
+	case fcWriteSingleRegister:
+		if code := s.writeRegisters(addr, pdu[3:5]); code != 0 {
+			return exception(fc, code)
+		}
+		return pdu[:5]
+	case fcWriteMultipleCoils:
+		if len(pdu) < 6 || value < 1 || value > 1968 || int(pdu[5]) != (int(value)+7)/8 || len(pdu) != 6+int(pdu[5]) {
+			return exception(fc, exIllegalDataValue)
+		}
+		bits := make([]bool, value)
+		for i := range bits {
+			bits[i] = pdu[6+i/8]>>(uint(i)%8)&0x01 == 0x01
+		}
+		if code := s.writeCoils(addr, bits); code != 0 {
+			return exception(fc, code)
+		}
+		return pdu[:5]
+	case fcWriteMultipleRegisters:
+		if len(pdu) < 6 || value < 1 || value > 123 || int(pdu[5]) != 2*int(value) || len(pdu) != 6+int(pdu[5]) {
+			return exception(fc, exIllegalDataValue)
+		}
+		if code := s.writeRegisters(addr, pdu[6:]); code != 0 {
+			return exception(fc, code)
+		}
+		return pdu[:5]
+	default:
+		return exception(fc, exIllegalFunction)
+	}
+}
+
+func (s *Server) readBits(fc byte, addr, quantity uint16) []byte {
+	if quantity < 1 || quantity > 2000 {
+		return exception(fc, exIllegalDataValue)
+	}
+	if int(addr)+int(quantity) > 65536 {
+		return exception(fc, exIllegalDataAddress)
+	}
+	table := s.coils
+	if fc == fcReadDiscreteInputs {
+		table = s.discrete
+	}
+	n := (int(quantity) + 7) / 8
+	resp := make([]byte, 2+n)
+	resp[0], resp[1] = fc, byte(n)
+	s.mu.RLock()
+	for i := 0; i < int(quantity); i++ {
+		if table[int(addr)+i] {
+			resp[2+i/8] |= 1 << (uint(i) % 8)
+		}
+	}
+	s.mu.RUnlock()
+	return resp
+}
+
+func (s *Server) readRegisters(fc byte, addr, quantity uint16) []byte {
+	if quantity < 1 || quantity > 125 {
+		return exception(fc, exIllegalDataValue)
+	}
+	if int(addr)+int(quantity) > 65536 {
+		return exception(fc, exIllegalDataAddress)
+	}
+	table := s.holding
+	if fc == fcReadInputRegisters {
+		table = s.input
+	}
+	resp := make([]byte, 2, 2+2*int(quantity))
+	resp[0], resp[1] = fc, byte(2*quantity)
+	s.mu.RLock()
+	for i := 0; i < int(quantity); i++ {
+		resp = binary.BigEndian.AppendUint16(resp, table[int(addr)+i])
+	}
+	s.mu.RUnlock()
+	return resp
+}
+
+// writeCoils 写入的每个线圈都须映射为可写点位，逐个转发到源设备
+func (s *Server) writeCoils(addr uint16, bits []bool) byte {
+	if int(addr)+len(bits) > 65536 {
+		return exIllegalDataAddress
+	}
+	s.mu.RLock()
+	targets := make([]*PointBinding, len(bits))
+	for i := range bits {
+		b := s.owners[modbus.AreaCoil][addr+uint16(i)]
+		if b == nil || !b.Writable {
+			s.mu.RUnlock()
+			return exIllegalDataAddress
+		}
+		targets[i] = b
+	}
+	s.mu.RUnlock()
+	for i, b := range targets {
+		if err := s.forward(b, bits[i]); err != nil {
+			return exServerDeviceFailed
+		}
+		s.mu.Lock()
+		s.coils[b.addr.Offset] = bits[i]
+		s.mu.Unlock()
+	}
+	return 0
+}
+
+// writeRegisters 写入范围须完整覆盖可写点位的全部寄存器（多寄存器格式不能只写一半），
+// 按点位格式解码后逐个转发到源设备
+func (s *Server) writeRegisters(addr uint16, raw []byte) byte {
+	quantity := len(raw) / 2
+	if int(addr)+quantity > 65536 {
+		return exIllegalDataAddress
+	}
+	var targets []*PointBinding
+	s.mu.RLock()
+	for off := 0; off < quantity; {
+		b := s.owners[modbus.AreaHoldingRegister][addr+uint16(off)]
+		if b == nil || !b.Writable || int(b.addr.Offset) != int(addr)+off || off+b.count > quantity {
+			s.mu.RUnlock()
+			return exIllegalDataAddress
+		}
+		targets = append(targets, b)
+		off += b.count
+	}
+	s.mu.RUnlock()
+	for _, b := range targets {
+		start := 2 * (int(b.addr.Offset) - int(addr))
+		chunk := raw[start : start+2*b.count]
+		value, err := decodeValue(b.Format, chunk)
+		if err != nil {
+			return exIllegalDataValue
+		}
+		if err := s.forward(b, value); err != nil {
+			return exServerDeviceFailed
+		}
+		s.mu.Lock()
+		for i := 0; i < b.count; i++ {
+			s.holding[int(b.addr.Offset)+i] = binary.BigEndian.Uint16(chunk[2*i:])
+		}
+		s.mu.Unlock()
+	}
+	return 0
+}
+
+// forward 将写入转发到源设备
+func (s *Server) forward(b *PointBinding, value interface{}) error {
+	if s.writer == nil {
+		return fmt.Errorf("point writer not configured")
+	}
+	err := s.writer(b.DeviceID, b.Point, value)
+	if err != nil {
+		log.Printf("[MODBUS-SERVER] %s 写入 %s.%s=%v 失败: %v", s.name, b.DeviceID, b.Point, value, err)
+	} else {
+		log.Printf("[MODBUS-SERVER] %s 写入 %s.%s=%v", s.name, b.DeviceID, b.Point, value)
+	}
+	return err
+}
@@ -0,0 +1,111 @@
+package modbusserver
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"sensor-edge/schema"
+
+	"github.com/goburrow/modbus"
+)
+
+func report(deviceID string, data map[string]interface{}) []byte {
+	buf, _ := json.Marshal(schema.DataReport{DeviceID: deviceID, Data: data})
+	return buf
+}
+
+func TestServerReadWrite(t *testing.T) {
+	mapping := &Mapping{UnitID: 1, Points: []PointBinding{
+		{DeviceID: "dev1", Point: "temp", Address: "30001", Format: "float ab cd"},
+		{DeviceID: "dev1", Point: "level", Address: "40001", Format: "INT", Writable: true},
+		{DeviceID: "dev2", Point: "setpoint", Address: "hr:10", Format: "Float CD AB", Writable: true},
+		{DeviceID: "dev2", Point: "pump", Address: "00001", Writable: true},
+		{DeviceID: "dev2", Point: "alarm", Address: "10001"},
+	}}
+	if err := mapping.resolve(); err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	s, err := NewServer("test", "127.0.0.1:0", mapping)
+	if err != nil {
+		t.Fatalf("NewServer failed: %v", err)
+	}
+	defer s.Close()
+	writes := map[string]interface{}{}
+	s.SetPointWriter(func(deviceID, point string, value interface{}) error {
+		if point == "level" && value == int16(-1) {
+			return errors.New("device rejected")
+		}
+		writes[deviceID+"."+point] = value
+		return nil
+	})
+	s.Send(report("dev1", map[string]interface{}{"temp": 21.5, "level": -3.2, "other": 1}))
+	s.Send(report("dev2", map[string]interface{}{"alarm": true, "pump": nil}))
+
+	handler := modbus.NewTCPClientHandler(s.Addr().String())
+	handler.SlaveId = 1
+	handler.Timeout = time.Second
+	defer handler.Close()
+	client := modbus.NewClient(handler)
+
+	regs, err := client.ReadInputRegisters(0, 2)
+	if err != nil {
+		t.Fatalf("read input registers failed: %v", err)
+	}
+	if f := math.Float32frombits(binary.BigEndian.Uint32(regs)); f != 21.5 {
+		t.Errorf("temp: got %v, want 21.5", f)
+	}
+	regs, err = client.ReadHoldingRegisters(0, 1)
+	if err != nil || int16(binary.BigEndian.Uint16(regs)) != -3 {
+		t.Errorf("level: got %v %v, want -3", regs, err)
+	}
+	bits, err := client.ReadDiscreteInputs(0, 1)
+	if err != nil || bits[0] != 1 {
+		t.Errorf("alarm: got %v %v, want on", bits, err)
+	}
+
+	// Float CD AB：低字在前
+	raw := make([]byte, 4)
+	binary.BigEndian.PutUint32(raw, math.Float32bits(26.5))
+	if _, err := client.WriteMultipleRegisters(10, 2, []byte{raw[2], raw[3], raw[0], raw[1]}); err != nil {
+		t.Fatalf("write setpoint failed: %v", err)
+	}
+	if writes["dev2.setpoint"] != 26.5 {
+		t.Errorf("setpoint forwarded as %v, want 26.5", writes["dev2.setpoint"])
+	}
+	if _, err := client.WriteSingleCoil(0, 0xFF00); err != nil || writes["dev2.pump"] != true {
+		t.Errorf("write pump: %v, forwarded %v", err, writes["dev2.pump"])
+	}
+
+	expectException := func(err error, code byte) {
+		t.Helper()
+		var me *modbus.ModbusError
+		if !errors.As(err, &me) || me.ExceptionCode != code {
+			t.Errorf("expect exception %d, got %v", code, err)
+		}
+	}
+	// 只写浮点数的一半、写未映射地址、源设备拒绝写入
+	_, err = client.WriteSingleRegister(10, 1)
+	expectException(err, exIllegalDataAddress)
+	_, err = client.WriteSingleRegister(5, 1)
+	expectException(err, exIllegalDataAddress)
+	_, err = client.WriteSingleRegister(0, 0xFFFF)
+	expectException(err, exServerDeviceFailed)
+}
+
+func TestMappingInvalid(t *testing.T) {
+	for _, points := range [][]PointBinding{
+		{{DeviceID: "d", Point: "p", Address: "0x10"}},
+		{{DeviceID: "d", Point: "p", Address: "30001", Writable: true}},
+		{{DeviceID: "d", Point: "p", Address: "40001", Format: "Float AB CD"}, {DeviceID: "d", Point: "q", Address: "40002"}},
+		{{DeviceID: "d", Point: "p", Address: "40001", Format: "Unknown"}},
+	} {
+		m := &Mapping{Points: points}
+		if err := m.resolve(); err == nil {
+			t.Errorf("%+v: expect error", points)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package opcuaserver
+
+import (
+	"fmt"
+	"os"
+
+	"sensor-edge/config"
+	"sensor-edge/protocols/opcua"
+	"sensor-edge/types"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Mapping 北向 OPC UA 服务端配置，示例见 configs/opcua_server.yaml。
+// 地址空间由设备清单和点位表生成：每个设备一个文件夹，每个点位一个变量
+type Mapping struct {
+	EndpointURL      string            `yaml:"endpoint_url"`
+	ApplicationURI   string            `yaml:"application_uri"`
+	ApplicationName  string            `yaml:"application_name"`
+	CertFile         string            `yaml:"cert_file"`
+	KeyFile          string            `yaml:"key_file"`
+	SecurityPolicies []string          `yaml:"security_policies"`
+	Anonymous        bool              `yaml:"anonymous"`
+	Users            map[string]string `yaml:"users"`
+	MaxSessions      int               `yaml:"max_sessions"`
+	Devices          string            `yaml:"devices"` // 设备清单，默认 configs/devices.yaml
+	Points           string            `yaml:"points"`  // 点位表，默认 configs/points.yaml
+
+	folders []opcua.ServerFolder
+}
+
+// LoadMapping 读取配置文件，并按设备清单和点位表生成地址空间
+func LoadMapping(file string) (*Mapping, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var m Mapping
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if m.Devices == "" {
+		m.Devices = "configs/devices.yaml"
+	}
+	if m.Points == "" {
+		m.Points = "configs/points.yaml"
+	}
+	devices, err := config.LoadDevicesFromYAML(m.Devices)
+	if err != nil {
+		return nil, fmt.Errorf("devices %s: %v", m.Devices, err)
+	}
+	sets, err := config.LoadPointMappingsV2(m.Points)
+	if err != nil {
+		return nil, fmt.Errorf("points %s: %v", m.Points, err)
+	}
+	m.folders = buildFolders(devices, sets)
+	return &m, nil
+}
+
+// buildFolders 设备清单中的设备依次成为文件夹，只出现在点位表中的设备追加在后；
+// 同一设备多个功能组中同名的点位只保留第一个
+func buildFolders(devices []types.DeviceConfigWithMeta, sets []types.DevicePointSetV2) []opcua.ServerFolder {
+	var folders []opcua.ServerFolder
+	index := make(map[string]int)
+	for _, d := range devices {
+		if _, ok := index[d.ID]; ok || d.ID == "" {
+			continue
+		}
+		index[d.ID] = len(folders)
+		folders = append(folders, opcua.ServerFolder{Name: d.ID, DisplayName: d.Name, Description: d.Description})
+	}
+	for _, set := range sets {
+		i, ok := index[set.DeviceID]
+		if !ok {
+			i = len(folders)
+			index[set.DeviceID] = i
+			folders = append(folders, opcua.ServerFolder{Name: set.DeviceID})
+		}
+		seen := make(map[string]bool)
+		for _, v := range folders[i].Variables {
+			seen[v.Name] = true
+		}
+		for _, group := range set.Functions {
+			for _, p := range group.Points {
+				if p.Name == "" || seen[p.Name] {
+					continue
+				}
+				seen[p.Name] = true
+				folders[i].Variables = append(folders[i].Variables, variableOf(p))
+			}
+		}
+	}
+	return folders
+}
+
+// variableOf 按点位类型选择变量的数据类型：带转换表达式的整数点位可能得到小数，按 Double 呈现
+func variableOf(p types.PointMapping) opcua.ServerVariable {
+	v := opcua.ServerVariable{Name: p.Name, Unit: p.Unit, DataType: "Double"}
+	switch p.Type {
+	case "bool":
+		v.DataType = "Boolean"
+	case "string":
+		v.DataType = "String"
+	case "int":
+		if p.Transform == "" {
+			v.DataType = "Int64"
+		}
+	}
+	if s, ok := p.Options["description"].(string); ok {
+		v.Description = s
+	}
+	if w, ok := p.Options["writable"].(bool); ok {
+		v.Writable = w
+	}
+	return v
+}
@@ -0,0 +1,70 @@
+package opcuaserver
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"sensor-edge/protocols/opcua"
+	"sensor-edge/schema"
+)
+
+// Server 北向 OPC UA 服务端：MES/SCADA 可浏览 Objects 下的设备文件夹、读取和订阅点位变量，
+// 对可写点位的写入转发到源设备。实现 uplink.Uplink 接口，采集主流程上报的 DataReport 经 Send 刷新变量值
+type Server struct {
+	name string
+	srv  *opcua.Server
+}
+
+// NewServer 监听 listen 地址（默认 :4840）并开始服务
+func NewServer(name, listen string, mapping *Mapping) (*Server, error) {
+	srv, err := opcua.NewServer(opcua.ServerConfig{
+		Listen:           listen,
+		EndpointURL:      mapping.EndpointURL,
+		ApplicationURI:   mapping.ApplicationURI,
+		ApplicationName:  mapping.ApplicationName,
+		CertFile:         mapping.CertFile,
+		KeyFile:          mapping.KeyFile,
+		SecurityPolicies: mapping.SecurityPolicies,
+		Anonymous:        mapping.Anonymous,
+		Users:            mapping.Users,
+		MaxSessions:      mapping.MaxSessions,
+	}, mapping.folders)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{name: name, srv: srv}, nil
+}
+
+func (s *Server) Name() string { return s.name }
+func (s *Server) Type() string { return "opcua_server" }
+
+// SetPointWriter 注入写入回调，未注入时可写变量的写入返回 BadNotWritable
+func (s *Server) SetPointWriter(w func(deviceID, point string, value interface{}) error) {
+	s.srv.SetWriteHandler(w)
+}
+
+// Send 接收 DataReport，以上报时间为源时间戳刷新变量；nil 值（采集失败）的变量状态为 BadNoCommunication
+func (s *Server) Send(data []byte) error {
+	var report schema.DataReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return err
+	}
+	ts := time.Now()
+	if report.Timestamp > 0 {
+		ts = time.Unix(report.Timestamp, 0)
+	}
+	for point, value := range report.Data {
+		// 规则引擎等附加的字段没有对应变量，直接跳过
+		if err := s.srv.Update(report.DeviceID, point, value, ts); err != nil && !errors.Is(err, opcua.ErrUnknownVariable) {
+			log.Printf("[OPCUA-SERVER] %s 点位 %s.%s 更新失败: %v", s.name, report.DeviceID, point, err)
+		}
+	}
+	return nil
+}
+
+// Close 停止服务
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
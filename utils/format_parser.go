@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"math"
+	"strconv"
 	"strings"
 )
 
@@ -47,7 +48,7 @@ func ParseFormat(format string, raw []byte) (interface{}, error) {
 		if len(raw) < 4 {
 			return nil, fmt.Errorf("Long CD AB需4字节")
 		}
-		return int32(binary.LittleEndian.Uint32([]byte{raw[2], raw[3], raw[0], raw[1]})), nil
+		return int32(binary.BigEndian.Uint32([]byte{raw[2], raw[3], raw[0], raw[1]})), nil
 	case FormatLongBADC:
 		if len(raw) < 4 {
 			return nil, fmt.Errorf("Long BA DC需4字节")
@@ -149,3 +150,128 @@ func ParseAndCastFormat(format string, raw interface{}) (interface{}, error) {
 	}
 	return raw, nil
 }
+
+// formatByteOrder 记录各格式在 ParseFormat 中的字节重排方式：
+// ParseFormat 取 raw[order[0]], raw[order[1]]... 按大端解释，EncodeFormat 按相同顺序逆向写回
+var formatByteOrder = map[string][]int{
+	FormatInt:            {0, 1},
+	FormatUInt:           {0, 1},
+	FormatLongABCD:       {0, 1, 2, 3},
+	FormatLongCDAB:       {2, 3, 0, 1},
+	FormatLongBADC:       {1, 0, 3, 2},
+	FormatLongDCBA:       {3, 2, 1, 0},
+	FormatFloatABCD:      {0, 1, 2, 3},
+	FormatFloatCDAB:      {2, 3, 0, 1},
+	FormatFloatBADC:      {1, 0, 3, 2},
+	FormatFloatDCBA:      {3, 2, 1, 0},
+	FormatDoubleABCDEFGH: {0, 1, 2, 3, 4, 5, 6, 7},
+	FormatDoubleGHEFCDAB: {6, 7, 4, 5, 2, 3, 0, 1},
+	FormatDoubleBADCFEHG: {1, 0, 3, 2, 5, 4, 7, 6},
+	FormatDoubleHGFEDCBA: {7, 6, 5, 4, 3, 2, 1, 0},
+}
+
+// CanonicalFormat 返回与 format 忽略大小写匹配的标准格式名，未知格式返回空串
+func CanonicalFormat(format string) string {
+	if _, ok := formatByteOrder[format]; ok {
+		return format
+	}
+	for f := range formatByteOrder {
+		if strings.EqualFold(f, format) {
+			return f
+		}
+	}
+	return ""
+}
+
+// FormatSize 返回格式占用的字节数，未知格式返回0
+func FormatSize(format string) int {
+	return len(formatByteOrder[CanonicalFormat(format)])
+}
+
+// EncodeFormat 是 ParseFormat 的逆操作：将数值按格式编码为原始字节，
+// 超出格式表示范围的值直接报错而不是截断
+func EncodeFormat(format string, value interface{}) ([]byte, error) {
+	f := CanonicalFormat(format)
+	if f == "" {
+		return nil, fmt.Errorf("未知format: %s", format)
+	}
+	v, ok := ToFloat64(value)
+	if !ok {
+		return nil, fmt.Errorf("format %s 不支持的值类型: %T", f, value)
+	}
+	be := make([]byte, len(formatByteOrder[f]))
+	switch f {
+	case FormatInt:
+		if err := checkInteger(f, v, math.MinInt16, math.MaxInt16); err != nil {
+			return nil, err
+		}
+		binary.BigEndian.PutUint16(be, uint16(int16(v)))
+	case FormatUInt:
+		if err := checkInteger(f, v, 0, math.MaxUint16); err != nil {
+			return nil, err
+		}
+		binary.BigEndian.PutUint16(be, uint16(v))
+	case FormatLongABCD, FormatLongCDAB, FormatLongBADC, FormatLongDCBA:
+		if err := checkInteger(f, v, math.MinInt32, math.MaxInt32); err != nil {
+			return nil, err
+		}
+		binary.BigEndian.PutUint32(be, uint32(int32(v)))
+	case FormatFloatABCD, FormatFloatCDAB, FormatFloatBADC, FormatFloatDCBA:
+		if !math.IsNaN(v) && !math.IsInf(v, 0) && math.Abs(v) > math.MaxFloat32 {
+			return nil, fmt.Errorf("值 %v 超出 %s 表示范围", value, f)
+		}
+		binary.BigEndian.PutUint32(be, math.Float32bits(float32(v)))
+	default:
+		binary.BigEndian.PutUint64(be, math.Float64bits(v))
+	}
+	raw := make([]byte, len(be))
+	for i, idx := range formatByteOrder[f] {
+		raw[idx] = be[i]
+	}
+	return raw, nil
+}
+
+func checkInteger(format string, v float64, min, max float64) error {
+	if v != math.Trunc(v) {
+		return fmt.Errorf("值 %v 不是整数，无法按 %s 写入", v, format)
+	}
+	if v < min || v > max {
+		return fmt.Errorf("值 %v 超出 %s 表示范围 [%v, %v]", v, format, min, max)
+	}
+	return nil
+}
+
+// ToFloat64 将常见数值类型（含数字字符串）统一转换为 float64
+func ToFloat64(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestEncodeFormatRoundTrip(t *testing.T) {
+	cases := []struct {
+		format string
+		value  interface{}
+	}{
+		{FormatInt, -1234},
+		{FormatUInt, 65535},
+		{FormatLongABCD, int32(-123456789)},
+		{FormatLongCDAB, 123456789},
+		{FormatLongBADC, -2},
+		{FormatLongDCBA, 70000},
+		{FormatFloatABCD, 12.5},
+		{FormatFloatCDAB, -0.375},
+		{FormatFloatBADC, float32(1e10)},
+		{FormatFloatDCBA, 3.25},
+		{FormatDoubleABCDEFGH, 1.0 / 3},
+		{FormatDoubleGHEFCDAB, -123456.789},
+		{FormatDoubleBADCFEHG, 1e300},
+		{FormatDoubleHGFEDCBA, 42.0},
+	}
+	for _, c := range cases {
+		raw, err := EncodeFormat(c.format, c.value)
+		if err != nil {
+			t.Errorf("%s: encode %v failed: %v", c.format, c.value, err)
+			continue
+		}
+		got, err := ParseFormat(c.format, raw)
+		if err != nil {
+			t.Errorf("%s: parse failed: %v", c.format, err)
+			continue
+		}
+		want, _ := ToFloat64(c.value)
+		gotF, _ := ToFloat64(got)
+		if c.format == FormatFloatABCD || c.format == FormatFloatCDAB || c.format == FormatFloatBADC || c.format == FormatFloatDCBA {
+			want = float64(float32(want))
+		}
+		if gotF != want {
+			t.Errorf("%s: round trip %v -> % x -> %v", c.format, c.value, raw, got)
+		}
+	}
+}
+
+func TestEncodeFormatOutOfRange(t *testing.T) {
+	cases := []struct {
+		format string
+		value  interface{}
+	}{
+		{FormatInt, 32768},
+		{FormatInt, 1.5},
+		{FormatUInt, -1},
+		{FormatUInt, 65536},
+		{FormatLongABCD, int64(1) << 31},
+		{FormatFloatABCD, 1e39},
+		{FormatFloatABCD, "abc"},
+		{"Float XY ZW", 1.0},
+	}
+	for _, c := range cases {
+		if _, err := EncodeFormat(c.format, c.value); err == nil {
+			t.Errorf("%s: value %v should be rejected", c.format, c.value)
+		}
+	}
+}
+
+func TestCanonicalFormat(t *testing.T) {
+	if f := CanonicalFormat("float ab cd"); f != FormatFloatABCD {
+		t.Errorf("expect %s, got %q", FormatFloatABCD, f)
+	}
+	if f := CanonicalFormat("int"); f != FormatInt {
+		t.Errorf("expect %s, got %q", FormatInt, f)
+	}
+	if FormatSize(FormatDoubleGHEFCDAB) != 8 {
+		t.Errorf("Double size should be 8")
+	}
+}
+
+// TestParseLongWordOrder 32 位整数各格式的字节顺序与同名 Float 格式一致
+func TestParseLongWordOrder(t *testing.T) {
+	// 0x12345678 按 AB CD 排列为 12 34 56 78
+	cases := map[string][]byte{
+		FormatLongABCD: {0x12, 0x34, 0x56, 0x78},
+		FormatLongCDAB: {0x56, 0x78, 0x12, 0x34},
+		FormatLongBADC: {0x34, 0x12, 0x78, 0x56},
+		FormatLongDCBA: {0x78, 0x56, 0x34, 0x12},
+	}
+	for format, raw := range cases {
+		got, err := ParseFormat(format, raw)
+		if err != nil || got != int32(0x12345678) {
+			t.Errorf("%s: got %v, %v", format, got, err)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONPath 编译后的 JSONPath 表达式，支持的语法：
+//
+//	$.a.b、$['a']["b"]     子节点
+//	$.items[0]、[-1]       下标（负数从末尾计）
+//	[0,2]、['a','b']       多个下标或键
+//	[1:3]、[:2]            切片
+//	.*、[*]                全部子节点
+//	..name、..*            递归查找
+//	[?(@.id == 'x')]       过滤：@ 后可跟多级字段，运算符 == != < <= > >=，省略运算符判断字段存在
+type JSONPath struct {
+	expr     string
+	segments []jsonSegment
+}
+
+type jsonSegment struct {
+	recursive bool
+	wildcard  bool
+	keys      []string
+	indices   []int
+	slice     *[2]*int
+	filter    *jsonFilter
+}
+
+type jsonFilter struct {
+	field []string
+	op    string
+	value interface{}
+}
+
+// CompileJSONPath 解析 JSONPath 表达式
+func CompileJSONPath(expr string) (*JSONPath, error) {
+	s := strings.TrimSpace(expr)
+	if !strings.HasPrefix(s, "$") {
+		return nil, fmt.Errorf("jsonpath %q: must start with $", expr)
+	}
+	p := &JSONPath{expr: expr}
+	i := 1
+	for i < len(s) {
+		var seg jsonSegment
+		switch {
+		case strings.HasPrefix(s[i:], ".."):
+			seg.recursive = true
+			i += 2
+			if i < len(s) && s[i] == '[' {
+				n, err := parseBracket(s[i:], &seg)
+				if err != nil {
+					return nil, fmt.Errorf("jsonpath %q: %v", expr, err)
+				}
+				i += n
+				break
+			}
+			i += parseName(s[i:], &seg)
+		case s[i] == '.':
+			i++
+			i += parseName(s[i:], &seg)
+		case s[i] == '[':
+			n, err := parseBracket(s[i:], &seg)
+			if err != nil {
+				return nil, fmt.Errorf("jsonpath %q: %v", expr, err)
+			}
+			i += n
+		default:
+			return nil, fmt.Errorf("jsonpath %q: unexpected %q at %d", expr, s[i], i)
+		}
+		if !seg.wildcard && seg.keys == nil && seg.indices == nil && seg.slice == nil && seg.filter == nil {
+			return nil, fmt.Errorf("jsonpath %q: empty segment at %d", expr, i)
+		}
+		p.segments = append(p.segments, seg)
+	}
+	return p, nil
+}
+
+// parseName 解析 .name 或 .*，返回消耗的字符数
+func parseName(s string, seg *jsonSegment) int {
+	if strings.HasPrefix(s, "*") {
+		seg.wildcard = true
+		return 1
+	}
+	n := 0
+	for n < len(s) && s[n] != '.' && s[n] != '[' {
+		n++
+	}
+	if n > 0 {
+		seg.keys = []string{s[:n]}
+	}
+	return n
+}
+
+// parseBracket 解析 [...]，返回消耗的字符数
+func parseBracket(s string, seg *jsonSegment) (int, error) {
+	end := closingBracket(s)
+	if end < 0 {
+		return 0, fmt.Errorf("unclosed [")
+	}
+	body := strings.TrimSpace(s[1:end])
+	switch {
+	case body == "*":
+		seg.wildcard = true
+	case strings.HasPrefix(body, "?"):
+		f, err := parseFilter(body[1:])
+		if err != nil {
+			return 0, err
+		}
+		seg.filter = f
+	case strings.Contains(body, ":") && !isQuoted(body):
+		parts := strings.SplitN(body, ":", 2)
+		var sl [2]*int
+		for k, part := range parts {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("invalid slice %q", body)
+			}
+			sl[k] = &n
+		}
+		seg.slice = &sl
+	default:
+		for _, part := range splitUnion(body) {
+			part = strings.TrimSpace(part)
+			if isQuoted(part) {
+				seg.keys = append(seg.keys, part[1:len(part)-1])
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("invalid index %q", part)
+			}
+			seg.indices = append(seg.indices, n)
+		}
+	}
+	return end + 1, nil
+}
+
+// closingBracket 返回与 s[0] 的 [ 匹配的 ] 位置，跳过引号内的字符
+func closingBracket(s string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[' || c == '(':
+			depth++
+		case c == ']' || c == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func splitUnion(s string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ',':
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func isQuoted(s string) bool {
+	return len(s) >= 2 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0]
+}
+
+// parseFilter 解析 (@.field op literal)
+func parseFilter(s string) (*jsonFilter, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "(") || !strings.HasSuffix(s, ")") {
+		return nil, fmt.Errorf("invalid filter %q", s)
+	}
+	s = strings.TrimSpace(s[1 : len(s)-1])
+	if !strings.HasPrefix(s, "@") {
+		return nil, fmt.Errorf("filter must start with @: %q", s)
+	}
+	f := &jsonFilter{}
+	left, right := s, ""
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if i := strings.Index(s, op); i > 0 {
+			left, right, f.op = strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+len(op):]), op
+			break
+		}
+	}
+	for _, name := range strings.Split(strings.TrimPrefix(left, "@"), ".") {
+		if name != "" {
+			f.field = append(f.field, name)
+		}
+	}
+	if f.op == "" {
+		return f, nil
+	}
+	switch {
+	case isQuoted(right):
+		f.value = right[1 : len(right)-1]
+	case right == "true" || right == "false":
+		f.value = right == "true"
+	case right == "null":
+		f.value = nil
+	default:
+		n, err := strconv.ParseFloat(right, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter value %q", right)
+		}
+		f.value = n
+	}
+	return f, nil
+}
+
+// Definite 表达式是否最多匹配一个值（不含通配、递归、切片、过滤、多个键或下标）
+func (p *JSONPath) Definite() bool {
+	for _, seg := range p.segments {
+		if seg.recursive || seg.wildcard || seg.slice != nil || seg.filter != nil || len(seg.keys)+len(seg.indices) > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// Eval 对 encoding/json 解析出的文档求值，返回全部匹配的值
+func (p *JSONPath) Eval(doc interface{}) []interface{} {
+	nodes := []interface{}{doc}
+	for _, seg := range p.segments {
+		var next []interface{}
+		for _, n := range nodes {
+			if seg.recursive {
+				for _, d := range descendants(n) {
+					next = append(next, seg.apply(d)...)
+				}
+				continue
+			}
+			next = append(next, seg.apply(n)...)
+		}
+		nodes = next
+	}
+	return nodes
+}
+
+// descendants 返回节点自身及全部后代（对象按键排序，保证结果顺序稳定）
+func descendants(n interface{}) []interface{} {
+	out := []interface{}{n}
+	for _, c := range children(n) {
+		out = append(out, descendants(c)...)
+	}
+	return out
+}
+
+func children(n interface{}) []interface{} {
+	switch v := n.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := make([]interface{}, len(keys))
+		for i, k := range keys {
+			out[i] = v[k]
+		}
+		return out
+	case []interface{}:
+		return v
+	}
+	return nil
+}
+
+func (seg jsonSegment) apply(n interface{}) []interface{} {
+	switch {
+	case seg.wildcard:
+		return children(n)
+	case seg.filter != nil:
+		var out []interface{}
+		for _, c := range children(n) {
+			if seg.filter.match(c) {
+				out = append(out, c)
+			}
+		}
+		return out
+	}
+	var out []interface{}
+	switch v := n.(type) {
+	case map[string]interface{}:
+		for _, k := range seg.keys {
+			if c, ok := v[k]; ok {
+				out = append(out, c)
+			}
+		}
+	case []interface{}:
+		for _, i := range seg.indices {
+			if i < 0 {
+				i += len(v)
+			}
+			if i >= 0 && i < len(v) {
+				out = append(out, v[i])
+			}
+		}
+		if seg.slice != nil {
+			start, end := 0, len(v)
+			if seg.slice[0] != nil {
+				start = clampIndex(*seg.slice[0], len(v))
+			}
+			if seg.slice[1] != nil {
+				end = clampIndex(*seg.slice[1], len(v))
+			}
+			for i := start; i < end; i++ {
+				out = append(out, v[i])
+			}
+		}
+	}
+	return out
+}
+
+func clampIndex(i, n int) int {
+	if i < 0 {
+		i += n
+	}
+	if i < 0 {
+		return 0
+	}
+	if i > n {
+		return n
+	}
+	return i
+}
+
+func (f *jsonFilter) match(n interface{}) bool {
+	v := n
+	for _, name := range f.field {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if v, ok = m[name]; !ok {
+			return false
+		}
+	}
+	if f.op == "" {
+		return true
+	}
+	if a, ok := v.(float64); ok {
+		if b, ok := f.value.(float64); ok {
+			switch f.op {
+			case "==":
+				return a == b
+			case "!=":
+				return a != b
+			case "<":
+				return a < b
+			case "<=":
+				return a <= b
+			case ">":
+				return a > b
+			case ">=":
+				return a >= b
+			}
+		}
+	}
+	if a, ok := v.(string); ok {
+		if b, ok := f.value.(string); ok {
+			switch f.op {
+			case "==":
+				return a == b
+			case "!=":
+				return a != b
+			case "<":
+				return a < b
+			case "<=":
+				return a <= b
+			case ">":
+				return a > b
+			case ">=":
+				return a >= b
+			}
+		}
+	}
+	switch f.op {
+	case "==":
+		return v == f.value
+	case "!=":
+		return v != f.value
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RawPoint 点位在原始报文中的位置：
+//
+//	"3"、"3:4"   从第 3 字节起按 Format 取值（长度默认取 Format 的字节数，未配置 Format 时为 1 字节）
+//	"3.0"        第 3 字节的第 0 位
+//	"re:<正则>"  对文本报文做正则匹配，取第 group 个分组（默认第 1 个，无分组时取整个匹配）
+type RawPoint struct {
+	offset int
+	length int // 0 为按 Format 或到帧末
+	bit    int // -1 为非位点位
+	re     *regexp.Regexp
+	group  int
+	format string
+}
+
+// ParseRawPoint 解析点位地址，options 中的 group 选择正则分组
+func ParseRawPoint(addr, format string, options map[string]interface{}) (RawPoint, error) {
+	p := RawPoint{bit: -1, format: format}
+	if expr, ok := strings.CutPrefix(addr, "re:"); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return p, err
+		}
+		p.re = re
+		if re.NumSubexp() > 0 {
+			p.group = 1
+		}
+		if g, ok := ToFloat64(options["group"]); ok {
+			if g < 0 || int(g) > re.NumSubexp() {
+				return p, fmt.Errorf("group %v out of range", g)
+			}
+			p.group = int(g)
+		}
+		return p, nil
+	}
+	s := addr
+	if off, n, ok := strings.Cut(s, ":"); ok {
+		length, err := strconv.Atoi(n)
+		if err != nil || length <= 0 {
+			return p, fmt.Errorf("invalid length in %q", addr)
+		}
+		s, p.length = off, length
+	} else if off, b, ok := strings.Cut(s, "."); ok {
+		bit, err := strconv.Atoi(b)
+		if err != nil || bit < 0 || bit > 7 {
+			return p, fmt.Errorf("invalid bit in %q", addr)
+		}
+		s, p.bit = off, bit
+	}
+	offset, err := strconv.Atoi(s)
+	if err != nil || offset < 0 {
+		return p, fmt.Errorf("invalid offset in %q", addr)
+	}
+	p.offset = offset
+	if p.length == 0 && p.bit < 0 {
+		p.length = rawFormatSize(format)
+		if format == "" {
+			p.length = 1
+		}
+	}
+	return p, nil
+}
+
+// rawFormatSize Format 占用的字节数，变长格式（STRING/HEX/BCD）或未配置时返回 0
+func rawFormatSize(format string) int {
+	switch strings.ToUpper(format) {
+	case "INT8", "UINT8":
+		return 1
+	}
+	return FormatSize(format)
+}
+
+// Extract 从报文中取值
+func (p RawPoint) Extract(frame []byte) (interface{}, error) {
+	if p.re != nil {
+		m := p.re.FindSubmatch(frame)
+		if m == nil || m[p.group] == nil {
+			return nil, fmt.Errorf("no match for %s", p.re)
+		}
+		s := strings.TrimSpace(string(m[p.group]))
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, nil
+		}
+		return s, nil
+	}
+	if p.offset >= len(frame) {
+		return nil, fmt.Errorf("offset %d beyond frame length %d", p.offset, len(frame))
+	}
+	if p.bit >= 0 {
+		return frame[p.offset]>>p.bit&1 == 1, nil
+	}
+	raw := frame[p.offset:]
+	if p.length > 0 {
+		if p.offset+p.length > len(frame) {
+			return nil, fmt.Errorf("bytes %d:%d beyond frame length %d", p.offset, p.length, len(frame))
+		}
+		raw = raw[:p.length]
+	}
+	return DecodeBytes(p.format, raw)
+}
+
+// DecodeBytes 按 Format 解码：INT8/UINT8、STRING、HEX、BCD 以及 ParseFormat 支持的寄存器格式，
+// 未配置 Format 时 1/2/4/8 字节按大端无符号整数，其他长度返回十六进制字符串
+func DecodeBytes(format string, raw []byte) (interface{}, error) {
+	switch strings.ToUpper(format) {
+	case "INT8":
+		return int8(raw[0]), nil
+	case "UINT8":
+		return raw[0], nil
+	case "STRING":
+		return strings.TrimRight(string(raw), "\x00 "), nil
+	case "HEX":
+		return strings.ToUpper(hex.EncodeToString(raw)), nil
+	case "BCD":
+		var v uint64
+		for _, b := range raw {
+			hi, lo := b>>4, b&0x0F
+			if hi > 9 || lo > 9 {
+				return nil, fmt.Errorf("invalid BCD byte %02X", b)
+			}
+			v = v*100 + uint64(hi)*10 + uint64(lo)
+		}
+		return v, nil
+	case "":
+		switch len(raw) {
+		case 1:
+			return raw[0], nil
+		case 2:
+			return binary.BigEndian.Uint16(raw), nil
+		case 4:
+			return binary.BigEndian.Uint32(raw), nil
+		case 8:
+			return binary.BigEndian.Uint64(raw), nil
+		}
+		return strings.ToUpper(hex.EncodeToString(raw)), nil
+	}
+	f := CanonicalFormat(format)
+	if f == "" {
+		return nil, fmt.Errorf("unknown format %q", format)
+	}
+	return ParseFormat(f, raw)
+}
@@ -0,0 +1,286 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// XMLNode 简化的 XML 元素树（忽略命名空间前缀，只按本地名匹配）
+type XMLNode struct {
+	name     string
+	attrs    map[string]string
+	children []*XMLNode
+	text     strings.Builder
+}
+
+// ParseXML 解析 XML 文档，返回虚拟根节点（其唯一子节点为文档元素）
+func ParseXML(data []byte) (*XMLNode, error) {
+	root := &XMLNode{}
+	stack := []*XMLNode{root}
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	dec.Strict = false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &XMLNode{name: t.Name.Local, attrs: make(map[string]string, len(t.Attr))}
+			for _, a := range t.Attr {
+				n.attrs[a.Name.Local] = a.Value
+			}
+			parent := stack[len(stack)-1]
+			parent.children = append(parent.children, n)
+			stack = append(stack, n)
+		case xml.EndElement:
+			if len(stack) > 1 {
+				stack = stack[:len(stack)-1]
+			}
+		case xml.CharData:
+			stack[len(stack)-1].text.Write(t)
+		}
+	}
+	if len(root.children) == 0 {
+		return nil, fmt.Errorf("empty xml document")
+	}
+	return root, nil
+}
+
+// textContent 元素及其后代的文本
+func (n *XMLNode) textContent() string {
+	var b strings.Builder
+	var walk func(*XMLNode)
+	walk = func(n *XMLNode) {
+		b.WriteString(n.text.String())
+		for _, c := range n.children {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.TrimSpace(b.String())
+}
+
+// XPath 编译后的 XPath 表达式，支持的语法：
+//
+//	/a/b、//b、*            子元素、后代元素、任意元素
+//	[2]、[last()]          位置（从 1 开始）
+//	[@id='x']、[name='x']  属性或子元素文本等于
+//	[@id]                  属性存在
+//	/@attr、/text()        取属性值、元素自身文本（否则取元素的全部文本）
+type XPath struct {
+	expr  string
+	steps []xStep
+	attr  string // 末尾的 @attr
+	text  bool   // 末尾的 text()
+}
+
+type xStep struct {
+	descendant bool
+	name       string // * 为任意元素
+	preds      []xPred
+}
+
+type xPred struct {
+	pos   int // 1 起的位置，-1 为 last()
+	attr  string
+	child string
+	value *string
+}
+
+// CompileXPath 解析 XPath 表达式（必须为 / 开头的绝对路径）
+func CompileXPath(expr string) (*XPath, error) {
+	s := strings.TrimSpace(expr)
+	if !strings.HasPrefix(s, "/") {
+		return nil, fmt.Errorf("xpath %q: must start with /", expr)
+	}
+	p := &XPath{expr: expr}
+	for len(s) > 0 {
+		var step xStep
+		if strings.HasPrefix(s, "//") {
+			step.descendant, s = true, s[2:]
+		} else if strings.HasPrefix(s, "/") {
+			s = s[1:]
+		} else {
+			return nil, fmt.Errorf("xpath %q: expected /", expr)
+		}
+		end := 0
+		for end < len(s) && s[end] != '/' && s[end] != '[' {
+			end++
+		}
+		name := s[:end]
+		s = s[end:]
+		switch {
+		case strings.HasPrefix(name, "@"):
+			if s != "" || step.descendant || len(name) == 1 {
+				return nil, fmt.Errorf("xpath %q: @attr must be the last step", expr)
+			}
+			p.attr = name[1:]
+			return p, nil
+		case name == "text()":
+			if s != "" || step.descendant {
+				return nil, fmt.Errorf("xpath %q: text() must be the last step", expr)
+			}
+			p.text = true
+			return p, nil
+		case name == "":
+			return nil, fmt.Errorf("xpath %q: empty step", expr)
+		}
+		if i := strings.Index(name, ":"); i >= 0 {
+			name = name[i+1:]
+		}
+		step.name = name
+		for strings.HasPrefix(s, "[") {
+			end := closingBracket(s)
+			if end < 0 {
+				return nil, fmt.Errorf("xpath %q: unclosed [", expr)
+			}
+			pred, err := parsePred(strings.TrimSpace(s[1:end]))
+			if err != nil {
+				return nil, fmt.Errorf("xpath %q: %v", expr, err)
+			}
+			step.preds = append(step.preds, pred)
+			s = s[end+1:]
+		}
+		p.steps = append(p.steps, step)
+	}
+	if len(p.steps) == 0 {
+		return nil, fmt.Errorf("xpath %q: no steps", expr)
+	}
+	return p, nil
+}
+
+func parsePred(s string) (xPred, error) {
+	if s == "last()" {
+		return xPred{pos: -1}, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 1 {
+			return xPred{}, fmt.Errorf("invalid position %d", n)
+		}
+		return xPred{pos: n}, nil
+	}
+	var pred xPred
+	left := s
+	if i := strings.Index(s, "="); i > 0 {
+		left = strings.TrimSpace(s[:i])
+		right := strings.TrimSpace(s[i+1:])
+		if !isQuoted(right) {
+			return xPred{}, fmt.Errorf("predicate value must be quoted: %q", s)
+		}
+		v := right[1 : len(right)-1]
+		pred.value = &v
+	}
+	if strings.HasPrefix(left, "@") {
+		pred.attr = left[1:]
+	} else {
+		pred.child = left
+	}
+	if pred.attr == "" && pred.child == "" {
+		return xPred{}, fmt.Errorf("invalid predicate %q", s)
+	}
+	return pred, nil
+}
+
+// Definite 表达式是否按固定路径取值（不含 // 和 *）
+func (p *XPath) Definite() bool {
+	for _, step := range p.steps {
+		if step.descendant || step.name == "*" {
+			return false
+		}
+	}
+	return true
+}
+
+// Eval 求值，返回匹配元素的文本或属性值
+func (p *XPath) Eval(root *XMLNode) []interface{} {
+	nodes := []*XMLNode{root}
+	for _, step := range p.steps {
+		var next []*XMLNode
+		for _, n := range nodes {
+			var candidates []*XMLNode
+			if step.descendant {
+				for _, c := range n.children {
+					candidates = append(candidates, c.selfAndDescendants()...)
+				}
+			} else {
+				candidates = n.children
+			}
+			var matched []*XMLNode
+			for _, c := range candidates {
+				if step.name == "*" || c.name == step.name {
+					matched = append(matched, c)
+				}
+			}
+			next = append(next, applyPreds(matched, step.preds)...)
+		}
+		nodes = next
+	}
+	var out []interface{}
+	for _, n := range nodes {
+		switch {
+		case p.attr != "":
+			if v, ok := n.attrs[p.attr]; ok {
+				out = append(out, v)
+			}
+		case p.text:
+			out = append(out, strings.TrimSpace(n.text.String()))
+		default:
+			out = append(out, n.textContent())
+		}
+	}
+	return out
+}
+
+func (n *XMLNode) selfAndDescendants() []*XMLNode {
+	out := []*XMLNode{n}
+	for _, c := range n.children {
+		out = append(out, c.selfAndDescendants()...)
+	}
+	return out
+}
+
+func applyPreds(nodes []*XMLNode, preds []xPred) []*XMLNode {
+	for _, pred := range preds {
+		switch {
+		case pred.pos == -1:
+			if len(nodes) > 0 {
+				nodes = nodes[len(nodes)-1:]
+			}
+		case pred.pos > 0:
+			if pred.pos > len(nodes) {
+				return nil
+			}
+			nodes = nodes[pred.pos-1 : pred.pos]
+		default:
+			var kept []*XMLNode
+			for _, n := range nodes {
+				if pred.match(n) {
+					kept = append(kept, n)
+				}
+			}
+			nodes = kept
+		}
+	}
+	return nodes
+}
+
+func (pred xPred) match(n *XMLNode) bool {
+	if pred.attr != "" {
+		v, ok := n.attrs[pred.attr]
+		return ok && (pred.value == nil || v == *pred.value)
+	}
+	for _, c := range n.children {
+		if c.name == pred.child && (pred.value == nil || c.textContent() == *pred.value) {
+			return true
+		}
+	}
+	return false
+}